@@ -11,6 +11,10 @@ import (
 	"tinygo.org/x/drivers"
 )
 
+// ErrOutOfRange is returned when bytes or pages beyond the end of the EEPROM
+// are read, written or erased.
+var ErrOutOfRange = errors.New("at24cx: out of range")
+
 // Device wraps an I2C connection to a DS3231 device.
 type Device struct {
 	bus               drivers.I2C
@@ -74,9 +78,13 @@ func (d *Device) ReadByte(eepromAddress uint16) (uint8, error) {
 	return data[0], err
 }
 
-// WriteAt writes a byte array at the specified address
+// WriteAt writes a byte array at the specified offset from StartRAMAddress.
+// The data must be within Size.
 func (d *Device) WriteAt(data []byte, offset int64) (n int, err error) {
-	return d.writeAt(data, uint16(offset))
+	if !d.inRange(offset, len(data)) {
+		return 0, ErrOutOfRange
+	}
+	return d.writeAt(data, d.startRAMAddress+uint16(offset))
 }
 
 // writeAt writes a byte array at the specified address
@@ -118,9 +126,19 @@ func (d *Device) writeAt(data []byte, offset uint16) (n int, err error) {
 	return len(data), nil
 }
 
-// ReadAt reads the bytes at the specified address
+// ReadAt reads the bytes at the specified offset from StartRAMAddress. The
+// data must be within Size.
 func (d *Device) ReadAt(data []byte, offset int64) (n int, err error) {
-	return d.readAt(data, uint16(offset))
+	if !d.inRange(offset, len(data)) {
+		return 0, ErrOutOfRange
+	}
+	return d.readAt(data, d.startRAMAddress+uint16(offset))
+}
+
+// inRange returns whether n bytes at offset from StartRAMAddress are within
+// Size.
+func (d *Device) inRange(offset int64, n int) bool {
+	return offset >= 0 && int64(n) <= d.Size()-offset
 }
 
 // readAt reads the bytes at the specified address
@@ -170,3 +188,45 @@ func (d *Device) Write(data []byte) (n int, err error) {
 func (d *Device) Read(data []uint8) (n int, err error) {
 	return d.readAt(data, d.currentRAMAddress)
 }
+
+// Size returns the size of the EEPROM in bytes, from StartRAMAddress to
+// EndRAMAddress. It is 0 until Configure is called.
+func (d *Device) Size() int64 {
+	return int64(d.endRAMAddress) - int64(d.startRAMAddress)
+}
+
+// WriteBlockSize returns the page size of the EEPROM.
+func (d *Device) WriteBlockSize() int64 {
+	return int64(d.pageSize)
+}
+
+// EraseBlockSize returns the page size of the EEPROM. An EEPROM doesn't need
+// to be erased before writing, but erasing is supported so that the device
+// can be used as a drivers.BlockDevice.
+func (d *Device) EraseBlockSize() int64 {
+	return int64(d.pageSize)
+}
+
+// EraseBlocks erases the given number of pages by filling them with 0xff, the
+// erased state of flash memory. Pages are counted from StartRAMAddress, like
+// the offsets of ReadAt and WriteAt, and must be within Size.
+func (d *Device) EraseBlocks(start, count int64) error {
+	if d.pageSize == 0 {
+		return ErrOutOfRange
+	}
+	pages := d.Size() / int64(d.pageSize)
+	if start < 0 || count < 0 || start > pages || count > pages-start {
+		return ErrOutOfRange
+	}
+	erased := make([]uint8, d.pageSize)
+	for i := range erased {
+		erased[i] = 0xff
+	}
+	for page := start; page < start+count; page++ {
+		addr := uint32(d.startRAMAddress) + uint32(page)*uint32(d.pageSize)
+		if _, err := d.writeAt(erased, uint16(addr)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package at24cx
+
+import (
+	"bytes"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+// erased returns the write of n erased bytes at addr.
+func erased(addr uint16, n int) []byte {
+	return append([]byte{uint8(addr >> 8), uint8(addr)}, bytes.Repeat([]byte{0xff}, n)...)
+}
+
+func TestEraseBlocks(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewI2CBus(c)
+	dev := tester.NewI2CDeviceLog(c, Address)
+	bus.AddDevice(dev)
+
+	d := New(bus)
+	c.Assert(d.Size(), qt.Equals, int64(0))
+	c.Assert(d.EraseBlocks(0, 1), qt.Equals, ErrOutOfRange)
+
+	d.Configure(Config{})
+	c.Assert(d.Size(), qt.Equals, int64(4096))
+	c.Assert(d.EraseBlockSize(), qt.Equals, int64(32))
+
+	// A page is written in two parts, as the address takes two bytes of the
+	// 32-byte buffer.
+	c.Assert(d.EraseBlocks(1, 2), qt.IsNil)
+	c.Assert(dev.Writes, qt.DeepEquals, [][]byte{
+		erased(0x20, 30), erased(0x3E, 2),
+		erased(0x40, 30), erased(0x5E, 2),
+	})
+
+	dev.Writes = nil
+	c.Assert(d.EraseBlocks(127, 1), qt.IsNil)
+	c.Assert(dev.Writes, qt.DeepEquals, [][]byte{erased(0xFE0, 30), erased(0xFFE, 2)})
+
+	dev.Writes = nil
+	c.Assert(d.EraseBlocks(127, 2), qt.Equals, ErrOutOfRange)
+	c.Assert(d.EraseBlocks(128, 0), qt.IsNil)
+	c.Assert(d.EraseBlocks(129, 0), qt.Equals, ErrOutOfRange)
+	c.Assert(d.EraseBlocks(-1, 1), qt.Equals, ErrOutOfRange)
+	c.Assert(d.EraseBlocks(0, -1), qt.Equals, ErrOutOfRange)
+	// The page would wrap around to 0 in 16 bits.
+	c.Assert(d.EraseBlocks(2048, 1), qt.Equals, ErrOutOfRange)
+	c.Assert(d.EraseBlocks(1, 1<<62), qt.Equals, ErrOutOfRange)
+	c.Assert(dev.Writes, qt.HasLen, 0)
+}
+
+// eeprom is a bus with an EEPROM with 16-bit addresses.
+type eeprom struct {
+	mem [0x1000]byte
+}
+
+func (e *eeprom) Tx(addr uint16, w, r []byte) error {
+	if addr != Address {
+		return tester.ErrNACK
+	}
+	a := int(w[0])<<8 | int(w[1])
+	copy(e.mem[a:], w[2:])
+	copy(r, e.mem[a:])
+	return nil
+}
+
+func TestStartAddress(t *testing.T) {
+	c := qt.New(t)
+	bus := &eeprom{}
+	d := New(bus)
+	d.Configure(Config{StartRAMAddress: 0x100, EndRAMAddress: 0x200})
+	c.Assert(d.Size(), qt.Equals, int64(0x100))
+
+	// All offsets are counted from the start address.
+	c.Assert(d.EraseBlocks(0, 1), qt.IsNil)
+	buf := make([]byte, 32)
+	n, err := d.ReadAt(buf, 0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 32)
+	c.Assert(buf, qt.DeepEquals, bytes.Repeat([]byte{0xff}, 32))
+	c.Assert(bus.mem[0xFF], qt.Equals, uint8(0))
+	c.Assert(bus.mem[0x100], qt.Equals, uint8(0xff))
+
+	n, err = d.WriteAt([]byte("hello"), 0xFB)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 5)
+	c.Assert(string(bus.mem[0x1FB:0x200]), qt.Equals, "hello")
+	n, err = d.ReadAt(buf[:5], 0xFB)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[:n]), qt.Equals, "hello")
+
+	// Nothing is read or written outside of the range.
+	_, err = d.WriteAt([]byte("hello"), 0xFC)
+	c.Assert(err, qt.Equals, ErrOutOfRange)
+	_, err = d.ReadAt(buf[:5], 0xFC)
+	c.Assert(err, qt.Equals, ErrOutOfRange)
+	_, err = d.ReadAt(buf[:1], -1)
+	c.Assert(err, qt.Equals, ErrOutOfRange)
+	c.Assert(d.EraseBlocks(8, 1), qt.Equals, ErrOutOfRange)
+	c.Assert(bus.mem[0x200], qt.Equals, uint8(0))
+}
@@ -0,0 +1,33 @@
+package drivers
+
+import "io"
+
+// BlockDevice is a storage device that can be read and written at arbitrary
+// offsets and erased in blocks, such as NOR flash, EEPROM or an SD card. It is
+// implemented by flash.Device, sdcard.Device and at24cx.Device and is what the
+// filesystem and storage packages are built on.
+type BlockDevice interface {
+	// ReadAt reads len(p) bytes starting at offset off.
+	io.ReaderAt
+
+	// WriteAt writes len(p) bytes starting at offset off. Depending on the
+	// device, the destination may need to be erased first (see EraseBlocks).
+	// Non-aligned writes must work correctly, although they may be slower.
+	io.WriterAt
+
+	// Size returns the number of bytes in the device.
+	Size() int64
+
+	// WriteBlockSize returns the block size in which data can be written to
+	// memory. It can be used by a client to optimize writes.
+	WriteBlockSize() int64
+
+	// EraseBlockSize returns the smallest erasable area in bytes. This is
+	// used for the block size in EraseBlocks.
+	EraseBlockSize() int64
+
+	// EraseBlocks erases the given number of blocks. The start and len
+	// parameters are in block numbers, use EraseBlockSize to map addresses
+	// to blocks.
+	EraseBlocks(start, len int64) error
+}
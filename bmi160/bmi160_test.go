@@ -0,0 +1,62 @@
+package bmi160
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestConfigure(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewSPIBus(c)
+	dev := tester.NewSPIScript(c)
+	cs := bus.AddDevice(dev)
+
+	// The dummy read that switches the chip to SPI.
+	dev.Expect([]byte{0x80 | 0x7F, 0}, nil)
+	// Accelerometer to normal mode, the command is busy once.
+	dev.Expect([]byte{reg_CMD, 0x11}, nil)
+	dev.Expect([]byte{0x80 | reg_CMD, 0}, []byte{0, 0x11})
+	dev.Expect([]byte{0x80 | reg_CMD, 0}, []byte{0, 0})
+	// Gyroscope to normal mode.
+	dev.Expect([]byte{reg_CMD, 0x15}, nil)
+	dev.Expect([]byte{0x80 | reg_CMD, 0}, []byte{0, 0})
+	// Both are powered up.
+	dev.Expect([]byte{0x80 | reg_PMU_STATUS, 0}, []byte{0, 0x14})
+
+	d := NewSPI(cs, bus)
+	c.Assert(d.Configure(), qt.IsNil)
+	dev.Done()
+	c.Assert(cs.Get(), qt.IsTrue)
+}
+
+func TestRead(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewSPIBus(c)
+	dev := tester.NewSPIDevice8(c)
+	d := NewSPI(bus.AddDevice(dev), bus)
+
+	c.Assert(d.Connected(), qt.IsFalse)
+	dev.Registers[reg_CHIPID] = 0xD1
+	c.Assert(d.Connected(), qt.IsTrue)
+
+	// 512/32768 of 64°C above 23°C.
+	dev.Registers[reg_TEMPERATURE_0] = 0x00
+	dev.Registers[reg_TEMPERATURE_0+1] = 0x02
+	temp, err := d.ReadTemperature()
+	c.Assert(err, qt.IsNil)
+	c.Assert(temp, qt.Equals, int32(24000))
+
+	// 1g on X and -1g on Y at the default range of ±2g.
+	copy(dev.Registers[reg_ACC_XL:], []byte{0x00, 0x40, 0x00, 0xC0, 0x00, 0x00})
+	x, y, z, err := d.ReadAcceleration()
+	c.Assert(err, qt.IsNil)
+	c.Assert([]int32{x, y, z}, qt.DeepEquals, []int32{1000000, -1000000, 0})
+
+	// ±1.95°/s at the default range of ±2000°/s.
+	copy(dev.Registers[reg_GYR_XL:], []byte{0x20, 0x00, 0xE0, 0xFF, 0x00, 0x00})
+	x, y, z, err = d.ReadRotation()
+	c.Assert(err, qt.IsNil)
+	c.Assert([]int32{x, y, z}, qt.DeepEquals, []int32{1953125, -1953125, 0})
+}
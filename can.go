@@ -0,0 +1,43 @@
+package drivers
+
+// CANFrame is a frame on a CAN bus.
+type CANFrame struct {
+	// ID is the 11-bit standard or 29-bit extended identifier.
+	ID uint32
+
+	// Extended is set for frames with a 29-bit identifier.
+	Extended bool
+
+	// Remote is set for remote transmission request (RTR) frames, which
+	// have no data.
+	Remote bool
+
+	// DLC is the data length code, the number of data bytes (0 to 8).
+	DLC uint8
+
+	// Data holds the data bytes, of which the first DLC are used.
+	Data [8]byte
+}
+
+// Payload returns the data bytes of the frame.
+func (f *CANFrame) Payload() []byte {
+	if f.Remote {
+		return nil
+	}
+	n := f.DLC
+	if n > 8 {
+		n = 8
+	}
+	return f.Data[:n]
+}
+
+// CAN is a CAN bus controller. It is implemented by mcp2515.Device.
+type CAN interface {
+	// Transmit queues a frame for transmission. It returns once the
+	// controller accepted the frame, which may be before it has been sent.
+	Transmit(frame *CANFrame) error
+
+	// Receive copies the next received frame into frame. It doesn't wait for
+	// a frame to arrive, and returns false if there is none.
+	Receive(frame *CANFrame) (bool, error)
+}
@@ -0,0 +1,124 @@
+// Package canopen implements a minimal CANopen (CiA 301) device and the
+// messages to control CANopen networks: network management (NMT), service
+// data objects (SDO) to read and write the object dictionary, process data
+// objects (PDO) and heartbeats.
+//
+// The predefined connection set is used: the CAN IDs are derived from the
+// node ID, from 1 to 127. PDO mapping is left to the application, which
+// sends and receives the raw PDO data. It works on any drivers.CAN
+// controller, such as the mcp2515.
+package canopen // import "tinygo.org/x/drivers/can/canopen"
+
+import (
+	"errors"
+	"strconv"
+
+	"tinygo.org/x/drivers"
+)
+
+var (
+	ErrTimeout        = errors.New("canopen: timeout")
+	ErrNotOperational = errors.New("canopen: node not operational")
+	ErrInvalidPDO     = errors.New("canopen: invalid PDO number")
+	ErrBufferTooSmall = errors.New("canopen: buffer too small")
+	ErrProtocol       = errors.New("canopen: unexpected SDO response")
+)
+
+// Function codes, the upper bits of the CAN IDs of the predefined
+// connection set. The node ID is added to all but NMT and SYNC.
+const (
+	FunctionNMT       = 0x000
+	FunctionSync      = 0x080
+	FunctionEmergency = 0x080
+	FunctionTPDO1     = 0x180
+	FunctionRPDO1     = 0x200
+	FunctionSDOTx     = 0x580 // SDO responses of the server
+	FunctionSDORx     = 0x600 // SDO requests to the server
+	FunctionHeartbeat = 0x700
+)
+
+// State is the NMT state of a node.
+type State uint8
+
+const (
+	StateInitializing   State = 0x00
+	StateStopped        State = 0x04
+	StateOperational    State = 0x05
+	StatePreOperational State = 0x7F
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateInitializing:
+		return "initializing"
+	case StateStopped:
+		return "stopped"
+	case StateOperational:
+		return "operational"
+	case StatePreOperational:
+		return "pre-operational"
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Command is an NMT command.
+type Command uint8
+
+const (
+	CommandStart               Command = 0x01
+	CommandStop                Command = 0x02
+	CommandEnterPreOperational Command = 0x80
+	CommandResetNode           Command = 0x81
+	CommandResetCommunication  Command = 0x82
+)
+
+// SendNMT sends an NMT command to a node, or to all nodes if node is 0.
+func SendNMT(bus drivers.CAN, cmd Command, node uint8) error {
+	return bus.Transmit(&drivers.CANFrame{
+		ID:   FunctionNMT,
+		DLC:  2,
+		Data: [8]byte{byte(cmd), node},
+	})
+}
+
+// SendSync sends a SYNC message, on which nodes send their synchronous
+// PDOs.
+func SendSync(bus drivers.CAN) error {
+	return bus.Transmit(&drivers.CANFrame{ID: FunctionSync})
+}
+
+// ParseHeartbeat returns the node ID and state of a heartbeat or boot-up
+// message. It returns false if the frame is no heartbeat.
+func ParseHeartbeat(f *drivers.CANFrame) (node uint8, state State, ok bool) {
+	if f.Extended || f.Remote || f.DLC != 1 || f.ID&^0x7f != FunctionHeartbeat || f.ID == FunctionHeartbeat {
+		return 0, 0, false
+	}
+	return uint8(f.ID & 0x7f), State(f.Data[0] & 0x7f), true
+}
+
+// SDO abort codes.
+const (
+	AbortToggle         = 0x05030000 // toggle bit not alternated
+	AbortTimeout        = 0x05040000 // SDO protocol timed out
+	AbortCommand        = 0x05040001 // command specifier not valid
+	AbortOutOfMemory    = 0x05040005
+	AbortReadOnly       = 0x06010002 // attempt to write a read only object
+	AbortNoObject       = 0x06020000 // object does not exist
+	AbortLength         = 0x06070010 // length of service parameter does not match
+	AbortNoSubindex     = 0x06090011 // sub-index does not exist
+	AbortGeneral        = 0x08000000
+	AbortNotInThisState = 0x08000022 // data cannot be transferred in this state
+)
+
+// SDOAbortError is returned when an SDO transfer was aborted.
+type SDOAbortError struct {
+	Index    uint16
+	Subindex uint8
+	Code     uint32
+}
+
+func (e SDOAbortError) Error() string {
+	return "canopen: SDO transfer of object 0x" + strconv.FormatUint(uint64(e.Index), 16) +
+		" sub " + strconv.Itoa(int(e.Subindex)) + " aborted with code 0x" + strconv.FormatUint(uint64(e.Code), 16)
+}
@@ -0,0 +1,240 @@
+package canopen
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/tester"
+)
+
+func objects() []Object {
+	return []Object{
+		{Index: 0x1000, Subindex: 0, Data: []byte{0x91, 0x01, 0x0F, 0x00}}, // device type
+		{Index: 0x1008, Subindex: 0, Data: []byte("tinygo canopen node")},  // device name
+		{Index: 0x1017, Subindex: 0, Data: []byte{0, 0}, Writable: true},   // heartbeat time
+		{Index: 0x2000, Subindex: 0, Data: []byte{1}},
+		{Index: 0x2000, Subindex: 1, Data: make([]byte, 4), Writable: true},
+		{Index: 0x2000, Subindex: 2, Data: make([]byte, 12), Writable: true},
+		{Index: 0x2001, Subindex: 0, Data: []byte{}, Writable: true},
+	}
+}
+
+func TestNMT(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	master := bus.Node()
+	n := New(bus.Node(), 5, objects())
+	c.Assert(n.Start(), qt.IsNil)
+	c.Assert(n.State(), qt.Equals, StatePreOperational)
+
+	// Boot-up message.
+	var f drivers.CANFrame
+	ok, _ := master.Receive(&f)
+	c.Assert(ok, qt.IsTrue)
+	node, state, ok := ParseHeartbeat(&f)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(node, qt.Equals, uint8(5))
+	c.Assert(state, qt.Equals, StateInitializing)
+
+	for _, test := range []struct {
+		cmd   Command
+		node  uint8
+		state State
+	}{
+		{CommandStart, 5, StateOperational},
+		{CommandStop, 0, StateStopped},
+		{CommandStart, 6, StateStopped},
+		{CommandEnterPreOperational, 5, StatePreOperational},
+		{CommandStart, 0, StateOperational},
+		{CommandResetCommunication, 5, StatePreOperational},
+	} {
+		c.Assert(SendNMT(master, test.cmd, test.node), qt.IsNil)
+		c.Assert(n.Poll(), qt.IsNil)
+		c.Assert(n.State(), qt.Equals, test.state, qt.Commentf("%s", test.state))
+	}
+	// The reset sent another boot-up message.
+	ok, _ = master.Receive(&f)
+	c.Assert(ok, qt.IsTrue)
+	_, state, _ = ParseHeartbeat(&f)
+	c.Assert(state, qt.Equals, StateInitializing)
+
+	_, _, ok = ParseHeartbeat(&drivers.CANFrame{ID: FunctionSDOTx + 5, DLC: 1})
+	c.Assert(ok, qt.IsFalse)
+}
+
+func TestHeartbeat(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	master := bus.Node()
+	n := New(bus.Node(), 5, objects())
+	c.Assert(n.Start(), qt.IsNil)
+
+	// Disabled by default.
+	c.Assert(n.Poll(), qt.IsNil)
+	c.Assert(master.Pending(), qt.Equals, 1)
+
+	n.Object(0x1017, 0).Data[0] = 10
+	for i := 0; i < 5; i++ {
+		time.Sleep(5 * time.Millisecond)
+		c.Assert(n.Poll(), qt.IsNil)
+	}
+	c.Assert(master.Pending() > 1, qt.IsTrue)
+	var f drivers.CANFrame
+	for master.Pending() > 0 {
+		master.Receive(&f)
+	}
+	_, state, ok := ParseHeartbeat(&f)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(state, qt.Equals, StatePreOperational)
+	c.Assert(state.String(), qt.Equals, "pre-operational")
+}
+
+// serve polls the node in a goroutine until the test ends.
+func serve(t *testing.T, n *Node) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			n.Poll()
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+}
+
+func TestSDO(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	client := NewSDOClient(bus.Node(), 5)
+	n := New(bus.Node(), 5, objects())
+	c.Assert(n.Start(), qt.IsNil)
+	serve(t, n)
+
+	// Expedited transfers.
+	buf := make([]byte, 32)
+	size, err := client.Read(0x1000, 0, buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:size], qt.DeepEquals, []byte{0x91, 0x01, 0x0F, 0x00})
+	c.Assert(client.Write(0x2000, 1, []byte{1, 2, 3, 4}), qt.IsNil)
+	size, err = client.Read(0x2000, 1, buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:size], qt.DeepEquals, []byte{1, 2, 3, 4})
+	size, err = client.Read(0x2000, 0, buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:size], qt.DeepEquals, []byte{1})
+
+	// Segmented transfers.
+	size, err = client.Read(0x1008, 0, buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[:size]), qt.Equals, "tinygo canopen node")
+	c.Assert(client.Write(0x2000, 2, []byte("hello, world")), qt.IsNil)
+	size, err = client.Read(0x2000, 2, buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[:size]), qt.Equals, "hello, world")
+
+	// Empty objects, which are transferred as a single empty segment.
+	size, err = client.Read(0x2001, 0, buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, 0)
+	c.Assert(client.Write(0x2001, 0, nil), qt.IsNil)
+
+	_, err = client.Read(0x1008, 0, buf[:8])
+	c.Assert(err, qt.Equals, ErrBufferTooSmall)
+	_, err = client.Read(0x1000, 0, buf[:2])
+	c.Assert(err, qt.Equals, ErrBufferTooSmall)
+
+	// Aborted transfers.
+	for _, test := range []struct {
+		index    uint16
+		subindex uint8
+		data     []byte
+		code     uint32
+	}{
+		{0x3000, 0, nil, AbortNoObject},
+		{0x2000, 3, nil, AbortNoSubindex},
+		{0x1000, 0, []byte{1, 2, 3, 4}, AbortReadOnly},
+		{0x2000, 1, []byte{1, 2}, AbortLength},
+		{0x2000, 2, []byte("too long for the object"), AbortLength},
+		{0x2000, 1, []byte{}, AbortLength},
+	} {
+		if test.data == nil {
+			_, err = client.Read(test.index, test.subindex, buf)
+		} else {
+			err = client.Write(test.index, test.subindex, test.data)
+		}
+		c.Assert(err, qt.Equals, SDOAbortError{Index: test.index, Subindex: test.subindex, Code: test.code})
+	}
+	c.Assert(err.Error(), qt.Equals, "canopen: SDO transfer of object 0x2000 sub 1 aborted with code 0x6070010")
+
+	// Nobody answers.
+	client = NewSDOClient(bus.Node(), 6)
+	client.Timeout = 10 * time.Millisecond
+	_, err = client.Read(0x1000, 0, buf)
+	c.Assert(err, qt.Equals, ErrTimeout)
+}
+
+func TestSDOEmptyUpload(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	master := bus.Node()
+	n := New(bus.Node(), 5, objects())
+	c.Assert(n.Start(), qt.IsNil)
+	var f drivers.CANFrame
+	master.Receive(&f) // boot-up
+
+	// The response announces a segmented transfer of 0 bytes, not an
+	// expedited one whose size would spill into the command specifier.
+	req := drivers.CANFrame{ID: FunctionSDORx + 5, DLC: 8, Data: [8]byte{ccsInitiateUpload, 0x01, 0x20, 0}}
+	c.Assert(master.Transmit(&req), qt.IsNil)
+	c.Assert(n.Poll(), qt.IsNil)
+	ok, _ := master.Receive(&f)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(f.Data, qt.Equals, [8]byte{scsInitiateUpload | sdoSize, 0x01, 0x20, 0, 0, 0, 0, 0})
+}
+
+func TestPDO(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	master := bus.Node()
+	n := New(bus.Node(), 5, objects())
+	var pdos []int
+	n.PDOHandler = func(pdo int, data []byte) {
+		pdos = append(pdos, pdo)
+		c.Assert(data, qt.DeepEquals, []byte{byte(pdo)})
+	}
+	c.Assert(n.Start(), qt.IsNil)
+	var f drivers.CANFrame
+	master.Receive(&f)
+
+	c.Assert(n.SendPDO(1, []byte{1}), qt.Equals, ErrNotOperational)
+	master.Transmit(&drivers.CANFrame{ID: 0x305, DLC: 1, Data: [8]byte{2}})
+	c.Assert(n.Poll(), qt.IsNil)
+	c.Assert(pdos, qt.HasLen, 0)
+
+	SendNMT(master, CommandStart, 5)
+	for pdo := 1; pdo <= 4; pdo++ {
+		master.Transmit(&drivers.CANFrame{ID: uint32(0x100 + pdo*0x100 + 5), DLC: 1, Data: [8]byte{byte(pdo)}})
+	}
+	// A TPDO, which nodes only send, and an RPDO to another node.
+	master.Transmit(&drivers.CANFrame{ID: 0x185, DLC: 1})
+	master.Transmit(&drivers.CANFrame{ID: 0x206, DLC: 1})
+	c.Assert(n.Poll(), qt.IsNil)
+	c.Assert(pdos, qt.DeepEquals, []int{1, 2, 3, 4})
+
+	c.Assert(n.SendPDO(3, []byte{1, 2, 3}), qt.IsNil)
+	ok, _ := master.Receive(&f)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(f, qt.Equals, drivers.CANFrame{ID: 0x385, DLC: 3, Data: [8]byte{1, 2, 3}})
+	c.Assert(n.SendPDO(5, nil), qt.Equals, ErrInvalidPDO)
+}
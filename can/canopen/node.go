@@ -0,0 +1,172 @@
+package canopen
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// Object is an entry of the object dictionary of a node.
+type Object struct {
+	Index    uint16
+	Subindex uint8
+
+	// Data holds the value, least significant byte first. Writes through
+	// SDO must have the same length.
+	Data []byte
+
+	Writable bool
+}
+
+// Node is a CANopen device. It answers NMT commands and SDO requests, sends
+// heartbeats and receives PDOs. Poll must be called regularly to do so.
+type Node struct {
+	// ID is the node ID, from 1 to 127.
+	ID uint8
+
+	// Objects is the object dictionary. Heartbeats are sent with the
+	// interval in milliseconds of object 0x1017 (producer heartbeat time),
+	// if it is in the dictionary.
+	Objects []Object
+
+	// PDOHandler is called with the number (1 to 4) and data of a received
+	// PDO, while the node is operational.
+	PDOHandler func(pdo int, data []byte)
+
+	bus       drivers.CAN
+	state     State
+	frame     drivers.CANFrame
+	heartbeat time.Time // time of the last heartbeat
+	sdo       sdoServer
+}
+
+// New returns a node with an ID and an object dictionary.
+func New(bus drivers.CAN, id uint8, objects []Object) *Node {
+	return &Node{
+		ID:      id,
+		Objects: objects,
+		bus:     bus,
+	}
+}
+
+// State returns the NMT state of the node.
+func (n *Node) State() State {
+	return n.state
+}
+
+// Start sends the boot-up message and enters the pre-operational state.
+func (n *Node) Start() error {
+	n.state = StateInitializing
+	n.sdo.active = false
+	if err := n.sendHeartbeat(); err != nil {
+		return err
+	}
+	n.state = StatePreOperational
+	return nil
+}
+
+// Poll handles the received frames and sends a heartbeat if it is due. It
+// doesn't wait for frames to arrive.
+func (n *Node) Poll() error {
+	for {
+		ok, err := n.bus.Receive(&n.frame)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := n.handle(); err != nil {
+			return err
+		}
+	}
+	if n.state == StateInitializing {
+		return nil
+	}
+	if ms := n.heartbeatTime(); ms != 0 && time.Since(n.heartbeat) >= time.Duration(ms)*time.Millisecond {
+		return n.sendHeartbeat()
+	}
+	return nil
+}
+
+// SendPDO sends a transmit PDO, numbered 1 to 4. The node must be
+// operational.
+func (n *Node) SendPDO(pdo int, data []byte) error {
+	if pdo < 1 || pdo > 4 {
+		return ErrInvalidPDO
+	}
+	if n.state != StateOperational {
+		return ErrNotOperational
+	}
+	f := drivers.CANFrame{ID: uint32(FunctionTPDO1 + 0x100*(pdo-1) + int(n.ID))}
+	f.DLC = uint8(copy(f.Data[:], data))
+	return n.bus.Transmit(&f)
+}
+
+// Object returns an object of the dictionary, or nil if it doesn't exist.
+func (n *Node) Object(index uint16, subindex uint8) *Object {
+	for i := range n.Objects {
+		if n.Objects[i].Index == index && n.Objects[i].Subindex == subindex {
+			return &n.Objects[i]
+		}
+	}
+	return nil
+}
+
+// handle handles the received frame.
+func (n *Node) handle() error {
+	f := &n.frame
+	if f.Extended || f.Remote {
+		return nil
+	}
+	switch {
+	case f.ID == FunctionNMT:
+		if f.DLC == 2 && (f.Data[1] == 0 || f.Data[1] == n.ID) {
+			return n.command(Command(f.Data[0]))
+		}
+	case f.ID == uint32(FunctionSDORx)+uint32(n.ID):
+		if f.DLC == 8 && n.state != StateStopped {
+			return n.handleSDO()
+		}
+	case f.ID&0x7f == uint32(n.ID) && f.ID >= FunctionRPDO1 && f.ID < FunctionSDOTx && f.ID&0x80 == 0:
+		// RPDO1 to RPDO4 are at 0x200, 0x300, 0x400 and 0x500.
+		if n.state == StateOperational && n.PDOHandler != nil {
+			n.PDOHandler(int(f.ID>>8)-1, f.Payload())
+		}
+	}
+	return nil
+}
+
+// command executes an NMT command. The reset commands only restart the
+// communication; resetting the application is left to the caller.
+func (n *Node) command(cmd Command) error {
+	switch cmd {
+	case CommandStart:
+		n.state = StateOperational
+	case CommandStop:
+		n.state = StateStopped
+	case CommandEnterPreOperational:
+		n.state = StatePreOperational
+	case CommandResetNode, CommandResetCommunication:
+		return n.Start()
+	}
+	return nil
+}
+
+// heartbeatTime returns the producer heartbeat time in milliseconds.
+func (n *Node) heartbeatTime() uint16 {
+	obj := n.Object(0x1017, 0)
+	if obj == nil || len(obj.Data) < 2 {
+		return 0
+	}
+	return uint16(obj.Data[0]) | uint16(obj.Data[1])<<8
+}
+
+func (n *Node) sendHeartbeat() error {
+	n.heartbeat = time.Now()
+	return n.bus.Transmit(&drivers.CANFrame{
+		ID:   FunctionHeartbeat + uint32(n.ID),
+		DLC:  1,
+		Data: [8]byte{byte(n.state)},
+	})
+}
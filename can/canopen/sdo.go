@@ -0,0 +1,380 @@
+package canopen
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// Command specifiers in the upper 3 bits of the first byte of SDO messages.
+const (
+	ccsDownloadSegment  = 0x00
+	ccsInitiateDownload = 0x20
+	ccsInitiateUpload   = 0x40
+	ccsUploadSegment    = 0x60
+	csAbort             = 0x80
+
+	scsUploadSegment    = 0x00
+	scsDownloadSegment  = 0x20
+	scsInitiateUpload   = 0x40
+	scsInitiateDownload = 0x60
+)
+
+// Flags of the first byte of SDO messages.
+const (
+	sdoSize      = 0x01 // size indicated
+	sdoExpedited = 0x02
+	sdoLast      = 0x01 // no more segments
+	sdoToggle    = 0x10
+)
+
+// sdoServer is the state of a segmented transfer of a node.
+type sdoServer struct {
+	active bool
+	upload bool
+	obj    *Object
+	offset int
+	toggle byte
+}
+
+// handleSDO answers an SDO request in the current frame.
+func (n *Node) handleSDO() error {
+	req := &n.frame.Data
+	s := &n.sdo
+	cmd := req[0]
+	switch cmd & 0xe0 {
+	case ccsInitiateDownload:
+		s.active = false
+		obj, code := n.lookup(req, true)
+		if code != 0 {
+			return n.sdoAbort(req, code)
+		}
+		size := len(obj.Data)
+		if cmd&sdoExpedited != 0 {
+			if cmd&sdoSize != 0 && 4-int(cmd>>2&3) != size || size > 4 {
+				return n.sdoAbort(req, AbortLength)
+			}
+			copy(obj.Data, req[4:4+size])
+		} else {
+			if cmd&sdoSize != 0 && int(getUint32(req[4:])) != size {
+				return n.sdoAbort(req, AbortLength)
+			}
+			*s = sdoServer{active: true, obj: obj}
+		}
+		return n.sdoRespond(scsInitiateDownload, req[1:4], nil)
+	case ccsDownloadSegment:
+		if !s.active || s.upload {
+			return n.sdoAbortObject(s.obj, AbortCommand)
+		}
+		if cmd&sdoToggle != s.toggle {
+			s.active = false
+			return n.sdoAbortObject(s.obj, AbortToggle)
+		}
+		size := 7 - int(cmd>>1&7)
+		if s.offset+size > len(s.obj.Data) || cmd&sdoLast != 0 && s.offset+size != len(s.obj.Data) {
+			s.active = false
+			return n.sdoAbortObject(s.obj, AbortLength)
+		}
+		s.offset += copy(s.obj.Data[s.offset:], req[1:1+size])
+		if cmd&sdoLast != 0 {
+			s.active = false
+		}
+		resp := scsDownloadSegment | s.toggle
+		s.toggle ^= sdoToggle
+		return n.sdoRespond(resp, nil, nil)
+	case ccsInitiateUpload:
+		s.active = false
+		obj, code := n.lookup(req, false)
+		if code != 0 {
+			return n.sdoAbort(req, code)
+		}
+		size := len(obj.Data)
+		// An expedited transfer has 1 to 4 bytes, an empty object is sent
+		// as a segmented transfer without data.
+		if size > 0 && size <= 4 {
+			return n.sdoRespond(scsInitiateUpload|byte(4-size)<<2|sdoExpedited|sdoSize, req[1:4], obj.Data)
+		}
+		*s = sdoServer{active: true, upload: true, obj: obj}
+		var data [4]byte
+		putUint32(data[:], uint32(size))
+		return n.sdoRespond(scsInitiateUpload|sdoSize, req[1:4], data[:])
+	case ccsUploadSegment:
+		if !s.active || !s.upload {
+			return n.sdoAbortObject(s.obj, AbortCommand)
+		}
+		if cmd&sdoToggle != s.toggle {
+			s.active = false
+			return n.sdoAbortObject(s.obj, AbortToggle)
+		}
+		data := s.obj.Data[s.offset:]
+		if len(data) > 7 {
+			data = data[:7]
+		}
+		s.offset += len(data)
+		resp := scsUploadSegment | s.toggle | byte(7-len(data))<<1
+		if s.offset == len(s.obj.Data) {
+			resp |= sdoLast
+			s.active = false
+		}
+		s.toggle ^= sdoToggle
+		return n.sdoRespondSegment(resp, data)
+	case csAbort:
+		s.active = false
+		return nil
+	}
+	return n.sdoAbort(req, AbortCommand)
+}
+
+// lookup returns the object of an SDO request, or the abort code if it
+// can't be accessed.
+func (n *Node) lookup(req *[8]byte, write bool) (*Object, uint32) {
+	index := uint16(req[1]) | uint16(req[2])<<8
+	obj := n.Object(index, req[3])
+	if obj == nil {
+		if n.Object(index, 0) != nil {
+			return nil, AbortNoSubindex
+		}
+		return nil, AbortNoObject
+	}
+	if write && !obj.Writable {
+		return nil, AbortReadOnly
+	}
+	return obj, 0
+}
+
+// sdoRespond sends a response with a multiplexer (index and subindex) and
+// up to 4 bytes of data.
+func (n *Node) sdoRespond(cmd byte, mux []byte, data []byte) error {
+	f := drivers.CANFrame{ID: FunctionSDOTx + uint32(n.ID), DLC: 8}
+	f.Data[0] = cmd
+	copy(f.Data[1:4], mux)
+	copy(f.Data[4:], data)
+	return n.bus.Transmit(&f)
+}
+
+// sdoRespondSegment sends a response with up to 7 bytes of data.
+func (n *Node) sdoRespondSegment(cmd byte, data []byte) error {
+	f := drivers.CANFrame{ID: FunctionSDOTx + uint32(n.ID), DLC: 8}
+	f.Data[0] = cmd
+	copy(f.Data[1:], data)
+	return n.bus.Transmit(&f)
+}
+
+func (n *Node) sdoAbort(req *[8]byte, code uint32) error {
+	var data [4]byte
+	putUint32(data[:], code)
+	return n.sdoRespond(csAbort, req[1:4], data[:])
+}
+
+// sdoAbortObject aborts the segmented transfer of an object.
+func (n *Node) sdoAbortObject(obj *Object, code uint32) error {
+	var mux [3]byte
+	if obj != nil {
+		mux = [3]byte{byte(obj.Index), byte(obj.Index >> 8), obj.Subindex}
+	}
+	var data [4]byte
+	putUint32(data[:], code)
+	return n.sdoRespond(csAbort, mux[:], data[:])
+}
+
+// SDOClient reads and writes the object dictionary of a node.
+type SDOClient struct {
+	// Timeout is how long to wait for each response. It defaults to 500ms.
+	Timeout time.Duration
+
+	bus   drivers.CAN
+	node  uint8
+	frame drivers.CANFrame
+}
+
+// NewSDOClient returns a client for the node with an ID.
+func NewSDOClient(bus drivers.CAN, node uint8) *SDOClient {
+	return &SDOClient{
+		Timeout: 500 * time.Millisecond,
+		bus:     bus,
+		node:    node,
+	}
+}
+
+// Read reads an object into buf and returns its length. It returns
+// ErrBufferTooSmall if the object doesn't fit.
+func (c *SDOClient) Read(index uint16, subindex uint8, buf []byte) (int, error) {
+	resp, err := c.request(ccsInitiateUpload, index, subindex, nil)
+	if err != nil {
+		return 0, err
+	}
+	if resp[0]&0xe0 != scsInitiateUpload {
+		return 0, ErrProtocol
+	}
+	if resp[0]&sdoExpedited != 0 {
+		size := 4
+		if resp[0]&sdoSize != 0 {
+			size -= int(resp[0] >> 2 & 3)
+		}
+		if size > len(buf) {
+			return size, ErrBufferTooSmall
+		}
+		return copy(buf, resp[4:4+size]), nil
+	}
+	size := -1
+	if resp[0]&sdoSize != 0 {
+		size = int(getUint32(resp[4:]))
+		if size > len(buf) {
+			c.abort(index, subindex, AbortOutOfMemory)
+			return size, ErrBufferTooSmall
+		}
+	}
+	n := 0
+	toggle := byte(0)
+	for {
+		resp, err := c.requestSegment(ccsUploadSegment|toggle, index, subindex, nil)
+		if err != nil {
+			return n, err
+		}
+		if resp[0]&0xe0 != scsUploadSegment || resp[0]&sdoToggle != toggle {
+			c.abort(index, subindex, AbortToggle)
+			return n, ErrProtocol
+		}
+		data := resp[1 : 8-int(resp[0]>>1&7)]
+		if n+len(data) > len(buf) {
+			c.abort(index, subindex, AbortOutOfMemory)
+			return n + len(data), ErrBufferTooSmall
+		}
+		n += copy(buf[n:], data)
+		if resp[0]&sdoLast != 0 {
+			if size >= 0 && n != size {
+				return n, ErrProtocol
+			}
+			return n, nil
+		}
+		toggle ^= sdoToggle
+	}
+}
+
+// Write writes data to an object.
+func (c *SDOClient) Write(index uint16, subindex uint8, data []byte) error {
+	// An expedited transfer has 1 to 4 bytes, empty data is sent as a
+	// segmented transfer with a single empty segment.
+	if len(data) > 0 && len(data) <= 4 {
+		resp, err := c.request(ccsInitiateDownload|byte(4-len(data))<<2|sdoExpedited|sdoSize, index, subindex, data)
+		if err != nil {
+			return err
+		}
+		if resp[0]&0xe0 != scsInitiateDownload {
+			return ErrProtocol
+		}
+		return nil
+	}
+	var size [4]byte
+	putUint32(size[:], uint32(len(data)))
+	resp, err := c.request(ccsInitiateDownload|sdoSize, index, subindex, size[:])
+	if err != nil {
+		return err
+	}
+	if resp[0]&0xe0 != scsInitiateDownload {
+		return ErrProtocol
+	}
+	toggle := byte(0)
+	for sent := 0; ; {
+		segment := data[sent:]
+		if len(segment) > 7 {
+			segment = segment[:7]
+		}
+		sent += len(segment)
+		cmd := ccsDownloadSegment | toggle | byte(7-len(segment))<<1
+		if sent == len(data) {
+			cmd |= sdoLast
+		}
+		resp, err := c.requestSegment(cmd, index, subindex, segment)
+		if err != nil {
+			return err
+		}
+		if resp[0]&0xe0 != scsDownloadSegment || resp[0]&sdoToggle != toggle {
+			c.abort(index, subindex, AbortToggle)
+			return ErrProtocol
+		}
+		if sent == len(data) {
+			return nil
+		}
+		toggle ^= sdoToggle
+	}
+}
+
+// request sends a request with a multiplexer and up to 4 bytes of data, and
+// waits for the response.
+func (c *SDOClient) request(cmd byte, index uint16, subindex uint8, data []byte) (*[8]byte, error) {
+	f := &c.frame
+	*f = drivers.CANFrame{ID: FunctionSDORx + uint32(c.node), DLC: 8}
+	f.Data[0] = cmd
+	f.Data[1] = byte(index)
+	f.Data[2] = byte(index >> 8)
+	f.Data[3] = subindex
+	copy(f.Data[4:], data)
+	return c.exchange(index, subindex)
+}
+
+// requestSegment sends a request with up to 7 bytes of data, and waits for
+// the response.
+func (c *SDOClient) requestSegment(cmd byte, index uint16, subindex uint8, data []byte) (*[8]byte, error) {
+	f := &c.frame
+	*f = drivers.CANFrame{ID: FunctionSDORx + uint32(c.node), DLC: 8}
+	f.Data[0] = cmd
+	copy(f.Data[1:], data)
+	return c.exchange(index, subindex)
+}
+
+// exchange sends the request in c.frame and waits for the response.
+func (c *SDOClient) exchange(index uint16, subindex uint8) (*[8]byte, error) {
+	if err := c.bus.Transmit(&c.frame); err != nil {
+		return nil, err
+	}
+	f := &c.frame
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		ok, err := c.bus.Receive(f)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if time.Now().After(deadline) {
+				c.abort(index, subindex, AbortTimeout)
+				return nil, ErrTimeout
+			}
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		if f.ID != FunctionSDOTx+uint32(c.node) || f.Extended || f.Remote || f.DLC != 8 {
+			continue
+		}
+		if f.Data[0]&0xe0 == csAbort {
+			return nil, SDOAbortError{
+				Index:    uint16(f.Data[1]) | uint16(f.Data[2])<<8,
+				Subindex: f.Data[3],
+				Code:     getUint32(f.Data[4:]),
+			}
+		}
+		return &f.Data, nil
+	}
+}
+
+// abort aborts the current transfer.
+func (c *SDOClient) abort(index uint16, subindex uint8, code uint32) {
+	f := drivers.CANFrame{ID: FunctionSDORx + uint32(c.node), DLC: 8}
+	f.Data[0] = csAbort
+	f.Data[1] = byte(index)
+	f.Data[2] = byte(index >> 8)
+	f.Data[3] = subindex
+	putUint32(f.Data[4:], code)
+	c.bus.Transmit(&f)
+}
+
+func putUint32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
+
+func getUint32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
@@ -0,0 +1,314 @@
+// Package isotp implements ISO 15765-2 (ISO-TP), the transport protocol used
+// to send messages of up to 4095 bytes over CAN, for example for vehicle
+// diagnostics.
+//
+// Messages of up to 7 bytes are sent in a single frame. Longer messages are
+// split into a first frame and consecutive frames, and the receiver controls
+// the pace with flow control frames.
+package isotp // import "tinygo.org/x/drivers/can/isotp"
+
+import (
+	"errors"
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+var (
+	ErrTimeout  = errors.New("isotp: timeout")
+	ErrOverflow = errors.New("isotp: message too long for receiver")
+	ErrTooLong  = errors.New("isotp: message too long")
+	ErrSequence = errors.New("isotp: wrong sequence number")
+)
+
+// MaxMessageLength is the longest message that can be sent or received.
+const MaxMessageLength = 4095
+
+// Protocol control information, in the upper nibble of the first byte.
+const (
+	pciSingle      = 0x00
+	pciFirst       = 0x10
+	pciConsecutive = 0x20
+	pciFlowControl = 0x30
+)
+
+// Flow status of a flow control frame.
+const (
+	flowContinue = 0
+	flowWait     = 1
+	flowOverflow = 2
+)
+
+// maxWait is the number of flow control frames with a wait status that are
+// accepted in a row, before giving up.
+const maxWait = 10
+
+// pollInterval is the time to wait before checking for a new frame again.
+const pollInterval = 100 * time.Microsecond
+
+// Config is the configuration of a connection.
+type Config struct {
+	// TxID is the CAN ID of the frames that are sent, RxID of the frames
+	// that are received.
+	TxID uint32
+	RxID uint32
+
+	// FlowControlID is the CAN ID of the flow control frames that are sent
+	// while receiving a message. It defaults to TxID. It is needed when the
+	// requests are sent to a functional (broadcast) address, such as 0x7DF
+	// for OBD-II.
+	FlowControlID uint32
+
+	// Extended is set to use 29-bit CAN IDs.
+	Extended bool
+
+	// Padding fills all frames up to 8 bytes with PadByte, which many
+	// vehicles require.
+	Padding bool
+	PadByte byte
+
+	// BlockSize is the number of consecutive frames that the sender may
+	// send before waiting for another flow control frame. Zero means that
+	// the whole message is sent without waiting.
+	BlockSize uint8
+
+	// SeparationTime is the minimum time that the sender must wait between
+	// consecutive frames, from 100µs to 127ms.
+	SeparationTime time.Duration
+
+	// Timeout is how long to wait for the next frame from the other side.
+	// It defaults to one second.
+	Timeout time.Duration
+}
+
+// Conn is an ISO-TP connection between two nodes on a CAN bus.
+//
+// Frames from the bus with IDs other than RxID are discarded, so the CAN
+// controller should filter out other traffic.
+type Conn struct {
+	bus   drivers.CAN
+	cfg   Config
+	frame drivers.CANFrame
+}
+
+// New returns a connection that sends and receives frames on bus.
+func New(bus drivers.CAN, cfg Config) *Conn {
+	if cfg.FlowControlID == 0 {
+		cfg.FlowControlID = cfg.TxID
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	return &Conn{bus: bus, cfg: cfg}
+}
+
+// Send sends a message, waiting for flow control from the receiver if it
+// doesn't fit in a single frame.
+func (c *Conn) Send(data []byte) error {
+	if len(data) > MaxMessageLength {
+		return ErrTooLong
+	}
+	if len(data) <= 7 {
+		n := copy(c.frame.Data[1:], data)
+		return c.transmit(c.cfg.TxID, byte(pciSingle|n), n+1)
+	}
+
+	c.frame.Data[1] = byte(len(data))
+	sent := copy(c.frame.Data[2:], data)
+	if err := c.transmit(c.cfg.TxID, byte(pciFirst|len(data)>>8), 8); err != nil {
+		return err
+	}
+	seq := 1
+	for sent < len(data) {
+		blockSize, sepTime, err := c.waitFlowControl()
+		if err != nil {
+			return err
+		}
+		for i := 0; sent < len(data) && (blockSize == 0 || i < blockSize); i++ {
+			if i > 0 {
+				time.Sleep(sepTime)
+			}
+			n := copy(c.frame.Data[1:], data[sent:])
+			if err := c.transmit(c.cfg.TxID, byte(pciConsecutive|seq&0x0f), n+1); err != nil {
+				return err
+			}
+			sent += n
+			seq++
+		}
+	}
+	return nil
+}
+
+// Receive waits for a message and copies it into buf. It returns the length
+// of the message, and ErrOverflow if it doesn't fit in buf.
+func (c *Conn) Receive(buf []byte) (int, error) {
+	for {
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+		f := &c.frame
+		// The DLC of a malformed frame can be up to 15, the payload is at
+		// most 8 bytes.
+		payload := f.Payload()
+		if len(payload) == 0 {
+			continue
+		}
+		switch payload[0] & 0xf0 {
+		case pciSingle:
+			n := int(payload[0] & 0x0f)
+			if n == 0 || n > len(payload)-1 {
+				continue
+			}
+			if n > len(buf) {
+				return n, ErrOverflow
+			}
+			return copy(buf, payload[1:1+n]), nil
+		case pciFirst:
+			if len(payload) < 8 {
+				continue
+			}
+			return c.receiveSegmented(buf)
+		}
+		// Consecutive frames and flow control outside of a transfer are
+		// ignored.
+	}
+}
+
+// receiveSegmented receives the rest of a message after its first frame.
+func (c *Conn) receiveSegmented(buf []byte) (int, error) {
+	f := &c.frame
+	length := int(f.Data[0]&0x0f)<<8 | int(f.Data[1])
+	if length > len(buf) {
+		c.sendFlowControl(flowOverflow)
+		return length, ErrOverflow
+	}
+	received := copy(buf, f.Data[2:8])
+	if err := c.sendFlowControl(flowContinue); err != nil {
+		return 0, err
+	}
+	seq := 1
+	block := 0
+	for received < length {
+		if err := c.next(); err != nil {
+			return received, err
+		}
+		payload := f.Payload()
+		if len(payload) == 0 || payload[0]&0xf0 != pciConsecutive {
+			continue
+		}
+		if int(payload[0]&0x0f) != seq&0x0f {
+			return received, ErrSequence
+		}
+		n := len(payload) - 1
+		if n > length-received {
+			n = length - received
+		}
+		received += copy(buf[received:length], payload[1:1+n])
+		seq++
+		block++
+		if c.cfg.BlockSize != 0 && block == int(c.cfg.BlockSize) && received < length {
+			block = 0
+			if err := c.sendFlowControl(flowContinue); err != nil {
+				return received, err
+			}
+		}
+	}
+	return length, nil
+}
+
+// waitFlowControl waits for a flow control frame that allows sending, and
+// returns the block size and separation time.
+func (c *Conn) waitFlowControl() (int, time.Duration, error) {
+	waits := 0
+	for {
+		if err := c.next(); err != nil {
+			return 0, 0, err
+		}
+		f := &c.frame
+		if f.Data[0]&0xf0 != pciFlowControl || f.DLC < 3 {
+			continue
+		}
+		switch f.Data[0] & 0x0f {
+		case flowContinue:
+			return int(f.Data[1]), decodeSeparationTime(f.Data[2]), nil
+		case flowWait:
+			waits++
+			if waits > maxWait {
+				return 0, 0, ErrTimeout
+			}
+		case flowOverflow:
+			return 0, 0, ErrOverflow
+		}
+	}
+}
+
+func (c *Conn) sendFlowControl(status byte) error {
+	c.frame.Data[1] = c.cfg.BlockSize
+	c.frame.Data[2] = encodeSeparationTime(c.cfg.SeparationTime)
+	return c.transmit(c.cfg.FlowControlID, pciFlowControl|status, 3)
+}
+
+// transmit sends the frame with the given PCI byte and length, where the
+// rest of the data has already been filled in.
+func (c *Conn) transmit(id uint32, pci byte, n int) error {
+	f := &c.frame
+	f.ID = id
+	f.Extended = c.cfg.Extended
+	f.Remote = false
+	f.Data[0] = pci
+	f.DLC = uint8(n)
+	if c.cfg.Padding {
+		for i := n; i < 8; i++ {
+			f.Data[i] = c.cfg.PadByte
+		}
+		f.DLC = 8
+	}
+	return c.bus.Transmit(f)
+}
+
+// next waits for the next frame from the other side.
+func (c *Conn) next() error {
+	deadline := time.Now().Add(c.cfg.Timeout)
+	for {
+		ok, err := c.bus.Receive(&c.frame)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if c.frame.ID == c.cfg.RxID && c.frame.Extended == c.cfg.Extended && !c.frame.Remote && c.frame.DLC > 0 {
+				return nil
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// encodeSeparationTime returns the STmin byte of a flow control frame.
+func encodeSeparationTime(d time.Duration) byte {
+	switch {
+	case d <= 0:
+		return 0
+	case d <= 900*time.Microsecond:
+		us := (d + 99*time.Microsecond) / (100 * time.Microsecond)
+		return 0xf0 + byte(us)
+	case d > 127*time.Millisecond:
+		return 127
+	}
+	return byte((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// decodeSeparationTime returns the time in the STmin byte of a flow control
+// frame. Reserved values are treated as the maximum of 127ms.
+func decodeSeparationTime(b byte) time.Duration {
+	switch {
+	case b <= 0x7f:
+		return time.Duration(b) * time.Millisecond
+	case b >= 0xf1 && b <= 0xf9:
+		return time.Duration(b-0xf0) * 100 * time.Microsecond
+	}
+	return 127 * time.Millisecond
+}
@@ -0,0 +1,171 @@
+package isotp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/tester"
+)
+
+func pair(cfg Config) (a, b *Conn, na, nb *tester.CANNode) {
+	bus := tester.NewCANBus()
+	na, nb = bus.Node(), bus.Node()
+	a = New(na, cfg)
+	cfg.TxID, cfg.RxID = cfg.RxID, cfg.TxID
+	cfg.FlowControlID = 0
+	b = New(nb, cfg)
+	return a, b, na, nb
+}
+
+type result struct {
+	n   int
+	err error
+}
+
+func receive(conn *Conn, buf []byte) chan result {
+	ch := make(chan result, 1)
+	go func() {
+		n, err := conn.Receive(buf)
+		ch <- result{n, err}
+	}()
+	return ch
+}
+
+func TestSingleFrame(t *testing.T) {
+	c := qt.New(t)
+	a, b, _, nb := pair(Config{TxID: 0x7E0, RxID: 0x7E8, Padding: true, PadByte: 0xCC})
+	c.Assert(a.Send([]byte{0x01, 0x0C}), qt.IsNil)
+
+	// The frame is padded.
+	var f drivers.CANFrame
+	ok, _ := nb.Receive(&f)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(f, qt.Equals, drivers.CANFrame{ID: 0x7E0, DLC: 8, Data: [8]byte{0x02, 0x01, 0x0C, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC}})
+
+	c.Assert(a.Send([]byte{0x01, 0x0D}), qt.IsNil)
+	buf := make([]byte, 16)
+	n, err := b.Receive(buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:n], qt.DeepEquals, []byte{0x01, 0x0D})
+}
+
+func TestSegmented(t *testing.T) {
+	for _, cfg := range []Config{
+		{TxID: 0x7E0, RxID: 0x7E8},
+		{TxID: 0x18DA10F1, RxID: 0x18DAF110, Extended: true, Padding: true, BlockSize: 4, SeparationTime: time.Millisecond},
+	} {
+		c := qt.New(t)
+		a, b, na, nb := pair(cfg)
+		msg := make([]byte, 200)
+		for i := range msg {
+			msg[i] = byte(i)
+		}
+		buf := make([]byte, 256)
+		ch := receive(b, buf)
+		c.Assert(a.Send(msg), qt.IsNil)
+		r := <-ch
+		c.Assert(r.err, qt.IsNil)
+		c.Assert(buf[:r.n], qt.DeepEquals, msg)
+
+		// A first frame, 28 consecutive frames and the flow control.
+		c.Assert(na.Sent, qt.Equals, 29)
+		if cfg.BlockSize == 0 {
+			c.Assert(nb.Sent, qt.Equals, 1)
+		} else {
+			c.Assert(nb.Sent, qt.Equals, 7)
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	c := qt.New(t)
+	a, b, _, _ := pair(Config{TxID: 1, RxID: 2})
+	ch := receive(b, make([]byte, 10))
+	c.Assert(a.Send(bytes.Repeat([]byte{1}, 20)), qt.Equals, ErrOverflow)
+	r := <-ch
+	c.Assert(r.err, qt.Equals, ErrOverflow)
+	c.Assert(r.n, qt.Equals, 20)
+
+	c.Assert(a.Send(make([]byte, MaxMessageLength+1)), qt.Equals, ErrTooLong)
+}
+
+func TestErrors(t *testing.T) {
+	c := qt.New(t)
+	a, b, na, nb := pair(Config{TxID: 1, RxID: 2, Timeout: 10 * time.Millisecond})
+
+	// Nobody answers with flow control.
+	c.Assert(a.Send(make([]byte, 20)), qt.Equals, ErrTimeout)
+	_, err := a.Receive(make([]byte, 10))
+	c.Assert(err, qt.Equals, ErrTimeout)
+
+	// A consecutive frame is lost. Frames with other IDs are ignored.
+	var f drivers.CANFrame
+	for nb.Pending() > 0 {
+		nb.Receive(&f)
+	}
+	for _, f := range []drivers.CANFrame{
+		{ID: 1, DLC: 8, Data: [8]byte{0x10, 20, 1, 2, 3, 4, 5, 6}},
+		{ID: 3, DLC: 8, Data: [8]byte{0x21}},
+		{ID: 1, DLC: 8, Data: [8]byte{0x22}},
+	} {
+		f := f
+		na.Transmit(&f)
+	}
+	_, err = b.Receive(make([]byte, 100))
+	c.Assert(err, qt.Equals, ErrSequence)
+}
+
+func TestMalformedDLC(t *testing.T) {
+	c := qt.New(t)
+	_, b, na, _ := pair(Config{TxID: 1, RxID: 2})
+
+	// A controller can pass on DLC values up to 15, of which only 8 bytes
+	// are data. A single frame longer than that is ignored.
+	for _, f := range []drivers.CANFrame{
+		{ID: 1, DLC: 15, Data: [8]byte{0x0E, 1, 2, 3, 4, 5, 6, 7}},
+		{ID: 1, DLC: 15, Data: [8]byte{0x07, 1, 2, 3, 4, 5, 6, 7}},
+	} {
+		f := f
+		na.Transmit(&f)
+	}
+	buf := make([]byte, 32)
+	n, err := b.Receive(buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:n], qt.DeepEquals, []byte{1, 2, 3, 4, 5, 6, 7})
+
+	// The consecutive frames of a segmented message carry 7 bytes.
+	for _, f := range []drivers.CANFrame{
+		{ID: 1, DLC: 15, Data: [8]byte{0x10, 16, 1, 2, 3, 4, 5, 6}},
+		{ID: 1, DLC: 15, Data: [8]byte{0x21, 7, 8, 9, 10, 11, 12, 13}},
+		{ID: 1, DLC: 9, Data: [8]byte{0x22, 14, 15, 16}},
+	} {
+		f := f
+		na.Transmit(&f)
+	}
+	n, err = b.Receive(buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:n], qt.DeepEquals, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+}
+
+func TestSeparationTime(t *testing.T) {
+	c := qt.New(t)
+	for _, test := range []struct {
+		d time.Duration
+		b byte
+	}{
+		{0, 0},
+		{100 * time.Microsecond, 0xF1},
+		{900 * time.Microsecond, 0xF9},
+		{time.Millisecond, 1},
+		{127 * time.Millisecond, 127},
+	} {
+		c.Assert(encodeSeparationTime(test.d), qt.Equals, test.b)
+		c.Assert(decodeSeparationTime(test.b), qt.Equals, test.d)
+	}
+	c.Assert(encodeSeparationTime(time.Second), qt.Equals, byte(127))
+	c.Assert(encodeSeparationTime(150*time.Microsecond), qt.Equals, byte(0xF2))
+	c.Assert(decodeSeparationTime(0xFA), qt.Equals, 127*time.Millisecond)
+}
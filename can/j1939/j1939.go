@@ -0,0 +1,174 @@
+// Package j1939 implements SAE J1939, the CAN protocol used in trucks, buses
+// and agricultural and construction machinery.
+//
+// It decodes and encodes the 29-bit CAN identifiers with the parameter group
+// number (PGN), claims an address on the network and sends and receives
+// messages of up to 1785 bytes with the transport protocol (BAM and CMDT).
+//
+// It works on any drivers.CAN controller, such as the mcp2515. J1939 uses
+// extended frames at 250kbit/s, or 500kbit/s for J1939-14.
+package j1939 // import "tinygo.org/x/drivers/can/j1939"
+
+import "errors"
+
+var (
+	ErrAddressLost = errors.New("j1939: cannot claim an address")
+	ErrNoAddress   = errors.New("j1939: no address claimed")
+	ErrTimeout     = errors.New("j1939: timeout")
+	ErrAborted     = errors.New("j1939: transfer aborted")
+	ErrTooLong     = errors.New("j1939: message too long")
+)
+
+// Special addresses.
+const (
+	// AddressGlobal is the destination of messages to all nodes.
+	AddressGlobal = 255
+
+	// AddressNull is the source address of a node without address.
+	AddressNull = 254
+)
+
+// MaxMessageLength is the longest message that can be sent with the
+// transport protocol.
+const MaxMessageLength = 255 * 7
+
+// PGN is a parameter group number, which identifies the content of a
+// message. It is 18 bits long.
+type PGN uint32
+
+// Parameter group numbers of network management and the transport protocol,
+// and a few common ones.
+const (
+	PGNAcknowledgement  PGN = 0xE800 // 59392
+	PGNRequest          PGN = 0xEA00 // 59904
+	PGNTPData           PGN = 0xEB00 // 60160, TP.DT
+	PGNTPConnection     PGN = 0xEC00 // 60416, TP.CM
+	PGNAddressClaimed   PGN = 0xEE00 // 60928
+	PGNEEC1             PGN = 0xF004 // 61444, electronic engine controller 1
+	PGNCommandedAddress PGN = 0xFED8 // 65240
+	PGNEngineTemp1      PGN = 0xFEEE // 65262
+	PGNVehicleSpeed     PGN = 0xFEF1 // 65265, cruise control/vehicle speed
+)
+
+// PDU1 returns whether messages of the PGN are sent to a specific
+// destination (PDU1 format) instead of to all nodes (PDU2 format).
+func (p PGN) PDU1() bool {
+	return p>>8&0xff < 240
+}
+
+// ID holds the fields of a 29-bit J1939 CAN identifier.
+type ID struct {
+	// Priority is 0 for the highest priority up to 7. Most messages use 6,
+	// control messages 3.
+	Priority uint8
+
+	PGN    PGN
+	Source uint8
+
+	// Destination is the address of the receiver of PDU1 messages, and
+	// AddressGlobal for PDU2 messages.
+	Destination uint8
+}
+
+// ParseID returns the fields of a CAN identifier.
+func ParseID(id uint32) ID {
+	pgn := PGN(id>>8) & 0x3ffff
+	dest := uint8(AddressGlobal)
+	if pgn.PDU1() {
+		dest = uint8(pgn)
+		pgn &^= 0xff
+	}
+	return ID{
+		Priority:    uint8(id>>26) & 7,
+		PGN:         pgn,
+		Source:      uint8(id),
+		Destination: dest,
+	}
+}
+
+// CANID returns the 29-bit CAN identifier.
+func (id ID) CANID() uint32 {
+	pgn := uint32(id.PGN) & 0x3ffff
+	if id.PGN.PDU1() {
+		pgn = pgn&^0xff | uint32(id.Destination)
+	}
+	return uint32(id.Priority&7)<<26 | pgn<<8 | uint32(id.Source)
+}
+
+// Message is a J1939 message.
+type Message struct {
+	ID
+	Data []byte
+}
+
+// Name is the 64-bit NAME of a node, which identifies it and is used to
+// decide which node gets an address when two nodes claim the same one.
+type Name struct {
+	IdentityNumber          uint32 // 21 bits, for example a serial number
+	ManufacturerCode        uint16 // 11 bits, assigned by SAE
+	ECUInstance             uint8  // 3 bits
+	FunctionInstance        uint8  // 5 bits
+	Function                uint8
+	VehicleSystem           uint8 // 7 bits
+	VehicleSystemInstance   uint8 // 4 bits
+	IndustryGroup           uint8 // 3 bits
+	ArbitraryAddressCapable bool
+}
+
+// Uint64 returns the NAME as a number. The node with the lowest number wins
+// an address conflict.
+func (n Name) Uint64() uint64 {
+	v := uint64(n.IdentityNumber&0x1fffff) |
+		uint64(n.ManufacturerCode&0x7ff)<<21 |
+		uint64(n.ECUInstance&7)<<32 |
+		uint64(n.FunctionInstance&0x1f)<<35 |
+		uint64(n.Function)<<40 |
+		uint64(n.VehicleSystem&0x7f)<<49 |
+		uint64(n.VehicleSystemInstance&0xf)<<56 |
+		uint64(n.IndustryGroup&7)<<60
+	if n.ArbitraryAddressCapable {
+		v |= 1 << 63
+	}
+	return v
+}
+
+// ParseName returns the fields of a NAME.
+func ParseName(v uint64) Name {
+	return Name{
+		IdentityNumber:          uint32(v & 0x1fffff),
+		ManufacturerCode:        uint16(v>>21) & 0x7ff,
+		ECUInstance:             uint8(v>>32) & 7,
+		FunctionInstance:        uint8(v>>35) & 0x1f,
+		Function:                uint8(v >> 40),
+		VehicleSystem:           uint8(v>>49) & 0x7f,
+		VehicleSystemInstance:   uint8(v>>56) & 0xf,
+		IndustryGroup:           uint8(v>>60) & 7,
+		ArbitraryAddressCapable: v>>63 != 0,
+	}
+}
+
+// putUint64 and getUint64 encode the NAME in the data of an address claim,
+// least significant byte first like all J1939 parameters.
+func putUint64(b []byte, v uint64) {
+	for i := 0; i < 8; i++ {
+		b[i] = byte(v >> (8 * i))
+	}
+}
+
+func getUint64(b []byte) uint64 {
+	var v uint64
+	for i := 7; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+func putPGN(b []byte, pgn PGN) {
+	b[0] = byte(pgn)
+	b[1] = byte(pgn >> 8)
+	b[2] = byte(pgn >> 16)
+}
+
+func getPGN(b []byte) PGN {
+	return PGN(b[0]) | PGN(b[1])<<8 | PGN(b[2])<<16
+}
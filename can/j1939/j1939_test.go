@@ -0,0 +1,270 @@
+package j1939
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestID(t *testing.T) {
+	c := qt.New(t)
+
+	// PDU2: engine temperature from address 0.
+	id := ParseID(0x18FEEE00)
+	c.Assert(id, qt.Equals, ID{Priority: 6, PGN: PGNEngineTemp1, Source: 0, Destination: AddressGlobal})
+	c.Assert(id.CANID(), qt.Equals, uint32(0x18FEEE00))
+
+	// PDU1: request from 0xF9 to 0x00.
+	id = ParseID(0x18EA00F9)
+	c.Assert(id, qt.Equals, ID{Priority: 6, PGN: PGNRequest, Source: 0xF9, Destination: 0})
+	c.Assert(id.CANID(), qt.Equals, uint32(0x18EA00F9))
+
+	// The destination of PDU2 messages is ignored.
+	id = ID{Priority: 3, PGN: PGNEEC1, Source: 0x00, Destination: 0x10}
+	c.Assert(id.CANID(), qt.Equals, uint32(0x0CF00400))
+	c.Assert(PGNEEC1.PDU1(), qt.IsFalse)
+	c.Assert(PGNAddressClaimed.PDU1(), qt.IsTrue)
+}
+
+func TestName(t *testing.T) {
+	c := qt.New(t)
+	name := Name{
+		IdentityNumber:          0x12345,
+		ManufacturerCode:        0x123,
+		ECUInstance:             1,
+		FunctionInstance:        2,
+		Function:                0x81,
+		VehicleSystem:           0x7F,
+		VehicleSystemInstance:   3,
+		IndustryGroup:           2,
+		ArbitraryAddressCapable: true,
+	}
+	c.Assert(name.Uint64(), qt.Equals, uint64(0xA3FE811124612345))
+	c.Assert(ParseName(name.Uint64()), qt.Equals, name)
+}
+
+// run calls f in a goroutine and receives messages on the nodes until it
+// returns. It returns the received messages and the error of f.
+func run(f func() error, nodes ...*Node) ([]Message, error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	var msgs []Message
+	receive := func() error {
+		for _, n := range nodes {
+			var m Message
+			ok, err := n.Receive(&m)
+			if err != nil {
+				return err
+			}
+			if ok {
+				m.Data = append([]byte(nil), m.Data...)
+				msgs = append(msgs, m)
+			}
+		}
+		return nil
+	}
+	for {
+		select {
+		case err := <-done:
+			// Receive the last frames that were sent.
+			if err := receive(); err != nil {
+				return msgs, err
+			}
+			return msgs, err
+		default:
+		}
+		if err := receive(); err != nil {
+			return msgs, err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func TestAddressClaim(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	a := New(bus.Node(), Name{IdentityNumber: 3, ArbitraryAddressCapable: true}, 0x80)
+	b := New(bus.Node(), Name{IdentityNumber: 2}, 0x80)
+	d := New(bus.Node(), Name{IdentityNumber: 4}, 0x80)
+	c.Assert(a.Address(), qt.Equals, uint8(AddressNull))
+	c.Assert(a.Send(&Message{ID: ID{PGN: PGNEEC1}}), qt.Equals, ErrNoAddress)
+	c.Assert(a.Claim(), qt.IsNil)
+	c.Assert(a.Address(), qt.Equals, uint8(0x80))
+
+	// b has a lower NAME and takes the address, a moves to another one.
+	_, err := run(b.Claim, a, d)
+	c.Assert(err, qt.IsNil)
+	c.Assert(b.Address(), qt.Equals, uint8(0x80))
+	c.Assert(a.Address(), qt.Equals, uint8(0x81))
+
+	// d loses and isn't arbitrary address capable.
+	_, err = run(d.Claim, a, b)
+	c.Assert(err, qt.Equals, ErrAddressLost)
+	c.Assert(d.Address(), qt.Equals, uint8(AddressNull))
+	c.Assert(b.Address(), qt.Equals, uint8(0x80))
+}
+
+func TestRequestAddressClaim(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewCANBus()
+	raw := bus.Node()
+	n := New(bus.Node(), Name{IdentityNumber: 1}, 0x20)
+	c.Assert(n.Claim(), qt.IsNil)
+	var f drivers.CANFrame
+	raw.Receive(&f)
+
+	for _, dest := range []uint8{AddressGlobal, 0x20} {
+		f = drivers.CANFrame{
+			ID:       ID{Priority: 6, PGN: PGNRequest, Source: 0xF9, Destination: dest}.CANID(),
+			Extended: true,
+			DLC:      3,
+			Data:     [8]byte{0x00, 0xEE, 0x00},
+		}
+		raw.Transmit(&f)
+		var m Message
+		ok, err := n.Receive(&m)
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsFalse)
+		ok, _ = raw.Receive(&f)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(ParseID(f.ID), qt.Equals, ID{Priority: 6, PGN: PGNAddressClaimed, Source: 0x20, Destination: AddressGlobal})
+		c.Assert(getUint64(f.Data[:]), qt.Equals, uint64(1))
+	}
+
+	// Other requests are passed on.
+	f.ID = ID{Priority: 6, PGN: PGNRequest, Source: 0xF9, Destination: 0x20}.CANID()
+	f.Data = [8]byte{0xF1, 0xFE, 0x00}
+	f.DLC = 3
+	raw.Transmit(&f)
+	var m Message
+	ok, _ := n.Receive(&m)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(m.PGN, qt.Equals, PGNRequest)
+	c.Assert(getPGN(m.Data), qt.Equals, PGNVehicleSpeed)
+}
+
+func newPair(c *qt.C) (a, b *Node, raw *tester.CANNode) {
+	bus := tester.NewCANBus()
+	raw = bus.Node()
+	a = New(bus.Node(), Name{IdentityNumber: 1}, 0x10)
+	b = New(bus.Node(), Name{IdentityNumber: 2}, 0x20)
+	c.Assert(a.Claim(), qt.IsNil)
+	c.Assert(b.Claim(), qt.IsNil)
+	var f drivers.CANFrame
+	for _, n := range []*Node{a, b} {
+		for ok, _ := n.Receive(&Message{}); ok; ok, _ = n.Receive(&Message{}) {
+		}
+	}
+	for raw.Pending() > 0 {
+		raw.Receive(&f)
+	}
+	return a, b, raw
+}
+
+func TestSingleFrame(t *testing.T) {
+	c := qt.New(t)
+	a, b, _ := newPair(c)
+	c.Assert(a.Send(&Message{ID: ID{Priority: 3, PGN: PGNEEC1}, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}), qt.IsNil)
+	var m Message
+	ok, err := b.Receive(&m)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(m.ID, qt.Equals, ID{Priority: 3, PGN: PGNEEC1, Source: 0x10, Destination: AddressGlobal})
+	c.Assert(m.Data, qt.DeepEquals, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	// Messages to other nodes are dropped.
+	c.Assert(a.Send(&Message{ID: ID{PGN: 0xEF00, Destination: 0x30}, Data: []byte{1}}), qt.IsNil)
+	c.Assert(a.Send(&Message{ID: ID{PGN: 0xEF00, Destination: 0x20}, Data: []byte{2}}), qt.IsNil)
+	ok, _ = b.Receive(&m)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(m.Data, qt.DeepEquals, []byte{2})
+}
+
+func TestTransport(t *testing.T) {
+	c := qt.New(t)
+	a, b, raw := newPair(c)
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	// CMDT to b.
+	msgs, err := run(func() error {
+		return a.Send(&Message{ID: ID{Priority: 6, PGN: 0xEF00, Destination: 0x20}, Data: data})
+	}, b)
+	c.Assert(err, qt.IsNil)
+	c.Assert(msgs, qt.HasLen, 1)
+	c.Assert(msgs[0].ID, qt.Equals, ID{Priority: 7, PGN: 0xEF00, Source: 0x10, Destination: 0x20})
+	c.Assert(msgs[0].Data, qt.DeepEquals, data)
+	// RTS, 15 packets, CTS and EOMA.
+	c.Assert(raw.Pending(), qt.Equals, 18)
+	var f drivers.CANFrame
+	for raw.Pending() > 0 {
+		raw.Receive(&f)
+	}
+
+	// BAM to all nodes.
+	msgs, err = run(func() error {
+		return a.Send(&Message{ID: ID{Priority: 6, PGN: PGNCommandedAddress}, Data: data[:20]})
+	}, b)
+	c.Assert(err, qt.IsNil)
+	c.Assert(msgs, qt.HasLen, 1)
+	c.Assert(msgs[0].PGN, qt.Equals, PGNCommandedAddress)
+	c.Assert(msgs[0].Destination, qt.Equals, uint8(AddressGlobal))
+	c.Assert(msgs[0].Data, qt.DeepEquals, data[:20])
+	c.Assert(raw.Pending(), qt.Equals, 4)
+
+	c.Assert(a.Send(&Message{ID: ID{PGN: PGNCommandedAddress}, Data: make([]byte, MaxMessageLength+1)}), qt.Equals, ErrTooLong)
+}
+
+func TestTransportWindow(t *testing.T) {
+	c := qt.New(t)
+	_, b, raw := newPair(c)
+	var f drivers.CANFrame
+	send := func(pgn PGN, data ...byte) {
+		f = drivers.CANFrame{
+			ID:       ID{Priority: 7, PGN: pgn, Source: 0x40, Destination: 0x20}.CANID(),
+			Extended: true,
+			DLC:      8,
+		}
+		copy(f.Data[:], data)
+		raw.Transmit(&f)
+	}
+	expect := func(data ...byte) {
+		var m Message
+		b.Receive(&m)
+		ok, _ := raw.Receive(&f)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(ParseID(f.ID), qt.Equals, ID{Priority: 7, PGN: PGNTPConnection, Source: 0x20, Destination: 0x40})
+		c.Assert(f.Data[:len(data)], qt.DeepEquals, data)
+	}
+
+	// 5 packets, at most 2 per CTS.
+	send(PGNTPConnection, cmRTS, 30, 0, 5, 2, 0x00, 0xEF, 0x00)
+	expect(cmCTS, 2, 1)
+	send(PGNTPData, 1)
+	send(PGNTPData, 2)
+	expect(cmCTS, 2, 3)
+	send(PGNTPData, 3)
+	send(PGNTPData, 4)
+	expect(cmCTS, 1, 5)
+	send(PGNTPData, 5)
+	var m Message
+	ok, _ := b.Receive(&m)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(m.Data, qt.HasLen, 30)
+	ok, _ = raw.Receive(&f)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(f.Data[:4], qt.DeepEquals, []byte{cmEOMA, 30, 0, 5})
+
+	// A lost packet aborts the transfer.
+	send(PGNTPConnection, cmRTS, 30, 0, 5, 0xFF, 0x00, 0xEF, 0x00)
+	expect(cmCTS, 5, 1)
+	send(PGNTPData, 2)
+	expect(cmAbort, abortSequence)
+}
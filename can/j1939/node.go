@@ -0,0 +1,251 @@
+package j1939
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// Timeouts of J1939-21 and J1939-81.
+const (
+	claimTime   = 250 * time.Millisecond  // wait after an address claim
+	timeoutT1   = 750 * time.Millisecond  // between data packets of a BAM
+	timeoutT2   = 1250 * time.Millisecond // after a CTS, for its data packets
+	timeoutT3   = 1250 * time.Millisecond // after the last data packet, for a CTS
+	timeoutT4   = 1050 * time.Millisecond // after a CTS to hold the connection
+	bamInterval = 50 * time.Millisecond   // between data packets of a BAM
+)
+
+// pollInterval is the time to wait before checking for a new frame again.
+const pollInterval = 100 * time.Microsecond
+
+// Addresses that are tried by nodes that are arbitrary address capable.
+const (
+	firstDynamicAddress = 128
+	lastDynamicAddress  = 247
+)
+
+// Node is a node on a J1939 network. It claims an address and handles the
+// network management and transport protocol messages.
+type Node struct {
+	bus     drivers.CAN
+	name    uint64
+	address uint8
+	claimed bool
+	used    [8]uint32 // addresses claimed by other nodes
+
+	frame drivers.CANFrame
+	data  [8]byte // data of the last single frame message
+	rx    session
+}
+
+// New returns a node with a NAME that will claim the preferred address.
+func New(bus drivers.CAN, name Name, address uint8) *Node {
+	return &Node{
+		bus:     bus,
+		name:    name.Uint64(),
+		address: address,
+	}
+}
+
+// Address returns the claimed address, or AddressNull if the node couldn't
+// claim an address.
+func (n *Node) Address() uint8 {
+	if !n.claimed {
+		return AddressNull
+	}
+	return n.address
+}
+
+// Claim claims the address of the node and waits 250ms for other nodes to
+// contest it. A node with an arbitrary address capable NAME tries the
+// addresses from 128 to 247 when it loses its address.
+//
+// Messages that are received while claiming are dropped.
+func (n *Node) Claim() error {
+	n.claimed = true
+	if err := n.sendClaim(); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(claimTime)
+	for time.Now().Before(deadline) {
+		ok, err := n.bus.Receive(&n.frame)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			time.Sleep(pollInterval)
+			continue
+		}
+		if !n.frame.Extended || n.frame.Remote {
+			continue
+		}
+		lost, err := n.handleNetwork(ParseID(n.frame.ID))
+		if err != nil {
+			return err
+		}
+		if lost {
+			if !n.claimed {
+				return ErrAddressLost
+			}
+			// Claimed another address.
+			deadline = time.Now().Add(claimTime)
+		}
+	}
+	return nil
+}
+
+// Send sends a message from the claimed address. Messages longer than 8
+// bytes are sent with the transport protocol: with a broadcast announce
+// message (BAM) to all nodes, or with connection mode data transfer (CMDT)
+// to a single node, which waits for the receiver to acknowledge the data.
+//
+// Frames that are received during a CMDT are dropped, except for network
+// management.
+func (n *Node) Send(m *Message) error {
+	if !n.claimed {
+		return ErrNoAddress
+	}
+	id := m.ID
+	id.Source = n.address
+	if !id.PGN.PDU1() {
+		id.Destination = AddressGlobal
+	}
+	if len(m.Data) <= 8 {
+		return n.transmit(id, m.Data)
+	}
+	if len(m.Data) > MaxMessageLength {
+		return ErrTooLong
+	}
+	if id.Destination == AddressGlobal {
+		return n.sendBAM(id, m.Data)
+	}
+	return n.sendCMDT(id, m.Data)
+}
+
+// Receive returns the next message that is sent to all nodes or to this
+// node. It doesn't wait for a message to arrive, and returns false if there
+// is none. The data of the message is only valid until the next call.
+//
+// Messages of the transport protocol are reassembled, and address claims and
+// requests for the address claim are handled.
+func (n *Node) Receive(m *Message) (bool, error) {
+	for {
+		ok, err := n.bus.Receive(&n.frame)
+		if err != nil || !ok {
+			n.checkTimeout()
+			return false, err
+		}
+		f := &n.frame
+		if !f.Extended || f.Remote {
+			continue
+		}
+		id := ParseID(f.ID)
+		if id.Destination != AddressGlobal && (!n.claimed || id.Destination != n.address) {
+			continue
+		}
+		switch id.PGN {
+		case PGNAddressClaimed, PGNRequest:
+			lost, err := n.handleNetwork(id)
+			if err != nil {
+				return false, err
+			}
+			if lost && !n.claimed {
+				return false, ErrAddressLost
+			}
+			if id.PGN == PGNAddressClaimed || f.DLC < 3 || getPGN(f.Data[:]) == PGNAddressClaimed {
+				continue
+			}
+		case PGNTPConnection:
+			if err := n.handleConnection(id); err != nil {
+				return false, err
+			}
+			continue
+		case PGNTPData:
+			done, err := n.handleData(id)
+			if err != nil {
+				return false, err
+			}
+			if !done {
+				continue
+			}
+			m.ID = n.rx.id
+			m.Data = n.rx.buf[:n.rx.size]
+			return true, nil
+		}
+		m.ID = id
+		m.Data = n.data[:copy(n.data[:], f.Payload())]
+		return true, nil
+	}
+}
+
+// handleNetwork handles address claims and requests for them in the current
+// frame. It returns whether this node lost its address.
+func (n *Node) handleNetwork(id ID) (bool, error) {
+	f := &n.frame
+	switch id.PGN {
+	case PGNRequest:
+		if f.DLC >= 3 && getPGN(f.Data[:]) == PGNAddressClaimed {
+			return false, n.sendClaim()
+		}
+	case PGNAddressClaimed:
+		if f.DLC < 8 {
+			return false, nil
+		}
+		name := getUint64(f.Data[:])
+		if id.Source >= AddressNull {
+			return false, nil
+		}
+		if id.Source != n.address || !n.claimed {
+			n.used[id.Source/32] |= 1 << (id.Source % 32)
+			return false, nil
+		}
+		if name > n.name {
+			// We win, so defend the address.
+			return false, n.sendClaim()
+		}
+		if name == n.name {
+			return false, nil
+		}
+		n.used[id.Source/32] |= 1 << (id.Source % 32)
+		return true, n.claimOther()
+	}
+	return false, nil
+}
+
+// claimOther claims a free address, or sends a cannot claim address message
+// if there is none.
+func (n *Node) claimOther() error {
+	if n.name>>63 != 0 {
+		for a := firstDynamicAddress; a <= lastDynamicAddress; a++ {
+			if n.used[a/32]&(1<<(a%32)) == 0 {
+				n.address = uint8(a)
+				return n.sendClaim()
+			}
+		}
+	}
+	n.claimed = false
+	return n.sendClaim()
+}
+
+// sendClaim sends an address claimed message, or a cannot claim address
+// message if the node has no address.
+func (n *Node) sendClaim() error {
+	var data [8]byte
+	putUint64(data[:], n.name)
+	return n.transmit(ID{
+		Priority:    6,
+		PGN:         PGNAddressClaimed,
+		Source:      n.Address(),
+		Destination: AddressGlobal,
+	}, data[:])
+}
+
+// transmit sends a single frame.
+func (n *Node) transmit(id ID, data []byte) error {
+	var f drivers.CANFrame
+	f.ID = id.CANID()
+	f.Extended = true
+	f.DLC = uint8(copy(f.Data[:], data))
+	return n.bus.Transmit(&f)
+}
@@ -0,0 +1,257 @@
+package j1939
+
+import "time"
+
+// Control bytes of TP.CM messages.
+const (
+	cmRTS   = 16  // request to send
+	cmCTS   = 17  // clear to send
+	cmEOMA  = 19  // end of message acknowledgement
+	cmBAM   = 32  // broadcast announce message
+	cmAbort = 255 // connection abort
+)
+
+// Reasons of a connection abort.
+const (
+	abortBusy     = 1 // already in a connection managed session
+	abortTimeout  = 3
+	abortSequence = 7 // bad sequence number
+)
+
+// session is a message that is being received with the transport protocol.
+type session struct {
+	active   bool
+	bam      bool
+	id       ID
+	size     int
+	packets  uint8
+	next     uint8 // sequence number of the next packet
+	last     uint8 // sequence number of the last packet of the current CTS
+	window   uint8 // maximum number of packets per CTS
+	deadline time.Time
+	buf      [MaxMessageLength]byte
+}
+
+// handleConnection handles a TP.CM message of a transfer to this node.
+func (n *Node) handleConnection(id ID) error {
+	f := &n.frame
+	if f.DLC < 8 {
+		return nil
+	}
+	rx := &n.rx
+	size := int(f.Data[1]) | int(f.Data[2])<<8
+	packets := f.Data[3]
+	pgn := getPGN(f.Data[5:])
+	switch f.Data[0] {
+	case cmRTS:
+		if id.Destination == AddressGlobal {
+			return nil
+		}
+		if rx.active && (rx.bam || rx.id.Source != id.Source) {
+			return n.sendAbort(id.Source, pgn, abortBusy)
+		}
+		if !rx.start(id, pgn, size, packets, false) {
+			return nil
+		}
+		rx.window = f.Data[4]
+		if rx.window == 0 {
+			rx.window = 0xff
+		}
+		return n.sendCTS()
+	case cmBAM:
+		if id.Destination != AddressGlobal || rx.active {
+			return nil
+		}
+		rx.start(id, pgn, size, packets, true)
+	case cmAbort:
+		if rx.active && !rx.bam && rx.id.Source == id.Source && rx.id.PGN == pgn {
+			rx.active = false
+		}
+	}
+	return nil
+}
+
+// start starts receiving a message, if the size and number of packets are
+// valid.
+func (rx *session) start(id ID, pgn PGN, size int, packets uint8, bam bool) bool {
+	if size <= 8 || size > MaxMessageLength || int(packets) != (size+6)/7 {
+		return false
+	}
+	rx.active = true
+	rx.bam = bam
+	rx.id = ID{Priority: id.Priority, PGN: pgn, Source: id.Source, Destination: id.Destination}
+	rx.size = size
+	rx.packets = packets
+	rx.next = 1
+	rx.deadline = time.Now().Add(timeoutT1)
+	return true
+}
+
+// handleData handles a TP.DT message, and returns whether the message is
+// complete.
+func (n *Node) handleData(id ID) (bool, error) {
+	f := &n.frame
+	rx := &n.rx
+	if !rx.active || id.Source != rx.id.Source || rx.bam != (id.Destination == AddressGlobal) || f.DLC < 2 {
+		return false, nil
+	}
+	if f.Data[0] != rx.next {
+		rx.active = false
+		if !rx.bam {
+			return false, n.sendAbort(rx.id.Source, rx.id.PGN, abortSequence)
+		}
+		return false, nil
+	}
+	copy(rx.buf[int(rx.next-1)*7:rx.size], f.Payload()[1:])
+	if rx.next == rx.packets {
+		rx.active = false
+		if !rx.bam {
+			return true, n.sendConnection(rx.id.Source, rx.id.PGN, cmEOMA, byte(rx.size), byte(rx.size>>8), rx.packets, 0xff)
+		}
+		return true, nil
+	}
+	rx.next++
+	if !rx.bam && rx.next > rx.last {
+		return false, n.sendCTS()
+	}
+	rx.deadline = time.Now().Add(timeoutT1)
+	return false, nil
+}
+
+// checkTimeout aborts the message that is being received if the sender
+// stopped sending.
+func (n *Node) checkTimeout() {
+	rx := &n.rx
+	if !rx.active || time.Now().Before(rx.deadline) {
+		return
+	}
+	rx.active = false
+	if !rx.bam {
+		n.sendAbort(rx.id.Source, rx.id.PGN, abortTimeout)
+	}
+}
+
+// sendCTS allows the sender to send the next packets.
+func (n *Node) sendCTS() error {
+	rx := &n.rx
+	count := rx.packets - rx.next + 1
+	if count > rx.window {
+		count = rx.window
+	}
+	rx.last = rx.next + count - 1
+	rx.deadline = time.Now().Add(timeoutT2)
+	return n.sendConnection(rx.id.Source, rx.id.PGN, cmCTS, count, rx.next, 0xff, 0xff)
+}
+
+func (n *Node) sendAbort(dest uint8, pgn PGN, reason byte) error {
+	return n.sendConnection(dest, pgn, cmAbort, reason, 0xff, 0xff, 0xff)
+}
+
+// sendConnection sends a TP.CM message.
+func (n *Node) sendConnection(dest uint8, pgn PGN, control, b1, b2, b3, b4 byte) error {
+	data := [8]byte{control, b1, b2, b3, b4}
+	putPGN(data[5:], pgn)
+	return n.transmit(ID{Priority: 7, PGN: PGNTPConnection, Source: n.address, Destination: dest}, data[:])
+}
+
+// sendPacket sends the TP.DT message with a sequence number.
+func (n *Node) sendPacket(dest uint8, seq uint8, data []byte) error {
+	packet := [8]byte{seq, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	copy(packet[1:], data[int(seq-1)*7:])
+	return n.transmit(ID{Priority: 7, PGN: PGNTPData, Source: n.address, Destination: dest}, packet[:])
+}
+
+// sendBAM sends a message to all nodes with the broadcast announce message.
+func (n *Node) sendBAM(id ID, data []byte) error {
+	packets := uint8((len(data) + 6) / 7)
+	err := n.sendConnection(AddressGlobal, id.PGN, cmBAM, byte(len(data)), byte(len(data)>>8), packets, 0xff)
+	if err != nil {
+		return err
+	}
+	for seq := uint8(1); seq <= packets; seq++ {
+		time.Sleep(bamInterval)
+		if err := n.sendPacket(AddressGlobal, seq, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendCMDT sends a message to a single node with connection mode data
+// transfer.
+func (n *Node) sendCMDT(id ID, data []byte) error {
+	packets := uint8((len(data) + 6) / 7)
+	err := n.sendConnection(id.Destination, id.PGN, cmRTS, byte(len(data)), byte(len(data)>>8), packets, 0xff)
+	if err != nil {
+		return err
+	}
+	timeout := timeoutT3
+	for {
+		err := n.waitConnection(id.Destination, id.PGN, timeout)
+		if err == ErrTimeout {
+			n.sendAbort(id.Destination, id.PGN, abortTimeout)
+		}
+		if err != nil {
+			return err
+		}
+		f := &n.frame
+		switch f.Data[0] {
+		case cmCTS:
+			count, next := f.Data[1], f.Data[2]
+			if count == 0 {
+				// The receiver holds the connection open.
+				timeout = timeoutT4
+				continue
+			}
+			for seq := next; count > 0 && seq >= next && seq <= packets; seq, count = seq+1, count-1 {
+				if err := n.sendPacket(id.Destination, seq, data); err != nil {
+					return err
+				}
+			}
+			timeout = timeoutT3
+		case cmEOMA:
+			return nil
+		case cmAbort:
+			return ErrAborted
+		}
+	}
+}
+
+// waitConnection waits for a TP.CM message from source about a PGN. Frames
+// other than network management are dropped while waiting.
+func (n *Node) waitConnection(source uint8, pgn PGN, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := n.bus.Receive(&n.frame)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if time.Now().After(deadline) {
+				return ErrTimeout
+			}
+			time.Sleep(pollInterval)
+			continue
+		}
+		f := &n.frame
+		if !f.Extended || f.Remote {
+			continue
+		}
+		id := ParseID(f.ID)
+		switch id.PGN {
+		case PGNAddressClaimed, PGNRequest:
+			lost, err := n.handleNetwork(id)
+			if err != nil {
+				return err
+			}
+			if lost {
+				// The receiver no longer knows this node.
+				return ErrAddressLost
+			}
+		case PGNTPConnection:
+			if id.Source == source && id.Destination == n.address && f.DLC == 8 && getPGN(f.Data[5:]) == pgn {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package obd2 implements an OBD-II (SAE J1979) diagnostics client over CAN,
+// to read live data and the vehicle identification number from a vehicle.
+//
+// Requests are sent to the functional (broadcast) address 0x7DF, and the
+// responses of the engine control module at 0x7E8 are used.
+package obd2 // import "tinygo.org/x/drivers/can/obd2"
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/can/isotp"
+)
+
+var (
+	ErrUnexpectedResponse = errors.New("obd2: unexpected response")
+	ErrUnknownPID         = errors.New("obd2: unknown PID")
+)
+
+// CAN IDs for 11-bit addressing, as defined by ISO 15765-4.
+const (
+	FunctionalID  = 0x7DF // requests to all ECUs
+	EngineID      = 0x7E0 // requests to the engine control module
+	EngineReplyID = 0x7E8 // responses of the engine control module
+)
+
+// Services (modes) of OBD-II.
+const (
+	ServiceCurrentData = 0x01
+	ServiceVehicleInfo = 0x09
+
+	negativeResponse = 0x7F
+	positiveResponse = 0x40
+)
+
+// PID is a parameter ID of service 01 (current data).
+type PID byte
+
+// Service 01 parameter IDs that Query can decode. The comments give the unit
+// of the value returned by Query.
+const (
+	PIDEngineLoad     PID = 0x04 // %
+	PIDCoolantTemp    PID = 0x05 // °C
+	PIDIntakePressure PID = 0x0B // kPa
+	PIDEngineRPM      PID = 0x0C // rpm
+	PIDVehicleSpeed   PID = 0x0D // km/h
+	PIDTimingAdvance  PID = 0x0E // ° before TDC
+	PIDIntakeAirTemp  PID = 0x0F // °C
+	PIDMAFRate        PID = 0x10 // g/s
+	PIDThrottle       PID = 0x11 // %
+	PIDRunTime        PID = 0x1F // s
+	PIDFuelLevel      PID = 0x2F // %
+	PIDBarometric     PID = 0x33 // kPa
+	PIDControlVoltage PID = 0x42 // V
+	PIDAmbientAirTemp PID = 0x46 // °C
+	PIDOilTemp        PID = 0x5C // °C
+	PIDFuelRate       PID = 0x5E // L/h
+	PIDOdometer       PID = 0xA6 // km
+)
+
+const (
+	pidVIN               = 0x02 // service 09
+	pidSupportedInterval = 0x20 // PIDs 0x00, 0x20, ... return the supported PIDs
+)
+
+// NegativeResponseError is returned when the vehicle rejects a request.
+type NegativeResponseError struct {
+	Service byte
+	Code    byte
+}
+
+func (e NegativeResponseError) Error() string {
+	return "obd2: service " + strconv.FormatUint(uint64(e.Service), 16) +
+		" rejected with code " + strconv.FormatUint(uint64(e.Code), 16)
+}
+
+// Client sends OBD-II requests and decodes the responses.
+type Client struct {
+	conn      *isotp.Conn
+	buf       [64]byte
+	supported [8]uint32 // supported PIDs, a bitmap per block of 32
+	known     uint8     // blocks of supported that have been requested
+}
+
+// New returns a client that uses 11-bit functional addressing on bus. The
+// CAN bus must run at the speed of the vehicle, usually 500kbit/s.
+func New(bus drivers.CAN) *Client {
+	return NewConn(isotp.New(bus, isotp.Config{
+		TxID:          FunctionalID,
+		RxID:          EngineReplyID,
+		FlowControlID: EngineID,
+		Padding:       true,
+		PadByte:       0x55,
+		Timeout:       200 * time.Millisecond,
+	}))
+}
+
+// NewConn returns a client that uses an ISO-TP connection, for vehicles that
+// use other CAN IDs.
+func NewConn(conn *isotp.Conn) *Client {
+	return &Client{conn: conn}
+}
+
+// Request sends a request for a PID and returns the data of the response,
+// without the service and PID. The data is only valid until the next request.
+func (c *Client) Request(service, pid byte) ([]byte, error) {
+	c.buf[0] = service
+	c.buf[1] = pid
+	if err := c.conn.Send(c.buf[:2]); err != nil {
+		return nil, err
+	}
+	for {
+		n, err := c.conn.Receive(c.buf[:])
+		if err != nil {
+			return nil, err
+		}
+		resp := c.buf[:n]
+		if len(resp) >= 3 && resp[0] == negativeResponse && resp[1] == service {
+			return nil, NegativeResponseError{Service: service, Code: resp[2]}
+		}
+		if len(resp) < 2 || resp[0] != service+positiveResponse {
+			return nil, ErrUnexpectedResponse
+		}
+		if resp[1] != pid {
+			// A late response to an earlier request.
+			continue
+		}
+		return resp[2:], nil
+	}
+}
+
+// Supported returns whether the vehicle supports a service 01 PID.
+func (c *Client) Supported(pid PID) (bool, error) {
+	if pid == 0 {
+		return true, nil
+	}
+	block := (pid - 1) / pidSupportedInterval
+	for b := PID(0); b <= block; b++ {
+		if c.known&(1<<b) != 0 {
+			continue
+		}
+		if b > 0 && c.supported[b-1]&1 == 0 {
+			// The previous block says there are no more.
+			return false, nil
+		}
+		data, err := c.Request(ServiceCurrentData, byte(b*pidSupportedInterval))
+		if err != nil {
+			return false, err
+		}
+		if len(data) < 4 {
+			return false, ErrUnexpectedResponse
+		}
+		c.supported[b] = uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+		c.known |= 1 << b
+	}
+	bit := 31 - (pid-1)%pidSupportedInterval
+	return c.supported[block]&(1<<bit) != 0, nil
+}
+
+// Query requests a service 01 PID and returns its value, in the unit given
+// with the PID constants.
+func (c *Client) Query(pid PID) (float32, error) {
+	size := pidSize(pid)
+	if size == 0 {
+		return 0, ErrUnknownPID
+	}
+	data, err := c.Request(ServiceCurrentData, byte(pid))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < size {
+		return 0, ErrUnexpectedResponse
+	}
+	return decode(pid, data), nil
+}
+
+// EngineRPM returns the engine speed in revolutions per minute.
+func (c *Client) EngineRPM() (float32, error) {
+	return c.Query(PIDEngineRPM)
+}
+
+// VehicleSpeed returns the vehicle speed in km/h.
+func (c *Client) VehicleSpeed() (int, error) {
+	v, err := c.Query(PIDVehicleSpeed)
+	return int(v), err
+}
+
+// CoolantTemperature returns the engine coolant temperature in °C.
+func (c *Client) CoolantTemperature() (int, error) {
+	v, err := c.Query(PIDCoolantTemp)
+	return int(v), err
+}
+
+// VIN returns the 17 character vehicle identification number.
+func (c *Client) VIN() (string, error) {
+	data, err := c.Request(ServiceVehicleInfo, pidVIN)
+	if err != nil {
+		return "", err
+	}
+	// The first byte is the number of data items, which is 1. Some vehicles
+	// pad the VIN at the start.
+	if len(data) < 18 {
+		return "", ErrUnexpectedResponse
+	}
+	return string(data[len(data)-17:]), nil
+}
+
+// pidSize returns the number of data bytes of a PID, or 0 if it is unknown.
+func pidSize(pid PID) int {
+	switch pid {
+	case PIDEngineLoad, PIDCoolantTemp, PIDIntakePressure, PIDVehicleSpeed,
+		PIDTimingAdvance, PIDIntakeAirTemp, PIDThrottle, PIDFuelLevel,
+		PIDBarometric, PIDAmbientAirTemp, PIDOilTemp:
+		return 1
+	case PIDEngineRPM, PIDMAFRate, PIDRunTime, PIDControlVoltage, PIDFuelRate:
+		return 2
+	case PIDOdometer:
+		return 4
+	}
+	return 0
+}
+
+// decode returns the value of a PID with the formulas of SAE J1979.
+func decode(pid PID, data []byte) float32 {
+	a := float32(data[0])
+	switch pid {
+	case PIDEngineLoad, PIDThrottle, PIDFuelLevel:
+		return a * 100 / 255
+	case PIDCoolantTemp, PIDIntakeAirTemp, PIDAmbientAirTemp, PIDOilTemp:
+		return a - 40
+	case PIDTimingAdvance:
+		return a/2 - 64
+	case PIDOdometer:
+		return float32(uint32(data[0])<<24|uint32(data[1])<<16|uint32(data[2])<<8|uint32(data[3])) / 10
+	}
+	if pidSize(pid) == 2 {
+		ab := float32(uint16(data[0])<<8 | uint16(data[1]))
+		switch pid {
+		case PIDEngineRPM:
+			return ab / 4
+		case PIDMAFRate:
+			return ab / 100
+		case PIDControlVoltage:
+			return ab / 1000
+		case PIDFuelRate:
+			return ab / 20
+		}
+		return ab
+	}
+	return a
+}
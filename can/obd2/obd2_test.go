@@ -0,0 +1,110 @@
+package obd2
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/can/isotp"
+	"tinygo.org/x/drivers/tester"
+)
+
+const vin = "1G1JC5444R7252367"
+
+// ecu simulates an engine control module that answers functional requests.
+func ecu(node *tester.CANNode, responses map[uint16][]byte, done chan struct{}) {
+	conn := isotp.New(node, isotp.Config{TxID: EngineReplyID, RxID: EngineID, Padding: true})
+	var f drivers.CANFrame
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if ok, _ := node.Receive(&f); !ok {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		if f.ID != FunctionalID || f.Data[0] != 2 {
+			continue
+		}
+		service, pid := f.Data[1], f.Data[2]
+		resp, ok := responses[uint16(service)<<8|uint16(pid)]
+		if !ok {
+			conn.Send([]byte{negativeResponse, service, 0x12})
+			continue
+		}
+		conn.Send(append([]byte{service + positiveResponse, pid}, resp...))
+	}
+}
+
+func newClient(t *testing.T) *Client {
+	bus := tester.NewCANBus()
+	client := New(bus.Node())
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go ecu(bus.Node(), map[uint16][]byte{
+		0x0100: {0xBE, 0x1F, 0xB8, 0x11}, // 01-07, 0C-0D, 0F-11, 13-15, 1C, 20
+		0x0120: {0x80, 0x00, 0x00, 0x00}, // 21
+		0x0105: {0x7B},
+		0x010C: {0x1A, 0xF8},
+		0x010D: {0x3C},
+		0x0110: {0x01, 0x2C},
+		0x0111: {0x40},
+		0x0902: append([]byte{0x01}, vin...),
+	}, done)
+	return client
+}
+
+func TestQuery(t *testing.T) {
+	c := qt.New(t)
+	client := newClient(t)
+
+	rpm, err := client.EngineRPM()
+	c.Assert(err, qt.IsNil)
+	c.Assert(rpm, qt.Equals, float32(1726))
+	speed, err := client.VehicleSpeed()
+	c.Assert(err, qt.IsNil)
+	c.Assert(speed, qt.Equals, 60)
+	temp, err := client.CoolantTemperature()
+	c.Assert(err, qt.IsNil)
+	c.Assert(temp, qt.Equals, 83)
+	maf, err := client.Query(PIDMAFRate)
+	c.Assert(err, qt.IsNil)
+	c.Assert(maf, qt.Equals, float32(3))
+	throttle, err := client.Query(PIDThrottle)
+	c.Assert(err, qt.IsNil)
+	c.Assert(throttle > 25 && throttle < 25.2, qt.IsTrue)
+
+	_, err = client.Query(PIDOilTemp)
+	c.Assert(err, qt.Equals, error(NegativeResponseError{Service: ServiceCurrentData, Code: 0x12}))
+	_, err = client.Query(0x01)
+	c.Assert(err, qt.Equals, ErrUnknownPID)
+}
+
+func TestSupported(t *testing.T) {
+	c := qt.New(t)
+	client := newClient(t)
+	for pid, want := range map[PID]bool{
+		PIDEngineLoad:    true,
+		PIDEngineRPM:     true,
+		PIDIntakeAirTemp: true,
+		PIDRunTime:       false,
+		0x21:             true,
+		PIDFuelLevel:     false,
+		PIDOdometer:      false, // block 0x40 is not supported
+	} {
+		ok, err := client.Supported(pid)
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.Equals, want, qt.Commentf("PID %02X", pid))
+	}
+}
+
+func TestVIN(t *testing.T) {
+	c := qt.New(t)
+	client := newClient(t)
+	v, err := client.VIN()
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.Equals, vin)
+}
@@ -0,0 +1,302 @@
+// Command assetpack converts images into pre-decoded bitmaps for use with
+// the tinygo.org/x/drivers/image/asset package.
+//
+// By default it writes Go source with one asset.Bitmap variable per input:
+//
+//	go run ./cmd/assetpack -format rgb565 -compress rle -pkg main -o images.go gopher.png logo.png
+//
+// With -pack it writes a single binary blob with an index instead, which can be
+// stored in a flash.Device or on an SD card and read back with asset.Open:
+//
+//	go run ./cmd/assetpack -pack -format mono -compress lz -o assets.bin icons/*.png
+//
+// The raw format stores the input file unchanged, which is useful for PNG or
+// JPEG files that are decoded on the device with image/png or image/jpeg.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"unicode"
+
+	"tinygo.org/x/drivers/image/asset"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type options struct {
+	format    asset.Format
+	compress  asset.Compression
+	pack      bool
+	pkg       string
+	threshold uint
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("assetpack", flag.ContinueOnError)
+	formatName := fs.String("format", "rgb565", "pixel format: rgb565, mono, gray4 or raw")
+	compressName := fs.String("compress", "none", "compression: none, rle or lz")
+	pack := fs.Bool("pack", false, "write a binary asset pack instead of Go source")
+	pkg := fs.String("pkg", "main", "package name of the generated Go source")
+	output := fs.String("o", "", "output file (default stdout)")
+	threshold := fs.Uint("threshold", 128, "luminance threshold (0-255) for the mono format")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: assetpack [flags] FILE...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no input files")
+	}
+
+	opts := options{pack: *pack, pkg: *pkg, threshold: *threshold}
+	var err error
+	if opts.format, err = parseFormat(*formatName); err != nil {
+		return err
+	}
+	if opts.compress, err = parseCompression(*compressName); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if opts.pack {
+		err = writePack(&buf, fs.Args(), opts)
+	} else {
+		err = writeSource(&buf, fs.Args(), opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(*output, buf.Bytes(), 0644)
+}
+
+func parseFormat(s string) (asset.Format, error) {
+	for _, f := range []asset.Format{asset.FormatRaw, asset.FormatRGB565, asset.FormatMono, asset.FormatGray4} {
+		if f.String() == s {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %q", s)
+}
+
+func parseCompression(s string) (asset.Compression, error) {
+	for _, c := range []asset.Compression{asset.CompressionNone, asset.CompressionRLE, asset.CompressionLZ} {
+		if c.String() == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression %q", s)
+}
+
+// load reads and converts a single input file. The returned entry has all
+// fields except Offset and Size filled in.
+func load(path string, opts options) (asset.Entry, []byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return asset.Entry{}, nil, err
+	}
+	e := asset.Entry{
+		Name:        filepath.Base(path),
+		Format:      opts.format,
+		Compression: opts.compress,
+	}
+
+	var raw []byte
+	if opts.format == asset.FormatRaw {
+		// Keep the file as is, but record the size if it is an image.
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(b)); err == nil {
+			e.Width, e.Height = int16(cfg.Width), int16(cfg.Height)
+		}
+		raw = b
+	} else {
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			return asset.Entry{}, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		size := img.Bounds().Size()
+		if size.X > 0x7fff || size.Y > 0x7fff {
+			return asset.Entry{}, nil, fmt.Errorf("%s: image too large", path)
+		}
+		e.Width, e.Height = int16(size.X), int16(size.Y)
+		raw = convert(img, opts)
+	}
+	e.RawSize = uint32(len(raw))
+
+	data, err := asset.Compress(raw, e.Format, e.Compression)
+	if err != nil {
+		return asset.Entry{}, nil, err
+	}
+	return e, data, nil
+}
+
+// convert turns img into the pixel format selected in opts. Transparent
+// pixels are blended onto black.
+func convert(img image.Image, opts options) []byte {
+	bounds := img.Bounds()
+	rowSize := opts.format.RowSize(bounds.Dx())
+	out := make([]byte, rowSize*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := out[(y-bounds.Min.Y)*rowSize:]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			i := x - bounds.Min.X
+			switch opts.format {
+			case asset.FormatRGB565:
+				v := uint16(c.R&0xf8)<<8 | uint16(c.G&0xfc)<<3 | uint16(c.B)>>3
+				row[i*2] = byte(v >> 8)
+				row[i*2+1] = byte(v)
+			case asset.FormatMono:
+				if uint(luminance(c)) >= opts.threshold {
+					row[i/8] |= 0x80 >> uint(i%8)
+				}
+			case asset.FormatGray4:
+				v := luminance(c) >> 4
+				if i%2 == 0 {
+					v <<= 4
+				}
+				row[i/2] |= v
+			}
+		}
+	}
+	return out
+}
+
+func luminance(c color.RGBA) uint8 {
+	return uint8((299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B)) / 1000)
+}
+
+func writePack(w io.Writer, paths []string, opts options) error {
+	var pw asset.PackWriter
+	for _, path := range paths {
+		e, data, err := load(path, opts)
+		if err != nil {
+			return err
+		}
+		if err := pw.Add(e, data); err != nil {
+			return err
+		}
+	}
+	_, err := pw.WriteTo(w)
+	return err
+}
+
+func writeSource(w io.Writer, paths []string, opts options) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by assetpack; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", opts.pkg)
+	fmt.Fprintf(&buf, "import \"tinygo.org/x/drivers/image/asset\"\n")
+	seen := make(map[string]string)
+	for _, path := range paths {
+		id := identifier(path)
+		if prev, ok := seen[id]; ok {
+			return fmt.Errorf("%s and %s both become the variable %s, rename one of them", prev, path, id)
+		}
+		seen[id] = path
+		e, data, err := load(path, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "\n// %s is %s (%dx%d, %d bytes).\n", id, filepath.Base(path), e.Width, e.Height, len(data))
+		fmt.Fprintf(&buf, "var %s = asset.Bitmap{\n", id)
+		fmt.Fprintf(&buf, "Width: %d,\nHeight: %d,\n", e.Width, e.Height)
+		fmt.Fprintf(&buf, "Format: asset.Format%s,\n", formatConst(e.Format))
+		fmt.Fprintf(&buf, "Compression: asset.Compression%s,\n", compressionConst(e.Compression))
+		fmt.Fprintf(&buf, "Data: \"\" +\n")
+		writeString(&buf, data)
+		fmt.Fprintf(&buf, ",\n}\n")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// writeString writes data as a concatenation of string literals with hex
+// escapes, 32 bytes per line.
+func writeString(buf *bytes.Buffer, data []byte) {
+	const max = 32
+	if len(data) == 0 {
+		buf.WriteString("\"\"")
+	}
+	for i := 0; i < len(data); i += max {
+		end := i + max
+		if end > len(data) {
+			end = len(data)
+		}
+		buf.WriteString("\"")
+		for _, b := range data[i:end] {
+			fmt.Fprintf(buf, "\\x%02X", b)
+		}
+		buf.WriteString("\"")
+		if end < len(data) {
+			buf.WriteString(" +\n")
+		}
+	}
+}
+
+// identifier derives a Go identifier from a file name, for example
+// "gopher-small.png" becomes "gopher_small_png". Different names can give the
+// same identifier, which writeSource reports as an error.
+func identifier(path string) string {
+	name := []rune(filepath.Base(path))
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			name[i] = '_'
+		}
+	}
+	s := string(name)
+	if s == "" || unicode.IsDigit(name[0]) {
+		s = "_" + s
+	}
+	return s
+}
+
+func formatConst(f asset.Format) string {
+	switch f {
+	case asset.FormatRGB565:
+		return "RGB565"
+	case asset.FormatMono:
+		return "Mono"
+	case asset.FormatGray4:
+		return "Gray4"
+	}
+	return "Raw"
+}
+
+func compressionConst(c asset.Compression) string {
+	switch c {
+	case asset.CompressionRLE:
+		return "RLE"
+	case asset.CompressionLZ:
+		return "LZ"
+	}
+	return "None"
+}
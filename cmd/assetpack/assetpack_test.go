@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/image/asset"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// writeImages writes two small images to dir and returns their paths.
+func writeImages(c *qt.C, dir string) []string {
+	gradient := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		gradient.Set(x, 0, color.RGBA{uint8(x * 85), 0, 0, 255})
+		gradient.Set(x, 1, color.RGBA{0, 0, uint8(x * 85), 255})
+	}
+	blank := image.NewGray(image.Rect(0, 0, 9, 1))
+	blank.Set(0, 0, color.White)
+	var paths []string
+	for _, f := range []struct {
+		name string
+		img  image.Image
+	}{
+		{"gopher-small.png", gradient},
+		{"1x.png", blank},
+	} {
+		var buf bytes.Buffer
+		c.Assert(png.Encode(&buf, f.img), qt.IsNil)
+		path := filepath.Join(dir, f.name)
+		c.Assert(ioutil.WriteFile(path, buf.Bytes(), 0644), qt.IsNil)
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestSource(t *testing.T) {
+	for _, test := range []struct {
+		golden string
+		args   []string
+	}{
+		{"rgb565.golden", []string{"-pkg", "images"}},
+		{"mono_rle.golden", []string{"-format", "mono", "-compress", "rle"}},
+		{"gray4_lz.golden", []string{"-format", "gray4", "-compress", "lz"}},
+	} {
+		t.Run(test.golden, func(t *testing.T) {
+			c := qt.New(t)
+			paths := writeImages(c, t.TempDir())
+			var out bytes.Buffer
+			c.Assert(run(append(test.args, paths...), &out), qt.IsNil)
+
+			golden := filepath.Join("testdata", test.golden)
+			if *update {
+				c.Assert(ioutil.WriteFile(golden, out.Bytes(), 0644), qt.IsNil)
+			}
+			want, err := ioutil.ReadFile(golden)
+			c.Assert(err, qt.IsNil)
+			c.Assert(out.String(), qt.Equals, string(want))
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	c := qt.New(t)
+	dir := t.TempDir()
+	paths := writeImages(c, dir)
+	output := filepath.Join(dir, "assets.bin")
+	c.Assert(run(append([]string{"-pack", "-format", "mono", "-compress", "lz", "-o", output}, paths...), ioutil.Discard), qt.IsNil)
+
+	f, err := os.Open(output)
+	c.Assert(err, qt.IsNil)
+	defer f.Close()
+	p, err := asset.Open(f, 0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(p.Len(), qt.Equals, 2)
+	e, err := p.Lookup("1x.png")
+	c.Assert(err, qt.IsNil)
+	c.Assert(e.Width, qt.Equals, int16(9))
+	c.Assert(e.Height, qt.Equals, int16(1))
+	r, err := p.Reader(e)
+	c.Assert(err, qt.IsNil)
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, qt.IsNil)
+	c.Assert(data, qt.DeepEquals, []byte{0x80, 0x00})
+}
+
+func TestIdentifierCollision(t *testing.T) {
+	c := qt.New(t)
+	dir := t.TempDir()
+	paths := writeImages(c, dir)
+	other := filepath.Join(dir, "gopher_small.png")
+	c.Assert(os.Rename(paths[1], other), qt.IsNil)
+
+	err := run([]string{paths[0], other}, ioutil.Discard)
+	c.Assert(err, qt.ErrorMatches, `.*gopher-small.png and .*gopher_small.png both become the variable gopher_small_png, rename one of them`)
+}
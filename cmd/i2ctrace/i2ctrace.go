@@ -0,0 +1,146 @@
+// Command i2ctrace decodes the transactions logged by an i2cbus.Trace, such as
+// a capture of the serial output of a board:
+//
+//	go run ./cmd/i2ctrace capture.log
+//
+// Lines that are not trace records, like the other output of the program, are
+// skipped. With -summary it prints the statistics of each address instead,
+// and -addr only keeps the transactions with one address:
+//
+//	go run ./cmd/i2ctrace -summary -addr 0x76 capture.log
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tinygo.org/x/drivers/i2cbus"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type summary struct {
+	transactions, errors int
+	consecutive, max     int
+	time                 time.Duration
+	lastError            string
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("i2ctrace", flag.ContinueOnError)
+	summaryOnly := fs.Bool("summary", false, "print the statistics of each address instead of the transactions")
+	addrFlag := fs.String("addr", "", "only decode the transactions with this address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	addr := -1
+	if *addrFlag != "" {
+		a, err := strconv.ParseUint(*addrFlag, 0, 16)
+		if err != nil {
+			return fmt.Errorf("invalid address %q", *addrFlag)
+		}
+		addr = int(a)
+	}
+
+	var input io.Reader = stdin
+	if fs.NArg() > 0 {
+		var readers []io.Reader
+		for _, name := range fs.Args() {
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			readers = append(readers, f)
+		}
+		input = io.MultiReader(readers...)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	stats := map[uint16]*summary{}
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// A capture can have garbage before the record.
+		i := strings.Index(line, "i2c ")
+		if i < 0 {
+			continue
+		}
+		rec, err := i2cbus.ParseRecord(line[i:])
+		if err != nil || addr >= 0 && int(rec.Addr) != addr {
+			continue
+		}
+		if *summaryOnly {
+			add(stats, &rec)
+			continue
+		}
+		fmt.Fprintf(w, "%.6fs\t%v\t%#02x\t%s\n", rec.Start.Seconds(), rec.Duration, rec.Addr, describe(&rec))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if *summaryOnly {
+		printSummary(w, stats)
+	}
+	return w.Flush()
+}
+
+func describe(rec *i2cbus.Record) string {
+	var s []string
+	if len(rec.W) > 0 {
+		s = append(s, fmt.Sprintf("write % x", rec.W))
+	}
+	if rec.Err != "" {
+		s = append(s, "error: "+rec.Err)
+	} else if len(rec.R) > 0 {
+		s = append(s, fmt.Sprintf("read % x", rec.R))
+	}
+	return strings.Join(s, "  ")
+}
+
+func add(stats map[uint16]*summary, rec *i2cbus.Record) {
+	st := stats[rec.Addr]
+	if st == nil {
+		st = &summary{}
+		stats[rec.Addr] = st
+	}
+	st.transactions++
+	st.time += rec.Duration
+	if rec.Err == "" {
+		st.consecutive = 0
+		return
+	}
+	st.errors++
+	st.consecutive++
+	if st.consecutive > st.max {
+		st.max = st.consecutive
+	}
+	st.lastError = rec.Err
+}
+
+func printSummary(w io.Writer, stats map[uint16]*summary) {
+	var addrs []int
+	for addr := range stats {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+	fmt.Fprintf(w, "address\ttransactions\terrors\tmax consecutive\ttime\tlast error\n")
+	for _, addr := range addrs {
+		st := stats[uint16(addr)]
+		fmt.Fprintf(w, "%#02x\t%d\t%d\t%d\t%v\t%s\n", addr, st.transactions, st.errors, st.max, st.time, st.lastError)
+	}
+}
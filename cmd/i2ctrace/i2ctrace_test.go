@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// capture is the serial output of a board, with the trace between other
+// output of the program.
+const capture = `booting
+i2c 1024 180 76 d0 60 ok
+temperature: 21.5 i2c 2048 95 76 f427 - ok
+i2c 3000 120 29 010f - tester: I2C address not acknowledged
+i2c 4000 110 29 010f - tester: I2C address not acknowledged
+i2c 5000 300 29 010f eacc ok
+i2c x 2 76 d0 60 ok
+done
+`
+
+func TestRun(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{{
+		args: nil,
+		want: `0.001024s  180µs  0x76  write d0  read 60
+0.002048s  95µs   0x76  write f4 27
+0.003000s  120µs  0x29  write 01 0f  error: tester: I2C address not acknowledged
+0.004000s  110µs  0x29  write 01 0f  error: tester: I2C address not acknowledged
+0.005000s  300µs  0x29  write 01 0f  read ea cc
+`,
+	}, {
+		args: []string{"-addr", "0x76"},
+		want: `0.001024s  180µs  0x76  write d0  read 60
+0.002048s  95µs   0x76  write f4 27
+`,
+	}, {
+		// The tab writer pads the empty last error.
+		args: []string{"-summary"},
+		want: "address  transactions  errors  max consecutive  time   last error\n" +
+			"0x29     3             2       2                530µs  tester: I2C address not acknowledged\n" +
+			"0x76     2             0       0                275µs  \n",
+	}} {
+		c := qt.New(t)
+		var out bytes.Buffer
+		c.Assert(run(test.args, strings.NewReader(capture), &out), qt.IsNil)
+		c.Assert(out.String(), qt.Equals, test.want, qt.Commentf("%q", test.args))
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	c := qt.New(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "capture.log")
+	c.Assert(ioutil.WriteFile(name, []byte(capture), 0644), qt.IsNil)
+
+	// The files are read instead of stdin.
+	var out bytes.Buffer
+	c.Assert(run([]string{"-addr", "41", name, name}, strings.NewReader("i2c 1 2 29 00 - ok\n"), &out), qt.IsNil)
+	c.Assert(strings.Count(out.String(), "\n"), qt.Equals, 6)
+
+	c.Assert(run([]string{"-addr", "x"}, nil, &out), qt.ErrorMatches, `invalid address "x"`)
+	c.Assert(run([]string{filepath.Join(dir, "missing.log")}, nil, &out), qt.ErrorMatches, `open .*missing.log: .*`)
+}
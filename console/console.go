@@ -0,0 +1,507 @@
+// Package console provides a scrolling text terminal on a display, such as to
+// show logs. It implements io.Writer, and understands the ANSI (VT100) escape
+// sequences for colors, cursor motion and erasing:
+//
+//	con := console.New(display)
+//	con.Configure(console.Config{})
+//	fmt.Fprintf(con, "\x1b[32mOK\x1b[0m %d\n", n)
+//
+// To mirror logs to the display, write them to the console and to the serial
+// port or semihosting output with io.MultiWriter.
+//
+// The console doesn't need a frame buffer: characters are drawn when they are
+// written, and the console keeps the characters and their colors to redraw
+// the lines that change when it scrolls. Displays with hardware scrolling,
+// such as the ili9341 and st7789, scroll without redrawing, and then the
+// console doesn't keep the characters either.
+package console // import "tinygo.org/x/drivers/console"
+
+import (
+	"image/color"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/pixel"
+)
+
+// Scroller is a display that scrolls its memory vertically in hardware.
+type Scroller interface {
+	SetScrollArea(topFixedArea, bottomFixedArea int16)
+	SetScroll(line int16)
+}
+
+// Config is the configuration of a console.
+type Config struct {
+	// Font defaults to Font6x8.
+	Font *Font
+
+	// Palette replaces the 16 ANSI colors, of which 7 and 0 are the
+	// default foreground and background.
+	Palette *[16]color.RGBA
+
+	// HardwareScroll scrolls with a display that implements Scroller. The
+	// display must not be rotated by 90 or 270 degrees, and the lines
+	// below the last row of text are not used.
+	HardwareScroll bool
+}
+
+// DefaultPalette holds the ANSI colors as the VGA text mode shows them.
+var DefaultPalette = [16]color.RGBA{
+	{0x00, 0x00, 0x00, 0xFF}, // black
+	{0xAA, 0x00, 0x00, 0xFF}, // red
+	{0x00, 0xAA, 0x00, 0xFF}, // green
+	{0xAA, 0x55, 0x00, 0xFF}, // yellow
+	{0x00, 0x00, 0xAA, 0xFF}, // blue
+	{0xAA, 0x00, 0xAA, 0xFF}, // magenta
+	{0x00, 0xAA, 0xAA, 0xFF}, // cyan
+	{0xAA, 0xAA, 0xAA, 0xFF}, // white
+	{0x55, 0x55, 0x55, 0xFF}, // bright black
+	{0xFF, 0x55, 0x55, 0xFF}, // bright red
+	{0x55, 0xFF, 0x55, 0xFF}, // bright green
+	{0xFF, 0xFF, 0x55, 0xFF}, // bright yellow
+	{0x55, 0x55, 0xFF, 0xFF}, // bright blue
+	{0xFF, 0x55, 0xFF, 0xFF}, // bright magenta
+	{0x55, 0xFF, 0xFF, 0xFF}, // bright cyan
+	{0xFF, 0xFF, 0xFF, 0xFF}, // bright white
+}
+
+// Default colors, as indexes in the palette.
+const (
+	defaultFg = 7
+	defaultBg = 0
+)
+
+// States of the escape sequence parser.
+const (
+	stateText = iota
+	stateEscape
+	stateCSI // control sequence, after ESC [
+)
+
+// maxParams is the number of parameters of a control sequence that are kept.
+const maxParams = 8
+
+// tabWidth is the distance between tab stops.
+const tabWidth = 8
+
+// cell is a character on the screen, with its foreground color in the lower
+// nibble of attr and its background color in the upper nibble.
+type cell struct {
+	ch   byte
+	attr byte
+}
+
+// Console is a text terminal on a display.
+type Console struct {
+	display  drivers.Displayer
+	bitmap   drivers.BitmapDisplayer
+	scroller Scroller
+	font     *Font
+	palette  [16]color.RGBA
+	glyph    pixel.Image
+	cells    []cell // nil with hardware scrolling
+	cols     int
+	rows     int
+	col      int // may be cols after the last column is written
+	row      int
+	savedCol int
+	savedRow int
+	top      int // row of the display memory at the top of the screen
+	fg       uint8
+	bg       uint8
+	bold     bool
+	reverse  bool
+	attr     byte
+	state    int
+	private  bool
+	params   [maxParams]int
+	nparams  int
+	dirty    bool
+	err      error
+}
+
+// New returns a console on a display. The display must be configured.
+func New(display drivers.Displayer) *Console {
+	return &Console{
+		display: display,
+	}
+}
+
+// Configure sets up the console and clears the screen.
+func (c *Console) Configure(cfg Config) {
+	c.font = cfg.Font
+	if c.font == nil {
+		c.font = &Font6x8
+	}
+	c.palette = DefaultPalette
+	if cfg.Palette != nil {
+		c.palette = *cfg.Palette
+	}
+
+	w, h := c.display.Size()
+	fw, fh := int16(c.font.Width), int16(c.font.Height)
+	c.cols = int(w / fw)
+	c.rows = int(h / fh)
+
+	c.bitmap = nil
+	if b, ok := c.display.(drivers.BitmapDisplayer); ok && b.PixelFormat() != 0 {
+		c.bitmap = b
+		c.glyph = pixel.NewImage(b.PixelFormat(), fw, fh)
+	}
+	c.scroller = nil
+	c.cells = nil
+	if s, ok := c.display.(Scroller); ok && cfg.HardwareScroll {
+		c.scroller = s
+		s.SetScrollArea(0, h-int16(c.rows)*fh)
+	} else {
+		c.cells = make([]cell, c.cols*c.rows)
+	}
+	c.resetAttr()
+	c.state = stateText
+	c.Clear()
+}
+
+// Size returns the number of columns and rows of text.
+func (c *Console) Size() (cols, rows int) {
+	return c.cols, c.rows
+}
+
+// Cursor returns the position where the next character is written.
+func (c *Console) Cursor() (col, row int) {
+	if c.col >= c.cols {
+		return c.cols - 1, c.row
+	}
+	return c.col, c.row
+}
+
+// Clear clears the screen with the current background color, and moves the
+// cursor to the top left corner.
+func (c *Console) Clear() {
+	c.top = 0
+	if c.scroller != nil {
+		c.scroller.SetScroll(0)
+	}
+	for row := 0; row < c.rows; row++ {
+		c.erase(0, c.cols, row)
+	}
+	c.col, c.row = 0, 0
+	c.flush()
+}
+
+// Write writes text and escape sequences to the console. It returns the first
+// error of the display.
+func (c *Console) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.put(b)
+	}
+	return len(p), c.flush()
+}
+
+// WriteString is the same as Write, for a string.
+func (c *Console) WriteString(s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c.put(s[i])
+	}
+	return len(s), c.flush()
+}
+
+// flush updates a display with a buffer, and returns the first error since
+// the previous flush.
+func (c *Console) flush() error {
+	if c.dirty {
+		c.dirty = false
+		c.setErr(c.display.Display())
+	}
+	err := c.err
+	c.err = nil
+	return err
+}
+
+func (c *Console) setErr(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// put handles a byte of text or of an escape sequence.
+func (c *Console) put(b byte) {
+	switch c.state {
+	case stateEscape:
+		c.state = stateText
+		switch b {
+		case '[':
+			c.state = stateCSI
+			c.private = false
+			c.params = [maxParams]int{}
+			c.nparams = 0
+		case 'c': // reset
+			c.resetAttr()
+			c.Clear()
+		case '7':
+			c.savedCol, c.savedRow = c.col, c.row
+		case '8':
+			c.col, c.row = c.savedCol, c.savedRow
+		}
+		return
+	case stateCSI:
+		switch {
+		case b >= '0' && b <= '9':
+			p := &c.params[c.nparams]
+			*p = *p*10 + int(b-'0')
+		case b == ';':
+			if c.nparams < maxParams-1 {
+				c.nparams++
+			}
+		case b == '?':
+			c.private = true
+		case b >= 0x40 && b <= 0x7E:
+			c.state = stateText
+			if !c.private {
+				c.control(b)
+			}
+		}
+		return
+	}
+
+	switch b {
+	case 0x1B:
+		c.state = stateEscape
+	case '\n':
+		// As the output of println, a new line also returns the
+		// cursor to the first column.
+		c.col = 0
+		c.lineFeed()
+	case '\r':
+		c.col = 0
+	case '\b':
+		if c.col >= c.cols {
+			c.col = c.cols - 1
+		}
+		if c.col > 0 {
+			c.col--
+		}
+	case '\t':
+		c.col = (c.col/tabWidth + 1) * tabWidth
+		if c.col >= c.cols {
+			c.col = c.cols - 1
+		}
+	default:
+		if b < ' ' || b == 0x7F || (b >= 0x80 && b < 0xC0) {
+			// Control characters and the continuation bytes of
+			// UTF-8 are ignored. Other characters that the font
+			// doesn't have are shown as '?'.
+			return
+		}
+		if c.col >= c.cols {
+			c.col = 0
+			c.lineFeed()
+		}
+		c.set(c.col, c.row, cell{b, c.attr})
+		c.col++
+	}
+}
+
+// control executes a control sequence.
+func (c *Console) control(final byte) {
+	n := c.params[0]
+	if n == 0 {
+		n = 1
+	}
+	if c.col >= c.cols {
+		c.col = c.cols - 1
+	}
+	switch final {
+	case 'A': // cursor up
+		c.row = clamp(c.row-n, c.rows)
+	case 'B': // cursor down
+		c.row = clamp(c.row+n, c.rows)
+	case 'C': // cursor forward
+		c.col = clamp(c.col+n, c.cols)
+	case 'D': // cursor back
+		c.col = clamp(c.col-n, c.cols)
+	case 'H', 'f': // cursor position, from 1
+		c.row = clamp(c.params[0]-1, c.rows)
+		c.col = clamp(c.params[1]-1, c.cols)
+	case 'J': // erase in display
+		switch c.params[0] {
+		case 0:
+			c.erase(c.col, c.cols, c.row)
+			for row := c.row + 1; row < c.rows; row++ {
+				c.erase(0, c.cols, row)
+			}
+		case 1:
+			for row := 0; row < c.row; row++ {
+				c.erase(0, c.cols, row)
+			}
+			c.erase(0, c.col+1, c.row)
+		case 2:
+			for row := 0; row < c.rows; row++ {
+				c.erase(0, c.cols, row)
+			}
+		}
+	case 'K': // erase in line
+		switch c.params[0] {
+		case 0:
+			c.erase(c.col, c.cols, c.row)
+		case 1:
+			c.erase(0, c.col+1, c.row)
+		case 2:
+			c.erase(0, c.cols, c.row)
+		}
+	case 'm': // select graphic rendition
+		for _, p := range c.params[:c.nparams+1] {
+			c.setGraphics(p)
+		}
+	case 's':
+		c.savedCol, c.savedRow = c.col, c.row
+	case 'u':
+		c.col, c.row = c.savedCol, c.savedRow
+	}
+}
+
+// setGraphics sets a color or attribute of the following text.
+func (c *Console) setGraphics(p int) {
+	switch {
+	case p == 0:
+		c.resetAttr()
+		return
+	case p == 1:
+		c.bold = true
+	case p == 22:
+		c.bold = false
+	case p == 7:
+		c.reverse = true
+	case p == 27:
+		c.reverse = false
+	case p >= 30 && p <= 37:
+		c.fg = uint8(p - 30)
+	case p == 39:
+		c.fg = defaultFg
+	case p >= 40 && p <= 47:
+		c.bg = uint8(p - 40)
+	case p == 49:
+		c.bg = defaultBg
+	case p >= 90 && p <= 97:
+		c.fg = uint8(p-90) + 8
+	case p >= 100 && p <= 107:
+		c.bg = uint8(p-100) + 8
+	}
+	c.updateAttr()
+}
+
+func (c *Console) resetAttr() {
+	c.fg, c.bg = defaultFg, defaultBg
+	c.bold, c.reverse = false, false
+	c.updateAttr()
+}
+
+func (c *Console) updateAttr() {
+	fg, bg := c.fg, c.bg
+	if c.bold && fg < 8 {
+		fg += 8
+	}
+	if c.reverse {
+		fg, bg = bg, fg
+	}
+	c.attr = fg | bg<<4
+}
+
+// lineFeed moves the cursor down, and scrolls at the last row.
+func (c *Console) lineFeed() {
+	if c.row < c.rows-1 {
+		c.row++
+		return
+	}
+	if c.scroller != nil {
+		// The first row becomes the last one.
+		c.top = (c.top + 1) % c.rows
+		c.erase(0, c.cols, c.rows-1)
+		c.scroller.SetScroll(int16(c.top) * int16(c.font.Height))
+		return
+	}
+	// Redraw the characters that differ from the ones above.
+	for row := 0; row < c.rows-1; row++ {
+		for col := 0; col < c.cols; col++ {
+			c.set(col, row, c.cells[(row+1)*c.cols+col])
+		}
+	}
+	c.erase(0, c.cols, c.rows-1)
+}
+
+// erase clears the columns from start up to end of a row, with the current
+// background color.
+func (c *Console) erase(start, end, row int) {
+	if end > c.cols {
+		end = c.cols
+	}
+	blank := cell{' ', c.attr&0xF0 | c.attr>>4}
+	if c.bitmap == nil || c.cells != nil {
+		for col := start; col < end; col++ {
+			c.set(col, row, blank)
+		}
+		return
+	}
+	if start >= end {
+		return
+	}
+	fw, fh := int16(c.font.Width), int16(c.font.Height)
+	c.setErr(c.bitmap.FillRect(int16(start)*fw, c.y(row), int16(end-start)*fw, fh, c.palette[c.attr>>4]))
+}
+
+// set changes a character on the screen, if it is different.
+func (c *Console) set(col, row int, ch cell) {
+	if c.cells != nil {
+		i := row*c.cols + col
+		if c.cells[i] == ch {
+			return
+		}
+		c.cells[i] = ch
+	}
+	c.draw(col, row, ch)
+}
+
+// draw draws a character.
+func (c *Console) draw(col, row int, ch cell) {
+	fg := c.palette[ch.attr&0x0F]
+	bg := c.palette[ch.attr>>4]
+	glyph := c.font.glyph(ch.ch)
+	x0, y0 := int16(col)*int16(c.font.Width), c.y(row)
+	c.dirty = true
+	if c.bitmap != nil {
+		for x, bits := range glyph {
+			for y := int16(0); y < int16(c.font.Height); y++ {
+				if bits>>y&1 != 0 {
+					c.glyph.Set(int16(x), y, fg)
+				} else {
+					c.glyph.Set(int16(x), y, bg)
+				}
+			}
+		}
+		c.setErr(c.bitmap.DrawBitmap(x0, y0, c.glyph))
+		return
+	}
+	for x, bits := range glyph {
+		for y := int16(0); y < int16(c.font.Height); y++ {
+			if bits>>y&1 != 0 {
+				c.display.SetPixel(x0+int16(x), y0+y, fg)
+			} else {
+				c.display.SetPixel(x0+int16(x), y0+y, bg)
+			}
+		}
+	}
+}
+
+// y returns the coordinate of a row in the display memory.
+func (c *Console) y(row int) int16 {
+	return int16((row+c.top)%c.rows) * int16(c.font.Height)
+}
+
+// clamp limits v to 0 up to n-1.
+func clamp(v, n int) int {
+	if v < 0 {
+		return 0
+	}
+	if v >= n {
+		return n - 1
+	}
+	return v
+}
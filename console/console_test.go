@@ -0,0 +1,229 @@
+package console
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/pixel"
+	"tinygo.org/x/drivers/tester"
+)
+
+// display is a Displayer with pixels in memory.
+type display struct {
+	width, height int16
+	pixels        []color.RGBA
+	displays      int
+	setPixels     int
+}
+
+func newDisplay(width, height int16) *display {
+	return &display{
+		width:  width,
+		height: height,
+		pixels: make([]color.RGBA, int(width)*int(height)),
+	}
+}
+
+func (d *display) Size() (int16, int16) {
+	return d.width, d.height
+}
+
+func (d *display) SetPixel(x, y int16, c color.RGBA) {
+	if x < 0 || y < 0 || x >= d.width || y >= d.height {
+		panic(fmt.Sprintf("pixel %d,%d outside display", x, y))
+	}
+	d.setPixels++
+	d.pixels[int(y)*int(d.width)+int(x)] = c
+}
+
+func (d *display) Display() error {
+	d.displays++
+	return nil
+}
+
+// tft is a BitmapDisplayer with hardware scrolling.
+type tft struct {
+	*display
+	scroll      int16
+	bottomFixed int16
+	bitmaps     int
+}
+
+func (d *tft) PixelFormat() pixel.Format {
+	return pixel.RGB565
+}
+
+func (d *tft) DrawBitmap(x, y int16, img pixel.Image) error {
+	d.bitmaps++
+	w, h := img.Size()
+	for i := int16(0); i < h; i++ {
+		for j := int16(0); j < w; j++ {
+			d.SetPixel(x+j, y+i, img.Get(j, i))
+		}
+	}
+	return nil
+}
+
+func (d *tft) FillRect(x, y, width, height int16, c color.RGBA) error {
+	for i := int16(0); i < height; i++ {
+		for j := int16(0); j < width; j++ {
+			d.SetPixel(x+j, y+i, c)
+		}
+	}
+	return nil
+}
+
+func (d *tft) SetScrollArea(topFixedArea, bottomFixedArea int16) {
+	d.bottomFixed = bottomFixedArea
+}
+
+func (d *tft) SetScroll(line int16) {
+	d.scroll = line
+}
+
+func (d *tft) Rotation() drivers.Rotation {
+	return drivers.Rotation0
+}
+
+// screen returns the text on a display, and the foreground color of the
+// first character of each row, by matching the pixels with the font.
+func screen(d *display, scroll int16) (text []string, colors []color.RGBA) {
+	f := &Font6x8
+	cols, rows := int(d.width)/int(f.Width), int(d.height)/int(f.Height)
+	for row := 0; row < rows; row++ {
+		y0 := (int16(row)*int16(f.Height) + scroll) % (int16(rows) * int16(f.Height))
+		var line []byte
+		for col := 0; col < cols; col++ {
+			x0 := int16(col) * int16(f.Width)
+			bg := d.pixels[int(y0)*int(d.width)+int(x0)]
+			var fg color.RGBA
+			var bits [6]byte
+			for x := int16(0); x < int16(f.Width); x++ {
+				for y := int16(0); y < int16(f.Height); y++ {
+					p := d.pixels[int(y0+y)*int(d.width)+int(x0+x)]
+					if p != bg {
+						bits[x] |= 1 << y
+						fg = p
+					}
+				}
+			}
+			ch := byte('#')
+			for c := byte(' '); c <= '~'; c++ {
+				g := f.glyph(c)
+				if string(g) == string(bits[:]) {
+					ch = c
+					break
+				}
+				// A character in reverse video.
+				var inv [6]byte
+				for i := range inv {
+					inv[i] = ^g[i]
+				}
+				if string(inv[:]) == string(bits[:]) {
+					ch = c
+					fg = bg
+					break
+				}
+			}
+			if col == 0 {
+				colors = append(colors, fg)
+			}
+			line = append(line, ch)
+		}
+		text = append(text, strings.TrimRight(string(line), " "))
+	}
+	return text, colors
+}
+
+func TestWrite(t *testing.T) {
+	c := qt.New(t)
+	d := newDisplay(60, 32) // 10x4 characters
+	con := New(d)
+	con.Configure(Config{})
+	cols, rows := con.Size()
+	c.Assert(cols, qt.Equals, 10)
+	c.Assert(rows, qt.Equals, 4)
+
+	n, err := fmt.Fprintf(con, "Hello\nTinyGo %d\r\tx\x7F\xc3\xa9", 1)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 20)
+	text, _ := screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"Hello", "TinyGo 1x?", "", ""})
+	col, row := con.Cursor()
+	c.Assert(col, qt.Equals, 9)
+	c.Assert(row, qt.Equals, 1)
+	c.Assert(d.displays > 0, qt.IsTrue)
+
+	// Wrapping and scrolling.
+	con.WriteString("0123456789abc\nlast")
+	text, _ = screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"TinyGo 1x?", "0123456789", "abc", "last"})
+
+	// Only the changed characters are redrawn.
+	d.setPixels = 0
+	con.WriteString("\n")
+	text, _ = screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"0123456789", "abc", "last", ""})
+	c.Assert(d.setPixels, qt.Equals, (10+10+4+4)*6*8)
+}
+
+func TestEscapeSequences(t *testing.T) {
+	c := qt.New(t)
+	d := newDisplay(60, 32)
+	con := New(d)
+	con.Configure(Config{})
+
+	con.WriteString("\x1b[31mred\x1b[0m\n\x1b[1;34mblue\x1b[m\n\x1b[7minv")
+	text, colors := screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"red", "blue", "inv", ""})
+	c.Assert(colors[0], qt.Equals, DefaultPalette[1])
+	c.Assert(colors[1], qt.Equals, DefaultPalette[12])
+	c.Assert(colors[2], qt.Equals, DefaultPalette[0]) // on white
+
+	// Cursor motion and erasing.
+	con.WriteString("\x1b[2J\x1b[2;3HX\x1b[AY\x1b[2DZ\x1b[10;10HE\x1b[s\x1b[1;1Hab\x1b[u!")
+	text, _ = screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"abZY", "  X", "", "         !"})
+	con.WriteString("\x1b[1;2H\x1b[K\x1b[2;3H\x1b[1K\x1b[?25l")
+	text, _ = screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"a", "", "", "         !"})
+	con.WriteString("\x1b[4;1H\x1b[1J")
+	text, _ = screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"", "", "", "         !"})
+	con.WriteString("\x1bc")
+	text, _ = screen(d, 0)
+	c.Assert(text, qt.DeepEquals, []string{"", "", "", ""})
+}
+
+func TestHardwareScroll(t *testing.T) {
+	c := qt.New(t)
+	d := &tft{display: newDisplay(60, 36)}
+	con := New(d)
+	con.Configure(Config{HardwareScroll: true})
+	c.Assert(d.bottomFixed, qt.Equals, int16(4))
+	c.Assert(con.cells, qt.IsNil)
+
+	con.WriteString("1\n2\n3\n4\n5\n6")
+	c.Assert(d.scroll, qt.Equals, int16(16))
+	text, _ := screen(d.display, d.scroll)
+	c.Assert(text, qt.DeepEquals, []string{"3", "4", "5", "6"})
+	c.Assert(d.bitmaps, qt.Equals, 6)
+
+	con.Clear()
+	c.Assert(d.scroll, qt.Equals, int16(0))
+	text, _ = screen(d.display, 0)
+	c.Assert(text, qt.DeepEquals, []string{"", "", "", ""})
+}
+
+func TestGolden(t *testing.T) {
+	c := qt.New(t)
+	d := tester.NewDisplay(60, 32, pixel.RGB565)
+	con := New(d)
+	con.Configure(Config{})
+	con.WriteString("\x1b[31mred\x1b[0m\n\x1b[1;34mblue\x1b[m\n\x1b[7minv\x1b[m\n\x1b[42mgreen")
+	d.CheckPNG(c, "testdata/colors.png")
+}
@@ -1,5 +1,3 @@
-//go:build tinygo
-
 // Package dht provides a driver for DHTXX family temperature and humidity sensors.
 //
 // [1] Datasheet DHT11: https://www.mouser.com/datasheet/2/758/DHT11-Technical-Data-Sheet-Translated-Version-1143054.pdf
@@ -9,7 +7,6 @@
 package dht // import "tinygo.org/x/drivers/dht"
 
 import (
-	"machine"
 	"time"
 )
 
@@ -83,9 +80,3 @@ var (
 func init() {
 	timeout = cyclesPerMillisecond()
 }
-
-func cyclesPerMillisecond() counter {
-	freq := machine.CPUFrequency()
-	freq /= 1000
-	return counter(freq)
-}
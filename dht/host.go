@@ -0,0 +1,19 @@
+//go:build !tinygo
+
+package dht // import "tinygo.org/x/drivers/dht"
+
+// This file provides the counter for tests with the standard Go toolchain,
+// which count loop iterations of much less than a microsecond.
+type counter uint32
+
+func cyclesPerMillisecond() counter {
+	return 1 << 20
+}
+
+type interruptState struct{}
+
+func disableInterrupts() interruptState {
+	return interruptState{}
+}
+
+func restoreInterrupts(mask interruptState) {}
@@ -1,4 +1,4 @@
-//go:build !mimxrt1062 && !stm32f405 && !atsamd51 && !stm32f103xx && !k210 && !stm32f407
+//go:build tinygo && !mimxrt1062 && !stm32f405 && !atsamd51 && !stm32f103xx && !k210 && !stm32f407
 
 package dht // import "tinygo.org/x/drivers/dht"
 
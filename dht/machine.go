@@ -0,0 +1,22 @@
+//go:build tinygo
+
+package dht // import "tinygo.org/x/drivers/dht"
+
+import (
+	"machine"
+	"runtime/interrupt"
+)
+
+func cyclesPerMillisecond() counter {
+	freq := machine.CPUFrequency()
+	freq /= 1000
+	return counter(freq)
+}
+
+func disableInterrupts() interrupt.State {
+	return interrupt.Disable()
+}
+
+func restoreInterrupts(mask interrupt.State) {
+	interrupt.Restore(mask)
+}
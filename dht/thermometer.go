@@ -1,5 +1,3 @@
-//go:build tinygo
-
 // Package dht provides a driver for DHTXX family temperature and humidity sensors.
 //
 // [1] Datasheet DHT11: https://www.mouser.com/datasheet/2/758/DHT11-Technical-Data-Sheet-Translated-Version-1143054.pdf
@@ -9,9 +7,10 @@
 package dht // import "tinygo.org/x/drivers/dht"
 
 import (
-	"machine"
-	"runtime/interrupt"
 	"time"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 // DummyDevice provides a basic interface for DHT devices.
@@ -30,7 +29,7 @@ type DummyDevice interface {
 // Since taking measurements from the sensor is time consuming procedure and blocks interrupts,
 // user can avoid any hidden calls to the sensor.
 type device struct {
-	pin machine.Pin
+	pin drivers.Pin
 
 	measurements DeviceType
 	initialized  bool
@@ -93,14 +92,14 @@ func (t *device) HumidityFloat() (float32, error) {
 // Perform initialization of the communication protocol.
 // Device lowers the voltage on pin for startingLow=20ms and starts listening for response
 // Section 5.2 in [1]
-func initiateCommunication(p machine.Pin) {
+func initiateCommunication(p drivers.Pin) {
 	// Send low signal to the device
-	p.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	legacy.ConfigurePin(p, drivers.PinModeOutput)
 	p.Low()
 	time.Sleep(startingLow)
 	// Set pin to high and wait for reply
 	p.High()
-	p.Configure(machine.PinConfig{Mode: machine.PinInput})
+	legacy.ConfigurePin(p, drivers.PinModeInput)
 }
 
 // Measurements returns both measurements: temperature and humidity as they sent by the device.
@@ -158,10 +157,10 @@ func (t *device) read() error {
 
 // receiveSignals counts number of low and high cycles. The execution is time critical, so the function disables
 // interrupts
-func receiveSignals(pin machine.Pin, result []counter) {
+func receiveSignals(pin drivers.Pin, result []counter) {
 	i := uint8(0)
-	mask := interrupt.Disable()
-	defer interrupt.Restore(mask)
+	mask := disableInterrupts()
+	defer restoreInterrupts(mask)
 	for ; i < 40; i++ {
 		result[i*2] = expectChange(pin, false)
 		result[i*2+1] = expectChange(pin, true)
@@ -189,7 +188,7 @@ func (t *device) extractData(signals []counter, buf []uint8) error {
 // waitForDataTransmission waits for reply from the sensor.
 // If no reply received, returns NoSignalError.
 // For more details, see section 5.2 in [1]
-func waitForDataTransmission(p machine.Pin) error {
+func waitForDataTransmission(p drivers.Pin) error {
 	// wait for thermometer to pull down
 	if expectChange(p, true) == timeout {
 		return NoSignalError
@@ -209,7 +208,7 @@ func waitForDataTransmission(p machine.Pin) error {
 // This device provides full control to the user.
 // It does not do any hidden measurements calls and does not check
 // for 2 seconds delay between measurements.
-func NewDummyDevice(pin machine.Pin, deviceType DeviceType) DummyDevice {
+func NewDummyDevice(pin drivers.Pin, deviceType DeviceType) DummyDevice {
 	pin.High()
 	return &device{
 		pin:          pin,
@@ -0,0 +1,60 @@
+package dht
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/tester"
+)
+
+// reply returns the waveform of a sensor sending the bits. The driver
+// measures the levels by counting loop iterations, each of which reads the
+// pin once, so the levels last for a number of reads.
+func reply(bits string) []tester.Level {
+	level := func(high bool, reads int) tester.Level {
+		return tester.Level{High: high, Reads: reads}
+	}
+	wave := []tester.Level{level(true, 20), level(false, 80), level(true, 80)}
+	for _, b := range bits {
+		high := 26
+		if b == '1' {
+			high = 70
+		}
+		wave = append(wave, level(false, 50), level(true, high))
+	}
+	return append(wave, level(false, 50), tester.Level{High: true})
+}
+
+func TestReadMeasurements(t *testing.T) {
+	c := qt.New(t)
+	pin := &tester.Pin{}
+	dev := NewDummyDevice(pin, DHT22)
+	pin.PlayOnInput(reply("0000001010001100000000010101111111101110")...)
+	c.Assert(dev.ReadMeasurements(), qt.IsNil)
+	temp, hum, err := dev.Measurements()
+	c.Assert(err, qt.IsNil)
+	c.Assert(temp, qt.Equals, int16(351))
+	c.Assert(hum, qt.Equals, uint16(652))
+	// The start signal is a low pulse of 20ms.
+	p := pin.Pulses()
+	c.Assert(len(p) >= 2 && p[1] >= startingLow, qt.IsTrue, qt.Commentf("pulses %v", p))
+	c.Assert(pin.Mode(), qt.Equals, drivers.PinModeInput)
+
+	// A wrong checksum.
+	pin.PlayOnInput(reply("0000001010001100000000010101111111101111")...)
+	c.Assert(dev.ReadMeasurements(), qt.Equals, ChecksumError)
+
+	// The sensor stops after 39 bits.
+	pin.PlayOnInput(reply("000000101000110000000001010111111110111")...)
+	c.Assert(dev.ReadMeasurements(), qt.Equals, NoDataError)
+}
+
+func TestReadMeasurementsNoSensor(t *testing.T) {
+	c := qt.New(t)
+	pin := &tester.Pin{}
+	dev := NewDummyDevice(pin, DHT11)
+	c.Assert(dev.ReadMeasurements(), qt.Equals, NoSignalError)
+	_, _, err := dev.Measurements()
+	c.Assert(err, qt.Equals, UninitializedDataError)
+}
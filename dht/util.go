@@ -1,14 +1,13 @@
-//go:build tinygo
-
 package dht // import "tinygo.org/x/drivers/dht"
 
 import (
-	"machine"
 	"time"
+
+	"tinygo.org/x/drivers"
 )
 
 // Check if the pin is disabled
-func powerUp(p machine.Pin) bool {
+func powerUp(p drivers.Pin) bool {
 	state := p.Get()
 	if !state {
 		p.High()
@@ -17,7 +16,7 @@ func powerUp(p machine.Pin) bool {
 	return state
 }
 
-func expectChange(p machine.Pin, oldState bool) counter {
+func expectChange(p drivers.Pin, oldState bool) counter {
 	cnt := counter(0)
 	for ; p.Get() == oldState && cnt != timeout; cnt++ {
 	}
@@ -1,6 +1,10 @@
 package drivers
 
-import "image/color"
+import (
+	"image/color"
+
+	"tinygo.org/x/drivers/pixel"
+)
 
 type Displayer interface {
 	// Size returns the current size of the display.
@@ -13,6 +17,31 @@ type Displayer interface {
 	Display() error
 }
 
+// BitmapDisplayer is a display that writes rectangles of pixels directly to
+// its memory, such as the TFT displays. Drawing an image or filling a
+// rectangle is much faster than doing the same with SetPixel.
+type BitmapDisplayer interface {
+	Displayer
+
+	// PixelFormat returns the format of the pixels in the display memory.
+	PixelFormat() pixel.Format
+
+	// DrawBitmap copies an image to the display at the given coordinates.
+	// The image must be in the pixel format of the display, or
+	// pixel.ErrFormat is returned.
+	DrawBitmap(x, y int16, img pixel.Image) error
+
+	// FillRect fills a rectangle with a color.
+	FillRect(x, y, width, height int16, c color.RGBA) error
+
+	// SetScroll sets the line of the display memory that is shown at the
+	// top of the scroll area.
+	SetScroll(line int16)
+
+	// Rotation returns the current rotation of the display.
+	Rotation() Rotation
+}
+
 // Rotation is how much a display has been rotated. Displays can be rotated, and
 // sometimes also mirrored.
 type Rotation uint8
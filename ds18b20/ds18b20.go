@@ -13,6 +13,7 @@ const (
 	CONVERT_TEMPERATURE uint8 = 0x44
 	READ_SCRATCHPAD     uint8 = 0xBE
 	WRITE_SCRATCHPAD    uint8 = 0x4E
+	READ_POWER_SUPPLY   uint8 = 0xB4
 )
 
 type OneWireDevice interface {
@@ -22,9 +23,20 @@ type OneWireDevice interface {
 	Сrc8([]uint8, int) uint8
 }
 
+// powerWriter is implemented by 1-Wire devices that can power the bus with a
+// strong pull-up, such as onewire.Device.
+type powerWriter interface {
+	WritePower(uint8)
+}
+
 // Device wraps a connection to an 1-Wire devices.
 type Device struct {
 	owd OneWireDevice
+
+	// Parasite is set when the sensors are parasite powered, without VDD
+	// pin. The bus is then powered with a strong pull-up during the
+	// temperature conversion, if the 1-Wire device supports it.
+	Parasite bool
 }
 
 // Errors list
@@ -55,9 +67,24 @@ func (d Device) ThermometerResolution(romid []uint8, resolution uint8) {
 // RequestTemperature sends request to device
 func (d Device) RequestTemperature(romid []uint8) {
 	d.owd.Select(romid)
+	if p, ok := d.owd.(powerWriter); ok && d.Parasite {
+		p.WritePower(CONVERT_TEMPERATURE)
+		return
+	}
 	d.owd.Write(CONVERT_TEMPERATURE)
 }
 
+// ParasitePowered returns whether the device is parasite powered. With an
+// empty romid, it returns whether any device on the bus is.
+func (d Device) ParasitePowered(romid []uint8) (bool, error) {
+	if err := d.owd.Select(romid); err != nil {
+		return false, err
+	}
+	d.owd.Write(READ_POWER_SUPPLY)
+	// Parasite powered devices pull the bus low.
+	return d.owd.Read()&1 == 0, nil
+}
+
 // ReadTemperatureRaw returns the raw temperature.
 // ScratchPad memory map:
 // byte 0: Temperature LSB
@@ -0,0 +1,93 @@
+// Package ds2413 provides a driver for the DS2413 dual channel addressable
+// switch, whose two open drain PIO pins can be used as inputs or outputs.
+//
+// Datasheet:
+// https://www.analog.com/media/en/technical-documentation/data-sheets/DS2413.pdf
+package ds2413 // import "tinygo.org/x/drivers/ds2413"
+
+import (
+	"errors"
+)
+
+// Device function commands
+const (
+	PIO_ACCESS_READ  uint8 = 0xF5
+	PIO_ACCESS_WRITE uint8 = 0x5A
+)
+
+// Bits of the pins in the values of Read and Write.
+const (
+	PIOA uint8 = 1 << 0
+	PIOB uint8 = 1 << 1
+)
+
+// confirmation is sent by the device after a valid PIO access write.
+const confirmation = 0xAA
+
+var (
+	ErrStatus = errors.New("ds2413: invalid PIO status")
+	ErrWrite  = errors.New("ds2413: PIO write not confirmed")
+)
+
+type OneWireDevice interface {
+	Write(uint8)
+	Read() uint8
+	Select([]uint8) error
+}
+
+// Device wraps a connection to 1-Wire devices.
+type Device struct {
+	owd OneWireDevice
+}
+
+func New(owd OneWireDevice) Device {
+	return Device{
+		owd: owd,
+	}
+}
+
+// Read returns the levels of the pins and the states of the output latches.
+// A cleared latch bit means that the output transistor is on and pulls the
+// pin low.
+func (d Device) Read(romid []uint8) (pins, latches uint8, err error) {
+	if err := d.owd.Select(romid); err != nil {
+		return 0, 0, err
+	}
+	d.owd.Write(PIO_ACCESS_READ)
+	return parseStatus(d.owd.Read())
+}
+
+// Write sets the output latches. A pin with a set bit is released, so that
+// it can be used as an input.
+func (d Device) Write(romid []uint8, latches uint8) error {
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	data := latches | ^(PIOA | PIOB)
+	d.owd.Write(PIO_ACCESS_WRITE)
+	d.owd.Write(data)
+	d.owd.Write(^data)
+	if d.owd.Read() != confirmation {
+		return ErrWrite
+	}
+	_, got, err := parseStatus(d.owd.Read())
+	if err != nil {
+		return err
+	}
+	if got != latches&(PIOA|PIOB) {
+		return ErrWrite
+	}
+	return nil
+}
+
+// parseStatus checks the status byte, whose upper nibble is the inverse of
+// the lower one: the level of PIOA, its latch, the level of PIOB and its
+// latch.
+func parseStatus(status uint8) (pins, latches uint8, err error) {
+	if status>>4 != ^status&0x0F {
+		return 0, 0, ErrStatus
+	}
+	pins = status&0x01 | status>>1&0x02
+	latches = status>>1&0x01 | status>>2&0x02
+	return pins, latches, nil
+}
@@ -0,0 +1,46 @@
+package ds2413
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/onewire"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestReadWrite(t *testing.T) {
+	c := qt.New(t)
+	sw := tester.NewDS2413(1)
+	d := New(onewire.NewMaster(tester.NewOneWireBus(sw)))
+	rom := sw.ROM()
+
+	pins, latches, err := d.Read(rom[:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(pins, qt.Equals, PIOA|PIOB)
+	c.Assert(latches, qt.Equals, PIOA|PIOB)
+
+	// PIOA is an output and PIOB an input that is pulled low.
+	c.Assert(d.Write(rom[:], PIOB), qt.IsNil)
+	c.Assert(sw.Latches, qt.Equals, PIOB)
+	sw.Inputs = PIOA
+	pins, latches, err = d.Read(rom[:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(pins, qt.Equals, uint8(0))
+	c.Assert(latches, qt.Equals, PIOB)
+
+	sw.Inputs = PIOA | PIOB
+	pins, _, err = d.Read(rom[:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(pins, qt.Equals, PIOB)
+}
+
+func TestParseStatus(t *testing.T) {
+	c := qt.New(t)
+	pins, latches, err := parseStatus(0x3C) // PIOB high and off
+	c.Assert(err, qt.IsNil)
+	c.Assert(pins, qt.Equals, PIOB)
+	c.Assert(latches, qt.Equals, PIOB)
+
+	_, _, err = parseStatus(0xFF)
+	c.Assert(err, qt.Equals, ErrStatus)
+}
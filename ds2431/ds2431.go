@@ -0,0 +1,166 @@
+// Package ds2431 provides a driver for the DS2431 1024-bit 1-Wire EEPROM.
+//
+// Data is written in rows of 8 bytes: to a scratchpad first, which is read
+// back to verify it, and then copied to the memory.
+//
+// Datasheet:
+// https://www.analog.com/media/en/technical-documentation/data-sheets/DS2431.pdf
+package ds2431 // import "tinygo.org/x/drivers/ds2431"
+
+import (
+	"errors"
+	"time"
+)
+
+// Device function commands
+const (
+	WRITE_SCRATCHPAD uint8 = 0x0F
+	READ_SCRATCHPAD  uint8 = 0xAA
+	COPY_SCRATCHPAD  uint8 = 0x55
+	READ_MEMORY      uint8 = 0xF0
+)
+
+const (
+	// MemorySize is the size of the data memory.
+	MemorySize = 0x80
+
+	// RegistersEnd is the end of the registers that follow the data
+	// memory, which can write protect its pages permanently.
+	RegistersEnd = 0x90
+
+	rowSize = 8
+
+	// programTime is the time to copy the scratchpad to the memory.
+	programTime = 10 * time.Millisecond
+
+	// copied is read after a successful copy.
+	copied = 0xAA
+)
+
+var (
+	ErrAddress = errors.New("ds2431: address out of range")
+	ErrCRC     = errors.New("ds2431: CRC mismatch")
+	ErrVerify  = errors.New("ds2431: scratchpad verification failed")
+	ErrCopy    = errors.New("ds2431: copy scratchpad failed")
+)
+
+type OneWireDevice interface {
+	Write(uint8)
+	Read() uint8
+	Select([]uint8) error
+	Crc16([]uint8, uint16) uint16
+}
+
+// Device wraps a connection to 1-Wire devices.
+type Device struct {
+	owd OneWireDevice
+}
+
+func New(owd OneWireDevice) Device {
+	return Device{
+		owd: owd,
+	}
+}
+
+// ReadMemory reads the memory of a device from an address. The memory
+// doesn't have a CRC, so a single device may be read with an empty romid.
+func (d Device) ReadMemory(romid []uint8, addr uint16, buf []byte) error {
+	if int(addr)+len(buf) > RegistersEnd {
+		return ErrAddress
+	}
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(READ_MEMORY)
+	d.owd.Write(uint8(addr))
+	d.owd.Write(uint8(addr >> 8))
+	for i := range buf {
+		buf[i] = d.owd.Read()
+	}
+	return nil
+}
+
+// WriteMemory writes data to the memory of a device from an address. Rows
+// that are written partially are read first.
+func (d Device) WriteMemory(romid []uint8, addr uint16, data []byte) error {
+	if int(addr)+len(data) > RegistersEnd {
+		return ErrAddress
+	}
+	var row [rowSize]byte
+	for len(data) > 0 {
+		start := addr &^ (rowSize - 1)
+		offset := int(addr - start)
+		n := copy(row[offset:], data)
+		if n < rowSize {
+			var old [rowSize]byte
+			if err := d.ReadMemory(romid, start, old[:]); err != nil {
+				return err
+			}
+			copy(row[:offset], old[:offset])
+			copy(row[offset+n:], old[offset+n:])
+		}
+		if err := d.writeRow(romid, start, &row); err != nil {
+			return err
+		}
+		addr += uint16(n)
+		data = data[n:]
+	}
+	return nil
+}
+
+// writeRow writes a row to the scratchpad, verifies it and copies it to the
+// memory.
+func (d Device) writeRow(romid []uint8, addr uint16, row *[rowSize]byte) error {
+	var buf [rowSize + 6]byte
+
+	// Write the scratchpad. The device sends the inverted CRC of the
+	// command, the address and the data.
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	msg := append(append(buf[:0], WRITE_SCRATCHPAD, uint8(addr), uint8(addr>>8)), row[:]...)
+	for _, b := range msg {
+		d.owd.Write(b)
+	}
+	if !d.checkCRC(msg) {
+		return ErrCRC
+	}
+
+	// Read it back: the address, the E/S register, the data and the CRC.
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(READ_SCRATCHPAD)
+	msg = append(buf[:0], READ_SCRATCHPAD)
+	for i := 0; i < rowSize+3; i++ {
+		msg = append(msg, d.owd.Read())
+	}
+	if !d.checkCRC(msg) {
+		return ErrCRC
+	}
+	es := msg[3]
+	if msg[1] != uint8(addr) || msg[2] != uint8(addr>>8) || es != rowSize-1 || string(msg[4:]) != string(row[:]) {
+		return ErrVerify
+	}
+
+	// Copy it with the address and E/S as authorization.
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(COPY_SCRATCHPAD)
+	d.owd.Write(uint8(addr))
+	d.owd.Write(uint8(addr >> 8))
+	d.owd.Write(es)
+	time.Sleep(programTime)
+	if d.owd.Read() != copied {
+		return ErrCopy
+	}
+	return nil
+}
+
+// checkCRC reads the inverted CRC16 of msg and checks it.
+func (d Device) checkCRC(msg []byte) bool {
+	crc := uint16(d.owd.Read())
+	crc |= uint16(d.owd.Read()) << 8
+	return ^crc == d.owd.Crc16(msg, 0)
+}
@@ -0,0 +1,34 @@
+package ds2431
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/onewire"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestReadWrite(t *testing.T) {
+	c := qt.New(t)
+	eeprom := tester.NewDS2431(1)
+	other := tester.NewDS2431(2)
+	d := New(onewire.NewMaster(tester.NewOneWireBus(eeprom, other)))
+	rom := eeprom.ROM()
+
+	// A full row.
+	row := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	c.Assert(d.WriteMemory(rom[:], 0x10, row), qt.IsNil)
+	c.Assert(eeprom.Memory[0x10:0x18], qt.DeepEquals, row)
+	c.Assert(other.Memory[0x10], qt.Equals, byte(0xFF))
+
+	// Across rows, keeping the other bytes.
+	c.Assert(d.WriteMemory(rom[:], 0x16, []byte("hello")), qt.IsNil)
+	c.Assert(eeprom.Memory[0x10:0x1c], qt.DeepEquals, []byte{1, 2, 3, 4, 5, 6, 'h', 'e', 'l', 'l', 'o', 0xFF})
+
+	buf := make([]byte, 6)
+	c.Assert(d.ReadMemory(rom[:], 0x15, buf), qt.IsNil)
+	c.Assert(buf, qt.DeepEquals, []byte{6, 'h', 'e', 'l', 'l', 'o'})
+
+	c.Assert(d.WriteMemory(rom[:], MemorySize-2, make([]byte, 0x20)), qt.Equals, ErrAddress)
+	c.Assert(d.ReadMemory(rom[:], RegistersEnd, buf[:1]), qt.Equals, ErrAddress)
+}
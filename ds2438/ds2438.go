@@ -0,0 +1,192 @@
+// Package ds2438 provides a driver for the DS2438 smart battery monitor,
+// which measures temperature, voltage and current, and has 40 bytes of
+// EEPROM.
+//
+// Datasheet:
+// https://www.analog.com/media/en/technical-documentation/data-sheets/DS2438.pdf
+package ds2438 // import "tinygo.org/x/drivers/ds2438"
+
+import (
+	"errors"
+	"time"
+)
+
+// Device function commands
+const (
+	CONVERT_T        uint8 = 0x44
+	CONVERT_V        uint8 = 0xB4
+	RECALL_MEMORY    uint8 = 0xB8
+	READ_SCRATCHPAD  uint8 = 0xBE
+	WRITE_SCRATCHPAD uint8 = 0x4E
+	COPY_SCRATCHPAD  uint8 = 0x48
+)
+
+// Bits of the status/configuration register, the first byte of page 0.
+const (
+	CONFIG_IAD uint8 = 0x01 // current A/D converter enabled
+	CONFIG_CA  uint8 = 0x02 // current accumulator enabled
+	CONFIG_EE  uint8 = 0x04 // current accumulator shadowed to EEPROM
+	CONFIG_AD  uint8 = 0x08 // voltage A/D input is VDD instead of VAD
+	CONFIG_TB  uint8 = 0x10 // temperature conversion busy
+	CONFIG_NVB uint8 = 0x20 // EEPROM busy
+	CONFIG_ADB uint8 = 0x40 // voltage conversion busy
+)
+
+// Input is an input of the voltage A/D converter.
+type Input uint8
+
+const (
+	// VAD is the general purpose A/D input.
+	VAD Input = iota
+
+	// VDD is the supply voltage, usually the battery.
+	VDD
+)
+
+const (
+	// PageSize is the size of the 8 pages of memory.
+	PageSize = 8
+
+	// conversionTime is the maximum time of a temperature or voltage
+	// conversion.
+	conversionTime = 10 * time.Millisecond
+
+	// programTime is the time to copy the scratchpad to EEPROM.
+	programTime = 10 * time.Millisecond
+)
+
+var (
+	ErrPage = errors.New("ds2438: invalid page")
+	ErrCRC  = errors.New("ds2438: CRC mismatch")
+)
+
+type OneWireDevice interface {
+	Write(uint8)
+	Read() uint8
+	Select([]uint8) error
+	Сrc8([]uint8, int) uint8
+}
+
+// Device wraps a connection to 1-Wire devices.
+type Device struct {
+	owd OneWireDevice
+}
+
+func New(owd OneWireDevice) Device {
+	return Device{
+		owd: owd,
+	}
+}
+
+// ReadTemperature converts and returns the temperature in celsius milli
+// degrees (°C/1000), with a resolution of 0.03125°C.
+func (d Device) ReadTemperature(romid []uint8) (int32, error) {
+	if err := d.convert(romid, CONVERT_T); err != nil {
+		return 0, err
+	}
+	var page [PageSize]byte
+	if err := d.ReadPage(romid, 0, page[:]); err != nil {
+		return 0, err
+	}
+	t := int32(int16(uint16(page[1])|uint16(page[2])<<8) >> 3)
+	return t * 3125 / 100, nil
+}
+
+// ReadVoltage converts and returns the voltage of an input in mV, with a
+// resolution of 10mV.
+func (d Device) ReadVoltage(romid []uint8, input Input) (int32, error) {
+	var page [PageSize]byte
+	if err := d.ReadPage(romid, 0, page[:]); err != nil {
+		return 0, err
+	}
+	config := page[0] &^ CONFIG_AD
+	if input == VDD {
+		config |= CONFIG_AD
+	}
+	if config != page[0] {
+		page[0] = config
+		if err := d.WritePage(romid, 0, page[:1]); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.convert(romid, CONVERT_V); err != nil {
+		return 0, err
+	}
+	if err := d.ReadPage(romid, 0, page[:]); err != nil {
+		return 0, err
+	}
+	v := int32(page[3]) | int32(page[4]&0x03)<<8
+	return v * 10, nil
+}
+
+// ReadCurrent returns the current through a sense resistor in µA, which is
+// positive while the battery is charged. The resistance is in mΩ. The
+// current is measured continuously while CONFIG_IAD is set.
+func (d Device) ReadCurrent(romid []uint8, rsense uint32) (int32, error) {
+	var page [PageSize]byte
+	if err := d.ReadPage(romid, 0, page[:]); err != nil {
+		return 0, err
+	}
+	// The register is 1/(4096·Rsense) A per bit.
+	i := int64(int16(uint16(page[5]) | uint16(page[6])<<8))
+	return int32(i * 1000000000 / (4096 * int64(rsense))), nil
+}
+
+// ReadPage reads a page of memory to buf, which holds up to PageSize bytes.
+func (d Device) ReadPage(romid []uint8, page uint8, buf []byte) error {
+	if page >= 8 {
+		return ErrPage
+	}
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(RECALL_MEMORY)
+	d.owd.Write(page)
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(READ_SCRATCHPAD)
+	d.owd.Write(page)
+	var spb [PageSize + 1]byte // ScratchPad buffer
+	for i := range spb {
+		spb[i] = d.owd.Read()
+	}
+	if d.owd.Сrc8(spb[:], PageSize) != spb[PageSize] {
+		return ErrCRC
+	}
+	copy(buf, spb[:])
+	return nil
+}
+
+// WritePage writes data to a page of memory, from its first byte. Of page
+// 0, only the configuration and the threshold are writable.
+func (d Device) WritePage(romid []uint8, page uint8, data []byte) error {
+	if page >= 8 || len(data) > PageSize {
+		return ErrPage
+	}
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(WRITE_SCRATCHPAD)
+	d.owd.Write(page)
+	for _, b := range data {
+		d.owd.Write(b)
+	}
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(COPY_SCRATCHPAD)
+	d.owd.Write(page)
+	time.Sleep(programTime)
+	return nil
+}
+
+// convert starts a conversion and waits until it is done.
+func (d Device) convert(romid []uint8, cmd uint8) error {
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	d.owd.Write(cmd)
+	time.Sleep(conversionTime)
+	return nil
+}
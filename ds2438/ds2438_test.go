@@ -0,0 +1,57 @@
+package ds2438
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/onewire"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestMeasurements(t *testing.T) {
+	c := qt.New(t)
+	monitor := tester.NewDS2438(1)
+	monitor.Temperature = -5250
+	monitor.VDD = 4870
+	monitor.VAD = 1230
+	monitor.Current = 205
+	d := New(onewire.NewMaster(tester.NewOneWireBus(monitor)))
+	rom := monitor.ROM()
+
+	temp, err := d.ReadTemperature(rom[:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(temp, qt.Equals, int32(-5250))
+
+	v, err := d.ReadVoltage(rom[:], VDD)
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.Equals, int32(4870))
+	v, err = d.ReadVoltage(rom[:], VAD)
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.Equals, int32(1230))
+	c.Assert(monitor.Pages[0][0]&CONFIG_AD, qt.Equals, uint8(0))
+
+	// 205/(4096·0.05Ω) ≈ 1A
+	i, err := d.ReadCurrent(rom[:], 50)
+	c.Assert(err, qt.IsNil)
+	c.Assert(i, qt.Equals, int32(1000976))
+	monitor.Current = -205
+	i, err = d.ReadCurrent(rom[:], 50)
+	c.Assert(err, qt.IsNil)
+	c.Assert(i, qt.Equals, int32(-1000976))
+}
+
+func TestPages(t *testing.T) {
+	c := qt.New(t)
+	monitor := tester.NewDS2438(1)
+	d := New(onewire.NewMaster(tester.NewOneWireBus(monitor)))
+
+	data := []byte("battery1")
+	c.Assert(d.WritePage(nil, 3, data), qt.IsNil)
+	c.Assert(monitor.Pages[3][:], qt.DeepEquals, data)
+	buf := make([]byte, PageSize)
+	c.Assert(d.ReadPage(nil, 3, buf), qt.IsNil)
+	c.Assert(buf, qt.DeepEquals, data)
+
+	c.Assert(d.ReadPage(nil, 8, buf), qt.Equals, ErrPage)
+	c.Assert(d.WritePage(nil, 1, make([]byte, 9)), qt.Equals, ErrPage)
+}
@@ -0,0 +1,259 @@
+// Package ds2482 provides a driver for the DS2482-100 and DS2482-800 I2C to
+// 1-Wire bridges. The bridge generates the 1-Wire time slots in hardware, so
+// they don't depend on the timing of the CPU.
+//
+// The Device implements onewire.Master:
+//
+//	bridge := ds2482.New(machine.I2C0)
+//	bridge.Configure(ds2482.Config{ActivePullUp: true})
+//	ow := onewire.NewMaster(bridge)
+//	sensor := ds18b20.New(ow)
+//
+// Datasheets:
+// https://www.analog.com/media/en/technical-documentation/data-sheets/DS2482-100.pdf
+// https://www.analog.com/media/en/technical-documentation/data-sheets/DS2482-800.pdf
+package ds2482 // import "tinygo.org/x/drivers/ds2482"
+
+import (
+	"errors"
+
+	"tinygo.org/x/drivers"
+)
+
+// Address is the I2C address with the address pins low.
+const Address = 0x18
+
+var (
+	ErrTimeout = errors.New("ds2482: timeout")
+	ErrReset   = errors.New("ds2482: device reset failed")
+	ErrChannel = errors.New("ds2482: invalid channel")
+)
+
+// Commands.
+const (
+	cmdDeviceReset   = 0xF0
+	cmdSetPointer    = 0xE1
+	cmdWriteConfig   = 0xD2
+	cmdChannelSelect = 0xC3
+	cmdWireReset     = 0xB4
+	cmdSingleBit     = 0x87
+	cmdWriteByte     = 0xA5
+	cmdReadByte      = 0x96
+	cmdTriplet       = 0x78
+)
+
+// regData is the read data register, which the read pointer is set to after
+// a read byte command.
+const regData = 0xE1
+
+// Bits of the status register.
+const (
+	status1WB = 0x01 // 1-Wire busy
+	statusPPD = 0x02 // presence pulse detected
+	statusSD  = 0x04 // short detected
+	statusRST = 0x10 // device reset
+	statusSBR = 0x20 // single bit result
+	statusTSB = 0x40 // triplet second bit
+	statusDIR = 0x80 // branch direction taken
+)
+
+// Bits of the configuration register.
+const (
+	configAPU = 0x01 // active pull-up
+	configSPU = 0x04 // strong pull-up
+	config1WS = 0x08 // 1-Wire overdrive speed
+)
+
+// maxPolls is the number of times the status is read while the bridge is
+// busy. A status read takes about 100µs at 100kHz, and a reset 1.2ms.
+const maxPolls = 100
+
+// channels are the codes of the channels of a DS2482-800.
+var channels = [8]byte{0xF0, 0xE1, 0xD2, 0xC3, 0xB4, 0xA5, 0x96, 0x87}
+
+// Device is a DS2482 bridge.
+type Device struct {
+	bus     drivers.I2C
+	Address uint8
+	config  uint8
+	buf     [2]byte
+	err     error
+}
+
+// Config is the configuration of the bridge.
+type Config struct {
+	// ActivePullUp drives the bus high at the end of time slots, which
+	// is recommended with more than one device or long cables.
+	ActivePullUp bool
+}
+
+// New returns a bridge on an I2C bus.
+func New(bus drivers.I2C) *Device {
+	return &Device{
+		bus:     bus,
+		Address: Address,
+	}
+}
+
+// Configure resets the bridge and writes its configuration.
+func (d *Device) Configure(cfg Config) error {
+	d.buf[0] = cmdDeviceReset
+	status, err := d.command(d.buf[:1])
+	if err != nil {
+		return err
+	}
+	if status&statusRST == 0 {
+		return ErrReset
+	}
+	d.config = 0
+	if cfg.ActivePullUp {
+		d.config |= configAPU
+	}
+	return d.writeConfig(d.config)
+}
+
+// SelectChannel selects one of the 8 buses of a DS2482-800.
+func (d *Device) SelectChannel(channel int) error {
+	if channel < 0 || channel >= len(channels) {
+		return ErrChannel
+	}
+	d.buf[0] = cmdChannelSelect
+	d.buf[1] = channels[channel]
+	return d.bus.Tx(uint16(d.Address), d.buf[:2], nil)
+}
+
+// Reset implements onewire.Master. It also returns the first error of the
+// bit and byte operations since the previous reset.
+func (d *Device) Reset() (bool, error) {
+	if err := d.err; err != nil {
+		d.err = nil
+		return false, err
+	}
+	d.buf[0] = cmdWireReset
+	status, err := d.command(d.buf[:1])
+	if err != nil {
+		return false, err
+	}
+	return status&statusPPD != 0 && status&statusSD == 0, nil
+}
+
+// WriteBit implements onewire.Master.
+func (d *Device) WriteBit(bit uint8) {
+	d.singleBit(bit)
+}
+
+// ReadBit implements onewire.Master.
+func (d *Device) ReadBit() uint8 {
+	return d.singleBit(1)
+}
+
+func (d *Device) singleBit(bit uint8) uint8 {
+	d.buf[0] = cmdSingleBit
+	d.buf[1] = bit << 7
+	status, err := d.command(d.buf[:2])
+	if err != nil {
+		d.setErr(err)
+		return 1
+	}
+	if status&statusSBR != 0 {
+		return 1
+	}
+	return 0
+}
+
+// Write implements onewire.Master.
+func (d *Device) Write(data uint8) {
+	d.buf[0] = cmdWriteByte
+	d.buf[1] = data
+	_, err := d.command(d.buf[:2])
+	d.setErr(err)
+}
+
+// WritePower implements onewire.Master. The strong pull-up ends with the next
+// 1-Wire command.
+func (d *Device) WritePower(data uint8) {
+	if err := d.writeConfig(d.config | configSPU); err != nil {
+		d.setErr(err)
+		return
+	}
+	d.Write(data)
+}
+
+// Read implements onewire.Master.
+func (d *Device) Read() uint8 {
+	d.buf[0] = cmdReadByte
+	if _, err := d.command(d.buf[:1]); err != nil {
+		d.setErr(err)
+		return 0xFF
+	}
+	d.buf[0] = cmdSetPointer
+	d.buf[1] = regData
+	if err := d.bus.Tx(uint16(d.Address), d.buf[:2], d.buf[:1]); err != nil {
+		d.setErr(err)
+		return 0xFF
+	}
+	return d.buf[0]
+}
+
+// SetOverdrive implements onewire.Master.
+func (d *Device) SetOverdrive(overdrive bool) {
+	if overdrive {
+		d.config |= config1WS
+	} else {
+		d.config &^= config1WS
+	}
+	d.setErr(d.writeConfig(d.config))
+}
+
+// Triplet does the two read slots and the write slot of a step of a ROM
+// search, which onewire.Device.Search uses.
+func (d *Device) Triplet(direction uint8) (bit, complement, taken uint8) {
+	d.buf[0] = cmdTriplet
+	d.buf[1] = direction << 7
+	status, err := d.command(d.buf[:2])
+	if err != nil {
+		d.setErr(err)
+		return 1, 1, 1
+	}
+	if status&statusSBR != 0 {
+		bit = 1
+	}
+	if status&statusTSB != 0 {
+		complement = 1
+	}
+	if status&statusDIR != 0 {
+		taken = 1
+	}
+	return bit, complement, taken
+}
+
+// writeConfig writes the configuration register, whose upper nibble must be
+// the complement of the lower one.
+func (d *Device) writeConfig(config uint8) error {
+	d.buf[0] = cmdWriteConfig
+	d.buf[1] = config | ^config<<4
+	return d.bus.Tx(uint16(d.Address), d.buf[:2], nil)
+}
+
+// command sends a command with an optional parameter, waits until the bridge
+// is done and returns the status register.
+func (d *Device) command(w []byte) (uint8, error) {
+	if err := d.bus.Tx(uint16(d.Address), w, d.buf[:1]); err != nil {
+		return 0, err
+	}
+	for i := 0; d.buf[0]&status1WB != 0; i++ {
+		if i == maxPolls {
+			return 0, ErrTimeout
+		}
+		if err := d.bus.Tx(uint16(d.Address), nil, d.buf[:1]); err != nil {
+			return 0, err
+		}
+	}
+	return d.buf[0], nil
+}
+
+func (d *Device) setErr(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
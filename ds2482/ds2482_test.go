@@ -0,0 +1,106 @@
+package ds2482
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/ds18b20"
+	"tinygo.org/x/drivers/onewire"
+	"tinygo.org/x/drivers/tester"
+)
+
+func newBridge(c *qt.C, buses ...*tester.OneWireBus) (*Device, *tester.DS2482) {
+	bus := tester.NewI2CBus(c)
+	fake := tester.NewDS2482(Address, buses...)
+	bus.AddDevice(fake)
+	d := New(bus)
+	c.Assert(d.Configure(Config{ActivePullUp: true}), qt.IsNil)
+	return d, fake
+}
+
+func TestSearch(t *testing.T) {
+	c := qt.New(t)
+	sensor := tester.NewDS18B20(0x42)
+	d, fake := newBridge(c, tester.NewOneWireBus(sensor))
+	ow := onewire.NewMaster(d)
+
+	ids, err := ow.Search(onewire.SEARCH_ROM)
+	c.Assert(err, qt.IsNil)
+	rom := sensor.ROM()
+	c.Assert(ids, qt.DeepEquals, [][]uint8{rom[:]})
+	// A reset, the search command and a triplet for each bit.
+	c.Assert(fake.Commands, qt.Equals, 1+1+64)
+
+	// Two devices.
+	d, _ = newBridge(c, tester.NewOneWireBus(sensor, tester.NewDS2431(0x43)))
+	ids, err = onewire.NewMaster(d).Search(onewire.SEARCH_ROM)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.HasLen, 2)
+
+	// No devices.
+	d, _ = newBridge(c, tester.NewOneWireBus())
+	_, err = onewire.NewMaster(d).Search(onewire.SEARCH_ROM)
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestTemperature(t *testing.T) {
+	c := qt.New(t)
+	sensor := tester.NewDS18B20(1)
+	sensor.Temperature = -10125
+	sensor.Parasite = true
+	bus := tester.NewOneWireBus(sensor)
+	d, _ := newBridge(c, bus)
+	ow := onewire.NewMaster(d)
+
+	rom := sensor.ROM()
+	thermometer := ds18b20.New(ow)
+	parasite, err := thermometer.ParasitePowered(rom[:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(parasite, qt.IsTrue)
+
+	thermometer.Parasite = true
+	thermometer.RequestTemperature(rom[:])
+	c.Assert(bus.StrongPullUps, qt.Equals, 1)
+	temp, err := thermometer.ReadTemperature(rom[:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(temp, qt.Equals, int32(-10125))
+}
+
+func TestOverdrive(t *testing.T) {
+	c := qt.New(t)
+	eeprom := tester.NewDS2431(1)
+	bus := tester.NewOneWireBus(eeprom)
+	d, _ := newBridge(c, bus)
+	ow := onewire.NewMaster(d)
+
+	rom := eeprom.ROM()
+	c.Assert(ow.Overdrive(rom[:]), qt.IsNil)
+	c.Assert(bus.Overdrive, qt.IsTrue)
+	id, err := ow.ReadAddress()
+	c.Assert(err, qt.IsNil)
+	c.Assert(id, qt.DeepEquals, rom[:])
+
+	c.Assert(ow.StandardSpeed(), qt.IsNil)
+	c.Assert(bus.Overdrive, qt.IsFalse)
+}
+
+func TestSelectChannel(t *testing.T) {
+	c := qt.New(t)
+	buses := make([]*tester.OneWireBus, 8)
+	for i := range buses {
+		buses[i] = tester.NewOneWireBus()
+	}
+	sensor := tester.NewDS18B20(5)
+	buses[5].Add(sensor)
+	d, _ := newBridge(c, buses...)
+	ow := onewire.NewMaster(d)
+
+	c.Assert(ow.Reset(), qt.Not(qt.IsNil))
+	c.Assert(d.SelectChannel(5), qt.IsNil)
+	id, err := ow.ReadAddress()
+	c.Assert(err, qt.IsNil)
+	rom := sensor.ROM()
+	c.Assert(id, qt.DeepEquals, rom[:])
+
+	c.Assert(d.SelectChannel(8), qt.Equals, ErrChannel)
+}
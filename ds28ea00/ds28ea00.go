@@ -0,0 +1,117 @@
+// Package ds28ea00 provides a driver for the chain mode of the DS28EA00
+// digital thermometer, which finds the physical order of the devices on a
+// bus. The devices are connected in a chain, from the PIOB pin of a device
+// to the PIOA pin of the next one, and the PIOA pin of the first device is
+// connected to ground.
+//
+// The thermometer works as a DS18B20, with the ds18b20 package.
+//
+// Datasheet:
+// https://www.analog.com/media/en/technical-documentation/data-sheets/DS28EA00.pdf
+package ds28ea00 // import "tinygo.org/x/drivers/ds28ea00"
+
+import (
+	"errors"
+
+	"tinygo.org/x/drivers/onewire"
+)
+
+// Device function commands
+const (
+	CHAIN uint8 = 0x99
+)
+
+// Control bytes of the chain command.
+const (
+	CHAIN_OFF  uint8 = 0x3C
+	CHAIN_ON   uint8 = 0x5A
+	CHAIN_DONE uint8 = 0x96
+)
+
+// confirmation is sent by the devices after a valid chain command.
+const confirmation = 0xAA
+
+// maxDevices limits the length of a chain, in case a device doesn't leave
+// the sequence.
+const maxDevices = 256
+
+var (
+	ErrChain = errors.New("ds28ea00: chain command not confirmed")
+	ErrCRC   = errors.New("ds28ea00: ROM CRC mismatch")
+)
+
+type OneWireDevice interface {
+	Reset() error
+	Write(uint8)
+	Read() uint8
+	Select([]uint8) error
+	Сrc8([]uint8, int) uint8
+}
+
+// Device wraps a connection to 1-Wire devices.
+type Device struct {
+	owd OneWireDevice
+}
+
+func New(owd OneWireDevice) Device {
+	return Device{
+		owd: owd,
+	}
+}
+
+// Sequence returns the ROM IDs of the devices of the chain, in their
+// physical order.
+func (d Device) Sequence() ([][]uint8, error) {
+	if err := d.chain(nil, CHAIN_ON); err != nil {
+		return nil, err
+	}
+	var romIDs [][]uint8
+	for len(romIDs) < maxDevices {
+		// Only the device whose PIOA is low and that isn't done yet
+		// answers.
+		if err := d.owd.Reset(); err != nil {
+			return nil, err
+		}
+		d.owd.Write(onewire.CONDITIONAL_READ_ROM)
+		romid := make([]uint8, 8)
+		none := true
+		for i := range romid {
+			romid[i] = d.owd.Read()
+			none = none && romid[i] == 0xFF
+		}
+		if none {
+			break
+		}
+		if d.owd.Сrc8(romid, 7) != romid[7] {
+			return nil, ErrCRC
+		}
+		// The device is selected by the conditional read ROM.
+		if err := d.control(CHAIN_DONE); err != nil {
+			return nil, err
+		}
+		romIDs = append(romIDs, romid)
+	}
+	if err := d.chain(nil, CHAIN_OFF); err != nil {
+		return nil, err
+	}
+	return romIDs, nil
+}
+
+// chain sends a chain command to a device, or to all devices with an empty
+// romid.
+func (d Device) chain(romid []uint8, ctrl uint8) error {
+	if err := d.owd.Select(romid); err != nil {
+		return err
+	}
+	return d.control(ctrl)
+}
+
+func (d Device) control(ctrl uint8) error {
+	d.owd.Write(CHAIN)
+	d.owd.Write(ctrl)
+	d.owd.Write(^ctrl)
+	if d.owd.Read() != confirmation {
+		return ErrChain
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package ds28ea00
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/ds18b20"
+	"tinygo.org/x/drivers/onewire"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestSequence(t *testing.T) {
+	c := qt.New(t)
+	// The serial numbers are in a different order than the chain.
+	first := tester.NewDS28EA00(0x30, nil)
+	second := tester.NewDS28EA00(0x10, first)
+	third := tester.NewDS28EA00(0x20, second)
+	ow := onewire.NewMaster(tester.NewOneWireBus(third, first, tester.NewDS18B20(1), second))
+
+	romIDs, err := New(ow).Sequence()
+	c.Assert(err, qt.IsNil)
+	var want [][]uint8
+	for _, dev := range []*tester.DS28EA00{first, second, third} {
+		rom := dev.ROM()
+		want = append(want, rom[:])
+	}
+	c.Assert(romIDs, qt.DeepEquals, want)
+
+	// The chain is off, and the sequence can be detected again.
+	romIDs, err = New(ow).Sequence()
+	c.Assert(err, qt.IsNil)
+	c.Assert(romIDs, qt.DeepEquals, want)
+
+	// The thermometers work as DS18B20.
+	second.Temperature = 23125
+	sensor := ds18b20.New(ow)
+	sensor.RequestTemperature(want[1])
+	temp, err := sensor.ReadTemperature(want[1])
+	c.Assert(err, qt.IsNil)
+	c.Assert(temp, qt.Equals, int32(23125))
+}
+
+func TestNoChain(t *testing.T) {
+	c := qt.New(t)
+	ow := onewire.NewMaster(tester.NewOneWireBus(tester.NewDS18B20(1)))
+	_, err := New(ow).Sequence()
+	c.Assert(err, qt.Equals, ErrChain)
+}
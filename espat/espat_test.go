@@ -0,0 +1,68 @@
+package espat
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestConnected(t *testing.T) {
+	c := qt.New(t)
+	uart := tester.NewUART(c)
+	uart.Expect("AT\r\n", "AT\r\n\r\nOK\r\n", 0)
+	d := New(uart)
+	c.Assert(d.Connected(), qt.IsTrue)
+	uart.Done()
+
+	// No reply.
+	uart = tester.NewUART(c)
+	d = New(uart)
+	c.Assert(d.Connected(), qt.IsFalse)
+	c.Assert(string(uart.Written), qt.Equals, "AT\r\n")
+}
+
+func TestConnectToAccessPoint(t *testing.T) {
+	c := qt.New(t)
+	uart := tester.NewUART(c)
+	uart.Expect("AT+CWMODE=1\r\n", "\r\nOK\r\n", 0)
+	uart.Expect("AT+CWJAP=\"ssid\",\"secret\"\r\n", "WIFI CONNECTED\r\nWIFI GOT IP\r\n\r\nOK\r\n", 250*time.Millisecond)
+	d := New(uart)
+	c.Assert(d.ConnectToAccessPoint("ssid", "secret", 2*time.Second), qt.IsNil)
+	uart.Done()
+
+	uart = tester.NewUART(c)
+	uart.Expect("AT+CWMODE=1\r\n", "\r\nOK\r\n", 0)
+	uart.Expect("AT+CWJAP=\"ssid\",\"wrong\"\r\n", "+CWJAP:2\r\n\r\nFAIL\r\nERROR\r\n", 100*time.Millisecond)
+	d = New(uart)
+	c.Assert(d.ConnectToAccessPoint("ssid", "wrong", time.Second), qt.ErrorMatches, `(?s)response error:.*FAIL.*`)
+	uart.Done()
+}
+
+func TestGetDNS(t *testing.T) {
+	c := qt.New(t)
+	uart := tester.NewUART(c)
+	uart.Expect("AT+CIPDOMAIN=\"tinygo.org\"\r\n", "+CIPDOMAIN:\"185.199.108.153\"\r\n\r\nOK\r\n", 150*time.Millisecond)
+	d := New(uart)
+	ip, err := d.GetDNS("tinygo.org")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ip, qt.Equals, "185.199.108.153")
+	uart.Done()
+}
+
+func TestReadSocket(t *testing.T) {
+	c := qt.New(t)
+	uart := tester.NewUART(c)
+	d := New(uart)
+	uart.Inject([]byte("\r\n+IPD,5:hello"))
+	c.Assert(d.IsSocketDataAvailable(), qt.IsTrue)
+	buf := make([]byte, 3)
+	n, err := d.ReadSocket(buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[:n]), qt.Equals, "hel")
+	n, err = d.ReadSocket(buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[:n]), qt.Equals, "lo")
+	c.Assert(d.IsSocketDataAvailable(), qt.IsFalse)
+}
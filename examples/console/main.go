@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"tinygo.org/x/drivers/console"
+	"tinygo.org/x/drivers/examples/ili9341/initdisplay"
+	"tinygo.org/x/drivers/ili9341"
+)
+
+func main() {
+	display := initdisplay.InitDisplay()
+
+	// Hardware scrolling only works in portrait orientation.
+	display.SetRotation(ili9341.Rotation0)
+
+	con := console.New(display)
+	con.Configure(console.Config{HardwareScroll: true})
+
+	fmt.Fprintln(con, "\x1b[1;33mTinyGo console\x1b[0m")
+	for i := 0; ; i++ {
+		color := 32 // green
+		if i%10 == 0 {
+			color = 31 // red
+		}
+		fmt.Fprintf(con, "\x1b[%dm%5d\x1b[0m %s\n", color, i, time.Now().Format("15:04:05.000"))
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/ds2413"
+	"tinygo.org/x/drivers/onewire"
+)
+
+func main() {
+	ow := onewire.New(machine.D2)
+	sw := ds2413.New(ow)
+
+	// PIOA drives an LED, and PIOB reads a button to ground.
+	on := false
+	for {
+		on = !on
+		latches := ds2413.PIOB
+		if !on {
+			latches |= ds2413.PIOA
+		}
+		if err := sw.Write(nil, latches); err != nil {
+			println(err.Error())
+		}
+		pins, _, err := sw.Read(nil)
+		if err != nil {
+			println(err.Error())
+		}
+		println("button pressed:", pins&ds2413.PIOB == 0)
+		time.Sleep(500 * time.Millisecond)
+	}
+}
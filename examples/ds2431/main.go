@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/hex"
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/ds2431"
+	"tinygo.org/x/drivers/onewire"
+)
+
+func main() {
+	ow := onewire.New(machine.D2)
+	eeprom := ds2431.New(ow)
+
+	// A single device on the bus is selected with an empty ROM ID.
+	if err := eeprom.WriteMemory(nil, 0, []byte("TinyGo")); err != nil {
+		println(err.Error())
+	}
+
+	buf := make([]byte, 16)
+	for {
+		if err := eeprom.ReadMemory(nil, 0, buf); err != nil {
+			println(err.Error())
+		}
+		println(hex.EncodeToString(buf))
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/ds2438"
+	"tinygo.org/x/drivers/onewire"
+)
+
+func main() {
+	ow := onewire.New(machine.D2)
+	monitor := ds2438.New(ow)
+
+	for {
+		t, err := monitor.ReadTemperature(nil)
+		if err != nil {
+			println(err.Error())
+		}
+		v, err := monitor.ReadVoltage(nil, ds2438.VDD)
+		if err != nil {
+			println(err.Error())
+		}
+		// 50mΩ sense resistor
+		i, err := monitor.ReadCurrent(nil, 50)
+		if err != nil {
+			println(err.Error())
+		}
+		println("temperature (m°C):", t, "voltage (mV):", v, "current (µA):", i)
+		time.Sleep(time.Second)
+	}
+}
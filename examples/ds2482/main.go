@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/hex"
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/ds18b20"
+	"tinygo.org/x/drivers/ds2482"
+	"tinygo.org/x/drivers/onewire"
+)
+
+func main() {
+	machine.I2C0.Configure(machine.I2CConfig{})
+
+	bridge := ds2482.New(machine.I2C0)
+	if err := bridge.Configure(ds2482.Config{ActivePullUp: true}); err != nil {
+		println(err.Error())
+	}
+	ow := onewire.NewMaster(bridge)
+	romIDs, err := ow.Search(onewire.SEARCH_ROM)
+	if err != nil {
+		println(err.Error())
+	}
+	sensor := ds18b20.New(ow)
+	sensor.Parasite, _ = sensor.ParasitePowered(nil)
+
+	for {
+		for _, romid := range romIDs {
+			sensor.RequestTemperature(romid)
+		}
+
+		// wait 750ms or more for DS18B20 convert T
+		time.Sleep(1 * time.Second)
+
+		for _, romid := range romIDs {
+			t, err := sensor.ReadTemperature(romid)
+			if err != nil {
+				println(err.Error())
+				continue
+			}
+			println(hex.EncodeToString(romid), t)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
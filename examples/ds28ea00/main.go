@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/hex"
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/ds18b20"
+	"tinygo.org/x/drivers/ds28ea00"
+	"tinygo.org/x/drivers/onewire"
+)
+
+func main() {
+	ow := onewire.New(machine.D2)
+
+	// The ROM IDs in the order of the devices along the chain.
+	romIDs, err := ds28ea00.New(ow).Sequence()
+	if err != nil {
+		println(err.Error())
+	}
+	sensor := ds18b20.New(ow)
+
+	for {
+		sensor.RequestTemperature(nil)
+		time.Sleep(time.Second)
+		for i, romid := range romIDs {
+			t, err := sensor.ReadTemperature(romid)
+			if err != nil {
+				println(err.Error())
+				continue
+			}
+			println(i, hex.EncodeToString(romid), t)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/gps"
+)
+
+func main() {
+	println("GPS streaming Example")
+	machine.UART1.Configure(machine.UARTConfig{BaudRate: 9600})
+	ublox := gps.NewUART(machine.UART1)
+
+	var fix gps.Fix
+	var updated bool
+	stream := gps.NewStream(func(s *gps.Sentence) {
+		if s.Fix(&fix) == nil {
+			updated = true
+		}
+	})
+
+	for {
+		// Poll never blocks, so the rest of the loop keeps running at a
+		// steady rate.
+		ublox.Poll(&stream)
+		if updated && fix.Valid {
+			updated = false
+			print(fix.Time.Format("15:04:05"))
+			print(", lat=")
+			print(fix.Latitude)
+			print(", long=")
+			print(fix.Longitude)
+			print(", hdop=")
+			print(fix.HDOP)
+			println()
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"machine"
 
+	"tinygo.org/x/drivers"
 	"tinygo.org/x/drivers/hd44780"
 )
 
 func main() {
 
 	lcd, _ := hd44780.NewGPIO4Bit(
-		[]machine.Pin{machine.P0, machine.P1, machine.P2, machine.P3},
+		[]drivers.Pin{machine.P0, machine.P1, machine.P2, machine.P3},
 		machine.P4,
 		machine.P5,
 		machine.P6,
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/bme280"
+	"tinygo.org/x/drivers/i2cbus"
+)
+
+// The transactions of a BME280 are logged to the serial port, where they can
+// be decoded with the i2ctrace command, and those that fail are retried.
+func main() {
+	machine.I2C0.Configure(machine.I2CConfig{})
+	trace := i2cbus.NewTrace(machine.I2C0, machine.Serial)
+	retry := i2cbus.NewRetry(trace, i2cbus.RetryPolicy{Delay: time.Millisecond})
+	stats := i2cbus.NewStats(retry)
+
+	sensor := bme280.New(stats)
+	sensor.Configure()
+
+	for {
+		temp, err := sensor.ReadTemperature()
+		if err == nil {
+			println("Temperature:", temp/1000, "°C")
+		}
+		for _, addr := range stats.Addresses() {
+			st := stats.Get(addr)
+			println("address", addr, "transactions", st.Transactions, "errors", st.Errors)
+		}
+		println("retries", retry.Retries)
+		time.Sleep(2 * time.Second)
+	}
+}
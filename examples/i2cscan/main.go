@@ -0,0 +1,29 @@
+package main
+
+import (
+	"machine"
+	"strconv"
+	"time"
+
+	"tinygo.org/x/drivers/i2cscan"
+)
+
+func main() {
+	machine.I2C0.Configure(machine.I2CConfig{})
+
+	for {
+		devices := i2cscan.Detect(machine.I2C0)
+		println(len(devices), "devices found")
+		for _, d := range devices {
+			line := "0x" + strconv.FormatUint(uint64(d.Address), 16) + ":"
+			if len(d.Candidates) == 0 {
+				line += " unknown"
+			}
+			for _, name := range d.Candidates {
+				line += " " + name
+			}
+			println(line)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -9,8 +9,9 @@ import (
 )
 
 var (
-	spi   = machine.SPI0
-	csPin = machine.D5
+	spi    = machine.SPI0
+	csPin  = machine.D5
+	intPin = machine.D6
 )
 
 func main() {
@@ -27,6 +28,18 @@ func main() {
 		failMessage(err.Error())
 	}
 
+	// Move the received frames into the queue of the driver as soon as the
+	// controller signals them, as it only has room for two.
+	intPin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	go func() {
+		for {
+			for !intPin.Get() {
+				can.HandleInterrupt()
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
 	for {
 		err := can.Tx(0x111, 8, []byte{0x00, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA})
 		if err != nil {
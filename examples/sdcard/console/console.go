@@ -210,11 +210,7 @@ func dbg(argv []string) {
 
 func lsblk(argv []string) {
 	csd := dev.CSD
-	sectors, err := csd.Sectors()
-	if err != nil {
-		fmt.Printf("%s\r\n", err.Error())
-		return
-	}
+	sectors := dev.Size() / 512
 	cid := dev.CID
 
 	fmt.Printf(
@@ -257,7 +253,7 @@ func lsblk(argv []string) {
 		false,                   // attrs.WriteStatusSplit,
 		false,                   // attrs.SingleStatusByte,
 		sectors,
-		csd.Size(),
+		dev.Size(),
 		cid.ManufacturerID,
 		cid.OEMApplicationID,
 		cid.ProductName,
@@ -23,8 +23,8 @@ func main() {
 	led := ledPin
 	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
 
-	sd := sdcard.New(spi, sckPin, sdoPin, sdiPin, csPin)
-	err := sd.Configure()
+	sd := sdcard.NewSPI(spi, sckPin, sdoPin, sdiPin, csPin)
+	err := sd.Configure(sdcard.Config{})
 	if err != nil {
 		fmt.Printf("%s\r\n", err.Error())
 		for {
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"machine"
+	"time"
+
+	"tinygo.org/x/drivers/tca9548a"
+	"tinygo.org/x/drivers/vl53l1x"
+)
+
+// Two VL53L1X sensors, which have the same address, on channels 0 and 1 of
+// the multiplexer. Each is read by its own goroutine.
+func main() {
+	machine.I2C0.Configure(machine.I2CConfig{
+		Frequency: 400000,
+	})
+	mux := tca9548a.New(machine.I2C0)
+	if !mux.Connected() {
+		println("TCA9548A device not found")
+		return
+	}
+	mux.Configure()
+
+	for i := 0; i < 2; i++ {
+		go measure(i, vl53l1x.New(mux.Channel(i)))
+	}
+	select {}
+}
+
+func measure(channel int, sensor vl53l1x.Device) {
+	if !sensor.Connected() {
+		println("VL53L1X device not found on channel", channel)
+		return
+	}
+	sensor.Configure(true)
+	sensor.SetMeasurementTimingBudget(50000)
+	sensor.StartContinuous(50)
+	for {
+		sensor.Read(true)
+		println("Channel", channel, "distance (mm):", sensor.Distance())
+		time.Sleep(100 * time.Millisecond)
+	}
+}
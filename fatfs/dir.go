@@ -0,0 +1,570 @@
+package fatfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	entrySize = 32
+
+	entryFree    = 0xe5 // first name byte of a deleted entry
+	entryEnd     = 0x00 // first name byte of the entry after the last one
+	lfnLast      = 0x40 // flag in the order byte of the last long name entry
+	lfnChars     = 13   // UCS-2 characters per long name entry
+	maxNameLen   = 255
+	ntLowerBase  = 0x08 // NT flag: base of the short name is lower case
+	ntLowerExt   = 0x10 // NT flag: extension of the short name is lower case
+	maxDirLookup = 1 << 16
+)
+
+var errEndOfDir = errors.New("fatfs: end of directory")
+
+// dirEntry is a parsed directory entry together with its location.
+type dirEntry struct {
+	name    string // long name if present, otherwise the short name
+	short   [11]byte
+	ntFlags uint8
+	attr    uint8
+	cluster uint32
+	size    uint32
+	modTime time.Time
+
+	dir   uint32 // first cluster of the parent directory, 0 for a fixed root
+	first int    // index of the first (long name) entry in the directory
+	index int    // index of the short entry in the directory
+	addr  int64  // device address of the short entry, 0 for the root
+}
+
+func (e *dirEntry) isDir() bool {
+	return e.attr&attrDirectory != 0
+}
+
+func (e *dirEntry) isRoot() bool {
+	return e.addr == 0
+}
+
+func (e *dirEntry) decode(b []byte) {
+	copy(e.short[:], b[:11])
+	if e.short[0] == 0x05 {
+		e.short[0] = entryFree
+	}
+	e.attr = b[11]
+	e.ntFlags = b[12]
+	le := binary.LittleEndian
+	e.cluster = uint32(le.Uint16(b[20:]))<<16 | uint32(le.Uint16(b[26:]))
+	e.size = le.Uint32(b[28:])
+	e.modTime = decodeTime(le.Uint16(b[24:]), le.Uint16(b[22:]))
+}
+
+func (e *dirEntry) encode(b []byte) {
+	copy(b[:11], e.short[:])
+	if b[0] == entryFree {
+		b[0] = 0x05
+	}
+	b[11] = e.attr
+	b[12] = e.ntFlags
+	b[13] = 0
+	date, tm := encodeTime(e.modTime)
+	le := binary.LittleEndian
+	le.PutUint16(b[14:], tm)   // creation time
+	le.PutUint16(b[16:], date) // creation date
+	le.PutUint16(b[18:], date) // access date
+	le.PutUint16(b[20:], uint16(e.cluster>>16))
+	le.PutUint16(b[22:], tm)
+	le.PutUint16(b[24:], date)
+	le.PutUint16(b[26:], uint16(e.cluster))
+	le.PutUint32(b[28:], e.size)
+}
+
+// shortName returns the short name in its usual dotted form.
+func (e *dirEntry) shortName() string {
+	base := strings.TrimRight(string(e.short[:8]), " ")
+	ext := strings.TrimRight(string(e.short[8:]), " ")
+	if e.ntFlags&ntLowerBase != 0 {
+		base = strings.ToLower(base)
+	}
+	if e.ntFlags&ntLowerExt != 0 {
+		ext = strings.ToLower(ext)
+	}
+	if ext == "" {
+		return base
+	}
+	return base + "." + ext
+}
+
+func decodeTime(date, tm uint16) time.Time {
+	if date == 0 {
+		return time.Time{}
+	}
+	return time.Date(1980+int(date>>9), time.Month(date>>5&0x0f), int(date&0x1f),
+		int(tm>>11), int(tm>>5&0x3f), int(tm&0x1f)*2, 0, time.UTC)
+}
+
+func encodeTime(t time.Time) (date, tm uint16) {
+	if t.Year() < 1980 {
+		return 0x21, 0 // 1980-01-01
+	}
+	if t.Year() > 2107 {
+		t = time.Date(2107, 12, 31, 23, 59, 58, 0, time.UTC)
+	}
+	date = uint16(t.Year()-1980)<<9 | uint16(t.Month())<<5 | uint16(t.Day())
+	tm = uint16(t.Hour())<<11 | uint16(t.Minute())<<5 | uint16(t.Second()/2)
+	return
+}
+
+// dirReader maps entry indices of a directory to device addresses.
+type dirReader struct {
+	fsys       *FS
+	start      uint32 // first cluster, 0 for a fixed root directory
+	cluster    uint32 // cluster number clusterIdx in the chain
+	clusterIdx int
+}
+
+func (fsys *FS) dirReader(start uint32) *dirReader {
+	return &dirReader{fsys: fsys, start: start, cluster: start}
+}
+
+// addr returns the device address of entry idx. If the directory is too
+// short and extend is set, a cluster is added to it.
+func (d *dirReader) addr(idx int, extend bool) (int64, error) {
+	fsys := d.fsys
+	if d.start == 0 {
+		if idx >= fsys.rootEntries {
+			if extend {
+				return 0, ErrNoSpace
+			}
+			return 0, errEndOfDir
+		}
+		return fsys.rootStart + int64(idx)*entrySize, nil
+	}
+	perCluster := int(fsys.clusterSize / entrySize)
+	ci := idx / perCluster
+	if ci < d.clusterIdx {
+		d.cluster, d.clusterIdx = d.start, 0
+	}
+	for d.clusterIdx < ci {
+		next, err := fsys.nextCluster(d.cluster)
+		if err != nil {
+			return 0, err
+		}
+		if next == 0 {
+			if !extend {
+				return 0, errEndOfDir
+			}
+			next, err = fsys.allocCluster(d.cluster, true)
+			if err != nil {
+				return 0, err
+			}
+		}
+		d.cluster = next
+		d.clusterIdx++
+		if d.clusterIdx > maxDirLookup {
+			return 0, ErrCorrupt
+		}
+	}
+	return fsys.clusterAddr(d.cluster) + int64(idx%perCluster)*entrySize, nil
+}
+
+// readEntries calls fn for each entry in the directory starting at cluster
+// start, beginning at entry index idx. It stops when fn returns false and
+// returns the index of the entry after the last one passed to fn. The "."
+// and ".." entries as well as volume labels are skipped.
+func (fsys *FS) readEntries(start uint32, idx int, fn func(e *dirEntry) bool) (int, error) {
+	d := fsys.dirReader(start)
+	var buf [entrySize]byte
+	var lfn [20 * lfnChars]uint16
+	lfnCount, lfnSum, lfnFirst := 0, byte(0), 0
+	for ; ; idx++ {
+		addr, err := d.addr(idx, false)
+		if err == errEndOfDir {
+			return idx, nil
+		}
+		if err != nil {
+			return idx, err
+		}
+		if _, err := fsys.dev.ReadAt(buf[:], addr); err != nil {
+			return idx, err
+		}
+		switch {
+		case buf[0] == entryEnd:
+			return idx, nil
+		case buf[0] == entryFree:
+			lfnCount = 0
+			continue
+		case buf[11]&0x3f == attrLongName:
+			ord := int(buf[0] & 0x1f)
+			if buf[0]&lfnLast != 0 {
+				if ord == 0 || ord > 20 {
+					lfnCount = 0
+					continue
+				}
+				lfnCount, lfnSum, lfnFirst = ord, buf[13], idx
+				for i := range lfn[:ord*lfnChars] {
+					lfn[i] = 0xffff
+				}
+			} else if lfnCount == 0 || buf[13] != lfnSum || idx-lfnFirst != lfnCount-ord {
+				lfnCount = 0
+				continue
+			}
+			chars := lfn[(ord-1)*lfnChars:]
+			for i, off := range lfnOffsets {
+				chars[i] = binary.LittleEndian.Uint16(buf[off:])
+			}
+			continue
+		}
+		if buf[11]&attrVolumeID != 0 || buf[0] == '.' {
+			lfnCount = 0
+			continue
+		}
+		e := dirEntry{dir: start, index: idx, first: idx, addr: addr}
+		e.decode(buf[:])
+		e.name = e.shortName()
+		if lfnCount != 0 && idx-lfnFirst == lfnCount && checksum(e.short[:]) == lfnSum {
+			n := 0
+			for n < lfnCount*lfnChars && lfn[n] != 0 && lfn[n] != 0xffff {
+				n++
+			}
+			e.name = string(utf16.Decode(lfn[:n]))
+			e.first = lfnFirst
+		}
+		lfnCount = 0
+		if !fn(&e) {
+			return idx + 1, nil
+		}
+	}
+}
+
+// Byte offsets of the name characters in a long name entry.
+var lfnOffsets = [lfnChars]int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30}
+
+func checksum(short []byte) byte {
+	var sum byte
+	for _, c := range short[:11] {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+	return sum
+}
+
+// walkDir calls fn for each entry in the directory until fn returns false.
+func (fsys *FS) walkDir(start uint32, fn func(e *dirEntry) bool) error {
+	_, err := fsys.readEntries(start, 0, fn)
+	return err
+}
+
+// findEntry looks up name in a single directory.
+func (fsys *FS) findEntry(dir uint32, name string) (dirEntry, error) {
+	var found dirEntry
+	ok := false
+	err := fsys.walkDir(dir, func(e *dirEntry) bool {
+		if strings.EqualFold(e.name, name) || strings.EqualFold(e.shortName(), name) {
+			found, ok = *e, true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return dirEntry{}, err
+	}
+	if !ok {
+		return dirEntry{}, fs.ErrNotExist
+	}
+	return found, nil
+}
+
+func (fsys *FS) root() dirEntry {
+	e := dirEntry{name: ".", attr: attrDirectory}
+	if fsys.typ == FAT32 {
+		e.cluster = fsys.rootCluster
+	}
+	return e
+}
+
+// lookup returns the entry of the named file, which must be a valid path.
+func (fsys *FS) lookup(name string) (dirEntry, error) {
+	e := fsys.root()
+	if name == "." {
+		return e, nil
+	}
+	for {
+		elem := name
+		i := strings.IndexByte(name, '/')
+		if i >= 0 {
+			elem, name = name[:i], name[i+1:]
+		}
+		if !e.isDir() {
+			return dirEntry{}, ErrNotDir
+		}
+		var err error
+		e, err = fsys.findEntry(e.cluster, elem)
+		if err != nil {
+			return dirEntry{}, err
+		}
+		if i < 0 {
+			return e, nil
+		}
+	}
+}
+
+// lookupParent returns the directory that contains the named file, and the
+// base name of the file.
+func (fsys *FS) lookupParent(name string) (dirEntry, string, error) {
+	dir, base := parentPath(name), name
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		base = name[i+1:]
+	}
+	parent, err := fsys.lookup(dir)
+	if err != nil {
+		return dirEntry{}, "", err
+	}
+	if !parent.isDir() {
+		return dirEntry{}, "", ErrNotDir
+	}
+	return parent, base, nil
+}
+
+// writeEntry writes the short entry of e back to the device.
+func (fsys *FS) writeEntry(e *dirEntry) error {
+	var buf [entrySize]byte
+	e.encode(buf[:])
+	_, err := fsys.dev.WriteAt(buf[:], e.addr)
+	return err
+}
+
+// deleteEntry marks the entries belonging to e as deleted.
+func (fsys *FS) deleteEntry(e dirEntry) error {
+	d := fsys.dirReader(e.dir)
+	free := []byte{entryFree}
+	for idx := e.first; idx <= e.index; idx++ {
+		addr, err := d.addr(idx, false)
+		if err != nil {
+			return err
+		}
+		if _, err := fsys.dev.WriteAt(free, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEntry creates a new entry called name in the directory dir.
+func (fsys *FS) addEntry(dir uint32, name string, attr uint8, cluster, size uint32) (dirEntry, error) {
+	if _, err := fsys.findEntry(dir, name); err == nil {
+		return dirEntry{}, fs.ErrExist
+	} else if err != fs.ErrNotExist {
+		return dirEntry{}, err
+	}
+	return fsys.insertEntry(dir, name, attr, cluster, size)
+}
+
+// insertEntry is addEntry without checking that the name is free, for
+// changing the case of a name.
+func (fsys *FS) insertEntry(dir uint32, name string, attr uint8, cluster, size uint32) (dirEntry, error) {
+	if !validName(name) {
+		return dirEntry{}, fs.ErrInvalid
+	}
+	e := dirEntry{
+		name:    name,
+		attr:    attr,
+		cluster: cluster,
+		size:    size,
+		modTime: fsys.now(),
+		dir:     dir,
+	}
+	var lfn []uint16
+	if short, flags, ok := shortName(name); ok {
+		e.short, e.ntFlags = short, flags
+	} else {
+		var err error
+		if e.short, err = fsys.uniqueShortName(dir, name); err != nil {
+			return dirEntry{}, err
+		}
+		lfn = utf16.Encode([]rune(name))
+	}
+	lfnEntries := (len(lfn) + lfnChars - 1) / lfnChars
+	if lfnEntries > 20 {
+		return dirEntry{}, fs.ErrInvalid
+	}
+
+	// Find a run of free entries.
+	d := fsys.dirReader(dir)
+	need := lfnEntries + 1
+	run := 0
+	var buf [entrySize]byte
+	for idx := 0; run < need; idx++ {
+		addr, err := d.addr(idx, true)
+		if err != nil {
+			return dirEntry{}, err
+		}
+		if _, err := fsys.dev.ReadAt(buf[:1], addr); err != nil {
+			return dirEntry{}, err
+		}
+		switch buf[0] {
+		case entryEnd:
+			// All entries from here on are free.
+			if idx-run+need > maxDirLookup {
+				return dirEntry{}, ErrNoSpace
+			}
+			e.first = idx - run
+			run = need
+		case entryFree:
+			run++
+			e.first = idx - run + 1
+		default:
+			run = 0
+		}
+	}
+	e.index = e.first + lfnEntries
+
+	// Write the long name entries (in reverse order) and the short entry.
+	sum := checksum(e.short[:])
+	for i := 0; i < lfnEntries; i++ {
+		ord := lfnEntries - i
+		for j := range buf {
+			buf[j] = 0
+		}
+		buf[0] = byte(ord)
+		if i == 0 {
+			buf[0] |= lfnLast
+		}
+		buf[11] = attrLongName
+		buf[13] = sum
+		for j, off := range lfnOffsets {
+			c := uint16(0xffff)
+			k := (ord-1)*lfnChars + j
+			if k < len(lfn) {
+				c = lfn[k]
+			} else if k == len(lfn) {
+				c = 0
+			}
+			binary.LittleEndian.PutUint16(buf[off:], c)
+		}
+		addr, err := d.addr(e.first+i, true)
+		if err != nil {
+			return dirEntry{}, err
+		}
+		if _, err := fsys.dev.WriteAt(buf[:], addr); err != nil {
+			return dirEntry{}, err
+		}
+	}
+	addr, err := d.addr(e.index, true)
+	if err != nil {
+		return dirEntry{}, err
+	}
+	e.addr = addr
+	if err := fsys.writeEntry(&e); err != nil {
+		return dirEntry{}, err
+	}
+	return e, nil
+}
+
+// validName reports whether name can be used as a long file name.
+func validName(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > maxNameLen {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(`"*/:<>?\|`, r) {
+			return false
+		}
+	}
+	return !strings.HasSuffix(name, ".") && !strings.HasSuffix(name, " ")
+}
+
+func shortChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80 ||
+		strings.IndexByte("!#$%&'()-@^_`{}~", c) >= 0
+}
+
+// shortName returns the 8.3 form of name if it can be stored without a long
+// name entry. Lower case names are supported using the NT case flags.
+func shortName(name string) (short [11]byte, flags uint8, ok bool) {
+	base, ext := name, ""
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		base, ext = name[:i], name[i+1:]
+	}
+	if len(base) == 0 || len(base) > 8 || len(ext) > 3 || strings.IndexByte(ext, '.') >= 0 {
+		return short, 0, false
+	}
+	check := func(s string, flag uint8) bool {
+		upper, lower := strings.ToUpper(s), strings.ToLower(s)
+		switch {
+		case s == lower && s != upper:
+			flags |= flag
+		case s != upper:
+			return false // mixed case
+		}
+		for i := 0; i < len(upper); i++ {
+			if upper[i] >= 0x80 || !shortChar(upper[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if !check(base, ntLowerBase) || !check(ext, ntLowerExt) {
+		return short, 0, false
+	}
+	copy(short[:], "           ")
+	copy(short[:8], strings.ToUpper(base))
+	copy(short[8:], strings.ToUpper(ext))
+	return short, flags, true
+}
+
+// uniqueShortName generates a short name like "LONGNA~1.TXT" for name that
+// doesn't exist yet in the directory.
+func (fsys *FS) uniqueShortName(dir uint32, name string) ([11]byte, error) {
+	base, ext := name, ""
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		base, ext = name[:i], name[i+1:]
+	}
+	clean := func(s string, max int) string {
+		var b []byte
+		for i := 0; i < len(s) && len(b) < max; i++ {
+			c := s[i]
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			switch {
+			case c == ' ' || c == '.':
+				continue
+			case c >= 0x80 || !shortChar(c):
+				c = '_'
+			}
+			b = append(b, c)
+		}
+		return string(b)
+	}
+	base, ext = clean(base, 8), clean(ext, 3)
+	if base == "" {
+		base = "_"
+	}
+
+	existing := make(map[[11]byte]bool)
+	err := fsys.walkDir(dir, func(e *dirEntry) bool {
+		existing[e.short] = true
+		return true
+	})
+	if err != nil {
+		return [11]byte{}, err
+	}
+	var short [11]byte
+	for n := 1; n < 1000000; n++ {
+		tail := "~" + strconv.Itoa(n)
+		b := base
+		if len(b)+len(tail) > 8 {
+			b = b[:8-len(tail)]
+		}
+		copy(short[:], "           ")
+		copy(short[:8], b+tail)
+		copy(short[8:], ext)
+		if !existing[short] {
+			return short, nil
+		}
+	}
+	return short, ErrNoSpace
+}
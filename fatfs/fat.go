@@ -0,0 +1,206 @@
+package fatfs
+
+import "encoding/binary"
+
+// fatByte returns a pointer to the byte at offset off in the FAT, loading
+// the sector containing it into the cache. Callers that modify the byte must
+// set fatBufDirty.
+func (fsys *FS) fatByte(off int64) (*byte, error) {
+	sec := off / fsys.sectorSize
+	if sec != fsys.fatBufSector {
+		if err := fsys.flushFAT(); err != nil {
+			return nil, err
+		}
+		if _, err := fsys.dev.ReadAt(fsys.fatBuf, fsys.fatStart+sec*fsys.sectorSize); err != nil {
+			fsys.fatBufSector = -1
+			return nil, err
+		}
+		fsys.fatBufSector = sec
+	}
+	return &fsys.fatBuf[off%fsys.sectorSize], nil
+}
+
+// flushFAT writes the cached FAT sector to all copies of the FAT.
+func (fsys *FS) flushFAT() error {
+	if !fsys.fatBufDirty {
+		return nil
+	}
+	for i := 0; i < fsys.numFATs; i++ {
+		addr := fsys.fatStart + int64(i)*fsys.fatSize + fsys.fatBufSector*fsys.sectorSize
+		if _, err := fsys.dev.WriteAt(fsys.fatBuf, addr); err != nil {
+			return err
+		}
+	}
+	fsys.fatBufDirty = false
+	if fsys.fsInfo != 0 && !fsys.fsInfoStale {
+		// The free cluster count in the FSInfo sector is not maintained, so
+		// mark it as unknown once the FAT is modified.
+		unknown := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		if _, err := fsys.dev.WriteAt(unknown, fsys.fsInfo+488); err != nil {
+			return err
+		}
+		fsys.fsInfoStale = true
+	}
+	return nil
+}
+
+// getFAT returns the FAT entry of cluster c.
+func (fsys *FS) getFAT(c uint32) (uint32, error) {
+	switch fsys.typ {
+	case FAT12:
+		off := int64(c) + int64(c)/2
+		lo, err := fsys.fatByte(off)
+		if err != nil {
+			return 0, err
+		}
+		v := uint32(*lo)
+		hi, err := fsys.fatByte(off + 1)
+		if err != nil {
+			return 0, err
+		}
+		v |= uint32(*hi) << 8
+		if c&1 != 0 {
+			return v >> 4, nil
+		}
+		return v & 0xfff, nil
+	case FAT16:
+		if _, err := fsys.fatByte(int64(c) * 2); err != nil {
+			return 0, err
+		}
+		off := (int64(c) * 2) % fsys.sectorSize
+		return uint32(binary.LittleEndian.Uint16(fsys.fatBuf[off:])), nil
+	default:
+		if _, err := fsys.fatByte(int64(c) * 4); err != nil {
+			return 0, err
+		}
+		off := (int64(c) * 4) % fsys.sectorSize
+		return binary.LittleEndian.Uint32(fsys.fatBuf[off:]) & 0x0fffffff, nil
+	}
+}
+
+// setFAT sets the FAT entry of cluster c to v.
+func (fsys *FS) setFAT(c, v uint32) error {
+	switch fsys.typ {
+	case FAT12:
+		off := int64(c) + int64(c)/2
+		lo, err := fsys.fatByte(off)
+		if err != nil {
+			return err
+		}
+		if c&1 != 0 {
+			*lo = *lo&0x0f | byte(v<<4)
+		} else {
+			*lo = byte(v)
+		}
+		fsys.fatBufDirty = true
+		hi, err := fsys.fatByte(off + 1)
+		if err != nil {
+			return err
+		}
+		if c&1 != 0 {
+			*hi = byte(v >> 4)
+		} else {
+			*hi = *hi&0xf0 | byte(v>>8)&0x0f
+		}
+		fsys.fatBufDirty = true
+	case FAT16:
+		if _, err := fsys.fatByte(int64(c) * 2); err != nil {
+			return err
+		}
+		off := (int64(c) * 2) % fsys.sectorSize
+		binary.LittleEndian.PutUint16(fsys.fatBuf[off:], uint16(v))
+		fsys.fatBufDirty = true
+	default:
+		if _, err := fsys.fatByte(int64(c) * 4); err != nil {
+			return err
+		}
+		off := (int64(c) * 4) % fsys.sectorSize
+		old := binary.LittleEndian.Uint32(fsys.fatBuf[off:])
+		binary.LittleEndian.PutUint32(fsys.fatBuf[off:], old&0xf0000000|v&0x0fffffff)
+		fsys.fatBufDirty = true
+	}
+	return nil
+}
+
+// nextCluster returns the cluster following c in its chain, or 0 if c is the
+// last cluster.
+func (fsys *FS) nextCluster(c uint32) (uint32, error) {
+	v, err := fsys.getFAT(c)
+	if err != nil {
+		return 0, err
+	}
+	if v >= fsys.eocMin {
+		return 0, nil
+	}
+	if !fsys.validCluster(v) {
+		return 0, ErrCorrupt
+	}
+	return v, nil
+}
+
+// allocCluster allocates a free cluster and appends it to the chain ending
+// in prev (if prev is not 0). If zero is set, the cluster is filled with
+// zeroes, as needed for directories.
+func (fsys *FS) allocCluster(prev uint32, zero bool) (uint32, error) {
+	c := fsys.next
+	for i := uint32(0); i < fsys.clusters; i++ {
+		if !fsys.validCluster(c) {
+			c = 2
+		}
+		v, err := fsys.getFAT(c)
+		if err != nil {
+			return 0, err
+		}
+		if v == 0 {
+			if zero {
+				if err := fsys.zeroCluster(c); err != nil {
+					return 0, err
+				}
+			}
+			if err := fsys.setFAT(c, fsys.eocMark); err != nil {
+				return 0, err
+			}
+			if prev != 0 {
+				if err := fsys.setFAT(prev, c); err != nil {
+					return 0, err
+				}
+			}
+			fsys.next = c + 1
+			return c, nil
+		}
+		c++
+	}
+	return 0, ErrNoSpace
+}
+
+func (fsys *FS) zeroCluster(c uint32) error {
+	var zero [512]byte
+	addr := fsys.clusterAddr(c)
+	for off := int64(0); off < fsys.clusterSize; off += int64(len(zero)) {
+		if _, err := fsys.dev.WriteAt(zero[:], addr+off); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freeChain marks all clusters in the chain starting at c as free.
+func (fsys *FS) freeChain(c uint32) error {
+	for n := uint32(0); c != 0; n++ {
+		if n > fsys.clusters {
+			return ErrCorrupt
+		}
+		next, err := fsys.nextCluster(c)
+		if err != nil {
+			return err
+		}
+		if err := fsys.setFAT(c, 0); err != nil {
+			return err
+		}
+		if c < fsys.next {
+			fsys.next = c
+		}
+		c = next
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+// Package fatfs implements the FAT12, FAT16 and FAT32 filesystems on top of a
+// drivers.BlockDevice such as an sdcard.Device, so that files written by a
+// microcontroller can be read by a PC and the other way around.
+//
+// The filesystem implements io/fs.FS for reading. Files can be created and
+// modified with OpenFile, Mkdir, Remove and Rename. Long file names (VFAT) are
+// supported.
+//
+//	fsys, err := fatfs.Mount(sd)
+//	if err != nil {
+//		return err
+//	}
+//	f, err := fsys.OpenFile("log.txt", os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+//	if err != nil {
+//		return err
+//	}
+//	f.Write([]byte("hello\n"))
+//	f.Close()
+package fatfs // import "tinygo.org/x/drivers/fatfs"
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// Type is the FAT variant of a filesystem.
+type Type uint8
+
+const (
+	FAT12 Type = 12
+	FAT16 Type = 16
+	FAT32 Type = 32
+)
+
+var (
+	ErrNotFAT   = errors.New("fatfs: no FAT filesystem found")
+	ErrCorrupt  = errors.New("fatfs: filesystem is corrupt")
+	ErrNoSpace  = errors.New("fatfs: no space left on device")
+	ErrNotEmpty = errors.New("fatfs: directory not empty")
+	ErrIsDir    = errors.New("fatfs: is a directory")
+	ErrNotDir   = errors.New("fatfs: not a directory")
+)
+
+// Attributes of a directory entry.
+const (
+	attrReadOnly  = 0x01
+	attrHidden    = 0x02
+	attrSystem    = 0x04
+	attrVolumeID  = 0x08
+	attrDirectory = 0x10
+	attrArchive   = 0x20
+	attrLongName  = attrReadOnly | attrHidden | attrSystem | attrVolumeID
+)
+
+// FS is a mounted FAT filesystem.
+type FS struct {
+	dev  drivers.BlockDevice
+	base int64 // byte offset of the volume on the device (partition start)
+	typ  Type
+
+	sectorSize  int64
+	clusterSize int64
+	fatStart    int64 // byte offset of the first FAT
+	fatSize     int64 // size of a single FAT in bytes
+	numFATs     int
+	rootStart   int64 // byte offset of the FAT12/FAT16 root directory
+	rootEntries int
+	rootCluster uint32 // first cluster of the FAT32 root directory
+	dataStart   int64
+	clusters    uint32 // number of data clusters
+	fsInfo      int64  // byte offset of the FAT32 FSInfo sector, or 0
+
+	eocMin  uint32 // smallest end of chain marker
+	eocMark uint32 // end of chain marker written by this package
+	next    uint32 // where to start looking for free clusters
+
+	// Single sector cache for FAT accesses.
+	fatBuf       []byte
+	fatBufSector int64
+	fatBufDirty  bool
+	fsInfoStale  bool
+
+	// Now returns the time used for modification times. If it is nil,
+	// time.Now is used.
+	Now func() time.Time
+}
+
+// Mount mounts the FAT filesystem on dev. If dev starts with a master boot
+// record, the first FAT partition is used.
+func Mount(dev drivers.BlockDevice) (*FS, error) {
+	buf := make([]byte, 512)
+	if _, err := dev.ReadAt(buf, 0); err != nil {
+		return nil, err
+	}
+	if buf[510] != 0x55 || buf[511] != 0xaa {
+		return nil, ErrNotFAT
+	}
+	fsys := &FS{dev: dev, fatBufSector: -1}
+	if !isBootSector(buf) {
+		// Look for a partition in the master boot record.
+		for i := 0; i < 4 && fsys.base == 0; i++ {
+			p := buf[0x1be+i*16:]
+			switch p[4] {
+			case 0x01, 0x04, 0x06, 0x0b, 0x0c, 0x0e:
+				fsys.base = int64(binary.LittleEndian.Uint32(p[8:])) * 512
+			}
+		}
+		if fsys.base == 0 {
+			return nil, ErrNotFAT
+		}
+		if _, err := dev.ReadAt(buf, fsys.base); err != nil {
+			return nil, err
+		}
+		if !isBootSector(buf) {
+			return nil, ErrNotFAT
+		}
+	}
+	if err := fsys.parseBootSector(buf); err != nil {
+		return nil, err
+	}
+	fsys.fatBuf = make([]byte, fsys.sectorSize)
+	return fsys, nil
+}
+
+func isBootSector(b []byte) bool {
+	if b[0] != 0xeb && b[0] != 0xe9 {
+		return false
+	}
+	bps := binary.LittleEndian.Uint16(b[11:])
+	spc := b[13]
+	return (bps == 512 || bps == 1024 || bps == 2048 || bps == 4096) &&
+		spc != 0 && spc&(spc-1) == 0 && b[16] != 0
+}
+
+func (fsys *FS) parseBootSector(b []byte) error {
+	le := binary.LittleEndian
+	fsys.sectorSize = int64(le.Uint16(b[11:]))
+	fsys.clusterSize = int64(b[13]) * fsys.sectorSize
+	reserved := int64(le.Uint16(b[14:]))
+	fsys.numFATs = int(b[16])
+	fsys.rootEntries = int(le.Uint16(b[17:]))
+	totalSectors := int64(le.Uint16(b[19:]))
+	if totalSectors == 0 {
+		totalSectors = int64(le.Uint32(b[32:]))
+	}
+	fatSectors := int64(le.Uint16(b[22:]))
+	if fatSectors == 0 {
+		fatSectors = int64(le.Uint32(b[36:]))
+	}
+	rootSectors := (int64(fsys.rootEntries)*32 + fsys.sectorSize - 1) / fsys.sectorSize
+	dataSectors := totalSectors - reserved - int64(fsys.numFATs)*fatSectors - rootSectors
+	if reserved == 0 || fatSectors == 0 || dataSectors <= 0 {
+		return ErrNotFAT
+	}
+
+	fsys.fatStart = fsys.base + reserved*fsys.sectorSize
+	fsys.fatSize = fatSectors * fsys.sectorSize
+	fsys.rootStart = fsys.fatStart + int64(fsys.numFATs)*fsys.fatSize
+	fsys.dataStart = fsys.rootStart + rootSectors*fsys.sectorSize
+	fsys.clusters = uint32(dataSectors * fsys.sectorSize / fsys.clusterSize)
+
+	switch {
+	case fsys.clusters < 4085:
+		fsys.typ = FAT12
+		fsys.eocMin, fsys.eocMark = 0xff8, 0xfff
+	case fsys.clusters < 65525:
+		fsys.typ = FAT16
+		fsys.eocMin, fsys.eocMark = 0xfff8, 0xffff
+	default:
+		fsys.typ = FAT32
+		fsys.eocMin, fsys.eocMark = 0x0ffffff8, 0x0fffffff
+		fsys.rootCluster = le.Uint32(b[44:])
+		if sec := int64(le.Uint16(b[48:])); sec != 0 && sec != 0xffff {
+			fsys.fsInfo = fsys.base + sec*fsys.sectorSize
+		}
+		if !fsys.validCluster(fsys.rootCluster) {
+			return ErrCorrupt
+		}
+	}
+	if int64(fsys.clusters)*fatEntryBits(fsys.typ)/8 > fsys.fatSize {
+		return ErrCorrupt
+	}
+	fsys.next = 2
+	return nil
+}
+
+func fatEntryBits(t Type) int64 {
+	if t == FAT12 {
+		return 12
+	}
+	return int64(t)
+}
+
+// Type returns the FAT variant of the filesystem.
+func (fsys *FS) Type() Type {
+	return fsys.typ
+}
+
+// ClusterSize returns the allocation unit of the filesystem in bytes.
+func (fsys *FS) ClusterSize() int64 {
+	return fsys.clusterSize
+}
+
+// Sync writes any cached filesystem metadata to the device.
+func (fsys *FS) Sync() error {
+	return fsys.flushFAT()
+}
+
+func (fsys *FS) now() time.Time {
+	if fsys.Now != nil {
+		return fsys.Now()
+	}
+	return time.Now()
+}
+
+func (fsys *FS) validCluster(c uint32) bool {
+	return c >= 2 && c < fsys.clusters+2
+}
+
+func (fsys *FS) clusterAddr(c uint32) int64 {
+	return fsys.dataStart + int64(c-2)*fsys.clusterSize
+}
+
+// Open implements fs.FS. The returned file is read only.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	f, err := fsys.OpenFile(name, 0)
+	if err != nil {
+		// Avoid returning a typed nil.
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat returns information about the named file.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	e, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return e.info(), nil
+}
+
+// Mkdir creates a new directory.
+func (fsys *FS) Mkdir(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := fsys.mkdir(name); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (fsys *FS) mkdir(name string) error {
+	parent, base, err := fsys.lookupParent(name)
+	if err != nil {
+		return err
+	}
+	c, err := fsys.allocCluster(0, true)
+	if err != nil {
+		return err
+	}
+	now := fsys.now()
+	// Write the "." and ".." entries.
+	var buf [64]byte
+	dot := dirEntry{attr: attrDirectory, cluster: c, modTime: now}
+	copy(dot.short[:], ".          ")
+	dot.encode(buf[:32])
+	dotdot := dirEntry{attr: attrDirectory, cluster: parent.cluster, modTime: now}
+	if parent.isRoot() {
+		dotdot.cluster = 0
+	}
+	copy(dotdot.short[:], "..         ")
+	dotdot.encode(buf[32:])
+	if _, err := fsys.dev.WriteAt(buf[:], fsys.clusterAddr(c)); err != nil {
+		return err
+	}
+	if _, err := fsys.addEntry(parent.cluster, base, attrDirectory, c, 0); err != nil {
+		fsys.freeChain(c)
+		return err
+	}
+	return fsys.flushFAT()
+}
+
+// Remove removes the named file or empty directory.
+func (fsys *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := fsys.remove(name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (fsys *FS) remove(name string) error {
+	e, err := fsys.lookup(name)
+	if err != nil {
+		return err
+	}
+	if e.isDir() {
+		empty := true
+		err := fsys.walkDir(e.cluster, func(child *dirEntry) bool {
+			empty = false
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return ErrNotEmpty
+		}
+	}
+	if err := fsys.deleteEntry(e); err != nil {
+		return err
+	}
+	if e.cluster != 0 {
+		if err := fsys.freeChain(e.cluster); err != nil {
+			return err
+		}
+	}
+	return fsys.flushFAT()
+}
+
+// Rename renames (moves) oldname to newname. If newname already exists, it
+// is not replaced and an error is returned.
+func (fsys *FS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) || oldname == "." || newname == "." {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if err := fsys.rename(oldname, newname); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (fsys *FS) rename(oldname, newname string) error {
+	e, err := fsys.lookup(oldname)
+	if err != nil {
+		return err
+	}
+	parent, base, err := fsys.lookupParent(newname)
+	if err != nil {
+		return err
+	}
+	add := fsys.addEntry
+	if existing, err := fsys.lookup(newname); err == nil {
+		if existing.addr != e.addr {
+			return fs.ErrExist
+		}
+		// Only the case of the name changes: the new entry is added next to
+		// the old one, which is only removed once the new one is written so
+		// that the file isn't lost if that fails.
+		add = fsys.insertEntry
+	}
+	if e.isDir() {
+		// Don't allow moving a directory into itself.
+		for p := newname; p != "."; p = parentPath(p) {
+			if pe, err := fsys.lookup(p); err == nil && pe.cluster == e.cluster {
+				return fs.ErrInvalid
+			}
+		}
+	}
+	n, err := add(parent.cluster, base, e.attr, e.cluster, e.size)
+	if err != nil {
+		return err
+	}
+	n.modTime = e.modTime
+	if err := fsys.writeEntry(&n); err != nil {
+		return err
+	}
+	if err := fsys.deleteEntry(e); err != nil {
+		return err
+	}
+	if e.isDir() && e.dir != parent.cluster {
+		// Update the ".." entry.
+		var buf [4]byte
+		c := parent.cluster
+		if parent.isRoot() {
+			c = 0
+		}
+		addr := fsys.clusterAddr(e.cluster) + 32
+		binary.LittleEndian.PutUint16(buf[0:], uint16(c>>16))
+		if _, err := fsys.dev.WriteAt(buf[:2], addr+20); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint16(buf[2:], uint16(c))
+		if _, err := fsys.dev.WriteAt(buf[2:], addr+26); err != nil {
+			return err
+		}
+	}
+	return fsys.flushFAT()
+}
+
+func parentPath(name string) string {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '/' {
+			return name[:i]
+		}
+	}
+	return "."
+}
@@ -0,0 +1,257 @@
+package fatfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func newFS(c *qt.C, size int64, typ Type) (*tester.BlockDevice, *FS) {
+	dev := tester.NewBlockDevice(size, 512)
+	err := Format(dev, &FormatConfig{Type: typ, Label: "TEST"})
+	c.Assert(err, qt.IsNil)
+	fsys, err := Mount(dev)
+	c.Assert(err, qt.IsNil)
+	c.Assert(fsys.Type(), qt.Equals, typ)
+	return dev, fsys
+}
+
+func writeFile(c *qt.C, fsys *FS, name string, data []byte) {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	c.Assert(err, qt.IsNil)
+	n, err := f.Write(data)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, len(data))
+	c.Assert(f.Close(), qt.IsNil)
+}
+
+func TestTypes(t *testing.T) {
+	for _, tc := range []struct {
+		typ  Type
+		size int64
+	}{
+		{FAT12, 1 << 20},
+		{FAT16, 32 << 20},
+		{FAT32, 40 << 20},
+	} {
+		t.Run(fmt.Sprint("FAT", tc.typ), func(t *testing.T) {
+			c := qt.New(t)
+			dev, fsys := newFS(c, tc.size, tc.typ)
+
+			big := make([]byte, 3*fsys.ClusterSize()+100)
+			for i := range big {
+				big[i] = byte(i * 7)
+			}
+			writeFile(c, fsys, "HELLO.TXT", []byte("hello world\n"))
+			writeFile(c, fsys, "a long file name.data", big)
+			c.Assert(fsys.Mkdir("dir"), qt.IsNil)
+			c.Assert(fsys.Mkdir("dir/sub"), qt.IsNil)
+			writeFile(c, fsys, "dir/sub/empty", nil)
+			writeFile(c, fsys, "dir/readme.md", []byte("# readme"))
+
+			// Remount to make sure everything was written to the device.
+			fsys, err := Mount(dev)
+			c.Assert(err, qt.IsNil)
+			err = fstest.TestFS(fsys, "HELLO.TXT", "a long file name.data", "dir/sub/empty", "dir/readme.md")
+			c.Assert(err, qt.IsNil)
+
+			data, err := fs.ReadFile(fsys, "a long file name.data")
+			c.Assert(err, qt.IsNil)
+			c.Assert(data, qt.DeepEquals, big)
+
+			// Names are case insensitive.
+			data, err = fs.ReadFile(fsys, "DIR/README.MD")
+			c.Assert(err, qt.IsNil)
+			c.Assert(string(data), qt.Equals, "# readme")
+		})
+	}
+}
+
+func TestShortNames(t *testing.T) {
+	c := qt.New(t)
+	dev, fsys := newFS(c, 1<<20, FAT12)
+	writeFile(c, fsys, "readme.txt", nil)
+	writeFile(c, fsys, "Makefile", nil)
+	writeFile(c, fsys, "verylongname.txt", nil)
+	writeFile(c, fsys, "verylongname2.txt", nil)
+
+	var names []string
+	err := fsys.walkDir(0, func(e *dirEntry) bool {
+		names = append(names, e.name+"="+e.shortName())
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(names, qt.DeepEquals, []string{
+		"readme.txt=readme.txt",
+		"Makefile=MAKEFI~1",
+		"verylongname.txt=VERYLO~1.TXT",
+		"verylongname2.txt=VERYLO~2.TXT",
+	})
+
+	// A lower case 8.3 name doesn't need a long name entry.
+	c.Assert(string(dev.Data[fsys.rootStart+32:][:11]), qt.Equals, "README  TXT")
+}
+
+func TestReadWrite(t *testing.T) {
+	c := qt.New(t)
+	_, fsys := newFS(c, 1<<20, FAT12)
+
+	f, err := fsys.OpenFile("file", os.O_RDWR|os.O_CREATE)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write([]byte("0123456789"))
+	c.Assert(err, qt.IsNil)
+	_, err = f.WriteAt([]byte("abc"), 3)
+	c.Assert(err, qt.IsNil)
+	// Writing past the end fills the gap with zeroes.
+	_, err = f.WriteAt([]byte("z"), 12)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, qt.IsNil)
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(data), qt.Equals, "012abc6789\x00\x00z")
+
+	c.Assert(f.Truncate(4), qt.IsNil)
+	c.Assert(f.Close(), qt.IsNil)
+	data, err = fs.ReadFile(fsys, "file")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(data), qt.Equals, "012a")
+
+	f, err = fsys.OpenFile("file", os.O_WRONLY|os.O_APPEND)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write([]byte("++"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(f.Close(), qt.IsNil)
+	data, _ = fs.ReadFile(fsys, "file")
+	c.Assert(string(data), qt.Equals, "012a++")
+
+	_, err = fsys.OpenFile("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	c.Assert(errors.Is(err, fs.ErrExist), qt.IsTrue)
+	_, err = fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE)
+	c.Assert(errors.Is(err, fs.ErrNotExist), qt.IsTrue)
+	_, err = fsys.OpenFile("bad:name", os.O_WRONLY|os.O_CREATE)
+	c.Assert(errors.Is(err, fs.ErrInvalid), qt.IsTrue)
+}
+
+func TestRemoveRename(t *testing.T) {
+	c := qt.New(t)
+	_, fsys := newFS(c, 1<<20, FAT12)
+	c.Assert(fsys.Mkdir("a"), qt.IsNil)
+	c.Assert(fsys.Mkdir("b"), qt.IsNil)
+	writeFile(c, fsys, "a/some file.txt", bytes.Repeat([]byte("x"), 5000))
+
+	c.Assert(errors.Is(fsys.Remove("a"), ErrNotEmpty), qt.IsTrue)
+	c.Assert(fsys.Rename("a/some file.txt", "b/moved.txt"), qt.IsNil)
+	c.Assert(fsys.Remove("a"), qt.IsNil)
+	c.Assert(fsys.Rename("b", "c"), qt.IsNil)
+	c.Assert(fsys.Rename("c/moved.txt", "c/MOVED.TXT"), qt.IsNil)
+	c.Assert(errors.Is(fsys.Rename("c", "c/d"), fs.ErrInvalid), qt.IsTrue)
+
+	entries, err := fs.ReadDir(fsys, ".")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(entries), qt.Equals, 1)
+	c.Assert(entries[0].Name(), qt.Equals, "c")
+	data, err := fs.ReadFile(fsys, "c/MOVED.TXT")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(data), qt.Equals, 5000)
+	c.Assert(fstest.TestFS(fsys, "c/MOVED.TXT"), qt.IsNil)
+
+	// Removing the file frees its clusters again.
+	c.Assert(fsys.Remove("c/MOVED.TXT"), qt.IsNil)
+	free := 0
+	for cl := uint32(2); cl < fsys.clusters+2; cl++ {
+		if v, _ := fsys.getFAT(cl); v == 0 {
+			free++
+		}
+	}
+	c.Assert(free, qt.Equals, int(fsys.clusters)-1) // only "c" remains
+}
+
+func TestRenameCaseFullDir(t *testing.T) {
+	c := qt.New(t)
+	_, fsys := newFS(c, 1<<20, FAT12)
+	writeFile(c, fsys, "AB", []byte("data"))
+	// Fill the root directory, which can't grow.
+	for i := 0; ; i++ {
+		f, err := fsys.OpenFile(fmt.Sprintf("F%d", i), os.O_WRONLY|os.O_CREATE)
+		if err != nil {
+			c.Assert(errors.Is(err, ErrNoSpace), qt.IsTrue)
+			break
+		}
+		c.Assert(f.Close(), qt.IsNil)
+	}
+
+	// The mixed case name needs a long name entry, there is no room for it
+	// and the file keeps its old name.
+	c.Assert(errors.Is(fsys.Rename("AB", "Ab"), ErrNoSpace), qt.IsTrue)
+	data, err := fs.ReadFile(fsys, "AB")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(data), qt.Equals, "data")
+	entries, err := fs.ReadDir(fsys, ".")
+	c.Assert(err, qt.IsNil)
+	c.Assert(entries[0].Name(), qt.Equals, "AB")
+
+	c.Assert(fsys.Remove("F0"), qt.IsNil)
+	c.Assert(fsys.Remove("F1"), qt.IsNil)
+	c.Assert(fsys.Rename("AB", "Ab"), qt.IsNil)
+	data, err = fs.ReadFile(fsys, "Ab")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(data), qt.Equals, "data")
+	entries, err = fs.ReadDir(fsys, ".")
+	c.Assert(err, qt.IsNil)
+	c.Assert(entries[0].Name(), qt.Equals, "Ab") // and not also "AB"
+}
+
+func TestFullDisk(t *testing.T) {
+	c := qt.New(t)
+	_, fsys := newFS(c, 256<<10, FAT12)
+	f, err := fsys.OpenFile("big", os.O_WRONLY|os.O_CREATE)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write(make([]byte, 300<<10))
+	c.Assert(errors.Is(err, ErrNoSpace), qt.IsTrue)
+	c.Assert(f.Close(), qt.IsNil)
+
+	// Many files in a subdirectory make it grow over several clusters.
+	c.Assert(fsys.Remove("big"), qt.IsNil)
+	c.Assert(fsys.Mkdir("many"), qt.IsNil)
+	for i := 0; i < 100; i++ {
+		writeFile(c, fsys, fmt.Sprintf("many/file number %d", i), []byte{byte(i)})
+	}
+	entries, err := fs.ReadDir(fsys, "many")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(entries), qt.Equals, 100)
+}
+
+func TestPartition(t *testing.T) {
+	c := qt.New(t)
+	part := tester.NewBlockDevice(2<<20, 512)
+	c.Assert(Format(part, nil), qt.IsNil)
+
+	// Put the filesystem at sector 2048 behind a master boot record.
+	dev := tester.NewBlockDevice(2048*512+part.Size(), 512)
+	copy(dev.Data[2048*512:], part.Data)
+	mbr := dev.Data[0x1be:]
+	mbr[4] = 0x01
+	mbr[9] = 2048 >> 8
+	dev.Data[510], dev.Data[511] = 0x55, 0xaa
+
+	fsys, err := Mount(dev)
+	c.Assert(err, qt.IsNil)
+	writeFile(c, fsys, "file", []byte("data"))
+	data, err := fs.ReadFile(fsys, "file")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(data), qt.Equals, "data")
+	c.Assert(dev.Data[:512], qt.DeepEquals, append(make([]byte, 0x1be), dev.Data[0x1be:512]...))
+
+	_, err = Mount(tester.NewBlockDevice(1<<20, 512))
+	c.Assert(err, qt.Equals, ErrNotFAT)
+}
@@ -0,0 +1,393 @@
+package fatfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// File is an open file or directory on a FAT filesystem.
+type File struct {
+	fsys  *FS
+	entry dirEntry
+	name  string
+	flag  int
+	pos   int64
+	dirty bool
+
+	// Position cache for sequential access.
+	cluster    uint32
+	clusterIdx int64
+
+	dirIdx int // next directory entry index for ReadDir
+}
+
+// OpenFile opens the named file with the given flags (os.O_RDONLY,
+// os.O_WRONLY, os.O_RDWR, os.O_APPEND, os.O_CREATE, os.O_EXCL or os.O_TRUNC).
+func (fsys *FS) OpenFile(name string, flag int) (*File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := fsys.openFile(name, flag)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+func (fsys *FS) openFile(name string, flag int) (*File, error) {
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	e, err := fsys.lookup(name)
+	switch {
+	case err == fs.ErrNotExist && flag&os.O_CREATE != 0:
+		parent, base, err := fsys.lookupParent(name)
+		if err != nil {
+			return nil, err
+		}
+		e, err = fsys.addEntry(parent.cluster, base, attrArchive, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := fsys.flushFAT(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, fs.ErrExist
+	}
+	if e.isDir() && write {
+		return nil, ErrIsDir
+	}
+	if write && e.attr&attrReadOnly != 0 {
+		return nil, fs.ErrPermission
+	}
+	f := &File{fsys: fsys, entry: e, name: name, flag: flag, cluster: e.cluster}
+	if write && flag&os.O_TRUNC != 0 && e.size != 0 {
+		if err := f.truncate(0); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Stat returns information about the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	return f.entry.info(), nil
+}
+
+// Read reads up to len(p) bytes from the file.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// ReadAt reads len(p) bytes from the file starting at offset off.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.fsys == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.entry.isDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrIsDir}
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	n := 0
+	for n < len(p) {
+		if off >= int64(f.entry.size) {
+			return n, io.EOF
+		}
+		addr, avail, err := f.locate(off, false)
+		if err != nil {
+			return n, err
+		}
+		if rest := int64(f.entry.size) - off; avail > rest {
+			avail = rest
+		}
+		chunk := p[n:]
+		if int64(len(chunk)) > avail {
+			chunk = chunk[:avail]
+		}
+		if _, err := f.fsys.dev.ReadAt(chunk, addr); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		off += int64(len(chunk))
+	}
+	return n, nil
+}
+
+// locate returns the device address of file offset off and the number of
+// bytes until the end of its cluster. If extend is set, clusters are
+// allocated as needed.
+func (f *File) locate(off int64, extend bool) (int64, int64, error) {
+	fsys := f.fsys
+	idx := off / fsys.clusterSize
+	if f.entry.cluster == 0 {
+		if !extend {
+			return 0, 0, ErrCorrupt
+		}
+		c, err := fsys.allocCluster(0, false)
+		if err != nil {
+			return 0, 0, err
+		}
+		f.entry.cluster, f.cluster, f.clusterIdx = c, c, 0
+		f.dirty = true
+	}
+	if idx < f.clusterIdx || f.cluster == 0 {
+		f.cluster, f.clusterIdx = f.entry.cluster, 0
+	}
+	for f.clusterIdx < idx {
+		next, err := fsys.nextCluster(f.cluster)
+		if err != nil {
+			return 0, 0, err
+		}
+		if next == 0 {
+			if !extend {
+				return 0, 0, ErrCorrupt
+			}
+			next, err = fsys.allocCluster(f.cluster, false)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		f.cluster = next
+		f.clusterIdx++
+	}
+	within := off % fsys.clusterSize
+	return fsys.clusterAddr(f.cluster) + within, fsys.clusterSize - within, nil
+}
+
+// Write writes p to the file at the current position.
+func (f *File) Write(p []byte) (int, error) {
+	if f.flag&os.O_APPEND != 0 {
+		f.pos = int64(f.entry.size)
+	}
+	n, err := f.WriteAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// WriteAt writes p to the file starting at offset off. Writing past the end
+// of the file fills the gap with zeroes.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if f.fsys == nil {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+	if off < 0 || off+int64(len(p)) > 0xffffffff {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if off > int64(f.entry.size) {
+		if err := f.zeroFill(off); err != nil {
+			return 0, &fs.PathError{Op: "write", Path: f.name, Err: err}
+		}
+	}
+	n, err := f.write(p, off)
+	if err != nil {
+		err = &fs.PathError{Op: "write", Path: f.name, Err: err}
+	}
+	return n, err
+}
+
+func (f *File) write(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		addr, avail, err := f.locate(off, true)
+		if err != nil {
+			return n, err
+		}
+		chunk := p[n:]
+		if int64(len(chunk)) > avail {
+			chunk = chunk[:avail]
+		}
+		if _, err := f.fsys.dev.WriteAt(chunk, addr); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		off += int64(len(chunk))
+		if off > int64(f.entry.size) {
+			f.entry.size = uint32(off)
+		}
+		f.dirty = true
+	}
+	return n, nil
+}
+
+// zeroFill extends the file with zeroes up to size.
+func (f *File) zeroFill(size int64) error {
+	var zero [512]byte
+	for off := int64(f.entry.size); off < size; {
+		n := size - off
+		if n > int64(len(zero)) {
+			n = int64(len(zero))
+		}
+		if _, err := f.write(zero[:n], off); err != nil {
+			return err
+		}
+		off += n
+	}
+	return nil
+}
+
+// Seek implements io.Seeker.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(f.entry.size)
+	default:
+		offset = -1
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file.
+func (f *File) Truncate(size int64) error {
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrPermission}
+	}
+	if size < 0 || size > 0xffffffff {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.truncate(size); err != nil {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+func (f *File) truncate(size int64) error {
+	if size > int64(f.entry.size) {
+		return f.zeroFill(size)
+	}
+	fsys := f.fsys
+	if size == 0 {
+		if err := fsys.freeChain(f.entry.cluster); err != nil {
+			return err
+		}
+		f.entry.cluster = 0
+	} else {
+		// Keep the clusters needed for size bytes and free the rest.
+		last := f.entry.cluster
+		for i := int64(1); i < (size+fsys.clusterSize-1)/fsys.clusterSize; i++ {
+			next, err := fsys.nextCluster(last)
+			if err != nil {
+				return err
+			}
+			last = next
+		}
+		next, err := fsys.nextCluster(last)
+		if err != nil {
+			return err
+		}
+		if next != 0 {
+			if err := fsys.setFAT(last, fsys.eocMark); err != nil {
+				return err
+			}
+			if err := fsys.freeChain(next); err != nil {
+				return err
+			}
+		}
+	}
+	f.entry.size = uint32(size)
+	f.cluster, f.clusterIdx = f.entry.cluster, 0
+	f.dirty = true
+	return f.Sync()
+}
+
+// Sync writes the directory entry and the FAT to the device.
+func (f *File) Sync() error {
+	if f.fsys == nil {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	if err := f.fsys.flushFAT(); err != nil {
+		return err
+	}
+	if !f.dirty {
+		return nil
+	}
+	f.entry.modTime = f.fsys.now()
+	f.entry.attr |= attrArchive
+	if err := f.fsys.writeEntry(&f.entry); err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+// Close closes the file, writing any pending changes.
+func (f *File) Close() error {
+	if f.fsys == nil {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	err := f.Sync()
+	f.fsys = nil
+	return err
+}
+
+// ReadDir reads the contents of the directory. It behaves like
+// fs.ReadDirFile.ReadDir.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.fsys == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.entry.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+	}
+	var list []fs.DirEntry
+	var err error
+	f.dirIdx, err = f.fsys.readEntries(f.entry.cluster, f.dirIdx, func(e *dirEntry) bool {
+		list = append(list, e.info())
+		return n <= 0 || len(list) < n
+	})
+	if err != nil {
+		return list, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+	}
+	if n > 0 && len(list) == 0 {
+		return nil, io.EOF
+	}
+	return list, nil
+}
+
+// fileInfo implements fs.FileInfo and fs.DirEntry.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (e *dirEntry) info() *fileInfo {
+	fi := &fileInfo{name: e.name, size: int64(e.size), modTime: e.modTime, mode: 0666}
+	if e.isDir() {
+		fi.mode = fs.ModeDir | 0777
+		fi.size = 0
+	}
+	if e.attr&attrReadOnly != 0 {
+		fi.mode &^= 0222
+	}
+	return fi
+}
+
+func (fi *fileInfo) Name() string               { return fi.name }
+func (fi *fileInfo) Size() int64                { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode          { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time         { return fi.modTime }
+func (fi *fileInfo) IsDir() bool                { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}           { return nil }
+func (fi *fileInfo) Type() fs.FileMode          { return fi.mode.Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
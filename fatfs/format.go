@@ -0,0 +1,191 @@
+package fatfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// FormatConfig contains the parameters used by Format.
+type FormatConfig struct {
+	// Type selects the FAT variant. If it is 0, it is chosen based on the
+	// size of the device.
+	Type Type
+
+	// Label is the volume label, up to 11 characters.
+	Label string
+}
+
+// Format creates a new, empty FAT filesystem that spans all of dev. Any
+// existing data on the device is lost.
+func Format(dev drivers.BlockDevice, config *FormatConfig) error {
+	if config == nil {
+		config = &FormatConfig{}
+	}
+	const sectorSize = 512
+	totalSectors := dev.Size() / sectorSize
+	if totalSectors > 0xffffffff {
+		totalSectors = 0xffffffff
+	}
+	typ := config.Type
+	if typ == 0 {
+		switch size := dev.Size(); {
+		case size < 16<<20:
+			typ = FAT12
+		case size < 512<<20:
+			typ = FAT16
+		default:
+			typ = FAT32
+		}
+	}
+
+	reserved := int64(1)
+	rootEntries := int64(512)
+	if typ == FAT32 {
+		reserved = 32
+		rootEntries = 0
+	}
+	rootSectors := rootEntries * 32 / sectorSize
+
+	// Pick the smallest cluster size that results in a valid cluster count
+	// for the requested FAT type.
+	var spc, fatSectors, clusters int64
+	for spc = 1; spc <= 128; spc *= 2 {
+		// Number of FAT sectors, see "FAT Type Determination" in the
+		// Microsoft FAT specification. This slightly overestimates for FAT12.
+		tmp1 := totalSectors - reserved - rootSectors
+		tmp2 := 256*spc + 2
+		if typ == FAT32 {
+			tmp2 /= 2
+		}
+		fatSectors = (tmp1 + tmp2 - 1) / tmp2
+		if typ == FAT12 {
+			// 1.5 bytes per entry instead of 2.
+			fatSectors = (fatSectors*3 + 3) / 4
+		}
+		clusters = (totalSectors - reserved - 2*fatSectors - rootSectors) / spc
+		if fatSectors*sectorSize*8/fatEntryBits(typ) < clusters+2 {
+			continue
+		}
+		if typ == FAT12 && clusters < 4085 ||
+			typ == FAT16 && clusters >= 4085 && clusters < 65525 ||
+			typ == FAT32 && clusters >= 65525 && clusters < 0x0ffffff5 {
+			break
+		}
+		if typ != FAT12 && clusters < 4085 {
+			// Clusters only get bigger from here, so the device is too small.
+			spc = 256
+		}
+	}
+	if spc > 128 || clusters < 1 {
+		return errors.New("fatfs: device size not suitable for the FAT type")
+	}
+
+	// Clear the reserved sectors, the FATs and the root directory.
+	zero := make([]byte, sectorSize)
+	clear := reserved + 2*fatSectors + rootSectors
+	if typ == FAT32 {
+		clear += spc
+	}
+	for sec := int64(0); sec < clear; sec++ {
+		if _, err := dev.WriteAt(zero, sec*sectorSize); err != nil {
+			return err
+		}
+	}
+
+	le := binary.LittleEndian
+	b := make([]byte, sectorSize)
+	copy(b, []byte{0xeb, 0x3c, 0x90})
+	copy(b[3:], "TINYGO  ")
+	le.PutUint16(b[11:], sectorSize)
+	b[13] = byte(spc)
+	le.PutUint16(b[14:], uint16(reserved))
+	b[16] = 2 // number of FATs
+	le.PutUint16(b[17:], uint16(rootEntries))
+	if totalSectors < 0x10000 && typ != FAT32 {
+		le.PutUint16(b[19:], uint16(totalSectors))
+	} else {
+		le.PutUint32(b[32:], uint32(totalSectors))
+	}
+	b[21] = 0xf8              // media: fixed disk
+	le.PutUint16(b[24:], 63)  // sectors per track
+	le.PutUint16(b[26:], 255) // number of heads
+	label := []byte("NO NAME    ")
+	copy(label, config.Label)
+	volID := uint32(time.Now().UnixNano())
+	fsType := "FAT12   "
+	ext := b[36:] // extended boot record
+	switch typ {
+	case FAT16:
+		fsType = "FAT16   "
+	case FAT32:
+		b[1] = 0x58
+		fsType = "FAT32   "
+		le.PutUint32(b[36:], uint32(fatSectors))
+		le.PutUint32(b[44:], 2) // root cluster
+		le.PutUint16(b[48:], 1) // FSInfo sector
+		le.PutUint16(b[50:], 6) // backup boot sector
+		ext = b[64:]
+	}
+	if typ != FAT32 {
+		le.PutUint16(b[22:], uint16(fatSectors))
+	}
+	ext[0] = 0x80 // drive number
+	ext[2] = 0x29 // extended boot signature
+	le.PutUint32(ext[3:], volID)
+	copy(ext[7:18], label)
+	copy(ext[18:26], fsType)
+	b[510], b[511] = 0x55, 0xaa
+	if _, err := dev.WriteAt(b, 0); err != nil {
+		return err
+	}
+
+	if typ == FAT32 {
+		if _, err := dev.WriteAt(b, 6*sectorSize); err != nil {
+			return err
+		}
+		info := make([]byte, sectorSize)
+		le.PutUint32(info[0:], 0x41615252)
+		le.PutUint32(info[484:], 0x61417272)
+		le.PutUint32(info[488:], 0xffffffff) // free count unknown
+		le.PutUint32(info[492:], 0xffffffff) // next free unknown
+		le.PutUint32(info[508:], 0xaa550000)
+		if _, err := dev.WriteAt(info, 1*sectorSize); err != nil {
+			return err
+		}
+	}
+
+	// The first two FAT entries hold the media byte and an end of chain
+	// marker. On FAT32, cluster 2 is the root directory.
+	var fat []byte
+	switch typ {
+	case FAT12:
+		fat = []byte{0xf8, 0xff, 0xff}
+	case FAT16:
+		fat = []byte{0xf8, 0xff, 0xff, 0xff}
+	case FAT32:
+		fat = []byte{0xf8, 0xff, 0xff, 0x0f, 0xff, 0xff, 0xff, 0x0f, 0xff, 0xff, 0xff, 0x0f}
+	}
+	for i := int64(0); i < 2; i++ {
+		if _, err := dev.WriteAt(fat, (reserved+i*fatSectors)*sectorSize); err != nil {
+			return err
+		}
+	}
+
+	if config.Label != "" {
+		// Store the volume label in the root directory as well.
+		var e [entrySize]byte
+		copy(e[:11], label)
+		e[11] = attrVolumeID
+		date, tm := encodeTime(time.Now())
+		le.PutUint16(e[22:], tm)
+		le.PutUint16(e[24:], date)
+		root := (reserved + 2*fatSectors) * sectorSize
+		if _, err := dev.WriteAt(e[:], root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
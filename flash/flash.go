@@ -24,7 +24,12 @@ type Device struct {
 // DeviceConfig contains the parameters that can be set when configuring a
 // flash memory device.
 type DeviceConfig struct {
+	// Identifier is used to look up the attributes of devices that don't
+	// have an SFDP table, and the timing attributes of those that do.
 	Identifier DeviceIdentifier
+
+	// DisableSFDP skips reading the SFDP table and only uses Identifier.
+	DisableSFDP bool
 }
 
 // JedecID encapsules the ID values that unique identify a flash memory device.
@@ -83,6 +88,23 @@ type Attrs struct {
 	// Enable bit is in the first byte and the Read Status Register 2 command
 	// (0x35) is unsupported.
 	SingleStatusByte bool
+
+	// PageSize is the largest amount of data that can be programmed with a
+	// single command. If 0, the default PageSize is used.
+	PageSize uint32
+
+	// EraseTypes lists the erase sizes supported by the device, as reported
+	// by SFDP. Unused entries have a size of 0. If all entries are unused,
+	// 4 KiB sectors (0x20) and 64 KiB blocks (0xD8) are assumed.
+	EraseTypes [4]EraseType
+
+	// FourByteMode describes how addresses above 16 MiB are reached.
+	FourByteMode FourByteMode
+
+	// Commands to suspend and resume an erase or program operation. If 0,
+	// 0x75 and 0x7A are used.
+	SuspendCmd byte
+	ResumeCmd  byte
 }
 
 // Configure sets up the device and the underlying transport mechanism.  The
@@ -98,12 +120,24 @@ func (dev *Device) Configure(config *DeviceConfig) (err error) {
 		return err
 	}
 
-	// try to ascertain the vendor-specific attributes of the chip using the
-	// provided Identifier
+	// Ascertain the attributes of the chip from its SFDP table, or if it
+	// doesn't have one, using the provided Identifier.
+	var known Attrs
 	if config.Identifier != nil {
-		dev.attrs = config.Identifier.Identify(id)
+		known = config.Identifier.Identify(id)
 	} else {
-		dev.attrs = Attrs{JedecID: id}
+		known = Attrs{JedecID: id}
+	}
+	dev.attrs = known
+	if !config.DisableSFDP {
+		// The Identifier knows what SFDP doesn't describe, like timing and
+		// sector protection.
+		attrs, err := dev.readSFDP(known)
+		if err == nil {
+			dev.attrs = attrs
+		} else if err != ErrNoSFDP {
+			return err
+		}
 	}
 
 	// We don't know what state the flash is in so wait for any remaining
@@ -115,9 +149,14 @@ func (dev *Device) Configure(config *DeviceConfig) (err error) {
 			return err
 		}
 	}
-	// The suspended write/erase bit should be low.
-	for s, err := dev.ReadStatus2(); (s & 0x80) > 0; s, err = dev.ReadStatus2() {
-		if err != nil {
+	// Finish an erase or write that was suspended.
+	if suspended, err := dev.Suspended(); err != nil {
+		return err
+	} else if suspended {
+		if err := dev.Resume(); err != nil {
+			return err
+		}
+		if err := dev.WaitUntilReady(); err != nil {
 			return err
 		}
 	}
@@ -132,6 +171,11 @@ func (dev *Device) Configure(config *DeviceConfig) (err error) {
 	// Wait for the reset - 30us by default
 	time.Sleep(30 * time.Microsecond)
 
+	// The reset switched the device back to 3-byte addresses.
+	if err := dev.setAddressMode(); err != nil {
+		return err
+	}
+
 	// Speed up to max device frequency
 	// I propose a check here for max frequency, but not put that functionality directly into the driver.
 	// Either that or we have to change the signature of the SPI interface in the machine package itself.
@@ -248,6 +292,7 @@ func (dev *Device) WriteAt(buf []byte, addr int64) (n int, err error) {
 	remain := uint32(len(buf))
 	idx := uint32(0)
 	loc := uint32(addr)
+	pageSize := uint32(dev.WriteBlockSize())
 	for remain > 0 {
 		if err = dev.WaitUntilReady(); err != nil {
 			return
@@ -255,7 +300,7 @@ func (dev *Device) WriteAt(buf []byte, addr int64) (n int, err error) {
 		if err = dev.WriteEnable(); err != nil {
 			return
 		}
-		leftOnPage := PageSize - (loc & (PageSize - 1))
+		leftOnPage := pageSize - (loc & (pageSize - 1))
 		toWrite := remain
 		if leftOnPage < remain {
 			toWrite = leftOnPage
@@ -273,55 +318,103 @@ func (dev *Device) WriteAt(buf []byte, addr int64) (n int, err error) {
 // WriteBlockSize returns the block size in which data can be written to
 // memory. It can be used by a client to optimize writes, non-aligned writes
 // should always work correctly.
-// For SPI NOR flash this is the page size, usually/always 256.
+// For SPI NOR flash this is the page size, usually 256.
 func (dev *Device) WriteBlockSize() int64 {
+	if dev.attrs.PageSize != 0 {
+		return int64(dev.attrs.PageSize)
+	}
 	return PageSize
 }
 
 // EraseBlockSize returns the smallest erasable area on this particular chip
 // in bytes. This is used for the block size in EraseBlocks.
-// For SPI NOR flash this is the sector size, usually/always 4096.
+// For SPI NOR flash this is the sector size, usually 4096.
 func (dev *Device) EraseBlockSize() int64 {
-	return SectorSize
+	return int64(dev.sectorErase().Size)
 }
 
-// EraseBlocks erases the given number of blocks. An implementation may
-// transparently coalesce ranges of blocks into larger bundles if the chip
-// supports this. The start and len parameters are in block numbers, use
-// EraseBlockSize to map addresses to blocks.
+// EraseBlocks erases the given number of blocks. Ranges of blocks are
+// coalesced into larger erase blocks where possible, which is much faster.
+// The start and len parameters are in block numbers, use EraseBlockSize to
+// map addresses to blocks.
 func (dev *Device) EraseBlocks(start, len int64) error {
-	for i := start; i < start+len; i++ {
-		if err := dev.EraseBlock(uint32(i)); err != nil {
+	sector, block := dev.sectorErase(), dev.blockErase()
+	perBlock := int64(block.Size / sector.Size)
+	for i := start; i < start+len; {
+		if perBlock > 1 && i%perBlock == 0 && start+len-i >= perBlock {
+			if err := dev.erase(block, uint32(i)*sector.Size); err != nil {
+				return err
+			}
+			i += perBlock
+			continue
+		}
+		if err := dev.erase(sector, uint32(i)*sector.Size); err != nil {
 			return err
 		}
+		i++
 	}
 	return nil
 }
 
+// sectorErase returns the smallest supported erase type.
+func (dev *Device) sectorErase() EraseType {
+	e := EraseType{Size: SectorSize, Cmd: cmdEraseSector, Cmd4: cmdEraseSector4}
+	found := false
+	for _, t := range dev.attrs.EraseTypes {
+		if t.Size != 0 && (!found || t.Size < e.Size) {
+			e, found = t, true
+		}
+	}
+	return e
+}
+
+// blockErase returns the supported erase type closest to BlockSize.
+func (dev *Device) blockErase() EraseType {
+	e := EraseType{Size: BlockSize, Cmd: cmdEraseBlock, Cmd4: cmdEraseBlock4}
+	found := false
+	for _, t := range dev.attrs.EraseTypes {
+		if t.Size != 0 && t.Size <= BlockSize && (!found || t.Size > e.Size) {
+			e, found = t, true
+		}
+	}
+	return e
+}
+
 func (dev *Device) WriteEnable() error {
 	return dev.trans.runCommand(cmdWriteEnable)
 }
 
-// EraseBlock erases a block of memory at the specified index
+// EraseBlock erases a block of memory at the specified index. A block is
+// usually 64 KiB.
 func (dev *Device) EraseBlock(blockNumber uint32) error {
-	if err := dev.WaitUntilReady(); err != nil {
-		return err
-	}
-	if err := dev.WriteEnable(); err != nil {
-		return err
-	}
-	return dev.trans.eraseCommand(cmdEraseBlock, blockNumber*BlockSize)
+	e := dev.blockErase()
+	return dev.erase(e, blockNumber*e.Size)
 }
 
-// EraseSector erases a sector of memory at the given index
+// EraseSector erases a sector of memory at the given index. A sector is
+// usually 4 KiB.
 func (dev *Device) EraseSector(sectorNumber uint32) error {
+	e := dev.sectorErase()
+	return dev.erase(e, sectorNumber*e.Size)
+}
+
+// erase starts erasing the memory at addr. It doesn't wait for the erase to
+// finish, so it can be suspended.
+func (dev *Device) erase(e EraseType, addr uint32) error {
+	cmd, cmd4 := e.Cmd, dev.attrs.FourByteMode == FourByteCommands && e.Cmd4 != 0
+	if cmd4 {
+		cmd = e.Cmd4
+	} else if dev.attrs.FourByteMode == FourByteCommands && addr >= 1<<24 {
+		// The erase type has no 4-byte command to reach the address.
+		return ErrInvalidAddrRange
+	}
 	if err := dev.WaitUntilReady(); err != nil {
 		return err
 	}
 	if err := dev.WriteEnable(); err != nil {
 		return err
 	}
-	return dev.trans.eraseCommand(cmdEraseSector, sectorNumber*SectorSize)
+	return dev.trans.eraseCommand(cmd, addr, cmd4)
 }
 
 // EraseChip erases the entire flash memory chip
@@ -365,22 +458,35 @@ func (dev *Device) WaitUntilReady() error {
 }
 
 const (
-	cmdRead            = 0x03 // read memory using single-bit transfer
-	cmdQuadRead        = 0x6B // read with 1 line address, 4 line data
-	cmdReadJedecID     = 0x9F // read the JEDEC ID from the device
-	cmdPageProgram     = 0x02 // write a page of memory using single-bit transfer
-	cmdQuadPageProgram = 0x32 // write with 1 line address, 4 line data
-	cmdReadStatus      = 0x05 // read status register 1
-	cmdReadStatus2     = 0x35 // read status register 2
-	cmdWriteStatus     = 0x01 // write status register 1
-	cmdWriteStatus2    = 0x31 // write status register 2
-	cmdEnableReset     = 0x66 // enable reset
-	cmdReset           = 0x99 // perform reset
-	cmdWriteEnable     = 0x06 // write-enable memory
-	cmdWriteDisable    = 0x04 // write-protect memory
-	cmdEraseSector     = 0x20 // erase a sector of memory
-	cmdEraseBlock      = 0xD8 // erase a block of memory
-	cmdEraseChip       = 0xC7 // erase the entire chip
+	cmdRead             = 0x03 // read memory using single-bit transfer
+	cmdQuadRead         = 0x6B // read with 1 line address, 4 line data
+	cmdReadJedecID      = 0x9F // read the JEDEC ID from the device
+	cmdPageProgram      = 0x02 // write a page of memory using single-bit transfer
+	cmdQuadPageProgram  = 0x32 // write with 1 line address, 4 line data
+	cmdReadStatus       = 0x05 // read status register 1
+	cmdReadStatus2      = 0x35 // read status register 2
+	cmdWriteStatus      = 0x01 // write status register 1
+	cmdWriteStatus2     = 0x31 // write status register 2
+	cmdEnableReset      = 0x66 // enable reset
+	cmdReset            = 0x99 // perform reset
+	cmdWriteEnable      = 0x06 // write-enable memory
+	cmdWriteDisable     = 0x04 // write-protect memory
+	cmdEraseSector      = 0x20 // erase a sector of memory
+	cmdEraseBlock       = 0xD8 // erase a block of memory
+	cmdEraseChip        = 0xC7 // erase the entire chip
+	cmdReadSFDP         = 0x5A // read the SFDP table
+	cmdEnter4Byte       = 0xB7 // switch to 4-byte addresses
+	cmdRead4            = 0x13 // read memory using a 4-byte address
+	cmdQuadRead4        = 0x6C // quad read using a 4-byte address
+	cmdPageProgram4     = 0x12 // write a page of memory using a 4-byte address
+	cmdQuadPageProgram4 = 0x34 // quad page program using a 4-byte address
+	cmdEraseSector4     = 0x21 // erase a sector using a 4-byte address
+	cmdEraseBlock4      = 0xDC // erase a block using a 4-byte address
+	cmdSuspend          = 0x75 // suspend an erase or program operation
+	cmdResume           = 0x7A // resume a suspended operation
+	cmdReadSecurity     = 0x48 // read a security register
+	cmdProgramSecurity  = 0x42 // program a security register
+	cmdEraseSecurity    = 0x44 // erase a security register
 )
 
 type Error uint8
@@ -390,6 +496,7 @@ const (
 	ErrInvalidClockSpeed Error = iota
 	ErrInvalidAddrRange
 	ErrWaitExpired
+	ErrNoSFDP
 )
 
 func (err Error) Error() string {
@@ -400,6 +507,8 @@ func (err Error) Error() string {
 		return "flash: invalid address range"
 	case ErrWaitExpired:
 		return "flash: wait until ready expired"
+	case ErrNoSFDP:
+		return "flash: device has no SFDP table"
 	default:
 		return "flash: unspecified error"
 	}
@@ -0,0 +1,113 @@
+package flash
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+// newScripted returns a 32 MiB device in the given 4-byte address mode, on a
+// bus with a scripted chip.
+func newScripted(c *qt.C, mode FourByteMode) (*Device, *tester.SPIScript) {
+	bus := tester.NewSPIBus(c)
+	chip := tester.NewSPIScript(c)
+	dev := New(bus, bus.AddDevice(chip))
+	dev.attrs = Attrs{
+		TotalSize:    32 << 20,
+		FourByteMode: mode,
+		EraseTypes: [4]EraseType{
+			{Size: 4 << 10, Cmd: cmdEraseSector, Cmd4: cmdEraseSector4},
+			{Size: 32 << 10, Cmd: 0x52},
+		},
+	}
+	dev.trans.setAddressMode(mode)
+	return dev, chip
+}
+
+// expectReady expects a status read of a device that is ready.
+func expectReady(chip *tester.SPIScript) {
+	chip.Expect([]byte{cmdReadStatus, 0xFF}, nil)
+}
+
+func TestAddressBytes(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		mode FourByteMode
+		// The commands and addresses of a read, a page program and a sector
+		// erase at 0x01020304, a 32 KiB erase at 0x8000 and a read of
+		// security register 1.
+		read, program, erase, erase32, security []byte
+	}{{
+		name:     "commands",
+		mode:     FourByteCommands,
+		read:     []byte{cmdRead4, 0x01, 0x02, 0x03, 0x04},
+		program:  []byte{cmdPageProgram4, 0x01, 0x02, 0x03, 0x04},
+		erase:    []byte{cmdEraseSector4, 0x01, 0x02, 0x00, 0x00},
+		erase32:  []byte{0x52, 0x00, 0x80, 0x00},
+		security: []byte{cmdReadSecurity, 0x00, 0x10, 0x00},
+	}, {
+		name:     "enter",
+		mode:     FourByteEnter,
+		read:     []byte{cmdRead, 0x01, 0x02, 0x03, 0x04},
+		program:  []byte{cmdPageProgram, 0x01, 0x02, 0x03, 0x04},
+		erase:    []byte{cmdEraseSector, 0x01, 0x02, 0x00, 0x00},
+		erase32:  []byte{0x52, 0x00, 0x00, 0x80, 0x00},
+		security: []byte{cmdReadSecurity, 0x00, 0x00, 0x10, 0x00},
+	}, {
+		name:     "always",
+		mode:     FourByteAlways,
+		read:     []byte{cmdRead, 0x01, 0x02, 0x03, 0x04},
+		program:  []byte{cmdPageProgram, 0x01, 0x02, 0x03, 0x04},
+		erase:    []byte{cmdEraseSector, 0x01, 0x02, 0x00, 0x00},
+		erase32:  []byte{0x52, 0x00, 0x00, 0x80, 0x00},
+		security: []byte{cmdReadSecurity, 0x00, 0x00, 0x10, 0x00},
+	}, {
+		name:     "none",
+		mode:     FourByteNone,
+		read:     []byte{cmdRead, 0x02, 0x03, 0x04},
+		program:  []byte{cmdPageProgram, 0x02, 0x03, 0x04},
+		erase:    []byte{cmdEraseSector, 0x02, 0x00, 0x00},
+		erase32:  []byte{0x52, 0x00, 0x80, 0x00},
+		security: []byte{cmdReadSecurity, 0x00, 0x10, 0x00},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			c := qt.New(t)
+			dev, chip := newScripted(c, test.mode)
+
+			expectReady(chip)
+			chip.Expect(append(test.read, 0xFF), append(make([]byte, len(test.read)), 0xAB))
+			expectReady(chip)
+			chip.Expect([]byte{cmdWriteEnable}, nil)
+			chip.Expect(append(test.program, 0x55), nil)
+			expectReady(chip)
+			chip.Expect([]byte{cmdWriteEnable}, nil)
+			chip.Expect(test.erase, nil)
+			expectReady(chip)
+			chip.Expect([]byte{cmdWriteEnable}, nil)
+			chip.Expect(test.erase32, nil)
+			expectReady(chip)
+			chip.Expect(append(test.security, 0xFF, 0xFF), nil)
+
+			buf := make([]byte, 1)
+			_, err := dev.ReadAt(buf, 0x01020304)
+			c.Assert(err, qt.IsNil)
+			c.Assert(buf[0], qt.Equals, byte(0xAB))
+			_, err = dev.WriteAt([]byte{0x55}, 0x01020304)
+			c.Assert(err, qt.IsNil)
+			c.Assert(dev.EraseSector(0x01020304/(4<<10)), qt.IsNil)
+			c.Assert(dev.erase(dev.attrs.EraseTypes[1], 0x8000), qt.IsNil)
+			c.Assert(dev.ReadSecurityRegister(1, buf, 0), qt.IsNil)
+			chip.Done()
+		})
+	}
+}
+
+func TestEraseWithoutFourByteCommand(t *testing.T) {
+	c := qt.New(t)
+	dev, chip := newScripted(c, FourByteCommands)
+	// The 32 KiB erase type has no 4-byte command, it can't reach the memory
+	// above 16 MiB.
+	c.Assert(dev.erase(dev.attrs.EraseTypes[1], 0x01008000), qt.Equals, ErrInvalidAddrRange)
+	chip.Done()
+}
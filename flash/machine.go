@@ -0,0 +1,26 @@
+//go:build tinygo
+
+package flash
+
+import (
+	"machine"
+)
+
+// NewSPI returns a pointer to a flash device that uses a SPI peripheral to
+// communicate with a serial memory chip. Configure sets up the SPI peripheral
+// and changes its clock frequency as needed.
+func NewSPI(spi *machine.SPI, sdo, sdi, sck, cs machine.Pin) *Device {
+	cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	dev := New(spi, cs)
+	dev.trans.(*spiTransport).setFrequency = func(hz uint32) {
+		spi.Configure(machine.SPIConfig{
+			Frequency: hz,
+			SDI:       sdi,
+			SDO:       sdo,
+			SCK:       sck,
+			LSBFirst:  false,
+			Mode:      0,
+		})
+	}
+	return dev
+}
@@ -0,0 +1,247 @@
+package flash
+
+import "time"
+
+// Bits of status register 1 and 2.
+const (
+	statusBusy     = 0x01 // status register 1: erase or write in progress
+	statusBPMask   = 0x7C // status register 1: BP0-BP2, TB and SEC
+	statusTB       = 0x20 // status register 1: protect from the bottom
+	status2LB1     = 0x08 // status register 2: lock bit of security register 1
+	status2Suspend = 0x80 // status register 2: erase or program suspended
+)
+
+// setAddressMode switches the device and the transport to 4-byte addresses
+// if the device needs them.
+func (dev *Device) setAddressMode() error {
+	switch dev.attrs.FourByteMode {
+	case FourByteEnterWriteEnable:
+		if err := dev.WriteEnable(); err != nil {
+			return err
+		}
+		fallthrough
+	case FourByteEnter:
+		if err := dev.trans.runCommand(cmdEnter4Byte); err != nil {
+			return err
+		}
+	}
+	dev.trans.setAddressMode(dev.attrs.FourByteMode)
+	return nil
+}
+
+// readStatus2 reads status register 2, or returns 0 if the device only has a
+// single status register.
+func (dev *Device) readStatus2() (byte, error) {
+	if dev.attrs.SingleStatusByte {
+		return 0, nil
+	}
+	return dev.ReadStatus2()
+}
+
+// writeStatus writes status registers 1 and 2 and waits for the write to
+// finish.
+func (dev *Device) writeStatus(status, status2 byte) error {
+	if err := dev.WaitUntilReady(); err != nil {
+		return err
+	}
+	if err := dev.WriteEnable(); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case dev.attrs.SingleStatusByte:
+		err = dev.trans.writeCommand(cmdWriteStatus, []byte{status})
+	case dev.attrs.WriteStatusSplit:
+		if err = dev.trans.writeCommand(cmdWriteStatus, []byte{status}); err != nil {
+			return err
+		}
+		if err = dev.WaitUntilReady(); err != nil {
+			return err
+		}
+		if err = dev.WriteEnable(); err != nil {
+			return err
+		}
+		err = dev.trans.writeCommand(cmdWriteStatus2, []byte{status2})
+	default:
+		err = dev.trans.writeCommand(cmdWriteStatus, []byte{status, status2})
+	}
+	if err != nil {
+		return err
+	}
+	return dev.WaitUntilReady()
+}
+
+// BlockProtection returns the block protect bits of status register 1 (bits
+// 2 to 6, which are BP0-BP2, TB and SEC on most devices).
+func (dev *Device) BlockProtection() (uint8, error) {
+	status, err := dev.ReadStatus()
+	return status & statusBPMask, err
+}
+
+// SetBlockProtection writes the block protect bits of status register 1
+// (bits 2 to 6), leaving the other bits unchanged. Which memory is protected
+// by which bits differs between manufacturers, see the datasheet or use
+// ProtectBottom. The setting is non-volatile.
+//
+// Devices that have HasSectorProtection set in their Attrs are unprotected
+// by Configure.
+func (dev *Device) SetBlockProtection(bits uint8) error {
+	status, err := dev.ReadStatus()
+	if err != nil {
+		return err
+	}
+	status2, err := dev.readStatus2()
+	if err != nil {
+		return err
+	}
+	return dev.writeStatus(status&^statusBPMask|bits&statusBPMask, status2)
+}
+
+// ProtectBottom write-protects the first size bytes of memory, for example to
+// protect a bootloader. Passing 0 removes the protection. The size must be
+// the total size of the device or 1/64, 1/32, 1/16, 1/8, 1/4 or 1/2 of it.
+//
+// This uses the block protection scheme with three BP bits and a TB bit
+// found on most Winbond and GigaDevice chips up to 16 MiB, such as the
+// W25Q16 to W25Q128 and the GD25Q series.
+func (dev *Device) ProtectBottom(size uint32) error {
+	total := uint32(dev.Size())
+	var bits uint8
+	switch {
+	case size == 0:
+	case size == total:
+		bits = 7 << 2
+	default:
+		bp := uint8(1)
+		for bp < 7 && total>>(7-bp) < size {
+			bp++
+		}
+		if bp == 7 || total>>(7-bp) != size {
+			return ErrInvalidAddrRange
+		}
+		bits = bp<<2 | statusTB
+	}
+	return dev.SetBlockProtection(bits)
+}
+
+// securityAddr returns the address of byte off of security register reg.
+func securityAddr(reg, off, n int) (uint32, error) {
+	if reg < 1 || reg > 3 || off < 0 || off+n > 256 {
+		return 0, ErrInvalidAddrRange
+	}
+	return uint32(reg)<<12 | uint32(off), nil
+}
+
+// ReadSecurityRegister reads len(buf) bytes starting at offset off from one of
+// the three 256-byte one time programmable security registers (1 to 3).
+// These are commonly used to store a serial number or calibration data.
+func (dev *Device) ReadSecurityRegister(reg int, buf []byte, off int) error {
+	addr, err := securityAddr(reg, off, len(buf))
+	if err != nil {
+		return err
+	}
+	if err := dev.WaitUntilReady(); err != nil {
+		return err
+	}
+	return dev.trans.readAddressed(cmdReadSecurity, addr, buf)
+}
+
+// WriteSecurityRegister writes data at offset off into security register reg
+// (1 to 3). The register must have been erased, and not locked.
+func (dev *Device) WriteSecurityRegister(reg int, data []byte, off int) error {
+	addr, err := securityAddr(reg, off, len(data))
+	if err != nil {
+		return err
+	}
+	if err := dev.WaitUntilReady(); err != nil {
+		return err
+	}
+	if err := dev.WriteEnable(); err != nil {
+		return err
+	}
+	if err := dev.trans.writeAddressed(cmdProgramSecurity, addr, data); err != nil {
+		return err
+	}
+	return dev.WaitUntilReady()
+}
+
+// EraseSecurityRegister erases security register reg (1 to 3).
+func (dev *Device) EraseSecurityRegister(reg int) error {
+	addr, err := securityAddr(reg, 0, 0)
+	if err != nil {
+		return err
+	}
+	if err := dev.WaitUntilReady(); err != nil {
+		return err
+	}
+	if err := dev.WriteEnable(); err != nil {
+		return err
+	}
+	if err := dev.trans.eraseCommand(cmdEraseSecurity, addr, false); err != nil {
+		return err
+	}
+	return dev.WaitUntilReady()
+}
+
+// LockSecurityRegister permanently write-protects security register reg (1
+// to 3). This can't be undone.
+func (dev *Device) LockSecurityRegister(reg int) error {
+	if _, err := securityAddr(reg, 0, 0); err != nil {
+		return err
+	}
+	status, err := dev.ReadStatus()
+	if err != nil {
+		return err
+	}
+	status2, err := dev.readStatus2()
+	if err != nil {
+		return err
+	}
+	return dev.writeStatus(status, status2|status2LB1<<uint(reg-1))
+}
+
+// Suspend suspends the erase or program operation in progress, so that other
+// parts of the memory can be read. Erases started with EraseSector,
+// EraseBlock or EraseBlocks don't wait for the erase to finish, so they can be
+// suspended. Call Resume to continue the operation.
+func (dev *Device) Suspend() error {
+	status, err := dev.ReadStatus()
+	if err != nil || status&statusBusy == 0 {
+		return err
+	}
+	cmd := dev.attrs.SuspendCmd
+	if cmd == 0 {
+		cmd = cmdSuspend
+	}
+	if err := dev.trans.runCommand(cmd); err != nil {
+		return err
+	}
+	// The device takes up to a few tens of microseconds to suspend.
+	expire := time.Now().UnixNano() + int64(1*time.Millisecond)
+	for status&statusBusy != 0 {
+		if time.Now().UnixNano() > expire {
+			return ErrWaitExpired
+		}
+		time.Sleep(10 * time.Microsecond)
+		if status, err = dev.ReadStatus(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resume resumes a suspended erase or program operation. It does nothing if
+// no operation is suspended.
+func (dev *Device) Resume() error {
+	cmd := dev.attrs.ResumeCmd
+	if cmd == 0 {
+		cmd = cmdResume
+	}
+	return dev.trans.runCommand(cmd)
+}
+
+// Suspended reports whether an erase or program operation is suspended.
+func (dev *Device) Suspended() (bool, error) {
+	status2, err := dev.readStatus2()
+	return status2&status2Suspend != 0, err
+}
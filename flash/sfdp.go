@@ -0,0 +1,264 @@
+package flash
+
+import "encoding/binary"
+
+// SFDP (Serial Flash Discoverable Parameters, JEDEC JESD216) is a table that
+// most NOR flash chips made in the last decade can report about themselves.
+// It describes the capacity, the supported erase sizes and commands, how to
+// enable quad mode and how to use 4-byte addresses.
+
+const (
+	sfdpSignature = 0x50444653 // "SFDP"
+
+	// Parameter table IDs (MSB << 8 | LSB).
+	sfdpBasicTable     = 0xff00
+	sfdp4ByteAddrTable = 0xff84
+
+	// Number of DWORDs of the basic table that are used below.
+	sfdpBasicDwords = 16
+)
+
+// FourByteMode describes how a device uses 4-byte addresses, which are needed
+// to access memory above 16 MiB.
+type FourByteMode uint8
+
+const (
+	// FourByteNone means the device only uses 3-byte addresses.
+	FourByteNone FourByteMode = iota
+
+	// FourByteCommands means the device has dedicated commands that take a
+	// 4-byte address, such as 0x13 (read), 0x12 (page program) and 0x21
+	// (sector erase).
+	FourByteCommands
+
+	// FourByteEnter means the device switches to 4-byte addresses for all
+	// commands after command 0xB7.
+	FourByteEnter
+
+	// FourByteEnterWriteEnable is like FourByteEnter, but the device needs a
+	// write enable command before 0xB7.
+	FourByteEnterWriteEnable
+
+	// FourByteAlways means the device always uses 4-byte addresses.
+	FourByteAlways
+)
+
+// EraseType is an erase size supported by a device, with the commands to
+// erase it.
+type EraseType struct {
+	Size uint32
+	Cmd  byte // command with a 3-byte address
+	Cmd4 byte // command with a 4-byte address, 0 if there is none
+}
+
+// ReadSFDP reads the SFDP table of the device and returns the attributes that
+// can be derived from it. It returns ErrNoSFDP if the device doesn't have an
+// SFDP table.
+//
+// SFDP doesn't describe the maximum clock speed, the startup time, sector
+// protection or quad page programming, so these fields are left zero.
+func (dev *Device) ReadSFDP() (Attrs, error) {
+	id, err := dev.ReadJEDEC()
+	if err != nil {
+		return Attrs{}, err
+	}
+	return dev.readSFDP(Attrs{JedecID: id})
+}
+
+// readSFDP returns known with the fields that the SFDP table describes
+// replaced by those of the table.
+func (dev *Device) readSFDP(known Attrs) (Attrs, error) {
+	if err := dev.WaitUntilReady(); err != nil {
+		return Attrs{}, err
+	}
+	return parseSFDP(known, dev.trans.readSFDP)
+}
+
+// parseSFDP derives the attributes of a device from its SFDP table, which is
+// read using read. The attributes that the table doesn't describe are those
+// of known.
+func parseSFDP(known Attrs, read func(addr uint32, buf []byte) error) (Attrs, error) {
+	le := binary.LittleEndian
+	var buf [8]byte
+	if err := read(0, buf[:]); err != nil {
+		return Attrs{}, err
+	}
+	if le.Uint32(buf[0:]) != sfdpSignature || buf[5] != 1 {
+		return Attrs{}, ErrNoSFDP
+	}
+	headers := int(buf[6]) + 1
+
+	// Find the parameter tables, using the newest revision of each.
+	var basicAddr, basicLen, addr4Addr, addr4Len uint32
+	var basicRev uint16
+	for i := 0; i < headers; i++ {
+		if err := read(uint32(8+8*i), buf[:]); err != nil {
+			return Attrs{}, err
+		}
+		table := uint16(buf[7])<<8 | uint16(buf[0])
+		rev := uint16(buf[2])<<8 | uint16(buf[1])
+		length := uint32(buf[3])
+		ptr := le.Uint32(buf[4:]) & 0xffffff
+		switch {
+		case table == sfdpBasicTable && buf[2] == 1 && (basicAddr == 0 || rev >= basicRev):
+			basicAddr, basicLen, basicRev = ptr, length, rev
+		case table == sfdp4ByteAddrTable:
+			addr4Addr, addr4Len = ptr, length
+		}
+	}
+	if basicAddr == 0 || basicLen < 9 {
+		return Attrs{}, ErrNoSFDP
+	}
+
+	// Read the basic table. Older chips (JESD216 before revision A) only
+	// have 9 DWORDs, the rest reads as zero.
+	var dw [sfdpBasicDwords]uint32
+	n := basicLen
+	if n > sfdpBasicDwords {
+		n = sfdpBasicDwords
+	}
+	raw := make([]byte, 4*n)
+	if err := read(basicAddr, raw); err != nil {
+		return Attrs{}, err
+	}
+	for i := range dw[:n] {
+		dw[i] = le.Uint32(raw[4*i:])
+	}
+
+	attrs := known
+	attrs.SupportsFastRead = true // 0x0B is mandatory for SFDP devices
+	attrs.TotalSize = 0
+	attrs.FourByteMode = FourByteNone
+	attrs.SupportsQSPI = false
+
+	// DWORD 2: memory density in bits.
+	if dw[1]&(1<<31) == 0 {
+		attrs.TotalSize = (dw[1] + 1) / 8
+	} else if bits := dw[1] &^ (1 << 31); bits >= 3 && bits < 35 {
+		attrs.TotalSize = uint32(uint64(1) << bits / 8)
+	}
+	if attrs.TotalSize == 0 {
+		return Attrs{}, ErrNoSFDP
+	}
+
+	// DWORD 1: address bytes and 1-1-4 fast read support. DWORD 3 has the
+	// 1-1-4 opcode and wait states; the driver uses 0x6B with 8 dummy cycles.
+	switch (dw[0] >> 17) & 0x3 {
+	case 1:
+		attrs.FourByteMode = FourByteEnter
+	case 2:
+		attrs.FourByteMode = FourByteAlways
+	}
+	if dw[0]&(1<<22) != 0 {
+		dummy := (dw[2] >> 16) & 0x1f
+		mode := (dw[2] >> 21) & 0x7
+		attrs.SupportsQSPI = dw[2]>>24 == cmdQuadRead && dummy+mode == 8
+	}
+
+	// DWORDs 8 and 9: up to four erase types.
+	var erase [4]EraseType
+	for i := 0; i < 4; i++ {
+		v := dw[7+i/2] >> (16 * uint(i%2))
+		size, cmd := v&0xff, byte(v>>8)
+		if size == 0 || size > 31 {
+			continue
+		}
+		erase[i] = EraseType{Size: 1 << size, Cmd: cmd}
+	}
+	if erase == ([4]EraseType{}) && (dw[0]&0x3) == 1 {
+		// Only DWORD 1 tells about the 4 KiB erase command.
+		erase[0] = EraseType{Size: 4096, Cmd: byte(dw[0] >> 8)}
+	}
+	if erase != ([4]EraseType{}) {
+		attrs.EraseTypes = erase
+	}
+
+	// DWORD 11: page size.
+	if basicLen >= 11 {
+		if shift := (dw[10] >> 4) & 0xf; shift != 0 {
+			attrs.PageSize = 1 << shift
+		}
+	}
+
+	// DWORDs 12 and 13: erase suspend and resume commands.
+	if basicLen >= 13 && dw[11]&(1<<31) == 0 {
+		attrs.SuspendCmd = byte(dw[12] >> 24)
+		attrs.ResumeCmd = byte(dw[12] >> 16)
+	}
+
+	// DWORD 15: Quad Enable Requirements.
+	if basicLen >= 15 && attrs.SupportsQSPI {
+		attrs.SingleStatusByte = false
+		attrs.WriteStatusSplit = false
+		switch (dw[14] >> 20) & 0x7 {
+		case 1, 4, 5:
+			// QE is bit 1 of status register 2, written together with status
+			// register 1 using 0x01.
+			attrs.QuadEnableBitMask = 0x02
+		case 2:
+			// QE is bit 6 of status register 1.
+			attrs.QuadEnableBitMask = 0x40
+			attrs.SingleStatusByte = true
+		case 6:
+			// QE is bit 1 of status register 2, written using 0x31.
+			attrs.QuadEnableBitMask = 0x02
+			attrs.WriteStatusSplit = true
+		default:
+			// Either there is no QE bit, or it is bit 7 of status register 2
+			// which is accessed using commands this driver doesn't support.
+			attrs.QuadEnableBitMask = 0
+		}
+	}
+
+	// DWORD 16: methods to enter 4-byte address mode.
+	if basicLen >= 16 && attrs.FourByteMode == FourByteEnter {
+		switch enter := dw[15] >> 24; {
+		case enter&(1<<5) != 0:
+			attrs.FourByteMode = FourByteCommands
+		case enter&(1<<0) != 0:
+			attrs.FourByteMode = FourByteEnter
+		case enter&(1<<1) != 0:
+			attrs.FourByteMode = FourByteEnterWriteEnable
+		case enter&(1<<6) != 0:
+			attrs.FourByteMode = FourByteAlways
+		default:
+			// Only methods this driver doesn't support, like an extended
+			// address register.
+			attrs.FourByteMode = FourByteNone
+		}
+	}
+
+	// The 4-byte address instruction table lists the 4-byte erase commands.
+	// Without it, assume the usual commands.
+	if attrs.FourByteMode == FourByteCommands {
+		for i, e := range attrs.EraseTypes {
+			switch e.Size {
+			case 4 << 10:
+				attrs.EraseTypes[i].Cmd4 = 0x21
+			case 32 << 10:
+				attrs.EraseTypes[i].Cmd4 = 0x5C
+			case 64 << 10:
+				attrs.EraseTypes[i].Cmd4 = 0xDC
+			}
+		}
+	}
+	if attrs.FourByteMode == FourByteCommands && addr4Addr != 0 && addr4Len >= 2 {
+		if err := read(addr4Addr, buf[:]); err != nil {
+			return Attrs{}, err
+		}
+		supported, cmds := le.Uint32(buf[0:]), le.Uint32(buf[4:])
+		// The table replaces the usual commands.
+		for i := range attrs.EraseTypes {
+			attrs.EraseTypes[i].Cmd4 = 0
+			if attrs.EraseTypes[i].Size != 0 && supported&(1<<(9+uint(i))) != 0 {
+				attrs.EraseTypes[i].Cmd4 = byte(cmds >> (8 * uint(i)))
+			}
+		}
+	}
+
+	// Devices up to 16 MiB don't need 4-byte addresses.
+	if attrs.TotalSize <= 1<<24 {
+		attrs.FourByteMode = FourByteNone
+	}
+	return attrs, nil
+}
@@ -0,0 +1,156 @@
+package flash
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// sfdpReader returns a function that reads an SFDP table from the bytes of a
+// dump. Past its end, the table reads as 0xFF.
+func sfdpReader(dump []byte) func(addr uint32, buf []byte) error {
+	return func(addr uint32, buf []byte) error {
+		for i := range buf {
+			buf[i] = 0xFF
+			if a := int(addr) + i; a < len(dump) {
+				buf[i] = dump[a]
+			}
+		}
+		return nil
+	}
+}
+
+// The SFDP table of a Winbond W25Q128JV (16 MiB), as read from the chip.
+var w25q128jvSFDP = []byte{
+	0x53, 0x46, 0x44, 0x50, 0x05, 0x01, 0x00, 0xFF,
+	0x00, 0x05, 0x01, 0x10, 0x80, 0x00, 0x00, 0xFF,
+	0x80: 0xE5, 0x20, 0xF9, 0xFF, 0xFF, 0xFF, 0xFF, 0x07,
+	0x44, 0xEB, 0x08, 0x6B, 0x08, 0x3B, 0x42, 0xBB,
+	0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00,
+	0xFF, 0xFF, 0x40, 0xEB, 0x0C, 0x20, 0x0F, 0x52,
+	0x10, 0xD8, 0x00, 0x00, 0x36, 0x02, 0xA6, 0x00,
+	0x82, 0xEA, 0x14, 0xC9, 0xE9, 0x63, 0x76, 0x33,
+	0x7A, 0x75, 0x7A, 0x75, 0xF7, 0xA2, 0xD5, 0x5C,
+	0x19, 0xF7, 0x4D, 0xFF, 0xE9, 0x30, 0xF8, 0x80,
+}
+
+// sfdpTable builds an SFDP dump with a basic parameter table and, if addr4 is
+// not nil, a 4-byte address instruction table.
+func sfdpTable(basic []uint32, addr4 []uint32) []byte {
+	le := binary.LittleEndian
+	dump := make([]byte, 0x100)
+	le.PutUint32(dump[0:], sfdpSignature)
+	dump[4], dump[5] = 0x06, 0x01 // revision 1.6
+	// Basic table header at 0x08, table at 0x80.
+	copy(dump[8:], []byte{0x00, 0x06, 0x01, byte(len(basic)), 0x80, 0x00, 0x00, 0xFF})
+	for i, v := range basic {
+		le.PutUint32(dump[0x80+4*i:], v)
+	}
+	if addr4 != nil {
+		dump[6] = 1 // two parameter headers
+		copy(dump[16:], []byte{0x84, 0x00, 0x01, byte(len(addr4)), 0xC0, 0x00, 0x00, 0xFF})
+		for i, v := range addr4 {
+			le.PutUint32(dump[0xC0+4*i:], v)
+		}
+	}
+	return dump
+}
+
+func TestParseSFDP(t *testing.T) {
+	c := qt.New(t)
+	id := JedecID{0xEF, 0x40, 0x18}
+	attrs, err := parseSFDP(Attrs{JedecID: id}, sfdpReader(w25q128jvSFDP))
+	c.Assert(err, qt.IsNil)
+	c.Assert(attrs, qt.DeepEquals, Attrs{
+		TotalSize:         16 << 20,
+		JedecID:           id,
+		QuadEnableBitMask: 0x02,
+		SupportsFastRead:  true,
+		SupportsQSPI:      true,
+		PageSize:          256,
+		EraseTypes: [4]EraseType{
+			{Size: 4 << 10, Cmd: 0x20},
+			{Size: 32 << 10, Cmd: 0x52},
+			{Size: 64 << 10, Cmd: 0xD8},
+		},
+		SuspendCmd: 0x75,
+		ResumeCmd:  0x7A,
+	})
+	c.Assert(attrs.FourByteMode, qt.Equals, FourByteNone)
+
+	// No SFDP table.
+	_, err = parseSFDP(Attrs{JedecID: id}, sfdpReader(nil))
+	c.Assert(err, qt.Equals, ErrNoSFDP)
+}
+
+func TestParseSFDPMerge(t *testing.T) {
+	c := qt.New(t)
+	// What SFDP doesn't describe comes from the Identifier. The status
+	// register layout comes from DWORD 15 of the table.
+	known := W25Q128JVSQ()
+	known.HasSectorProtection = true
+	known.SingleStatusByte = true
+	attrs, err := parseSFDP(known, sfdpReader(w25q128jvSFDP))
+	c.Assert(err, qt.IsNil)
+	c.Assert(attrs.JedecID, qt.Equals, known.JedecID)
+	c.Assert(attrs.StartUp, qt.Equals, known.StartUp)
+	c.Assert(attrs.MaxClockSpeedMHz, qt.Equals, known.MaxClockSpeedMHz)
+	c.Assert(attrs.SupportsQSPIWrites, qt.Equals, known.SupportsQSPIWrites)
+	c.Assert(attrs.HasSectorProtection, qt.IsTrue)
+	c.Assert(attrs.SingleStatusByte, qt.IsFalse)
+	c.Assert(attrs.QuadEnableBitMask, qt.Equals, uint8(0x02))
+
+	// A JESD216 table of 9 DWORDs, without page size or quad enable
+	// requirements.
+	old := Attrs{
+		JedecID:           JedecID{0x1F, 0x45, 0x01},
+		StartUp:           10 * time.Millisecond,
+		PageSize:          128,
+		QuadEnableBitMask: 0x40,
+		SingleStatusByte:  true,
+	}
+	dw := make([]uint32, 9)
+	copy(dw, []uint32{0xFFF920E5, 0x007FFFFF, 0x6B08EB44, 0xBB423B08, 0xFFFFFFFE, 0x0000FFFF, 0xEB40FFFF, 0x520F200C, 0x0000D810})
+	attrs, err = parseSFDP(old, sfdpReader(sfdpTable(dw, nil)))
+	c.Assert(err, qt.IsNil)
+	c.Assert(attrs.TotalSize, qt.Equals, uint32(1<<20))
+	c.Assert(attrs.PageSize, qt.Equals, uint32(128))
+	c.Assert(attrs.QuadEnableBitMask, qt.Equals, uint8(0x40))
+	c.Assert(attrs.SingleStatusByte, qt.IsTrue)
+	c.Assert(attrs.StartUp, qt.Equals, 10*time.Millisecond)
+}
+
+func TestParseSFDPFourByte(t *testing.T) {
+	c := qt.New(t)
+	// A 32 MiB chip with 3- or 4-byte addresses, which has dedicated 4-byte
+	// commands, and 4-byte erase commands for 4 KiB and 64 KiB only.
+	basic := []uint32{
+		0xFFFB20E5, 0x0FFFFFFF, 0x6B08EB44, 0xBB423B08,
+		0xFFFFFFFE, 0x0000FFFF, 0xEB40FFFF, 0x520F200C,
+		0x0000D810, 0x00A60236, 0xC914EA82, 0x337663E9,
+		0x757A757A, 0x5CD5A2F7, 0xFF4DF719, 0xA1F830E9,
+	}
+	addr4 := []uint32{0x00000AFB, 0xFFDC5C21}
+	attrs, err := parseSFDP(Attrs{}, sfdpReader(sfdpTable(basic, addr4)))
+	c.Assert(err, qt.IsNil)
+	c.Assert(attrs.TotalSize, qt.Equals, uint32(32<<20))
+	c.Assert(attrs.FourByteMode, qt.Equals, FourByteCommands)
+	c.Assert(attrs.EraseTypes, qt.DeepEquals, [4]EraseType{
+		{Size: 4 << 10, Cmd: 0x20, Cmd4: 0x21},
+		{Size: 32 << 10, Cmd: 0x52},
+		{Size: 64 << 10, Cmd: 0xD8, Cmd4: 0xDC},
+	})
+
+	// Without the 4-byte address instruction table, the usual commands.
+	attrs, err = parseSFDP(Attrs{}, sfdpReader(sfdpTable(basic, nil)))
+	c.Assert(err, qt.IsNil)
+	c.Assert(attrs.EraseTypes[1], qt.Equals, EraseType{Size: 32 << 10, Cmd: 0x52, Cmd4: 0x5C})
+
+	// A chip that enters 4-byte mode with 0xB7.
+	basic[15] = 0x81F830E9
+	attrs, err = parseSFDP(Attrs{}, sfdpReader(sfdpTable(basic, nil)))
+	c.Assert(err, qt.IsNil)
+	c.Assert(attrs.FourByteMode, qt.Equals, FourByteEnter)
+}
@@ -57,6 +57,30 @@ const (
 		(8 << sam.QSPI_INSTRFRAME_DUMMYLEN_Pos) |
 		(sam.QSPI_INSTRFRAME_TFRTYPE_READMEMORY << sam.QSPI_INSTRFRAME_TFRTYPE_Pos)
 
+	// Instruction frame to read the SFDP table or security registers, which
+	// use single bit transfers and 8 dummy cycles
+	iframeReadAddressed = 0x0 |
+		sam.QSPI_INSTRFRAME_WIDTH_SINGLE_BIT_SPI |
+		sam.QSPI_INSTRFRAME_ADDRLEN_24BITS |
+		sam.QSPI_INSTRFRAME_INSTREN |
+		sam.QSPI_INSTRFRAME_DATAEN |
+		sam.QSPI_INSTRFRAME_ADDREN |
+		(8 << sam.QSPI_INSTRFRAME_DUMMYLEN_Pos) |
+		(sam.QSPI_INSTRFRAME_TFRTYPE_READMEMORY << sam.QSPI_INSTRFRAME_TFRTYPE_Pos)
+
+	// Instruction frame to write data to an address using single bit
+	// transfers
+	iframeWriteAddressed = 0x0 |
+		sam.QSPI_INSTRFRAME_WIDTH_SINGLE_BIT_SPI |
+		sam.QSPI_INSTRFRAME_ADDRLEN_24BITS |
+		sam.QSPI_INSTRFRAME_INSTREN |
+		sam.QSPI_INSTRFRAME_ADDREN |
+		sam.QSPI_INSTRFRAME_DATAEN |
+		(sam.QSPI_INSTRFRAME_TFRTYPE_WRITEMEMORY << sam.QSPI_INSTRFRAME_TFRTYPE_Pos)
+
+	// Added to an instruction frame to send 4-byte addresses
+	iframeAddr32 = sam.QSPI_INSTRFRAME_ADDRLEN_32BITS << sam.QSPI_INSTRFRAME_ADDRLEN_Pos
+
 	// Instruction frame for running a command that requires parameter data
 	iframeWriteCommand = 0x0 |
 		sam.QSPI_INSTRFRAME_WIDTH_SINGLE_BIT_SPI |
@@ -89,9 +113,12 @@ type qspiTransport struct {
 	d1  machine.Pin
 	d2  machine.Pin
 	d3  machine.Pin
+
+	addr4 bool // send 4-byte addresses
+	cmds4 bool // use the dedicated 4-byte address commands
 }
 
-func (q qspiTransport) configure(config *DeviceConfig) {
+func (q *qspiTransport) configure(config *DeviceConfig) {
 
 	// enable main clocks
 	sam.MCLK.APBCMASK.SetBits(sam.MCLK_APBCMASK_QSPI_)
@@ -121,11 +148,11 @@ func (q qspiTransport) configure(config *DeviceConfig) {
 	sam.QSPI.CTRLA.SetBits(sam.QSPI_CTRLA_ENABLE)
 }
 
-func (q qspiTransport) supportQuadMode() bool {
+func (q *qspiTransport) supportQuadMode() bool {
 	return true
 }
 
-func (q qspiTransport) setClockSpeed(hz uint32) error {
+func (q *qspiTransport) setClockSpeed(hz uint32) error {
 	// The clock speed for the QSPI peripheral is controlled by a divider, so
 	// we can't set the requested speed exactly. Instead we will increment the
 	// divider until the speed is less than or equal to the speed requested.
@@ -138,13 +165,13 @@ func (q qspiTransport) setClockSpeed(hz uint32) error {
 	return ErrInvalidClockSpeed
 }
 
-func (q qspiTransport) runCommand(cmd byte) (err error) {
+func (q *qspiTransport) runCommand(cmd byte) (err error) {
 	q.runInstruction(cmd, iframeRunCommand)
 	q.endTransfer()
 	return
 }
 
-func (q qspiTransport) readCommand(cmd byte, buf []byte) (err error) {
+func (q *qspiTransport) readCommand(cmd byte, buf []byte) (err error) {
 	q.disableAndClearCache()
 	q.runInstruction(cmd, iframeReadCommand)
 	q.readInto(buf, 0)
@@ -153,19 +180,68 @@ func (q qspiTransport) readCommand(cmd byte, buf []byte) (err error) {
 	return
 }
 
-func (q qspiTransport) readMemory(addr uint32, buf []byte) (err error) {
+func (q *qspiTransport) setAddressMode(mode FourByteMode) {
+	// With dedicated 4-byte commands, the other commands still take 3-byte
+	// addresses.
+	q.addr4 = mode == FourByteEnter || mode == FourByteEnterWriteEnable || mode == FourByteAlways
+	q.cmds4 = mode == FourByteCommands
+}
+
+// addrLen returns the address length bits for an instruction frame, with a
+// 4-byte address if addr4 is set.
+func addrLen(addr4 bool) uint32 {
+	if addr4 {
+		return iframeAddr32
+	}
+	return 0
+}
+
+func (q *qspiTransport) readMemory(addr uint32, buf []byte) (err error) {
+	// Note that only the first 16MiB can be mapped into the address space.
 	if (addr + uint32(len(buf))) > (qspi_AHB_HI - qspi_AHB_LO) {
 		return ErrInvalidAddrRange
 	}
+	cmd := byte(cmdQuadRead)
+	if q.cmds4 {
+		cmd = cmdQuadRead4
+	}
 	q.disableAndClearCache()
-	q.runInstruction(cmdQuadRead, iframeReadMemory)
+	q.runInstruction(cmd, iframeReadMemory|addrLen(q.addr4 || q.cmds4))
 	q.readInto(buf, addr)
 	q.endTransfer()
 	q.enableCache()
 	return
 }
 
-func (q qspiTransport) writeCommand(cmd byte, data []byte) (err error) {
+func (q *qspiTransport) readSFDP(addr uint32, buf []byte) (err error) {
+	// SFDP always uses 3-byte addresses.
+	q.disableAndClearCache()
+	q.runInstruction(cmdReadSFDP, iframeReadAddressed)
+	q.readInto(buf, addr)
+	q.endTransfer()
+	q.enableCache()
+	return
+}
+
+func (q *qspiTransport) readAddressed(cmd byte, addr uint32, buf []byte) (err error) {
+	q.disableAndClearCache()
+	q.runInstruction(cmd, iframeReadAddressed|addrLen(q.addr4))
+	q.readInto(buf, addr)
+	q.endTransfer()
+	q.enableCache()
+	return
+}
+
+func (q *qspiTransport) writeAddressed(cmd byte, addr uint32, data []byte) (err error) {
+	q.disableAndClearCache()
+	q.runInstruction(cmd, iframeWriteAddressed|addrLen(q.addr4))
+	q.writeFrom(data, addr)
+	q.endTransfer()
+	q.enableCache()
+	return
+}
+
+func (q *qspiTransport) writeCommand(cmd byte, data []byte) (err error) {
 	var dataen uint32
 	if len(data) > 0 {
 		dataen = sam.QSPI_INSTRFRAME_DATAEN
@@ -178,52 +254,56 @@ func (q qspiTransport) writeCommand(cmd byte, data []byte) (err error) {
 	return
 }
 
-func (q qspiTransport) writeMemory(addr uint32, data []byte) (err error) {
+func (q *qspiTransport) writeMemory(addr uint32, data []byte) (err error) {
 	if (addr + uint32(len(data))) > (qspi_AHB_HI - qspi_AHB_LO) {
 		return ErrInvalidAddrRange
 	}
+	cmd := byte(cmdQuadPageProgram)
+	if q.cmds4 {
+		cmd = cmdQuadPageProgram4
+	}
 	q.disableAndClearCache()
-	q.runInstruction(cmdQuadPageProgram, iframeWriteMemory)
+	q.runInstruction(cmd, iframeWriteMemory|addrLen(q.addr4 || q.cmds4))
 	q.writeFrom(data, addr)
 	q.endTransfer()
 	q.enableCache()
 	return
 }
 
-func (q qspiTransport) eraseCommand(cmd byte, addr uint32) (err error) {
+func (q *qspiTransport) eraseCommand(cmd byte, addr uint32, cmd4 bool) (err error) {
 	q.disableAndClearCache()
 	sam.QSPI.INSTRADDR.Set(addr)
-	q.runInstruction(cmd, iframeEraseCommand)
+	q.runInstruction(cmd, iframeEraseCommand|addrLen(q.addr4 || cmd4))
 	q.endTransfer()
 	q.enableCache()
 	return
 }
 
-func (q qspiTransport) runInstruction(cmd byte, iframe uint32) {
+func (q *qspiTransport) runInstruction(cmd byte, iframe uint32) {
 	sam.QSPI.INSTRCTRL.Set(uint32(cmd))
 	sam.QSPI.INSTRFRAME.Set(iframe)
 	sam.QSPI.INSTRFRAME.Get() // dummy read for synchronization, as per datasheet
 }
 
-func (q qspiTransport) enableCache() {
+func (q *qspiTransport) enableCache() {
 	sam.CMCC.CTRL.SetBits(sam.CMCC_CTRL_CEN)
 }
 
-func (q qspiTransport) disableAndClearCache() {
+func (q *qspiTransport) disableAndClearCache() {
 	sam.CMCC.CTRL.ClearBits(sam.CMCC_CTRL_CEN)
 	for sam.CMCC.SR.HasBits(sam.CMCC_SR_CSTS) {
 	}
 	sam.CMCC.MAINT0.SetBits(sam.CMCC_MAINT0_INVALL)
 }
 
-func (q qspiTransport) endTransfer() {
+func (q *qspiTransport) endTransfer() {
 	sam.QSPI.CTRLA.Set(sam.QSPI_CTRLA_ENABLE | sam.QSPI_CTRLA_LASTXFER)
 	for !sam.QSPI.INTFLAG.HasBits(sam.QSPI_INTFLAG_INSTREND) {
 	}
 	sam.QSPI.INTFLAG.Set(sam.QSPI_INTFLAG_INSTREND)
 }
 
-func (q qspiTransport) readInto(buf []byte, addr uint32) {
+func (q *qspiTransport) readInto(buf []byte, addr uint32) {
 	var ptr = qspi_AHB_LO + uintptr(addr)
 	for i := range buf {
 		buf[i] = volatile.LoadUint8((*uint8)(unsafe.Pointer(ptr)))
@@ -238,7 +318,7 @@ func (q qspiTransport) readInto(buf []byte, addr uint32) {
 	*/
 }
 
-func (q qspiTransport) writeFrom(buf []byte, addr uint32) {
+func (q *qspiTransport) writeFrom(buf []byte, addr uint32) {
 	var ptr = qspi_AHB_LO + uintptr(addr)
 	for i := range buf {
 		volatile.StoreUint8((*uint8)(unsafe.Pointer(ptr)), buf[i])
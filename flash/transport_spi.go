@@ -1,7 +1,7 @@
 package flash
 
 import (
-	"machine"
+	"tinygo.org/x/drivers"
 )
 
 type transport interface {
@@ -11,39 +11,42 @@ type transport interface {
 	runCommand(cmd byte) (err error)
 	readCommand(cmd byte, rsp []byte) (err error)
 	writeCommand(cmd byte, data []byte) (err error)
-	eraseCommand(cmd byte, address uint32) (err error)
+	eraseCommand(cmd byte, address uint32, cmd4 bool) (err error)
 	readMemory(addr uint32, rsp []byte) (err error)
 	writeMemory(addr uint32, data []byte) (err error)
+	readSFDP(addr uint32, rsp []byte) (err error)
+	readAddressed(cmd byte, addr uint32, rsp []byte) (err error)
+	writeAddressed(cmd byte, addr uint32, data []byte) (err error)
+	setAddressMode(mode FourByteMode)
 }
 
-// NewSPI returns a pointer to a flash device that uses a SPI peripheral to
-// communicate with a serial memory chip.
-func NewSPI(spi *machine.SPI, sdo, sdi, sck, cs machine.Pin) *Device {
+// New returns a pointer to a flash device connected to the given SPI bus,
+// whose chip select pin is cs. The bus must already be configured. See NewSPI
+// for devices connected to a machine.SPI.
+func New(bus drivers.SPI, cs drivers.PinOutput) *Device {
 	return &Device{
 		trans: &spiTransport{
-			spi: spi,
-			sdo: sdo,
-			sdi: sdi,
-			sck: sck,
+			spi: bus,
 			ss:  cs,
 		},
 	}
 }
 
 type spiTransport struct {
-	spi *machine.SPI
-	sdo machine.Pin
-	sdi machine.Pin
-	sck machine.Pin
-	ss  machine.Pin
+	spi drivers.SPI
+	ss  drivers.PinOutput
+
+	// setFrequency changes the clock frequency of the bus, if it can be.
+	setFrequency func(hz uint32)
+
+	addr4 bool // send 4-byte addresses
+	cmds4 bool // use the dedicated 4-byte address commands
 }
 
 func (tr *spiTransport) configure(config *DeviceConfig) {
 	// Configure spi bus
 	tr.setClockSpeed(5000000)
 
-	// Configure chip select pin
-	tr.ss.Configure(machine.PinConfig{Mode: machine.PinOutput})
 	tr.ss.High()
 }
 
@@ -53,14 +56,9 @@ func (tr *spiTransport) setClockSpeed(hz uint32) error {
 	if hz > 24*1e6 {
 		hz = 24 * 1e6
 	}
-	tr.spi.Configure(machine.SPIConfig{
-		Frequency: hz,
-		SDI:       tr.sdi,
-		SDO:       tr.sdo,
-		SCK:       tr.sck,
-		LSBFirst:  false,
-		Mode:      0,
-	})
+	if tr.setFrequency != nil {
+		tr.setFrequency(hz)
+	}
 	return nil
 }
 
@@ -102,16 +100,29 @@ func (tr *spiTransport) writeCommand(cmd byte, data []byte) (err error) {
 	return
 }
 
-func (tr *spiTransport) eraseCommand(cmd byte, address uint32) (err error) {
+// eraseCommand runs an erase command. cmd4 is set for the dedicated 4-byte
+// address commands.
+func (tr *spiTransport) eraseCommand(cmd byte, address uint32, cmd4 bool) (err error) {
 	tr.ss.Low()
-	err = tr.sendAddress(cmd, address)
+	err = tr.sendAddress(cmd, address, tr.addr4 || cmd4)
 	tr.ss.High()
 	return
 }
 
+func (tr *spiTransport) setAddressMode(mode FourByteMode) {
+	// With dedicated 4-byte commands, the other commands still take 3-byte
+	// addresses.
+	tr.addr4 = mode == FourByteEnter || mode == FourByteEnterWriteEnable || mode == FourByteAlways
+	tr.cmds4 = mode == FourByteCommands
+}
+
 func (tr *spiTransport) readMemory(addr uint32, rsp []byte) (err error) {
+	cmd := byte(cmdRead)
+	if tr.cmds4 {
+		cmd = cmdRead4
+	}
 	tr.ss.Low()
-	if err = tr.sendAddress(cmdRead, addr); err == nil {
+	if err = tr.sendAddress(cmd, addr, tr.addr4 || tr.cmds4); err == nil {
 		err = tr.readInto(rsp)
 	}
 	tr.ss.High()
@@ -119,16 +130,55 @@ func (tr *spiTransport) readMemory(addr uint32, rsp []byte) (err error) {
 }
 
 func (tr *spiTransport) writeMemory(addr uint32, data []byte) (err error) {
+	if tr.cmds4 {
+		return tr.write(cmdPageProgram4, addr, true, data)
+	}
+	return tr.write(cmdPageProgram, addr, tr.addr4, data)
+}
+
+func (tr *spiTransport) writeAddressed(cmd byte, addr uint32, data []byte) (err error) {
+	return tr.write(cmd, addr, tr.addr4, data)
+}
+
+func (tr *spiTransport) write(cmd byte, addr uint32, addr4 bool, data []byte) (err error) {
 	tr.ss.Low()
-	if err = tr.sendAddress(cmdPageProgram, addr); err == nil {
+	if err = tr.sendAddress(cmd, addr, addr4); err == nil {
 		err = tr.writeFrom(data)
 	}
 	tr.ss.High()
 	return
 }
 
-func (tr *spiTransport) sendAddress(cmd byte, addr uint32) error {
+func (tr *spiTransport) readSFDP(addr uint32, rsp []byte) (err error) {
+	// SFDP always uses 3-byte addresses.
+	return tr.read(cmdReadSFDP, addr, false, rsp)
+}
+
+// readAddressed runs a read command with an address and 8 dummy cycles.
+func (tr *spiTransport) readAddressed(cmd byte, addr uint32, rsp []byte) (err error) {
+	return tr.read(cmd, addr, tr.addr4, rsp)
+}
+
+func (tr *spiTransport) read(cmd byte, addr uint32, addr4 bool, rsp []byte) (err error) {
+	tr.ss.Low()
+	if err = tr.sendAddress(cmd, addr, addr4); err == nil {
+		// 8 dummy cycles
+		_, err = tr.spi.Transfer(0xFF)
+	}
+	if err == nil {
+		err = tr.readInto(rsp)
+	}
+	tr.ss.High()
+	return
+}
+
+// sendAddress sends a command and its address, which is 4 bytes long if addr4
+// is set and 3 bytes otherwise.
+func (tr *spiTransport) sendAddress(cmd byte, addr uint32, addr4 bool) error {
 	_, err := tr.spi.Transfer(byte(cmd))
+	if err == nil && addr4 {
+		_, err = tr.spi.Transfer(byte((addr >> 24) & 0xFF))
+	}
 	if err == nil {
 		_, err = tr.spi.Transfer(byte((addr >> 16) & 0xFF))
 	}
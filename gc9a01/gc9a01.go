@@ -5,12 +5,13 @@ package gc9a01 // import "tinygo.org/x/drivers/gc9a01"
 
 import (
 	"image/color"
-	"machine"
 	"time"
 
 	"errors"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+	"tinygo.org/x/drivers/pixel"
 )
 
 // Rotation controls the rotation used by the display.
@@ -22,10 +23,10 @@ type FrameRate uint8
 // Device wraps an SPI connection.
 type Device struct {
 	bus             drivers.SPI
-	dcPin           machine.Pin
-	resetPin        machine.Pin
-	csPin           machine.Pin
-	blPin           machine.Pin
+	dcPin           drivers.PinOutput
+	resetPin        drivers.PinOutput
+	csPin           drivers.PinOutput
+	blPin           drivers.PinOutput
 	width           int16
 	height          int16
 	columnOffsetCfg int16
@@ -52,11 +53,11 @@ type Config struct {
 }
 
 // New creates a new ST7789 connection. The SPI wire must already be configured.
-func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin machine.Pin) Device {
-	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	blPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin drivers.PinOutput) Device {
+	legacy.ConfigurePin(resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(blPin, drivers.PinModeOutput)
 	return Device{
 		bus:      bus,
 		resetPin: resetPin,
@@ -151,6 +152,32 @@ func (d *Device) Display() error {
 	return nil
 }
 
+// PixelFormat returns the format of the pixels of DrawBitmap.
+func (d *Device) PixelFormat() pixel.Format {
+	return pixel.RGB565
+}
+
+// DrawBitmap copies an RGB565 image to the screen at given coordinates.
+func (d *Device) DrawBitmap(x, y int16, img pixel.Image) error {
+	if img.Format() != pixel.RGB565 {
+		return pixel.ErrFormat
+	}
+	w, h := img.Size()
+	k, j := d.Size()
+	if x < 0 || y < 0 || w <= 0 || h <= 0 ||
+		x >= k || (x+w) > k || y >= j || (y+h) > j {
+		return errors.New("rectangle coordinates outside display area")
+	}
+	d.setWindow(x, y, w, h)
+	d.Tx(img.Bytes(), false)
+	return nil
+}
+
+// FillRect fills a rectangle at a given coordinates with a color.
+func (d *Device) FillRect(x, y, width, height int16, c color.RGBA) error {
+	return d.FillRectangle(x, y, width, height, c)
+}
+
 // FillRectangleWithBuffer fills buffer with a rectangle at a given coordinates.
 func (d *Device) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
 	h, w := d.Size()
@@ -291,6 +318,12 @@ func (d *Device) StopScroll() {
 	d.Command(NORON)
 }
 
+// Rotation returns drivers.Rotation0, as the orientation of the display is set
+// with Config.Orientation instead.
+func (d *Device) Rotation() drivers.Rotation {
+	return drivers.Rotation0
+}
+
 // RGBATo565 converts a color.RGBA to uint16 used in the display
 func RGBATo565(c color.RGBA) uint16 {
 	r, g, b, _ := c.RGBA()
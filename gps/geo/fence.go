@@ -0,0 +1,41 @@
+package geo
+
+// Fence is a geofence, an area that a position can be inside of.
+type Fence interface {
+	Contains(p Point) bool
+}
+
+// Circle is a geofence of all points within Radius meters of Center.
+type Circle struct {
+	Center Point
+	Radius float32
+}
+
+// Contains returns whether p is inside the circle. It uses ApproxDistanceTo,
+// which is accurate for circles of up to 10km.
+func (c Circle) Contains(p Point) bool {
+	return c.Center.ApproxDistanceTo(p) <= c.Radius
+}
+
+// Polygon is a geofence bounded by a polygon. Edges are straight lines in
+// latitude and longitude, which is accurate enough for fences of a few
+// kilometers. Polygons must not cross the 180th meridian.
+type Polygon []Point
+
+// Contains returns whether p is inside the polygon, using the even-odd rule.
+func (poly Polygon) Contains(p Point) bool {
+	inside := false
+	j := len(poly) - 1
+	for i := range poly {
+		a, b := poly[i], poly[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) {
+			// Longitude where the edge crosses the latitude of p.
+			lon := a.Longitude + (p.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+			if p.Longitude < lon {
+				inside = !inside
+			}
+		}
+		j = i
+	}
+	return inside
+}
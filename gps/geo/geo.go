@@ -0,0 +1,123 @@
+// Package geo provides geodesy helpers for GPS fixes: distance and bearing
+// between positions, geofences, a track recorder and a GPX writer.
+//
+// Positions are float32, as in gps.Fix. DistanceTo, BearingTo and Destination
+// use the great-circle formulas, which need the float64 trigonometry of the
+// math package to be accurate both across continents and for the few meters
+// between successive fixes. Circle fences, Track and Recorder only deal with
+// short distances and use ApproxDistanceTo instead, which is float32 only and
+// much cheaper on microcontrollers without a double precision FPU.
+package geo // import "tinygo.org/x/drivers/gps/geo"
+
+import (
+	"math"
+
+	"tinygo.org/x/drivers/gps"
+)
+
+// EarthRadius is the mean radius of the earth in meters.
+const EarthRadius = 6371008.8
+
+// Point is a position in degrees, north and east being positive.
+type Point struct {
+	Latitude  float32
+	Longitude float32
+}
+
+// FromFix returns the position of a fix.
+func FromFix(fix gps.Fix) Point {
+	return Point{Latitude: fix.Latitude, Longitude: fix.Longitude}
+}
+
+// Distance returns the great-circle distance in meters between two fixes.
+func Distance(a, b gps.Fix) float32 {
+	return FromFix(a).DistanceTo(FromFix(b))
+}
+
+// Bearing returns the initial bearing in degrees (0 to 360, clockwise from
+// north) to travel from fix a to fix b.
+func Bearing(a, b gps.Fix) float32 {
+	return FromFix(a).BearingTo(FromFix(b))
+}
+
+// DistanceTo returns the great-circle distance in meters from p to q.
+func (p Point) DistanceTo(q Point) float32 {
+	lat1, lon1 := p.radians()
+	lat2, lon2 := q.radians()
+	sinLat := math.Sin((lat2 - lat1) / 2)
+	sinLon := math.Sin((lon2 - lon1) / 2)
+	a := sinLat*sinLat + math.Cos(lat1)*math.Cos(lat2)*sinLon*sinLon
+	return float32(2 * EarthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a)))
+}
+
+// ApproxDistanceTo returns the distance in meters from p to q using the
+// equirectangular approximation. It only uses float32 math, and is within
+// 0.1% of DistanceTo for distances up to 10km.
+func (p Point) ApproxDistanceTo(q Point) float32 {
+	dlon := q.Longitude - p.Longitude
+	if dlon > 180 {
+		dlon -= 360
+	} else if dlon < -180 {
+		dlon += 360
+	}
+	x := dlon * cos32((p.Latitude+q.Latitude)/2*math.Pi/180)
+	y := q.Latitude - p.Latitude
+	return EarthRadius * math.Pi / 180 * sqrt32(x*x+y*y)
+}
+
+// BearingTo returns the initial bearing in degrees (0 to 360, clockwise from
+// north) to travel from p to q along a great circle.
+func (p Point) BearingTo(q Point) float32 {
+	lat1, lon1 := p.radians()
+	lat2, lon2 := q.radians()
+	dlon := lon2 - lon1
+	y := math.Sin(dlon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dlon)
+	return float32(normalize(degrees(math.Atan2(y, x))))
+}
+
+// Destination returns the point reached when travelling distance meters from
+// p with the given initial bearing in degrees.
+func (p Point) Destination(bearing, distance float32) Point {
+	lat1, lon1 := p.radians()
+	brng := radians(float64(bearing))
+	d := float64(distance) / EarthRadius
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brng))
+	lon2 := lon1 + math.Atan2(math.Sin(brng)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+	lon := normalize(degrees(lon2)+180) - 180
+	return Point{Latitude: float32(degrees(lat2)), Longitude: float32(lon)}
+}
+
+// cos32 returns the cosine of x, for x between -π/2 and π/2 (a latitude),
+// using its Taylor series. The error is below 1e-6.
+func cos32(x float32) float32 {
+	x2 := x * x
+	return 1 + x2*(-1.0/2+x2*(1.0/24+x2*(-1.0/720+x2*(1.0/40320+x2*(-1.0/3628800)))))
+}
+
+// sqrt32 returns the square root of x. LLVM turns the conversions around
+// math.Sqrt into a float32 square root.
+func sqrt32(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
+
+func (p Point) radians() (lat, lon float64) {
+	return radians(float64(p.Latitude)), radians(float64(p.Longitude))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// normalize returns deg in the range [0, 360).
+func normalize(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
@@ -0,0 +1,166 @@
+package geo
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/gps"
+)
+
+var (
+	bigBen  = Point{Latitude: 51.5007, Longitude: -0.1246}
+	liberty = Point{Latitude: 40.6892, Longitude: -74.0445}
+)
+
+func near(c *qt.C, got, want, tolerance float32) {
+	c.Helper()
+	if math.Abs(float64(got-want)) > float64(tolerance) {
+		c.Fatalf("got %v, want %v ± %v", got, want, tolerance)
+	}
+}
+
+func TestDistanceBearing(t *testing.T) {
+	c := qt.New(t)
+	near(c, bigBen.DistanceTo(liberty), 5574848, 1)
+	near(c, bigBen.BearingTo(liberty), 288.337, 0.001)
+	near(c, liberty.BearingTo(bigBen), 51.195, 0.001)
+	c.Assert(bigBen.DistanceTo(bigBen), qt.Equals, float32(0))
+
+	// Short distances are accurate to the resolution of float32 positions,
+	// which is about half a meter.
+	p := Point{Latitude: 51.1504364, Longitude: -114.0306778}
+	q := p.Destination(90, 25)
+	near(c, p.DistanceTo(q), 25, 0.5)
+	near(c, p.BearingTo(q), 90, 1)
+
+	// Destination wraps around the 180th meridian.
+	q = Point{Latitude: 0, Longitude: 179.9}.Destination(90, 22239)
+	near(c, q.Longitude, -179.9, 0.001)
+
+	// The approximation is close enough for fences and track points.
+	for _, d := range []float32{1, 25, 1000, 10000} {
+		for _, bearing := range []float32{0, 45, 90} {
+			q := p.Destination(bearing, d)
+			near(c, p.ApproxDistanceTo(q), p.DistanceTo(q), d/1000+0.01)
+		}
+	}
+	p, q = Point{Latitude: 0, Longitude: 179.9}, Point{Latitude: 0, Longitude: -179.9}
+	near(c, p.ApproxDistanceTo(q), p.DistanceTo(q), 0.1)
+
+	a := gps.Fix{Latitude: bigBen.Latitude, Longitude: bigBen.Longitude}
+	b := gps.Fix{Latitude: liberty.Latitude, Longitude: liberty.Longitude}
+	c.Assert(Distance(a, b), qt.Equals, bigBen.DistanceTo(liberty))
+	c.Assert(Bearing(a, b), qt.Equals, bigBen.BearingTo(liberty))
+}
+
+func TestFences(t *testing.T) {
+	c := qt.New(t)
+	var fence Fence = Circle{Center: bigBen, Radius: 100}
+	c.Assert(fence.Contains(bigBen.Destination(45, 99)), qt.IsTrue)
+	c.Assert(fence.Contains(bigBen.Destination(45, 101)), qt.IsFalse)
+
+	// A concave (L-shaped) polygon.
+	fence = Polygon{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 2},
+		{Latitude: 1, Longitude: 2},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 2, Longitude: 1},
+		{Latitude: 2, Longitude: 0},
+	}
+	c.Assert(fence.Contains(Point{Latitude: 0.5, Longitude: 1.5}), qt.IsTrue)
+	c.Assert(fence.Contains(Point{Latitude: 1.5, Longitude: 0.5}), qt.IsTrue)
+	c.Assert(fence.Contains(Point{Latitude: 1.5, Longitude: 1.5}), qt.IsFalse)
+	c.Assert(fence.Contains(Point{Latitude: -0.5, Longitude: 0.5}), qt.IsFalse)
+	c.Assert(Polygon{}.Contains(bigBen), qt.IsFalse)
+}
+
+func TestRecorder(t *testing.T) {
+	c := qt.New(t)
+	r := Recorder{MinDistance: 10}
+	var track Track
+	start := time.Date(2022, time.May, 13, 20, 35, 22, 0, time.UTC)
+	for i, d := range []float32{0, 3, 12, 15, 30, 31, 32, 50} {
+		p := bigBen.Destination(0, d)
+		fix := gps.Fix{Valid: true, Latitude: p.Latitude, Longitude: p.Longitude, Time: start.Add(time.Duration(i) * time.Second)}
+		if tp, ok := r.Add(fix); ok {
+			track = append(track, tp)
+		}
+	}
+	_, ok := r.Add(gps.Fix{Latitude: 1})
+	c.Assert(ok, qt.IsFalse)
+
+	c.Assert(track, qt.HasLen, 4)
+	c.Assert(track[1].Time, qt.Equals, start.Add(2*time.Second))
+	near(c, r.Distance, 50, 0.5)
+	near(c, track.Distance(), r.Distance, 0.001)
+
+	r.Reset()
+	c.Assert(r.Distance, qt.Equals, float32(0))
+	_, ok = r.Add(gps.Fix{Valid: true})
+	c.Assert(ok, qt.IsTrue)
+}
+
+const expectedGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="tinygo.org/x/drivers/gps/geo" xmlns="http://www.topografix.com/GPX/1/1">
+<wpt lat="51.5007" lon="-0.1246">
+  <name>Big Ben &amp; co</name>
+</wpt>
+<trk>
+  <name>walk</name>
+  <trkseg>
+    <trkpt lat="51.5007" lon="-0.1246">
+      <ele>12.5</ele>
+      <time>2022-05-13T20:35:22Z</time>
+    </trkpt>
+  </trkseg>
+  <trkseg>
+    <trkpt lat="40.6892" lon="-74.0445">
+      <ele>0</ele>
+    </trkpt>
+  </trkseg>
+</trk>
+</gpx>
+`
+
+func TestGPXWriter(t *testing.T) {
+	c := qt.New(t)
+	var buf bytes.Buffer
+	w := NewGPXWriter(&buf)
+	c.Assert(w.Waypoint(bigBen, "Big Ben & co"), qt.IsNil)
+	c.Assert(w.Track("walk"), qt.IsNil)
+	c.Assert(w.Point(TrackPoint{Point: bigBen, Altitude: 12.5, Time: time.Date(2022, time.May, 13, 20, 35, 22, 0, time.UTC)}), qt.IsNil)
+	c.Assert(w.Segment(), qt.IsNil)
+	c.Assert(w.Point(TrackPoint{Point: liberty}), qt.IsNil)
+	c.Assert(w.Waypoint(liberty, "late"), qt.Equals, ErrWaypointAfterTrack)
+	c.Assert(w.Close(), qt.IsNil)
+	c.Assert(buf.String(), qt.Equals, expectedGPX)
+
+	// The document is only ended once.
+	c.Assert(w.Close(), qt.Equals, ErrClosed)
+	c.Assert(w.Point(TrackPoint{Point: bigBen}), qt.Equals, ErrClosed)
+	c.Assert(buf.String(), qt.Equals, expectedGPX)
+}
+
+type failWriter struct{ n int }
+
+var errFull = errors.New("disk full")
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errFull
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestGPXWriterError(t *testing.T) {
+	c := qt.New(t)
+	w := NewGPXWriter(&failWriter{n: 2})
+	c.Assert(w.Point(TrackPoint{Point: bigBen}), qt.Equals, errFull)
+	c.Assert(w.Close(), qt.Equals, errFull)
+}
@@ -0,0 +1,189 @@
+package geo
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	gpxHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="tinygo.org/x/drivers/gps/geo" xmlns="http://www.topografix.com/GPX/1/1">
+`
+	gpxFooter = "</gpx>\n"
+)
+
+var (
+	// ErrWaypointAfterTrack is returned when a waypoint is written after a
+	// track, as GPX requires all waypoints to come first.
+	ErrWaypointAfterTrack = errors.New("geo: GPX waypoint after a track")
+
+	// ErrClosed is returned when writing to a closed GPXWriter.
+	ErrClosed = errors.New("geo: GPX writer is closed")
+)
+
+// GPXWriter writes tracks and waypoints in GPX 1.1 format, for example to a
+// file on an SD card. Track points are written as they are added, so a track
+// can be logged for as long as there is storage. The first error is kept and
+// returned by all further calls.
+type GPXWriter struct {
+	w       io.Writer
+	buf     []byte
+	err     error
+	started bool
+	tracks  bool // a track was written
+	closed  bool
+	inTrack bool
+	inSeg   bool
+}
+
+// NewGPXWriter returns a GPXWriter that writes to w.
+func NewGPXWriter(w io.Writer) *GPXWriter {
+	return &GPXWriter{w: w, buf: make([]byte, 0, 128)}
+}
+
+// Waypoint writes a named waypoint. Waypoints must be written before the
+// first track, or ErrWaypointAfterTrack is returned.
+func (g *GPXWriter) Waypoint(p Point, name string) error {
+	if g.closed {
+		return ErrClosed
+	}
+	if g.tracks {
+		return ErrWaypointAfterTrack
+	}
+	g.start()
+	b := g.appendPoint(g.buf[:0], "wpt", p)
+	b = append(b, ">\n  <name>"...)
+	b = appendEscaped(b, name)
+	b = append(b, "</name>\n</wpt>\n"...)
+	return g.write(b)
+}
+
+// Track starts a new track with the given name, ending the current one.
+func (g *GPXWriter) Track(name string) error {
+	if g.closed {
+		return ErrClosed
+	}
+	g.endTrack()
+	g.start()
+	b := append(g.buf[:0], "<trk>\n  <name>"...)
+	b = appendEscaped(b, name)
+	b = append(b, "</name>\n"...)
+	g.inTrack = true
+	g.tracks = true
+	return g.write(b)
+}
+
+// Segment starts a new segment in the current track, for example after
+// the GPS lost its fix.
+func (g *GPXWriter) Segment() error {
+	if g.closed {
+		return ErrClosed
+	}
+	if !g.inTrack {
+		return g.Track("")
+	}
+	g.endSegment()
+	return g.err
+}
+
+// Point adds a point to the current track segment, starting a track if
+// needed.
+func (g *GPXWriter) Point(p TrackPoint) error {
+	if g.closed {
+		return ErrClosed
+	}
+	if !g.inTrack {
+		g.Track("")
+	}
+	if !g.inSeg {
+		g.write(append(g.buf[:0], "  <trkseg>\n"...))
+		g.inSeg = true
+	}
+	b := append(g.buf[:0], "    "...)
+	b = g.appendPoint(b, "trkpt", p.Point)
+	b = append(b, ">\n      <ele>"...)
+	b = strconv.AppendFloat(b, float64(p.Altitude), 'f', -1, 32)
+	b = append(b, "</ele>\n"...)
+	if !p.Time.IsZero() {
+		b = append(b, "      <time>"...)
+		b = p.Time.UTC().AppendFormat(b, time.RFC3339)
+		b = append(b, "</time>\n"...)
+	}
+	b = append(b, "    </trkpt>\n"...)
+	return g.write(b)
+}
+
+// Close ends the GPX document. It doesn't close the underlying writer.
+// Closing it again returns ErrClosed.
+func (g *GPXWriter) Close() error {
+	if g.closed {
+		return ErrClosed
+	}
+	g.closed = true
+	g.endTrack()
+	g.start()
+	return g.write(append(g.buf[:0], gpxFooter...))
+}
+
+func (g *GPXWriter) start() {
+	if !g.started {
+		g.started = true
+		g.write(append(g.buf[:0], gpxHeader...))
+	}
+}
+
+func (g *GPXWriter) endSegment() {
+	if g.inSeg {
+		g.inSeg = false
+		g.write(append(g.buf[:0], "  </trkseg>\n"...))
+	}
+}
+
+func (g *GPXWriter) endTrack() {
+	g.endSegment()
+	if g.inTrack {
+		g.inTrack = false
+		g.write(append(g.buf[:0], "</trk>\n"...))
+	}
+}
+
+func (g *GPXWriter) appendPoint(b []byte, tag string, p Point) []byte {
+	b = append(b, '<')
+	b = append(b, tag...)
+	b = append(b, ` lat="`...)
+	b = strconv.AppendFloat(b, float64(p.Latitude), 'f', -1, 32)
+	b = append(b, `" lon="`...)
+	b = strconv.AppendFloat(b, float64(p.Longitude), 'f', -1, 32)
+	return append(b, '"')
+}
+
+func (g *GPXWriter) write(b []byte) error {
+	g.buf = b[:0]
+	if g.err == nil {
+		_, g.err = g.w.Write(b)
+	}
+	return g.err
+}
+
+// appendEscaped appends s with the XML special characters escaped.
+func appendEscaped(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '<':
+			b = append(b, "&lt;"...)
+		case '>':
+			b = append(b, "&gt;"...)
+		case '&':
+			b = append(b, "&amp;"...)
+		case '"':
+			b = append(b, "&quot;"...)
+		case '\'':
+			b = append(b, "&apos;"...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
@@ -0,0 +1,70 @@
+package geo
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers/gps"
+)
+
+// TrackPoint is a recorded position.
+type TrackPoint struct {
+	Point
+	Altitude float32
+	Time     time.Time
+}
+
+// Track is a sequence of recorded positions.
+type Track []TrackPoint
+
+// Distance returns the length of the track in meters.
+func (t Track) Distance() float32 {
+	var d float32
+	for i := 1; i < len(t); i++ {
+		d += t[i-1].ApproxDistanceTo(t[i].Point)
+	}
+	return d
+}
+
+// Recorder decimates a stream of fixes into track points, dropping fixes
+// that are closer than MinDistance meters to the last recorded point. This
+// keeps a track small when standing still, and removes most of the jitter of
+// the position.
+type Recorder struct {
+	// MinDistance is the minimum distance in meters between track points.
+	MinDistance float32
+
+	// Distance is the total distance between the recorded points in meters.
+	Distance float32
+
+	last    TrackPoint
+	started bool
+}
+
+// Add returns the track point for a fix, and whether it should be recorded.
+// Invalid fixes are never recorded.
+func (r *Recorder) Add(fix gps.Fix) (TrackPoint, bool) {
+	p := TrackPoint{
+		Point:    FromFix(fix),
+		Altitude: float32(fix.Altitude),
+		Time:     fix.Time,
+	}
+	if !fix.Valid {
+		return p, false
+	}
+	if r.started {
+		d := r.last.ApproxDistanceTo(p.Point)
+		if d < r.MinDistance {
+			return p, false
+		}
+		r.Distance += d
+	}
+	r.last = p
+	r.started = true
+	return p, true
+}
+
+// Reset forgets the recorded points, so that the next valid fix is recorded.
+func (r *Recorder) Reset() {
+	r.Distance = 0
+	r.started = false
+}
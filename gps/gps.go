@@ -18,6 +18,11 @@ var (
 	errInvalidGGASentence        = errors.New("invalid GGA NMEA sentence")
 	errInvalidRMCSentence        = errors.New("invalid RMC NMEA sentence")
 	errInvalidGLLSentence        = errors.New("invalid GLL NMEA sentence")
+	errInvalidGSASentence        = errors.New("invalid GSA NMEA sentence")
+	errInvalidGSVSentence        = errors.New("invalid GSV NMEA sentence")
+	errInvalidVTGSentence        = errors.New("invalid VTG NMEA sentence")
+	errInvalidZDASentence        = errors.New("invalid ZDA NMEA sentence")
+	errInvalidGSTSentence        = errors.New("invalid GST NMEA sentence")
 )
 
 type GPSError struct {
@@ -44,6 +49,7 @@ func (ge GPSError) Unwrap() error {
 
 const (
 	minimumNMEALength = 7
+	maximumNMEALength = 100 // 82 according to the standard, but not all devices care
 	startingDelimiter = '$'
 	checksumDelimiter = '*'
 )
@@ -52,18 +58,30 @@ const (
 type Device struct {
 	buffer   []byte
 	bufIdx   int
+	bufLen   int
 	sentence strings.Builder
+	ubx      []byte
+	deadline time.Time
 	uart     drivers.UART
 	bus      drivers.I2C
 	address  uint16
 }
 
+// Message is a message read from a GPS device, either an NMEA sentence or a
+// UBX message.
+type Message struct {
+	// Sentence is the NMEA sentence, or empty for a UBX message.
+	Sentence string
+
+	// UBX is the UBX message if Sentence is empty.
+	UBX UBXMessage
+}
+
 // NewUART creates a new UART GPS connection. The UART must already be configured.
 func NewUART(uart drivers.UART) Device {
 	return Device{
 		uart:     uart,
 		buffer:   make([]byte, bufferSize),
-		bufIdx:   bufferSize,
 		sentence: strings.Builder{},
 	}
 }
@@ -74,79 +92,164 @@ func NewI2C(bus drivers.I2C) Device {
 		bus:      bus,
 		address:  I2C_ADDRESS,
 		buffer:   make([]byte, bufferSize),
-		bufIdx:   bufferSize,
 		sentence: strings.Builder{},
 	}
 }
 
-// NextSentence returns the next valid NMEA sentence from the GPS device.
+// NextSentence returns the next valid NMEA sentence from the GPS device. UBX
+// messages are skipped, but the errors reading them are returned.
 func (gps *Device) NextSentence() (sentence string, err error) {
-	sentence = gps.readNextSentence()
-	if err = validSentence(sentence); err != nil {
-		return "", err
+	for {
+		msg, err := gps.Next()
+		if err != nil {
+			return "", err
+		}
+		if msg.Sentence == "" {
+			// UBX message.
+			continue
+		}
+		return msg.Sentence, nil
 	}
-	return sentence, nil
 }
 
-// readNextSentence returns the next sentence from the GPS device.
-func (gps *Device) readNextSentence() (sentence string) {
-	gps.sentence.Reset()
-	var b byte = ' '
-
-	for b != startingDelimiter {
-		b = gps.readNextByte()
+// Next returns the next NMEA sentence or UBX message from the GPS device.
+// The payload of a UBX message is only valid until the next call. If the
+// sentence or message is invalid, it is returned together with the error.
+func (gps *Device) Next() (Message, error) {
+	for {
+		b, err := gps.readByte()
+		if err != nil {
+			return Message{}, err
+		}
+		switch b {
+		case startingDelimiter:
+			sentence, err := gps.readSentence()
+			return Message{Sentence: sentence}, err
+		case ubxSync1:
+			b, err = gps.readByte()
+			if err != nil {
+				return Message{}, err
+			}
+			if b == ubxSync2 {
+				msg, err := gps.readUBX()
+				return Message{UBX: msg}, err
+			}
+			// Not a UBX message, look at the byte again.
+			gps.bufIdx--
+		}
 	}
+}
+
+// readSentence reads an NMEA sentence, after the starting delimiter.
+func (gps *Device) readSentence() (string, error) {
+	gps.sentence.Reset()
+	b := byte(startingDelimiter)
 
 	for b != checksumDelimiter {
+		if gps.sentence.Len() >= maximumNMEALength {
+			return gps.sentence.String(), errInvalidNMEASentenceLength
+		}
 		gps.sentence.WriteByte(b)
-		b = gps.readNextByte()
+		var err error
+		if b, err = gps.readByte(); err != nil {
+			return "", err
+		}
 	}
 	gps.sentence.WriteByte(b)
-	gps.sentence.WriteByte(gps.readNextByte())
-	gps.sentence.WriteByte(gps.readNextByte())
+	for i := 0; i < 2; i++ {
+		b, err := gps.readByte()
+		if err != nil {
+			return "", err
+		}
+		gps.sentence.WriteByte(b)
+	}
 
-	sentence = gps.sentence.String()
-	return sentence
+	sentence := gps.sentence.String()
+	return sentence, validSentence(sentence)
 }
 
-func (gps *Device) readNextByte() (b byte) {
-	gps.bufIdx += 1
-	if gps.bufIdx >= bufferSize {
-		gps.fillBuffer()
+// readByte returns the next byte from the GPS device, waiting until one is
+// available or the deadline has passed.
+func (gps *Device) readByte() (byte, error) {
+	for gps.bufIdx >= gps.bufLen {
+		if !gps.deadline.IsZero() && time.Now().After(gps.deadline) {
+			return 0, errTimeout
+		}
+		ok, err := gps.fillBuffer()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			time.Sleep(10 * time.Millisecond)
+		}
 	}
-	return gps.buffer[gps.bufIdx]
+	b := gps.buffer[gps.bufIdx]
+	gps.bufIdx++
+	return b, nil
 }
 
-func (gps *Device) fillBuffer() {
+// Poll feeds the data that the GPS device has available to the stream,
+// without waiting for more. It is the non-blocking alternative to Next: call
+// it regularly and handle the sentences in the handler of the stream.
+func (gps *Device) Poll(s *Stream) {
+	for {
+		if gps.bufIdx < gps.bufLen {
+			s.Write(gps.buffer[gps.bufIdx:gps.bufLen])
+			gps.bufIdx = gps.bufLen
+		}
+		if ok, err := gps.fillBuffer(); !ok || err != nil {
+			return
+		}
+	}
+}
+
+// fillBuffer reads the available data into the buffer. It returns false if
+// there was no data, and the error of the UART or I2C bus.
+func (gps *Device) fillBuffer() (bool, error) {
 	if gps.uart != nil {
-		gps.uartFillBuffer()
-	} else {
-		gps.i2cFillBuffer()
+		return gps.uartFillBuffer()
 	}
+	return gps.i2cFillBuffer()
 }
 
-func (gps *Device) uartFillBuffer() {
-	for gps.uart.Buffered() < bufferSize {
-		time.Sleep(100 * time.Millisecond)
+func (gps *Device) uartFillBuffer() (bool, error) {
+	n := gps.uart.Buffered()
+	if n == 0 {
+		return false, nil
+	}
+	if n > bufferSize {
+		n = bufferSize
+	}
+	n, err := gps.uart.Read(gps.buffer[0:n])
+	if err != nil {
+		return false, err
 	}
-	gps.uart.Read(gps.buffer[0:bufferSize])
 	gps.bufIdx = 0
+	gps.bufLen = n
+	return n > 0, nil
 }
 
-func (gps *Device) i2cFillBuffer() {
-	for gps.available() < bufferSize {
-		time.Sleep(100 * time.Millisecond)
+func (gps *Device) i2cFillBuffer() (bool, error) {
+	n, err := gps.available()
+	if n == 0 || err != nil {
+		return false, err
+	}
+	if n > bufferSize {
+		n = bufferSize
+	}
+	if err := gps.bus.Tx(gps.address, []byte{DATA_STREAM_REG}, gps.buffer[0:n]); err != nil {
+		return false, err
 	}
-	gps.bus.Tx(gps.address, []byte{DATA_STREAM_REG}, gps.buffer[0:bufferSize])
 	gps.bufIdx = 0
+	gps.bufLen = n
+	return true, nil
 }
 
 // Available returns how many bytes of GPS data are currently available.
-func (gps *Device) available() (available int) {
+func (gps *Device) available() (int, error) {
 	var lengthBytes [2]byte
-	gps.bus.Tx(gps.address, []byte{BYTES_AVAIL_REG}, lengthBytes[0:2])
-	available = int(lengthBytes[0])*256 + int(lengthBytes[1])
-	return available
+	err := gps.bus.Tx(gps.address, []byte{BYTES_AVAIL_REG}, lengthBytes[0:2])
+	return int(lengthBytes[0])*256 + int(lengthBytes[1]), err
 }
 
 // WriteBytes sends data/commands to the GPS device
@@ -0,0 +1,84 @@
+package gps
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestUARTSentences(t *testing.T) {
+	c := qt.New(t)
+	const (
+		gll = "$GPGLL,5109.0262317,N,11401.8407304,W,202725.00,A,D*79"
+		gga = "$GPGGA,115739.00,4158.8441367,N,09147.4416929,W,4,13,0.9,255.747,M,-32.00,M,01,0000*6E"
+	)
+	uart := tester.NewUART(c)
+	// The sentences arrive in pieces, split in the middle, after noise.
+	uart.Inject([]byte("\x00\xff" + gll[:20]))
+	uart.InjectAfter([]byte(gll[20:]+"\r\n"+gga[:40]), 20*time.Millisecond)
+	uart.InjectAfter([]byte(gga[40:]+"\r\n"), 20*time.Millisecond)
+	gps := NewUART(uart)
+	sentence, err := gps.NextSentence()
+	c.Assert(err, qt.IsNil)
+	c.Assert(sentence, qt.Equals, gll)
+	sentence, err = gps.NextSentence()
+	c.Assert(err, qt.IsNil)
+	c.Assert(sentence, qt.Equals, gga)
+
+	// A corrupted checksum.
+	uart.Inject([]byte(gll[:len(gll)-1] + "8\r\n"))
+	_, err = gps.NextSentence()
+	c.Assert(err, qt.ErrorMatches, ".*checksum.*")
+}
+
+func TestUARTConfigureUBX(t *testing.T) {
+	c := qt.New(t)
+	uart := tester.NewUART(c)
+	msg := CfgRate(200*time.Millisecond, 1)
+	ack := UBXMessage{Class: UBXClassACK, ID: UBXAckAck, Payload: []byte{msg.Class, msg.ID}}
+	uart.Expect(string(msg.Bytes()), string(ack.Bytes()), 300*time.Millisecond)
+	gps := NewUART(uart)
+	c.Assert(gps.ConfigureUBX(msg), qt.IsNil)
+	uart.Done()
+
+	// The module doesn't answer.
+	start := time.Now()
+	uart.Expect(string(msg.Bytes()), "", 0)
+	c.Assert(gps.ConfigureUBX(msg), qt.Equals, errUBXNoAck)
+	c.Assert(time.Since(start) >= ubxAckTimeout, qt.IsTrue)
+	uart.Done()
+}
+
+// failingBus is an I2C bus whose transactions fail.
+type failingBus struct {
+	err error
+}
+
+func (b failingBus) Tx(addr uint16, w, r []byte) error {
+	return b.err
+}
+
+func TestNextSentenceErrors(t *testing.T) {
+	c := qt.New(t)
+	errBus := errors.New("bus error")
+	gps := NewI2C(failingBus{errBus})
+	_, err := gps.NextSentence()
+	c.Assert(err, qt.Equals, errBus)
+
+	// UBX messages are skipped, unless they are corrupted.
+	const gll = "$GPGLL,5109.0262317,N,11401.8407304,W,202725.00,A,D*79"
+	ack := UBXMessage{Class: UBXClassACK, ID: UBXAckAck, Payload: []byte{0x06, 0x08}}.Bytes()
+	bad := append([]byte(nil), ack...)
+	bad[len(bad)-1]++
+	uart := tester.NewUART(c)
+	uart.Inject(append(append(ack, gll+"\r\n"...), bad...))
+	gps = NewUART(uart)
+	sentence, err := gps.NextSentence()
+	c.Assert(err, qt.IsNil)
+	c.Assert(sentence, qt.Equals, gll)
+	_, err = gps.NextSentence()
+	c.Assert(err, qt.ErrorMatches, ".*checksum.*")
+}
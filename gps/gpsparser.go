@@ -6,8 +6,15 @@ import (
 	"time"
 )
 
-// Parser for GPS NMEA sentences.
+// Parser for GPS NMEA sentences. It keeps a table of the satellites in view,
+// which is built from GSV and GSA sentences.
 type Parser struct {
+	sats    []Satellite // from complete GSV sequences
+	pending []Satellite // GSV sequence being received
+	next    int         // next expected GSV message number, 0 if none
+	gsvGNSS GNSS
+	gsvSig  uint8
+	used    []Satellite // used satellites from GSA sentences
 }
 
 // Fix is a GPS location fix
@@ -33,16 +40,58 @@ type Fix struct {
 	// Speed based on reported movement. Only returned for RMC sentences.
 	Speed float32
 
-	// Heading based on reported movement. Only returned for RMC sentences.
+	// Heading based on reported movement (course over ground, in degrees).
+	// Only returned for RMC and VTG sentences.
 	Heading float32
+
+	// Quality is the fix quality of GGA sentences: 0 for no fix, 1 for a
+	// GNSS fix, 2 for a differential fix, 4 for RTK fixed, 5 for RTK float
+	// and 6 for an estimated (dead reckoning) fix.
+	Quality uint8
+
+	// FixType is Fix2D or Fix3D for GSA sentences that report a fix, and
+	// FixNone otherwise.
+	FixType uint8
+
+	// HDOP, VDOP and PDOP are the horizontal, vertical and position dilution
+	// of precision. Lower is better; values above 5 indicate a poor fix. GSA
+	// sentences report all three, GGA sentences only HDOP.
+	HDOP float32
+	VDOP float32
+	PDOP float32
+
+	// SatellitesInView is the number of satellites in view of one satellite
+	// system, only returned for GSV sentences. See Parser.Satellites for
+	// details on the satellites.
+	SatellitesInView int16
+
+	// LatitudeError, LongitudeError and AltitudeError are the standard
+	// deviation of the position error in meters. Only returned for GST
+	// sentences.
+	LatitudeError  float32
+	LongitudeError float32
+	AltitudeError  float32
 }
 
+// Fix quality values in GGA sentences.
+const (
+	QualityInvalid   = 0
+	QualityGNSS      = 1
+	QualityDGPS      = 2
+	QualityRTKFixed  = 4
+	QualityRTKFloat  = 5
+	QualityEstimated = 6
+)
+
 // NewParser returns a GPS NMEA Parser.
 func NewParser() Parser {
 	return Parser{}
 }
 
-// Parse parses a NMEA sentence looking for fix info.
+// Parse parses a NMEA sentence looking for fix info. It understands GGA,
+// GLL, RMC, GSA, GSV, VTG, ZDA and GST sentences from any talker, such as GP
+// (GPS), GL (GLONASS), GA (Galileo), GB or BD (BeiDou) and GN (multiple
+// systems).
 func (parser *Parser) Parse(sentence string) (Fix, error) {
 	var fix Fix
 	if sentence == "" {
@@ -63,7 +112,9 @@ func (parser *Parser) Parse(sentence string) (Fix, error) {
 		fix.Time = findTime(fields[1])
 		fix.Latitude = findLatitude(fields[2], fields[3])
 		fix.Longitude = findLongitude(fields[4], fields[5])
+		fix.Quality = uint8(fieldInt(fields[6]))
 		fix.Satellites = findSatellites(fields[7])
+		fix.HDOP = fieldFloat(fields[8])
 		fix.Altitude = findAltitude(fields[9])
 		fix.Valid = (fix.Altitude != -99999) && (fix.Satellites > 0)
 
@@ -98,6 +149,91 @@ func (parser *Parser) Parse(sentence string) (Fix, error) {
 		date := findDate(fields[9])
 		fix.Time = fix.Time.AddDate(date.Year(), int(date.Month()), date.Day())
 
+		return fix, nil
+	case "GSA":
+		// https://docs.novatel.com/OEM7/Content/Logs/GPGSA.htm
+		// NMEA 4.10 adds the system ID as the last field.
+		fields := strings.Split(sentence, ",")
+		if len(fields) != 18 && len(fields) != 19 {
+			return fix, errInvalidGSASentence
+		}
+		fields[len(fields)-1] = stripChecksum(fields[len(fields)-1])
+
+		if t := fieldInt(fields[2]); t == 2 || t == 3 {
+			fix.FixType = uint8(t)
+		}
+		fix.PDOP = fieldFloat(fields[15])
+		fix.HDOP = fieldFloat(fields[16])
+		fix.VDOP = fieldFloat(fields[17])
+		fix.Valid = fix.FixType != FixNone
+
+		gnss, ok := talkerGNSS(sentence)
+		if len(fields) == 19 {
+			gnss, ok = systemGNSS(fieldHex(fields[18]))
+		}
+		parser.gsa(gnss, ok, fields[3:15])
+
+		return fix, nil
+	case "GSV":
+		// https://docs.novatel.com/OEM7/Content/Logs/GPGSV.htm
+		// NMEA 4.10 adds the signal ID as the last field.
+		fields := strings.Split(sentence, ",")
+		if len(fields) < 4 || (len(fields)-4)%4 > 1 {
+			return fix, errInvalidGSVSentence
+		}
+		fields[len(fields)-1] = stripChecksum(fields[len(fields)-1])
+
+		fix.SatellitesInView = int16(fieldInt(fields[3]))
+		if err := parser.gsv(sentence, fields); err != nil {
+			return fix, err
+		}
+
+		return fix, nil
+	case "VTG":
+		// https://docs.novatel.com/OEM7/Content/Logs/GPVTG.htm
+		fields := strings.Split(sentence, ",")
+		if len(fields) != 9 && len(fields) != 10 {
+			return fix, errInvalidVTGSentence
+		}
+		fields[len(fields)-1] = stripChecksum(fields[len(fields)-1])
+
+		fix.Heading = findHeading(fields[1])
+		fix.Speed = findSpeed(fields[5])
+		fix.Valid = len(fields) == 9 || (fields[9] != "" && fields[9][0] != 'N')
+
+		return fix, nil
+	case "ZDA":
+		// https://docs.novatel.com/OEM7/Content/Logs/GPZDA.htm
+		fields := strings.Split(sentence, ",")
+		if len(fields) != 7 {
+			return fix, errInvalidZDASentence
+		}
+		fields[len(fields)-1] = stripChecksum(fields[len(fields)-1])
+
+		tm := findTime(fields[1])
+		d, _ := strconv.ParseInt(fields[2], 10, 8)
+		m, _ := strconv.ParseInt(fields[3], 10, 8)
+		y, _ := strconv.ParseInt(fields[4], 10, 16)
+		fix.Valid = len(fields[1]) >= 6 && d > 0 && m > 0 && y > 0
+		if fix.Valid {
+			fix.Time = time.Date(int(y), time.Month(m), int(d), tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond(), time.UTC)
+		}
+
+		return fix, nil
+	case "GST":
+		// https://docs.novatel.com/OEM7/Content/Logs/GPGST.htm
+		fields := strings.Split(sentence, ",")
+		if len(fields) != 9 {
+			return fix, errInvalidGSTSentence
+		}
+		fields[len(fields)-1] = stripChecksum(fields[len(fields)-1])
+
+		fix.Time = findTime(fields[1])
+		fix.LatitudeError = fieldFloat(fields[6])
+		fix.LongitudeError = fieldFloat(fields[7])
+		fix.AltitudeError = fieldFloat(fields[8])
+		fix.Valid = fields[6] != ""
+
 		return fix, nil
 	}
 
@@ -210,3 +346,222 @@ func findHeading(val string) float32 {
 	}
 	return 0
 }
+
+// Satellites returns the satellites in view according to the last complete
+// sequence of GSV sentences of each satellite system and signal. Satellites
+// listed in the last GSA sentence of their system are marked as used. The
+// returned slice is only valid until the next call to Parse.
+func (parser *Parser) Satellites() []Satellite {
+	return parser.sats
+}
+
+// gsv adds the satellites of a GSV sentence to the sequence being received,
+// and replaces the satellites of the same system and signal once the
+// sequence is complete.
+func (parser *Parser) gsv(sentence string, fields []string) error {
+	total := fieldInt(fields[1])
+	num := fieldInt(fields[2])
+	if num < 1 || num > total {
+		return errInvalidGSVSentence
+	}
+	var signal uint8
+	if (len(fields)-4)%4 == 1 {
+		signal = uint8(fieldHex(fields[len(fields)-1]))
+	}
+	talker, talkerOK := talkerGNSS(sentence)
+	if num == 1 {
+		parser.pending = parser.pending[:0]
+		parser.gsvGNSS = talker
+		parser.gsvSig = signal
+	} else if num != parser.next {
+		// A sentence was lost, skip the rest of the sequence.
+		parser.next = 0
+		return nil
+	}
+	parser.next = num + 1
+
+	for i := 4; i+4 <= len(fields); i += 4 {
+		id := fieldInt(fields[i])
+		if id <= 0 {
+			continue
+		}
+		gnss := talker
+		if !talkerOK {
+			gnss = prnGNSS(id)
+		}
+		parser.pending = append(parser.pending, Satellite{
+			GNSS:      gnss,
+			ID:        uint16(id),
+			Signal:    signal,
+			Elevation: int8(fieldInt(fields[i+1])),
+			Azimuth:   int16(fieldInt(fields[i+2])),
+			SNR:       uint8(fieldInt(fields[i+3])),
+			Used:      parser.isUsed(gnss, uint16(id)),
+		})
+	}
+
+	if num == total {
+		// Replace the satellites of this system and signal.
+		sats := parser.sats[:0]
+		for _, sat := range parser.sats {
+			if !parser.inSequence(sat, talkerOK) {
+				sats = append(sats, sat)
+			}
+		}
+		parser.sats = append(sats, parser.pending...)
+		parser.next = 0
+	}
+	return nil
+}
+
+// inSequence reports whether sat belongs to the GSV sequence that was just
+// received.
+func (parser *Parser) inSequence(sat Satellite, talkerOK bool) bool {
+	if sat.Signal != parser.gsvSig {
+		return false
+	}
+	if talkerOK {
+		return sat.GNSS == parser.gsvGNSS
+	}
+	for _, p := range parser.pending {
+		if p.GNSS == sat.GNSS {
+			return true
+		}
+	}
+	return false
+}
+
+// gsa records the satellites used in the solution of one satellite system.
+// The system is not known (ok is false) for GN sentences of older receivers,
+// in which case it is derived from the satellite IDs.
+func (parser *Parser) gsa(gnss GNSS, ok bool, ids []string) {
+	var first Satellite
+	n := 0
+	used := parser.used
+	for _, f := range ids {
+		id := fieldInt(f)
+		if id <= 0 {
+			continue
+		}
+		sat := Satellite{GNSS: gnss, ID: uint16(id), Used: true}
+		if !ok {
+			sat.GNSS = prnGNSS(id)
+		}
+		if n == 0 {
+			// Forget the satellites previously used in this system.
+			first = sat
+			kept := used[:0]
+			for _, u := range used {
+				if u.GNSS != sat.GNSS {
+					kept = append(kept, u)
+				}
+			}
+			used = kept
+		}
+		used = append(used, sat)
+		n++
+	}
+	parser.used = used
+	if n == 0 {
+		return
+	}
+	for i, sat := range parser.sats {
+		if sat.GNSS == first.GNSS {
+			parser.sats[i].Used = parser.isUsed(sat.GNSS, sat.ID)
+		}
+	}
+}
+
+func (parser *Parser) isUsed(gnss GNSS, id uint16) bool {
+	for _, u := range parser.used {
+		if u.GNSS == gnss && u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// talkerGNSS returns the satellite system of the talker ID of a sentence. It
+// returns false for GN (multiple systems) and unknown talkers.
+func talkerGNSS(sentence string) (GNSS, bool) {
+	if len(sentence) < 3 {
+		return 0, false
+	}
+	switch sentence[1:3] {
+	case "GP":
+		return GNSSGPS, true
+	case "GL":
+		return GNSSGLONASS, true
+	case "GA":
+		return GNSSGalileo, true
+	case "GB", "BD":
+		return GNSSBeiDou, true
+	case "GQ", "QZ":
+		return GNSSQZSS, true
+	}
+	return 0, false
+}
+
+// systemGNSS returns the satellite system of an NMEA 4.10 system ID.
+func systemGNSS(id int) (GNSS, bool) {
+	switch id {
+	case 1:
+		return GNSSGPS, true
+	case 2:
+		return GNSSGLONASS, true
+	case 3:
+		return GNSSGalileo, true
+	case 4:
+		return GNSSBeiDou, true
+	case 5:
+		return GNSSQZSS, true
+	}
+	return 0, false
+}
+
+// prnGNSS returns the satellite system of a satellite ID as used in NMEA
+// sentences with the GN talker.
+func prnGNSS(id int) GNSS {
+	switch {
+	case id <= 32:
+		return GNSSGPS
+	case id <= 64, id >= 120 && id <= 158:
+		return GNSSSBAS
+	case id <= 96:
+		return GNSSGLONASS
+	case id >= 193 && id <= 200:
+		return GNSSQZSS
+	case id >= 201 && id <= 263, id >= 401 && id <= 437:
+		return GNSSBeiDou
+	case id >= 301 && id <= 336:
+		return GNSSGalileo
+	}
+	return GNSSGPS
+}
+
+// fieldInt returns the decimal integer of a field, or 0 if it is empty.
+func fieldInt(val string) int {
+	v, _ := strconv.ParseInt(val, 10, 32)
+	return int(v)
+}
+
+// fieldHex returns the hexadecimal integer of a field, such as the system and
+// signal IDs of NMEA 4.11, or 0 if it is empty.
+func fieldHex(val string) int {
+	v, _ := strconv.ParseInt(val, 16, 32)
+	return int(v)
+}
+
+// fieldFloat returns the number of a field, or 0 if it is empty.
+func fieldFloat(val string) float32 {
+	v, _ := strconv.ParseFloat(val, 32)
+	return float32(v)
+}
+
+// stripChecksum removes the checksum from the last field of a sentence.
+func stripChecksum(val string) string {
+	if i := strings.IndexByte(val, checksumDelimiter); i >= 0 {
+		return val[:i]
+	}
+	return val
+}
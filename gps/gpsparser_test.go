@@ -12,7 +12,7 @@ func TestParseUnknownSentence(t *testing.T) {
 
 	p := NewParser()
 
-	val := "$GPGSV,3,1,09,07,14,317,22,08,31,284,25,10,32,133,39,16,85,232,29*7F"
+	val := "$GPTXT,01,01,02,ANTSTATUS=OK*3B"
 	_, err := p.Parse(val)
 	c.Assert(err.Error(), qt.Contains, "unsupported NMEA sentence type")
 }
@@ -101,3 +101,154 @@ func TestTime(t *testing.T) {
 	tm = findTime(val)
 	c.Assert(tm, qt.Equals, time.Date(0, 0, 0, 12, 43, 26, 2752, time.UTC))
 }
+
+func TestParseGSA(t *testing.T) {
+	c := qt.New(t)
+
+	p := NewParser()
+
+	_, err := p.Parse("$GPGSA,A,3,04,05,,09")
+	c.Assert(err, qt.Equals, errInvalidGSASentence)
+
+	fix, err := p.Parse("$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsTrue)
+	c.Assert(fix.FixType, qt.Equals, uint8(Fix3D))
+	c.Assert(fix.PDOP, qt.Equals, float32(2.5))
+	c.Assert(fix.HDOP, qt.Equals, float32(1.3))
+	c.Assert(fix.VDOP, qt.Equals, float32(2.1))
+
+	fix, err = p.Parse("$GNGSA,A,1,,,,,,,,,,,,,99.99,99.99,99.99,2*3F")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsFalse)
+	c.Assert(fix.FixType, qt.Equals, uint8(FixNone))
+}
+
+func TestParseGSV(t *testing.T) {
+	c := qt.New(t)
+
+	p := NewParser()
+
+	_, err := p.Parse("$GPGSV,3,4,09*7F")
+	c.Assert(err, qt.Equals, errInvalidGSVSentence)
+
+	// GSA usually comes before GSV.
+	for _, s := range []string{
+		"$GNGSA,A,3,07,10,,,,,,,,,,,1.8,1.0,1.5,1*33",
+		"$GNGSA,A,3,70,,,,,,,,,,,,1.8,1.0,1.5,2*33",
+		"$GPGSV,2,1,05,07,14,317,22,08,31,284,25,10,32,133,39,16,85,232,29*7F",
+		"$GPGSV,2,2,05,27,,,*7F",
+		"$GLGSV,1,1,01,70,45,010,33*7F",
+	} {
+		_, err := p.Parse(s)
+		c.Assert(err, qt.IsNil)
+	}
+	c.Assert(p.Satellites(), qt.DeepEquals, []Satellite{
+		{GNSS: GNSSGPS, ID: 7, SNR: 22, Elevation: 14, Azimuth: 317, Used: true},
+		{GNSS: GNSSGPS, ID: 8, SNR: 25, Elevation: 31, Azimuth: 284},
+		{GNSS: GNSSGPS, ID: 10, SNR: 39, Elevation: 32, Azimuth: 133, Used: true},
+		{GNSS: GNSSGPS, ID: 16, SNR: 29, Elevation: 85, Azimuth: 232},
+		{GNSS: GNSSGPS, ID: 27},
+		{GNSS: GNSSGLONASS, ID: 70, SNR: 33, Elevation: 45, Azimuth: 10, Used: true},
+	})
+
+	// A new sequence replaces the satellites of its system only, and an
+	// incomplete sequence is ignored.
+	for _, s := range []string{
+		"$GPGSV,2,1,05,07,14,317,22,08,31,284,25,10,32,133,39,16,85,232,29*7F",
+		"$GPGSV,1,1,01,08,30,285,27*7F",
+		"$GPGSV,3,1,09,07,14,317,22,08,31,284,25,10,32,133,39,16,85,232,29*7F",
+		"$GPGSV,3,3,09,27,,,*7F",
+		"$GNGSA,A,3,08,,,,,,,,,,,,1.8,1.0,1.5,1*33",
+	} {
+		fix, err := p.Parse(s)
+		c.Assert(err, qt.IsNil)
+		c.Assert(fix.SatellitesInView > 0 || fix.Valid, qt.IsTrue)
+	}
+	c.Assert(p.Satellites(), qt.DeepEquals, []Satellite{
+		{GNSS: GNSSGLONASS, ID: 70, SNR: 33, Elevation: 45, Azimuth: 10, Used: true},
+		{GNSS: GNSSGPS, ID: 8, SNR: 27, Elevation: 30, Azimuth: 285, Used: true},
+	})
+
+	// Sequences per signal are kept apart, and GN sentences derive the
+	// system from the satellite ID.
+	_, err = p.Parse("$GPGSV,1,1,01,08,30,285,31,8*7F")
+	c.Assert(err, qt.IsNil)
+	_, err = p.Parse("$GNGSV,1,1,02,301,10,100,20,201,20,200,30*7F")
+	c.Assert(err, qt.IsNil)
+	sats := p.Satellites()
+	c.Assert(sats, qt.HasLen, 5)
+	c.Assert(sats[2], qt.Equals, Satellite{GNSS: GNSSGPS, ID: 8, SNR: 31, Elevation: 30, Azimuth: 285, Used: true, Signal: 8})
+	c.Assert(sats[3].GNSS, qt.Equals, GNSSGalileo)
+	c.Assert(sats[4].GNSS, qt.Equals, GNSSBeiDou)
+
+	// Signal IDs are hexadecimal.
+	_, err = p.Parse("$GBGSV,1,1,01,19,45,120,38,B*35")
+	c.Assert(err, qt.IsNil)
+	sats = p.Satellites()
+	c.Assert(sats[len(sats)-1], qt.Equals, Satellite{GNSS: GNSSBeiDou, ID: 19, SNR: 38, Elevation: 45, Azimuth: 120, Signal: 0xB})
+}
+
+func TestParseVTG(t *testing.T) {
+	c := qt.New(t)
+
+	p := NewParser()
+
+	_, err := p.Parse("$GPVTG,054.7,T")
+	c.Assert(err, qt.Equals, errInvalidVTGSentence)
+
+	fix, err := p.Parse("$GPVTG,054.7,T,034.4,M,005.5,N,010.2,K,A*48")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsTrue)
+	c.Assert(fix.Heading, qt.Equals, float32(54.7))
+	c.Assert(fix.Speed, qt.Equals, float32(5.5))
+
+	fix, err = p.Parse("$GNVTG,,T,,M,,N,,K,N*32")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsFalse)
+}
+
+func TestParseZDA(t *testing.T) {
+	c := qt.New(t)
+
+	p := NewParser()
+
+	_, err := p.Parse("$GPZDA,172809.456,12")
+	c.Assert(err, qt.Equals, errInvalidZDASentence)
+
+	fix, err := p.Parse("$GPZDA,172809.00,12,07,1996,00,00*57")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsTrue)
+	c.Assert(fix.Time, qt.Equals, time.Date(1996, time.July, 12, 17, 28, 9, 0, time.UTC))
+
+	fix, err = p.Parse("$GNZDA,,,,,00,00*56")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsFalse)
+}
+
+func TestParseGST(t *testing.T) {
+	c := qt.New(t)
+
+	p := NewParser()
+
+	_, err := p.Parse("$GPGST,172814.0,0.006")
+	c.Assert(err, qt.Equals, errInvalidGSTSentence)
+
+	fix, err := p.Parse("$GPGST,172814.0,0.006,0.023,0.020,273.6,0.023,0.020,0.031*6A")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Valid, qt.IsTrue)
+	c.Assert(fix.LatitudeError, qt.Equals, float32(0.023))
+	c.Assert(fix.LongitudeError, qt.Equals, float32(0.020))
+	c.Assert(fix.AltitudeError, qt.Equals, float32(0.031))
+}
+
+func TestParseGGAQuality(t *testing.T) {
+	c := qt.New(t)
+
+	p := NewParser()
+
+	fix, err := p.Parse("$GNGGA,115739.00,4158.8441367,N,09147.4416929,W,4,13,0.9,255.747,M,-32.00,M,01,0000*6E")
+	c.Assert(err, qt.IsNil)
+	c.Assert(fix.Quality, qt.Equals, uint8(QualityRTKFixed))
+	c.Assert(fix.HDOP, qt.Equals, float32(0.9))
+}
@@ -0,0 +1,473 @@
+package gps
+
+import (
+	"time"
+)
+
+// maxNMEAFields is the maximum number of fields of a sentence received by a
+// Stream, including the address field. A GSV sentence with four satellites
+// and a signal ID has 21.
+const maxNMEAFields = 32
+
+// SentenceType is the type of an NMEA sentence, independent of the talker.
+type SentenceType uint8
+
+const (
+	SentenceUnknown SentenceType = iota
+	SentenceGGA
+	SentenceGLL
+	SentenceRMC
+	SentenceGSA
+	SentenceGSV
+	SentenceVTG
+	SentenceZDA
+	SentenceGST
+)
+
+var sentenceTypes = [...]string{
+	SentenceGGA: "GGA",
+	SentenceGLL: "GLL",
+	SentenceRMC: "RMC",
+	SentenceGSA: "GSA",
+	SentenceGSV: "GSV",
+	SentenceVTG: "VTG",
+	SentenceZDA: "ZDA",
+	SentenceGST: "GST",
+}
+
+// Sentence is an NMEA sentence received by a Stream. It holds the data
+// between the starting delimiter and the checksum, split into fields.
+type Sentence struct {
+	buf     [maximumNMEALength]byte
+	n       int
+	fields  [maxNMEAFields]uint8 // offset of every field in buf
+	nfields int
+}
+
+// Stream is an incremental NMEA parser. It is fed one byte at a time, for
+// example from a UART interrupt buffer, and never allocates memory. Anything
+// that is not a valid NMEA sentence, such as UBX messages, is skipped.
+type Stream struct {
+	sentence Sentence
+	handler  func(s *Sentence)
+	state    uint8
+	sum      byte // checksum calculated over the received data
+	check    byte // checksum received at the end of the sentence
+}
+
+// Stream states.
+const (
+	streamIdle = iota
+	streamData
+	streamChecksum1
+	streamChecksum2
+)
+
+// NewStream returns a new Stream. The handler, which may be nil, is called by
+// Write for every sentence that is received.
+func NewStream(handler func(s *Sentence)) Stream {
+	return Stream{handler: handler}
+}
+
+// Feed adds a byte to the stream. It returns true when the byte completes a
+// sentence with a valid checksum. The sentence is then available from
+// Sentence until the next call to Feed.
+func (st *Stream) Feed(b byte) bool {
+	s := &st.sentence
+	if b == startingDelimiter {
+		// Always start over, so a corrupted sentence can't eat the next.
+		s.n = 0
+		s.fields[0] = 0
+		s.nfields = 1
+		st.sum = 0
+		st.state = streamData
+		return false
+	}
+	switch st.state {
+	case streamData:
+		switch {
+		case b == checksumDelimiter:
+			st.state = streamChecksum1
+			if s.n < minimumNMEALength-3 {
+				st.state = streamIdle
+			}
+		case b < ' ' || b > '~' || s.n == len(s.buf):
+			st.state = streamIdle
+		case b == ',':
+			if s.nfields == len(s.fields) {
+				st.state = streamIdle
+				return false
+			}
+			st.sum ^= b
+			s.buf[s.n] = b
+			s.n++
+			s.fields[s.nfields] = uint8(s.n)
+			s.nfields++
+		default:
+			st.sum ^= b
+			s.buf[s.n] = b
+			s.n++
+		}
+	case streamChecksum1, streamChecksum2:
+		v, ok := hexDigit(b)
+		if !ok {
+			st.state = streamIdle
+			return false
+		}
+		if st.state == streamChecksum1 {
+			st.check = v << 4
+			st.state = streamChecksum2
+			return false
+		}
+		st.state = streamIdle
+		return st.check|v == st.sum
+	}
+	return false
+}
+
+// Write feeds all of p to the stream, calling the handler for every complete
+// sentence. It never fails.
+func (st *Stream) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if st.Feed(b) && st.handler != nil {
+			st.handler(&st.sentence)
+		}
+	}
+	return len(p), nil
+}
+
+// Sentence returns the last sentence completed by Feed.
+func (st *Stream) Sentence() *Sentence {
+	return &st.sentence
+}
+
+// Bytes returns the sentence without the starting delimiter and checksum,
+// for example "GPGLL,3751.65,S,14507.36,E".
+func (s *Sentence) Bytes() []byte {
+	return s.buf[:s.n]
+}
+
+// Len returns the number of fields, including the address field.
+func (s *Sentence) Len() int {
+	return s.nfields
+}
+
+// Field returns field i, where field 0 is the address (such as "GPGGA"). It
+// returns nil if there is no such field.
+func (s *Sentence) Field(i int) []byte {
+	if i < 0 || i >= s.nfields {
+		return nil
+	}
+	end := s.n
+	if i+1 < s.nfields {
+		end = int(s.fields[i+1]) - 1
+	}
+	return s.buf[s.fields[i]:end]
+}
+
+// Int returns field i as an integer. It returns false if the field is empty
+// or not a number.
+func (s *Sentence) Int(i int) (int, bool) {
+	v, ok := parseInt(s.Field(i))
+	return int(v), ok
+}
+
+// Float returns field i as a floating point number. It returns false if the
+// field is empty or not a number.
+func (s *Sentence) Float(i int) (float32, bool) {
+	v, ok := parseDecimal(s.Field(i))
+	return float32(v), ok
+}
+
+// Type returns the type of the sentence.
+func (s *Sentence) Type() SentenceType {
+	addr := s.Field(0)
+	if len(addr) != 5 || addr[0] == 'P' {
+		// Proprietary sentences start with P.
+		return SentenceUnknown
+	}
+	for i, typ := range sentenceTypes {
+		if typ != "" && string(addr[2:]) == typ {
+			return SentenceType(i)
+		}
+	}
+	return SentenceUnknown
+}
+
+// GNSS returns the satellite system of the talker. It returns false for GN
+// (multiple systems) and unknown talkers.
+func (s *Sentence) GNSS() (GNSS, bool) {
+	var talker [3]byte
+	talker[0] = startingDelimiter
+	copy(talker[1:], s.Field(0))
+	return talkerGNSS(string(talker[:]))
+}
+
+// Fix updates fix with the information in the sentence, leaving the fields
+// that the sentence does not carry unchanged, so a complete fix can be built
+// from a sequence of sentences. Valid is only updated by sentences that
+// report a position (GGA, GLL and RMC), and sentences with only a time of day
+// keep the date of fix.Time.
+func (s *Sentence) Fix(fix *Fix) error {
+	switch s.Type() {
+	case SentenceGGA:
+		if s.nfields != 15 {
+			return errInvalidGGASentence
+		}
+		s.time(fix, 1)
+		fix.Latitude = s.coordinate(2, 'S')
+		fix.Longitude = s.coordinate(4, 'W')
+		q, _ := s.Int(6)
+		fix.Quality = uint8(q)
+		n, _ := s.Int(7)
+		fix.Satellites = int16(n)
+		fix.HDOP, _ = s.Float(8)
+		alt, _ := s.Float(9)
+		fix.Altitude = int32(alt)
+		fix.Valid = q != QualityInvalid
+	case SentenceGLL:
+		if s.nfields != 8 {
+			return errInvalidGLLSentence
+		}
+		fix.Latitude = s.coordinate(1, 'S')
+		fix.Longitude = s.coordinate(3, 'W')
+		s.time(fix, 5)
+		fix.Valid = s.status(6)
+	case SentenceRMC:
+		if s.nfields < 12 {
+			return errInvalidRMCSentence
+		}
+		s.time(fix, 1)
+		fix.Valid = s.status(2)
+		fix.Latitude = s.coordinate(3, 'S')
+		fix.Longitude = s.coordinate(5, 'W')
+		fix.Speed, _ = s.Float(7)
+		fix.Heading, _ = s.Float(8)
+		if d := s.Field(9); len(d) == 6 {
+			day, _ := parseInt(d[0:2])
+			month, _ := parseInt(d[2:4])
+			year, _ := parseInt(d[4:6])
+			s.date(fix, int(2000+year), int(month), int(day))
+		}
+	case SentenceGSA:
+		if s.nfields != 18 && s.nfields != 19 {
+			return errInvalidGSASentence
+		}
+		fix.FixType = FixNone
+		if t, _ := s.Int(2); t == 2 || t == 3 {
+			fix.FixType = uint8(t)
+		}
+		fix.PDOP, _ = s.Float(15)
+		fix.HDOP, _ = s.Float(16)
+		fix.VDOP, _ = s.Float(17)
+	case SentenceGSV:
+		if s.nfields < 4 || (s.nfields-4)%4 > 1 {
+			return errInvalidGSVSentence
+		}
+		n, _ := s.Int(3)
+		fix.SatellitesInView = int16(n)
+	case SentenceVTG:
+		if s.nfields != 9 && s.nfields != 10 {
+			return errInvalidVTGSentence
+		}
+		fix.Heading, _ = s.Float(1)
+		fix.Speed, _ = s.Float(5)
+	case SentenceZDA:
+		if s.nfields != 7 {
+			return errInvalidZDASentence
+		}
+		s.time(fix, 1)
+		day, _ := s.Int(2)
+		month, _ := s.Int(3)
+		year, _ := s.Int(4)
+		s.date(fix, year, month, day)
+	case SentenceGST:
+		if s.nfields != 9 {
+			return errInvalidGSTSentence
+		}
+		s.time(fix, 1)
+		fix.LatitudeError, _ = s.Float(6)
+		fix.LongitudeError, _ = s.Float(7)
+		fix.AltitudeError, _ = s.Float(8)
+	default:
+		return errUnknownNMEASentence
+	}
+	return nil
+}
+
+// AppendSatellites appends the satellites of a GSV sentence to sats. The
+// message number and count of a sequence of GSV sentences are in fields 2
+// and 1.
+func (s *Sentence) AppendSatellites(sats []Satellite) []Satellite {
+	if s.Type() != SentenceGSV || s.nfields < 4 || (s.nfields-4)%4 > 1 {
+		return sats
+	}
+	var signal int
+	if (s.nfields-4)%4 == 1 {
+		signal, _ = parseHex(s.Field(s.nfields - 1))
+	}
+	talker, talkerOK := s.GNSS()
+	for i := 4; i+4 <= s.nfields; i += 4 {
+		id, ok := s.Int(i)
+		if !ok || id <= 0 {
+			continue
+		}
+		gnss := talker
+		if !talkerOK {
+			gnss = prnGNSS(id)
+		}
+		elevation, _ := s.Int(i + 1)
+		azimuth, _ := s.Int(i + 2)
+		snr, _ := s.Int(i + 3)
+		sats = append(sats, Satellite{
+			GNSS:      gnss,
+			ID:        uint16(id),
+			Signal:    uint8(signal),
+			Elevation: int8(elevation),
+			Azimuth:   int16(azimuth),
+			SNR:       uint8(snr),
+		})
+	}
+	return sats
+}
+
+// status returns whether field i is the status 'A' (valid).
+func (s *Sentence) status(i int) bool {
+	f := s.Field(i)
+	return len(f) == 1 && f[0] == 'A'
+}
+
+// coordinate returns the latitude or longitude in field i, which is in
+// (d)ddmm.mmmm format and followed by the hemisphere.
+func (s *Sentence) coordinate(i int, negative byte) float32 {
+	v, ok := parseDecimal(s.Field(i))
+	if !ok {
+		return 0
+	}
+	deg := float64(int64(v / 100))
+	v = deg + (v-deg*100)/60
+	if h := s.Field(i + 1); len(h) == 1 && h[0] == negative {
+		v = -v
+	}
+	return float32(v)
+}
+
+// time sets the time of day of fix.Time from field i, in hhmmss.sss format.
+func (s *Sentence) time(fix *Fix, i int) {
+	f := s.Field(i)
+	if len(f) < 6 {
+		return
+	}
+	h, ok1 := parseInt(f[0:2])
+	m, ok2 := parseInt(f[2:4])
+	sec, ok3 := parseDecimal(f[4:])
+	if !ok1 || !ok2 || !ok3 {
+		return
+	}
+	us := int64(sec*1e6 + 0.5)
+	y, mon, d := fix.Time.Date()
+	fix.Time = time.Date(y, mon, d, int(h), int(m), 0, 0, time.UTC).Add(time.Duration(us) * time.Microsecond)
+}
+
+// date sets the date of fix.Time, keeping the time of day.
+func (s *Sentence) date(fix *Fix, year, month, day int) {
+	if year <= 0 || month <= 0 || day <= 0 {
+		return
+	}
+	t := fix.Time
+	fix.Time = time.Date(year, time.Month(month), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// parseInt parses a decimal integer with an optional sign.
+func parseInt(b []byte) (int32, bool) {
+	neg := false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg = b[0] == '-'
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 9 {
+		return 0, false
+	}
+	var v int32
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		v = v*10 + int32(c-'0')
+	}
+	if neg {
+		v = -v
+	}
+	return v, true
+}
+
+// parseDecimal parses a decimal number such as "-12.345". It doesn't accept
+// exponents, which are not used in NMEA sentences.
+func parseDecimal(b []byte) (float64, bool) {
+	neg := false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg = b[0] == '-'
+		b = b[1:]
+	}
+	var mant int64
+	var scale float64 = 1
+	digits, dot := 0, false
+	for _, c := range b {
+		switch {
+		case c == '.' && !dot:
+			dot = true
+		case c >= '0' && c <= '9':
+			if digits == 18 {
+				// More precision than a float64 has, ignore.
+				if !dot {
+					return 0, false
+				}
+				continue
+			}
+			mant = mant*10 + int64(c-'0')
+			digits++
+			if dot {
+				scale *= 10
+			}
+		default:
+			return 0, false
+		}
+	}
+	if digits == 0 {
+		return 0, false
+	}
+	v := float64(mant) / scale
+	if neg {
+		v = -v
+	}
+	return v, true
+}
+
+// parseHex parses a hexadecimal integer, such as the system and signal IDs of
+// NMEA 4.11.
+func parseHex(b []byte) (int, bool) {
+	if len(b) == 0 || len(b) > 7 {
+		return 0, false
+	}
+	v := 0
+	for _, c := range b {
+		d, ok := hexDigit(c)
+		if !ok {
+			return 0, false
+		}
+		v = v<<4 | int(d)
+	}
+	return v, true
+}
+
+func hexDigit(b byte) (byte, bool) {
+	switch {
+	case b >= '0' && b <= '9':
+		return b - '0', true
+	case b >= 'A' && b <= 'F':
+		return b - 'A' + 10, true
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,50 @@
+//go:build go1.18
+
+package gps
+
+import (
+	"fmt"
+	"testing"
+)
+
+func FuzzStream(f *testing.F) {
+	f.Add([]byte(streamInput))
+	f.Add([]byte("$GPZDA,172809.456,12,07,1996,-5,00*4F"))
+	f.Add([]byte("$GPGST,172814.0,0.006,0.023,0.020,273.6,0.023,0.020,0.031*6A"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sats := make([]Satellite, 0, 16)
+		st := NewStream(func(s *Sentence) {
+			// Every sentence passes the checks of the string based parser.
+			sentence := fmt.Sprintf("$%s*%02X", s.Bytes(), checksum(s.Bytes()))
+			if err := validSentence(sentence); err != nil {
+				t.Fatalf("invalid sentence %q: %v", sentence, err)
+			}
+			for i := 0; i < s.Len(); i++ {
+				s.Int(i)
+				s.Float(i)
+			}
+			var fix Fix
+			s.Fix(&fix)
+			sats = s.AppendSatellites(sats[:0])
+		})
+		st.Write(data)
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("$GPGSV,3,1,09,07,14,317,22,08,31,284,25,10,32,133,39,16,85,232,29*7F")
+	f.Add("$GNGSA,A,3,70,,,,,,,,,,,,1.8,1.0,1.5,2*33")
+	f.Add("$GPVTG,054.7,T,034.4,M,005.5,N,010.2,K,A*48")
+	f.Fuzz(func(t *testing.T, sentence string) {
+		p := NewParser()
+		p.Parse(sentence)
+	})
+}
+
+func checksum(b []byte) byte {
+	var cs byte
+	for _, c := range b {
+		cs ^= c
+	}
+	return cs
+}
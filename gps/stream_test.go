@@ -0,0 +1,147 @@
+package gps
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+const streamInput = "garbage$GPGGA,1157\r\n" +
+	"$GNRMC,203522.00,A,5109.0262308,N,11401.8407342,W,0.004,133.4,130522,0.0,E,D*35\r\n" +
+	"$GNGGA,203523.50,5109.0262317,N,11401.8407304,W,1,13,0.9,1097.3,M,-32.00,M,,*4C\r\n" +
+	"\xb5\x62\x01\x07\x00\x00\x08\x19" + // UBX message
+	"$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39\r\n" +
+	"$GPVTG,054.7,T,034.4,M,005.5,N,010.2,K,A*00\r\n" + // bad checksum
+	"$GPGSV,1,1,02,07,14,317,22,08,31,284,25,1*62\r\n" +
+	"$PUBX,00,TEST*00\r\n"
+
+func TestStream(t *testing.T) {
+	c := qt.New(t)
+	var sentences []string
+	var types []SentenceType
+	var fix Fix
+	var sats []Satellite
+	st := NewStream(func(s *Sentence) {
+		sentences = append(sentences, string(s.Bytes()))
+		types = append(types, s.Type())
+		if s.Type() == SentenceGSV {
+			sats = s.AppendSatellites(sats)
+		}
+		c.Assert(s.Fix(&fix), qt.IsNil)
+	})
+	n, err := st.Write([]byte(streamInput))
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, len(streamInput))
+	c.Assert(sentences, qt.DeepEquals, []string{
+		"GNRMC,203522.00,A,5109.0262308,N,11401.8407342,W,0.004,133.4,130522,0.0,E,D",
+		"GNGGA,203523.50,5109.0262317,N,11401.8407304,W,1,13,0.9,1097.3,M,-32.00,M,,",
+		"GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1",
+		"GPGSV,1,1,02,07,14,317,22,08,31,284,25,1",
+	})
+	c.Assert(types, qt.DeepEquals, []SentenceType{SentenceRMC, SentenceGGA, SentenceGSA, SentenceGSV})
+
+	// The fix combines all sentences.
+	c.Assert(fix.Valid, qt.IsTrue)
+	c.Assert(fix.Time, qt.Equals, time.Date(2022, time.May, 13, 20, 35, 23, 500000000, time.UTC))
+	c.Assert(fix.Latitude, qt.Equals, float32(51.1504364))
+	c.Assert(fix.Longitude, qt.Equals, float32(-114.0306778))
+	c.Assert(fix.Altitude, qt.Equals, int32(1097))
+	c.Assert(fix.Quality, qt.Equals, uint8(QualityGNSS))
+	c.Assert(fix.Satellites, qt.Equals, int16(13))
+	c.Assert(fix.Heading, qt.Equals, float32(133.4))
+	c.Assert(fix.FixType, qt.Equals, uint8(Fix3D))
+	c.Assert(fix.PDOP, qt.Equals, float32(2.5))
+	c.Assert(fix.HDOP, qt.Equals, float32(1.3))
+	c.Assert(fix.VDOP, qt.Equals, float32(2.1))
+	c.Assert(fix.SatellitesInView, qt.Equals, int16(2))
+	c.Assert(sats, qt.DeepEquals, []Satellite{
+		{GNSS: GNSSGPS, ID: 7, SNR: 22, Elevation: 14, Azimuth: 317, Signal: 1},
+		{GNSS: GNSSGPS, ID: 8, SNR: 25, Elevation: 31, Azimuth: 284, Signal: 1},
+	})
+}
+
+func TestStreamSignal(t *testing.T) {
+	c := qt.New(t)
+	var sats []Satellite
+	st := NewStream(func(s *Sentence) {
+		sats = s.AppendSatellites(sats)
+	})
+	_, err := st.Write([]byte("$GBGSV,1,1,01,19,45,120,38,B*35\r\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(sats, qt.DeepEquals, []Satellite{
+		{GNSS: GNSSBeiDou, ID: 19, SNR: 38, Elevation: 45, Azimuth: 120, Signal: 0xB},
+	})
+}
+
+func TestStreamFields(t *testing.T) {
+	c := qt.New(t)
+	st := NewStream(nil)
+	var done bool
+	for _, b := range []byte("$GPZDA,172809.456,12,07,1996,-5,00*4F") {
+		done = st.Feed(b)
+	}
+	c.Assert(done, qt.IsTrue)
+	s := st.Sentence()
+	c.Assert(s.Len(), qt.Equals, 7)
+	c.Assert(string(s.Field(0)), qt.Equals, "GPZDA")
+	c.Assert(string(s.Field(6)), qt.Equals, "00")
+	c.Assert(s.Field(7), qt.IsNil)
+	v, ok := s.Int(5)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(v, qt.Equals, -5)
+	_, ok = s.Int(1)
+	c.Assert(ok, qt.IsFalse)
+	f, ok := s.Float(1)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(f, qt.Equals, float32(172809.456))
+	gnss, ok := s.GNSS()
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(gnss, qt.Equals, GNSSGPS)
+
+	var fix Fix
+	c.Assert(s.Fix(&fix), qt.IsNil)
+	c.Assert(fix.Time, qt.Equals, time.Date(1996, time.July, 12, 17, 28, 9, 456000000, time.UTC))
+
+	// Sentences that are too long are dropped.
+	for _, b := range []byte("$GPTXT," + string(make([]byte, maximumNMEALength)) + "*00") {
+		c.Assert(st.Feed(b), qt.IsFalse)
+	}
+}
+
+func TestStreamAllocs(t *testing.T) {
+	var fix Fix
+	sats := make([]Satellite, 0, 16)
+	st := NewStream(func(s *Sentence) {
+		s.Fix(&fix)
+		sats = s.AppendSatellites(sats[:0])
+	})
+	input := []byte(streamInput)
+	allocs := testing.AllocsPerRun(100, func() {
+		st.Write(input)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	c := qt.New(t)
+	uart := &fakeUART{}
+	gps := NewUART(uart)
+	var sentences []string
+	st := NewStream(func(s *Sentence) {
+		sentences = append(sentences, string(s.Field(0)))
+	})
+
+	// Poll returns immediately when there is no data.
+	gps.Poll(&st)
+	c.Assert(sentences, qt.HasLen, 0)
+
+	uart.rx.WriteString(streamInput[:150])
+	gps.Poll(&st)
+	c.Assert(sentences, qt.DeepEquals, []string{"GNRMC"})
+	uart.rx.WriteString(streamInput[150:])
+	gps.Poll(&st)
+	c.Assert(sentences, qt.DeepEquals, []string{"GNRMC", "GNGGA", "GPGSA", "GPGSV"})
+}
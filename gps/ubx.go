@@ -0,0 +1,531 @@
+package gps
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// UBX is the binary protocol of u-blox receivers. A UBX message is framed
+// as:
+//
+//	0xB5 0x62 class id length(2, little endian) payload checksum(2)
+//
+// UBX messages can be mixed with NMEA sentences on the same port.
+
+var (
+	errInvalidUBXChecksum = errors.New("invalid UBX message checksum")
+	errInvalidUBXLength   = errors.New("invalid UBX message length")
+	errInvalidUBXMessage  = errors.New("unexpected UBX message type")
+	errUBXNak             = errors.New("UBX command rejected by GPS")
+	errUBXNoAck           = errors.New("no ACK to GPS command")
+	errTimeout            = errors.New("timeout reading from GPS")
+)
+
+const (
+	ubxSync1         = 0xB5
+	ubxSync2         = 0x62
+	ubxMaxPayload    = 1024
+	ubxAckTimeout    = time.Second
+	ubxNavPVTLength  = 92
+	ubxNavStatLength = 16
+	ubxCfgNav5Length = 36
+)
+
+// UBX message classes.
+const (
+	UBXClassNAV = 0x01
+	UBXClassACK = 0x05
+	UBXClassCFG = 0x06
+)
+
+// UBX message IDs.
+const (
+	UBXNavStatus = 0x03 // NAV-STATUS: receiver navigation status
+	UBXNavPVT    = 0x07 // NAV-PVT: position, velocity and time solution
+	UBXNavSat    = 0x35 // NAV-SAT: satellite information
+
+	UBXAckNak = 0x00 // ACK-NAK: message not acknowledged
+	UBXAckAck = 0x01 // ACK-ACK: message acknowledged
+
+	UBXCfgPrt    = 0x00 // CFG-PRT: port configuration
+	UBXCfgMsg    = 0x01 // CFG-MSG: message rate
+	UBXCfgRate   = 0x08 // CFG-RATE: navigation and measurement rate
+	UBXCfgNav5   = 0x24 // CFG-NAV5: navigation engine settings
+	UBXCfgGNSS   = 0x3E // CFG-GNSS: GNSS system configuration
+	UBXCfgValset = 0x8A // CFG-VALSET: set configuration items (M9 and later)
+)
+
+// UBXMessage is a UBX protocol message.
+type UBXMessage struct {
+	Class   byte
+	ID      byte
+	Payload []byte
+}
+
+// Append appends the framed message, including the checksum, to buf.
+func (m UBXMessage) Append(buf []byte) []byte {
+	start := len(buf)
+	buf = append(buf, ubxSync1, ubxSync2, m.Class, m.ID, byte(len(m.Payload)), byte(len(m.Payload)>>8))
+	buf = append(buf, m.Payload...)
+	a, b := ubxChecksum(buf[start+2:])
+	return append(buf, a, b)
+}
+
+// Bytes returns the framed message.
+func (m UBXMessage) Bytes() []byte {
+	return m.Append(make([]byte, 0, len(m.Payload)+8))
+}
+
+// ubxChecksum returns the 8-bit Fletcher checksum over the class, ID, length
+// and payload of a message.
+func ubxChecksum(data []byte) (a, b byte) {
+	for _, c := range data {
+		a += c
+		b += a
+	}
+	return a, b
+}
+
+// SendUBX sends a UBX message to the GPS device.
+func (gps *Device) SendUBX(msg UBXMessage) {
+	gps.WriteBytes(msg.Bytes())
+}
+
+// ConfigureUBX sends a UBX configuration message (class CFG) and waits for
+// the device to acknowledge it. NMEA sentences and other UBX messages that
+// are received in the meantime are discarded.
+func (gps *Device) ConfigureUBX(msg UBXMessage) error {
+	gps.SendUBX(msg)
+	gps.deadline = time.Now().Add(ubxAckTimeout)
+	defer func() {
+		gps.deadline = time.Time{}
+	}()
+	for {
+		m, err := gps.Next()
+		if err == errTimeout {
+			return errUBXNoAck
+		}
+		if err != nil || m.Sentence != "" || m.UBX.Class != UBXClassACK {
+			continue
+		}
+		ack, err := ParseAck(m.UBX)
+		if err != nil || ack.Class != msg.Class || ack.ID != msg.ID {
+			continue
+		}
+		if !ack.Acknowledged {
+			return errUBXNak
+		}
+		return nil
+	}
+}
+
+// readUBX reads a UBX message, after the two sync bytes. The payload is only
+// valid until the next message is read.
+func (gps *Device) readUBX() (msg UBXMessage, err error) {
+	var hdr [4]byte
+	for i := range hdr {
+		if hdr[i], err = gps.readByte(); err != nil {
+			return msg, err
+		}
+	}
+	msg.Class, msg.ID = hdr[0], hdr[1]
+	n := int(binary.LittleEndian.Uint16(hdr[2:]))
+	if n > ubxMaxPayload {
+		return msg, errInvalidUBXLength
+	}
+	if cap(gps.ubx) < n+2 {
+		gps.ubx = make([]byte, n+2)
+	}
+	data := gps.ubx[:n+2]
+	for i := range data {
+		if data[i], err = gps.readByte(); err != nil {
+			return msg, err
+		}
+	}
+	msg.Payload = data[:n]
+	a, b := ubxChecksum(hdr[:])
+	for _, c := range msg.Payload {
+		a += c
+		b += a
+	}
+	if a != data[n] || b != data[n+1] {
+		return msg, errInvalidUBXChecksum
+	}
+	return msg, nil
+}
+
+// UBXPort is the port number used in CFG-PRT and CFG-MSG.
+type UBXPort uint8
+
+const (
+	UBXPortI2C   UBXPort = 0 // DDC, the I2C compatible port
+	UBXPortUART1 UBXPort = 1
+	UBXPortUART2 UBXPort = 2
+	UBXPortUSB   UBXPort = 3
+	UBXPortSPI   UBXPort = 4
+)
+
+// Protocol masks for CFG-PRT.
+const (
+	UBXProtoUBX   = 0x01
+	UBXProtoNMEA  = 0x02
+	UBXProtoRTCM3 = 0x20
+)
+
+// CfgPrt returns a CFG-PRT message that configures a port. The baud rate is
+// only used for UART ports; I2C ports keep the default address 0x42. inProto
+// and outProto are combinations of the UBXProto masks.
+func CfgPrt(port UBXPort, baudRate uint32, inProto, outProto uint16) UBXMessage {
+	p := make([]byte, 20)
+	p[0] = byte(port)
+	le := binary.LittleEndian
+	switch port {
+	case UBXPortUART1, UBXPortUART2:
+		le.PutUint32(p[4:], 0x08D0) // 8 data bits, no parity, 1 stop bit
+		le.PutUint32(p[8:], baudRate)
+	case UBXPortI2C:
+		le.PutUint32(p[4:], I2C_ADDRESS<<1)
+	}
+	le.PutUint16(p[12:], inProto)
+	le.PutUint16(p[14:], outProto)
+	return UBXMessage{Class: UBXClassCFG, ID: UBXCfgPrt, Payload: p}
+}
+
+// CfgMsg returns a CFG-MSG message that sets how often the message with the
+// given class and ID is output on the current port, in navigation solutions.
+// A rate of 0 disables the message. This also works for NMEA sentences,
+// which have class 0xF0.
+func CfgMsg(class, id, rate byte) UBXMessage {
+	return UBXMessage{Class: UBXClassCFG, ID: UBXCfgMsg, Payload: []byte{class, id, rate}}
+}
+
+// CfgRate returns a CFG-RATE message that sets the measurement period and
+// the number of measurements per navigation solution. Navigation solutions
+// are aligned to UTC.
+func CfgRate(period time.Duration, navRate uint16) UBXMessage {
+	p := make([]byte, 6)
+	binary.LittleEndian.PutUint16(p[0:], uint16(period/time.Millisecond))
+	binary.LittleEndian.PutUint16(p[2:], navRate)
+	return UBXMessage{Class: UBXClassCFG, ID: UBXCfgRate, Payload: p}
+}
+
+// DynamicModel is the dynamic platform model of the navigation engine.
+type DynamicModel uint8
+
+const (
+	DynamicPortable   DynamicModel = 0
+	DynamicStationary DynamicModel = 2
+	DynamicPedestrian DynamicModel = 3
+	DynamicAutomotive DynamicModel = 4
+	DynamicSea        DynamicModel = 5
+	DynamicAirborne1g DynamicModel = 6 // needed above 12km altitude
+	DynamicAirborne2g DynamicModel = 7
+	DynamicAirborne4g DynamicModel = 8
+	DynamicWrist      DynamicModel = 9
+	DynamicBike       DynamicModel = 10
+	DynamicLawnmower  DynamicModel = 11
+	DynamicScooter    DynamicModel = 12
+)
+
+// CfgNav5 returns a CFG-NAV5 message that only sets the dynamic platform
+// model, leaving the other navigation settings unchanged.
+func CfgNav5(model DynamicModel) UBXMessage {
+	p := make([]byte, ubxCfgNav5Length)
+	p[0] = 0x01 // mask: apply dynamic model
+	p[2] = byte(model)
+	return UBXMessage{Class: UBXClassCFG, ID: UBXCfgNav5, Payload: p}
+}
+
+// UBXLayer is a set of configuration layers for CFG-VALSET.
+type UBXLayer uint8
+
+const (
+	UBXLayerRAM   UBXLayer = 0x01
+	UBXLayerBBR   UBXLayer = 0x02 // battery backed RAM
+	UBXLayerFlash UBXLayer = 0x04
+)
+
+// Some configuration keys for CFG-VALSET. The size of the value is part of
+// the key.
+const (
+	UBXKeyRateMeas             = 0x30210001 // measurement period in ms
+	UBXKeyNavspgDynmodel       = 0x20110021 // DynamicModel
+	UBXKeyUART1Baudrate        = 0x40520001
+	UBXKeyMsgoutNavPVTUART1    = 0x20910007 // NAV-PVT output rate on UART1
+	UBXKeyMsgoutNavPVTI2C      = 0x20910006 // NAV-PVT output rate on I2C
+	UBXKeyUART1OutprotNMEA     = 0x10740002
+	UBXKeyI2COutprotNMEA       = 0x10720002
+	UBXKeyMsgoutNavSatUART1    = 0x20910016 // NAV-SAT output rate on UART1
+	UBXKeyMsgoutNavStatusUART1 = 0x2091001B // NAV-STATUS output rate on UART1
+)
+
+// UBXConfigValue is a key and value for CFG-VALSET.
+type UBXConfigValue struct {
+	Key   uint32
+	Value uint64
+}
+
+// CfgValset returns a CFG-VALSET message that sets the given configuration
+// items in the given layers. It is supported by u-blox M9 and later
+// receivers, which deprecate most other CFG messages.
+func CfgValset(layers UBXLayer, values ...UBXConfigValue) UBXMessage {
+	p := []byte{0, byte(layers), 0, 0}
+	for _, v := range values {
+		p = append(p, byte(v.Key), byte(v.Key>>8), byte(v.Key>>16), byte(v.Key>>24))
+		size := 1
+		switch (v.Key >> 28) & 0x7 {
+		case 3:
+			size = 2
+		case 4:
+			size = 4
+		case 5:
+			size = 8
+		}
+		for i := 0; i < size; i++ {
+			p = append(p, byte(v.Value>>(8*uint(i))))
+		}
+	}
+	return UBXMessage{Class: UBXClassCFG, ID: UBXCfgValset, Payload: p}
+}
+
+// Ack is an ACK-ACK or ACK-NAK message.
+type Ack struct {
+	// Class and ID of the message that was acknowledged or rejected.
+	Class, ID byte
+
+	// Acknowledged is true for ACK-ACK and false for ACK-NAK.
+	Acknowledged bool
+}
+
+// ParseAck parses an ACK-ACK or ACK-NAK message.
+func ParseAck(msg UBXMessage) (Ack, error) {
+	if msg.Class != UBXClassACK || (msg.ID != UBXAckAck && msg.ID != UBXAckNak) {
+		return Ack{}, errInvalidUBXMessage
+	}
+	if len(msg.Payload) != 2 {
+		return Ack{}, errInvalidUBXLength
+	}
+	return Ack{Class: msg.Payload[0], ID: msg.Payload[1], Acknowledged: msg.ID == UBXAckAck}, nil
+}
+
+// Fix types reported by NAV-PVT and NAV-STATUS.
+const (
+	FixNone          = 0
+	FixDeadReckoning = 1
+	Fix2D            = 2
+	Fix3D            = 3
+	FixGNSSDR        = 4 // GNSS combined with dead reckoning
+	FixTimeOnly      = 5
+)
+
+// NavPVT is a NAV-PVT message, the navigation solution of the receiver.
+type NavPVT struct {
+	// ITOW is the GPS time of week of the navigation epoch in ms.
+	ITOW uint32
+
+	// Time is the UTC time of the solution. It is only valid if ValidDate
+	// and ValidTime are set.
+	Time      time.Time
+	ValidDate bool
+	ValidTime bool
+
+	// FixType is one of the Fix constants, and FixOK is set if the fix is
+	// within the configured accuracy limits.
+	FixType uint8
+	FixOK   bool
+
+	// NumSV is the number of satellites used in the solution.
+	NumSV uint8
+
+	// Longitude and Latitude in 1e-7 degrees.
+	Longitude int32
+	Latitude  int32
+
+	// Height above the ellipsoid and above mean sea level in mm.
+	Height    int32
+	HeightMSL int32
+
+	// Horizontal and vertical accuracy estimates in mm.
+	HAcc uint32
+	VAcc uint32
+
+	// Velocity north, east and down and ground speed in mm/s.
+	VelN        int32
+	VelE        int32
+	VelD        int32
+	GroundSpeed int32
+
+	// Heading of motion in 1e-5 degrees.
+	Heading int32
+
+	// Accuracy estimates of the speed (mm/s) and heading (1e-5 degrees).
+	SpeedAcc   uint32
+	HeadingAcc uint32
+
+	// PDOP is the position dilution of precision, scaled by 100.
+	PDOP uint16
+}
+
+// ParseNavPVT parses a NAV-PVT message.
+func ParseNavPVT(msg UBXMessage) (NavPVT, error) {
+	var pvt NavPVT
+	if msg.Class != UBXClassNAV || msg.ID != UBXNavPVT {
+		return pvt, errInvalidUBXMessage
+	}
+	p := msg.Payload
+	if len(p) != ubxNavPVTLength {
+		return pvt, errInvalidUBXLength
+	}
+	le := binary.LittleEndian
+	pvt.ITOW = le.Uint32(p[0:])
+	pvt.ValidDate = p[11]&0x01 != 0
+	pvt.ValidTime = p[11]&0x02 != 0
+	pvt.Time = time.Date(int(le.Uint16(p[4:])), time.Month(p[6]), int(p[7]),
+		int(p[8]), int(p[9]), int(p[10]), int(int32(le.Uint32(p[16:]))), time.UTC)
+	pvt.FixType = p[20]
+	pvt.FixOK = p[21]&0x01 != 0
+	pvt.NumSV = p[23]
+	pvt.Longitude = int32(le.Uint32(p[24:]))
+	pvt.Latitude = int32(le.Uint32(p[28:]))
+	pvt.Height = int32(le.Uint32(p[32:]))
+	pvt.HeightMSL = int32(le.Uint32(p[36:]))
+	pvt.HAcc = le.Uint32(p[40:])
+	pvt.VAcc = le.Uint32(p[44:])
+	pvt.VelN = int32(le.Uint32(p[48:]))
+	pvt.VelE = int32(le.Uint32(p[52:]))
+	pvt.VelD = int32(le.Uint32(p[56:]))
+	pvt.GroundSpeed = int32(le.Uint32(p[60:]))
+	pvt.Heading = int32(le.Uint32(p[64:]))
+	pvt.SpeedAcc = le.Uint32(p[68:])
+	pvt.HeadingAcc = le.Uint32(p[72:])
+	pvt.PDOP = le.Uint16(p[76:])
+	return pvt, nil
+}
+
+// Fix returns the solution as a Fix, like the one parsed from NMEA
+// sentences. The speed is in knots, as in RMC sentences.
+func (pvt NavPVT) Fix() Fix {
+	return Fix{
+		Valid:      pvt.FixOK && pvt.FixType >= Fix2D && pvt.FixType <= FixGNSSDR,
+		Time:       pvt.Time,
+		Latitude:   float32(pvt.Latitude) / 1e7,
+		Longitude:  float32(pvt.Longitude) / 1e7,
+		Altitude:   pvt.HeightMSL / 1000,
+		Satellites: int16(pvt.NumSV),
+		Speed:      float32(pvt.GroundSpeed) * (3600.0 / 1852000),
+		Heading:    float32(pvt.Heading) / 1e5,
+	}
+}
+
+// NavStatus is a NAV-STATUS message.
+type NavStatus struct {
+	// ITOW is the GPS time of week in ms.
+	ITOW uint32
+
+	// FixType is one of the Fix constants, and FixOK is set if the fix is
+	// within the configured accuracy limits.
+	FixType uint8
+	FixOK   bool
+
+	// DiffSoln is set if differential corrections are applied.
+	DiffSoln bool
+
+	// WeekSet and TOWSet are set if the week number and time of week are
+	// valid.
+	WeekSet bool
+	TOWSet  bool
+
+	// TTFF is the time to first fix and MSSS the time since startup, in ms.
+	TTFF uint32
+	MSSS uint32
+}
+
+// ParseNavStatus parses a NAV-STATUS message.
+func ParseNavStatus(msg UBXMessage) (NavStatus, error) {
+	var s NavStatus
+	if msg.Class != UBXClassNAV || msg.ID != UBXNavStatus {
+		return s, errInvalidUBXMessage
+	}
+	p := msg.Payload
+	if len(p) != ubxNavStatLength {
+		return s, errInvalidUBXLength
+	}
+	le := binary.LittleEndian
+	s.ITOW = le.Uint32(p[0:])
+	s.FixType = p[4]
+	s.FixOK = p[5]&0x01 != 0
+	s.DiffSoln = p[5]&0x02 != 0
+	s.WeekSet = p[5]&0x04 != 0
+	s.TOWSet = p[5]&0x08 != 0
+	s.TTFF = le.Uint32(p[8:])
+	s.MSSS = le.Uint32(p[12:])
+	return s, nil
+}
+
+// GNSS identifies a satellite system, using the u-blox gnssId numbering.
+type GNSS uint8
+
+const (
+	GNSSGPS     GNSS = 0
+	GNSSSBAS    GNSS = 1
+	GNSSGalileo GNSS = 2
+	GNSSBeiDou  GNSS = 3
+	GNSSQZSS    GNSS = 5
+	GNSSGLONASS GNSS = 6
+)
+
+// Satellite describes a satellite that is in view.
+type Satellite struct {
+	GNSS GNSS
+	ID   uint16
+
+	// SNR is the carrier to noise ratio in dBHz, or 0 if the satellite is
+	// not tracked.
+	SNR uint8
+
+	// Elevation and Azimuth in degrees.
+	Elevation int8
+	Azimuth   int16
+
+	// Used is set if the satellite is used in the navigation solution.
+	Used bool
+
+	// Signal is the NMEA 4.10 signal ID from GSV sentences, for receivers
+	// that track several signals per satellite. It is 0 if not known.
+	Signal uint8
+}
+
+// NavSat is a NAV-SAT message.
+type NavSat struct {
+	// ITOW is the GPS time of week in ms.
+	ITOW uint32
+
+	Satellites []Satellite
+}
+
+// ParseNavSat parses a NAV-SAT message. The satellites are appended to
+// sats[:0], so that the slice can be reused between messages.
+func ParseNavSat(msg UBXMessage, sats []Satellite) (NavSat, error) {
+	s := NavSat{Satellites: sats[:0]}
+	if msg.Class != UBXClassNAV || msg.ID != UBXNavSat {
+		return s, errInvalidUBXMessage
+	}
+	p := msg.Payload
+	if len(p) < 8 || len(p) != 8+12*int(p[5]) {
+		return s, errInvalidUBXLength
+	}
+	le := binary.LittleEndian
+	s.ITOW = le.Uint32(p[0:])
+	for sv := p[8:]; len(sv) >= 12; sv = sv[12:] {
+		s.Satellites = append(s.Satellites, Satellite{
+			GNSS:      GNSS(sv[0]),
+			ID:        uint16(sv[1]),
+			SNR:       sv[2],
+			Elevation: int8(sv[3]),
+			Azimuth:   int16(le.Uint16(sv[4:])),
+			Used:      sv[8]&0x08 != 0,
+		})
+	}
+	return s, nil
+}
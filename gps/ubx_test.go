@@ -0,0 +1,185 @@
+package gps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// fakeUART is a UART that returns rx and collects the written data in tx.
+// If reply is set, it is called for every write to add a response.
+type fakeUART struct {
+	rx    bytes.Buffer
+	tx    bytes.Buffer
+	reply func(w []byte) []byte
+}
+
+func (u *fakeUART) Read(p []byte) (int, error) { return u.rx.Read(p) }
+func (u *fakeUART) Buffered() int              { return u.rx.Len() }
+
+func (u *fakeUART) Write(p []byte) (int, error) {
+	if u.reply != nil {
+		u.rx.Write(u.reply(p))
+	}
+	return u.tx.Write(p)
+}
+
+func TestUBXFraming(t *testing.T) {
+	c := qt.New(t)
+	c.Assert(CfgRate(time.Second, 1).Bytes(), qt.DeepEquals,
+		[]byte{0xB5, 0x62, 0x06, 0x08, 0x06, 0x00, 0xE8, 0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x37})
+	c.Assert(CfgMsg(0xF0, 0x00, 0).Bytes(), qt.DeepEquals,
+		[]byte{0xB5, 0x62, 0x06, 0x01, 0x03, 0x00, 0xF0, 0x00, 0x00, 0xFA, 0x0F})
+
+	msg := CfgValset(UBXLayerRAM|UBXLayerBBR,
+		UBXConfigValue{UBXKeyRateMeas, 200},
+		UBXConfigValue{UBXKeyNavspgDynmodel, uint64(DynamicAirborne1g)},
+		UBXConfigValue{UBXKeyUART1Baudrate, 115200})
+	c.Assert(msg.Payload, qt.DeepEquals, []byte{
+		0x00, 0x03, 0x00, 0x00,
+		0x01, 0x00, 0x21, 0x30, 0xC8, 0x00,
+		0x21, 0x00, 0x11, 0x20, 0x06,
+		0x01, 0x00, 0x52, 0x40, 0x00, 0xC2, 0x01, 0x00,
+	})
+
+	prt := CfgPrt(UBXPortUART1, 9600, UBXProtoUBX|UBXProtoNMEA, UBXProtoUBX)
+	c.Assert(prt.Payload[0], qt.Equals, byte(1))
+	c.Assert(binary.LittleEndian.Uint32(prt.Payload[8:]), qt.Equals, uint32(9600))
+	c.Assert(binary.LittleEndian.Uint16(prt.Payload[14:]), qt.Equals, uint16(UBXProtoUBX))
+}
+
+func navPVT() UBXMessage {
+	p := make([]byte, 92)
+	le := binary.LittleEndian
+	le.PutUint32(p[0:], 123456000)
+	le.PutUint16(p[4:], 2022)
+	p[6], p[7], p[8], p[9], p[10] = 5, 13, 20, 35, 22
+	p[11] = 0x07
+	p[20] = Fix3D
+	p[21] = 0x01
+	p[23] = 11
+	lon := int32(-1140306778)
+	le.PutUint32(p[24:], uint32(lon))
+	le.PutUint32(p[28:], 511504364)
+	le.PutUint32(p[36:], 1097300)
+	le.PutUint32(p[60:], 5144) // 10 knots
+	le.PutUint32(p[64:], 13340000)
+	le.PutUint16(p[76:], 145)
+	return UBXMessage{Class: UBXClassNAV, ID: UBXNavPVT, Payload: p}
+}
+
+func TestParseNavPVT(t *testing.T) {
+	c := qt.New(t)
+	pvt, err := ParseNavPVT(navPVT())
+	c.Assert(err, qt.IsNil)
+	c.Assert(pvt.Time, qt.Equals, time.Date(2022, time.May, 13, 20, 35, 22, 0, time.UTC))
+	c.Assert(pvt.FixType, qt.Equals, uint8(Fix3D))
+	c.Assert(pvt.NumSV, qt.Equals, uint8(11))
+	c.Assert(pvt.PDOP, qt.Equals, uint16(145))
+
+	fix := pvt.Fix()
+	c.Assert(fix.Valid, qt.IsTrue)
+	c.Assert(fix.Latitude, qt.Equals, float32(51.1504364))
+	c.Assert(fix.Longitude, qt.Equals, float32(-114.0306778))
+	c.Assert(fix.Altitude, qt.Equals, int32(1097))
+	c.Assert(fix.Satellites, qt.Equals, int16(11))
+	c.Assert(fix.Heading, qt.Equals, float32(133.4))
+	c.Assert(fix.Speed > 9.99 && fix.Speed < 10.01, qt.IsTrue)
+
+	_, err = ParseNavPVT(UBXMessage{Class: UBXClassNAV, ID: UBXNavPVT, Payload: make([]byte, 10)})
+	c.Assert(err, qt.Equals, errInvalidUBXLength)
+	_, err = ParseNavPVT(UBXMessage{Class: UBXClassNAV, ID: UBXNavSat})
+	c.Assert(err, qt.Equals, errInvalidUBXMessage)
+}
+
+func TestParseNavSatStatus(t *testing.T) {
+	c := qt.New(t)
+	p := []byte{
+		0x10, 0x00, 0x00, 0x00, 1, 2, 0, 0,
+		0, 7, 42, 45, 0x3D, 0x01, 0, 0, 0x0F, 0, 0, 0,
+		6, 70, 0, 0xF6, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	sats := make([]Satellite, 0, 16)
+	s, err := ParseNavSat(UBXMessage{Class: UBXClassNAV, ID: UBXNavSat, Payload: p}, sats)
+	c.Assert(err, qt.IsNil)
+	c.Assert(s.ITOW, qt.Equals, uint32(16))
+	c.Assert(s.Satellites, qt.DeepEquals, []Satellite{
+		{GNSS: GNSSGPS, ID: 7, SNR: 42, Elevation: 45, Azimuth: 317, Used: true},
+		{GNSS: GNSSGLONASS, ID: 70, Elevation: -10},
+	})
+	_, err = ParseNavSat(UBXMessage{Class: UBXClassNAV, ID: UBXNavSat, Payload: p[:30]}, sats)
+	c.Assert(err, qt.Equals, errInvalidUBXLength)
+
+	p = []byte{0x10, 0, 0, 0, Fix3D, 0x0D, 0, 0, 0x30, 0x75, 0, 0, 0x40, 0x9C, 0, 0}
+	st, err := ParseNavStatus(UBXMessage{Class: UBXClassNAV, ID: UBXNavStatus, Payload: p})
+	c.Assert(err, qt.IsNil)
+	c.Assert(st, qt.Equals, NavStatus{ITOW: 16, FixType: Fix3D, FixOK: true, WeekSet: true, TOWSet: true, TTFF: 30000, MSSS: 40000})
+}
+
+func TestInterleaved(t *testing.T) {
+	c := qt.New(t)
+	uart := &fakeUART{}
+	const gga = "$GPGGA,115739.00,4158.8441367,N,09147.4416929,W,4,13,0.9,255.747,M,-32.00,M,01,0000*6E"
+	uart.rx.WriteString("garbage\r\n" + gga + "\r\n")
+	uart.rx.Write(navPVT().Bytes())
+	uart.rx.Write([]byte{0xB5, '$'}) // not a UBX message
+	uart.rx.WriteString("GPGLL,5109.0262317,N,11401.8407304,W,202725.00,A,D*79\r\n")
+	bad := navPVT().Bytes()
+	bad[20]++
+	uart.rx.Write(bad)
+	uart.rx.WriteString(gga + "\r\n")
+	gps := NewUART(uart)
+
+	msg, err := gps.Next()
+	c.Assert(err, qt.IsNil)
+	c.Assert(msg.Sentence, qt.Equals, gga)
+	msg, err = gps.Next()
+	c.Assert(err, qt.IsNil)
+	pvt, err := ParseNavPVT(msg.UBX)
+	c.Assert(err, qt.IsNil)
+	c.Assert(pvt.NumSV, qt.Equals, uint8(11))
+	msg, err = gps.Next()
+	c.Assert(err, qt.IsNil)
+	c.Assert(msg.Sentence, qt.Equals, "$GPGLL,5109.0262317,N,11401.8407304,W,202725.00,A,D*79")
+	_, err = gps.Next()
+	c.Assert(err, qt.Equals, errInvalidUBXChecksum)
+	msg, err = gps.Next()
+	c.Assert(err, qt.IsNil)
+	c.Assert(msg.Sentence, qt.Equals, gga)
+
+	// NextSentence skips UBX messages.
+	uart.rx.Write(navPVT().Bytes())
+	uart.rx.WriteString(gga + "\r\n")
+	sentence, err := gps.NextSentence()
+	c.Assert(err, qt.IsNil)
+	c.Assert(sentence, qt.Equals, gga)
+}
+
+func TestConfigureUBX(t *testing.T) {
+	c := qt.New(t)
+	var nak bool
+	uart := &fakeUART{
+		reply: func(w []byte) []byte {
+			id := byte(UBXAckAck)
+			if nak {
+				id = UBXAckNak
+			}
+			// Some NMEA output and an unrelated ACK come first.
+			var reply []byte
+			reply = append(reply, "$GPGLL,5109.0262317,N,11401.8407304,W,202725.00,A,D*79\r\n"...)
+			reply = UBXMessage{Class: UBXClassACK, ID: UBXAckAck, Payload: []byte{0x06, 0x99}}.Append(reply)
+			return UBXMessage{Class: UBXClassACK, ID: id, Payload: w[2:4]}.Append(reply)
+		},
+	}
+	gps := NewUART(uart)
+	c.Assert(FlightMode(gps), qt.IsNil)
+	c.Assert(uart.tx.Bytes()[:8], qt.DeepEquals, []byte{0xB5, 0x62, 0x06, 0x24, 0x24, 0x00, 0x01, 0x00})
+	nak = true
+	c.Assert(gps.ConfigureUBX(CfgRate(time.Second, 1)), qt.Equals, errUBXNak)
+
+	uart.reply = nil
+	c.Assert(gps.ConfigureUBX(CfgRate(time.Second, 1)), qt.Equals, errUBXNoAck)
+}
@@ -5,20 +5,22 @@
 package hcsr04
 
 import (
-	"machine"
 	"time"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 const TIMEOUT = 23324 // max sensing distance (4m)
 
 // Device holds the pins
 type Device struct {
-	trigger machine.Pin
-	echo    machine.Pin
+	trigger drivers.PinOutput
+	echo    drivers.PinInput
 }
 
 // New returns a new ultrasonic driver given 2 pins
-func New(trigger, echo machine.Pin) Device {
+func New(trigger drivers.PinOutput, echo drivers.PinInput) Device {
 	return Device{
 		trigger: trigger,
 		echo:    echo,
@@ -27,8 +29,8 @@ func New(trigger, echo machine.Pin) Device {
 
 // Configure configures the pins of the Device
 func (d *Device) Configure() {
-	d.trigger.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.echo.Configure(machine.PinConfig{Mode: machine.PinInput})
+	legacy.ConfigurePin(d.trigger, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.echo, drivers.PinModeInput)
 }
 
 // ReadDistance returns the distance of the object in mm
@@ -77,5 +79,4 @@ func (d *Device) ReadPulse() int32 {
 			i = 0
 		}
 	}
-	return 0
 }
@@ -0,0 +1,39 @@
+package hcsr04
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestReadDistance(t *testing.T) {
+	c := qt.New(t)
+	trigger, echo := &tester.Pin{}, &tester.Pin{}
+	d := New(trigger, echo)
+	d.Configure()
+
+	// An object at 20cm echoes for 1166µs. The echo starts after the
+	// trigger pulse, whose length depends on the host, which can also miss
+	// the CPU during the echo.
+	var mm int32
+	for i := 0; i < 3; i++ {
+		trigger.Edges = nil
+		echo.Play(
+			tester.Level{High: false, Duration: 10 * time.Millisecond},
+			tester.Level{High: true, Duration: 1166 * time.Microsecond},
+			tester.Level{High: false},
+		)
+		if mm = d.ReadDistance(); mm >= 195 && mm <= 205 {
+			break
+		}
+	}
+	c.Assert(mm >= 195 && mm <= 205, qt.IsTrue, qt.Commentf("distance %dmm", mm))
+	c.Assert(trigger.Edges, qt.HasLen, 2)
+	c.Assert(trigger.Pulses()[0] >= 10*time.Microsecond, qt.IsTrue)
+
+	// Nothing in range.
+	echo.Play(tester.Level{High: false})
+	c.Assert(d.ReadDistance(), qt.Equals, int32(0))
+}
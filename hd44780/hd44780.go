@@ -6,15 +6,16 @@ package hd44780 // import "tinygo.org/x/drivers/hd44780"
 import (
 	"errors"
 	"io"
-	"machine"
 	"time"
+
+	"tinygo.org/x/drivers"
 )
 
 const (
 	// These are the default execution times for the Clear and
 	// Home commands and everything else.
 	//
-	// These are used if RW is passed as machine.NoPin and ignored
+	// These are used if RW is passed as nil or machine.NoPin and ignored
 	// otherwise.
 	//
 	// They are set conservatively here and can be tweaked in the
@@ -62,8 +63,9 @@ type Config struct {
 
 // NewGPIO4Bit returns 4bit data length HD44780 driver. Datapins are LCD DB pins starting from DB4 to DB7
 //
-// If your device has RW set permanently to ground then pass in rw as machine.NoPin
-func NewGPIO4Bit(dataPins []machine.Pin, e, rs, rw machine.Pin) (Device, error) {
+// If your device has RW set permanently to ground then pass in rw as nil or
+// machine.NoPin. The data pins are only read if rw is wired.
+func NewGPIO4Bit(dataPins []drivers.Pin, e, rs, rw drivers.PinOutput) (Device, error) {
 	const fourBitMode = 4
 	if len(dataPins) != fourBitMode {
 		return Device{}, errors.New("4 pins are required in data slice (D4-D7) when HD44780 is used in 4 bit mode")
@@ -73,8 +75,9 @@ func NewGPIO4Bit(dataPins []machine.Pin, e, rs, rw machine.Pin) (Device, error)
 
 // NewGPIO8Bit returns 8bit data length HD44780 driver. Datapins are LCD DB pins starting from DB0 to DB7
 //
-// If your device has RW set permanently to ground then pass in rw as machine.NoPin
-func NewGPIO8Bit(dataPins []machine.Pin, e, rs, rw machine.Pin) (Device, error) {
+// If your device has RW set permanently to ground then pass in rw as nil or
+// machine.NoPin. The data pins are only read if rw is wired.
+func NewGPIO8Bit(dataPins []drivers.Pin, e, rs, rw drivers.PinOutput) (Device, error) {
 	const eightBitMode = 8
 	if len(dataPins) != eightBitMode {
 		return Device{}, errors.New("8 pins are required in data slice (D0-D7) when HD44780 is used in 8 bit mode")
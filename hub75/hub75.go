@@ -182,7 +182,9 @@ func (d *Device) fillMatrixBuffer(x int16, y int16, r uint8, g uint8, b uint8) {
 	}
 }
 
-// Display sends the buffer (if any) to the screen.
+// Display sends the buffer (if any) to the screen. Only one row of the matrix
+// is lit at a time, so Display must be called continuously and always sends
+// all the rows, even those that didn't change.
 func (d *Device) Display() error {
 	rp := uint16(d.rowPattern)
 	for i := uint16(0); i < rp; i++ {
@@ -0,0 +1,10 @@
+// Package i2cbus provides wrappers of a drivers.I2C that are used in place of
+// the bus they wrap, so that any driver can use them. They can be stacked,
+// for example to log the transactions that are retried:
+//
+//	stats := i2cbus.NewStats(i2cbus.NewRetry(i2cbus.NewTrace(machine.I2C0, machine.Serial), i2cbus.RetryPolicy{}))
+//	sensor := bme280.New(stats)
+//
+// Apart from Shared, the wrappers must not be used by several goroutines at
+// once: wrap the outermost one in a Shared for that.
+package i2cbus // import "tinygo.org/x/drivers/i2cbus"
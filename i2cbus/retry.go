@@ -0,0 +1,66 @@
+package i2cbus
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// RetryPolicy says which failed transactions are tried again, and how.
+type RetryPolicy struct {
+	// Attempts is the number of times a transaction is tried, 3 if zero.
+	Attempts int
+
+	// Delay is the time before the first retry. It doubles for each
+	// following retry, up to MaxDelay if MaxDelay is not zero.
+	Delay    time.Duration
+	MaxDelay time.Duration
+
+	// Retryable returns whether a transaction that failed with err is tried
+	// again. If it is nil, all errors are. The errors of a NACK or a timeout
+	// depend on the machine package of the chip.
+	Retryable func(err error) bool
+}
+
+// Retry tries the transactions that fail again, as a device that is busy or
+// a bad connection would make them fail.
+//
+// A failed write may have reached the device, and is written again. This is
+// fine for a register, but not for a command that the device counts or
+// queues.
+type Retry struct {
+	bus    drivers.I2C
+	policy RetryPolicy
+
+	// Retries is the number of transactions that were tried again.
+	Retries uint32
+}
+
+// NewRetry returns a Retry of the transactions on bus.
+func NewRetry(bus drivers.I2C, policy RetryPolicy) *Retry {
+	if policy.Attempts <= 0 {
+		policy.Attempts = 3
+	}
+	return &Retry{bus: bus, policy: policy}
+}
+
+// Tx implements drivers.I2C. It returns the error of the last attempt.
+func (rt *Retry) Tx(addr uint16, w, r []byte) error {
+	delay := rt.policy.Delay
+	err := rt.bus.Tx(addr, w, r)
+	for attempt := 1; err != nil && attempt < rt.policy.Attempts; attempt++ {
+		if rt.policy.Retryable != nil && !rt.policy.Retryable(err) {
+			break
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+			delay *= 2
+			if rt.policy.MaxDelay > 0 && delay > rt.policy.MaxDelay {
+				delay = rt.policy.MaxDelay
+			}
+		}
+		rt.Retries++
+		err = rt.bus.Tx(addr, w, r)
+	}
+	return err
+}
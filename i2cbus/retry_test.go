@@ -0,0 +1,53 @@
+package i2cbus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+var (
+	errNACK    = errors.New("NACK")
+	errTimeout = errors.New("timeout")
+)
+
+// flakyBus fails with the errors, then succeeds.
+type flakyBus struct {
+	errs []error
+	n    int
+}
+
+func (b *flakyBus) Tx(addr uint16, w, r []byte) error {
+	b.n++
+	if len(b.errs) == 0 {
+		return nil
+	}
+	err := b.errs[0]
+	b.errs = b.errs[1:]
+	return err
+}
+
+func TestRetry(t *testing.T) {
+	c := qt.New(t)
+	bus := &flakyBus{errs: []error{errNACK, errNACK}}
+	rt := NewRetry(bus, RetryPolicy{Delay: time.Millisecond})
+	start := time.Now()
+	c.Assert(rt.Tx(0x76, []byte{0xD0}, make([]byte, 1)), qt.IsNil)
+	c.Assert(time.Since(start) >= 3*time.Millisecond, qt.IsTrue)
+	c.Assert(bus.n, qt.Equals, 3)
+	c.Assert(rt.Retries, qt.Equals, uint32(2))
+
+	// Too many errors.
+	bus = &flakyBus{errs: []error{errNACK, errNACK, errTimeout, errNACK}}
+	rt = NewRetry(bus, RetryPolicy{})
+	c.Assert(rt.Tx(0x76, []byte{0xD0}, make([]byte, 1)), qt.Equals, errTimeout)
+	c.Assert(bus.n, qt.Equals, 3)
+
+	// An error that is not retried.
+	bus = &flakyBus{errs: []error{errNACK, errTimeout}}
+	rt = NewRetry(bus, RetryPolicy{Attempts: 5, Retryable: func(err error) bool { return err == errNACK }})
+	c.Assert(rt.Tx(0x76, []byte{0xD0}, make([]byte, 1)), qt.Equals, errTimeout)
+	c.Assert(bus.n, qt.Equals, 2)
+}
@@ -0,0 +1,36 @@
+package i2cbus
+
+import (
+	"sync"
+
+	"tinygo.org/x/drivers"
+)
+
+// Shared is a bus that is used by several goroutines, such as one per sensor.
+// Each transaction holds the bus until it is done.
+type Shared struct {
+	mu  sync.Mutex
+	bus drivers.I2C
+}
+
+// NewShared returns a Shared that uses bus, which must not be used directly
+// anymore.
+func NewShared(bus drivers.I2C) *Shared {
+	return &Shared{bus: bus}
+}
+
+// Tx implements drivers.I2C.
+func (s *Shared) Tx(addr uint16, w, r []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bus.Tx(addr, w, r)
+}
+
+// Do holds the bus while f runs, for transactions that must not be
+// interleaved with those of other goroutines. f is given the wrapped bus: it
+// must not use s, which would deadlock.
+func (s *Shared) Do(f func(bus drivers.I2C) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return f(s.bus)
+}
@@ -0,0 +1,48 @@
+package i2cbus
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+)
+
+// busyBus counts the transactions that overlap.
+type busyBus struct {
+	active  int32
+	overlap int32
+}
+
+func (b *busyBus) Tx(addr uint16, w, r []byte) error {
+	if atomic.AddInt32(&b.active, 1) > 1 {
+		atomic.AddInt32(&b.overlap, 1)
+	}
+	time.Sleep(10 * time.Microsecond)
+	atomic.AddInt32(&b.active, -1)
+	return nil
+}
+
+func TestShared(t *testing.T) {
+	c := qt.New(t)
+	bus := &busyBus{}
+	s := NewShared(bus)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				s.Tx(0x44, []byte{0x24, 0x00}, nil)
+				s.Do(func(bus drivers.I2C) error {
+					bus.Tx(0x44, []byte{0x24, 0x00}, nil)
+					return bus.Tx(0x44, nil, make([]byte, 6))
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	c.Assert(bus.overlap, qt.Equals, int32(0))
+}
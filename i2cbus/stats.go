@@ -0,0 +1,78 @@
+package i2cbus
+
+import "tinygo.org/x/drivers"
+
+// AddrStats are the statistics of the transactions with an address.
+type AddrStats struct {
+	Transactions uint32
+	Errors       uint32
+
+	// Consecutive is the number of errors since the last transaction that
+	// succeeded, and MaxConsecutive the largest it has been.
+	Consecutive    uint32
+	MaxConsecutive uint32
+
+	// LastError is the error of the last failed transaction.
+	LastError error
+}
+
+// Stats counts the transactions and the errors per address, to find the
+// devices with a bad connection.
+type Stats struct {
+	bus   drivers.I2C
+	addrs map[uint16]*AddrStats
+}
+
+// NewStats returns a Stats of the transactions on bus.
+func NewStats(bus drivers.I2C) *Stats {
+	return &Stats{bus: bus, addrs: map[uint16]*AddrStats{}}
+}
+
+// Tx implements drivers.I2C.
+func (s *Stats) Tx(addr uint16, w, r []byte) error {
+	err := s.bus.Tx(addr, w, r)
+	st := s.addrs[addr]
+	if st == nil {
+		st = &AddrStats{}
+		s.addrs[addr] = st
+	}
+	st.Transactions++
+	if err == nil {
+		st.Consecutive = 0
+		return nil
+	}
+	st.Errors++
+	st.Consecutive++
+	if st.Consecutive > st.MaxConsecutive {
+		st.MaxConsecutive = st.Consecutive
+	}
+	st.LastError = err
+	return err
+}
+
+// Get returns the statistics of addr.
+func (s *Stats) Get(addr uint16) AddrStats {
+	if st := s.addrs[addr]; st != nil {
+		return *st
+	}
+	return AddrStats{}
+}
+
+// Addresses returns the addresses that were used, in increasing order.
+func (s *Stats) Addresses() []uint16 {
+	addrs := make([]uint16, 0, len(s.addrs))
+	for addr := range s.addrs {
+		i := len(addrs)
+		addrs = append(addrs, addr)
+		for ; i > 0 && addrs[i-1] > addr; i-- {
+			addrs[i] = addrs[i-1]
+		}
+		addrs[i] = addr
+	}
+	return addrs
+}
+
+// Reset clears the statistics.
+func (s *Stats) Reset() {
+	s.addrs = map[uint16]*AddrStats{}
+}
@@ -0,0 +1,39 @@
+package i2cbus
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStats(t *testing.T) {
+	c := qt.New(t)
+	bus := &flakyBus{}
+	s := NewStats(bus)
+	buf := make([]byte, 1)
+	s.Tx(0x76, []byte{0xD0}, buf)
+	bus.errs = []error{errNACK, errTimeout}
+	s.Tx(0x29, nil, buf)
+	s.Tx(0x29, nil, buf)
+	s.Tx(0x29, nil, buf)
+	s.Tx(0x18, []byte{0x0F}, buf)
+
+	c.Assert(s.Addresses(), qt.DeepEquals, []uint16{0x18, 0x29, 0x76})
+	c.Assert(s.Get(0x29), qt.Equals, AddrStats{
+		Transactions:   3,
+		Errors:         2,
+		MaxConsecutive: 2,
+		LastError:      errTimeout,
+	})
+	c.Assert(s.Get(0x76), qt.Equals, AddrStats{Transactions: 1})
+	c.Assert(s.Get(0x40), qt.Equals, AddrStats{})
+
+	// Retried transactions are counted once.
+	bus.errs = []error{errNACK}
+	s = NewStats(NewRetry(bus, RetryPolicy{}))
+	c.Assert(s.Tx(0x76, []byte{0xD0}, buf), qt.IsNil)
+	c.Assert(s.Get(0x76), qt.Equals, AddrStats{Transactions: 1})
+
+	s.Reset()
+	c.Assert(s.Addresses(), qt.HasLen, 0)
+}
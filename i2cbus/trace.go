@@ -0,0 +1,162 @@
+package i2cbus
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+var errBadRecord = errors.New("i2cbus: invalid trace record")
+
+// Trace writes a Record of every transaction, as a line of text, to an
+// io.Writer such as a serial port. The lines can be decoded on the host with
+// ParseRecord, or with the i2ctrace command:
+//
+//	go run ./cmd/i2ctrace capture.log
+type Trace struct {
+	bus   drivers.I2C
+	w     io.Writer
+	start time.Time
+	line  []byte
+}
+
+// NewTrace returns a Trace of the transactions on bus that writes to w.
+// Record times are relative to the call to NewTrace.
+func NewTrace(bus drivers.I2C, w io.Writer) *Trace {
+	return &Trace{bus: bus, w: w, start: time.Now()}
+}
+
+// Tx implements drivers.I2C.
+func (t *Trace) Tx(addr uint16, w, r []byte) error {
+	start := time.Now()
+	err := t.bus.Tx(addr, w, r)
+	rec := Record{
+		Start:    start.Sub(t.start),
+		Duration: time.Since(start),
+		Addr:     addr,
+		W:        w,
+		R:        r,
+	}
+	if err != nil {
+		// Nothing valid was read.
+		rec.R = nil
+		rec.Err = err.Error()
+	}
+	t.line = rec.AppendText(t.line[:0])
+	t.w.Write(t.line)
+	return err
+}
+
+// Record is a traced transaction. Its text form is a line with the start and
+// the duration in microseconds, the address and the written and read bytes in
+// hexadecimal, and the error or ok:
+//
+//	i2c 1024 180 76 d0 60 ok
+//	i2c 2048 95 29 010f - i2c: NACK
+//
+// An empty buffer is written as a dash, as are the read bytes of a failed
+// transaction.
+type Record struct {
+	Start    time.Duration
+	Duration time.Duration
+	Addr     uint16
+	W, R     []byte
+	// Err is the text of the error, empty if the transaction succeeded.
+	Err string
+}
+
+// AppendText appends the line of rec, with its newline, to b.
+func (rec *Record) AppendText(b []byte) []byte {
+	b = append(b, "i2c "...)
+	b = strconv.AppendInt(b, int64(rec.Start/time.Microsecond), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(rec.Duration/time.Microsecond), 10)
+	b = append(b, ' ')
+	b = strconv.AppendUint(b, uint64(rec.Addr), 16)
+	b = append(b, ' ')
+	b = appendHex(b, rec.W)
+	b = append(b, ' ')
+	b = appendHex(b, rec.R)
+	b = append(b, ' ')
+	if rec.Err == "" {
+		b = append(b, "ok"...)
+	} else {
+		// The error stays on the line.
+		for i := 0; i < len(rec.Err); i++ {
+			c := rec.Err[i]
+			if c == '\n' || c == '\r' {
+				c = ' '
+			}
+			b = append(b, c)
+		}
+	}
+	return append(b, '\n')
+}
+
+// ParseRecord decodes a line written by a Trace, with or without its newline.
+func ParseRecord(line string) (Record, error) {
+	var rec Record
+	f := strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 7)
+	if len(f) != 7 || f[0] != "i2c" {
+		return rec, errBadRecord
+	}
+	start, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		return rec, errBadRecord
+	}
+	duration, err := strconv.ParseInt(f[2], 10, 64)
+	if err != nil {
+		return rec, errBadRecord
+	}
+	addr, err := strconv.ParseUint(f[3], 16, 16)
+	if err != nil {
+		return rec, errBadRecord
+	}
+	rec.Start = time.Duration(start) * time.Microsecond
+	rec.Duration = time.Duration(duration) * time.Microsecond
+	rec.Addr = uint16(addr)
+	if rec.W, err = parseHex(f[4]); err != nil {
+		return rec, err
+	}
+	if rec.R, err = parseHex(f[5]); err != nil {
+		return rec, err
+	}
+	if f[6] != "ok" {
+		rec.Err = f[6]
+	}
+	return rec, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+func appendHex(b, data []byte) []byte {
+	if len(data) == 0 {
+		return append(b, '-')
+	}
+	for _, c := range data {
+		b = append(b, hexDigits[c>>4], hexDigits[c&0xf])
+	}
+	return b
+}
+
+func parseHex(s string) ([]byte, error) {
+	if s == "-" {
+		return nil, nil
+	}
+	if len(s)%2 != 0 {
+		return nil, errBadRecord
+	}
+	data := make([]byte, len(s)/2)
+	for i := range data {
+		c, err := strconv.ParseUint(s[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return nil, errBadRecord
+		}
+		data[i] = uint8(c)
+	}
+	return data, nil
+}
@@ -0,0 +1,48 @@
+package i2cbus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestTrace(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewI2CBus(c)
+	bus.NACK = true
+	bus.NewDevice(0x76).Registers[0xD0] = 0x60
+	var log bytes.Buffer
+	trace := NewTrace(bus, &log)
+
+	buf := make([]byte, 1)
+	c.Assert(trace.Tx(0x76, []byte{0xD0}, buf), qt.IsNil)
+	c.Assert(trace.Tx(0x76, []byte{0xF4, 0x27}, nil), qt.IsNil)
+	c.Assert(trace.Tx(0x29, []byte{0x01, 0x0F}, make([]byte, 1)), qt.Equals, tester.ErrNACK)
+
+	lines := strings.SplitAfter(log.String(), "\n")
+	c.Assert(lines, qt.HasLen, 4)
+	c.Assert(lines[0], qt.Matches, `i2c \d+ \d+ 76 d0 60 ok\n`)
+	c.Assert(lines[1], qt.Matches, `i2c \d+ \d+ 76 f427 - ok\n`)
+	c.Assert(lines[2], qt.Matches, `i2c \d+ \d+ 29 010f - tester: I2C address not acknowledged\n`)
+
+	rec, err := ParseRecord(lines[2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(rec.Addr, qt.Equals, uint16(0x29))
+	c.Assert(rec.W, qt.DeepEquals, []byte{0x01, 0x0F})
+	c.Assert(rec.R, qt.IsNil)
+	c.Assert(rec.Err, qt.Equals, tester.ErrNACK.Error())
+	c.Assert(string(rec.AppendText(nil)), qt.Equals, lines[2])
+
+	rec, err = ParseRecord(lines[1])
+	c.Assert(err, qt.IsNil)
+	c.Assert(rec.R, qt.IsNil)
+	c.Assert(rec.Err, qt.Equals, "")
+
+	for _, line := range []string{"", "hello", "i2c 1 2 76 d 60 ok", "i2c x 2 76 d0 60 ok", "i2c 1 2 76 d0 60"} {
+		_, err = ParseRecord(line)
+		c.Assert(err, qt.Equals, errBadRecord, qt.Commentf("%q", line))
+	}
+}
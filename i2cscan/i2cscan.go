@@ -0,0 +1,163 @@
+// Package i2cscan finds the devices on an I2C bus and identifies the chips
+// supported by the drivers in this repository, to check a board without a
+// logic analyzer.
+//
+// A chip is identified by reading its ID or WHO_AM_I register. Doing so
+// writes the register address to every device that acknowledges one of the
+// addresses of the chip, which could change the state of a chip that is not
+// in the table. Scan itself only reads a byte from each address.
+package i2cscan // import "tinygo.org/x/drivers/i2cscan"
+
+import "tinygo.org/x/drivers"
+
+// The range of addresses that is scanned. The others are reserved by the I2C
+// specification.
+const (
+	FirstAddress = 0x08
+	LastAddress  = 0x77
+)
+
+// Chip describes how to identify a chip: the value read from Register,
+// masked with Mask, is Value.
+type Chip struct {
+	// Name is the name of the driver package.
+	Name string
+
+	// Addresses are the addresses at which the chip can be.
+	Addresses []uint8
+
+	// Register is the address of the ID register. Wide is set for chips
+	// with 16-bit register addresses, which are sent high byte first.
+	Register uint16
+	Wide     bool
+
+	// Size is the number of bytes that are read, 1 or 2. Two bytes are big
+	// endian.
+	Size int
+
+	// A zero Mask compares all the bits.
+	Mask  uint16
+	Value uint16
+}
+
+// Chips are the chips that Identify and Detect know about. An application
+// can add its own.
+var Chips = []Chip{
+	{Name: "bme280", Addresses: []uint8{0x76, 0x77}, Register: 0xD0, Size: 1, Value: 0x60},
+	{Name: "bmp280", Addresses: []uint8{0x76, 0x77}, Register: 0xD0, Size: 1, Value: 0x58},
+	{Name: "bmp180", Addresses: []uint8{0x77}, Register: 0xD0, Size: 1, Value: 0x55},
+	{Name: "bmp388", Addresses: []uint8{0x76, 0x77}, Register: 0x00, Size: 1, Value: 0x50},
+	{Name: "lis3dh", Addresses: []uint8{0x18, 0x19}, Register: 0x0F, Size: 1, Value: 0x33},
+	{Name: "lsm303agr", Addresses: []uint8{0x19}, Register: 0x0F, Size: 1, Value: 0x33},
+	{Name: "lsm303agr", Addresses: []uint8{0x1E}, Register: 0x4F, Size: 1, Value: 0x40},
+	{Name: "lis2mdl", Addresses: []uint8{0x1E}, Register: 0x4F, Size: 1, Value: 0x40},
+	{Name: "lsm9ds1", Addresses: []uint8{0x6A, 0x6B}, Register: 0x0F, Size: 1, Value: 0x68},
+	{Name: "lsm9ds1", Addresses: []uint8{0x1C, 0x1E}, Register: 0x0F, Size: 1, Value: 0x3D},
+	{Name: "mpu6050", Addresses: []uint8{0x68, 0x69}, Register: 0x75, Size: 1, Value: 0x68},
+	{Name: "mpu6886", Addresses: []uint8{0x68, 0x69}, Register: 0x75, Size: 1, Value: 0x19},
+	{Name: "lsm6ds3", Addresses: []uint8{0x6A, 0x6B}, Register: 0x0F, Size: 1, Value: 0x69},
+	{Name: "lsm6ds3tr", Addresses: []uint8{0x6A, 0x6B}, Register: 0x0F, Size: 1, Value: 0x6A},
+	{Name: "lsm6dsox", Addresses: []uint8{0x6A, 0x6B}, Register: 0x0F, Size: 1, Value: 0x6C},
+	{Name: "qmi8658c", Addresses: []uint8{0x6A, 0x6B}, Register: 0x00, Size: 1, Value: 0x05},
+	{Name: "hts221", Addresses: []uint8{0x5F}, Register: 0x0F, Size: 1, Value: 0xBC},
+	{Name: "lps22hb", Addresses: []uint8{0x5C, 0x5D}, Register: 0x0F, Size: 1, Value: 0xB1},
+	{Name: "mag3110", Addresses: []uint8{0x0E}, Register: 0x07, Size: 1, Value: 0xC4},
+	{Name: "mma8653", Addresses: []uint8{0x1D}, Register: 0x0D, Size: 1, Value: 0x5A},
+	{Name: "apds9960", Addresses: []uint8{0x39}, Register: 0x92, Size: 1, Value: 0xAB},
+	{Name: "adt7410", Addresses: []uint8{0x48, 0x49, 0x4A, 0x4B}, Register: 0x0B, Size: 1, Mask: 0xF8, Value: 0xC8},
+	// The TMP102 has no ID register, its configuration register is checked
+	// for the value it has after a reset.
+	{Name: "tmp102", Addresses: []uint8{0x48, 0x49, 0x4A, 0x4B}, Register: 0x01, Size: 2, Value: 0x60A0},
+	{Name: "ina260", Addresses: []uint8{0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F}, Register: 0xFF, Size: 2, Mask: 0xFFF0, Value: 0x2270},
+	{Name: "vl53l1x", Addresses: []uint8{0x29}, Register: 0x010F, Wide: true, Size: 2, Value: 0xEACC},
+	{Name: "vl6180x", Addresses: []uint8{0x29}, Register: 0x0000, Wide: true, Size: 1, Value: 0xB4},
+}
+
+// Device is a device found by Detect.
+type Device struct {
+	Address uint8
+
+	// Candidates are the names of the chips that the device can be. It is
+	// empty for a device that is not known.
+	Candidates []string
+}
+
+// Probe returns whether a device acknowledges addr, by reading a byte from
+// it.
+func Probe(bus drivers.I2C, addr uint8) bool {
+	var buf [1]byte
+	return bus.Tx(uint16(addr), nil, buf[:]) == nil
+}
+
+// Scan returns the addresses of the devices on the bus.
+func Scan(bus drivers.I2C) []uint8 {
+	var found []uint8
+	for addr := uint8(FirstAddress); addr <= LastAddress; addr++ {
+		if Probe(bus, addr) {
+			found = append(found, addr)
+		}
+	}
+	return found
+}
+
+// Identify returns the names of the chips whose ID is read at addr, in the
+// order of Chips.
+func Identify(bus drivers.I2C, addr uint8) []string {
+	var names []string
+	for i := range Chips {
+		c := &Chips[i]
+		if !c.at(addr) {
+			continue
+		}
+		if c.Match(bus, addr) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// Detect scans the bus and identifies the devices that it finds.
+func Detect(bus drivers.I2C) []Device {
+	var devices []Device
+	for _, addr := range Scan(bus) {
+		devices = append(devices, Device{Address: addr, Candidates: Identify(bus, addr)})
+	}
+	return devices
+}
+
+// Match reads the ID register of the chip at addr and returns whether it has
+// the expected value.
+func (c *Chip) Match(bus drivers.I2C, addr uint8) bool {
+	var w, r [2]byte
+	reg := w[:1]
+	w[0] = uint8(c.Register)
+	if c.Wide {
+		w[0], w[1] = uint8(c.Register>>8), uint8(c.Register)
+		reg = w[:2]
+	}
+	id := r[:1]
+	if c.Size == 2 {
+		id = r[:2]
+	}
+	if bus.Tx(uint16(addr), reg, id) != nil {
+		return false
+	}
+	v := uint16(r[0])
+	if c.Size == 2 {
+		v = v<<8 | uint16(r[1])
+	}
+	mask := c.Mask
+	if mask == 0 {
+		mask = 0xFFFF
+	}
+	return v&mask == c.Value
+}
+
+func (c *Chip) at(addr uint8) bool {
+	for _, a := range c.Addresses {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package i2cscan
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestDetect(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewI2CBus(c)
+	bus.NACK = true
+	bus.NewDevice(0x76).Registers[0xD0] = 0x60
+	bus.NewDevice(0x18).Registers[0x0F] = 0x33
+	bus.NewDevice(0x68).Registers[0x75] = 0x68
+	bus.NewDevice(0x1E).Registers[0x4F] = 0x40
+	// A display, which has no ID register.
+	bus.NewDevice(0x3C)
+
+	c.Assert(Scan(bus), qt.DeepEquals, []uint8{0x18, 0x1E, 0x3C, 0x68, 0x76})
+	c.Assert(Detect(bus), qt.DeepEquals, []Device{
+		{Address: 0x18, Candidates: []string{"lis3dh"}},
+		{Address: 0x1E, Candidates: []string{"lsm303agr", "lis2mdl"}},
+		{Address: 0x3C},
+		{Address: 0x68, Candidates: []string{"mpu6050"}},
+		{Address: 0x76, Candidates: []string{"bme280"}},
+	})
+}
+
+func TestIdentify(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewI2CBus(c)
+	bus.NACK = true
+	// The chips at the same addresses are told apart by their ID.
+	bus.NewDevice(0x6A).Registers[0x0F] = 0x6C
+	c.Assert(Identify(bus, 0x6A), qt.DeepEquals, []string{"lsm6dsox"})
+	c.Assert(Identify(bus, 0x6B), qt.IsNil)
+}
+
+// txFunc is a bus with devices that have 16-bit register addresses.
+type txFunc func(addr uint16, w, r []byte) error
+
+func (f txFunc) Tx(addr uint16, w, r []byte) error { return f(addr, w, r) }
+
+func TestIdentifyWide(t *testing.T) {
+	c := qt.New(t)
+	regs := map[uint16][]byte{0x010F: {0xEA, 0xCC}}
+	bus := txFunc(func(addr uint16, w, r []byte) error {
+		if addr != 0x29 {
+			return tester.ErrNACK
+		}
+		if len(w) == 2 {
+			copy(r, regs[uint16(w[0])<<8|uint16(w[1])])
+		}
+		return nil
+	})
+	c.Assert(Detect(bus), qt.DeepEquals, []Device{{Address: 0x29, Candidates: []string{"vl53l1x"}}})
+}
@@ -3,10 +3,11 @@ package ili9341
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+	"tinygo.org/x/drivers/pixel"
 )
 
 type Config struct {
@@ -25,10 +26,10 @@ type Device struct {
 	x0, x1 int16 // cached address window; prevents useless/expensive
 	y0, y1 int16 // syscalls to PASET and CASET
 
-	dc  machine.Pin
-	cs  machine.Pin
-	rst machine.Pin
-	rd  machine.Pin
+	dc  drivers.PinOutput
+	cs  drivers.PinOutput
+	rst drivers.PinOutput
+	rd  drivers.PinOutput
 }
 
 var cmdBuf [6]byte
@@ -75,29 +76,27 @@ func (d *Device) Configure(config Config) {
 	d.x0, d.x1 = -(d.width + 1), d.x0
 	d.y0, d.y1 = -(d.height + 1), d.y0
 
-	output := machine.PinConfig{machine.PinOutput}
-
 	// configure chip select if there is one
-	if d.cs != machine.NoPin {
-		d.cs.Configure(output)
+	if !legacy.PinIsNoPin(d.cs) {
+		legacy.ConfigurePin(d.cs, drivers.PinModeOutput)
 		d.cs.High() // deselect
 	}
 
-	d.dc.Configure(output)
+	legacy.ConfigurePin(d.dc, drivers.PinModeOutput)
 	d.dc.High() // data mode
 
 	// driver-specific configuration
 	d.driver.configure(&config)
 
-	if d.rd != machine.NoPin {
-		d.rd.Configure(output)
+	if !legacy.PinIsNoPin(d.rd) {
+		legacy.ConfigurePin(d.rd, drivers.PinModeOutput)
 		d.rd.High()
 	}
 
 	// reset the display
-	if d.rst != machine.NoPin {
+	if !legacy.PinIsNoPin(d.rst) {
 		// configure hardware reset if there is one
-		d.rst.Configure(output)
+		legacy.ConfigurePin(d.rst, drivers.PinModeOutput)
 		d.rst.High()
 		delay(100)
 		d.rst.Low()
@@ -200,6 +199,25 @@ func (d *Device) DrawRGBBitmap8(x, y int16, data []uint8, w, h int16) error {
 	return nil
 }
 
+// PixelFormat returns the format of the pixels of DrawBitmap.
+func (d *Device) PixelFormat() pixel.Format {
+	return pixel.RGB565
+}
+
+// DrawBitmap copies an RGB565 image to the display at given coordinates.
+func (d *Device) DrawBitmap(x, y int16, img pixel.Image) error {
+	if img.Format() != pixel.RGB565 {
+		return pixel.ErrFormat
+	}
+	w, h := img.Size()
+	return d.DrawRGBBitmap8(x, y, img.Bytes(), w, h)
+}
+
+// FillRect fills a rectangle at given coordinates with a color.
+func (d *Device) FillRect(x, y, width, height int16, c color.RGBA) error {
+	return d.FillRectangle(x, y, width, height, c)
+}
+
 // FillRectangle fills a rectangle at given coordinates with a color
 func (d *Device) FillRectangle(x, y, width, height int16, c color.RGBA) error {
 	k, i := d.Size()
@@ -374,14 +392,14 @@ func (d *Device) setWindow(x, y, w, h int16) {
 
 //go:inline
 func (d *Device) startWrite() {
-	if d.cs != machine.NoPin {
+	if !legacy.PinIsNoPin(d.cs) {
 		d.cs.Low()
 	}
 }
 
 //go:inline
 func (d *Device) endWrite() {
-	if d.cs != machine.NoPin {
+	if !legacy.PinIsNoPin(d.cs) {
 		d.cs.High()
 	}
 }
@@ -3,8 +3,6 @@
 package ili9341
 
 import (
-	"machine"
-
 	"tinygo.org/x/drivers"
 )
 
@@ -14,12 +12,11 @@ type spiDriver struct {
 	bus drivers.SPI
 }
 
-func NewSPI(bus drivers.SPI, dc, cs, rst machine.Pin) *Device {
+func NewSPI(bus drivers.SPI, dc, cs, rst drivers.PinOutput) *Device {
 	return &Device{
 		dc:  dc,
 		cs:  cs,
 		rst: rst,
-		rd:  machine.NoPin,
 		driver: &spiDriver{
 			bus: bus,
 		},
@@ -5,18 +5,19 @@ package ili9341
 import (
 	"device/sam"
 	"machine"
+
+	"tinygo.org/x/drivers"
 )
 
 type spiDriver struct {
 	bus machine.SPI
 }
 
-func NewSPI(bus machine.SPI, dc, cs, rst machine.Pin) *Device {
+func NewSPI(bus machine.SPI, dc, cs, rst drivers.PinOutput) *Device {
 	return &Device{
 		dc:  dc,
 		cs:  cs,
 		rst: rst,
-		rd:  machine.NoPin,
 		driver: &spiDriver{
 			bus: bus,
 		},
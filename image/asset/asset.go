@@ -0,0 +1,299 @@
+// Package asset provides access to pre-decoded bitmaps produced by the
+// cmd/assetpack tool.
+//
+// Bitmaps are stored either as Go source (a Bitmap value whose Data lives in
+// flash as a string constant) or packed together into a single binary blob
+// with an index. A pack can be read from anything implementing io.ReaderAt,
+// such as flash.Device, sdcard.Device or a strings.Reader.
+//
+// Pixel data is stored row by row in one of the following formats:
+//
+//	FormatRGB565: 2 bytes per pixel, big endian (ready for DrawRGBBitmap8)
+//	FormatMono:   1 bit per pixel, MSB first, rows padded to a whole byte
+//	FormatGray4:  4 bits per pixel, high nibble first, rows padded to a whole byte
+//	FormatRaw:    the original file (for example a PNG for image/png)
+package asset // import "tinygo.org/x/drivers/image/asset"
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Format is the pixel format of a stored bitmap.
+type Format uint8
+
+const (
+	FormatRaw Format = iota
+	FormatRGB565
+	FormatMono
+	FormatGray4
+)
+
+// String returns the name of the format as accepted by cmd/assetpack.
+func (f Format) String() string {
+	switch f {
+	case FormatRaw:
+		return "raw"
+	case FormatRGB565:
+		return "rgb565"
+	case FormatMono:
+		return "mono"
+	case FormatGray4:
+		return "gray4"
+	}
+	return "unknown"
+}
+
+// RowSize returns the number of bytes used by a single row of width pixels.
+// It returns 0 for FormatRaw.
+func (f Format) RowSize(width int) int {
+	switch f {
+	case FormatRGB565:
+		return width * 2
+	case FormatMono:
+		return (width + 7) / 8
+	case FormatGray4:
+		return (width + 1) / 2
+	}
+	return 0
+}
+
+// unit is the element size used by the RLE compressor for this format.
+func (f Format) unit() int {
+	if f == FormatRGB565 {
+		return 2
+	}
+	return 1
+}
+
+// Compression is the compression method of a stored bitmap.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionRLE
+	CompressionLZ
+)
+
+// String returns the name of the compression as accepted by cmd/assetpack.
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionRLE:
+		return "rle"
+	case CompressionLZ:
+		return "lz"
+	}
+	return "unknown"
+}
+
+var (
+	ErrBadMagic       = errors.New("asset: not an asset pack")
+	ErrNotFound       = errors.New("asset: not found")
+	ErrCorrupt        = errors.New("asset: corrupt data")
+	ErrBadCompression = errors.New("asset: unsupported compression")
+)
+
+// Bitmap is a single asset embedded in Go source.
+type Bitmap struct {
+	Width       int16
+	Height      int16
+	Format      Format
+	Compression Compression
+	Data        string
+}
+
+// Reader returns a reader for the uncompressed pixel data of the bitmap. It
+// returns ErrBadCompression if the compression is unknown.
+func (b *Bitmap) Reader() (io.Reader, error) {
+	return NewReader(strings.NewReader(b.Data), b.Format, b.Compression)
+}
+
+// NewReader returns a reader that decompresses data stored with the given
+// format and compression.
+func NewReader(r io.Reader, f Format, c Compression) (io.Reader, error) {
+	switch c {
+	case CompressionNone:
+		return r, nil
+	case CompressionRLE:
+		return &rleReader{r: r, unit: f.unit()}, nil
+	case CompressionLZ:
+		return &lzReader{r: r}, nil
+	}
+	return nil, ErrBadCompression
+}
+
+// Pack layout (all values little endian):
+//
+//	magic    [4]byte "TGAP"
+//	count    uint16
+//	reserved uint16
+//	entries  [count]struct {
+//		name        [16]byte, NUL padded
+//		format      uint8
+//		compression uint8
+//		reserved    uint16
+//		width       uint16
+//		height      uint16
+//		offset      uint32, relative to the start of the pack
+//		size        uint32, stored (compressed) size
+//		rawSize     uint32, uncompressed size
+//	}
+//	data
+const (
+	packMagic      = "TGAP"
+	packHeaderSize = 8
+	entrySize      = 36
+
+	// MaxNameLen is the maximum length of an asset name in a pack.
+	MaxNameLen = 16
+)
+
+// Entry describes a single asset in a pack.
+type Entry struct {
+	Name        string
+	Format      Format
+	Compression Compression
+	Width       int16
+	Height      int16
+	Offset      uint32
+	Size        uint32
+	RawSize     uint32
+}
+
+// Pack is an index over a blob of assets created by cmd/assetpack -pack.
+type Pack struct {
+	r     io.ReaderAt
+	base  int64
+	count int
+	buf   [entrySize]byte
+}
+
+// Open reads the pack header stored at offset off of r.
+func Open(r io.ReaderAt, off int64) (*Pack, error) {
+	p := &Pack{r: r, base: off}
+	hdr := p.buf[:packHeaderSize]
+	if _, err := r.ReadAt(hdr, off); err != nil {
+		return nil, err
+	}
+	if string(hdr[:4]) != packMagic {
+		return nil, ErrBadMagic
+	}
+	p.count = int(binary.LittleEndian.Uint16(hdr[4:]))
+	return p, nil
+}
+
+// Len returns the number of assets in the pack.
+func (p *Pack) Len() int {
+	return p.count
+}
+
+// Entry returns the index entry of the i-th asset.
+func (p *Pack) Entry(i int) (Entry, error) {
+	if i < 0 || i >= p.count {
+		return Entry{}, ErrNotFound
+	}
+	b := p.buf[:]
+	if _, err := p.r.ReadAt(b, p.base+packHeaderSize+int64(i)*entrySize); err != nil {
+		return Entry{}, err
+	}
+	n := 0
+	for n < MaxNameLen && b[n] != 0 {
+		n++
+	}
+	return Entry{
+		Name:        string(b[:n]),
+		Format:      Format(b[16]),
+		Compression: Compression(b[17]),
+		Width:       int16(binary.LittleEndian.Uint16(b[20:])),
+		Height:      int16(binary.LittleEndian.Uint16(b[22:])),
+		Offset:      binary.LittleEndian.Uint32(b[24:]),
+		Size:        binary.LittleEndian.Uint32(b[28:]),
+		RawSize:     binary.LittleEndian.Uint32(b[32:]),
+	}, nil
+}
+
+// Lookup returns the index entry of the asset with the given name.
+func (p *Pack) Lookup(name string) (Entry, error) {
+	for i := 0; i < p.count; i++ {
+		e, err := p.Entry(i)
+		if err != nil {
+			return Entry{}, err
+		}
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// Reader returns a reader for the uncompressed data of the asset e.
+func (p *Pack) Reader(e Entry) (io.Reader, error) {
+	sr := io.NewSectionReader(p.r, p.base+int64(e.Offset), int64(e.Size))
+	return NewReader(sr, e.Format, e.Compression)
+}
+
+// PackWriter builds an asset pack. It is used by cmd/assetpack but can also
+// be used to create packs at runtime.
+type PackWriter struct {
+	entries []Entry
+	data    []byte
+}
+
+// Add appends an asset whose data has already been compressed with e.Compression.
+// The Offset and Size fields of e are filled in by Add.
+func (w *PackWriter) Add(e Entry, data []byte) error {
+	if len(e.Name) > MaxNameLen {
+		return errors.New("asset: name too long: " + e.Name)
+	}
+	if len(w.entries) == 0xffff {
+		return errors.New("asset: too many assets")
+	}
+	e.Offset = uint32(len(w.data))
+	e.Size = uint32(len(data))
+	w.entries = append(w.entries, e)
+	w.data = append(w.data, data...)
+	return nil
+}
+
+// WriteTo writes the pack to out.
+func (w *PackWriter) WriteTo(out io.Writer) (int64, error) {
+	hdrSize := packHeaderSize + len(w.entries)*entrySize
+	hdr := make([]byte, hdrSize)
+	copy(hdr, packMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], uint16(len(w.entries)))
+	for i, e := range w.entries {
+		b := hdr[packHeaderSize+i*entrySize:]
+		copy(b[:MaxNameLen], e.Name)
+		b[16] = byte(e.Format)
+		b[17] = byte(e.Compression)
+		binary.LittleEndian.PutUint16(b[20:], uint16(e.Width))
+		binary.LittleEndian.PutUint16(b[22:], uint16(e.Height))
+		binary.LittleEndian.PutUint32(b[24:], e.Offset+uint32(hdrSize))
+		binary.LittleEndian.PutUint32(b[28:], e.Size)
+		binary.LittleEndian.PutUint32(b[32:], e.RawSize)
+	}
+	n, err := out.Write(hdr)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := out.Write(w.data)
+	return int64(n + m), err
+}
+
+// Compress compresses src with the given method, using the element size of
+// format f for run length encoding.
+func Compress(src []byte, f Format, c Compression) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return src, nil
+	case CompressionRLE:
+		return compressRLE(src, f.unit()), nil
+	case CompressionLZ:
+		return compressLZ(src), nil
+	}
+	return nil, ErrBadCompression
+}
@@ -0,0 +1,119 @@
+package asset
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func testInputs() map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	noise := make([]byte, 3001)
+	rnd.Read(noise)
+	runs := make([]byte, 0, 5000)
+	for len(runs) < 5000 {
+		b := byte(rnd.Intn(4))
+		for n := rnd.Intn(300); n > 0; n-- {
+			runs = append(runs, b)
+		}
+	}
+	return map[string][]byte{
+		"empty":  {},
+		"single": {0x42},
+		"odd":    {1, 2, 1, 2, 1, 2, 1},
+		"solid":  bytes.Repeat([]byte{0xf8, 0x00}, 2000),
+		"noise":  noise,
+		"runs":   runs,
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	c := qt.New(t)
+	for name, src := range testInputs() {
+		for _, f := range []Format{FormatMono, FormatRGB565} {
+			for _, comp := range []Compression{CompressionNone, CompressionRLE, CompressionLZ} {
+				data, err := Compress(src, f, comp)
+				c.Assert(err, qt.IsNil)
+				r, err := NewReader(bytes.NewReader(data), f, comp)
+				c.Assert(err, qt.IsNil)
+				out, err := ioutil.ReadAll(r)
+				c.Assert(err, qt.IsNil, qt.Commentf("%s %s %s", name, f, comp))
+				c.Assert(out, qt.DeepEquals, src, qt.Commentf("%s %s %s", name, f, comp))
+			}
+		}
+	}
+}
+
+func TestCompressSolid(t *testing.T) {
+	c := qt.New(t)
+	src := testInputs()["solid"]
+	rle, _ := Compress(src, FormatRGB565, CompressionRLE)
+	c.Assert(len(rle) < len(src)/40, qt.IsTrue)
+	lz, _ := Compress(src, FormatRGB565, CompressionLZ)
+	c.Assert(len(lz) < len(src)/10, qt.IsTrue)
+}
+
+func TestPack(t *testing.T) {
+	c := qt.New(t)
+	src := testInputs()["runs"]
+
+	var w PackWriter
+	data, _ := Compress(src, FormatGray4, CompressionLZ)
+	err := w.Add(Entry{Name: "runs", Format: FormatGray4, Compression: CompressionLZ, Width: 100, Height: 100, RawSize: uint32(len(src))}, data)
+	c.Assert(err, qt.IsNil)
+	err = w.Add(Entry{Name: "logo.png", Format: FormatRaw}, []byte("\x89PNG"))
+	c.Assert(err, qt.IsNil)
+	err = w.Add(Entry{Name: "a-name-that-is-too-long"}, nil)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	c.Assert(err, qt.IsNil)
+
+	// Store the pack at an offset to check that offsets are relative.
+	blob := append(make([]byte, 100), buf.Bytes()...)
+	p, err := Open(bytes.NewReader(blob), 100)
+	c.Assert(err, qt.IsNil)
+	c.Assert(p.Len(), qt.Equals, 2)
+
+	e, err := p.Lookup("runs")
+	c.Assert(err, qt.IsNil)
+	c.Assert(e.Width, qt.Equals, int16(100))
+	c.Assert(e.Format, qt.Equals, FormatGray4)
+	r, err := p.Reader(e)
+	c.Assert(err, qt.IsNil)
+	out, err := ioutil.ReadAll(r)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.DeepEquals, src)
+
+	e, err = p.Lookup("logo.png")
+	c.Assert(err, qt.IsNil)
+	r, _ = p.Reader(e)
+	out, _ = ioutil.ReadAll(r)
+	c.Assert(string(out), qt.Equals, "\x89PNG")
+
+	_, err = p.Lookup("missing")
+	c.Assert(err, qt.Equals, ErrNotFound)
+
+	_, err = Open(bytes.NewReader(blob), 0)
+	c.Assert(err, qt.Equals, ErrBadMagic)
+}
+
+func TestBitmapReader(t *testing.T) {
+	c := qt.New(t)
+	src := []byte{0xff, 0xff, 0xff, 0xff, 0x00, 0x1f}
+	data, _ := Compress(src, FormatRGB565, CompressionRLE)
+	b := Bitmap{Width: 3, Height: 1, Format: FormatRGB565, Compression: CompressionRLE, Data: string(data)}
+	r, err := b.Reader()
+	c.Assert(err, qt.IsNil)
+	out, err := ioutil.ReadAll(r)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.DeepEquals, src)
+
+	b.Compression = 7
+	_, err = b.Reader()
+	c.Assert(err, qt.Equals, ErrBadCompression)
+}
@@ -0,0 +1,148 @@
+package asset
+
+import "io"
+
+// The LZ stream is an LZSS variant that can be decoded with a small, fixed
+// size window. Items are grouped by eight behind a flag byte, where bit i
+// (LSB first) tells whether item i is a literal byte (0) or a match (1).
+// A match is two bytes:
+//
+//	b0:  low 8 bits of distance-1
+//	b1:  bits 0-1: high 2 bits of distance-1, bits 2-7: length-3
+const (
+	lzWindowSize = 1024
+	lzMinMatch   = 3
+	lzMaxMatch   = lzMinMatch + 63
+)
+
+func compressLZ(src []byte) []byte {
+	var dst []byte
+	// Candidate positions indexed by a hash of the next three bytes.
+	const hashSize = 1 << 12
+	const maxChain = 64
+	head := make([]int32, hashSize)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(src))
+	hash := func(i int) int {
+		return int((uint32(src[i])<<8^uint32(src[i+1])<<4^uint32(src[i+2]))*2654435761>>20) & (hashSize - 1)
+	}
+	insert := func(i int) {
+		if i+lzMinMatch <= len(src) {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+
+	flagPos := -1
+	bit := uint(8)
+	item := func(match bool) {
+		if bit == 8 {
+			flagPos = len(dst)
+			dst = append(dst, 0)
+			bit = 0
+		}
+		if match {
+			dst[flagPos] |= 1 << bit
+		}
+		bit++
+	}
+
+	for i := 0; i < len(src); {
+		bestLen, bestDist := 0, 0
+		if i+lzMinMatch <= len(src) {
+			for c, j := 0, int(head[hash(i)]); j >= 0 && i-j <= lzWindowSize && c < maxChain; c, j = c+1, int(prev[j]) {
+				l := 0
+				for l < lzMaxMatch && i+l < len(src) && src[j+l] == src[i+l] {
+					l++
+				}
+				if l > bestLen {
+					bestLen, bestDist = l, i-j
+					if l == lzMaxMatch {
+						break
+					}
+				}
+			}
+		}
+		if bestLen >= lzMinMatch {
+			item(true)
+			d := bestDist - 1
+			dst = append(dst, byte(d), byte(d>>8)|byte(bestLen-lzMinMatch)<<2)
+			for k := 0; k < bestLen; k++ {
+				insert(i + k)
+			}
+			i += bestLen
+		} else {
+			item(false)
+			dst = append(dst, src[i])
+			insert(i)
+			i++
+		}
+	}
+	return dst
+}
+
+type lzReader struct {
+	r      io.Reader
+	flags  byte
+	nflags uint8 // items left in the current group
+	dist   int   // distance of the current match
+	count  int   // bytes left in the current match
+	pos    int   // write position in window
+	err    error
+	window [lzWindowSize]byte
+}
+
+func (d *lzReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if d.count > 0 {
+			b := d.window[(d.pos-d.dist)&(lzWindowSize-1)]
+			d.window[d.pos] = b
+			d.pos = (d.pos + 1) & (lzWindowSize - 1)
+			p[n] = b
+			n++
+			d.count--
+			continue
+		}
+		if d.err != nil {
+			break
+		}
+		var buf [2]byte
+		if d.nflags == 0 {
+			if _, err := io.ReadFull(d.r, buf[:1]); err != nil {
+				d.err = err
+				break
+			}
+			d.flags = buf[0]
+			d.nflags = 8
+		}
+		match := d.flags&1 != 0
+		d.flags >>= 1
+		d.nflags--
+		if !match {
+			if _, err := io.ReadFull(d.r, buf[:1]); err != nil {
+				// The last group may contain fewer than eight items.
+				d.err = err
+				break
+			}
+			d.window[d.pos] = buf[0]
+			d.pos = (d.pos + 1) & (lzWindowSize - 1)
+			p[n] = buf[0]
+			n++
+			continue
+		}
+		if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+			d.err = eofToCorrupt(err)
+			break
+		}
+		d.dist = (int(buf[0]) | int(buf[1]&0x03)<<8) + 1
+		d.count = int(buf[1]>>2) + lzMinMatch
+	}
+	if n == 0 && d.err != nil {
+		return 0, d.err
+	}
+	return n, nil
+}
@@ -0,0 +1,132 @@
+package asset
+
+import "io"
+
+// The RLE stream is a sequence of runs. Each run starts with a control byte c
+// and operates on units of one or two bytes (depending on the pixel format):
+//
+//	c < 0x80:  c+1 literal units follow
+//	c >= 0x80: a single unit follows which is repeated c-0x80+2 times
+const (
+	rleMaxLiteral = 0x80
+	rleMaxRepeat  = 0x81
+)
+
+func compressRLE(src []byte, unit int) []byte {
+	var dst []byte
+	n := len(src) / unit
+	at := func(i int) []byte { return src[i*unit : (i+1)*unit] }
+	same := func(i, j int) bool {
+		for k := 0; k < unit; k++ {
+			if src[i*unit+k] != src[j*unit+k] {
+				return false
+			}
+		}
+		return true
+	}
+	lit := 0 // start of the pending literal run
+	i := 0
+	flush := func(end int) {
+		for lit < end {
+			l := end - lit
+			if l > rleMaxLiteral {
+				l = rleMaxLiteral
+			}
+			dst = append(dst, byte(l-1))
+			dst = append(dst, src[lit*unit:(lit+l)*unit]...)
+			lit += l
+		}
+	}
+	for i < n {
+		j := i + 1
+		for j < n && j-i < rleMaxRepeat && same(i, j) {
+			j++
+		}
+		if j-i >= 2 {
+			flush(i)
+			dst = append(dst, byte(0x80+j-i-2))
+			dst = append(dst, at(i)...)
+			lit = j
+		}
+		i = j
+	}
+	flush(n)
+	// Trailing bytes that don't form a whole unit are stored as a literal.
+	if rest := src[n*unit:]; len(rest) > 0 {
+		dst = append(dst, 0)
+		dst = append(dst, rest...)
+	}
+	return dst
+}
+
+type rleReader struct {
+	r      io.Reader
+	unit   int
+	repeat bool
+	count  int // units left in the current run
+	pos    int // position within buf
+	buf    [2]byte
+	err    error
+}
+
+func (d *rleReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if d.count == 0 {
+			if d.err != nil {
+				break
+			}
+			var c [1]byte
+			if _, err := io.ReadFull(d.r, c[:]); err != nil {
+				d.err = err
+				break
+			}
+			d.pos = 0
+			if c[0] < 0x80 {
+				d.repeat = false
+				d.count = int(c[0]) + 1
+			} else {
+				d.repeat = true
+				d.count = int(c[0]) - 0x80 + 2
+			}
+			if d.repeat {
+				if _, err := io.ReadFull(d.r, d.buf[:d.unit]); err != nil {
+					d.err = eofToCorrupt(err)
+					d.count = 0
+					break
+				}
+			}
+		}
+		if d.repeat {
+			p[n] = d.buf[d.pos]
+		} else {
+			// Literal units are read byte by byte. A short literal at the end
+			// of the stream is allowed for a trailing partial unit.
+			if _, err := io.ReadFull(d.r, p[n:n+1]); err != nil {
+				d.err = eofToCorrupt(err)
+				if err == io.EOF && d.pos != 0 {
+					d.err = io.EOF
+				}
+				d.count = 0
+				break
+			}
+		}
+		n++
+		d.pos++
+		if d.pos == d.unit {
+			d.pos = 0
+			d.count--
+		}
+	}
+	if n == 0 && d.err != nil {
+		return 0, d.err
+	}
+	return n, nil
+}
+
+func eofToCorrupt(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrCorrupt
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+// Package dirty tracks the area of a display framebuffer that changed since it
+// was last sent to the display, so that drivers only transfer that area.
+package dirty
+
+// Rect is a rectangle of a framebuffer, with inclusive bounds in the units the
+// driver addresses its display memory with: pixels, bytes, rows or pages.
+// The zero value is an empty rectangle.
+type Rect struct {
+	X0, Y0 int16
+	X1, Y1 int16
+	dirty  bool
+}
+
+// Add grows the rectangle to include a point.
+func (r *Rect) Add(x, y int16) {
+	if !r.dirty {
+		r.X0, r.Y0, r.X1, r.Y1 = x, y, x, y
+		r.dirty = true
+		return
+	}
+	if x < r.X0 {
+		r.X0 = x
+	}
+	if x > r.X1 {
+		r.X1 = x
+	}
+	if y < r.Y0 {
+		r.Y0 = y
+	}
+	if y > r.Y1 {
+		r.Y1 = y
+	}
+}
+
+// Union grows the rectangle to include another one.
+func (r *Rect) Union(o Rect) {
+	if !o.dirty {
+		return
+	}
+	r.Add(o.X0, o.Y0)
+	r.Add(o.X1, o.Y1)
+}
+
+// All sets the rectangle to a whole framebuffer of width by height units.
+func (r *Rect) All(width, height int16) {
+	r.X0, r.Y0, r.X1, r.Y1 = 0, 0, width-1, height-1
+	r.dirty = width > 0 && height > 0
+}
+
+// Reset empties the rectangle, after it was sent to the display.
+func (r *Rect) Reset() {
+	*r = Rect{}
+}
+
+// Empty returns whether nothing changed.
+func (r *Rect) Empty() bool {
+	return !r.dirty
+}
+
+// IsAll returns whether the rectangle covers a whole framebuffer of width by
+// height units.
+func (r *Rect) IsAll(width, height int16) bool {
+	return r.dirty && r.X0 == 0 && r.Y0 == 0 && r.X1 == width-1 && r.Y1 == height-1
+}
@@ -0,0 +1,35 @@
+package dirty
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRect(t *testing.T) {
+	c := qt.New(t)
+	var r Rect
+	c.Assert(r.Empty(), qt.IsTrue)
+
+	r.Add(5, 3)
+	c.Assert(r.Empty(), qt.IsFalse)
+	c.Assert(r, qt.Equals, Rect{X0: 5, Y0: 3, X1: 5, Y1: 3, dirty: true})
+	r.Add(2, 7)
+	r.Add(9, 0)
+	c.Assert([4]int16{r.X0, r.Y0, r.X1, r.Y1}, qt.Equals, [4]int16{2, 0, 9, 7})
+	c.Assert(r.IsAll(10, 8), qt.IsFalse)
+
+	var o Rect
+	o.Union(r)
+	c.Assert(o, qt.Equals, r)
+	o.Union(Rect{})
+	c.Assert(o, qt.Equals, r)
+
+	r.Reset()
+	c.Assert(r.Empty(), qt.IsTrue)
+	r.All(10, 8)
+	c.Assert(r.IsAll(10, 8), qt.IsTrue)
+	c.Assert([4]int16{r.X0, r.Y0, r.X1, r.Y1}, qt.Equals, [4]int16{0, 0, 9, 7})
+	r.All(0, 8)
+	c.Assert(r.Empty(), qt.IsTrue)
+}
@@ -0,0 +1,19 @@
+package legacy
+
+import "tinygo.org/x/drivers"
+
+// ConfigurePin sets the direction of a pin, which is a machine.Pin or a pin
+// that implements drivers.PinConfigurer. Other pins are left as they are.
+func ConfigurePin(p interface{}, mode drivers.PinMode) {
+	if c, ok := p.(drivers.PinConfigurer); ok {
+		c.ConfigureMode(mode)
+		return
+	}
+	configureMachinePin(p, mode)
+}
+
+// PinIsNoPin returns whether p is nil or machine.NoPin, which drivers accept
+// for optional pins.
+func PinIsNoPin(p interface{}) bool {
+	return p == nil || isMachineNoPin(p)
+}
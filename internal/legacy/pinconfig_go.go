@@ -0,0 +1,13 @@
+//go:build !tinygo
+
+package legacy
+
+import "tinygo.org/x/drivers"
+
+// Without TinyGo there is no machine package, the pins are mocks.
+
+func configureMachinePin(p interface{}, mode drivers.PinMode) {}
+
+func isMachineNoPin(p interface{}) bool {
+	return false
+}
@@ -0,0 +1,31 @@
+//go:build tinygo
+
+package legacy
+
+import (
+	"machine"
+
+	"tinygo.org/x/drivers"
+)
+
+func configureMachinePin(p interface{}, mode drivers.PinMode) {
+	pin, ok := p.(machine.Pin)
+	if !ok {
+		return
+	}
+	switch mode {
+	case drivers.PinModeOutput:
+		pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	case drivers.PinModeInput:
+		pin.Configure(machine.PinConfig{Mode: machine.PinInput})
+	case drivers.PinModeInputPullup:
+		pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	case drivers.PinModeInputPulldown:
+		pin.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
+	}
+}
+
+func isMachineNoPin(p interface{}) bool {
+	pin, ok := p.(machine.Pin)
+	return ok && pin == machine.NoPin
+}
@@ -1,7 +1,8 @@
 package keypad4x4
 
 import (
-	"machine"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 // NoKeyPressed is used, when no key was pressed
@@ -19,30 +20,28 @@ type device struct {
 	inputEnabled bool
 	lastColumn   int
 	lastRow      int
-	columns      [4]machine.Pin
-	rows         [4]machine.Pin
+	columns      [4]drivers.PinInput
+	rows         [4]drivers.PinOutput
 	mapping      [4][4]uint8
 }
 
 // takes r4 -r1 pins and c4 - c1 pins
-func NewDevice(r4, r3, r2, r1, c4, c3, c2, c1 machine.Pin) Device {
+func NewDevice(r4, r3, r2, r1 drivers.PinOutput, c4, c3, c2, c1 drivers.PinInput) Device {
 	result := &device{}
-	result.columns = [4]machine.Pin{c4, c3, c2, c1}
-	result.rows = [4]machine.Pin{r4, r3, r2, r1}
+	result.columns = [4]drivers.PinInput{c4, c3, c2, c1}
+	result.rows = [4]drivers.PinOutput{r4, r3, r2, r1}
 
 	return result
 }
 
 // Configure sets the column pins as input and the row pins as output
 func (keypad *device) Configure() {
-	inputConfig := machine.PinConfig{Mode: machine.PinInputPullup}
 	for i := range keypad.columns {
-		keypad.columns[i].Configure(inputConfig)
+		legacy.ConfigurePin(keypad.columns[i], drivers.PinModeInputPullup)
 	}
 
-	outputConfig := machine.PinConfig{Mode: machine.PinOutput}
 	for i := range keypad.rows {
-		keypad.rows[i].Configure(outputConfig)
+		legacy.ConfigurePin(keypad.rows[i], drivers.PinModeOutput)
 		keypad.rows[i].High()
 	}
 
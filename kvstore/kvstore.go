@@ -0,0 +1,548 @@
+// Package kvstore implements a small key/value store for persisting settings
+// and counters on flash, EEPROM or other erase-block devices, such as a
+// flash.Device or an at24cx.Device.
+//
+// Every update is appended to a log that is spread over a ring of blocks, and
+// each record carries a CRC. A Set or Delete is atomic: after a power loss,
+// either the old or the new value is returned. When space runs low, the live
+// records in the oldest block are copied to the head of the log and the block
+// is erased, which spreads erase cycles evenly over the device.
+//
+// Erased memory must read as 0xff, as it does on NOR flash. The index of all
+// keys is kept in RAM, so the store is meant for a modest number of keys.
+package kvstore // import "tinygo.org/x/drivers/kvstore"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"tinygo.org/x/drivers"
+)
+
+var (
+	ErrNotFound = errors.New("kvstore: key not found")
+	ErrNoSpace  = errors.New("kvstore: no space left on device")
+	ErrTooLarge = errors.New("kvstore: key or value too large")
+	ErrCorrupt  = errors.New("kvstore: store is corrupt")
+	ErrGeometry = errors.New("kvstore: invalid store size")
+)
+
+// Block layout:
+//
+//	magic  [4]byte "KVS1"
+//	seq    uint32, sequence number of the block in the log
+//	_      uint32
+//	crc    uint32, CRC-32 of the previous 12 bytes
+//	records...
+//
+// Record layout:
+//
+//	type   uint8
+//	keyLen uint8
+//	valLen uint16
+//	key
+//	value
+//	crc    uint32, CRC-32 of the header, key and value
+//
+// A type of 0xff (erased memory) marks the end of the log in a block.
+const (
+	blockMagic      = "KVS1"
+	blockHeaderSize = 16
+	recHeaderSize   = 4
+	recOverhead     = recHeaderSize + 4
+
+	recSet    = 1
+	recDelete = 2
+
+	// MaxKeyLen is the maximum length of a key.
+	MaxKeyLen = 64
+)
+
+// Config contains the parameters used by Open and Format.
+type Config struct {
+	// Offset is the address on the device where the store starts. It must
+	// be a multiple of the erase block size.
+	Offset int64
+
+	// Size is the size of the store in bytes. It must be a multiple of
+	// BlockSize and hold at least two blocks. If it is 0, the rest of the
+	// device is used.
+	Size int64
+
+	// BlockSize is the unit in which the store is erased and garbage
+	// collected, and limits the size of a record. It must be a multiple of
+	// the erase block size of the device. If it is 0, the erase block size is
+	// used, or 1024 bytes if the erase block size is smaller than that (as
+	// on an EEPROM).
+	BlockSize int64
+}
+
+// Store is an open key/value store.
+type Store struct {
+	dev       drivers.BlockDevice
+	offset    int64
+	blockSize uint32
+	seqs      []uint32 // sequence number of each block, 0 for free blocks
+	seq       uint32   // highest sequence number in use
+
+	head    int    // block currently appended to, -1 if none
+	headOff uint32 // append offset within the head block
+
+	index map[string]uint32 // address of the latest record for each key
+	inGC  bool
+}
+
+func geometry(dev drivers.BlockDevice, config *Config) (offset int64, blockSize uint32, blocks int, err error) {
+	if config == nil {
+		config = &Config{}
+	}
+	ebs := dev.EraseBlockSize()
+	bs := config.BlockSize
+	if bs == 0 {
+		bs = ebs
+		for bs < 1024 {
+			bs *= 2
+		}
+	}
+	size := config.Size
+	if size == 0 {
+		size = dev.Size() - config.Offset
+		size -= size % bs
+	}
+	if ebs <= 0 || bs%ebs != 0 || bs < 64 || bs > 1<<20 || config.Offset%ebs != 0 || size%bs != 0 ||
+		config.Offset+size > dev.Size() || size/bs < 2 {
+		return 0, 0, 0, ErrGeometry
+	}
+	return config.Offset, uint32(bs), int(size / bs), nil
+}
+
+// Format erases the store area of dev, removing all keys. Opening an erased
+// device also results in an empty store, so Format is only needed to remove
+// existing data.
+func Format(dev drivers.BlockDevice, config *Config) error {
+	offset, bs, blocks, err := geometry(dev, config)
+	if err != nil {
+		return err
+	}
+	ebs := dev.EraseBlockSize()
+	return dev.EraseBlocks(offset/ebs, int64(blocks)*int64(bs)/ebs)
+}
+
+// Open reads the log on dev and returns the store.
+func Open(dev drivers.BlockDevice, config *Config) (*Store, error) {
+	offset, bs, blocks, err := geometry(dev, config)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dev:       dev,
+		offset:    offset,
+		blockSize: bs,
+		seqs:      make([]uint32, blocks),
+		head:      -1,
+		index:     make(map[string]uint32),
+	}
+	var hdr [blockHeaderSize]byte
+	for i := range s.seqs {
+		if _, err := dev.ReadAt(hdr[:], s.blockAddr(i)); err != nil {
+			return nil, err
+		}
+		if string(hdr[:4]) != blockMagic || crc32.ChecksumIEEE(hdr[:12]) != binary.LittleEndian.Uint32(hdr[12:]) {
+			continue
+		}
+		s.seqs[i] = binary.LittleEndian.Uint32(hdr[4:])
+		if s.seqs[i] > s.seq {
+			s.seq = s.seqs[i]
+			s.head = i
+		}
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) blockAddr(block int) int64 {
+	return s.offset + int64(block)*int64(s.blockSize)
+}
+
+func (s *Store) blockOf(addr uint32) int {
+	return int(addr / s.blockSize)
+}
+
+// logOrder returns the blocks in use, oldest first.
+func (s *Store) logOrder() []int {
+	var order []int
+	for i, seq := range s.seqs {
+		if seq != 0 {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return s.seqs[order[a]] < s.seqs[order[b]]
+	})
+	return order
+}
+
+// record is a record header read from the log.
+type record struct {
+	typ    uint8
+	key    string
+	valLen uint32
+	addr   uint32 // address of the record, relative to the store
+}
+
+func (r *record) size() uint32 {
+	return recOverhead + uint32(len(r.key)) + r.valLen
+}
+
+// value returns the address of the record value on the device.
+func (s *Store) value(r *record) int64 {
+	return s.offset + int64(r.addr) + recHeaderSize + int64(len(r.key))
+}
+
+// readRecord reads and verifies the record at addr, which must be inside a
+// block. It returns false at the end of the log in the block or when the
+// record is corrupt.
+func (s *Store) readRecord(addr uint32) (record, bool, error) {
+	var buf [recHeaderSize + MaxKeyLen]byte
+	end := (addr/s.blockSize + 1) * s.blockSize
+	if addr+recOverhead > end {
+		return record{}, false, nil
+	}
+	if _, err := s.dev.ReadAt(buf[:recHeaderSize], s.offset+int64(addr)); err != nil {
+		return record{}, false, err
+	}
+	r := record{
+		typ:    buf[0],
+		valLen: uint32(binary.LittleEndian.Uint16(buf[2:])),
+		addr:   addr,
+	}
+	keyLen := uint32(buf[1])
+	if r.typ == 0xff || keyLen > MaxKeyLen || addr+recOverhead+keyLen+r.valLen > end {
+		return record{}, false, nil
+	}
+	hdr := buf[:recHeaderSize+keyLen]
+	if _, err := s.dev.ReadAt(hdr[recHeaderSize:], s.offset+int64(addr+recHeaderSize)); err != nil {
+		return record{}, false, err
+	}
+	r.key = string(hdr[recHeaderSize:])
+	crc := crc32.ChecksumIEEE(hdr)
+	// Check the CRC, reading the value in chunks.
+	var chunk [64]byte
+	pos := s.value(&r)
+	for done := uint32(0); done < r.valLen; {
+		b := chunk[:]
+		if r.valLen-done < uint32(len(b)) {
+			b = b[:r.valLen-done]
+		}
+		if _, err := s.dev.ReadAt(b, pos+int64(done)); err != nil {
+			return record{}, false, err
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, b)
+		done += uint32(len(b))
+	}
+	if _, err := s.dev.ReadAt(chunk[:4], pos+int64(r.valLen)); err != nil {
+		return record{}, false, err
+	}
+	if binary.LittleEndian.Uint32(chunk[:4]) != crc {
+		return record{}, false, nil
+	}
+	return r, true, nil
+}
+
+// replay rebuilds the index from the log.
+func (s *Store) replay() error {
+	for _, block := range s.logOrder() {
+		addr := uint32(block)*s.blockSize + blockHeaderSize
+		for addr < uint32(block+1)*s.blockSize {
+			r, ok, err := s.readRecord(addr)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			switch r.typ {
+			case recSet:
+				s.index[r.key] = addr
+			case recDelete:
+				delete(s.index, r.key)
+			}
+			addr += r.size()
+		}
+		if block == s.head {
+			s.headOff = addr - uint32(block)*s.blockSize
+			// Appending is only safe if the rest of the block is still erased.
+			clean, err := s.erased(block, s.headOff)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				s.headOff = s.blockSize
+			}
+		}
+	}
+	return nil
+}
+
+// erased reports whether block is erased from off to its end.
+func (s *Store) erased(block int, off uint32) (bool, error) {
+	var buf [64]byte
+	for off < s.blockSize {
+		chunk := buf[:]
+		if s.blockSize-off < uint32(len(chunk)) {
+			chunk = chunk[:s.blockSize-off]
+		}
+		if _, err := s.dev.ReadAt(chunk, s.blockAddr(block)+int64(off)); err != nil {
+			return false, err
+		}
+		for _, b := range chunk {
+			if b != 0xff {
+				return false, nil
+			}
+		}
+		off += uint32(len(chunk))
+	}
+	return true, nil
+}
+
+// eraseBlock erases a block of the store.
+func (s *Store) eraseBlock(block int) error {
+	ebs := s.dev.EraseBlockSize()
+	return s.dev.EraseBlocks(s.blockAddr(block)/ebs, int64(s.blockSize)/ebs)
+}
+
+func (s *Store) freeBlocks() int {
+	n := 0
+	for _, seq := range s.seqs {
+		if seq == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// newBlock starts a new head block to make room for a record of size bytes.
+// Unless called during garbage collection, it makes sure a block stays free
+// for the next garbage collection.
+func (s *Store) newBlock(size uint32) error {
+	if !s.inGC {
+		for tries := 0; s.freeBlocks() <= 1; tries++ {
+			if tries >= len(s.seqs) {
+				return ErrNoSpace
+			}
+			if err := s.collect(); err != nil {
+				return err
+			}
+			if s.space() >= size {
+				// The garbage collector left enough room in its last block.
+				return nil
+			}
+		}
+	}
+	// Use the next free block after the current head.
+	block := -1
+	for i := 1; i <= len(s.seqs); i++ {
+		b := (s.head + i) % len(s.seqs)
+		if b < 0 {
+			b += len(s.seqs)
+		}
+		if s.seqs[b] == 0 {
+			block = b
+			break
+		}
+	}
+	if block < 0 {
+		return ErrNoSpace
+	}
+	if clean, err := s.erased(block, 0); err != nil {
+		return err
+	} else if !clean {
+		if err := s.eraseBlock(block); err != nil {
+			return err
+		}
+	}
+	var hdr [blockHeaderSize]byte
+	copy(hdr[:], blockMagic)
+	binary.LittleEndian.PutUint32(hdr[4:], s.seq+1)
+	binary.LittleEndian.PutUint32(hdr[8:], 0xffffffff)
+	binary.LittleEndian.PutUint32(hdr[12:], crc32.ChecksumIEEE(hdr[:12]))
+	// Claim the block before writing, so that it isn't reused if the write
+	// fails half way.
+	s.seq++
+	s.seqs[block] = s.seq
+	s.head = block
+	s.headOff = s.blockSize
+	if _, err := s.dev.WriteAt(hdr[:], s.blockAddr(block)); err != nil {
+		return err
+	}
+	s.headOff = blockHeaderSize
+	return nil
+}
+
+// space returns the record size that still fits in the head block.
+func (s *Store) space() uint32 {
+	if s.head < 0 {
+		return 0
+	}
+	return s.blockSize - s.headOff
+}
+
+// collect garbage collects the oldest block: the records in it that are
+// still current are appended to the head of the log, after which the block
+// is erased.
+func (s *Store) collect() error {
+	order := s.logOrder()
+	if len(order) == 0 {
+		return ErrNoSpace
+	}
+	victim := order[0]
+	s.inGC = true
+	defer func() { s.inGC = false }()
+	if victim == s.head {
+		if err := s.newBlock(0); err != nil {
+			return err
+		}
+	}
+	for key, addr := range s.index {
+		if s.blockOf(addr) != victim {
+			continue
+		}
+		r, ok, err := s.readRecord(addr)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrCorrupt
+		}
+		value := io.NewSectionReader(s.dev, s.value(&r), int64(r.valLen))
+		if err := s.appendRecord(recSet, key, value, r.valLen); err != nil {
+			return err
+		}
+	}
+
+	// Invalidate the block header before erasing, so that a partially
+	// erased block is never mistaken for part of the log.
+	var zero [4]byte
+	if _, err := s.dev.WriteAt(zero[:], s.blockAddr(victim)); err != nil {
+		return err
+	}
+	s.seqs[victim] = 0
+	return s.eraseBlock(victim)
+}
+
+// appendRecord appends a record with n bytes of value read from value, and
+// updates the index.
+func (s *Store) appendRecord(typ uint8, key string, value io.ReaderAt, n uint32) error {
+	size := recOverhead + uint32(len(key)) + n
+	if size > s.blockSize-blockHeaderSize {
+		return ErrTooLarge
+	}
+	if s.space() < size {
+		if err := s.newBlock(size); err != nil {
+			return err
+		}
+	}
+	var buf [recHeaderSize + MaxKeyLen]byte
+	buf[0] = typ
+	buf[1] = uint8(len(key))
+	binary.LittleEndian.PutUint16(buf[2:], uint16(n))
+	hdr := append(buf[:recHeaderSize], key...)
+	crc := crc32.ChecksumIEEE(hdr)
+
+	start := uint32(s.head)*s.blockSize + s.headOff
+	pos := s.offset + int64(start)
+	// If anything goes wrong, don't append to this block anymore.
+	s.headOff = s.blockSize
+	if _, err := s.dev.WriteAt(hdr, pos); err != nil {
+		return err
+	}
+	pos += int64(len(hdr))
+	var chunk [64]byte
+	for done := uint32(0); done < n; {
+		b := chunk[:]
+		if n-done < uint32(len(b)) {
+			b = b[:n-done]
+		}
+		if _, err := value.ReadAt(b, int64(done)); err != nil {
+			return err
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, b)
+		if _, err := s.dev.WriteAt(b, pos); err != nil {
+			return err
+		}
+		pos += int64(len(b))
+		done += uint32(len(b))
+	}
+	binary.LittleEndian.PutUint32(chunk[:4], crc)
+	if _, err := s.dev.WriteAt(chunk[:4], pos); err != nil {
+		return err
+	}
+	s.headOff = start + size - uint32(s.head)*s.blockSize
+	if typ == recSet {
+		s.index[key] = start
+	} else {
+		delete(s.index, key)
+	}
+	return nil
+}
+
+// Get returns the value stored for key, or ErrNotFound.
+func (s *Store) Get(key string) ([]byte, error) {
+	addr, ok := s.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var hdr [recHeaderSize]byte
+	if _, err := s.dev.ReadAt(hdr[:], s.offset+int64(addr)); err != nil {
+		return nil, err
+	}
+	r := record{key: key, valLen: uint32(binary.LittleEndian.Uint16(hdr[2:])), addr: addr}
+	value := make([]byte, r.valLen)
+	if _, err := s.dev.ReadAt(value, s.value(&r)); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Has reports whether a value is stored for key.
+func (s *Store) Has(key string) bool {
+	_, ok := s.index[key]
+	return ok
+}
+
+// Set stores value for key, replacing any previous value. Nothing is written
+// if the value doesn't change.
+func (s *Store) Set(key string, value []byte) error {
+	if len(key) == 0 || len(key) > MaxKeyLen || len(value) > 0xffff {
+		return ErrTooLarge
+	}
+	if old, err := s.Get(key); err == nil && bytes.Equal(old, value) {
+		return nil
+	}
+	return s.appendRecord(recSet, key, bytes.NewReader(value), uint32(len(value)))
+}
+
+// Delete removes key from the store. It returns ErrNotFound if there is no
+// value for key.
+func (s *Store) Delete(key string) error {
+	if !s.Has(key) {
+		return ErrNotFound
+	}
+	return s.appendRecord(recDelete, key, nil, 0)
+}
+
+// Keys returns all keys in the store, sorted.
+func (s *Store) Keys() []string {
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,170 @@
+package kvstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestSetGet(t *testing.T) {
+	c := qt.New(t)
+	dev := tester.NewFlashDevice(16<<10, 256, 4096)
+	s, err := Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+
+	_, err = s.Get("missing")
+	c.Assert(err, qt.Equals, ErrNotFound)
+	c.Assert(s.Set("ssid", []byte("network")), qt.IsNil)
+	c.Assert(s.Set("boots", []byte{1, 0, 0, 0}), qt.IsNil)
+	c.Assert(s.Set("boots", []byte{2, 0, 0, 0}), qt.IsNil)
+	c.Assert(s.Set("empty", nil), qt.IsNil)
+	c.Assert(s.Set("gone", []byte("x")), qt.IsNil)
+	c.Assert(s.Delete("gone"), qt.IsNil)
+	c.Assert(s.Delete("gone"), qt.Equals, ErrNotFound)
+
+	// Setting the same value again doesn't write anything.
+	writes := dev.Writes
+	c.Assert(s.Set("ssid", []byte("network")), qt.IsNil)
+	c.Assert(dev.Writes, qt.Equals, writes)
+
+	c.Assert(s.Set("", []byte("x")), qt.Equals, ErrTooLarge)
+	c.Assert(s.Set(string(make([]byte, MaxKeyLen+1)), nil), qt.Equals, ErrTooLarge)
+	c.Assert(s.Set("big", make([]byte, 4096)), qt.Equals, ErrTooLarge)
+
+	// Everything is still there after opening the store again.
+	s, err = Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(s.Keys(), qt.DeepEquals, []string{"boots", "empty", "ssid"})
+	v, err := s.Get("boots")
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.DeepEquals, []byte{2, 0, 0, 0})
+	v, err = s.Get("empty")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(v), qt.Equals, 0)
+	c.Assert(s.Has("gone"), qt.IsFalse)
+
+	c.Assert(Format(dev, nil), qt.IsNil)
+	s, err = Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(s.Keys()), qt.Equals, 0)
+}
+
+func TestWearLevelling(t *testing.T) {
+	c := qt.New(t)
+	dev := tester.NewFlashDevice(32<<10, 256, 4096)
+	s, err := Open(dev, &Config{Offset: 8 << 10})
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(s.Set("static", bytes.Repeat([]byte("s"), 1000)), qt.IsNil)
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("counter%d", i%10)
+		c.Assert(s.Set(key, []byte(fmt.Sprint(i))), qt.IsNil)
+	}
+	s, err = Open(dev, &Config{Offset: 8 << 10})
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(s.Keys()), qt.Equals, 11)
+	v, err := s.Get("counter3")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(v), qt.Equals, "4993")
+	v, err = s.Get("static")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(v), qt.Equals, 1000)
+
+	// The area before the store was never touched, and the blocks of the
+	// store were all erased about equally often.
+	c.Assert(dev.EraseCount[:2], qt.DeepEquals, []int{0, 0})
+	min, max := dev.EraseCount[2], dev.EraseCount[2]
+	for _, n := range dev.EraseCount[2:] {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	c.Assert(min > 0, qt.IsTrue)
+	c.Assert(max-min <= 1, qt.IsTrue, qt.Commentf("erase counts %v", dev.EraseCount))
+}
+
+func TestTwoBlocks(t *testing.T) {
+	// The smallest possible store keeps working by compacting the only
+	// block in use into the free one.
+	c := qt.New(t)
+	dev := tester.NewFlashDevice(2048, 32, 32)
+	s, err := Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < 500; i++ {
+		c.Assert(s.Set(fmt.Sprint("key", i%4), []byte(fmt.Sprint(i))), qt.IsNil)
+	}
+	s, err = Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+	v, err := s.Get("key3")
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(v), qt.Equals, "499")
+
+	// Filling the store results in an error, but the old data stays.
+	for i := 0; ; i++ {
+		err = s.Set(fmt.Sprint("new", i), make([]byte, 100))
+		if err != nil {
+			break
+		}
+	}
+	c.Assert(err, qt.Equals, ErrNoSpace)
+	s, err = Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+	v, _ = s.Get("key3")
+	c.Assert(string(v), qt.Equals, "499")
+}
+
+func TestPowerLoss(t *testing.T) {
+	// Cut the power at many points while updating values, and check that
+	// each key has either its old or its new value.
+	c := qt.New(t)
+	dev := tester.NewFlashDevice(8<<10, 256, 2048)
+	s, err := Open(dev, nil)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < 100; i++ {
+		c.Assert(s.Set(fmt.Sprint("key", i%5), []byte(fmt.Sprint("old", i%5))), qt.IsNil)
+	}
+	image := append([]byte(nil), dev.Data...)
+
+	for budget := 0; ; budget += 3 {
+		copy(dev.Data, image)
+		dev.WriteBudget = budget
+		s, err := Open(dev, nil)
+		c.Assert(err, qt.IsNil)
+		err = func() error {
+			for round := 0; round < 60; round++ {
+				for i := 0; i < 5; i++ {
+					if err := s.Set(fmt.Sprint("key", i), []byte(fmt.Sprint("new", i, "/", round))); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}()
+		dev.WriteBudget = -1
+		if err == nil {
+			break
+		}
+		c.Assert(errors.Is(err, tester.ErrPowerLoss), qt.IsTrue, qt.Commentf("budget %d: %v", budget, err))
+
+		s, err = Open(dev, nil)
+		c.Assert(err, qt.IsNil)
+		for i := 0; i < 5; i++ {
+			v, err := s.Get(fmt.Sprint("key", i))
+			c.Assert(err, qt.IsNil, qt.Commentf("budget %d", budget))
+			if !bytes.HasPrefix(v, []byte("old")) && !bytes.HasPrefix(v, []byte("new")) {
+				c.Fatalf("budget %d: unexpected value %q", budget, v)
+			}
+		}
+		// The store can be written to after the power loss.
+		c.Assert(s.Set("key0", []byte("after")), qt.IsNil)
+	}
+	// The updates needed garbage collection.
+	c.Assert(dev.Erases > 0, qt.IsTrue)
+}
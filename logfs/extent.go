@@ -0,0 +1,77 @@
+package logfs
+
+// extent maps a range of a file to the location of its data on the device.
+type extent struct {
+	off  uint32 // offset in the file
+	len  uint32
+	addr uint32 // device address of the data, relative to the filesystem
+}
+
+func (e extent) end() uint32 {
+	return e.off + e.len
+}
+
+// overlay writes n on top of the sorted, non overlapping list and returns
+// the updated list.
+func overlay(list []extent, n extent) []extent {
+	if n.len == 0 {
+		return list
+	}
+	out := list[:0:0]
+	inserted := false
+	for _, e := range list {
+		if e.end() <= n.off || e.off >= n.end() {
+			if !inserted && e.off >= n.end() {
+				out = append(out, n)
+				inserted = true
+			}
+			out = append(out, e)
+			continue
+		}
+		// e overlaps n: keep the parts of e outside of n.
+		if e.off < n.off {
+			out = append(out, extent{off: e.off, len: n.off - e.off, addr: e.addr})
+		}
+		if !inserted {
+			out = append(out, n)
+			inserted = true
+		}
+		if e.end() > n.end() {
+			skip := n.end() - e.off
+			out = append(out, extent{off: n.end(), len: e.end() - n.end(), addr: e.addr + skip})
+		}
+	}
+	if !inserted {
+		out = append(out, n)
+	}
+	return out
+}
+
+// truncate removes everything at or after size from the list.
+func truncate(list []extent, size uint32) []extent {
+	for i, e := range list {
+		if e.end() <= size {
+			continue
+		}
+		if e.off >= size {
+			return list[:i]
+		}
+		list[i].len = size - e.off
+		return list[:i+1]
+	}
+	return list
+}
+
+// find returns the extent containing off. If there is none, it returns the
+// offset of the next extent after off (or 0xffffffff).
+func find(list []extent, off uint32) (e extent, found bool, next uint32) {
+	for _, e := range list {
+		if e.off > off {
+			return extent{}, false, e.off
+		}
+		if off < e.end() {
+			return e, true, 0
+		}
+	}
+	return extent{}, false, 0xffffffff
+}
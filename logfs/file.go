@@ -0,0 +1,413 @@
+package logfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Size of the write buffer of a File. Small writes are collected in it to
+// avoid the overhead of a record per write.
+const bufferSize = 256
+
+// File is an open file or directory on a log-structured filesystem.
+type File struct {
+	fsys *FS
+	ino  *inode // nil for the root directory
+	name string
+	flag int
+	pos  int64
+	size uint32 // size including uncommitted writes
+
+	// Uncommitted writes, in RAM and on the device.
+	buf     []byte
+	bufOff  uint32
+	txn     uint32 // 0 if nothing was written to the device yet
+	pending []extent
+	dirty   bool
+
+	dirList []fs.DirEntry // snapshot of the directory for ReadDir
+}
+
+// OpenFile opens the named file with the given flags (os.O_RDONLY,
+// os.O_WRONLY, os.O_RDWR, os.O_APPEND, os.O_CREATE, os.O_EXCL or os.O_TRUNC).
+//
+// Writes only become visible to other File values and survive a power loss
+// after File.Sync or File.Close. Truncate (and O_TRUNC) commits immediately.
+func (fsys *FS) OpenFile(name string, flag int) (*File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := fsys.openFile(name, flag)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+func (fsys *FS) openFile(name string, flag int) (*File, error) {
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	ino, err := fsys.lookup(name)
+	switch {
+	case err == fs.ErrNotExist && flag&os.O_CREATE != 0:
+		ino, err = fsys.create(name, false)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, fs.ErrExist
+	}
+	if write && (ino == nil || ino.dir) {
+		return nil, ErrIsDir
+	}
+	f := &File{fsys: fsys, ino: ino, name: name, flag: flag}
+	if ino != nil {
+		f.size = ino.size
+	}
+	if write && flag&os.O_TRUNC != 0 && f.size != 0 {
+		if err := f.truncate(0); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *File) isDir() bool {
+	return f.ino == nil || f.ino.dir
+}
+
+// Stat returns information about the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	if f.fsys == nil {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	fi := newFileInfo(f.ino)
+	if !f.isDir() {
+		fi.size = int64(f.size)
+	}
+	return fi, nil
+}
+
+// Read reads up to len(p) bytes from the file.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// ReadAt reads len(p) bytes from the file starting at offset off.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.fsys == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.isDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrIsDir}
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if off >= int64(f.size) {
+		return 0, io.EOF
+	}
+	if err := f.flush(); err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	var err error
+	if rest := int64(f.size) - off; int64(len(p)) > rest {
+		p = p[:rest]
+		err = io.EOF
+	}
+	// Uncommitted data takes precedence over committed data.
+	pos := uint32(off)
+	for done := 0; done < len(p); {
+		chunk := p[done:]
+		e, found, next := find(f.pending, pos)
+		if found {
+			if n := e.end() - pos; uint32(len(chunk)) > n {
+				chunk = chunk[:n]
+			}
+			if _, err := f.fsys.dev.ReadAt(chunk, f.fsys.offset+int64(e.addr+pos-e.off)); err != nil {
+				return done, err
+			}
+		} else {
+			if n := next - pos; uint32(len(chunk)) > n {
+				chunk = chunk[:n]
+			}
+			if err := f.fsys.readExtents(f.ino.extents, chunk, pos); err != nil {
+				return done, err
+			}
+		}
+		done += len(chunk)
+		pos += uint32(len(chunk))
+	}
+	return len(p), err
+}
+
+// Write writes p to the file at the current position.
+func (f *File) Write(p []byte) (int, error) {
+	if f.flag&os.O_APPEND != 0 {
+		f.pos = int64(f.size)
+	}
+	n, err := f.WriteAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+// WriteAt writes p to the file starting at offset off. Writing past the end
+// of the file leaves a gap that reads as zeroes.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if f.fsys == nil {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+	if off < 0 || off+int64(len(p)) > 1<<31 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.write(p, uint32(off)); err != nil {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: err}
+	}
+	return len(p), nil
+}
+
+func (f *File) write(p []byte, off uint32) error {
+	if len(p) == 0 {
+		return nil
+	}
+	f.dirty = true
+	if end := off + uint32(len(p)); end > f.size {
+		f.size = end
+	}
+	if len(f.buf) > 0 && off == f.bufOff+uint32(len(f.buf)) && len(f.buf)+len(p) <= bufferSize {
+		f.buf = append(f.buf, p...)
+		return nil
+	}
+	if err := f.flush(); err != nil {
+		return err
+	}
+	if len(p) >= bufferSize {
+		return f.writeData(p, off)
+	}
+	if f.buf == nil {
+		f.buf = make([]byte, 0, bufferSize)
+	}
+	f.buf = append(f.buf, p...)
+	f.bufOff = off
+	return nil
+}
+
+// flush writes the write buffer to the device.
+func (f *File) flush() error {
+	if len(f.buf) == 0 {
+		return nil
+	}
+	err := f.writeData(f.buf, f.bufOff)
+	f.buf = f.buf[:0]
+	return err
+}
+
+// writeData appends p to the log as uncommitted data at offset off.
+func (f *File) writeData(p []byte, off uint32) error {
+	fsys := f.fsys
+	if f.txn == 0 {
+		f.txn = fsys.nextTxn
+		fsys.nextTxn++
+		fsys.writers = append(fsys.writers, f)
+	}
+	r := bytes.NewReader(p)
+	for done := uint32(0); done < uint32(len(p)); {
+		n := fsys.dataChunk(uint32(len(p)) - done)
+		addr, err := fsys.writeData(f.ino.id, f.txn, off+done, io.NewSectionReader(r, int64(done), int64(n)), n)
+		if err != nil {
+			return err
+		}
+		f.pending = overlay(f.pending, extent{off: off + done, len: n, addr: addr})
+		done += n
+	}
+	return nil
+}
+
+// Seek implements io.Seeker.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(f.size)
+	default:
+		offset = -1
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file and commits all changes.
+func (f *File) Truncate(size int64) error {
+	if f.fsys == nil {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrPermission}
+	}
+	if size < 0 || size > 1<<31 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.truncate(uint32(size)); err != nil {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+func (f *File) truncate(size uint32) error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	// The committed data is cut off at the new size as well, so that it
+	// doesn't show up again if the file grows later.
+	f.pending = truncate(f.pending, size)
+	f.size = size
+	f.dirty = true
+	return f.sync()
+}
+
+// Sync commits all writes to the file.
+func (f *File) Sync() error {
+	if f.fsys == nil {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	if err := f.sync(); err != nil {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+func (f *File) sync() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	if !f.dirty {
+		return nil
+	}
+	if f.ino.deleted {
+		return fs.ErrNotExist
+	}
+	fsys := f.fsys
+	n := *f.ino
+	n.size = f.size
+	n.modTime = fsys.now()
+	if err := fsys.writeCommit(&n, f.txn); err != nil {
+		return err
+	}
+	for _, e := range f.pending {
+		n.extents = overlay(n.extents, e)
+	}
+	n.extents = truncate(n.extents, n.size)
+	*f.ino = n
+	f.release()
+	f.dirty = false
+	return nil
+}
+
+// release forgets about uncommitted data on the device.
+func (f *File) release() {
+	f.txn = 0
+	f.pending = nil
+	for i, w := range f.fsys.writers {
+		if w == f {
+			f.fsys.writers = append(f.fsys.writers[:i], f.fsys.writers[i+1:]...)
+			break
+		}
+	}
+}
+
+// Close closes the file, committing any pending changes.
+func (f *File) Close() error {
+	if f.fsys == nil {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	err := f.sync()
+	f.release()
+	f.fsys = nil
+	if err != nil {
+		return &fs.PathError{Op: "close", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+// ReadDir reads the contents of the directory. It behaves like
+// fs.ReadDirFile.ReadDir.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.fsys == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+	}
+	if f.dirList == nil {
+		id := uint32(0)
+		if f.ino != nil {
+			id = f.ino.id
+		}
+		f.dirList = []fs.DirEntry{}
+		for _, ino := range f.fsys.files {
+			if ino.parent == id {
+				f.dirList = append(f.dirList, newFileInfo(ino))
+			}
+		}
+		sort.Slice(f.dirList, func(i, j int) bool {
+			return strings.Compare(f.dirList[i].Name(), f.dirList[j].Name()) < 0
+		})
+	}
+	list := f.dirList
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	f.dirList = f.dirList[len(list):]
+	if n > 0 && len(list) == 0 {
+		return nil, io.EOF
+	}
+	return list, nil
+}
+
+// fileInfo implements fs.FileInfo and fs.DirEntry.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newFileInfo(ino *inode) *fileInfo {
+	if ino == nil {
+		return &fileInfo{name: ".", mode: fs.ModeDir | 0777}
+	}
+	fi := &fileInfo{name: ino.name, size: int64(ino.size), modTime: time.Unix(0, ino.modTime), mode: 0666}
+	if ino.dir {
+		fi.mode = fs.ModeDir | 0777
+		fi.size = 0
+	}
+	return fi
+}
+
+func (fi *fileInfo) Name() string               { return fi.name }
+func (fi *fileInfo) Size() int64                { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode          { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time         { return fi.modTime }
+func (fi *fileInfo) IsDir() bool                { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}           { return nil }
+func (fi *fileInfo) Type() fs.FileMode          { return fi.mode.Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
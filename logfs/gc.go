@@ -0,0 +1,96 @@
+package logfs
+
+// collect garbage collects the oldest block of the log: all data in it that
+// is still in use is appended to the head of the log, after which the block
+// is erased.
+func (fsys *FS) collect() error {
+	order := fsys.logOrder()
+	if len(order) < 2 {
+		return ErrNoSpace
+	}
+	victim := order[0]
+	fsys.inGC = true
+	defer func() { fsys.inGC = false }()
+
+	for _, ino := range fsys.files {
+		if err := fsys.relocate(ino, victim); err != nil {
+			return err
+		}
+	}
+	for _, f := range fsys.writers {
+		pending, err := fsys.move(f.ino.id, f.txn, f.pending, victim)
+		if err != nil {
+			return err
+		}
+		f.pending = pending
+	}
+
+	// Invalidate the block header before erasing, so that a partially
+	// erased block is never mistaken for part of the log.
+	var zero [4]byte
+	if _, err := fsys.dev.WriteAt(zero[:], fsys.blockAddr(victim)); err != nil {
+		return err
+	}
+	fsys.seqs[victim] = 0
+	return fsys.dev.EraseBlocks(fsys.blockAddr(victim)/int64(fsys.blockSize), 1)
+}
+
+// relocate rewrites the parts of ino that are stored in block and commits
+// them again.
+func (fsys *FS) relocate(ino *inode, block int) error {
+	used := ino.commit == block
+	for _, e := range ino.extents {
+		if fsys.blockOf(e.addr) == block {
+			used = true
+			break
+		}
+	}
+	if !used {
+		return nil
+	}
+	txn := fsys.nextTxn
+	fsys.nextTxn++
+	moved, err := fsys.move(ino.id, txn, ino.extents, block)
+	if err != nil {
+		return err
+	}
+	n := *ino
+	n.extents = moved
+	if err := fsys.writeCommit(&n, txn); err != nil {
+		return err
+	}
+	ino.extents, ino.commit = n.extents, n.commit
+	return nil
+}
+
+// move appends data records for all extents of list that are stored in
+// block, as part of txn, and returns the updated list. Adjacent extents are
+// merged into as few records as possible.
+func (fsys *FS) move(id, txn uint32, list []extent, block int) ([]extent, error) {
+	out := list
+	for i := 0; i < len(list); {
+		if fsys.blockOf(list[i].addr) != block {
+			i++
+			continue
+		}
+		// Find a run of extents in block that are adjacent in the file.
+		j := i + 1
+		for j < len(list) && fsys.blockOf(list[j].addr) == block && list[j].off == list[j-1].end() {
+			j++
+		}
+		start, end := list[i].off, list[j-1].end()
+		src := &extentReader{fsys: fsys, list: list[i:j]}
+		for off := start; off < end; {
+			n := fsys.dataChunk(end - off)
+			src.base = off
+			addr, err := fsys.writeData(id, txn, off, src, n)
+			if err != nil {
+				return nil, err
+			}
+			out = overlay(out, extent{off: off, len: n, addr: addr})
+			off += n
+		}
+		i = j
+	}
+	return out, nil
+}
@@ -0,0 +1,816 @@
+// Package logfs implements a small log-structured filesystem for NOR flash
+// and other erase-block devices, such as a flash.Device.
+//
+// All changes are appended to a log that is spread over the erase blocks of
+// the device, so data is never overwritten in place. Each record carries a
+// CRC, and changes to a file only become visible once they are committed by
+// File.Sync or File.Close. A power loss at any point therefore leaves either
+// the old or the new contents of a file, never a mix. Blocks are reused in a
+// ring, and the oldest block is garbage collected when space runs low, which
+// spreads erase cycles evenly over the device.
+//
+// The index of all files is kept in RAM and is rebuilt by scanning the log
+// when mounting. RAM usage grows with the number of files and fragments, and
+// with the number of erase blocks (4 bytes each).
+//
+// The filesystem implements io/fs.FS for reading. Files can be created and
+// modified with OpenFile, Mkdir, Remove and Rename.
+package logfs // import "tinygo.org/x/drivers/logfs"
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+var (
+	ErrNoSpace  = errors.New("logfs: no space left on device")
+	ErrCorrupt  = errors.New("logfs: filesystem is corrupt")
+	ErrNotEmpty = errors.New("logfs: directory not empty")
+	ErrIsDir    = errors.New("logfs: is a directory")
+	ErrNotDir   = errors.New("logfs: not a directory")
+	ErrGeometry = errors.New("logfs: invalid filesystem size")
+)
+
+// Block layout:
+//
+//	magic  [4]byte "LFS1"
+//	seq    uint32, sequence number of the block in the log
+//	_      uint32
+//	crc    uint32, CRC-32 of the previous 12 bytes
+//	records...
+//
+// Record layout:
+//
+//	type   uint8
+//	_      uint8
+//	len    uint16, length of the payload
+//	id     uint32, file the record belongs to
+//	payload
+//	crc    uint32, CRC-32 of the header and payload
+//
+// Erased flash reads as 0xff, so a type of 0xff marks the end of the log in
+// a block.
+const (
+	blockMagic      = "LFS1"
+	blockHeaderSize = 16
+	recHeaderSize   = 8
+	recCRCSize      = 4
+	recOverhead     = recHeaderSize + recCRCSize
+
+	recData   = 1 // payload: txn uint32, offset uint32, data
+	recCommit = 2 // payload: txn, size, parent uint32, modTime int64, mode uint8, name
+	recDelete = 3 // no payload
+
+	commitFixedSize = 4 + 4 + 4 + 8 + 1
+	dataFixedSize   = 4 + 4
+
+	modeDir = 1
+
+	// Number of erase blocks kept free for garbage collection.
+	reserveBlocks = 2
+
+	// MaxNameLen is the maximum length of a file name (not the full path).
+	MaxNameLen = 255
+)
+
+// Config contains the parameters used by Mount and Format.
+type Config struct {
+	// Offset is the address on the device where the filesystem starts, for
+	// example to leave room for a bootloader. It must be a multiple of the
+	// erase block size.
+	Offset int64
+
+	// Size is the size of the filesystem in bytes, and must be a multiple of
+	// the erase block size. If it is 0, the rest of the device is used.
+	Size int64
+}
+
+// inode is the in-memory state of a committed file or directory.
+type inode struct {
+	id      uint32
+	parent  uint32 // id of the parent directory, 0 for the root
+	name    string
+	dir     bool
+	size    uint32
+	modTime int64
+	extents []extent
+	commit  int // erase block that holds the latest commit record
+	deleted bool
+}
+
+// FS is a mounted log-structured filesystem.
+type FS struct {
+	dev       drivers.BlockDevice
+	offset    int64
+	blockSize uint32
+	seqs      []uint32 // sequence number of each block, 0 for free blocks
+	seq       uint32   // highest sequence number in use
+
+	head    int    // block currently appended to, -1 if none
+	headOff uint32 // append offset within the head block
+
+	files   []*inode
+	writers []*File // files with uncommitted writes
+	nextID  uint32
+	nextTxn uint32
+	inGC    bool
+
+	// Now returns the time used for modification times. If it is nil,
+	// time.Now is used.
+	Now func() time.Time
+}
+
+func geometry(dev drivers.BlockDevice, config *Config) (offset int64, blockSize uint32, blocks int, err error) {
+	if config == nil {
+		config = &Config{}
+	}
+	bs := dev.EraseBlockSize()
+	size := config.Size
+	if size == 0 {
+		size = dev.Size() - config.Offset
+	}
+	if bs < 256 || bs > 1<<16 || config.Offset%bs != 0 || size%bs != 0 ||
+		config.Offset+size > dev.Size() || size/bs < reserveBlocks+2 || size > 1<<31 {
+		return 0, 0, 0, ErrGeometry
+	}
+	return config.Offset, uint32(bs), int(size / bs), nil
+}
+
+// Format erases the filesystem area of dev, resulting in an empty
+// filesystem. Mounting an erased device also results in an empty filesystem,
+// so Format is only needed to remove existing data.
+func Format(dev drivers.BlockDevice, config *Config) error {
+	offset, bs, blocks, err := geometry(dev, config)
+	if err != nil {
+		return err
+	}
+	return dev.EraseBlocks(offset/int64(bs), int64(blocks))
+}
+
+// Mount reads the log on dev and returns the filesystem.
+func Mount(dev drivers.BlockDevice, config *Config) (*FS, error) {
+	offset, bs, blocks, err := geometry(dev, config)
+	if err != nil {
+		return nil, err
+	}
+	fsys := &FS{
+		dev:       dev,
+		offset:    offset,
+		blockSize: bs,
+		seqs:      make([]uint32, blocks),
+		head:      -1,
+		nextID:    1,
+		nextTxn:   1,
+	}
+	var hdr [blockHeaderSize]byte
+	for i := range fsys.seqs {
+		if _, err := dev.ReadAt(hdr[:], fsys.blockAddr(i)); err != nil {
+			return nil, err
+		}
+		if string(hdr[:4]) != blockMagic || crc32.ChecksumIEEE(hdr[:12]) != binary.LittleEndian.Uint32(hdr[12:]) {
+			continue
+		}
+		fsys.seqs[i] = binary.LittleEndian.Uint32(hdr[4:])
+		if fsys.seqs[i] > fsys.seq {
+			fsys.seq = fsys.seqs[i]
+			fsys.head = i
+		}
+	}
+	if err := fsys.replay(); err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
+
+func (fsys *FS) blockAddr(block int) int64 {
+	return fsys.offset + int64(block)*int64(fsys.blockSize)
+}
+
+func (fsys *FS) blockOf(addr uint32) int {
+	return int(addr / fsys.blockSize)
+}
+
+func (fsys *FS) now() int64 {
+	if fsys.Now != nil {
+		return fsys.Now().UnixNano()
+	}
+	return time.Now().UnixNano()
+}
+
+// logOrder returns the blocks in use, oldest first.
+func (fsys *FS) logOrder() []int {
+	var order []int
+	for i, seq := range fsys.seqs {
+		if seq != 0 {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return fsys.seqs[order[a]] < fsys.seqs[order[b]]
+	})
+	return order
+}
+
+// record is a record header read from the log.
+type record struct {
+	typ  uint8
+	len  uint32 // payload length
+	id   uint32
+	addr uint32 // address of the payload
+}
+
+// readRecord reads and verifies the record at off in block. It returns false
+// at the end of the log in the block or when the record is corrupt.
+func (fsys *FS) readRecord(block int, off uint32, payload []byte) (record, []byte, bool, error) {
+	var hdr [recHeaderSize]byte
+	if off+recOverhead > fsys.blockSize {
+		return record{}, nil, false, nil
+	}
+	base := fsys.blockAddr(block)
+	if _, err := fsys.dev.ReadAt(hdr[:], base+int64(off)); err != nil {
+		return record{}, nil, false, err
+	}
+	r := record{
+		typ:  hdr[0],
+		len:  uint32(binary.LittleEndian.Uint16(hdr[2:])),
+		id:   binary.LittleEndian.Uint32(hdr[4:]),
+		addr: uint32(block)*fsys.blockSize + off + recHeaderSize,
+	}
+	if r.typ == 0xff || off+recOverhead+r.len > fsys.blockSize {
+		return record{}, nil, false, nil
+	}
+	crc := crc32.ChecksumIEEE(hdr[:])
+	// Check the CRC, reading the payload in chunks.
+	var buf [64]byte
+	for done := uint32(0); done < r.len; {
+		chunk := buf[:]
+		if r.len-done < uint32(len(chunk)) {
+			chunk = chunk[:r.len-done]
+		}
+		if _, err := fsys.dev.ReadAt(chunk, base+int64(off+recHeaderSize+done)); err != nil {
+			return record{}, nil, false, err
+		}
+		if done < uint32(len(payload)) {
+			copy(payload[done:], chunk)
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, chunk)
+		done += uint32(len(chunk))
+	}
+	if _, err := fsys.dev.ReadAt(buf[:4], base+int64(off+recHeaderSize+r.len)); err != nil {
+		return record{}, nil, false, err
+	}
+	if binary.LittleEndian.Uint32(buf[:4]) != crc {
+		return record{}, nil, false, nil
+	}
+	n := r.len
+	if n > uint32(len(payload)) {
+		n = uint32(len(payload))
+	}
+	return r, payload[:n], true, nil
+}
+
+// replay rebuilds the file index from the log.
+func (fsys *FS) replay() error {
+	pending := make(map[uint32][]extent)
+	payload := make([]byte, commitFixedSize+MaxNameLen)
+	le := binary.LittleEndian
+	for _, block := range fsys.logOrder() {
+		off := uint32(blockHeaderSize)
+		for {
+			r, p, ok, err := fsys.readRecord(block, off, payload)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			off += recOverhead + r.len
+			if r.id >= fsys.nextID {
+				fsys.nextID = r.id + 1
+			}
+			switch r.typ {
+			case recData:
+				if r.len < dataFixedSize {
+					continue
+				}
+				txn := le.Uint32(p)
+				if txn >= fsys.nextTxn {
+					fsys.nextTxn = txn + 1
+				}
+				e := extent{off: le.Uint32(p[4:]), len: r.len - dataFixedSize, addr: r.addr + dataFixedSize}
+				pending[txn] = overlay(pending[txn], e)
+			case recCommit:
+				if r.len < commitFixedSize || r.len > commitFixedSize+MaxNameLen {
+					continue
+				}
+				txn := le.Uint32(p)
+				if txn >= fsys.nextTxn {
+					fsys.nextTxn = txn + 1
+				}
+				ino := fsys.inode(r.id)
+				if ino == nil {
+					ino = &inode{id: r.id}
+					fsys.files = append(fsys.files, ino)
+				}
+				ino.size = le.Uint32(p[4:])
+				ino.parent = le.Uint32(p[8:])
+				ino.modTime = int64(le.Uint64(p[12:]))
+				ino.dir = p[20]&modeDir != 0
+				ino.name = string(p[commitFixedSize:])
+				ino.commit = block
+				if txn != 0 {
+					for _, e := range pending[txn] {
+						ino.extents = overlay(ino.extents, e)
+					}
+					delete(pending, txn)
+				}
+				ino.extents = truncate(ino.extents, ino.size)
+			case recDelete:
+				fsys.removeInode(r.id)
+			}
+		}
+		if block == fsys.head {
+			fsys.headOff = off
+			// Appending is only safe if the rest of the block is still erased.
+			clean, err := fsys.erased(block, off)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				fsys.headOff = fsys.blockSize
+			}
+		}
+	}
+	// Drop files whose parent directory is gone, which can happen when a
+	// directory's records were garbage collected after it was removed.
+	for i := 0; i < len(fsys.files); i++ {
+		ino := fsys.files[i]
+		if p := ino.parent; p != 0 {
+			if parent := fsys.inode(p); parent == nil || !parent.dir {
+				fsys.removeInode(ino.id)
+				i = -1
+			}
+		}
+	}
+	return nil
+}
+
+// erased reports whether block is erased from off to its end.
+func (fsys *FS) erased(block int, off uint32) (bool, error) {
+	var buf [64]byte
+	for off < fsys.blockSize {
+		chunk := buf[:]
+		if fsys.blockSize-off < uint32(len(chunk)) {
+			chunk = chunk[:fsys.blockSize-off]
+		}
+		if _, err := fsys.dev.ReadAt(chunk, fsys.blockAddr(block)+int64(off)); err != nil {
+			return false, err
+		}
+		for _, b := range chunk {
+			if b != 0xff {
+				return false, nil
+			}
+		}
+		off += uint32(len(chunk))
+	}
+	return true, nil
+}
+
+func (fsys *FS) inode(id uint32) *inode {
+	for _, ino := range fsys.files {
+		if ino.id == id {
+			return ino
+		}
+	}
+	return nil
+}
+
+func (fsys *FS) removeInode(id uint32) {
+	for i, ino := range fsys.files {
+		if ino.id == id {
+			ino.deleted = true
+			fsys.files = append(fsys.files[:i], fsys.files[i+1:]...)
+			return
+		}
+	}
+}
+
+// freeBlocks returns the number of blocks that are not part of the log.
+func (fsys *FS) freeBlocks() int {
+	n := 0
+	for _, seq := range fsys.seqs {
+		if seq == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// newBlock makes room for a record with a payload of need bytes by starting
+// a new head block. Unless called during garbage collection, it makes sure
+// enough blocks stay free for the next garbage collection.
+func (fsys *FS) newBlock(need uint32) error {
+	if !fsys.inGC {
+		for tries := 0; fsys.freeBlocks() <= reserveBlocks; tries++ {
+			if tries >= len(fsys.seqs) {
+				return ErrNoSpace
+			}
+			if err := fsys.collect(); err != nil {
+				return err
+			}
+		}
+		if fsys.space() >= need {
+			// The garbage collector left enough room in its last block.
+			return nil
+		}
+	}
+	// Use the next free block after the current head.
+	block := -1
+	for i := 1; i <= len(fsys.seqs); i++ {
+		b := (fsys.head + i) % len(fsys.seqs)
+		if b < 0 {
+			b += len(fsys.seqs)
+		}
+		if fsys.seqs[b] == 0 {
+			block = b
+			break
+		}
+	}
+	if block < 0 {
+		return ErrNoSpace
+	}
+	if clean, err := fsys.erased(block, 0); err != nil {
+		return err
+	} else if !clean {
+		if err := fsys.dev.EraseBlocks(fsys.blockAddr(block)/int64(fsys.blockSize), 1); err != nil {
+			return err
+		}
+	}
+	var hdr [blockHeaderSize]byte
+	copy(hdr[:], blockMagic)
+	binary.LittleEndian.PutUint32(hdr[4:], fsys.seq+1)
+	binary.LittleEndian.PutUint32(hdr[8:], 0xffffffff)
+	binary.LittleEndian.PutUint32(hdr[12:], crc32.ChecksumIEEE(hdr[:12]))
+	// Claim the block before writing, so that it isn't reused if the write
+	// fails half way.
+	fsys.seq++
+	fsys.seqs[block] = fsys.seq
+	fsys.head = block
+	fsys.headOff = fsys.blockSize
+	if _, err := fsys.dev.WriteAt(hdr[:], fsys.blockAddr(block)); err != nil {
+		return err
+	}
+	fsys.headOff = blockHeaderSize
+	return nil
+}
+
+// maxPayload is the largest record payload that fits in an empty block.
+func (fsys *FS) maxPayload() uint32 {
+	max := fsys.blockSize - blockHeaderSize - recOverhead
+	if max > 0xffff {
+		max = 0xffff
+	}
+	return max
+}
+
+// space returns the payload size that still fits in the head block.
+func (fsys *FS) space() uint32 {
+	if fsys.head < 0 || fsys.headOff+recOverhead >= fsys.blockSize {
+		return 0
+	}
+	return fsys.blockSize - fsys.headOff - recOverhead
+}
+
+// appendRecord appends a record whose payload is fixed followed by n bytes
+// read from data, and returns the address of the payload. The record must
+// fit in a block.
+func (fsys *FS) appendRecord(typ uint8, id uint32, fixed []byte, data io.ReaderAt, n uint32) (uint32, error) {
+	size := uint32(len(fixed)) + n
+	if size > fsys.maxPayload() {
+		return 0, ErrCorrupt
+	}
+	if fsys.space() < size {
+		if err := fsys.newBlock(size); err != nil {
+			return 0, err
+		}
+	}
+	var buf [64]byte
+	buf[0] = typ
+	buf[1] = 0xff
+	binary.LittleEndian.PutUint16(buf[2:], uint16(size))
+	binary.LittleEndian.PutUint32(buf[4:], id)
+	crc := crc32.ChecksumIEEE(buf[:recHeaderSize])
+
+	start := fsys.headOff
+	addr := fsys.blockAddr(fsys.head) + int64(start)
+	// If anything goes wrong, don't append to this block anymore.
+	fsys.headOff = fsys.blockSize
+	if _, err := fsys.dev.WriteAt(buf[:recHeaderSize], addr); err != nil {
+		return 0, err
+	}
+	addr += recHeaderSize
+	if len(fixed) > 0 {
+		crc = crc32.Update(crc, crc32.IEEETable, fixed)
+		if _, err := fsys.dev.WriteAt(fixed, addr); err != nil {
+			return 0, err
+		}
+		addr += int64(len(fixed))
+	}
+	for done := uint32(0); done < n; {
+		chunk := buf[:]
+		if n-done < uint32(len(chunk)) {
+			chunk = chunk[:n-done]
+		}
+		if _, err := data.ReadAt(chunk, int64(done)); err != nil {
+			return 0, err
+		}
+		crc = crc32.Update(crc, crc32.IEEETable, chunk)
+		if _, err := fsys.dev.WriteAt(chunk, addr); err != nil {
+			return 0, err
+		}
+		addr += int64(len(chunk))
+		done += uint32(len(chunk))
+	}
+	binary.LittleEndian.PutUint32(buf[:4], crc)
+	if _, err := fsys.dev.WriteAt(buf[:4], addr); err != nil {
+		return 0, err
+	}
+	fsys.headOff = start + recOverhead + size
+	return uint32(fsys.head)*fsys.blockSize + start + recHeaderSize, nil
+}
+
+// writeCommit appends a commit record for ino with txn.
+func (fsys *FS) writeCommit(ino *inode, txn uint32) error {
+	var p [commitFixedSize]byte
+	le := binary.LittleEndian
+	le.PutUint32(p[0:], txn)
+	le.PutUint32(p[4:], ino.size)
+	le.PutUint32(p[8:], ino.parent)
+	le.PutUint64(p[12:], uint64(ino.modTime))
+	if ino.dir {
+		p[20] = modeDir
+	}
+	addr, err := fsys.appendRecord(recCommit, ino.id, p[:], strings.NewReader(ino.name), uint32(len(ino.name)))
+	if err != nil {
+		return err
+	}
+	ino.commit = fsys.blockOf(addr)
+	return nil
+}
+
+// writeData appends a data record for n bytes of data at offset off of file
+// id, as part of txn. It returns the device address of the data.
+func (fsys *FS) writeData(id, txn, off uint32, data io.ReaderAt, n uint32) (uint32, error) {
+	var p [dataFixedSize]byte
+	binary.LittleEndian.PutUint32(p[0:], txn)
+	binary.LittleEndian.PutUint32(p[4:], off)
+	addr, err := fsys.appendRecord(recData, id, p[:], data, n)
+	if err != nil {
+		return 0, err
+	}
+	return addr + dataFixedSize, nil
+}
+
+// dataChunk returns how many of n bytes to put in the next data record. The
+// rest of the head block is used if it isn't too small.
+func (fsys *FS) dataChunk(n uint32) uint32 {
+	if space := fsys.space(); space >= dataFixedSize+64 && space < dataFixedSize+n {
+		return space - dataFixedSize
+	}
+	if max := fsys.maxPayload() - dataFixedSize; n > max {
+		return max
+	}
+	return n
+}
+
+// readExtents reads the file data at off described by list into p. Parts of
+// the file that aren't in list read as zeroes.
+func (fsys *FS) readExtents(list []extent, p []byte, off uint32) error {
+	for len(p) > 0 {
+		e, found, next := find(list, off)
+		n := uint32(len(p))
+		if !found {
+			if next-off < n {
+				n = next - off
+			}
+			for i := range p[:n] {
+				p[i] = 0
+			}
+		} else {
+			if e.end()-off < n {
+				n = e.end() - off
+			}
+			if _, err := fsys.dev.ReadAt(p[:n], fsys.offset+int64(e.addr+off-e.off)); err != nil {
+				return err
+			}
+		}
+		p = p[n:]
+		off += n
+	}
+	return nil
+}
+
+// extentReader reads file data starting at base through readExtents.
+type extentReader struct {
+	fsys *FS
+	list []extent
+	base uint32
+}
+
+func (r *extentReader) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.fsys.readExtents(r.list, p, r.base+uint32(off)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// lookup returns the inode of the named file, or nil for the root.
+func (fsys *FS) lookup(name string) (*inode, error) {
+	if name == "." {
+		return nil, nil
+	}
+	var dir *inode
+	for {
+		elem := name
+		i := strings.IndexByte(name, '/')
+		if i >= 0 {
+			elem, name = name[:i], name[i+1:]
+		}
+		if dir != nil && !dir.dir {
+			return nil, ErrNotDir
+		}
+		parent := uint32(0)
+		if dir != nil {
+			parent = dir.id
+		}
+		dir = fsys.child(parent, elem)
+		if dir == nil {
+			return nil, fs.ErrNotExist
+		}
+		if i < 0 {
+			return dir, nil
+		}
+	}
+}
+
+func (fsys *FS) child(parent uint32, name string) *inode {
+	for _, ino := range fsys.files {
+		if ino.parent == parent && ino.name == name {
+			return ino
+		}
+	}
+	return nil
+}
+
+// lookupParent returns the id of the directory containing name and the base
+// name.
+func (fsys *FS) lookupParent(name string) (uint32, string, error) {
+	dir, base := ".", name
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		dir, base = name[:i], name[i+1:]
+	}
+	if len(base) > MaxNameLen {
+		return 0, "", fs.ErrInvalid
+	}
+	parent, err := fsys.lookup(dir)
+	if err != nil {
+		return 0, "", err
+	}
+	if parent == nil {
+		return 0, base, nil
+	}
+	if !parent.dir {
+		return 0, "", ErrNotDir
+	}
+	return parent.id, base, nil
+}
+
+// create adds a new, committed file or directory.
+func (fsys *FS) create(name string, dir bool) (*inode, error) {
+	parent, base, err := fsys.lookupParent(name)
+	if err != nil {
+		return nil, err
+	}
+	if fsys.child(parent, base) != nil {
+		return nil, fs.ErrExist
+	}
+	ino := &inode{id: fsys.nextID, parent: parent, name: base, dir: dir, modTime: fsys.now()}
+	if err := fsys.writeCommit(ino, 0); err != nil {
+		return nil, err
+	}
+	fsys.nextID++
+	fsys.files = append(fsys.files, ino)
+	return ino, nil
+}
+
+// Open implements fs.FS. The returned file is read only.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	f, err := fsys.OpenFile(name, 0)
+	if err != nil {
+		// Avoid returning a typed nil.
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat returns information about the named file.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	ino, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return newFileInfo(ino), nil
+}
+
+// Mkdir creates a new directory.
+func (fsys *FS) Mkdir(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := fsys.create(name, true); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory. Files that are still
+// open can no longer be committed.
+func (fsys *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := fsys.remove(name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (fsys *FS) remove(name string) error {
+	ino, err := fsys.lookup(name)
+	if err != nil {
+		return err
+	}
+	if ino.dir {
+		for _, f := range fsys.files {
+			if f.parent == ino.id {
+				return ErrNotEmpty
+			}
+		}
+	}
+	if _, err := fsys.appendRecord(recDelete, ino.id, nil, nil, 0); err != nil {
+		return err
+	}
+	fsys.removeInode(ino.id)
+	return nil
+}
+
+// Rename renames (moves) oldname to newname. If newname already exists, it
+// is not replaced and an error is returned.
+func (fsys *FS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) || oldname == "." || newname == "." {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if err := fsys.rename(oldname, newname); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (fsys *FS) rename(oldname, newname string) error {
+	ino, err := fsys.lookup(oldname)
+	if err != nil {
+		return err
+	}
+	parent, base, err := fsys.lookupParent(newname)
+	if err != nil {
+		return err
+	}
+	if fsys.child(parent, base) != nil {
+		return fs.ErrExist
+	}
+	// Don't allow moving a directory into itself.
+	for p := parent; p != 0; p = fsys.inode(p).parent {
+		if p == ino.id {
+			return fs.ErrInvalid
+		}
+	}
+	n := *ino
+	n.parent, n.name = parent, base
+	if err := fsys.writeCommit(&n, 0); err != nil {
+		return err
+	}
+	ino.parent, ino.name, ino.commit = n.parent, n.name, n.commit
+	return nil
+}
@@ -0,0 +1,279 @@
+package logfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func newFS(c *qt.C, blocks int) (*tester.BlockDevice, *FS) {
+	dev := tester.NewFlashDevice(int64(blocks)*4096, 256, 4096)
+	fsys, err := Mount(dev, nil)
+	c.Assert(err, qt.IsNil)
+	return dev, fsys
+}
+
+func writeFile(c *qt.C, fsys *FS, name string, data []byte) {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	c.Assert(err, qt.IsNil)
+	n, err := f.Write(data)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, len(data))
+	c.Assert(f.Close(), qt.IsNil)
+}
+
+func pattern(n, seed int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*7 + seed)
+	}
+	return b
+}
+
+func TestFS(t *testing.T) {
+	c := qt.New(t)
+	dev, fsys := newFS(c, 16)
+
+	big := pattern(10000, 1)
+	writeFile(c, fsys, "hello.txt", []byte("hello world\n"))
+	writeFile(c, fsys, "big", big)
+	c.Assert(fsys.Mkdir("dir"), qt.IsNil)
+	c.Assert(fsys.Mkdir("dir/sub"), qt.IsNil)
+	writeFile(c, fsys, "dir/sub/empty", nil)
+	writeFile(c, fsys, "dir/readme.md", []byte("# readme"))
+	c.Assert(fstest.TestFS(fsys, "hello.txt", "big", "dir/sub/empty", "dir/readme.md"), qt.IsNil)
+
+	// Everything is still there after mounting again.
+	fsys, err := Mount(dev, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(fstest.TestFS(fsys, "hello.txt", "big", "dir/sub/empty", "dir/readme.md"), qt.IsNil)
+	data, err := fs.ReadFile(fsys, "big")
+	c.Assert(err, qt.IsNil)
+	c.Assert(data, qt.DeepEquals, big)
+
+	c.Assert(errors.Is(fsys.Mkdir("dir"), fs.ErrExist), qt.IsTrue)
+	c.Assert(errors.Is(fsys.Mkdir("hello.txt/x"), ErrNotDir), qt.IsTrue)
+	_, err = fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE)
+	c.Assert(errors.Is(err, fs.ErrNotExist), qt.IsTrue)
+	_, err = fsys.OpenFile("dir", os.O_WRONLY)
+	c.Assert(errors.Is(err, ErrIsDir), qt.IsTrue)
+}
+
+func TestReadWrite(t *testing.T) {
+	c := qt.New(t)
+	dev, fsys := newFS(c, 16)
+
+	f, err := fsys.OpenFile("file", os.O_RDWR|os.O_CREATE)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write([]byte("0123456789"))
+	c.Assert(err, qt.IsNil)
+	_, err = f.WriteAt([]byte("abc"), 3)
+	c.Assert(err, qt.IsNil)
+	// Writing past the end leaves a gap of zeroes.
+	_, err = f.WriteAt([]byte("z"), 12)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, qt.IsNil)
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(data), qt.Equals, "012abc6789\x00\x00z")
+
+	// Nothing is visible before the file is committed.
+	data, err = fs.ReadFile(fsys, "file")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(data), qt.Equals, 0)
+	c.Assert(f.Sync(), qt.IsNil)
+	data, _ = fs.ReadFile(fsys, "file")
+	c.Assert(string(data), qt.Equals, "012abc6789\x00\x00z")
+
+	// Shrinking and growing the file doesn't bring old data back.
+	c.Assert(f.Truncate(4), qt.IsNil)
+	_, err = f.WriteAt([]byte("!"), 7)
+	c.Assert(err, qt.IsNil)
+	c.Assert(f.Close(), qt.IsNil)
+	c.Assert(f.Close(), qt.Not(qt.IsNil))
+
+	fsys, err = Mount(dev, nil)
+	c.Assert(err, qt.IsNil)
+	data, _ = fs.ReadFile(fsys, "file")
+	c.Assert(string(data), qt.Equals, "012a\x00\x00\x00!")
+
+	f, err = fsys.OpenFile("file", os.O_WRONLY|os.O_APPEND)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write([]byte("++"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(f.Close(), qt.IsNil)
+	data, _ = fs.ReadFile(fsys, "file")
+	c.Assert(string(data), qt.Equals, "012a\x00\x00\x00!++")
+
+	writeFile(c, fsys, "file", []byte("new"))
+	data, _ = fs.ReadFile(fsys, "file")
+	c.Assert(string(data), qt.Equals, "new")
+}
+
+func TestRemoveRename(t *testing.T) {
+	c := qt.New(t)
+	dev, fsys := newFS(c, 16)
+	c.Assert(fsys.Mkdir("a"), qt.IsNil)
+	c.Assert(fsys.Mkdir("b"), qt.IsNil)
+	writeFile(c, fsys, "a/file", pattern(5000, 2))
+
+	c.Assert(errors.Is(fsys.Remove("a"), ErrNotEmpty), qt.IsTrue)
+	c.Assert(fsys.Rename("a/file", "b/moved"), qt.IsNil)
+	c.Assert(fsys.Remove("a"), qt.IsNil)
+	c.Assert(fsys.Rename("b", "c"), qt.IsNil)
+	c.Assert(errors.Is(fsys.Rename("c", "c/d"), fs.ErrInvalid), qt.IsTrue)
+
+	// A file that was removed while open can't be committed.
+	f, err := fsys.OpenFile("gone", os.O_WRONLY|os.O_CREATE)
+	c.Assert(err, qt.IsNil)
+	c.Assert(fsys.Remove("gone"), qt.IsNil)
+	_, err = f.Write([]byte("data"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(errors.Is(f.Close(), fs.ErrNotExist), qt.IsTrue)
+
+	fsys, err = Mount(dev, nil)
+	c.Assert(err, qt.IsNil)
+	entries, err := fs.ReadDir(fsys, ".")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(entries), qt.Equals, 1)
+	c.Assert(entries[0].Name(), qt.Equals, "c")
+	data, err := fs.ReadFile(fsys, "c/moved")
+	c.Assert(err, qt.IsNil)
+	c.Assert(data, qt.DeepEquals, pattern(5000, 2))
+}
+
+func TestGarbageCollection(t *testing.T) {
+	c := qt.New(t)
+	dev, fsys := newFS(c, 8)
+
+	// Rewrite a few files many times, which needs many times the size of
+	// the device.
+	keep := pattern(3000, 3)
+	writeFile(c, fsys, "static", keep)
+	for i := 0; i < 200; i++ {
+		writeFile(c, fsys, fmt.Sprintf("file%d", i%3), pattern(1000+i, i))
+	}
+
+	// A file that is open during garbage collection keeps its data.
+	f, err := fsys.OpenFile("open", os.O_RDWR|os.O_CREATE)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write(pattern(2000, 4))
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < 50; i++ {
+		writeFile(c, fsys, "file0", pattern(1000, i))
+	}
+	c.Assert(f.Close(), qt.IsNil)
+
+	fsys, err = Mount(dev, nil)
+	c.Assert(err, qt.IsNil)
+	data, err := fs.ReadFile(fsys, "static")
+	c.Assert(err, qt.IsNil)
+	c.Assert(data, qt.DeepEquals, keep)
+	data, _ = fs.ReadFile(fsys, "open")
+	c.Assert(data, qt.DeepEquals, pattern(2000, 4))
+	data, _ = fs.ReadFile(fsys, "file1")
+	c.Assert(data, qt.DeepEquals, pattern(1000+199, 199))
+	data, _ = fs.ReadFile(fsys, "file0")
+	c.Assert(data, qt.DeepEquals, pattern(1000, 49))
+
+	// All blocks were erased about equally often.
+	min, max := dev.EraseCount[0], dev.EraseCount[0]
+	for _, n := range dev.EraseCount {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	c.Assert(min > 0, qt.IsTrue)
+	c.Assert(max-min <= 2, qt.IsTrue, qt.Commentf("erase counts %v", dev.EraseCount))
+}
+
+func TestNoSpace(t *testing.T) {
+	c := qt.New(t)
+	_, fsys := newFS(c, 8)
+	writeFile(c, fsys, "small", []byte("small"))
+	f, err := fsys.OpenFile("big", os.O_WRONLY|os.O_CREATE)
+	c.Assert(err, qt.IsNil)
+	_, err = f.Write(make([]byte, 64<<10))
+	c.Assert(errors.Is(err, ErrNoSpace), qt.IsTrue)
+	c.Assert(f.Truncate(0), qt.IsNil)
+	c.Assert(f.Close(), qt.IsNil)
+
+	// The filesystem is still usable afterwards.
+	writeFile(c, fsys, "other", pattern(4000, 5))
+	data, err := fs.ReadFile(fsys, "other")
+	c.Assert(err, qt.IsNil)
+	c.Assert(data, qt.DeepEquals, pattern(4000, 5))
+	data, _ = fs.ReadFile(fsys, "small")
+	c.Assert(string(data), qt.Equals, "small")
+}
+
+func TestPowerLoss(t *testing.T) {
+	// Cut the power at many points (in bytes written to the device) while
+	// updating a file, and check that either the old or the new version
+	// survives.
+	c := qt.New(t)
+	dev, fsys := newFS(c, 6)
+	for i := 0; i < 30; i++ {
+		writeFile(c, fsys, "other", pattern(1500, i))
+	}
+	old := pattern(2000, 6)
+	writeFile(c, fsys, "file", old)
+	image := append([]byte(nil), dev.Data...)
+	counts := append([]int(nil), dev.EraseCount...)
+
+	update := pattern(2500, 7)
+	for budget := 1; ; budget += 13 {
+		copy(dev.Data, image)
+		copy(dev.EraseCount, counts)
+		dev.WriteBudget = budget
+		fsys, err := Mount(dev, nil)
+		c.Assert(err, qt.IsNil)
+		err = func() error {
+			for i := 0; i < 2; i++ {
+				f, err := fsys.OpenFile("file", os.O_WRONLY)
+				if err != nil {
+					return err
+				}
+				if _, err := f.WriteAt(update, 0); err != nil {
+					return err
+				}
+				if err := f.Close(); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+		dev.WriteBudget = -1
+		if err == nil {
+			break
+		}
+		c.Assert(errors.Is(err, tester.ErrPowerLoss), qt.IsTrue, qt.Commentf("budget %d: %v", budget, err))
+
+		fsys, err = Mount(dev, nil)
+		c.Assert(err, qt.IsNil)
+		data, err := fs.ReadFile(fsys, "file")
+		c.Assert(err, qt.IsNil)
+		if !bytes.Equal(data, old) && !bytes.Equal(data, update) {
+			c.Fatalf("budget %d: unexpected file contents", budget)
+		}
+		data, err = fs.ReadFile(fsys, "other")
+		c.Assert(err, qt.IsNil)
+		c.Assert(data, qt.DeepEquals, pattern(1500, 29))
+
+		// The filesystem can be written to after the power loss.
+		writeFile(c, fsys, "file", update)
+	}
+}
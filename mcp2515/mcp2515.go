@@ -1,5 +1,10 @@
 // Package mcp2515 implements a driver for the MCP2515 CAN Controller.
 //
+// Received frames are kept in a queue in the driver. A goroutine that calls
+// HandleInterrupt while the INT pin of the controller is low fills it while
+// the application is busy. The SPI bus is not used from a pin interrupt
+// handler, which could interrupt another SPI transaction.
+//
 // Datasheet: http://ww1.microchip.com/downloads/en/DeviceDoc/MCP2515-Stand-Alone-CAN-Controller-with-SPI-20001801J.pdf
 //
 // Reference: https://github.com/coryjfowler/MCP_CAN_lib
@@ -8,18 +13,35 @@ package mcp2515 // import "tinygo.org/x/drivers/mcp2515"
 import (
 	"errors"
 	"fmt"
-	"machine"
+	"sync"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
-// Device wraps MCP2515 SPI CAN Module.
+var (
+	ErrTxTimeout = errors.New("mcp2515: transmit timeout")
+	ErrBusOff    = errors.New("mcp2515: bus-off")
+
+	errNothingReceived = errors.New("mcp2515: nothing is received")
+	errInvalidFilter   = errors.New("mcp2515: invalid filter or mask number")
+)
+
+// Device wraps MCP2515 SPI CAN Module. Its methods can be called from
+// several goroutines, such as one that calls HandleInterrupt.
 type Device struct {
-	spi     SPI
-	cs      machine.Pin
-	msg     *CANMsg
-	mcpMode byte
+	// mu serializes the SPI transactions and protects the receive queue.
+	mu       sync.Mutex
+	spi      SPI
+	cs       drivers.PinOutput
+	msg      *CANMsg
+	frame    drivers.CANFrame
+	mcpMode  byte
+	rx       [rxQueueSize]drivers.CANFrame
+	rxHead   int
+	rxLen    int
+	overflow bool
 }
 
 // CANMsg stores CAN message fields.
@@ -31,19 +53,58 @@ type CANMsg struct {
 	Rtr  bool
 }
 
+// Mode is an operation mode of the MCP2515.
+type Mode byte
+
+const (
+	ModeNormal     Mode = modeNormal
+	ModeSleep      Mode = modeSleep
+	ModeLoopback   Mode = modeLoopBack
+	ModeListenOnly Mode = modeListenOnly
+	ModeConfig     Mode = modeConfig
+)
+
+// ErrorState is the state of the CAN error counters and flags.
+type ErrorState struct {
+	// TxErrors and RxErrors are the transmit and receive error counters.
+	TxErrors uint8
+	RxErrors uint8
+
+	// Warning is set when an error counter reached 96.
+	Warning bool
+
+	// TxPassive and RxPassive are set when the transmit or receive error
+	// counter reached 128. An error passive node no longer sends active
+	// error frames.
+	TxPassive bool
+	RxPassive bool
+
+	// BusOff is set when the transmit error counter exceeded 255. The
+	// controller no longer takes part in bus traffic, until it has seen
+	// 128 times 11 recessive bits.
+	BusOff bool
+
+	// Overflow is set if received frames were lost since the last call to
+	// ErrorState, because they were not read in time.
+	Overflow bool
+}
+
 const (
 	bufferSize int = 64
+
+	// rxQueueSize is the number of received frames that can be queued.
+	rxQueueSize = 16
 )
 
 // New returns a new MCP2515 driver. Pass in a fully configured SPI bus.
-func New(b drivers.SPI, csPin machine.Pin) *Device {
+func New(b drivers.SPI, cs drivers.PinOutput) *Device {
 	d := &Device{
 		spi: SPI{
 			bus: b,
 			tx:  make([]byte, 0, bufferSize),
 			rx:  make([]byte, 0, bufferSize),
 		},
-		cs:  csPin,
+		cs:  cs,
 		msg: &CANMsg{},
 	}
 
@@ -52,13 +113,16 @@ func New(b drivers.SPI, csPin machine.Pin) *Device {
 
 // Configure sets up the device for communication.
 func (d *Device) Configure() {
-	d.cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	legacy.ConfigurePin(d.cs, drivers.PinModeOutput)
+	d.cs.High()
 }
 
 const beginTimeoutValue int = 10
 
 // Begin starts the CAN controller.
 func (d *Device) Begin(speed byte, clock byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	timeOutCount := 0
 	for {
 		err := d.init(speed, clock)
@@ -73,26 +137,56 @@ func (d *Device) Begin(speed byte, clock byte) error {
 	return nil
 }
 
-// Received returns true if CAN message is received.
+// Received returns true if CAN message is received. It returns false if the
+// status could not be read.
 func (d *Device) Received() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.rxLen > 0 {
+		return true
+	}
 	res, err := d.readStatus()
 	if err != nil {
-		panic(err)
+		return false
 	}
-	// if RX STATUS INSTRUCTION  result is not 0x00 (= No RX message)
-	// TODO: reconsider this logic
 	return (res & mcpStatRxifMask) != 0x00
 }
 
 // Rx returns received CAN message.
 func (d *Device) Rx() (*CANMsg, error) {
-	err := d.readMsg()
-	return d.msg, err
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ok, err := d.receive(&d.frame)
+	if err != nil {
+		return d.msg, err
+	}
+	if !ok {
+		return d.msg, errNothingReceived
+	}
+	d.msg.ID = d.frame.ID
+	d.msg.Dlc = d.frame.DLC
+	d.msg.Data = d.frame.Payload()
+	d.msg.Ext = d.frame.Extended
+	d.msg.Rtr = d.frame.Remote
+	return d.msg, nil
 }
 
-// Tx transmits CAN Message.
+// Tx transmits CAN Message. IDs above 0x7FF are sent as extended IDs.
 func (d *Device) Tx(canid uint32, dlc uint8, data []byte) error {
-	// TODO: add ext, rtrBit, waitSent
+	f := drivers.CANFrame{
+		ID:       canid,
+		Extended: canid > 0x7FF,
+		DLC:      dlc,
+	}
+	copy(f.Data[:], data)
+	return d.Transmit(&f)
+}
+
+// Transmit loads a frame into a free transmit buffer and requests its
+// transmission. Use Flush to wait until it has been sent.
+func (d *Device) Transmit(f *drivers.CANFrame) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	timeoutCount := 0
 
 	var bufNum, res uint8
@@ -100,7 +194,10 @@ func (d *Device) Tx(canid uint32, dlc uint8, data []byte) error {
 	res = mcpAlltxbusy
 	for res == mcpAlltxbusy && (timeoutCount < timeoutvalue) {
 		if timeoutCount > 0 {
+			// Let HandleInterrupt run while waiting.
+			d.mu.Unlock()
 			time.Sleep(time.Microsecond * 10)
+			d.mu.Lock()
 		}
 		bufNum, res, err = d.getNextFreeTxBuf()
 		if err != nil {
@@ -108,19 +205,219 @@ func (d *Device) Tx(canid uint32, dlc uint8, data []byte) error {
 		}
 		timeoutCount++
 	}
-	if timeoutCount == timeoutvalue {
-		return fmt.Errorf("Tx: Tx timeout")
+	if res == mcpAlltxbusy {
+		return ErrTxTimeout
 	}
-	err = d.writeCANMsg(bufNum, canid, 0, 0, dlc, data)
-	if err != nil {
+	return d.writeCANMsg(bufNum, f)
+}
+
+// Flush waits until all frames passed to Transmit have been sent. If the
+// controller goes bus-off or the frames can't be sent in time, for example
+// because no other node acknowledges them, the pending transmissions are
+// aborted and ErrBusOff or ErrTxTimeout is returned.
+func (d *Device) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	start := time.Now()
+	for {
+		status, err := d.readStatus()
+		if err != nil {
+			return err
+		}
+		if status&mcpStatTxPendingMask == 0 {
+			return nil
+		}
+		eflg, err := d.readRegister(mcpEFLG)
+		if err != nil {
+			return err
+		}
+		if eflg&mcpEflgTxbo != 0 {
+			err = ErrBusOff
+		} else if time.Since(start) > cansendtimeout*time.Millisecond {
+			err = ErrTxTimeout
+		} else {
+			d.mu.Unlock()
+			time.Sleep(time.Microsecond * 10)
+			d.mu.Lock()
+			continue
+		}
+		if err := d.abort(); err != nil {
+			return err
+		}
 		return err
 	}
+}
+
+// abort aborts all pending transmissions.
+func (d *Device) abort() error {
+	if err := d.modifyRegister(mcpCANCTRL, abortTx, abortTx); err != nil {
+		return err
+	}
+	return d.modifyRegister(mcpCANCTRL, abortTx, 0)
+}
 
+// Receive copies the next received frame into f. It returns false if no
+// frame has been received.
+func (d *Device) Receive(f *drivers.CANFrame) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.receive(f)
+}
+
+func (d *Device) receive(f *drivers.CANFrame) (bool, error) {
+	if d.rxLen == 0 {
+		if err := d.handleInterrupt(); err != nil {
+			return false, err
+		}
+		if d.rxLen == 0 {
+			return false, nil
+		}
+	}
+	*f = d.rx[d.rxHead]
+	d.rxHead = (d.rxHead + 1) % rxQueueSize
+	d.rxLen--
+	return true, nil
+}
+
+// HandleInterrupt moves received frames from the controller into the receive
+// queue and handles error interrupts. Receive calls it when the queue is
+// empty, so calling it is only needed to avoid losing frames when they arrive
+// faster than they are received. The controller only holds two frames, so
+// call it as soon as its INT pin goes low, from a goroutine that watches the
+// pin while the application uses the device:
+//
+//	go func() {
+//		for {
+//			for !intPin.Get() {
+//				dev.HandleInterrupt()
+//			}
+//			time.Sleep(100 * time.Microsecond)
+//		}
+//	}()
+//
+// It must not be called from an interrupt handler itself, as it uses the SPI
+// bus and waits for the other methods to finish.
+func (d *Device) HandleInterrupt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.handleInterrupt()
+}
+
+func (d *Device) handleInterrupt() error {
+	intf, err := d.readRegister(mcpCANINTF)
+	if err != nil {
+		return err
+	}
+	if intf&mcpRX0IF != 0 {
+		if err := d.readRxBuffer(mcpReadRx0); err != nil {
+			return err
+		}
+	}
+	if intf&mcpRX1IF != 0 {
+		if err := d.readRxBuffer(mcpReadRx1); err != nil {
+			return err
+		}
+	}
+	if intf&(mcpERRIF|mcpMERRF) != 0 {
+		eflg, err := d.readRegister(mcpEFLG)
+		if err != nil {
+			return err
+		}
+		if eflg&(mcpEflgRx0ovr|mcpEflgRx1ovr) != 0 {
+			d.overflow = true
+			if err := d.modifyRegister(mcpEFLG, mcpEflgRx0ovr|mcpEflgRx1ovr, 0); err != nil {
+				return err
+			}
+		}
+		return d.modifyRegister(mcpCANINTF, mcpERRIF|mcpMERRF, 0)
+	}
 	return nil
 }
 
+// ErrorState returns the error counters and flags of the controller.
+func (d *Device) ErrorState() (ErrorState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var state ErrorState
+	var err error
+	if state.TxErrors, err = d.readRegister(mcpTEC); err != nil {
+		return state, err
+	}
+	if state.RxErrors, err = d.readRegister(mcpREC); err != nil {
+		return state, err
+	}
+	eflg, err := d.readRegister(mcpEFLG)
+	if err != nil {
+		return state, err
+	}
+	state.Warning = eflg&mcpEflgEwarn != 0
+	state.TxPassive = eflg&mcpEflgTxep != 0
+	state.RxPassive = eflg&mcpEflgRxep != 0
+	state.BusOff = eflg&mcpEflgTxbo != 0
+	state.Overflow = d.overflow || eflg&(mcpEflgRx0ovr|mcpEflgRx1ovr) != 0
+	d.overflow = false
+	if eflg&(mcpEflgRx0ovr|mcpEflgRx1ovr) != 0 {
+		err = d.modifyRegister(mcpEFLG, mcpEflgRx0ovr|mcpEflgRx1ovr, 0)
+	}
+	return state, err
+}
+
+// SetMode changes the operation mode. In loopback mode transmitted frames
+// are received again without being sent on the bus, and in listen-only mode
+// frames are received without acknowledging them.
+func (d *Device) SetMode(mode Mode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.setMode(byte(mode))
+}
+
+// SetMask sets acceptance mask n, which is 0 for receive buffer 0 and 1 for
+// receive buffer 1. Only the ID bits that are set in the mask are compared to
+// the filters of the buffer, so a zero mask accepts all frames. A standard
+// mask only compares the 11-bit ID, an extended mask the full 29-bit ID.
+func (d *Device) SetMask(n int, mask uint32, extended bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n < 0 || n > 1 {
+		return errInvalidFilter
+	}
+	regs := encodeID(mask, extended)
+	regs[1] &^= mcpTxbExideM
+	return d.setFilterRegisters(mcpRXM0SIDH+4*byte(n), regs)
+}
+
+// SetFilter sets acceptance filter n. Filters 0 and 1 belong to receive
+// buffer 0, filters 2 to 5 to receive buffer 1. A filter only accepts frames
+// with the same kind of ID (standard or extended).
+func (d *Device) SetFilter(n int, id uint32, extended bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n < 0 || n > 5 {
+		return errInvalidFilter
+	}
+	addr := byte(mcpRXF0SIDH + 4*n)
+	if n >= 3 {
+		addr = byte(mcpRXF3SIDH + 4*(n-3))
+	}
+	return d.setFilterRegisters(addr, encodeID(id, extended))
+}
+
+// setFilterRegisters writes the four ID registers of a filter or mask, which
+// requires configuration mode.
+func (d *Device) setFilterRegisters(addr byte, regs [4]byte) error {
+	if err := d.setCANCTRLMode(modeConfig); err != nil {
+		return err
+	}
+	for i, v := range regs {
+		if err := d.setRegister(addr+byte(i), v); err != nil {
+			return err
+		}
+	}
+	return d.setCANCTRLMode(d.mcpMode)
+}
+
 func (d *Device) init(speed, clock byte) error {
-	err := d.Reset()
+	err := d.reset()
 	if err != nil {
 		return err
 	}
@@ -139,7 +436,7 @@ func (d *Device) init(speed, clock byte) error {
 	if err := d.initCANBuffers(); err != nil {
 		return fmt.Errorf("initCANBuffers: %s ", err)
 	}
-	if err := d.setRegister(mcpCANINTE, mcpRX0IF|mcpRX1IF); err != nil {
+	if err := d.setRegister(mcpCANINTE, mcpRX0IF|mcpRX1IF|mcpERRIF); err != nil {
 		return fmt.Errorf("setRegister: %s ", err)
 	}
 	if err := d.modifyRegister(mcpRXB0CTRL, mcpRxbRxMask|mcpRxbBuktMask, mcpRxbRxStdExt|mcpRxbBuktMask); err != nil {
@@ -159,9 +456,15 @@ func (d *Device) init(speed, clock byte) error {
 
 // Reset resets mcp2515.
 func (d *Device) Reset() error {
-	d.cs.Low()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reset()
+}
+
+func (d *Device) reset() error {
+	d.cs.Set(false)
 	_, err := d.spi.readWrite(mcpReset)
-	d.cs.High()
+	d.cs.Set(true)
 	// time.Sleep(time.Microsecond * 4)
 	if err != nil {
 		return err
@@ -304,6 +607,17 @@ func (d *Device) initCANBuffers() error {
 		a3++
 	}
 
+	// Clear the masks, and alternate the filters between extended and
+	// standard IDs, so both kinds of frames are received.
+	for i, addr := range []byte{mcpRXM0SIDH, mcpRXM1SIDH, mcpRXF0SIDH, mcpRXF1SIDH, mcpRXF2SIDH, mcpRXF3SIDH, mcpRXF4SIDH, mcpRXF5SIDH} {
+		regs := encodeID(0, i >= 2 && i%2 == 0)
+		for j, v := range regs {
+			if err := d.setRegister(addr+byte(j), v); err != nil {
+				return err
+			}
+		}
+	}
+
 	if err := d.setRegister(mcpRXB0CTRL, 0); err != nil {
 		return err
 	}
@@ -314,71 +628,35 @@ func (d *Device) initCANBuffers() error {
 	return nil
 }
 
-func (d *Device) readMsg() error {
-	status, err := d.readRxTxStatus()
-	if err != nil {
-		return err
-	}
-	if (status & mcpRX0IF) == 0x01 {
-		err := d.readRxBuffer(mcpReadRx0)
-		if err != nil {
-			return err
-		}
-	} else if (status & mcpRX1IF) == 0x02 {
-		err := d.readRxBuffer(mcpReadRx1)
-		if err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("readMsg: nothing is received")
-	}
-
-	return nil
-}
-
+// readRxBuffer reads a receive buffer into the receive queue.
 func (d *Device) readRxBuffer(loadAddr uint8) error {
-	msg := d.msg
-	d.cs.Low()
-	defer d.cs.High()
+	d.cs.Set(false)
+	defer d.cs.Set(true)
 	_, err := d.spi.readWrite(loadAddr)
 	if err != nil {
 		return err
 	}
-	err = d.spi.read(4)
+	err = d.spi.read(5 + canMaxCharInMessage)
 	if err != nil {
 		return err
 	}
-	buf := d.spi.rx
-	msg.ID = uint32((uint32(buf[0]) << 3) + (uint32(buf[1]) >> 5))
-	msg.Ext = false
-	if (buf[1] & mcpTxbExideM) == mcpTxbExideM {
-		// extended id
-		msg.ID = uint32(uint32(msg.ID<<2) + uint32(buf[1]&0x03))
-		msg.ID = uint32(uint32(msg.ID<<8) + uint32(buf[2]))
-		msg.ID = uint32(uint32(msg.ID<<8) + uint32(buf[3]))
-		msg.Ext = true
+	if d.rxLen == rxQueueSize {
+		d.overflow = true
+		return nil
 	}
-	err = d.spi.read(1)
-	if err != nil {
-		return err
-	}
-	msgSize := d.spi.rx[0]
-	msg.Dlc = uint8(msgSize & mcpDlcMask)
-	msg.Rtr = false
-	if (msgSize & mcpRtrMask) == 0x40 {
-		msg.Rtr = true
-	}
-	readLen := uint8(canMaxCharInMessage)
-	if msg.Dlc < canMaxCharInMessage {
-		readLen = msg.Dlc
-	}
-	err = d.spi.read(int(readLen))
-	if err != nil {
-		return err
+	buf := d.spi.rx
+	f := &d.rx[(d.rxHead+d.rxLen)%rxQueueSize]
+	d.rxLen++
+	f.ID, f.Extended = decodeID(buf)
+	f.DLC = buf[4] & mcpDlcMask
+	if f.Extended {
+		f.Remote = buf[4]&mcpRxbRtrM != 0
+	} else {
+		f.Remote = buf[1]&mcpRxbSrrM != 0
 	}
-	msg.Data = d.spi.rx
+	copy(f.Data[:], buf[5:])
 
-	return err
+	return nil
 }
 
 func (d *Device) getNextFreeTxBuf() (uint8, uint8, error) {
@@ -405,9 +683,9 @@ func (d *Device) getNextFreeTxBuf() (uint8, uint8, error) {
 	return 0, mcpAlltxbusy, nil
 }
 
-func (d *Device) writeCANMsg(bufNum uint8, canid uint32, ext, rtrBit, dlc uint8, data []byte) error {
-	d.cs.Low()
-	defer d.cs.High()
+func (d *Device) writeCANMsg(bufNum uint8, f *drivers.CANFrame) error {
+	d.cs.Set(false)
+	defer d.cs.Set(true)
 	_, err := d.spi.readWrite(txSidhToLoad(bufNum))
 	if err != nil {
 		return err
@@ -416,7 +694,7 @@ func (d *Device) writeCANMsg(bufNum uint8, canid uint32, ext, rtrBit, dlc uint8,
 	if err != nil {
 		return err
 	}
-	err = d.spi.setTxBufData(canid, ext, rtrBit, dlc, data)
+	err = d.spi.setTxBufData(f)
 	if err != nil {
 		return err
 	}
@@ -426,7 +704,7 @@ func (d *Device) writeCANMsg(bufNum uint8, canid uint32, ext, rtrBit, dlc uint8,
 	}
 	// Since cs.Low and cs.High are executed in d.startTransmission,
 	// it is necessary to set cs.High once to separate the instruction of mcp2515.
-	d.cs.High()
+	d.cs.Set(true)
 
 	err = d.startTransmission(bufNum)
 	if err != nil {
@@ -436,54 +714,21 @@ func (d *Device) writeCANMsg(bufNum uint8, canid uint32, ext, rtrBit, dlc uint8,
 	return nil
 }
 
-func (s *SPI) setTxBufData(canid uint32, ext, rtrBit, dlc uint8, data []byte) error {
-	canid = canid & 0x0FFFF
-	if ext == 1 {
-		// TODO: add Extended ID
-		err := s.setTxData(0)
-		if err != nil {
-			return err
-		}
-		err = s.setTxData(0)
-		if err != nil {
-			return err
-		}
-		err = s.setTxData(0)
-		if err != nil {
-			return err
-		}
-		err = s.setTxData(0)
-		if err != nil {
-			return err
-		}
-	} else {
-		err := s.setTxData(byte(canid >> 3))
-		if err != nil {
-			return err
-		}
-		err = s.setTxData(byte((canid & 0x07) << 5))
-		if err != nil {
-			return err
-		}
-		err = s.setTxData(0)
-		if err != nil {
-			return err
-		}
-		err = s.setTxData(0)
-		if err != nil {
+func (s *SPI) setTxBufData(f *drivers.CANFrame) error {
+	for _, b := range encodeID(f.ID, f.Extended) {
+		if err := s.setTxData(b); err != nil {
 			return err
 		}
 	}
-	if rtrBit == 1 {
+	dlc := f.DLC & mcpDlcMask
+	if f.Remote {
 		dlc |= mcpRtrMask
-	} else {
-		dlc |= (0)
 	}
 	err := s.setTxData(dlc)
 	if err != nil {
 		return err
 	}
-	for _, d := range data {
+	for _, d := range f.Payload() {
 		err := s.setTxData(d)
 		if err != nil {
 			return err
@@ -493,10 +738,39 @@ func (s *SPI) setTxBufData(canid uint32, ext, rtrBit, dlc uint8, data []byte) er
 	return nil
 }
 
+// encodeID returns the SIDH, SIDL, EID8 and EID0 registers for an ID.
+func encodeID(id uint32, ext bool) [4]byte {
+	if !ext {
+		id &= 0x7FF
+		return [4]byte{byte(id >> 3), byte((id & 0x07) << 5), 0, 0}
+	}
+	id &= 0x1FFFFFFF
+	sid := id >> 18
+	return [4]byte{
+		byte(sid >> 3),
+		byte((sid&0x07)<<5) | mcpTxbExideM | byte(id>>16)&0x03,
+		byte(id >> 8),
+		byte(id),
+	}
+}
+
+// decodeID returns the ID in the SIDH, SIDL, EID8 and EID0 registers.
+func decodeID(buf []byte) (id uint32, ext bool) {
+	id = uint32((uint32(buf[0]) << 3) + (uint32(buf[1]) >> 5))
+	if (buf[1] & mcpTxbExideM) == mcpTxbExideM {
+		// extended id
+		id = uint32(uint32(id<<2) + uint32(buf[1]&0x03))
+		id = uint32(uint32(id<<8) + uint32(buf[2]))
+		id = uint32(uint32(id<<8) + uint32(buf[3]))
+		ext = true
+	}
+	return id, ext
+}
+
 func (d *Device) startTransmission(bufNum uint8) error {
-	d.cs.Low()
+	d.cs.Set(false)
 	_, err := d.spi.readWrite(txSidhToRTS(bufNum))
-	d.cs.High()
+	d.cs.Set(true)
 	if err != nil {
 		return err
 	}
@@ -590,8 +864,8 @@ func txSidhToLoad(i uint8) uint8 {
 }
 
 func (d *Device) setRegister(addr, value byte) error {
-	d.cs.Low()
-	defer d.cs.High()
+	d.cs.Set(false)
+	defer d.cs.Set(true)
 	_, err := d.spi.readWrite(mcpWrite)
 	if err != nil {
 		return err
@@ -610,8 +884,8 @@ func (d *Device) setRegister(addr, value byte) error {
 }
 
 func (d *Device) readRegister(addr byte) (byte, error) {
-	d.cs.Low()
-	defer d.cs.High()
+	d.cs.Set(false)
+	defer d.cs.Set(true)
 	_, err := d.spi.readWrite(mcpRead)
 	if err != nil {
 		return 0, err
@@ -629,8 +903,8 @@ func (d *Device) readRegister(addr byte) (byte, error) {
 }
 
 func (d *Device) modifyRegister(addr, mask, data byte) error {
-	d.cs.Low()
-	defer d.cs.High()
+	d.cs.Set(false)
+	defer d.cs.Set(true)
 	_, err := d.spi.readWrite(mcpBitMod)
 	if err != nil {
 		return err
@@ -672,8 +946,8 @@ func (d *Device) requestNewMode(newMode byte) error {
 }
 
 func (d *Device) readStatus() (byte, error) {
-	d.cs.Low()
-	defer d.cs.High()
+	d.cs.Set(false)
+	defer d.cs.Set(true)
 	_, err := d.spi.readWrite(mcpReadStatus)
 	if err != nil {
 		return 0, err
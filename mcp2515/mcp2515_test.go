@@ -0,0 +1,250 @@
+package mcp2515
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/tester"
+)
+
+func newDevice(c *qt.C) (*tester.MCP2515, *Device) {
+	chip := tester.NewMCP2515()
+	d := New(chip, chip)
+	c.Assert(d.Begin(CAN500kBps, Clock8MHz), qt.IsNil)
+	return chip, d
+}
+
+func frame(id uint32, ext bool, data ...byte) drivers.CANFrame {
+	f := drivers.CANFrame{ID: id, Extended: ext, DLC: uint8(len(data))}
+	copy(f.Data[:], data)
+	return f
+}
+
+func TestTransmit(t *testing.T) {
+	c := qt.New(t)
+	chip, d := newDevice(c)
+	var _ drivers.CAN = d
+
+	c.Assert(d.Tx(0x111, 3, []byte{1, 2, 3}), qt.IsNil)
+	f := frame(0x18DAF110, true, 0x02, 0x01, 0x0D)
+	c.Assert(d.Transmit(&f), qt.IsNil)
+	f = drivers.CANFrame{ID: 0x7DF, Remote: true, DLC: 8}
+	c.Assert(d.Transmit(&f), qt.IsNil)
+	c.Assert(d.Flush(), qt.IsNil)
+	c.Assert(chip.Sent, qt.DeepEquals, []drivers.CANFrame{
+		frame(0x111, false, 1, 2, 3),
+		frame(0x18DAF110, true, 0x02, 0x01, 0x0D),
+		{ID: 0x7DF, Remote: true, DLC: 8},
+	})
+}
+
+func TestReceive(t *testing.T) {
+	c := qt.New(t)
+	chip, d := newDevice(c)
+
+	_, err := d.Rx()
+	c.Assert(err, qt.Equals, errNothingReceived)
+	c.Assert(d.Received(), qt.IsFalse)
+
+	// Both receive buffers fill up, later frames are lost.
+	c.Assert(chip.Receive(frame(0x123, false, 0xAA)), qt.IsTrue)
+	c.Assert(chip.Receive(frame(0x1ABCDEF, true, 1, 2, 3, 4, 5, 6, 7, 8)), qt.IsTrue)
+	c.Assert(chip.Receive(drivers.CANFrame{ID: 0x7E8, Remote: true, DLC: 2}), qt.IsFalse)
+	c.Assert(chip.Interrupt(), qt.IsTrue)
+	c.Assert(d.Received(), qt.IsTrue)
+
+	msg, err := d.Rx()
+	c.Assert(err, qt.IsNil)
+	c.Assert(*msg, qt.DeepEquals, CANMsg{ID: 0x123, Dlc: 1, Data: []byte{0xAA}})
+	var f drivers.CANFrame
+	ok, err := d.Receive(&f)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(f, qt.Equals, frame(0x1ABCDEF, true, 1, 2, 3, 4, 5, 6, 7, 8))
+	ok, err = d.Receive(&f)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	state, err := d.ErrorState()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state.Overflow, qt.IsTrue)
+	c.Assert(chip.Interrupt(), qt.IsFalse)
+
+	// Remote frames.
+	c.Assert(chip.Receive(drivers.CANFrame{ID: 0x7E8, Remote: true, DLC: 2}), qt.IsTrue)
+	msg, err = d.Rx()
+	c.Assert(err, qt.IsNil)
+	c.Assert(msg.Rtr, qt.IsTrue)
+	c.Assert(msg.Data, qt.HasLen, 0)
+}
+
+func TestReceiveQueue(t *testing.T) {
+	c := qt.New(t)
+	chip, d := newDevice(c)
+
+	// Handling the interrupt after every frame queues them in the driver.
+	for i := 0; i < rxQueueSize+1; i++ {
+		c.Assert(chip.Receive(frame(uint32(i), false, byte(i))), qt.IsTrue)
+		c.Assert(d.HandleInterrupt(), qt.IsNil)
+	}
+	for i := 0; i < rxQueueSize; i++ {
+		var f drivers.CANFrame
+		ok, err := d.Receive(&f)
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(f.ID, qt.Equals, uint32(i))
+	}
+	state, err := d.ErrorState()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state.Overflow, qt.IsTrue)
+	state, err = d.ErrorState()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state.Overflow, qt.IsFalse)
+}
+
+func TestHandleInterruptGoroutine(t *testing.T) {
+	c := qt.New(t)
+	_, d := newDevice(c)
+	c.Assert(d.SetMode(ModeLoopback), qt.IsNil)
+
+	// A goroutine drains the controller while frames are sent, so that more
+	// frames are received than its two buffers hold.
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			c.Check(d.HandleInterrupt(), qt.IsNil)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	const n = 10
+	for i := 0; i < n; i++ {
+		f := frame(uint32(i), false, byte(i))
+		c.Assert(d.Transmit(&f), qt.IsNil)
+		c.Assert(d.Flush(), qt.IsNil)
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	<-stopped
+
+	for i := 0; i < n; i++ {
+		var f drivers.CANFrame
+		ok, err := d.Receive(&f)
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(f.ID, qt.Equals, uint32(i))
+	}
+	state, err := d.ErrorState()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state.Overflow, qt.IsFalse)
+}
+
+func TestFilters(t *testing.T) {
+	c := qt.New(t)
+	chip, d := newDevice(c)
+
+	// Buffer 0 accepts standard IDs 0x7E8-0x7EF, buffer 1 the extended
+	// J1939 PGN 0xFEF1 from any source.
+	c.Assert(d.SetMask(0, 0x7F8, false), qt.IsNil)
+	c.Assert(d.SetFilter(0, 0x7E8, false), qt.IsNil)
+	c.Assert(d.SetFilter(1, 0x7E8, false), qt.IsNil)
+	c.Assert(d.SetMask(1, 0x03FFFF00, true), qt.IsNil)
+	for i := 2; i < 6; i++ {
+		c.Assert(d.SetFilter(i, 0x00FEF100, true), qt.IsNil)
+	}
+	c.Assert(d.SetFilter(6, 0, false), qt.Equals, errInvalidFilter)
+	c.Assert(d.SetMask(2, 0, false), qt.Equals, errInvalidFilter)
+	c.Assert(chip.Register(mcpCANSTAT)&modeMask, qt.Equals, byte(modeNormal))
+
+	c.Assert(chip.Receive(frame(0x7DF, false)), qt.IsFalse)
+	c.Assert(chip.Receive(frame(0x7E8, true)), qt.IsFalse)
+	c.Assert(chip.Receive(frame(0x18FEF100, true)), qt.IsTrue)
+	c.Assert(chip.Receive(frame(0x7EA, false)), qt.IsTrue)
+	var ids []uint32
+	var f drivers.CANFrame
+	for {
+		ok, err := d.Receive(&f)
+		c.Assert(err, qt.IsNil)
+		if !ok {
+			break
+		}
+		ids = append(ids, f.ID)
+	}
+	c.Assert(ids, qt.DeepEquals, []uint32{0x7EA, 0x18FEF100})
+}
+
+func TestModes(t *testing.T) {
+	c := qt.New(t)
+	chip, d := newDevice(c)
+
+	// Loopback mode receives its own frames, without sending them.
+	c.Assert(d.SetMode(ModeLoopback), qt.IsNil)
+	c.Assert(d.Tx(0x321, 2, []byte{4, 5}), qt.IsNil)
+	c.Assert(d.Flush(), qt.IsNil)
+	c.Assert(chip.Sent, qt.HasLen, 0)
+	msg, err := d.Rx()
+	c.Assert(err, qt.IsNil)
+	c.Assert(msg.ID, qt.Equals, uint32(0x321))
+	c.Assert(msg.Data, qt.DeepEquals, []byte{4, 5})
+
+	// Filters can be changed without leaving the mode.
+	c.Assert(d.SetFilter(0, 0, false), qt.IsNil)
+	c.Assert(chip.Register(mcpCANSTAT)&modeMask, qt.Equals, byte(modeLoopBack))
+
+	// Listen-only mode receives but doesn't transmit.
+	c.Assert(d.SetMode(ModeListenOnly), qt.IsNil)
+	c.Assert(chip.Receive(frame(0x100, false)), qt.IsTrue)
+	c.Assert(d.Received(), qt.IsTrue)
+	c.Assert(d.Tx(0x200, 0, nil), qt.IsNil)
+	c.Assert(d.Flush(), qt.Equals, ErrTxTimeout)
+	c.Assert(chip.Sent, qt.HasLen, 0)
+}
+
+func TestBusOff(t *testing.T) {
+	c := qt.New(t)
+	chip, d := newDevice(c)
+
+	chip.SetErrorCounters(100, 130)
+	state, err := d.ErrorState()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, ErrorState{TxErrors: 100, RxErrors: 130, Warning: true, RxPassive: true})
+
+	chip.FailTx = true
+	c.Assert(d.Tx(0x111, 0, nil), qt.IsNil)
+	for i := 0; i < 20 && !chip.Interrupt(); i++ {
+		// Every read of the status retries the transmission.
+		d.readStatus()
+	}
+	c.Assert(d.Flush(), qt.Equals, ErrBusOff)
+	state, err = d.ErrorState()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state.BusOff, qt.IsTrue)
+	c.Assert(state.TxPassive, qt.IsTrue)
+	c.Assert(state.TxErrors, qt.Equals, uint8(255))
+
+	// The error interrupt is handled without losing frames.
+	c.Assert(d.HandleInterrupt(), qt.IsNil)
+	c.Assert(chip.Interrupt(), qt.IsFalse)
+}
+
+func TestSPITransactions(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewSPIBus(c)
+	chip := tester.NewMCP2515()
+	d := New(bus, bus.AddDevice(chip))
+	c.Assert(d.Begin(CAN500kBps, Clock8MHz), qt.IsNil)
+
+	// Each instruction is sent while the chip is selected.
+	c.Assert(bus.Transactions[0].W, qt.DeepEquals, []byte{0xC0})
+	for _, tr := range bus.Transactions {
+		c.Assert(tr.Device, qt.Equals, tester.SPIDevice(chip))
+	}
+}
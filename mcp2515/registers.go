@@ -38,6 +38,7 @@ const (
 	mcpTxbRtrM = 0x40 // in txbndlc
 	mcpRxbIdeM = 0x08 // in rxbnsidl
 	mcpRxbRtrM = 0x40 // in rxbndlc
+	mcpRxbSrrM = 0x10 // in rxbnsidl
 
 	mcpStatTxPendingMask = 0x54
 	mcpStatTx0Pending    = 0x04
@@ -8,12 +8,13 @@ import (
 	"machine"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 // Device wraps MCP3008 SPI ADC.
 type Device struct {
 	bus drivers.SPI
-	cs  machine.Pin
+	cs  drivers.PinOutput
 	tx  []byte
 	rx  []byte
 	CH0 ADCPin
@@ -33,7 +34,7 @@ type ADCPin struct {
 }
 
 // New returns a new MCP3008 driver. Pass in a fully configured SPI bus.
-func New(b drivers.SPI, csPin machine.Pin) *Device {
+func New(b drivers.SPI, csPin drivers.PinOutput) *Device {
 	d := &Device{bus: b,
 		cs: csPin,
 		tx: make([]byte, 3),
@@ -55,7 +56,7 @@ func New(b drivers.SPI, csPin machine.Pin) *Device {
 
 // Configure sets up the device for communication
 func (d *Device) Configure() {
-	d.cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	legacy.ConfigurePin(d.cs, drivers.PinModeOutput)
 }
 
 // Read analog data from channel
@@ -0,0 +1,124 @@
+package onewire
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+)
+
+// timing holds the durations of the reset pulse and time slots.
+type timing struct {
+	resetLow, presenceWait, resetRelease time.Duration
+	write1Low, write1Release             time.Duration
+	write0Low, write0Release             time.Duration
+	readLow, readWait, readRelease       time.Duration
+}
+
+var (
+	standardTiming = timing{
+		480 * time.Microsecond, 70 * time.Microsecond, 410 * time.Microsecond,
+		5 * time.Microsecond, 60 * time.Microsecond,
+		60 * time.Microsecond, 5 * time.Microsecond,
+		3 * time.Microsecond, 8 * time.Microsecond, 60 * time.Microsecond,
+	}
+	overdriveTiming = timing{
+		70 * time.Microsecond, 8500 * time.Nanosecond, 40 * time.Microsecond,
+		1 * time.Microsecond, 7500 * time.Nanosecond,
+		7500 * time.Nanosecond, 2500 * time.Nanosecond,
+		1 * time.Microsecond, 1 * time.Microsecond, 7 * time.Microsecond,
+	}
+)
+
+// gpio is a bit-banged bus master on a GPIO pin.
+type gpio struct {
+	p drivers.Pin
+	t *timing
+}
+
+// New creates a new GPIO 1-Wire connection.
+// The pin must be pulled up to the VCC via a resistor greater than 500 ohms (default 4.7k).
+func New(p drivers.Pin) Device {
+	return NewMaster(&gpio{
+		p: p,
+		t: &standardTiming,
+	})
+}
+
+// low pulls the bus low. This also ends a strong pull-up.
+func (g *gpio) low() {
+	g.p.Low()
+	legacy.ConfigurePin(g.p, drivers.PinModeOutput)
+}
+
+// release lets the pull-up resistor pull the bus high.
+func (g *gpio) release() {
+	legacy.ConfigurePin(g.p, drivers.PinModeInput)
+}
+
+// Reset pull DQ line low, then up.
+func (g *gpio) Reset() (bool, error) {
+	g.low()
+	time.Sleep(g.t.resetLow)
+	g.release()
+	time.Sleep(g.t.presenceWait)
+	presence := !g.p.Get()
+	time.Sleep(g.t.resetRelease)
+	return presence, nil
+}
+
+func (g *gpio) WriteBit(data uint8) {
+	g.low()
+	if data&1 == 1 { // Send '1'
+		time.Sleep(g.t.write1Low)
+		g.release()
+		time.Sleep(g.t.write1Release)
+	} else { // Send '0'
+		time.Sleep(g.t.write0Low)
+		g.release()
+		time.Sleep(g.t.write0Release)
+	}
+}
+
+func (g *gpio) Write(data uint8) {
+	for i := 0; i < 8; i++ {
+		g.WriteBit(data)
+		data >>= 1
+	}
+}
+
+// WritePower drives the pin high after the byte, which requires a pin that
+// can source the current of the devices.
+func (g *gpio) WritePower(data uint8) {
+	g.Write(data)
+	g.p.High()
+	legacy.ConfigurePin(g.p, drivers.PinModeOutput)
+}
+
+func (g *gpio) ReadBit() (data uint8) {
+	g.low()
+	time.Sleep(g.t.readLow)
+	g.release()
+	time.Sleep(g.t.readWait)
+	if g.p.Get() {
+		data = 1
+	}
+	time.Sleep(g.t.readRelease)
+	return data
+}
+
+func (g *gpio) Read() (data uint8) {
+	for i := 0; i < 8; i++ {
+		data >>= 1
+		data |= g.ReadBit() << 7
+	}
+	return data
+}
+
+func (g *gpio) SetOverdrive(overdrive bool) {
+	if overdrive {
+		g.t = &overdriveTiming
+	} else {
+		g.t = &standardTiming
+	}
+}
@@ -1,25 +1,63 @@
 // Package wire implements the Dallas Semiconductor Corp.'s 1-wire bus system.
 //
+// The bus is driven by a Master: a GPIO pin (see New), or a bridge chip such
+// as the DS2482 (see the ds2482 package) which generates the time slots in
+// hardware, so that they don't depend on interrupts of the CPU.
+//
 // Wikipedia: https://en.wikipedia.org/wiki/1-Wire
 package onewire // import "tinygo.org/x/drivers/onewire"
 
 import (
 	"errors"
-	"machine"
-	"time"
 )
 
 // OneWire ROM commands
 const (
-	READ_ROM   uint8 = 0x33
-	MATCH_ROM  uint8 = 0x55
-	SKIP_ROM   uint8 = 0xCC
-	SEARCH_ROM uint8 = 0xF0
+	READ_ROM            uint8 = 0x33
+	MATCH_ROM           uint8 = 0x55
+	SKIP_ROM            uint8 = 0xCC
+	SEARCH_ROM          uint8 = 0xF0
+	OVERDRIVE_SKIP_ROM  uint8 = 0x3C
+	OVERDRIVE_MATCH_ROM uint8 = 0x69
+
+	// CONDITIONAL_READ_ROM is answered by the device of a chain of DS28EA00
+	// that is next in the sequence.
+	CONDITIONAL_READ_ROM uint8 = 0x0F
 )
 
+// Master is a 1-Wire bus master, which generates the reset pulse and the
+// time slots on the bus.
+type Master interface {
+	// Reset sends a reset pulse and returns whether a device answered with
+	// a presence pulse.
+	Reset() (bool, error)
+
+	WriteBit(bit uint8)
+	ReadBit() uint8
+
+	// Write and Read transfer a byte, LSB first.
+	Write(data uint8)
+	Read() uint8
+
+	// WritePower writes a byte and then powers the bus with a strong
+	// pull-up until the next reset or time slot, for parasite powered
+	// devices that need more current than the pull-up resistor provides.
+	WritePower(data uint8)
+
+	// SetOverdrive switches the timing of the master between standard and
+	// overdrive speed.
+	SetOverdrive(overdrive bool)
+}
+
+// tripleter is implemented by masters that can do the two read slots and the
+// write slot of a search step in one operation.
+type tripleter interface {
+	Triplet(direction uint8) (bit, complement, taken uint8)
+}
+
 // Device wraps a connection to an 1-Wire devices.
 type Device struct {
-	p machine.Pin
+	m Master
 }
 
 // Config wraps a configuration to an 1-Wire devices.
@@ -31,26 +69,24 @@ var (
 	errReadAddress = errors.New("Error: OneWire. Read address error: CRC mismatch.")
 )
 
-// New creates a new GPIO 1-Wire connection.
-// The pin must be pulled up to the VCC via a resistor greater than 500 ohms (default 4.7k).
-func New(p machine.Pin) Device {
+// NewMaster creates a 1-Wire connection that uses a bus master, such as a
+// DS2482 bridge.
+func NewMaster(m Master) Device {
 	return Device{
-		p: p,
+		m: m,
 	}
 }
 
 // Configure initializes the protocol.
 func (d *Device) Configure(config Config) {}
 
-// Reset pull DQ line low, then up.
+// Reset sends a reset pulse, and returns an error if no device is present.
 func (d Device) Reset() error {
-	d.p.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	time.Sleep(480 * time.Microsecond)
-	d.p.Configure(machine.PinConfig{Mode: machine.PinInput})
-	time.Sleep(70 * time.Microsecond)
-	precence := d.p.Get()
-	time.Sleep(410 * time.Microsecond)
-	if precence {
+	presence, err := d.m.Reset()
+	if err != nil {
+		return err
+	}
+	if !presence {
 		return errNoPresence
 	}
 	return nil
@@ -58,46 +94,58 @@ func (d Device) Reset() error {
 
 // WriteBit transmits a bit to 1-Wire bus.
 func (d Device) WriteBit(data uint8) {
-	d.p.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	if data&1 == 1 { // Send '1'
-		time.Sleep(5 * time.Microsecond)
-		d.p.Configure(machine.PinConfig{Mode: machine.PinInput})
-		time.Sleep(60 * time.Microsecond)
-	} else { // Send '0'
-		time.Sleep(60 * time.Microsecond)
-		d.p.Configure(machine.PinConfig{Mode: machine.PinInput})
-		time.Sleep(5 * time.Microsecond)
-	}
+	d.m.WriteBit(data & 1)
 }
 
 // Write transmits a byte as bit array to 1-Wire bus. (LSB first)
 func (d Device) Write(data uint8) {
-	for i := 0; i < 8; i++ {
-		d.WriteBit(data)
-		data >>= 1
-	}
+	d.m.Write(data)
+}
+
+// WritePower transmits a byte and then powers the bus with a strong pull-up
+// until the next command, for example during the temperature conversion of
+// a parasite powered DS18B20.
+func (d Device) WritePower(data uint8) {
+	d.m.WritePower(data)
 }
 
 // ReadBit receives a bit from 1-Wire bus.
 func (d Device) ReadBit() (data uint8) {
-	d.p.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	time.Sleep(3 * time.Microsecond)
-	d.p.Configure(machine.PinConfig{Mode: machine.PinInput})
-	time.Sleep(8 * time.Microsecond)
-	if d.p.Get() {
-		data = 1
-	}
-	time.Sleep(60 * time.Microsecond)
-	return data
+	return d.m.ReadBit()
 }
 
 // Read receives a byte from 1-Wire bus. (LSB first)
 func (d Device) Read() (data uint8) {
+	return d.m.Read()
+}
+
+// Overdrive selects the device with romid and switches it and the master to
+// overdrive speed, which is about 8 times faster. With an empty romid, all
+// devices on the bus that support overdrive are switched. Devices that
+// don't support it must be left alone until StandardSpeed is called.
+func (d Device) Overdrive(romid []uint8) error {
+	d.m.SetOverdrive(false)
+	if err := d.Reset(); err != nil {
+		return err
+	}
+	if len(romid) == 0 {
+		d.Write(OVERDRIVE_SKIP_ROM)
+		d.m.SetOverdrive(true)
+		return nil
+	}
+	d.Write(OVERDRIVE_MATCH_ROM)
+	// The ROM ID is sent at overdrive speed.
+	d.m.SetOverdrive(true)
 	for i := 0; i < 8; i++ {
-		data >>= 1
-		data |= d.ReadBit() << 7
+		d.Write(romid[i])
 	}
-	return data
+	return nil
+}
+
+// StandardSpeed switches the master and all devices back to standard speed.
+func (d Device) StandardSpeed() error {
+	d.m.SetOverdrive(false)
+	return d.Reset()
 }
 
 // ReadAddress receives a 64-bit unique ROM ID from Device. (LSB first)
@@ -138,6 +186,7 @@ func (d Device) Select(romid []uint8) error {
 func (d Device) Search(cmd uint8) ([][]uint8, error) {
 	var (
 		bit, bit_c  uint8 = 0, 0
+		dir         uint8 = 0
 		bitOffset   uint8 = 0
 		lastZero    uint8 = 0
 		lastFork    uint8 = 0
@@ -161,31 +210,31 @@ func (d Device) Search(cmd uint8) ([][]uint8, error) {
 		lastZero = 0
 
 		for bitOffset = 0; bitOffset < 64; bitOffset++ {
-			bit = d.ReadBit()   // read first address bit
-			bit_c = d.ReadBit() // read second (complementary) address bit
+			// direction to take on a collision; lastFork counts the
+			// bits from 1, so that 0 means no fork
+			dir = 0
+			if bitOffset+1 == lastFork {
+				dir = 1
+			}
+			if bitOffset+1 < lastFork {
+				dir = (lastAddress[bitOffset>>3] >> (bitOffset & 0x07)) & 1
+			}
+
+			bit, bit_c, dir = d.triplet(dir)
 
 			if bit == 1 && bit_c == 1 { // no device
 				return nil, errNoPresence
 			}
 
-			if bit == 0 && bit_c == 0 { // collision
-				if bitOffset == lastFork {
-					bit = 1
-				}
-				if bitOffset < lastFork {
-					bit = (lastAddress[bitOffset>>3] >> (bitOffset & 0x07)) & 1
-				}
-				if bit == 0 {
-					lastZero = bitOffset
-				}
+			if bit == 0 && bit_c == 0 && dir == 0 { // collision
+				lastZero = bitOffset + 1
 			}
 
-			if bit == 0 {
+			if dir == 0 {
 				lastAddress[bitOffset>>3] &= ^(1 << (bitOffset & 0x07))
 			} else {
 				lastAddress[bitOffset>>3] |= (1 << (bitOffset & 0x07))
 			}
-			d.WriteBit(bit)
 		}
 		lastFork = lastZero
 		copy(romIDs[romIndex], lastAddress)
@@ -194,6 +243,24 @@ func (d Device) Search(cmd uint8) ([][]uint8, error) {
 	return romIDs[:romIndex:romIndex], nil
 }
 
+// triplet reads an address bit and its complement, and writes the bit of
+// the direction to take: the address bit if all devices agree, else dir.
+func (d Device) triplet(dir uint8) (bit, complement, taken uint8) {
+	if t, ok := d.m.(tripleter); ok {
+		return t.Triplet(dir)
+	}
+	bit = d.ReadBit()        // read first address bit
+	complement = d.ReadBit() // read second (complementary) address bit
+	switch {
+	case bit == 1 && complement == 1: // no device
+		return bit, complement, 1
+	case bit != complement:
+		dir = bit
+	}
+	d.WriteBit(dir)
+	return bit, complement, dir
+}
+
 // Crc8 compute a Dallas Semiconductor 8 bit CRC.
 func (d Device) Сrc8(buffer []uint8, size int) (crc uint8) {
 	// Dow-CRC using polynomial X^8 + X^5 + X^4 + X^0
@@ -211,3 +278,21 @@ func (d Device) Сrc8(buffer []uint8, size int) (crc uint8) {
 	}
 	return crc
 }
+
+// Crc16 computes the Dallas Semiconductor 16 bit CRC of the memory functions,
+// starting from crc so that a message can be checked in parts. Devices send
+// the CRC inverted, LSB first.
+func (d Device) Crc16(buffer []uint8, crc uint16) uint16 {
+	// Polynomial X^16 + X^15 + X^2 + X^0
+	for _, b := range buffer {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
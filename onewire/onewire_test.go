@@ -0,0 +1,138 @@
+package onewire
+
+import (
+	"sort"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func roms(devices ...tester.OneWireDevice) [][]uint8 {
+	var ids [][]uint8
+	for _, dev := range devices {
+		rom := dev.ROM()
+		ids = append(ids, rom[:])
+	}
+	return ids
+}
+
+// sorted sorts ROM IDs in the order of a search, by the bits from LSB of the
+// first byte.
+func sorted(ids [][]uint8) [][]uint8 {
+	key := func(id []uint8) uint64 {
+		var k uint64
+		for i := 0; i < 64; i++ {
+			k = k<<1 | uint64(id[i/8]>>(i%8)&1)
+		}
+		return k
+	}
+	sort.Slice(ids, func(i, j int) bool { return key(ids[i]) < key(ids[j]) })
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	c := qt.New(t)
+	devices := []tester.OneWireDevice{
+		tester.NewDS18B20(0x0000000001),
+		tester.NewDS18B20(0x0000000002),
+		tester.NewDS18B20(0x8000000003),
+		tester.NewDS2431(0x123456),
+	}
+	ow := NewMaster(tester.NewOneWireBus(devices...))
+	ids, err := ow.Search(SEARCH_ROM)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.DeepEquals, sorted(roms(devices...)))
+	for _, id := range ids {
+		c.Assert(ow.Сrc8(id, 7), qt.Equals, id[7])
+	}
+
+	// Empty bus.
+	_, err = NewMaster(tester.NewOneWireBus()).Search(SEARCH_ROM)
+	c.Assert(err, qt.Equals, errNoPresence)
+}
+
+func TestReadAddress(t *testing.T) {
+	c := qt.New(t)
+	dev := tester.NewDS2431(0xABCDEF)
+	ow := NewMaster(tester.NewOneWireBus(dev))
+	id, err := ow.ReadAddress()
+	c.Assert(err, qt.IsNil)
+	c.Assert(id, qt.DeepEquals, roms(dev)[0])
+
+	// With two devices the IDs collide and the CRC is wrong.
+	ow = NewMaster(tester.NewOneWireBus(dev, tester.NewDS18B20(2)))
+	_, err = ow.ReadAddress()
+	c.Assert(err, qt.Equals, errReadAddress)
+
+	_, err = NewMaster(tester.NewOneWireBus()).ReadAddress()
+	c.Assert(err, qt.Equals, errNoPresence)
+}
+
+func TestOverdrive(t *testing.T) {
+	c := qt.New(t)
+	eeprom1 := tester.NewDS2431(1)
+	eeprom2 := tester.NewDS2431(2)
+	sensor := tester.NewDS18B20(3)
+	bus := tester.NewOneWireBus(eeprom1, eeprom2, sensor)
+	ow := NewMaster(bus)
+
+	// Only eeprom1 is switched to overdrive.
+	c.Assert(ow.Overdrive(roms(eeprom1)[0]), qt.IsNil)
+	c.Assert(bus.Overdrive, qt.IsTrue)
+	ids, err := ow.Search(SEARCH_ROM)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.DeepEquals, roms(eeprom1))
+
+	// All devices that support overdrive.
+	c.Assert(ow.StandardSpeed(), qt.IsNil)
+	c.Assert(bus.Overdrive, qt.IsFalse)
+	c.Assert(ow.Overdrive(nil), qt.IsNil)
+	ids, err = ow.Search(SEARCH_ROM)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.DeepEquals, sorted(roms(eeprom1, eeprom2)))
+
+	c.Assert(ow.StandardSpeed(), qt.IsNil)
+	ids, err = ow.Search(SEARCH_ROM)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ids, qt.HasLen, 3)
+}
+
+func TestWritePower(t *testing.T) {
+	c := qt.New(t)
+	sensor := tester.NewDS18B20(1)
+	sensor.Parasite = true
+	sensor.Temperature = 21500
+	bus := tester.NewOneWireBus(sensor)
+	ow := NewMaster(bus)
+
+	// Without strong pull-up, a parasite powered sensor can't convert.
+	c.Assert(ow.Select(nil), qt.IsNil)
+	ow.Write(0x44)
+	ow.Reset()
+	sp := sensor.Scratchpad()
+	c.Assert(sp[:2], qt.DeepEquals, []byte{0x50, 0x05})
+
+	c.Assert(ow.Select(nil), qt.IsNil)
+	ow.WritePower(0x44)
+	c.Assert(bus.StrongPullUps, qt.Equals, 1)
+	sp = sensor.Scratchpad()
+	c.Assert(sp[:2], qt.DeepEquals, []byte{0x58, 0x01})
+}
+
+func TestCrc8(t *testing.T) {
+	c := qt.New(t)
+	var d Device
+	// Example from Maxim application note 27.
+	c.Assert(d.Сrc8([]uint8{0x02, 0x1C, 0xB8, 0x01, 0x00, 0x00, 0x00, 0xA2}, 7), qt.Equals, uint8(0xA2))
+}
+
+func TestCrc16(t *testing.T) {
+	c := qt.New(t)
+	var d Device
+	c.Assert(d.Crc16([]uint8("123456789"), 0), qt.Equals, uint16(0xBB3D))
+	// A message followed by its inverted CRC gives 0xB001.
+	msg := []uint8{0x0F, 0x00, 0x00, 1, 2, 3, 4, 5, 6, 7, 8}
+	crc := ^d.Crc16(msg, 0)
+	c.Assert(d.Crc16(append(msg, uint8(crc), uint8(crc>>8)), 0), qt.Equals, uint16(0xB001))
+}
@@ -6,22 +6,23 @@ package pcd8544 // import "tinygo.org/x/drivers/pcd8544"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
 )
 
 // Device wraps an SPI connection.
 type Device struct {
 	bus        drivers.SPI
-	dcPin      machine.Pin
-	rstPin     machine.Pin
-	scePin     machine.Pin
+	dcPin      drivers.PinOutput
+	rstPin     drivers.PinOutput
+	scePin     drivers.PinOutput
 	buffer     []byte
 	width      int16
 	height     int16
 	bufferSize int16
+	dirty      dirty.Rect // columns and banks changed since the last Display
 }
 
 type Config struct {
@@ -30,7 +31,7 @@ type Config struct {
 }
 
 // New creates a new PCD8544 connection. The SPI bus must already be configured.
-func New(bus drivers.SPI, dcPin, rstPin, scePin machine.Pin) *Device {
+func New(bus drivers.SPI, dcPin, rstPin, scePin drivers.PinOutput) *Device {
 	return &Device{
 		bus:    bus,
 		dcPin:  dcPin,
@@ -53,6 +54,7 @@ func (d *Device) Configure(cfg Config) {
 	}
 	d.bufferSize = d.width * d.height / 8
 	d.buffer = make([]byte, d.bufferSize)
+	d.dirty.All(d.width, d.height/8)
 
 	d.rstPin.Low()
 	time.Sleep(100 * time.Nanosecond)
@@ -68,6 +70,7 @@ func (d *Device) Configure(cfg Config) {
 // ClearBuffer clears the image buffer
 func (d *Device) ClearBuffer() {
 	d.buffer = make([]byte, d.bufferSize)
+	d.dirty.All(d.width, d.height/8)
 }
 
 // ClearDisplay clears the image buffer and clear the display
@@ -76,15 +79,21 @@ func (d *Device) ClearDisplay() {
 	d.Display()
 }
 
-// Display sends the whole buffer to the screen
+// Display sends the part of the buffer that changed since the last call to
+// the screen
 func (d *Device) Display() error {
+	if d.dirty.Empty() {
+		return nil
+	}
 	d.SendCommand(FUNCTIONSET) // H = 0
-	d.SendCommand(SETXADDR)
-	d.SendCommand(SETYADDR)
-
-	for i := int16(0); i < d.bufferSize; i++ {
-		d.SendData(d.buffer[i])
+	for bank := d.dirty.Y0; bank <= d.dirty.Y1; bank++ {
+		d.SendCommand(SETXADDR | uint8(d.dirty.X0))
+		d.SendCommand(SETYADDR | uint8(bank))
+		for x := d.dirty.X0; x <= d.dirty.X1; x++ {
+			d.SendData(d.buffer[x+bank*d.width])
+		}
 	}
+	d.dirty.Reset()
 	return nil
 }
 
@@ -108,10 +117,15 @@ func (d *Device) SetPixel(x int16, y int16, c color.RGBA) {
 		return
 	}
 	byteIndex := x + (y/8)*d.width
+	b := d.buffer[byteIndex]
 	if c.R != 0 || c.G != 0 || c.B != 0 {
-		d.buffer[byteIndex] |= 1 << uint8(y%8)
+		b |= 1 << uint8(y%8)
 	} else {
-		d.buffer[byteIndex] &^= 1 << uint8(y%8)
+		b &^= 1 << uint8(y%8)
+	}
+	if b != d.buffer[byteIndex] {
+		d.buffer[byteIndex] = b
+		d.dirty.Add(x, y/8)
 	}
 }
 
@@ -133,6 +147,7 @@ func (d *Device) SetBuffer(buffer []byte) error {
 	for i := int16(0); i < d.bufferSize; i++ {
 		d.buffer[i] = buffer[i]
 	}
+	d.dirty.All(d.width, d.height/8)
 	return nil
 }
 
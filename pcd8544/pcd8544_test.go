@@ -0,0 +1,37 @@
+package pcd8544
+
+import (
+	"image/color"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func TestDisplay(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewSPIBus(c)
+	d := New(bus, bus.DC, &tester.Pin{}, &tester.Pin{})
+	d.Configure(Config{})
+
+	// The first Display sends the whole buffer.
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(bus.Written(true), qt.HasLen, 84*6)
+
+	// Only the changed columns of the changed banks are sent.
+	bus.Reset()
+	d.SetPixel(10, 9, color.RGBA{255, 255, 255, 255})
+	d.SetPixel(12, 20, color.RGBA{255, 255, 255, 255})
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(bus.Written(false), qt.DeepEquals, []byte{
+		FUNCTIONSET,
+		SETXADDR | 10, SETYADDR | 1,
+		SETXADDR | 10, SETYADDR | 2,
+	})
+	c.Assert(bus.Written(true), qt.DeepEquals, []byte{1 << 1, 0, 0, 0, 0, 1 << 4})
+
+	// Nothing changed.
+	bus.Reset()
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(bus.Transactions, qt.HasLen, 0)
+}
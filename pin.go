@@ -0,0 +1,41 @@
+package drivers
+
+// PinOutput is a digital output, such as a chip select or reset line. It is
+// implemented by the machine.Pin type.
+type PinOutput interface {
+	// Set sets the output level of the pin: high if true, low if false.
+	Set(high bool)
+	High()
+	Low()
+}
+
+// PinInput is a digital input, such as a button or an interrupt line. It is
+// implemented by the machine.Pin type.
+type PinInput interface {
+	// Get returns the level of the pin: true if it is high.
+	Get() bool
+}
+
+// Pin is a digital pin that is both read and written, such as the data line
+// of a DHT sensor. It is implemented by the machine.Pin type.
+type Pin interface {
+	PinInput
+	PinOutput
+}
+
+// PinMode is the direction of a pin that is configured by a driver.
+type PinMode uint8
+
+const (
+	PinModeOutput PinMode = iota
+	PinModeInput
+	PinModeInputPullup
+	PinModeInputPulldown
+)
+
+// PinConfigurer is implemented by pins other than machine.Pin whose
+// direction is set by the drivers, such as the pins of an I/O expander. A
+// driver configures a machine.Pin itself, and leaves other pins as they are.
+type PinConfigurer interface {
+	ConfigureMode(mode PinMode)
+}
@@ -0,0 +1,228 @@
+// Package pixel provides images in the pixel formats that displays use in
+// their memory, so that an image can be sent to a display in one transfer
+// instead of pixel by pixel.
+//
+// The pixels are packed in rows, from the top left corner, without padding at
+// the end of the rows: in the order that a display writes them to its memory
+// window.
+package pixel // import "tinygo.org/x/drivers/pixel"
+
+import (
+	"errors"
+	"image/color"
+)
+
+// Format is the layout of a pixel in memory.
+type Format uint8
+
+const (
+	// RGB565 is 16 bits per pixel, big endian: 5 bits of red, 6 of green
+	// and 5 of blue. It is the default format of most TFT displays.
+	RGB565 Format = iota + 1
+
+	// RGB444 is 12 bits per pixel: 4 bits each of red, green and blue. Two
+	// pixels are packed in three bytes.
+	RGB444
+
+	// Mono is 1 bit per pixel, with the first pixel in the most
+	// significant bit of a byte.
+	Mono
+
+	// Gray4 is 4 bits of gray per pixel, with the first pixel in the high
+	// nibble of a byte.
+	Gray4
+)
+
+// ErrFormat is returned by displays for an image in another pixel format than
+// their own.
+var ErrFormat = errors.New("pixel: unsupported pixel format")
+
+// Bits returns the number of bits of a pixel.
+func (f Format) Bits() int {
+	switch f {
+	case RGB565:
+		return 16
+	case RGB444:
+		return 12
+	case Mono:
+		return 1
+	case Gray4:
+		return 4
+	}
+	return 0
+}
+
+// Len returns the number of bytes of an image of width by height pixels.
+func (f Format) Len(width, height int16) int {
+	return (int(width)*int(height)*f.Bits() + 7) / 8
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case RGB565:
+		return "RGB565"
+	case RGB444:
+		return "RGB444"
+	case Mono:
+		return "Mono"
+	case Gray4:
+		return "Gray4"
+	}
+	return "unknown"
+}
+
+// Image is a rectangle of pixels in a Format.
+type Image struct {
+	format Format
+	width  int16
+	height int16
+	data   []byte
+}
+
+// NewImage allocates an image of width by height pixels, with all bits
+// cleared.
+func NewImage(format Format, width, height int16) Image {
+	return Image{
+		format: format,
+		width:  width,
+		height: height,
+		data:   make([]byte, format.Len(width, height)),
+	}
+}
+
+// NewImageFromBytes returns an image that uses data as its pixels, such as a
+// bitmap that is stored in flash. It panics if data is too short.
+func NewImageFromBytes(format Format, width, height int16, data []byte) Image {
+	if len(data) < format.Len(width, height) {
+		panic("pixel: image data too short")
+	}
+	return Image{
+		format: format,
+		width:  width,
+		height: height,
+		data:   data[:format.Len(width, height)],
+	}
+}
+
+// Format returns the pixel format of the image.
+func (img Image) Format() Format {
+	return img.format
+}
+
+// Size returns the width and height of the image.
+func (img Image) Size() (int16, int16) {
+	return img.width, img.height
+}
+
+// Bytes returns the pixels, which can be sent to a display that uses the
+// format of the image.
+func (img Image) Bytes() []byte {
+	return img.data
+}
+
+// Set sets a pixel to the color that is closest in the format of the image.
+// Pixels outside the image are ignored.
+func (img Image) Set(x, y int16, c color.RGBA) {
+	if x < 0 || y < 0 || x >= img.width || y >= img.height {
+		return
+	}
+	i := int(y)*int(img.width) + int(x)
+	switch img.format {
+	case RGB565:
+		v := uint16(c.R)>>3<<11 | uint16(c.G)>>2<<5 | uint16(c.B)>>3
+		img.data[i*2] = byte(v >> 8)
+		img.data[i*2+1] = byte(v)
+	case RGB444:
+		r, g, b := c.R>>4, c.G>>4, c.B>>4
+		k := i * 3 / 2
+		if i%2 == 0 {
+			img.data[k] = r<<4 | g
+			img.data[k+1] = b<<4 | img.data[k+1]&0x0F
+		} else {
+			img.data[k] = img.data[k]&0xF0 | r
+			img.data[k+1] = g<<4 | b
+		}
+	case Mono:
+		mask := byte(0x80) >> (i % 8)
+		if gray(c) >= 0x80 {
+			img.data[i/8] |= mask
+		} else {
+			img.data[i/8] &^= mask
+		}
+	case Gray4:
+		v := gray(c) >> 4
+		if i%2 == 0 {
+			img.data[i/2] = v<<4 | img.data[i/2]&0x0F
+		} else {
+			img.data[i/2] = img.data[i/2]&0xF0 | v
+		}
+	}
+}
+
+// Get returns the color of a pixel, or transparent black outside the image.
+func (img Image) Get(x, y int16) color.RGBA {
+	if x < 0 || y < 0 || x >= img.width || y >= img.height {
+		return color.RGBA{}
+	}
+	i := int(y)*int(img.width) + int(x)
+	switch img.format {
+	case RGB565:
+		v := uint16(img.data[i*2])<<8 | uint16(img.data[i*2+1])
+		r, g, b := byte(v>>11), byte(v>>5&0x3F), byte(v&0x1F)
+		return color.RGBA{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 0xFF}
+	case RGB444:
+		var r, g, b byte
+		k := i * 3 / 2
+		if i%2 == 0 {
+			r, g, b = img.data[k]>>4, img.data[k]&0x0F, img.data[k+1]>>4
+		} else {
+			r, g, b = img.data[k]&0x0F, img.data[k+1]>>4, img.data[k+1]&0x0F
+		}
+		return color.RGBA{r * 0x11, g * 0x11, b * 0x11, 0xFF}
+	case Mono:
+		if img.data[i/8]&(0x80>>(i%8)) != 0 {
+			return color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+		}
+		return color.RGBA{0, 0, 0, 0xFF}
+	case Gray4:
+		v := img.data[i/2] >> 4
+		if i%2 != 0 {
+			v = img.data[i/2] & 0x0F
+		}
+		return color.RGBA{v * 0x11, v * 0x11, v * 0x11, 0xFF}
+	}
+	return color.RGBA{}
+}
+
+// Fill sets all pixels to a color.
+func (img Image) Fill(c color.RGBA) {
+	// Set the first pixels and copy them, as the pattern of the bytes
+	// repeats after at most 8 pixels.
+	n := 8
+	if int(img.width)*int(img.height) < n {
+		n = int(img.width) * int(img.height)
+	}
+	for i := 0; i < n; i++ {
+		img.Set(int16(i%int(img.width)), int16(i/int(img.width)), c)
+	}
+	pattern := img.format.Bits() // bytes of 8 pixels
+	for i := pattern; i < len(img.data); i += copy(img.data[i:], img.data[:i]) {
+	}
+}
+
+// Convert returns a copy of the image in another format.
+func (img Image) Convert(format Format) Image {
+	dst := NewImage(format, img.width, img.height)
+	for y := int16(0); y < img.height; y++ {
+		for x := int16(0); x < img.width; x++ {
+			dst.Set(x, y, img.Get(x, y))
+		}
+	}
+	return dst
+}
+
+// gray returns the luma of a color.
+func gray(c color.RGBA) byte {
+	return byte((299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B)) / 1000)
+}
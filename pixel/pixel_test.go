@@ -0,0 +1,106 @@
+package pixel
+
+import (
+	"image/color"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+var (
+	black  = color.RGBA{0, 0, 0, 0xFF}
+	white  = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	orange = color.RGBA{0xFF, 0x88, 0x00, 0xFF}
+)
+
+func TestLen(t *testing.T) {
+	c := qt.New(t)
+	c.Assert(RGB565.Len(3, 2), qt.Equals, 12)
+	c.Assert(RGB444.Len(3, 1), qt.Equals, 5)
+	c.Assert(Mono.Len(9, 1), qt.Equals, 2)
+	c.Assert(Gray4.Len(3, 3), qt.Equals, 5)
+}
+
+func TestRGB565(t *testing.T) {
+	c := qt.New(t)
+	img := NewImage(RGB565, 2, 2)
+	img.Set(1, 0, orange)
+	img.Set(0, 1, white)
+	img.Set(5, 5, white) // ignored
+	c.Assert(img.Bytes(), qt.DeepEquals, []byte{0, 0, 0xFC, 0x40, 0xFF, 0xFF, 0, 0})
+	c.Assert(img.Get(1, 0), qt.Equals, color.RGBA{0xFF, 0x8A, 0x00, 0xFF})
+	c.Assert(img.Get(0, 1), qt.Equals, white)
+	c.Assert(img.Get(2, 0), qt.Equals, color.RGBA{})
+}
+
+func TestRGB444(t *testing.T) {
+	c := qt.New(t)
+	img := NewImage(RGB444, 3, 1)
+	img.Set(0, 0, orange)
+	img.Set(1, 0, white)
+	img.Set(2, 0, color.RGBA{0x12, 0x34, 0x56, 0xFF})
+	c.Assert(img.Bytes(), qt.DeepEquals, []byte{0xF8, 0x0F, 0xFF, 0x13, 0x50})
+	c.Assert(img.Get(0, 0), qt.Equals, color.RGBA{0xFF, 0x88, 0x00, 0xFF})
+	c.Assert(img.Get(1, 0), qt.Equals, white)
+	c.Assert(img.Get(2, 0), qt.Equals, color.RGBA{0x11, 0x33, 0x55, 0xFF})
+}
+
+func TestMono(t *testing.T) {
+	c := qt.New(t)
+	img := NewImage(Mono, 5, 2)
+	img.Set(0, 0, white)
+	img.Set(3, 1, orange)
+	img.Set(4, 1, color.RGBA{0, 0, 0x80, 0xFF}) // dark
+	c.Assert(img.Bytes(), qt.DeepEquals, []byte{0x80, 0x80})
+	c.Assert(img.Get(3, 1), qt.Equals, white)
+	c.Assert(img.Get(4, 1), qt.Equals, black)
+	img.Set(0, 0, black)
+	c.Assert(img.Bytes()[0], qt.Equals, byte(0))
+}
+
+func TestGray4(t *testing.T) {
+	c := qt.New(t)
+	img := NewImage(Gray4, 3, 1)
+	img.Set(0, 0, white)
+	img.Set(1, 0, orange)
+	c.Assert(img.Bytes(), qt.DeepEquals, []byte{0xF9, 0x00})
+	c.Assert(img.Get(1, 0), qt.Equals, color.RGBA{0x99, 0x99, 0x99, 0xFF})
+}
+
+func TestFill(t *testing.T) {
+	c := qt.New(t)
+	for _, format := range []Format{RGB565, RGB444, Mono, Gray4} {
+		img := NewImage(format, 7, 5)
+		img.Fill(orange)
+		want := NewImage(format, 1, 1)
+		want.Set(0, 0, orange)
+		for y := int16(0); y < 5; y++ {
+			for x := int16(0); x < 7; x++ {
+				c.Assert(img.Get(x, y), qt.Equals, want.Get(0, 0), qt.Commentf("%v %d,%d", format, x, y))
+			}
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c := qt.New(t)
+	img := NewImage(RGB565, 2, 1)
+	img.Set(0, 0, white)
+	img.Set(1, 0, orange)
+	mono := img.Convert(Mono)
+	c.Assert(mono.Format(), qt.Equals, Mono)
+	c.Assert(mono.Bytes(), qt.DeepEquals, []byte{0xC0})
+	rgb444 := img.Convert(RGB444)
+	c.Assert(rgb444.Bytes(), qt.DeepEquals, []byte{0xFF, 0xFF, 0x80})
+}
+
+func TestNewImageFromBytes(t *testing.T) {
+	c := qt.New(t)
+	img := NewImageFromBytes(Gray4, 2, 2, []byte{0x0F, 0xF0, 0xAA})
+	w, h := img.Size()
+	c.Assert(w, qt.Equals, int16(2))
+	c.Assert(h, qt.Equals, int16(2))
+	c.Assert(img.Bytes(), qt.HasLen, 2)
+	c.Assert(img.Get(1, 0), qt.Equals, white)
+	c.Assert(func() { NewImageFromBytes(RGB565, 2, 2, nil) }, qt.PanicMatches, "pixel: image data too short")
+}
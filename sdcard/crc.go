@@ -0,0 +1,31 @@
+package sdcard
+
+// crc7 returns the CRC7 of a command or register, as used by the SD protocol
+// (polynomial x^7 + x^3 + 1). The result is in the low 7 bits.
+func crc7(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if (b^crc)&0x80 != 0 {
+				crc ^= 0x09
+			}
+			b <<= 1
+		}
+	}
+	return crc & 0x7f
+}
+
+// crc16 returns the CRC16-CCITT of a data block (polynomial x^16 + x^12 +
+// x^5 + 1, initial value 0).
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc>>8 | crc<<8
+		crc ^= uint16(b)
+		crc ^= (crc & 0xff) >> 4
+		crc ^= crc << 12
+		crc ^= (crc & 0xff) << 5
+	}
+	return crc
+}
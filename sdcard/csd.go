@@ -15,7 +15,8 @@ type CSD struct {
 	WRITE_BLK_MISALIGN byte   //  1 R  [78:78]     0x00 : Write Block Misalignment
 	READ_BLK_MISALIGN  byte   //  1 R  [77:77]     0x00 : Read Block Misalignment
 	DSR_IMP            byte   //  1 R  [76:76]     0x00 : DSR Implemented
-	C_SIZE             uint32 // 22 R  [69:48] 0xXXXXXX : Device Size
+	C_SIZE             uint32 // 22 R  [69:48] 0xXXXXXX : Device Size (12 bits [73:62] in version 1.0)
+	C_SIZE_MULT        byte   //  3 R  [49:47]     0xXX : Device Size Multiplier (version 1.0 only)
 	ERASE_BLK_EN       byte   //  1 R  [46:46]     0x01 : Erase Single Block Enable
 	SECTOR_SIZE        byte   //  7 R  [45:39]     0x7F : Erase Sector Size
 	WP_GRP_SIZE        byte   //  7 R  [38:32]     0x00 : Write Protect Group Size
@@ -32,7 +33,7 @@ type CSD struct {
 }
 
 func NewCSD(buf []byte) *CSD {
-	return &CSD{
+	c := &CSD{
 		CSD_STRUCTURE:      (buf[0] & 0xC0) >> 6,
 		TAAC:               buf[1],
 		NSAC:               buf[2],
@@ -43,7 +44,6 @@ func NewCSD(buf []byte) *CSD {
 		WRITE_BLK_MISALIGN: (buf[6] & 0x40) >> 6,
 		READ_BLK_MISALIGN:  (buf[6] & 0x20) >> 5,
 		DSR_IMP:            (buf[6] & 0x10) >> 4,
-		C_SIZE:             uint32(buf[7]&0x3F)<<16 | uint32(buf[8])<<8 | uint32(buf[9]),
 		ERASE_BLK_EN:       (buf[10] & 0x40) >> 6,
 		SECTOR_SIZE:        (buf[10]&0x3F)<<1 | (buf[11]&0x80)>>7,
 		WP_GRP_SIZE:        buf[11] & 0x7F,
@@ -58,6 +58,22 @@ func NewCSD(buf []byte) *CSD {
 		FILE_FORMAT:        (buf[14] & 0x0C) >> 2,
 		CRC:                (buf[15] & 0xFE) >> 1,
 	}
+	if c.CSD_STRUCTURE == 0x01 {
+		c.C_SIZE = uint32(buf[7]&0x3F)<<16 | uint32(buf[8])<<8 | uint32(buf[9])
+	} else {
+		c.C_SIZE = uint32(buf[6]&0x03)<<10 | uint32(buf[7])<<2 | uint32(buf[8])>>6
+		c.C_SIZE_MULT = (buf[9]&0x03)<<1 | (buf[10]&0x80)>>7
+	}
+	return c
+}
+
+// sectorsV1 returns the number of 512-byte sectors from a version 1.0 CSD,
+// which is also the layout used by MMC cards.
+func sectorsV1(buf []byte) int64 {
+	cSize := int64(buf[6]&0x03)<<10 | int64(buf[7])<<2 | int64(buf[8])>>6
+	mult := uint((buf[9]&0x03)<<1 | (buf[10]&0x80)>>7)
+	blLen := uint(buf[5] & 0x0F)
+	return (cSize + 1) << (mult + 2) << blLen / 512
 }
 
 func (c *CSD) Dump() {
@@ -72,6 +88,7 @@ func (c *CSD) Dump() {
 	fmt.Printf("READ_BLK_MISALIGN:  %X\r\n", c.READ_BLK_MISALIGN)
 	fmt.Printf("DSR_IMP:            %X\r\n", c.DSR_IMP)
 	fmt.Printf("C_SIZE:             %X\r\n", c.C_SIZE)
+	fmt.Printf("C_SIZE_MULT:        %X\r\n", c.C_SIZE_MULT)
 	fmt.Printf("ERASE_BLK_EN:       %X\r\n", c.ERASE_BLK_EN)
 	fmt.Printf("SECTOR_SIZE:        %X\r\n", c.SECTOR_SIZE)
 	fmt.Printf("WP_GRP_SIZE:        %X\r\n", c.WP_GRP_SIZE)
@@ -94,13 +111,16 @@ func (c *CSD) Sectors() (int64, error) {
 		sectors = (int64(c.C_SIZE) + 1) * 1024
 	} else if c.CSD_STRUCTURE == 0x00 {
 		// CSD version 1.0 (old, <=2GB)
-		return 0, fmt.Errorf("CSD format version 1.0 is not supported")
+		sectors = (int64(c.C_SIZE) + 1) << (c.C_SIZE_MULT + 2) << c.READ_BL_LEN / 512
 	} else {
 		return 0, fmt.Errorf("unknown CSD format")
 	}
 	return sectors, nil
 }
 
+// Size returns the capacity of the card in bytes, or 0 if the CSD format is
+// not known.
 func (c *CSD) Size() uint64 {
-	return uint64(c.C_SIZE) * 512 * 1024
+	sectors, _ := c.Sectors()
+	return uint64(sectors) * 512
 }
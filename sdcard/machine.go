@@ -0,0 +1,31 @@
+//go:build tinygo
+
+package sdcard
+
+import (
+	"machine"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+)
+
+// NewSPI returns a Device for a card connected to a hardware SPI peripheral.
+// Configure sets up the SPI peripheral and changes its clock frequency as
+// needed, unless Config.SetFrequency is set.
+func NewSPI(b *machine.SPI, sck, sdo, sdi, cs machine.Pin) Device {
+	legacy.ConfigurePin(cs, drivers.PinModeOutput)
+	cs.High()
+	d := New(b, cs)
+	d.setFrequency = func(hz uint32) error {
+		b.Configure(machine.SPIConfig{
+			SCK:       sck,
+			SDO:       sdo,
+			SDI:       sdi,
+			Frequency: hz,
+			LSBFirst:  false,
+			Mode:      0, // phase=0, polarity=0
+		})
+		return nil
+	}
+	return d
+}
@@ -7,9 +7,12 @@
 package sdcard
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"machine"
 	"time"
+
+	"tinygo.org/x/drivers"
 )
 
 const (
@@ -23,61 +26,119 @@ const (
 	_R1_ADDRESS_ERROR        = 1 << 5
 	_R1_PARAMETER_ERROR      = 1 << 6
 
+	// data tokens
+	_TOKEN_START_BLOCK     = 0xFE
+	_TOKEN_START_MULTI     = 0xFC
+	_TOKEN_STOP_TRAN       = 0xFD
+	_DATA_RES_MASK         = 0x1F
+	_DATA_RES_ACCEPTED     = 0x05
+	_DATA_RES_CRC_ERROR    = 0x0B
+	_CCC_SWITCH            = 1 << 10 // command class 10: CMD6
+	_OCR_CCS               = 0x40    // first OCR byte: card uses block addresses
+	_EXT_CSD_SEC_COUNT     = 212
+	_SDHC_MAX_SECTORS      = 32 << 30 / 512
+	_DEFAULT_FREQUENCY     = 4000000
+	_MAX_FREQUENCY         = 25000000
+	_MAX_FREQUENCY_HS      = 50000000
+	_INIT_FREQUENCY        = 250000
+	_HIGH_SPEED_CHECK_ARG  = 0x00FFFFF1 // CMD6: check function 1 of group 1
+	_HIGH_SPEED_SWITCH_ARG = 0x80FFFFF1 // CMD6: switch to function 1 of group 1
+
 	// card types
 	SD_CARD_TYPE_SD1  = 1 // Standard capacity V1 SD card
 	SD_CARD_TYPE_SD2  = 2 // Standard capacity V2 SD card
 	SD_CARD_TYPE_SDHC = 3 // High Capacity SD card
+	SD_CARD_TYPE_SDXC = 4 // Extended Capacity SD card (more than 32GB)
+	SD_CARD_TYPE_MMC  = 5 // MultiMediaCard
+)
+
+var (
+	ErrNoCard  = errors.New("sdcard: no card")
+	ErrTimeout = errors.New("sdcard: timeout")
+	ErrCRC     = errors.New("sdcard: CRC error")
 )
 
 var (
 	dummy [512]byte
 )
 
+// Config holds the settings used by Configure.
+type Config struct {
+	// SetFrequency changes the SPI clock frequency. The card is initialized
+	// at 250kHz and then switched to Frequency. When it is nil, the clock is
+	// left unchanged, which only works if the bus runs at 400kHz or less.
+	SetFrequency func(hz uint32) error
+
+	// Frequency is the SPI clock frequency once the card is initialized. It
+	// defaults to 4MHz. Frequencies above 25MHz switch the card to high
+	// speed mode (up to 50MHz), if the card supports it.
+	Frequency uint32
+
+	// DisableCRC turns off checking the CRC of commands and data, which
+	// costs a little time for each block.
+	DisableCRC bool
+}
+
 type Device struct {
-	bus        *machine.SPI
-	sck        machine.Pin
-	sdo        machine.Pin
-	sdi        machine.Pin
-	cs         machine.Pin
-	cmdbuf     []byte
-	dummybuf   []byte
-	tokenbuf   []byte
-	sdCardType byte
-	CID        *CID
-	CSD        *CSD
+	bus          drivers.SPI
+	cs           drivers.PinOutput
+	setFrequency func(hz uint32) error
+	cmdbuf       []byte
+	dummybuf     []byte
+	sdCardType   byte
+	blockAddr    bool // the card uses block instead of byte addresses
+	crc          bool
+	sectors      int64
+	CID          *CID
+	CSD          *CSD
 }
 
-func New(b *machine.SPI, sck, sdo, sdi, cs machine.Pin) Device {
+// New returns a Device for a card connected to the given SPI bus. The cs pin
+// must already be configured as an output. See NewSPI for cards connected to a
+// machine.SPI.
+func New(b drivers.SPI, cs drivers.PinOutput) Device {
 	return Device{
 		bus:        b,
 		cs:         cs,
-		sck:        sck,
-		sdo:        sdo,
-		sdi:        sdi,
 		cmdbuf:     make([]byte, 6),
 		dummybuf:   make([]byte, 512),
-		tokenbuf:   make([]byte, 1),
 		sdCardType: 0,
 	}
 }
 
-func (d *Device) Configure() error {
-	return d.initCard()
+// Configure initializes the card.
+func (d *Device) Configure(cfg Config) error {
+	if cfg.SetFrequency != nil {
+		d.setFrequency = cfg.SetFrequency
+	}
+	if cfg.Frequency == 0 {
+		cfg.Frequency = _DEFAULT_FREQUENCY
+	}
+	err := d.initCard(cfg)
+	d.cs.Set(true)
+	return err
 }
 
-func (d *Device) initCard() error {
-	d.bus.Configure(machine.SPIConfig{
-		SCK:       d.sck,
-		SDO:       d.sdo,
-		SDI:       d.sdi,
-		Frequency: 250000,
-		LSBFirst:  false,
-		Mode:      0, // phase=0, polarity=0
-	})
+// CardType returns the type of the card, one of the SD_CARD_TYPE constants.
+func (d *Device) CardType() byte {
+	return d.sdCardType
+}
 
-	// set pin modes
-	d.cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.cs.High()
+func (d *Device) setClock(hz uint32) error {
+	if d.setFrequency == nil {
+		return nil
+	}
+	return d.setFrequency(hz)
+}
+
+func (d *Device) initCard(cfg Config) error {
+	if err := d.setClock(_INIT_FREQUENCY); err != nil {
+		return err
+	}
+	d.cs.Set(true)
+	d.sdCardType = 0
+	d.blockAddr = false
+	d.crc = false
 
 	for i := range dummy {
 		dummy[i] = 0xFF
@@ -86,7 +147,7 @@ func (d *Device) initCard() error {
 	// clock card at least 100 cycles with cs high
 	d.bus.Tx(dummy[:10], nil)
 
-	d.cs.Low()
+	d.cs.Set(false)
 	d.bus.Tx(dummy[:], nil)
 
 	// CMD0: init card; sould return _R1_IDLE_STATE (allow 5 attempts)
@@ -94,87 +155,91 @@ func (d *Device) initCard() error {
 	tm := setTimeout(0, 2*time.Second)
 	for !tm.expired() {
 		// Wait up to 2 seconds to be the same as the Arduino
-		if d.cmd(CMD0_GO_IDLE_STATE, 0, 0x95) == _R1_IDLE_STATE {
+		if d.cmd(CMD0_GO_IDLE_STATE, 0) == _R1_IDLE_STATE {
 			ok = true
 			break
 		}
 	}
 	if !ok {
-		return fmt.Errorf("no SD card")
+		return ErrNoCard
 	}
 
 	// CMD8: determine card version
-	r := d.cmd(CMD8_SEND_IF_COND, 0x01AA, 0x87)
+	r := d.cmd(CMD8_SEND_IF_COND, 0x01AA)
 	if (r & _R1_ILLEGAL_COMMAND) == _R1_ILLEGAL_COMMAND {
 		d.sdCardType = SD_CARD_TYPE_SD1
-		return fmt.Errorf("init_card_v1 not impl\r\n")
 	} else {
 		// r7 response
-		status := byte(0)
-		for i := 0; i < 3; i++ {
-			var err error
-			status, err = d.bus.Transfer(byte(0xFF))
-			if err != nil {
-				return err
-			}
-		}
-		if (status & 0x0F) != 0x01 {
-			return fmt.Errorf("SD_CARD_ERROR_CMD8 %02X", status)
+		var r7 [4]byte
+		if err := d.bus.Tx(dummy[:4], r7[:]); err != nil {
+			return err
 		}
-
-		for i := 3; i < 4; i++ {
-			var err error
-			status, err = d.bus.Transfer(byte(0xFF))
-			if err != nil {
-				return err
-			}
+		if (r7[2] & 0x0F) != 0x01 {
+			return fmt.Errorf("SD_CARD_ERROR_CMD8 %02X", r7[2])
 		}
-		if status != 0xAA {
-			return fmt.Errorf("SD_CARD_ERROR_CMD8 %02X", status)
+		if r7[3] != 0xAA {
+			return fmt.Errorf("SD_CARD_ERROR_CMD8 %02X", r7[3])
 		}
 		d.sdCardType = SD_CARD_TYPE_SD2
 	}
 
+	// CMD59: enable CRC checking, so that the card rejects corrupted
+	// commands and data and the CRC of the data it sends can be trusted.
+	if !cfg.DisableCRC {
+		if d.cmd(CMD59_CRC_ON_OFF, 1)&^_R1_IDLE_STATE != 0 {
+			return fmt.Errorf("SD_CARD_ERROR_CMD59")
+		}
+		d.crc = true
+	}
+
 	// initialize card and send host supports SDHC if SD2
 	arg := uint32(0)
 	if d.sdCardType == SD_CARD_TYPE_SD2 {
 		arg = 0x40000000
 	}
+	r = d.acmd(ACMD41_SD_APP_OP_COND, arg)
+	if r&_R1_ILLEGAL_COMMAND != 0 && d.sdCardType == SD_CARD_TYPE_SD1 {
+		// A MultiMediaCard doesn't know ACMD41 and is initialized using
+		// CMD1 instead. The argument tells it the host supports sector
+		// addressing.
+		d.sdCardType = SD_CARD_TYPE_MMC
+		arg = 0x40000000
+	}
 
 	// check for timeout
-	ok = false
 	tm = setTimeout(0, 2*time.Second)
-	for !tm.expired() {
-		if d.acmd(ACMD41_SD_APP_OP_COND, arg) == 0 {
-			ok = true
-			break
+	for r != 0 {
+		if tm.expired() {
+			if d.sdCardType == SD_CARD_TYPE_MMC {
+				return fmt.Errorf("SD_CARD_ERROR_CMD1")
+			}
+			return fmt.Errorf("SD_CARD_ERROR_ACMD41")
+		}
+		if d.sdCardType == SD_CARD_TYPE_MMC {
+			r = d.cmd(CMD1_SEND_OP_CND, arg)
+		} else {
+			r = d.acmd(ACMD41_SD_APP_OP_COND, arg)
 		}
 	}
 
-	if !ok {
-		return fmt.Errorf("SD_CARD_ERROR_ACMD41")
-	}
-
-	// if SD2 read OCR register to check for SDHC card
-	if d.sdCardType == SD_CARD_TYPE_SD2 {
-		if d.cmd(CMD58_READ_OCR, 0, 0xFF) != 0 {
+	// if SD2 or MMC read OCR register to check for block addressing
+	if d.sdCardType != SD_CARD_TYPE_SD1 {
+		if d.cmd(CMD58_READ_OCR, 0) != 0 {
 			return fmt.Errorf("SD_CARD_ERROR_CMD58")
 		}
-
-		status, err := d.bus.Transfer(byte(0xFF))
-		if err != nil {
+		var ocr [4]byte
+		if err := d.bus.Tx(dummy[:4], ocr[:]); err != nil {
 			return err
 		}
-		if (status & 0xC0) == 0xC0 {
-			d.sdCardType = SD_CARD_TYPE_SDHC
-		}
-		// discard rest of ocr - contains allowed voltage range
-		for i := 1; i < 4; i++ {
-			d.bus.Transfer(byte(0xFF))
+		if ocr[0]&_OCR_CCS != 0 {
+			d.blockAddr = true
+			if d.sdCardType == SD_CARD_TYPE_SD2 {
+				d.sdCardType = SD_CARD_TYPE_SDHC
+			}
 		}
 	}
 
-	if d.cmd(CMD16_SET_BLOCKLEN, 0x0200, 0xFF) != 0 {
+	if d.cmd(CMD16_SET_BLOCKLEN, 0x0200) != 0 {
 		return fmt.Errorf("SD_CARD_ERROR_CMD16")
 	}
 
@@ -193,29 +258,77 @@ func (d *Device) initCard() error {
 	}
 	d.CSD = NewCSD(buf[:])
 
-	d.cs.High()
+	switch {
+	case d.sdCardType == SD_CARD_TYPE_MMC && d.blockAddr:
+		// The capacity of a high capacity MMC is in the extended CSD.
+		if err := d.readBlock(CMD8_SEND_IF_COND, 0, d.dummybuf); err != nil {
+			return err
+		}
+		d.sectors = int64(binary.LittleEndian.Uint32(d.dummybuf[_EXT_CSD_SEC_COUNT:]))
+	case d.sdCardType == SD_CARD_TYPE_MMC:
+		d.sectors = sectorsV1(buf[:])
+	default:
+		d.sectors, err = d.CSD.Sectors()
+		if err != nil {
+			return err
+		}
+	}
+	if d.sdCardType == SD_CARD_TYPE_SDHC && d.sectors > _SDHC_MAX_SECTORS {
+		d.sdCardType = SD_CARD_TYPE_SDXC
+	}
 
-	d.bus.Configure(machine.SPIConfig{
-		SCK:       d.sck,
-		SDO:       d.sdo,
-		SDI:       d.sdi,
-		Frequency: 4000000,
-		LSBFirst:  false,
-		Mode:      0, // phase=0, polarity=0
-	})
+	freq := cfg.Frequency
+	if freq > _MAX_FREQUENCY {
+		hs, err := d.switchHighSpeed()
+		if err != nil {
+			return err
+		}
+		if !hs {
+			freq = _MAX_FREQUENCY
+		} else if freq > _MAX_FREQUENCY_HS {
+			freq = _MAX_FREQUENCY_HS
+		}
+	}
 
-	return nil
+	d.cs.Set(true)
+	return d.setClock(freq)
+}
+
+// switchHighSpeed switches the card to high speed mode using CMD6, which
+// allows clock frequencies up to 50MHz. It returns false if the card doesn't
+// support high speed mode.
+func (d *Device) switchHighSpeed() (bool, error) {
+	switch d.sdCardType {
+	case SD_CARD_TYPE_SD1, SD_CARD_TYPE_MMC:
+		return false, nil
+	}
+	if d.CSD.CCC&_CCC_SWITCH == 0 {
+		return false, nil
+	}
+	// Check that function 1 (high speed) of function group 1 is supported
+	// and then switch to it. Byte 13 has the supported functions of group 1
+	// and byte 16 the function that is (or would be) selected.
+	status := d.dummybuf[:64]
+	for _, arg := range []uint32{_HIGH_SPEED_CHECK_ARG, _HIGH_SPEED_SWITCH_ARG} {
+		if err := d.readBlock(CMD6_SWITCH_FUNC, arg, status); err != nil {
+			return false, err
+		}
+		if status[13]&0x02 == 0 || status[16]&0x0F != 1 {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 func (d Device) acmd(cmd byte, arg uint32) byte {
-	d.cmd(CMD55_APP_CMD, 0, 0xFF)
-	return d.cmd(cmd, arg, 0xFF)
+	d.cmd(CMD55_APP_CMD, 0)
+	return d.cmd(cmd, arg)
 }
 
-func (d Device) cmd(cmd byte, arg uint32, crc byte) byte {
-	d.cs.Low()
+func (d Device) cmd(cmd byte, arg uint32) byte {
+	d.cs.Set(false)
 
-	if cmd != 12 {
+	if cmd != CMD12_STOP_TRANSMISSION {
 		d.waitNotBusy(300 * time.Millisecond)
 	}
 
@@ -226,26 +339,27 @@ func (d Device) cmd(cmd byte, arg uint32, crc byte) byte {
 	buf[2] = byte(arg >> 16)
 	buf[3] = byte(arg >> 8)
 	buf[4] = byte(arg)
-	buf[5] = crc
+	buf[5] = crc7(buf[:5])<<1 | 1
 	d.bus.Tx(buf, nil)
 
-	if cmd == 12 {
+	if cmd == CMD12_STOP_TRANSMISSION {
 		// skip 1 byte
 		d.bus.Transfer(byte(0xFF))
 	}
 
 	// wait for the response (response[7] == 0)
 	for i := 0; i < 0xFFFF; i++ {
-		d.bus.Tx([]byte{0xFF}, d.tokenbuf)
-		response := d.tokenbuf[0]
+		response, err := d.bus.Transfer(byte(0xFF))
+		if err != nil {
+			break
+		}
 		if (response & 0x80) == 0 {
 			return response
 		}
 	}
 
-	// TODO
-	//// timeout
-	d.cs.High()
+	// timeout
+	d.cs.Set(true)
 	d.bus.Transfer(byte(0xFF))
 
 	return 0xFF // -1
@@ -262,7 +376,7 @@ func (d Device) waitNotBusy(timeout time.Duration) error {
 			return nil
 		}
 	}
-	return nil
+	return ErrTimeout
 }
 
 func (d Device) waitStartBlock() error {
@@ -273,7 +387,7 @@ func (d Device) waitStartBlock() error {
 		var err error
 		status, err = d.bus.Transfer(byte(0xFF))
 		if err != nil {
-			d.cs.High()
+			d.cs.Set(true)
 			return err
 		}
 		if status != 0xFF {
@@ -281,43 +395,60 @@ func (d Device) waitStartBlock() error {
 		}
 	}
 
-	if status != 254 {
-		d.cs.High()
+	if status != _TOKEN_START_BLOCK {
+		d.cs.Set(true)
 		return fmt.Errorf("SD_CARD_START_BLOCK")
 	}
 
 	return nil
 }
 
+// address returns the command argument for the given block.
+func (d Device) address(block uint32) uint32 {
+	// use address if not SDHC card
+	if !d.blockAddr {
+		return block << 9
+	}
+	return block
+}
+
 // ReadCSD reads the CSD using CMD9.
 func (d Device) ReadCSD(csd []byte) error {
-	return d.readRegister(CMD9_SEND_CSD, csd)
+	return d.readBlock(CMD9_SEND_CSD, 0, csd[:16])
 }
 
 // ReadCID reads the CID using CMD10
 func (d Device) ReadCID(csd []byte) error {
-	return d.readRegister(CMD10_SEND_CID, csd)
+	return d.readBlock(CMD10_SEND_CID, 0, csd[:16])
 }
 
-func (d Device) readRegister(cmd uint8, dst []byte) error {
-	if d.cmd(cmd, 0, 0xFF) != 0 {
-		return fmt.Errorf("SD_CARD_ERROR_READ_REG")
+// readBlock sends a command that is answered with a data block, and reads
+// the data block into dst.
+func (d Device) readBlock(cmd uint8, arg uint32, dst []byte) error {
+	if d.cmd(cmd, arg) != 0 {
+		d.cs.Set(true)
+		return fmt.Errorf("CMD%d error", cmd)
 	}
+	err := d.readData(dst)
+	d.cs.Set(true)
+	return err
+}
+
+// readData reads a data block sent by the card and checks its CRC.
+func (d Device) readData(dst []byte) error {
 	if err := d.waitStartBlock(); err != nil {
 		return err
 	}
-	// transfer data
-	for i := uint16(0); i < 16; i++ {
-		r, err := d.bus.Transfer(byte(0xFF))
-		if err != nil {
-			return err
-		}
-		dst[i] = r
+	if err := d.bus.Tx(dummy[:len(dst)], dst); err != nil {
+		return err
+	}
+	var crc [2]byte
+	if err := d.bus.Tx(dummy[:2], crc[:]); err != nil {
+		return err
+	}
+	if d.crc && binary.BigEndian.Uint16(crc[:]) != crc16(dst) {
+		return ErrCRC
 	}
-	d.bus.Transfer(byte(0xFF))
-	d.bus.Transfer(byte(0xFF))
-	d.cs.High()
-
 	return nil
 }
 
@@ -326,40 +457,77 @@ func (d Device) ReadData(block uint32, dst []byte) error {
 	if len(dst) < 512 {
 		return fmt.Errorf("len(dst) must be greater than or equal to 512")
 	}
+	return d.readBlock(CMD17_READ_SINGLE_BLOCK, d.address(block), dst[:512])
+}
 
-	// use address if not SDHC card
-	if d.sdCardType != SD_CARD_TYPE_SDHC {
-		block <<= 9
+// ReadBlocks reads len(dst)/512 consecutive blocks starting at block, using
+// a single multi-block read (CMD18). This is much faster than reading the
+// blocks one by one with ReadData.
+func (d Device) ReadBlocks(block uint32, dst []byte) error {
+	n := len(dst) / 512
+	if n == 0 || n*512 != len(dst) {
+		return fmt.Errorf("len(dst) must be a multiple of 512")
 	}
-	if d.cmd(CMD17_READ_SINGLE_BLOCK, block, 0xFF) != 0 {
-		return fmt.Errorf("CMD17 error")
+	if n == 1 {
+		return d.ReadData(block, dst)
 	}
-	if err := d.waitStartBlock(); err != nil {
-		return fmt.Errorf("waitStartBlock()")
+	if d.cmd(CMD18_READ_MULTIPLE_BLOCK, d.address(block)) != 0 {
+		d.cs.Set(true)
+		return fmt.Errorf("CMD18 error")
 	}
+	var err error
+	for i := 0; i < n && err == nil; i++ {
+		err = d.readData(dst[i*512 : (i+1)*512])
+	}
+	// The card keeps sending blocks until it is told to stop, also after
+	// an error.
+	if d.cmd(CMD12_STOP_TRANSMISSION, 0) != 0 && err == nil {
+		err = fmt.Errorf("CMD12 error")
+	}
+	d.cs.Set(true)
+	return err
+}
 
-	err := d.bus.Tx(dummy[:512], dst)
+// writeData sends a data block with the given start token and checks the
+// data response of the card.
+func (d Device) writeData(token byte, src []byte) error {
+	d.bus.Transfer(token)
+
+	err := d.bus.Tx(src[:512], nil)
 	if err != nil {
 		return err
 	}
 
-	// skip CRC (2byte)
-	d.bus.Transfer(byte(0xFF))
-	d.bus.Transfer(byte(0xFF))
+	// The card only checks the CRC if CRC checking is enabled.
+	crc := crc16(src[:512])
+	d.bus.Transfer(byte(crc >> 8))
+	d.bus.Transfer(byte(crc))
 
-	// TODO: probably not necessary
-	d.cs.High()
+	// Data Resp.
+	r, err := d.bus.Transfer(byte(0xFF))
+	if err != nil {
+		return err
+	}
+	switch r & _DATA_RES_MASK {
+	case _DATA_RES_ACCEPTED:
+	case _DATA_RES_CRC_ERROR:
+		return ErrCRC
+	default:
+		return fmt.Errorf("SD_CARD_ERROR_WRITE")
+	}
 
+	// wait no busy
+	err = d.waitNotBusy(600 * time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("SD_CARD_ERROR_WRITE_TIMEOUT")
+	}
 	return nil
 }
 
 // WriteMultiStart starts the continuous write mode using CMD25.
 func (d Device) WriteMultiStart(block uint32) error {
-	// use address if not SDHC card
-	if d.sdCardType != SD_CARD_TYPE_SDHC {
-		block <<= 9
-	}
-	if d.cmd(CMD25_WRITE_MULTIPLE_BLOCK, block, 0xFF) != 0 {
+	if d.cmd(CMD25_WRITE_MULTIPLE_BLOCK, d.address(block)) != 0 {
+		d.cs.Set(true)
 		return fmt.Errorf("CMD25 error")
 	}
 
@@ -372,51 +540,25 @@ func (d Device) WriteMultiStart(block uint32) error {
 // WriteMulti performs continuous writing. It is necessary to call
 // WriteMultiStart() in prior.
 func (d Device) WriteMulti(buf []byte) error {
-	// send Data Token for CMD25
-	d.bus.Transfer(byte(0xFC))
-
-	for i := 0; i < 512; i++ {
-		_, err := d.bus.Transfer(buf[i])
-		if err != nil {
-			return err
-		}
-	}
-
-	// send dummy CRC (2 byte)
-	d.bus.Transfer(byte(0xFF))
-	d.bus.Transfer(byte(0xFF))
-
-	// Data Resp.
-	r, err := d.bus.Transfer(byte(0xFF))
-	if err != nil {
-		return err
-	}
-	if (r & 0x1F) != 0x05 {
-		return fmt.Errorf("SD_CARD_ERROR_WRITE")
-	}
-
-	// wait no busy
-	err = d.waitNotBusy(600 * time.Millisecond)
-	if err != nil {
-		return fmt.Errorf("SD_CARD_ERROR_WRITE_TIMEOUT")
+	if len(buf) < 512 {
+		return fmt.Errorf("len(buf) must be greater than or equal to 512")
 	}
-
-	return nil
+	return d.writeData(_TOKEN_START_MULTI, buf)
 }
 
 // WriteMultiStop exits the continuous write mode.
 func (d Device) WriteMultiStop() error {
-	defer d.cs.High()
+	defer d.cs.Set(true)
 
 	// Stop Tran token for CMD25
-	d.bus.Transfer(0xFD)
+	d.bus.Transfer(_TOKEN_STOP_TRAN)
 
 	// skip 1 byte
 	d.bus.Transfer(byte(0xFF))
 
 	err := d.waitNotBusy(600 * time.Millisecond)
 	if err != nil {
-		return nil
+		return fmt.Errorf("SD_CARD_ERROR_WRITE_TIMEOUT")
 	}
 
 	return nil
@@ -428,195 +570,125 @@ func (d Device) WriteData(block uint32, src []byte) error {
 		return fmt.Errorf("len(src) must be greater than or equal to 512")
 	}
 
-	// use address if not SDHC card
-	if d.sdCardType != SD_CARD_TYPE_SDHC {
-		block <<= 9
-	}
-	if d.cmd(CMD24_WRITE_BLOCK, block, 0xFF) != 0 {
+	if d.cmd(CMD24_WRITE_BLOCK, d.address(block)) != 0 {
+		d.cs.Set(true)
 		return fmt.Errorf("CMD24 error")
 	}
 
-	// wait 1 byte?
-	token := byte(0xFE)
-	d.bus.Transfer(token)
+	err := d.writeData(_TOKEN_START_BLOCK, src)
+	d.cs.Set(true)
+	return err
+}
 
-	err := d.bus.Tx(src[:512], nil)
-	if err != nil {
-		return err
+// WriteBlocks writes len(src)/512 consecutive blocks starting at block, using
+// a single multi-block write (CMD25).
+func (d Device) WriteBlocks(block uint32, src []byte) error {
+	n := len(src) / 512
+	if n == 0 || n*512 != len(src) {
+		return fmt.Errorf("len(src) must be a multiple of 512")
 	}
-
-	// send dummy CRC (2 byte)
-	d.bus.Transfer(byte(0xFF))
-	d.bus.Transfer(byte(0xFF))
-
-	// Data Resp.
-	r, err := d.bus.Transfer(byte(0xFF))
-	if err != nil {
-		return err
+	if n == 1 {
+		return d.WriteData(block, src)
 	}
-	if (r & 0x1F) != 0x05 {
-		return fmt.Errorf("SD_CARD_ERROR_WRITE")
+	if err := d.WriteMultiStart(block); err != nil {
+		return err
 	}
-
-	// wait no busy
-	err = d.waitNotBusy(600 * time.Millisecond)
-	if err != nil {
-		return fmt.Errorf("SD_CARD_ERROR_WRITE_TIMEOUT")
+	for i := 0; i < n; i++ {
+		if err := d.WriteMulti(src[i*512 : (i+1)*512]); err != nil {
+			d.WriteMultiStop()
+			return err
+		}
 	}
-
-	// TODO: probably not necessary
-	d.cs.High()
-	return nil
+	return d.WriteMultiStop()
 }
 
 // ReadAt reads the given number of bytes from the sdcard.
 func (dev *Device) ReadAt(buf []byte, addr int64) (int, error) {
-	block := uint64(addr)
-	// use address if not SDHC card
-	if dev.sdCardType == SD_CARD_TYPE_SDHC {
-		block >>= 9
-	}
+	block := uint32(addr / 512)
+	n := 0
 
-	idx := uint32(0)
-
-	start := uint32(addr % 512)
-	end := uint32(0)
-	remain := uint32(len(buf))
-
-	// If data starts in the middle
-	if 0 < start {
-		if start+remain <= 512 {
-			end = start + remain
-		} else {
-			end = 512
-		}
-
-		err := dev.ReadData(uint32(block), dev.dummybuf)
+	// If data starts in the middle of a block, or is shorter than a block
+	if start := int(addr % 512); start != 0 || len(buf) < 512 {
+		err := dev.ReadData(block, dev.dummybuf)
 		if err != nil {
 			return 0, err
 		}
-		copy(buf[idx:], dev.dummybuf[start:end])
-
-		remain -= end - start
-		idx += end - start
+		n = copy(buf, dev.dummybuf[start:])
 		block++
 	}
 
-	// If more than 512 bytes left
-	for 512 <= remain {
-		start = 0
-		end = 512
-
-		err := dev.ReadData(uint32(block), dev.dummybuf)
+	// Whole blocks are read directly into buf
+	if whole := (len(buf) - n) / 512 * 512; whole > 0 {
+		err := dev.ReadBlocks(block, buf[n:n+whole])
 		if err != nil {
-			return 0, err
+			return n, err
 		}
-		copy(buf[idx:], dev.dummybuf[start:end])
-
-		remain -= end - start
-		idx += end - start
-		block++
+		n += whole
+		block += uint32(whole / 512)
 	}
 
 	// Read to the end
-	if 0 < remain {
-		start = 0
-		end = remain
-
-		err := dev.ReadData(uint32(block), dev.dummybuf)
+	if n < len(buf) {
+		err := dev.ReadData(block, dev.dummybuf)
 		if err != nil {
-			return 0, err
+			return n, err
 		}
-		copy(buf[idx:], dev.dummybuf[start:end])
-
-		remain -= end - start
-		idx += end - start
-		block++
+		n += copy(buf[n:], dev.dummybuf)
 	}
 
-	return int(idx), nil
+	return n, nil
 }
 
 // WriteAt writes the given number of bytes to sdcard.
 func (dev *Device) WriteAt(buf []byte, addr int64) (n int, err error) {
-	block := uint64(addr)
-	// use address if not SDHC card
-	if dev.sdCardType == SD_CARD_TYPE_SDHC {
-		block >>= 9
-	}
-
-	idx := uint32(0)
-
-	start := uint32(addr % 512)
-	end := uint32(0)
-	remain := uint32(len(buf))
+	block := uint32(addr / 512)
 
-	// If data starts in the middle
-	if 0 < start {
-		if start+remain <= 512 {
-			end = start + remain
-		} else {
-			end = 512
-		}
-
-		err := dev.ReadData(uint32(block), dev.dummybuf)
+	// If data starts in the middle of a block, or is shorter than a block
+	if start := int(addr % 512); start != 0 || len(buf) < 512 {
+		err := dev.ReadData(block, dev.dummybuf)
 		if err != nil {
 			return 0, err
 		}
-		copy(dev.dummybuf[start:end], buf[idx:])
+		n = copy(dev.dummybuf[start:], buf)
 
-		err = dev.WriteData(uint32(block), dev.dummybuf)
+		err = dev.WriteData(block, dev.dummybuf)
 		if err != nil {
 			return 0, err
 		}
-
-		remain -= end - start
-		idx += end - start
 		block++
 	}
 
-	// If more than 512 bytes left
-	for 512 <= remain {
-		start = 0
-		end = 512
-
-		err := dev.WriteData(uint32(block), buf[idx:idx+512])
+	// Whole blocks are written directly from buf
+	if whole := (len(buf) - n) / 512 * 512; whole > 0 {
+		err := dev.WriteBlocks(block, buf[n:n+whole])
 		if err != nil {
-			return 0, err
+			return n, err
 		}
-
-		remain -= end - start
-		idx += end - start
-		block++
+		n += whole
+		block += uint32(whole / 512)
 	}
 
 	// Write to the end
-	if 0 < remain {
-		start = 0
-		end = remain
-
-		err := dev.ReadData(uint32(block), dev.dummybuf)
+	if n < len(buf) {
+		err := dev.ReadData(block, dev.dummybuf)
 		if err != nil {
-			return 0, err
+			return n, err
 		}
-		copy(dev.dummybuf[start:end], buf[idx:])
+		copy(dev.dummybuf, buf[n:])
 
-		err = dev.WriteData(uint32(block), dev.dummybuf)
+		err = dev.WriteData(block, dev.dummybuf)
 		if err != nil {
-			return 0, err
+			return n, err
 		}
-
-		remain -= end - start
-		idx += end - start
-		block++
+		n = len(buf)
 	}
 
-	return int(idx), nil
+	return n, nil
 }
 
 // Size returns the number of bytes in this sdcard.
 func (dev *Device) Size() int64 {
-	return int64(dev.CSD.Size())
+	return dev.sectors * 512
 }
 
 // WriteBlockSize returns the block size in which data can be written to
@@ -632,16 +704,20 @@ func (dev *Device) EraseBlockSize() int64 {
 
 // EraseBlocks erases the given number of blocks.
 func (dev *Device) EraseBlocks(start, len int64) error {
-	dev.WriteMultiStart(uint32(start))
+	if err := dev.WriteMultiStart(uint32(start)); err != nil {
+		return err
+	}
 
 	for i := range dev.dummybuf {
 		dev.dummybuf[i] = 0
 	}
 
 	for i := 0; i < int(len); i++ {
-		dev.WriteMulti(dev.dummybuf)
+		if err := dev.WriteMulti(dev.dummybuf); err != nil {
+			dev.WriteMultiStop()
+			return err
+		}
 	}
 
-	dev.WriteMultiStop()
-	return nil
+	return dev.WriteMultiStop()
 }
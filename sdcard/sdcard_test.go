@@ -0,0 +1,159 @@
+package sdcard
+
+import (
+	"bytes"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+func newCard(c *qt.C, typ tester.SDCardType, size int64, cfg Config) (*tester.SDCard, *Device) {
+	card := tester.NewSDCard(typ, size)
+	dev := New(card, card)
+	c.Assert(dev.Configure(cfg), qt.IsNil)
+	return card, &dev
+}
+
+func pattern(n, seed int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*7 + seed)
+	}
+	return b
+}
+
+func TestCRC(t *testing.T) {
+	c := qt.New(t)
+	c.Assert(crc7([]byte{0x40, 0, 0, 0, 0})<<1|1, qt.Equals, byte(0x95))
+	c.Assert(crc7([]byte{0x48, 0, 0, 0x01, 0xAA})<<1|1, qt.Equals, byte(0x87))
+	c.Assert(crc16(bytes.Repeat([]byte{0xFF}, 512)), qt.Equals, uint16(0x7FA1))
+}
+
+func TestCardTypes(t *testing.T) {
+	tests := []struct {
+		typ      tester.SDCardType
+		size     int64
+		cardType byte
+	}{
+		{tester.SDv1, 64 << 20, SD_CARD_TYPE_SD1},
+		{tester.SDv2, 2 << 30, SD_CARD_TYPE_SD2},
+		{tester.SDHC, 8 << 30, SD_CARD_TYPE_SDHC},
+		{tester.SDXC, 64 << 30, SD_CARD_TYPE_SDXC},
+		{tester.MMC, 128 << 20, SD_CARD_TYPE_MMC},
+		{tester.MMC, 4 << 30, SD_CARD_TYPE_MMC},
+	}
+	for _, test := range tests {
+		c := qt.New(t)
+		card, dev := newCard(c, test.typ, test.size, Config{})
+		c.Assert(dev.CardType(), qt.Equals, test.cardType)
+		c.Assert(dev.Size(), qt.Equals, test.size)
+		c.Assert(card.CRC, qt.IsTrue)
+
+		// The last block can be written and read, no matter how the card
+		// is addressed.
+		data := pattern(512, int(test.typ))
+		_, err := dev.WriteAt(data, test.size-512)
+		c.Assert(err, qt.IsNil)
+		buf := make([]byte, 512)
+		card.ReadBlock(uint32(test.size/512-1), buf)
+		c.Assert(buf, qt.DeepEquals, data)
+		_, err = dev.ReadAt(buf, test.size-1024)
+		c.Assert(err, qt.IsNil)
+		c.Assert(buf, qt.DeepEquals, make([]byte, 512))
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	c := qt.New(t)
+	card, dev := newCard(c, tester.SDHC, 1<<30, Config{})
+
+	// Unaligned writes and reads spanning many blocks.
+	data := pattern(10000, 1)
+	n, err := dev.WriteAt(data, 1000)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, len(data))
+	c.Assert(card.Commands[CMD25_WRITE_MULTIPLE_BLOCK], qt.Equals, 1)
+	buf := make([]byte, len(data))
+	n, err = dev.ReadAt(buf, 1000)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, len(data))
+	c.Assert(buf, qt.DeepEquals, data)
+	c.Assert(card.Commands[CMD18_READ_MULTIPLE_BLOCK], qt.Equals, 1)
+
+	// Short reads and writes within a block.
+	_, err = dev.WriteAt([]byte("hello"), 1002)
+	c.Assert(err, qt.IsNil)
+	_, err = dev.ReadAt(buf[:7], 1001)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[1:6]), qt.Equals, "hello")
+	c.Assert(buf[0], qt.Equals, data[1])
+
+	// Aligned multi-block reads go straight to the card.
+	reads := card.Commands[CMD17_READ_SINGLE_BLOCK]
+	_, err = dev.ReadAt(buf[:4096], 4096)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:4096], qt.DeepEquals, data[4096-1000:8192-1000])
+	c.Assert(card.Commands[CMD17_READ_SINGLE_BLOCK], qt.Equals, reads)
+
+	c.Assert(dev.EraseBlocks(2, 3), qt.IsNil)
+	_, err = dev.ReadAt(buf[:2048], 1024)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:1536], qt.DeepEquals, make([]byte, 1536))
+	c.Assert(buf[1536:2048], qt.DeepEquals, data[1560:2072])
+
+	// Reading past the end of the card fails.
+	_, err = dev.ReadAt(buf[:1024], dev.Size()-512)
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestCRCError(t *testing.T) {
+	c := qt.New(t)
+	card, dev := newCard(c, tester.SDHC, 1<<30, Config{})
+	_, err := dev.WriteAt(pattern(2048, 2), 0)
+	c.Assert(err, qt.IsNil)
+
+	card.CorruptReads = true
+	buf := make([]byte, 2048)
+	_, err = dev.ReadAt(buf, 0)
+	c.Assert(err, qt.Equals, ErrCRC)
+	_, err = dev.ReadAt(buf[:100], 0)
+	c.Assert(err, qt.Equals, ErrCRC)
+
+	// Without CRC checking, the corruption goes unnoticed.
+	card, dev = newCard(c, tester.SDHC, 1<<30, Config{DisableCRC: true})
+	c.Assert(card.CRC, qt.IsFalse)
+	card.WriteBlock(0, pattern(512, 3))
+	card.CorruptReads = true
+	_, err = dev.ReadAt(buf[:512], 0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(buf[:512], qt.Not(qt.DeepEquals), pattern(512, 3))
+}
+
+func TestHighSpeed(t *testing.T) {
+	var freq []uint32
+	cfg := Config{
+		Frequency: 50000000,
+		SetFrequency: func(hz uint32) error {
+			freq = append(freq, hz)
+			return nil
+		},
+	}
+	c := qt.New(t)
+	card, _ := newCard(c, tester.SDHC, 1<<30, cfg)
+	c.Assert(card.HighSpeed, qt.IsTrue)
+	c.Assert(freq, qt.DeepEquals, []uint32{250000, 50000000})
+
+	// Version 1 cards don't have a high speed mode.
+	freq = nil
+	card, _ = newCard(c, tester.SDv1, 64<<20, cfg)
+	c.Assert(card.HighSpeed, qt.IsFalse)
+	c.Assert(freq, qt.DeepEquals, []uint32{250000, 25000000})
+
+	// The default frequency doesn't need high speed mode.
+	freq = nil
+	cfg.Frequency = 0
+	card, _ = newCard(c, tester.SDHC, 1<<30, cfg)
+	c.Assert(card.HighSpeed, qt.IsFalse)
+	c.Assert(freq, qt.DeepEquals, []uint32{250000, 4000000})
+}
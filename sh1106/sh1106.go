@@ -6,10 +6,11 @@ package sh1106 // import "tinygo.org/x/drivers/sh1106"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 // Device wraps an SPI connection.
@@ -21,6 +22,7 @@ type Device struct {
 	height     int16
 	bufferSize int16
 	vccState   VccMode
+	dirty      dirty.Rect // columns and pages changed since the last Display
 }
 
 // Config is the configuration for the display
@@ -38,9 +40,9 @@ type I2CBus struct {
 
 type SPIBus struct {
 	wire     drivers.SPI
-	dcPin    machine.Pin
-	resetPin machine.Pin
-	csPin    machine.Pin
+	dcPin    drivers.PinOutput
+	resetPin drivers.PinOutput
+	csPin    drivers.PinOutput
 }
 
 type Buser interface {
@@ -62,10 +64,10 @@ func NewI2C(bus drivers.I2C) Device {
 }
 
 // NewSPI creates a new SSD1306 connection. The SPI wire must already be configured.
-func NewSPI(bus drivers.SPI, dcPin, resetPin, csPin machine.Pin) Device {
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+func NewSPI(bus drivers.SPI, dcPin, resetPin, csPin drivers.PinOutput) Device {
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
 	return Device{
 		bus: &SPIBus{
 			wire:     bus,
@@ -98,6 +100,7 @@ func (d *Device) Configure(cfg Config) {
 	}
 	d.bufferSize = d.width * d.height / 8
 	d.buffer = make([]byte, d.bufferSize)
+	d.dirty.All(d.width, d.height/8)
 
 	d.bus.configure()
 
@@ -169,6 +172,7 @@ func (d *Device) ClearBuffer() {
 	for i := int16(0); i < d.bufferSize; i++ {
 		d.buffer[i] = 0
 	}
+	d.dirty.All(d.width, d.height/8)
 }
 
 // ClearDisplay clears the image buffer and clear the display
@@ -177,8 +181,12 @@ func (d *Device) ClearDisplay() {
 	d.Display()
 }
 
-// Display sends the whole buffer to the screen
+// Display sends the part of the buffer that changed since the last call to
+// the screen
 func (d *Device) Display() error {
+	if d.dirty.Empty() {
+		return nil
+	}
 	// In the 128x64 (SPI) screen resetting to 0x0 after 128 times corrupt the buffer
 	// Since we're printing the whole buffer, avoid resetting it
 	if d.width != 128 || d.height != 64 {
@@ -190,12 +198,16 @@ func (d *Device) Display() error {
 		d.Command(uint8(d.height/8) - 1)
 	}
 
-	for pg := uint8(0); pg < uint8(d.height/8); pg++ {
-		d.Command(0xB0 | (pg & 0x07)) // SET_PAGE_ADDR
-		d.Command(SETLOWCOLUMN | 2)
-		d.Command(SETHIGHCOLUMN | 0)
-		d.Tx(d.buffer[uint16(pg)*0x80:uint16(pg+1)*0x80], false)
+	// The RAM has 132 columns, the display shows them from column 2
+	x0, x1 := d.dirty.X0, d.dirty.X1
+	column := uint8(x0 + 2)
+	for pg := d.dirty.Y0; pg <= d.dirty.Y1; pg++ {
+		d.Command(0xB0 | (uint8(pg) & 0x07)) // SET_PAGE_ADDR
+		d.Command(SETLOWCOLUMN | (column & 0x0F))
+		d.Command(SETHIGHCOLUMN | (column >> 4))
+		d.Tx(d.buffer[pg*d.width+x0:pg*d.width+x1+1], false)
 	}
+	d.dirty.Reset()
 
 	return nil
 }
@@ -208,10 +220,15 @@ func (d *Device) SetPixel(x int16, y int16, c color.RGBA) {
 		return
 	}
 	byteIndex := x + (y/8)*d.width
+	b := d.buffer[byteIndex]
 	if c.R != 0 || c.G != 0 || c.B != 0 {
-		d.buffer[byteIndex] |= 1 << uint8(y%8)
+		b |= 1 << uint8(y%8)
 	} else {
-		d.buffer[byteIndex] &^= 1 << uint8(y%8)
+		b &^= 1 << uint8(y%8)
+	}
+	if b != d.buffer[byteIndex] {
+		d.buffer[byteIndex] = b
+		d.dirty.Add(x, y/8)
 	}
 }
 
@@ -233,6 +250,7 @@ func (d *Device) SetBuffer(buffer []byte) error {
 	for i := int16(0); i < d.bufferSize; i++ {
 		d.buffer[i] = buffer[i]
 	}
+	d.dirty.All(d.width, d.height/8)
 	return nil
 }
 
@@ -283,9 +301,9 @@ func (d *Device) Tx(data []byte, isCommand bool) {
 // tx sends data to the display (I2CBus implementation)
 func (b *I2CBus) tx(data []byte, isCommand bool) {
 	if isCommand {
-		b.wire.WriteRegister(uint8(b.Address), 0x00, data)
+		legacy.WriteRegister(b.wire, uint8(b.Address), 0x00, data)
 	} else {
-		b.wire.WriteRegister(uint8(b.Address), 0x40, data)
+		legacy.WriteRegister(b.wire, uint8(b.Address), 0x40, data)
 	}
 }
 
@@ -0,0 +1,83 @@
+package sh1106
+
+import (
+	"image/color"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+// write is a write to an I2C display: commands, or data if the control byte
+// is 0x40.
+type write struct {
+	Data  bool
+	Bytes []byte
+}
+
+func writes(c *qt.C, log [][]byte) []write {
+	var w []write
+	for _, b := range log {
+		if b[0] != 0x00 && b[0] != 0x40 {
+			c.Fatalf("write with control byte %#x", b[0])
+		}
+		w = append(w, write{b[0] == 0x40, b[1:]})
+	}
+	return w
+}
+
+func TestDisplay(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewI2CBus(c)
+	screen := tester.NewI2CDeviceLog(c, Address)
+	bus.AddDevice(screen)
+	d := NewI2C(bus)
+	d.Configure(Config{})
+
+	// The first Display sends the whole buffer, one page at a time.
+	screen.Writes = nil
+	c.Assert(d.Display(), qt.IsNil)
+	w := writes(c, screen.Writes)
+	c.Assert(w, qt.HasLen, 4*8)
+	c.Assert(w[28:], qt.DeepEquals, []write{
+		{false, []byte{0xB7}},
+		{false, []byte{SETLOWCOLUMN | 2}},
+		{false, []byte{SETHIGHCOLUMN}},
+		{true, make([]byte, 128)},
+	})
+
+	// Only the changed columns of the changed pages are sent. The display
+	// shows the RAM from column 2.
+	screen.Writes = nil
+	d.SetPixel(10, 9, color.RGBA{255, 255, 255, 255})
+	d.SetPixel(20, 20, color.RGBA{255, 255, 255, 255})
+	c.Assert(d.Display(), qt.IsNil)
+	page1, page2 := make([]byte, 11), make([]byte, 11)
+	page1[0] = 1 << 1
+	page2[10] = 1 << 4
+	c.Assert(writes(c, screen.Writes), qt.DeepEquals, []write{
+		{false, []byte{0xB1}},
+		{false, []byte{SETLOWCOLUMN | 12}},
+		{false, []byte{SETHIGHCOLUMN}},
+		{true, page1},
+		{false, []byte{0xB2}},
+		{false, []byte{SETLOWCOLUMN | 12}},
+		{false, []byte{SETHIGHCOLUMN}},
+		{true, page2},
+	})
+
+	// Nothing changed.
+	screen.Writes = nil
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(screen.Writes, qt.HasLen, 0)
+
+	// Columns above 15 need the high nibble.
+	d.SetPixel(100, 63, color.RGBA{255, 255, 255, 255})
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(writes(c, screen.Writes), qt.DeepEquals, []write{
+		{false, []byte{0xB7}},
+		{false, []byte{SETLOWCOLUMN | 102&0x0F}},
+		{false, []byte{SETHIGHCOLUMN | 102>>4}},
+		{true, []byte{0x80}},
+	})
+}
@@ -3,7 +3,9 @@ package shifter // import "tinygo.org/x/drivers/shifter"
 
 import (
 	"errors"
-	"machine"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 const (
@@ -16,22 +18,22 @@ type NumberBit int8
 
 // Device holds the Pins.
 type Device struct {
-	latch machine.Pin
-	clk   machine.Pin
-	out   machine.Pin
+	latch drivers.PinOutput
+	clk   drivers.PinOutput
+	out   drivers.PinInput
 	Pins  []ShiftPin
 	bits  NumberBit
 }
 
 // ShiftPin is the implementation of the ShiftPin interface.
 type ShiftPin struct {
-	pin     machine.Pin
+	pin     int
 	d       *Device
 	pressed bool
 }
 
 // New returns a new shifter driver given the correct pins.
-func New(numBits NumberBit, latch, clk, out machine.Pin) Device {
+func New(numBits NumberBit, latch, clk drivers.PinOutput, out drivers.PinInput) Device {
 	return Device{
 		latch: latch,
 		clk:   clk,
@@ -43,9 +45,9 @@ func New(numBits NumberBit, latch, clk, out machine.Pin) Device {
 
 // Configure here just for interface compatibility.
 func (d *Device) Configure() {
-	d.latch.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.clk.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.out.Configure(machine.PinConfig{Mode: machine.PinInput})
+	legacy.ConfigurePin(d.latch, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.clk, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.out, drivers.PinModeInput)
 	for i := 0; i < int(d.bits); i++ {
 		d.Pins[i] = d.GetShiftPin(i)
 	}
@@ -53,7 +55,7 @@ func (d *Device) Configure() {
 
 // GetShiftPin returns an ShiftPin for a specific input.
 func (d *Device) GetShiftPin(input int) ShiftPin {
-	return ShiftPin{pin: machine.Pin(input), d: d}
+	return ShiftPin{pin: input, d: d}
 }
 
 // Read8Input updates the internal pins' states and returns it as an uint8.
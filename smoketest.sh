@@ -29,6 +29,7 @@ tinygo build -size short -o ./build/test.hex -target=pyportal ./examples/flash/c
 tinygo build -size short -o ./build/test.hex -target=microbit ./examples/gc9a01/main.go
 tinygo build -size short -o ./build/test.hex -target=feather-m0 ./examples/gps/i2c/main.go
 tinygo build -size short -o ./build/test.hex -target=feather-m0 ./examples/gps/uart/main.go
+tinygo build -size short -o ./build/test.hex -target=feather-m0 ./examples/gps/stream/main.go
 tinygo build -size short -o ./build/test.hex -target=itsybitsy-m0 ./examples/hcsr04/main.go
 tinygo build -size short -o ./build/test.hex -target=microbit ./examples/hd44780/customchar/main.go
 tinygo build -size short -o ./build/test.hex -target=microbit ./examples/hd44780/text/main.go
@@ -41,6 +42,7 @@ tinygo build -size short -o ./build/test.hex -target=pyportal ./examples/ili9341
 tinygo build -size short -o ./build/test.hex -target=pyportal ./examples/ili9341/scroll
 tinygo build -size short -o ./build/test.hex -target=xiao ./examples/ili9341/scroll
 tinygo build -size short -o ./build/test.hex -target=pyportal ./examples/ili9341/slideshow
+tinygo build -size short -o ./build/test.hex -target=pyportal ./examples/console
 tinygo build -size short -o ./build/test.hex -target=circuitplay-express ./examples/lis3dh/main.go
 tinygo build -size short -o ./build/test.hex -target=nano-33-ble ./examples/lps22hb/main.go
 tinygo build -size short -o ./build/test.hex -target=microbit ./examples/lsm303agr/main.go
@@ -112,6 +114,9 @@ tinygo build -size short -o ./build/test.hex -target=wioterminal ./examples/rtl8
 tinygo build -size short -o ./build/test.hex -target=wioterminal ./examples/rtl8720dn/webserver/
 tinygo build -size short -o ./build/test.hex -target=wioterminal ./examples/rtl8720dn/mqttsub/
 tinygo build -size short -o ./build/test.hex -target=feather-m4 ./examples/i2csoft/adt7410/
+tinygo build -size short -o ./build/test.hex -target=feather-m4 ./examples/i2cscan/
+tinygo build -size short -o ./build/test.hex -target=feather-m4 ./examples/tca9548a/
+tinygo build -size short -o ./build/test.hex -target=feather-m4 ./examples/i2cbus/
 tinygo build -size short -o ./build/test.elf -target=wioterminal ./examples/axp192/m5stack-core2-blinky/
 tinygo build -size short -o ./build/test.uf2 -target=pico ./examples/xpt2046/main.go
 tinygo build -size short -o ./build/test.elf -target=m5stack-core2 ./examples/ft6336/basic/
@@ -123,6 +128,11 @@ tinygo build -size short -o ./build/test.hex -target=badger2040 ./examples/uc815
 tinygo build -size short -o ./build/test.uf2 -target=pico ./examples/scd4x/main.go
 tinygo build -size short -o ./build/test.uf2 -target=circuitplay-express ./examples/makeybutton/main.go
 tinygo build -size short -o ./build/test.hex -target=arduino-nano33 ./examples/ds18b20/main.go
+tinygo build -size short -o ./build/test.hex -target=arduino-nano33 ./examples/ds2482/main.go
+tinygo build -size short -o ./build/test.hex -target=arduino-nano33 ./examples/ds2431/main.go
+tinygo build -size short -o ./build/test.hex -target=arduino-nano33 ./examples/ds2413/main.go
+tinygo build -size short -o ./build/test.hex -target=arduino-nano33 ./examples/ds2438/main.go
+tinygo build -size short -o ./build/test.hex -target=arduino-nano33 ./examples/ds28ea00/main.go
 tinygo build -size short -o ./build/test.hex -target=nucleo-wl55jc ./examples/lora/lorawan/atcmd/
 tinygo build -size short -o ./build/test.uf2 -target=pico ./examples/as560x/main.go
 tinygo build -size short -o ./build/test.uf2 -target=pico ./examples/mpu6886/main.go
@@ -6,10 +6,11 @@ package ssd1306 // import "tinygo.org/x/drivers/ssd1306"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 // Device wraps I2C or SPI connection.
@@ -21,6 +22,7 @@ type Device struct {
 	bufferSize int16
 	vccState   VccMode
 	canReset   bool
+	dirty      dirty.Rect // columns and pages changed since the last Display
 }
 
 // Config is the configuration for the display
@@ -38,9 +40,9 @@ type I2CBus struct {
 
 type SPIBus struct {
 	wire     drivers.SPI
-	dcPin    machine.Pin
-	resetPin machine.Pin
-	csPin    machine.Pin
+	dcPin    drivers.PinOutput
+	resetPin drivers.PinOutput
+	csPin    drivers.PinOutput
 }
 
 type Buser interface {
@@ -62,10 +64,10 @@ func NewI2C(bus drivers.I2C) Device {
 }
 
 // NewSPI creates a new SSD1306 connection. The SPI wire must already be configured.
-func NewSPI(bus drivers.SPI, dcPin, resetPin, csPin machine.Pin) Device {
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+func NewSPI(bus drivers.SPI, dcPin, resetPin, csPin drivers.PinOutput) Device {
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
 	return Device{
 		bus: &SPIBus{
 			wire:     bus,
@@ -98,6 +100,7 @@ func (d *Device) Configure(cfg Config) {
 	}
 	d.bufferSize = d.width * d.height / 8
 	d.buffer = make([]byte, d.bufferSize)
+	d.dirty.All(d.width, d.height/8)
 	d.canReset = cfg.Address != 0 || d.width != 128 || d.height != 64 // I2C or not 128x64
 
 	d.bus.configure()
@@ -169,6 +172,7 @@ func (d *Device) ClearBuffer() {
 	for i := int16(0); i < d.bufferSize; i++ {
 		d.buffer[i] = 0
 	}
+	d.dirty.All(d.width, d.height/8)
 }
 
 // ClearDisplay clears the image buffer and clear the display
@@ -177,22 +181,38 @@ func (d *Device) ClearDisplay() {
 	d.Display()
 }
 
-// Display sends the whole buffer to the screen
+// Display sends the part of the buffer that changed since the last call to
+// the screen
 func (d *Device) Display() error {
-	// Reset the screen to 0x0
-	// This works fine with I2C
+	if d.dirty.Empty() {
+		return nil
+	}
 	// In the 128x64 (SPI) screen resetting to 0x0 after 128 times corrupt the buffer
-	// Since we're printing the whole buffer, avoid resetting it in this case
-	if d.canReset {
-		d.Command(COLUMNADDR)
-		d.Command(0)
-		d.Command(uint8(d.width - 1))
-		d.Command(PAGEADDR)
-		d.Command(0)
-		d.Command(uint8(d.height/8) - 1)
+	// Since the address can't be set in this case, print the whole buffer
+	if !d.canReset {
+		d.Tx(d.buffer, false)
+		d.dirty.Reset()
+		return nil
 	}
 
-	d.Tx(d.buffer, false)
+	// Set the address window to the changed columns and pages
+	x0, x1 := d.dirty.X0, d.dirty.X1
+	d.Command(COLUMNADDR)
+	d.Command(uint8(x0))
+	d.Command(uint8(x1))
+	d.Command(PAGEADDR)
+	d.Command(uint8(d.dirty.Y0))
+	d.Command(uint8(d.dirty.Y1))
+
+	if x0 == 0 && x1 == d.width-1 {
+		// The pages are contiguous in the buffer
+		d.Tx(d.buffer[d.dirty.Y0*d.width:(d.dirty.Y1+1)*d.width], false)
+	} else {
+		for page := d.dirty.Y0; page <= d.dirty.Y1; page++ {
+			d.Tx(d.buffer[page*d.width+x0:page*d.width+x1+1], false)
+		}
+	}
+	d.dirty.Reset()
 	return nil
 }
 
@@ -204,10 +224,15 @@ func (d *Device) SetPixel(x int16, y int16, c color.RGBA) {
 		return
 	}
 	byteIndex := x + (y/8)*d.width
+	b := d.buffer[byteIndex]
 	if c.R != 0 || c.G != 0 || c.B != 0 {
-		d.buffer[byteIndex] |= 1 << uint8(y%8)
+		b |= 1 << uint8(y%8)
 	} else {
-		d.buffer[byteIndex] &^= 1 << uint8(y%8)
+		b &^= 1 << uint8(y%8)
+	}
+	if b != d.buffer[byteIndex] {
+		d.buffer[byteIndex] = b
+		d.dirty.Add(x, y/8)
 	}
 }
 
@@ -229,11 +254,14 @@ func (d *Device) SetBuffer(buffer []byte) error {
 	for i := int16(0); i < d.bufferSize; i++ {
 		d.buffer[i] = buffer[i]
 	}
+	d.dirty.All(d.width, d.height/8)
 	return nil
 }
 
-// GetBuffer returns the whole buffer
+// GetBuffer returns the whole buffer. As it may be modified, the next Display
+// sends the whole buffer.
 func (d *Device) GetBuffer() []byte {
+	d.dirty.All(d.width, d.height/8)
 	return d.buffer
 }
 
@@ -277,9 +305,9 @@ func (d *Device) Tx(data []byte, isCommand bool) {
 // tx sends data to the display (I2CBus implementation)
 func (b *I2CBus) tx(data []byte, isCommand bool) {
 	if isCommand {
-		b.wire.WriteRegister(uint8(b.Address), 0x00, data)
+		legacy.WriteRegister(b.wire, uint8(b.Address), 0x00, data)
 	} else {
-		b.wire.WriteRegister(uint8(b.Address), 0x40, data)
+		legacy.WriteRegister(b.wire, uint8(b.Address), 0x40, data)
 	}
 }
 
@@ -0,0 +1,87 @@
+package ssd1306
+
+import (
+	"image/color"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+var white = color.RGBA{255, 255, 255, 255}
+
+// split returns the commands and the data written to an I2C display, which
+// are prefixed by a control byte.
+func split(c *qt.C, writes [][]byte) (commands []byte, data [][]byte) {
+	for _, w := range writes {
+		switch w[0] {
+		case 0x00:
+			commands = append(commands, w[1:]...)
+		case 0x40:
+			data = append(data, w[1:])
+		default:
+			c.Fatalf("write with control byte %#x", w[0])
+		}
+	}
+	return commands, data
+}
+
+func TestDisplayI2C(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewI2CBus(c)
+	screen := tester.NewI2CDeviceLog(c, Address)
+	bus.AddDevice(screen)
+	d := NewI2C(bus)
+	d.Configure(Config{Width: 128, Height: 32, Address: Address})
+
+	// The first Display sends the whole buffer.
+	screen.Writes = nil
+	c.Assert(d.Display(), qt.IsNil)
+	commands, data := split(c, screen.Writes)
+	c.Assert(commands, qt.DeepEquals, []byte{COLUMNADDR, 0, 127, PAGEADDR, 0, 3})
+	c.Assert(data, qt.DeepEquals, [][]byte{make([]byte, 512)})
+
+	// Only the changed columns of the changed pages are sent.
+	screen.Writes = nil
+	d.SetPixel(10, 9, white)
+	d.SetPixel(20, 20, white)
+	c.Assert(d.Display(), qt.IsNil)
+	commands, data = split(c, screen.Writes)
+	c.Assert(commands, qt.DeepEquals, []byte{COLUMNADDR, 10, 20, PAGEADDR, 1, 2})
+	page1, page2 := make([]byte, 11), make([]byte, 11)
+	page1[0] = 1 << 1
+	page2[10] = 1 << 4
+	c.Assert(data, qt.DeepEquals, [][]byte{page1, page2})
+
+	// Nothing changed.
+	screen.Writes = nil
+	d.SetPixel(10, 9, white)
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(screen.Writes, qt.HasLen, 0)
+
+	// Pages of the full width are contiguous.
+	d.SetPixel(0, 0, white)
+	d.SetPixel(127, 0, white)
+	c.Assert(d.Display(), qt.IsNil)
+	commands, data = split(c, screen.Writes)
+	c.Assert(commands, qt.DeepEquals, []byte{COLUMNADDR, 0, 127, PAGEADDR, 0, 0})
+	c.Assert(data, qt.HasLen, 1)
+	c.Assert(data[0], qt.HasLen, 128)
+	c.Assert(data[0][0], qt.Equals, byte(1))
+	c.Assert(data[0][127], qt.Equals, byte(1))
+}
+
+func TestDisplaySPI(t *testing.T) {
+	c := qt.New(t)
+	bus := tester.NewSPIBus(c)
+	cs := &tester.Pin{}
+	d := NewSPI(bus, bus.DC, &tester.Pin{}, cs)
+	d.Configure(Config{Width: 128, Height: 32})
+	c.Assert(d.Display(), qt.IsNil)
+
+	bus.Reset()
+	d.SetPixel(64, 31, white)
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(bus.Written(false), qt.DeepEquals, []byte{COLUMNADDR, 64, 64, PAGEADDR, 3, 3})
+	c.Assert(bus.Written(true), qt.DeepEquals, []byte{0x80})
+}
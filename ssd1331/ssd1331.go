@@ -5,12 +5,12 @@ package ssd1331 // import "tinygo.org/x/drivers/ssd1331"
 
 import (
 	"image/color"
-	"machine"
 
 	"errors"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 type Model uint8
@@ -19,9 +19,9 @@ type Rotation uint8
 // Device wraps an SPI connection.
 type Device struct {
 	bus         drivers.SPI
-	dcPin       machine.Pin
-	resetPin    machine.Pin
-	csPin       machine.Pin
+	dcPin       drivers.PinOutput
+	resetPin    drivers.PinOutput
+	csPin       drivers.PinOutput
 	width       int16
 	height      int16
 	batchLength int16
@@ -36,10 +36,10 @@ type Config struct {
 }
 
 // New creates a new SSD1331 connection. The SPI wire must already be configured.
-func New(bus drivers.SPI, resetPin, dcPin, csPin machine.Pin) Device {
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+func New(bus drivers.SPI, resetPin, dcPin, csPin drivers.PinOutput) Device {
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
 	return Device{
 		bus:      bus,
 		dcPin:    dcPin,
@@ -6,10 +6,11 @@ package ssd1351 // import "tinygo.org/x/drivers/ssd1351"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+	"tinygo.org/x/drivers/pixel"
 )
 
 var (
@@ -20,11 +21,11 @@ var (
 // Device wraps an SPI connection.
 type Device struct {
 	bus          drivers.SPI
-	dcPin        machine.Pin
-	resetPin     machine.Pin
-	csPin        machine.Pin
-	enPin        machine.Pin
-	rwPin        machine.Pin
+	dcPin        drivers.PinOutput
+	resetPin     drivers.PinOutput
+	csPin        drivers.PinOutput
+	enPin        drivers.PinOutput
+	rwPin        drivers.PinOutput
 	width        int16
 	height       int16
 	rowOffset    int16
@@ -41,7 +42,7 @@ type Config struct {
 }
 
 // New creates a new SSD1351 connection. The SPI wire must already be configured.
-func New(bus drivers.SPI, resetPin, dcPin, csPin, enPin, rwPin machine.Pin) Device {
+func New(bus drivers.SPI, resetPin, dcPin, csPin, enPin, rwPin drivers.PinOutput) Device {
 	return Device{
 		bus:      bus,
 		dcPin:    dcPin,
@@ -73,11 +74,11 @@ func (d *Device) Configure(cfg Config) {
 	}
 
 	// configure GPIO pins
-	d.dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.enPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	d.rwPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	legacy.ConfigurePin(d.dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.enPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(d.rwPin, drivers.PinModeOutput)
 
 	// reset the device
 	d.resetPin.High()
@@ -197,6 +198,32 @@ func (d *Device) FillRectangle(x, y, width, height int16, c color.RGBA) error {
 	return nil
 }
 
+// PixelFormat returns the format of the pixels of DrawBitmap.
+func (d *Device) PixelFormat() pixel.Format {
+	return pixel.RGB565
+}
+
+// DrawBitmap copies an RGB565 image to the display at given coordinates.
+func (d *Device) DrawBitmap(x, y int16, img pixel.Image) error {
+	if img.Format() != pixel.RGB565 {
+		return pixel.ErrFormat
+	}
+	w, h := img.Size()
+	k, j := d.Size()
+	if x < 0 || y < 0 || w <= 0 || h <= 0 ||
+		x >= k || (x+w) > k || y >= j || (y+h) > j {
+		return errDrawingOutOfBounds
+	}
+	d.setWindow(x, y, w, h)
+	d.Tx(img.Bytes(), false)
+	return nil
+}
+
+// FillRect fills a rectangle at a given coordinates with a color.
+func (d *Device) FillRect(x, y, width, height int16, c color.RGBA) error {
+	return d.FillRectangle(x, y, width, height, c)
+}
+
 // FillRectangleWithBuffer fills a rectangle at given coordinates with a buffer
 func (d *Device) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
 	if x < 0 || y < 0 || width <= 0 || height <= 0 ||
@@ -289,6 +316,18 @@ func (d *Device) Size() (w, h int16) {
 	return d.width, d.height
 }
 
+// SetScroll sets the line of the display memory that is shown at the top of
+// the display.
+func (d *Device) SetScroll(line int16) {
+	d.Command(SET_DISPLAY_START_LINE)
+	d.Data(uint8(line & 0x7F))
+}
+
+// Rotation returns drivers.Rotation0, as the display can't be rotated.
+func (d *Device) Rotation() drivers.Rotation {
+	return drivers.Rotation0
+}
+
 // RGBATo565 converts a color.RGBA to uint16 used in the display
 func RGBATo565(c color.RGBA) uint16 {
 	r, g, b, _ := c.RGBA()
@@ -5,12 +5,13 @@ package st7735 // import "tinygo.org/x/drivers/st7735"
 
 import (
 	"image/color"
-	"machine"
 	"time"
 
 	"errors"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+	"tinygo.org/x/drivers/pixel"
 )
 
 type Model uint8
@@ -27,10 +28,10 @@ var (
 // Device wraps an SPI connection.
 type Device struct {
 	bus          drivers.SPI
-	dcPin        machine.Pin
-	resetPin     machine.Pin
-	csPin        machine.Pin
-	blPin        machine.Pin
+	dcPin        drivers.PinOutput
+	resetPin     drivers.PinOutput
+	csPin        drivers.PinOutput
+	blPin        drivers.PinOutput
 	width        int16
 	height       int16
 	columnOffset int16
@@ -53,11 +54,11 @@ type Config struct {
 }
 
 // New creates a new ST7735 connection. The SPI wire must already be configured.
-func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin machine.Pin) Device {
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	blPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin drivers.PinOutput) Device {
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(blPin, drivers.PinModeOutput)
 	return Device{
 		bus:      bus,
 		dcPin:    dcPin,
@@ -296,6 +297,25 @@ func (d *Device) DrawRGBBitmap8(x, y int16, data []uint8, w, h int16) error {
 	return nil
 }
 
+// PixelFormat returns the format of the pixels of DrawBitmap.
+func (d *Device) PixelFormat() pixel.Format {
+	return pixel.RGB565
+}
+
+// DrawBitmap copies an RGB565 image to the display at given coordinates.
+func (d *Device) DrawBitmap(x, y int16, img pixel.Image) error {
+	if img.Format() != pixel.RGB565 {
+		return pixel.ErrFormat
+	}
+	w, h := img.Size()
+	return d.DrawRGBBitmap8(x, y, img.Bytes(), w, h)
+}
+
+// FillRect fills a rectangle at given coordinates with a color.
+func (d *Device) FillRect(x, y, width, height int16, c color.RGBA) error {
+	return d.FillRectangle(x, y, width, height, c)
+}
+
 // FillRectangle fills a rectangle at a given coordinates with a buffer
 func (d *Device) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
 	k, l := d.Size()
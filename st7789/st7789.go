@@ -7,13 +7,14 @@ package st7789 // import "tinygo.org/x/drivers/st7789"
 
 import (
 	"image/color"
-	"machine"
 	"math"
 	"time"
 
 	"errors"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
+	"tinygo.org/x/drivers/pixel"
 )
 
 // Rotation controls the rotation used by the display.
@@ -34,10 +35,10 @@ var (
 // Device wraps an SPI connection.
 type Device struct {
 	bus             drivers.SPI
-	dcPin           machine.Pin
-	resetPin        machine.Pin
-	csPin           machine.Pin
-	blPin           machine.Pin
+	dcPin           drivers.PinOutput
+	resetPin        drivers.PinOutput
+	csPin           drivers.PinOutput
+	blPin           drivers.PinOutput
 	width           int16
 	height          int16
 	columnOffsetCfg int16
@@ -45,6 +46,7 @@ type Device struct {
 	columnOffset    int16
 	rowOffset       int16
 	rotation        drivers.Rotation
+	colorFormat     ColorFormat
 	frameRate       FrameRate
 	batchLength     int32
 	isBGR           bool
@@ -70,11 +72,11 @@ type Config struct {
 }
 
 // New creates a new ST7789 connection. The SPI wire must already be configured.
-func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin machine.Pin) Device {
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	blPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin drivers.PinOutput) Device {
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(resetPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(blPin, drivers.PinModeOutput)
 	return Device{
 		bus:      bus,
 		dcPin:    dcPin,
@@ -203,7 +205,7 @@ func (d *Device) sendCommand(command uint8, data []byte) error {
 // startWrite must be called at the beginning of all exported methods to set the
 // chip select pin low.
 func (d *Device) startWrite() {
-	if d.csPin != machine.NoPin {
+	if !legacy.PinIsNoPin(d.csPin) {
 		d.csPin.Low()
 	}
 }
@@ -211,7 +213,7 @@ func (d *Device) startWrite() {
 // endWrite must be called at the end of all exported methods to set the chip
 // select pin high.
 func (d *Device) endWrite() {
-	if d.csPin != machine.NoPin {
+	if !legacy.PinIsNoPin(d.csPin) {
 		d.csPin.High()
 	}
 }
@@ -366,6 +368,21 @@ func (d *Device) DrawRGBBitmap8(x, y int16, data []uint8, w, h int16) error {
 	return nil
 }
 
+// DrawBitmap copies an image in the pixel format of the display to the screen
+// at given coordinates.
+func (d *Device) DrawBitmap(x, y int16, img pixel.Image) error {
+	if img.Format() == 0 || img.Format() != d.PixelFormat() {
+		return pixel.ErrFormat
+	}
+	w, h := img.Size()
+	return d.DrawRGBBitmap8(x, y, img.Bytes(), w, h)
+}
+
+// FillRect fills a rectangle at a given coordinates with a color.
+func (d *Device) FillRect(x, y, width, height int16, c color.RGBA) error {
+	return d.FillRectangle(x, y, width, height, c)
+}
+
 // FillRectangleWithBuffer fills buffer with a rectangle at a given coordinates.
 func (d *Device) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
 	i, j := d.Size()
@@ -454,6 +471,19 @@ func (d *Device) setColorFormat(format ColorFormat) {
 	// reasonable default. Also, the RGB interface doesn't support RGB444.
 	colmod := byte(format) | 0x50
 	d.sendCommand(COLMOD, []byte{colmod})
+	d.colorFormat = format
+}
+
+// PixelFormat returns the format of the pixels of DrawBitmap, which depends
+// on SetColorFormat. RGB666 isn't supported by DrawBitmap, and returns 0.
+func (d *Device) PixelFormat() pixel.Format {
+	switch d.colorFormat {
+	case ColorRGB565:
+		return pixel.RGB565
+	case ColorRGB444:
+		return pixel.RGB444
+	}
+	return 0
 }
 
 // Rotation returns the current rotation of the device.
@@ -0,0 +1,8 @@
+package tca9548a
+
+// The I2C address which this device listens to, with A0 to A2 low. The
+// address is 0x70 to 0x77 depending on these pins.
+const Address = 0x70
+
+// The number of downstream channels.
+const Channels = 8
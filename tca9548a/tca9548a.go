@@ -0,0 +1,128 @@
+// Package tca9548a provides a driver for the TCA9548A and PCA9548A 8-channel
+// I2C multiplexers, which connect devices with the same address to one bus.
+//
+// The channels can be used by different goroutines. When other goroutines
+// also use the upstream bus directly, wrap it in an i2cbus.Shared.
+//
+// Datasheet:
+// https://www.ti.com/lit/ds/symlink/tca9548a.pdf
+package tca9548a // import "tinygo.org/x/drivers/tca9548a"
+
+import (
+	"errors"
+	"sync"
+
+	"tinygo.org/x/drivers"
+)
+
+var ErrInvalidChannel = errors.New("tca9548a: invalid channel")
+
+// Device wraps an I2C connection to a TCA9548A device.
+type Device struct {
+	bus     drivers.I2C
+	Address uint16
+
+	// mu is held while a channel is selected and used, so that the channels
+	// can be used by different goroutines.
+	mu sync.Mutex
+	// control is the value of the control register, when known is set.
+	control uint8
+	known   bool
+	buf     [1]byte
+}
+
+// New creates a new TCA9548A connection. The I2C bus must already be
+// configured.
+//
+// This function only creates the Device object, it does not touch the device.
+func New(bus drivers.I2C) *Device {
+	return &Device{
+		bus:     bus,
+		Address: Address,
+	}
+}
+
+// Configure disconnects all the channels.
+func (d *Device) Configure() error {
+	return d.Disable()
+}
+
+// Connected returns whether the device acknowledges its address.
+func (d *Device) Connected() bool {
+	_, err := d.Selected()
+	return err == nil
+}
+
+// Channel returns the bus behind channel n, 0 to 7. Each transaction on it
+// selects the channel first, if it isn't the selected one yet.
+func (d *Device) Channel(n int) drivers.I2C {
+	return &channel{d: d, n: n}
+}
+
+// Select connects channel n, and disconnects the others.
+func (d *Device) Select(n int) error {
+	if n < 0 || n >= Channels {
+		return ErrInvalidChannel
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.selectChannels(1 << n)
+}
+
+// Disable disconnects all the channels.
+func (d *Device) Disable() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.write(0)
+}
+
+// Selected returns the control register: bit n is set when channel n is
+// connected.
+func (d *Device) Selected() (uint8, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	control, err := d.read()
+	if err == nil {
+		d.control, d.known = control, true
+	}
+	return control, err
+}
+
+func (d *Device) selectChannels(control uint8) error {
+	if d.known && d.control == control {
+		return nil
+	}
+	return d.write(control)
+}
+
+func (d *Device) write(control uint8) error {
+	d.buf[0] = control
+	err := d.bus.Tx(d.Address, d.buf[:], nil)
+	// After an error the channels may or may not have been switched.
+	d.control, d.known = control, err == nil
+	return err
+}
+
+func (d *Device) read() (uint8, error) {
+	err := d.bus.Tx(d.Address, nil, d.buf[:])
+	return d.buf[0], err
+}
+
+// channel is the bus behind a channel of the multiplexer.
+type channel struct {
+	d *Device
+	n int
+}
+
+// Tx implements drivers.I2C.
+func (c *channel) Tx(addr uint16, w, r []byte) error {
+	if c.n < 0 || c.n >= Channels {
+		return ErrInvalidChannel
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if err := c.d.selectChannels(1 << c.n); err != nil {
+		return err
+	}
+	return c.d.bus.Tx(addr, w, r)
+}
@@ -0,0 +1,104 @@
+package tca9548a
+
+import (
+	"sync"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers/tester"
+)
+
+// mux is the bus upstream of a multiplexer, which forwards the transactions
+// to the selected channels.
+type mux struct {
+	c        *qt.C
+	control  uint8
+	writes   int
+	channels [Channels]*tester.I2CBus
+}
+
+func newMux(c *qt.C) *mux {
+	m := &mux{c: c}
+	for i := range m.channels {
+		m.channels[i] = tester.NewI2CBus(c)
+		m.channels[i].NACK = true
+	}
+	return m
+}
+
+func (m *mux) Tx(addr uint16, w, r []byte) error {
+	if addr == Address {
+		if len(w) == 1 {
+			m.control = w[0]
+			m.writes++
+		}
+		if len(r) == 1 {
+			r[0] = m.control
+		}
+		return nil
+	}
+	var selected *tester.I2CBus
+	for i, bus := range m.channels {
+		if m.control&(1<<i) != 0 {
+			if selected != nil {
+				m.c.Fatalf("transaction with %#x with channels %#b selected", addr, m.control)
+			}
+			selected = bus
+		}
+	}
+	if selected == nil {
+		return tester.ErrNACK
+	}
+	return selected.Tx(addr, w, r)
+}
+
+func TestChannels(t *testing.T) {
+	c := qt.New(t)
+	m := newMux(c)
+	m.channels[0].NewDevice(0x29).Registers[0x10] = 0xAA
+	m.channels[3].NewDevice(0x29).Registers[0x10] = 0xBB
+
+	d := New(m)
+	c.Assert(d.Configure(), qt.IsNil)
+	c.Assert(d.Connected(), qt.IsTrue)
+	ch0, ch3 := d.Channel(0), d.Channel(3)
+
+	buf := make([]byte, 1)
+	c.Assert(ch0.Tx(0x29, []byte{0x10}, buf), qt.IsNil)
+	c.Assert(buf[0], qt.Equals, uint8(0xAA))
+	c.Assert(ch0.Tx(0x29, []byte{0x10}, buf), qt.IsNil)
+	c.Assert(ch3.Tx(0x29, []byte{0x10}, buf), qt.IsNil)
+	c.Assert(buf[0], qt.Equals, uint8(0xBB))
+	// Configure, then one selection per switch.
+	c.Assert(m.writes, qt.Equals, 3)
+
+	control, err := d.Selected()
+	c.Assert(err, qt.IsNil)
+	c.Assert(control, qt.Equals, uint8(1<<3))
+
+	c.Assert(d.Channel(8).Tx(0x29, []byte{0x10}, buf), qt.Equals, ErrInvalidChannel)
+	c.Assert(d.Select(-1), qt.Equals, ErrInvalidChannel)
+}
+
+func TestConcurrentChannels(t *testing.T) {
+	c := qt.New(t)
+	m := newMux(c)
+	d := New(m)
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		m.channels[n].NewDevice(0x44).Registers[0] = uint8(n)
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			ch := d.Channel(n)
+			buf := make([]byte, 1)
+			for i := 0; i < 100; i++ {
+				if err := ch.Tx(0x44, []byte{0}, buf); err != nil || buf[0] != uint8(n) {
+					t.Errorf("channel %d read %d, %v", n, buf[0], err)
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,151 @@
+package tester
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrPowerLoss is returned by BlockDevice once its write budget is used up.
+var ErrPowerLoss = errors.New("tester: simulated power loss")
+
+// BlockDevice implements the drivers.BlockDevice interface in memory for
+// testing filesystems and other storage code.
+//
+// A BlockDevice created with NewFlashDevice behaves like NOR flash: erased
+// memory reads as 0xff and writes can only clear bits, so writing to memory
+// that was not erased first corrupts it just like on a real chip. A device
+// created with NewBlockDevice behaves like an SD card, where memory can be
+// overwritten freely.
+type BlockDevice struct {
+	// Data holds the contents of the device. It can be inspected or changed
+	// as desired for testing.
+	Data []byte
+
+	// WriteBudget is the number of bytes that can still be written (or
+	// erased) before the device simulates a power loss. Once it is used up,
+	// writes are cut short and return ErrPowerLoss. A negative value means
+	// there is no limit.
+	WriteBudget int
+
+	// Writes and Erases count the number of bytes written and erase blocks
+	// erased so far.
+	Writes int
+	Erases int
+
+	// EraseCount holds the number of times each erase block was erased, to
+	// check wear levelling.
+	EraseCount []int
+
+	writeBlockSize int64
+	eraseBlockSize int64
+	nor            bool
+}
+
+// NewBlockDevice returns an SD card like block device of the given size,
+// where writes overwrite data and erased blocks read as zero.
+func NewBlockDevice(size, blockSize int64) *BlockDevice {
+	return &BlockDevice{
+		Data:           make([]byte, size),
+		WriteBudget:    -1,
+		EraseCount:     make([]int, size/blockSize),
+		writeBlockSize: blockSize,
+		eraseBlockSize: blockSize,
+	}
+}
+
+// NewFlashDevice returns a NOR flash like block device of the given size. The
+// memory starts out erased.
+func NewFlashDevice(size, pageSize, sectorSize int64) *BlockDevice {
+	d := &BlockDevice{
+		Data:           make([]byte, size),
+		WriteBudget:    -1,
+		EraseCount:     make([]int, size/sectorSize),
+		writeBlockSize: pageSize,
+		eraseBlockSize: sectorSize,
+		nor:            true,
+	}
+	for i := range d.Data {
+		d.Data[i] = 0xff
+	}
+	return d
+}
+
+// ReadAt implements io.ReaderAt.
+func (d *BlockDevice) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(d.Data)) {
+		return 0, io.EOF
+	}
+	return copy(p, d.Data[off:]), nil
+}
+
+// WriteAt implements io.WriterAt.
+func (d *BlockDevice) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(d.Data)) {
+		return 0, io.ErrShortWrite
+	}
+	n := len(p)
+	var err error
+	if d.WriteBudget >= 0 && n > d.WriteBudget {
+		n = d.WriteBudget
+		err = ErrPowerLoss
+	}
+	if d.WriteBudget >= 0 {
+		d.WriteBudget -= n
+	}
+	dst := d.Data[off : off+int64(n)]
+	for i, b := range p[:n] {
+		if d.nor {
+			dst[i] &= b
+		} else {
+			dst[i] = b
+		}
+	}
+	d.Writes += n
+	return n, err
+}
+
+// Size returns the size of the device in bytes.
+func (d *BlockDevice) Size() int64 {
+	return int64(len(d.Data))
+}
+
+// WriteBlockSize returns the page size of the device.
+func (d *BlockDevice) WriteBlockSize() int64 {
+	return d.writeBlockSize
+}
+
+// EraseBlockSize returns the erase block size of the device.
+func (d *BlockDevice) EraseBlockSize() int64 {
+	return d.eraseBlockSize
+}
+
+// EraseBlocks erases len blocks starting at block start. When the write
+// budget is used up, the block being erased is left partially erased.
+func (d *BlockDevice) EraseBlocks(start, len int64) error {
+	if start < 0 || (start+len)*d.eraseBlockSize > int64(cap(d.Data)) {
+		return io.ErrShortWrite
+	}
+	var v byte
+	if d.nor {
+		v = 0xff
+	}
+	for blk := start; blk < start+len; blk++ {
+		data := d.Data[blk*d.eraseBlockSize : (blk+1)*d.eraseBlockSize]
+		if d.WriteBudget >= 0 && int(d.eraseBlockSize) > d.WriteBudget {
+			for i := range data[:d.WriteBudget] {
+				data[i] = v
+			}
+			d.WriteBudget = 0
+			return ErrPowerLoss
+		}
+		if d.WriteBudget >= 0 {
+			d.WriteBudget -= int(d.eraseBlockSize)
+		}
+		for i := range data {
+			data[i] = v
+		}
+		d.Erases++
+		d.EraseCount[blk]++
+	}
+	return nil
+}
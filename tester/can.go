@@ -0,0 +1,74 @@
+package tester
+
+import (
+	"sync"
+
+	"tinygo.org/x/drivers"
+)
+
+// CANBus is an in-memory CAN bus. Frames transmitted by one node are
+// received by all other nodes, in order. It is safe to use the nodes from
+// different goroutines.
+type CANBus struct {
+	mu    sync.Mutex
+	nodes []*CANNode
+}
+
+// CANNode is a node on a CANBus. It implements drivers.CAN.
+type CANNode struct {
+	// Sent counts the frames transmitted by this node.
+	Sent int
+
+	bus *CANBus
+	rx  []drivers.CANFrame
+}
+
+// NewCANBus returns a bus without nodes.
+func NewCANBus() *CANBus {
+	return &CANBus{}
+}
+
+// Node adds a node to the bus. It only receives frames that are transmitted
+// after it was added.
+func (b *CANBus) Node() *CANNode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := &CANNode{bus: b}
+	b.nodes = append(b.nodes, n)
+	return n
+}
+
+// Transmit sends a frame to all other nodes on the bus.
+func (n *CANNode) Transmit(f *drivers.CANFrame) error {
+	b := n.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n.Sent++
+	for _, node := range b.nodes {
+		if node != n {
+			node.rx = append(node.rx, *f)
+		}
+	}
+	return nil
+}
+
+// Receive returns the next frame transmitted by another node.
+func (n *CANNode) Receive(f *drivers.CANFrame) (bool, error) {
+	b := n.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(n.rx) == 0 {
+		return false, nil
+	}
+	*f = n.rx[0]
+	n.rx = n.rx[1:]
+	return true, nil
+}
+
+// Pending returns the number of frames that have not been received yet.
+func (n *CANNode) Pending() int {
+	b := n.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(n.rx)
+}
@@ -60,8 +60,12 @@ func (d *I2CDevice8) writeRegister(r uint8, buf []byte) error {
 func (bus *I2CDevice8) Tx(w, r []byte) error {
 	switch len(w) {
 	case 0:
-		bus.c.Fatalf("i2c mock: need a write byte")
-		return nil
+		// A read without a register address, such as the probe of a bus
+		// scanner, starts at register 0.
+		if len(r) == 0 {
+			return bus.Err
+		}
+		return bus.readRegister(0, r)
 	case 1:
 		return bus.readRegister(w[0], r)
 	default:
@@ -0,0 +1,54 @@
+package tester
+
+// I2CDeviceLog is a mock I2C device that accepts every transaction and
+// records what is written to it, such as the commands and the image data sent
+// to a display controller. Reads return zeros.
+type I2CDeviceLog struct {
+	c Failer
+	// addr is the i2c device address.
+	addr uint8
+	// Writes are the bytes written by each transaction, including the
+	// register address if there is one.
+	Writes [][]byte
+	// If Err is non-nil, it will be returned as the error from the
+	// I2C methods.
+	Err error
+}
+
+// NewI2CDeviceLog returns a new mock I2C device that records the writes.
+func NewI2CDeviceLog(c Failer, addr uint8) *I2CDeviceLog {
+	return &I2CDeviceLog{
+		c:    c,
+		addr: addr,
+	}
+}
+
+// Addr returns the Device address.
+func (d *I2CDeviceLog) Addr() uint8 {
+	return d.addr
+}
+
+// ReadRegister implements I2C.ReadRegister.
+func (d *I2CDeviceLog) readRegister(r uint8, buf []byte) error {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return d.Err
+}
+
+// WriteRegister implements I2C.WriteRegister.
+func (d *I2CDeviceLog) writeRegister(r uint8, buf []byte) error {
+	d.Writes = append(d.Writes, append([]byte{r}, buf...))
+	return d.Err
+}
+
+// Tx implements I2C.Tx.
+func (d *I2CDeviceLog) Tx(w, r []byte) error {
+	if len(w) > 0 {
+		d.Writes = append(d.Writes, append([]byte(nil), w...))
+	}
+	for i := range r {
+		r[i] = 0
+	}
+	return d.Err
+}
@@ -0,0 +1,357 @@
+package tester
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/pixel"
+)
+
+// ErrRectangle is returned by the drawing methods of Display for a rectangle
+// that is not entirely on the display, as the TFT drivers do.
+var ErrRectangle = errors.New("tester: rectangle coordinates outside display area")
+
+// Display is a display in memory, to test code that draws on a display
+// without the hardware. It implements drivers.BitmapDisplayer and the bulk
+// drawing methods of the TFT drivers such as ili9341.
+//
+// Its memory behaves like the memory of a display controller: the colors are
+// reduced to its pixel format, the coordinates follow the rotation and the
+// vertical scrolling, and an SSD1306 only shows its buffer after Display is
+// called. Image returns what the screen shows, which CheckPNG compares with a
+// golden image.
+type Display struct {
+	width       int16 // of the panel, in Rotation0
+	height      int16
+	format      pixel.Format
+	memory      pixel.Image // memory of a TFT
+	buffer      []byte      // pages of an SSD1306, copied to pages by Display
+	pages       []byte      // memory of an SSD1306
+	rotation    drivers.Rotation
+	topFixed    int16
+	bottomFixed int16
+	scroll      int16
+
+	// Displays is the number of calls to Display.
+	Displays int
+}
+
+// NewDisplay returns a TFT display of width by height pixels in Rotation0,
+// whose memory is in a pixel format such as pixel.RGB565. What is drawn is
+// shown immediately.
+func NewDisplay(width, height int16, format pixel.Format) *Display {
+	return &Display{
+		width:  width,
+		height: height,
+		format: format,
+		memory: pixel.NewImage(format, width, height),
+	}
+}
+
+// NewSSD1306Display returns a monochrome display that works like the ssd1306
+// driver: pixels of any color but black are on, and they are kept in a buffer
+// of 8 rows high pages until Display is called.
+func NewSSD1306Display(width, height int16) *Display {
+	return &Display{
+		width:  width,
+		height: height,
+		format: pixel.Mono,
+		buffer: make([]byte, int(width)*int(height)/8),
+		pages:  make([]byte, int(width)*int(height)/8),
+	}
+}
+
+// Size implements drivers.Displayer.
+func (d *Display) Size() (x, y int16) {
+	switch d.rotation % 4 {
+	case drivers.Rotation90, drivers.Rotation270:
+		return d.height, d.width
+	}
+	return d.width, d.height
+}
+
+// SetPixel implements drivers.Displayer. Pixels outside the display are
+// ignored.
+func (d *Display) SetPixel(x, y int16, c color.RGBA) {
+	w, h := d.Size()
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+	x, y = d.xy(x, y)
+	if d.buffer == nil {
+		d.memory.Set(x, y, c)
+		return
+	}
+	i := int(x) + int(y/8)*int(d.width)
+	if c.R != 0 || c.G != 0 || c.B != 0 {
+		d.buffer[i] |= 1 << uint8(y%8)
+	} else {
+		d.buffer[i] &^= 1 << uint8(y%8)
+	}
+}
+
+// Display implements drivers.Displayer. It shows the buffer of an SSD1306.
+func (d *Display) Display() error {
+	d.Displays++
+	copy(d.pages, d.buffer)
+	return nil
+}
+
+// Buffer returns the memory of the display in the layout of the controller:
+// the pages of an SSD1306 as ssd1306.Device.GetBuffer, or the pixels in the
+// pixel format of a TFT.
+func (d *Display) Buffer() []byte {
+	if d.buffer != nil {
+		return d.buffer
+	}
+	return d.memory.Bytes()
+}
+
+// PixelFormat implements drivers.BitmapDisplayer.
+func (d *Display) PixelFormat() pixel.Format {
+	return d.format
+}
+
+// DrawBitmap implements drivers.BitmapDisplayer.
+func (d *Display) DrawBitmap(x, y int16, img pixel.Image) error {
+	if img.Format() != d.format {
+		return pixel.ErrFormat
+	}
+	w, h := img.Size()
+	if !d.inside(x, y, w, h) {
+		return ErrRectangle
+	}
+	for i := int16(0); i < h; i++ {
+		for j := int16(0); j < w; j++ {
+			d.SetPixel(x+j, y+i, img.Get(j, i))
+		}
+	}
+	return nil
+}
+
+// DrawRGBBitmap copies RGB565 pixels to the display, as the TFT drivers do.
+func (d *Display) DrawRGBBitmap(x, y int16, data []uint16, w, h int16) error {
+	if !d.inside(x, y, w, h) || len(data) < int(w)*int(h) {
+		return ErrRectangle
+	}
+	for i := range data[:int(w)*int(h)] {
+		v := data[i]
+		r, g, b := byte(v>>11), byte(v>>5&0x3F), byte(v&0x1F)
+		c := color.RGBA{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 0xFF}
+		d.SetPixel(x+int16(i%int(w)), y+int16(i/int(w)), c)
+	}
+	return nil
+}
+
+// DrawRGBBitmap8 copies RGB565 pixels in big endian bytes to the display, as
+// the TFT drivers do.
+func (d *Display) DrawRGBBitmap8(x, y int16, data []uint8, w, h int16) error {
+	if len(data) < int(w)*int(h)*2 {
+		return ErrRectangle
+	}
+	pixels := make([]uint16, int(w)*int(h))
+	for i := range pixels {
+		pixels[i] = uint16(data[i*2])<<8 | uint16(data[i*2+1])
+	}
+	return d.DrawRGBBitmap(x, y, pixels, w, h)
+}
+
+// FillRect implements drivers.BitmapDisplayer.
+func (d *Display) FillRect(x, y, width, height int16, c color.RGBA) error {
+	return d.FillRectangle(x, y, width, height, c)
+}
+
+// FillRectangle fills a rectangle with a color, as the TFT drivers do.
+func (d *Display) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	if !d.inside(x, y, width, height) {
+		return ErrRectangle
+	}
+	for i := int16(0); i < height; i++ {
+		for j := int16(0); j < width; j++ {
+			d.SetPixel(x+j, y+i, c)
+		}
+	}
+	return nil
+}
+
+// DrawFastHLine draws a horizontal line, as the TFT drivers do.
+func (d *Display) DrawFastHLine(x0, x1, y int16, c color.RGBA) error {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	return d.FillRectangle(x0, y, x1-x0+1, 1, c)
+}
+
+// DrawFastVLine draws a vertical line, as the TFT drivers do.
+func (d *Display) DrawFastVLine(x, y0, y1 int16, c color.RGBA) error {
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	return d.FillRectangle(x, y0, 1, y1-y0+1, c)
+}
+
+// FillScreen fills the display with a color.
+func (d *Display) FillScreen(c color.RGBA) {
+	w, h := d.Size()
+	d.FillRectangle(0, 0, w, h, c)
+}
+
+// SetRotation sets the rotation of the display. Like a display controller,
+// it doesn't change the memory, only how the next drawings are written to it.
+func (d *Display) SetRotation(rotation drivers.Rotation) error {
+	d.rotation = rotation % 8
+	return nil
+}
+
+// Rotation implements drivers.BitmapDisplayer.
+func (d *Display) Rotation() drivers.Rotation {
+	return d.rotation
+}
+
+// SetScrollArea sets the number of rows of the panel, in Rotation0, above and
+// below the area that scrolls vertically.
+func (d *Display) SetScrollArea(topFixedArea, bottomFixedArea int16) {
+	d.topFixed = topFixedArea
+	d.bottomFixed = bottomFixedArea
+}
+
+// SetScroll implements drivers.BitmapDisplayer. It sets the row of the memory
+// shown at the top of the scroll area.
+func (d *Display) SetScroll(line int16) {
+	d.scroll = line
+}
+
+// Image returns what the screen shows, as the panel in Rotation0: SSD1306
+// pixels are white on black.
+func (d *Display) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(d.width), int(d.height)))
+	for y := int16(0); y < d.height; y++ {
+		row := d.row(y)
+		for x := int16(0); x < d.width; x++ {
+			var c color.RGBA
+			if d.pages != nil {
+				c = color.RGBA{0, 0, 0, 0xFF}
+				if d.pages[int(x)+int(row/8)*int(d.width)]&(1<<uint8(row%8)) != 0 {
+					c = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+				}
+			} else {
+				c = d.memory.Get(x, row)
+			}
+			img.SetRGBA(int(x), int(y), c)
+		}
+	}
+	return img
+}
+
+// WritePNG writes what the screen shows as a PNG image.
+func (d *Display) WritePNG(w io.Writer) error {
+	return png.Encode(w, d.Image())
+}
+
+// CheckPNG compares what the screen shows with a golden PNG image. If the
+// image differs, the screen is written next to it with the extension
+// .got.png. If the file doesn't exist, it is created and the test fails, so
+// that the new golden image is checked before being committed.
+func (d *Display) CheckPNG(t Failer, filename string) {
+	got := d.Image()
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		if err := writePNG(filename, got); err != nil {
+			t.Fatalf("%v", err)
+			return
+		}
+		t.Fatalf("created golden image %s", filename)
+		return
+	}
+	if err != nil {
+		t.Fatalf("%v", err)
+		return
+	}
+	want, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+		return
+	}
+
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("%s: image is %v, screen is %v", filename, want.Bounds().Size(), got.Bounds().Size())
+		return
+	}
+	diff := 0
+	var first image.Point
+	for y := 0; y < got.Rect.Dy(); y++ {
+		for x := 0; x < got.Rect.Dx(); x++ {
+			w := color.RGBAModel.Convert(want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y))
+			if w != got.RGBAAt(x, y) {
+				if diff == 0 {
+					first = image.Pt(x, y)
+				}
+				diff++
+			}
+		}
+	}
+	if diff != 0 {
+		actual := strings.TrimSuffix(filename, ".png") + ".got.png"
+		if err := writePNG(actual, got); err != nil {
+			t.Fatalf("%v", err)
+			return
+		}
+		t.Fatalf("%s: %d pixels differ, the first at %v; the screen is in %s", filename, diff, first, actual)
+	}
+}
+
+// xy returns the coordinates in the panel of a pixel of the rotated display.
+// A mirrored rotation flips the horizontal axis before rotating.
+func (d *Display) xy(x, y int16) (int16, int16) {
+	if d.rotation >= drivers.Rotation0Mirror {
+		w, _ := d.Size()
+		x = w - 1 - x
+	}
+	switch d.rotation % 4 {
+	case drivers.Rotation90:
+		return d.width - 1 - y, x
+	case drivers.Rotation180:
+		return d.width - 1 - x, d.height - 1 - y
+	case drivers.Rotation270:
+		return y, d.height - 1 - x
+	}
+	return x, y
+}
+
+// row returns the row of the memory that is shown at a row of the panel.
+func (d *Display) row(y int16) int16 {
+	area := d.height - d.topFixed - d.bottomFixed
+	if y < d.topFixed || y >= d.height-d.bottomFixed || area <= 0 {
+		return y
+	}
+	offset := (y - d.topFixed + d.scroll - d.topFixed) % area
+	if offset < 0 {
+		offset += area
+	}
+	return d.topFixed + offset
+}
+
+// inside returns whether a rectangle is entirely on the display.
+func (d *Display) inside(x, y, width, height int16) bool {
+	w, h := d.Size()
+	return x >= 0 && y >= 0 && width > 0 && height > 0 && x+width <= w && y+height <= h
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
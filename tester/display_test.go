@@ -0,0 +1,154 @@
+package tester
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/pixel"
+)
+
+var (
+	red   = color.RGBA{0xFF, 0, 0, 0xFF}
+	black = color.RGBA{0, 0, 0, 0xFF}
+)
+
+// failures records the failures of CheckPNG.
+type failures []string
+
+func (f *failures) Fatalf(format string, a ...interface{}) {
+	*f = append(*f, fmt.Sprintf(format, a...))
+}
+
+func TestDisplayRotation(t *testing.T) {
+	c := qt.New(t)
+	// The panel position of the pixel drawn at 0,1 in each rotation.
+	tests := []struct {
+		rotation drivers.Rotation
+		x, y     int
+	}{
+		{drivers.Rotation0, 0, 1},
+		{drivers.Rotation90, 2, 0},
+		{drivers.Rotation180, 3, 1},
+		{drivers.Rotation270, 1, 2},
+		{drivers.Rotation0Mirror, 3, 1},
+		{drivers.Rotation90Mirror, 2, 2},
+		{drivers.Rotation180Mirror, 0, 1},
+		{drivers.Rotation270Mirror, 1, 0},
+	}
+	for _, test := range tests {
+		d := NewDisplay(4, 3, pixel.RGB565)
+		c.Assert(d.SetRotation(test.rotation), qt.IsNil)
+		c.Assert(d.Rotation(), qt.Equals, test.rotation)
+		w, h := d.Size()
+		if test.rotation%2 == 1 {
+			c.Assert([2]int16{w, h}, qt.Equals, [2]int16{3, 4})
+		} else {
+			c.Assert([2]int16{w, h}, qt.Equals, [2]int16{4, 3})
+		}
+		d.SetPixel(0, 1, red)
+		d.SetPixel(w, 0, red) // outside
+		img := d.Image()
+		c.Assert(img.RGBAAt(test.x, test.y), qt.Equals, red, qt.Commentf("rotation %d", test.rotation))
+	}
+
+	// The memory doesn't change with the rotation.
+	d := NewDisplay(4, 3, pixel.RGB565)
+	d.SetPixel(0, 0, red)
+	d.SetRotation(drivers.Rotation180)
+	c.Assert(d.Image().RGBAAt(0, 0), qt.Equals, red)
+	c.Assert(d.FillRectangle(2, 1, 2, 2, red), qt.IsNil)
+	c.Assert(d.Image().RGBAAt(0, 0), qt.Equals, red)
+	c.Assert(d.Image().RGBAAt(1, 1), qt.Equals, red)
+	c.Assert(d.Image().RGBAAt(2, 1), qt.Equals, black)
+}
+
+func TestDisplayFormat(t *testing.T) {
+	c := qt.New(t)
+	d := NewDisplay(2, 2, pixel.RGB565)
+	c.Assert(d.PixelFormat(), qt.Equals, pixel.RGB565)
+	d.SetPixel(1, 0, color.RGBA{0x12, 0x34, 0x56, 0xFF})
+	c.Assert(d.Buffer()[2:4], qt.DeepEquals, []byte{0x11, 0xAA})
+	c.Assert(d.Image().RGBAAt(1, 0), qt.Equals, color.RGBA{0x10, 0x34, 0x52, 0xFF})
+
+	c.Assert(d.DrawRGBBitmap(0, 1, []uint16{0xF800, 0x001F}, 2, 1), qt.IsNil)
+	c.Assert(d.Image().RGBAAt(0, 1), qt.Equals, red)
+	c.Assert(d.Image().RGBAAt(1, 1), qt.Equals, color.RGBA{0, 0, 0xFF, 0xFF})
+	c.Assert(d.DrawRGBBitmap8(0, 1, []byte{0x07, 0xE0}, 1, 1), qt.IsNil)
+	c.Assert(d.Image().RGBAAt(0, 1), qt.Equals, color.RGBA{0, 0xFF, 0, 0xFF})
+
+	img := pixel.NewImage(pixel.RGB565, 2, 2)
+	img.Fill(red)
+	c.Assert(d.DrawBitmap(0, 0, img), qt.IsNil)
+	c.Assert(d.Image().RGBAAt(1, 1), qt.Equals, red)
+	c.Assert(d.DrawBitmap(1, 0, img), qt.Equals, ErrRectangle)
+	c.Assert(d.DrawBitmap(0, 0, img.Convert(pixel.Mono)), qt.Equals, pixel.ErrFormat)
+	c.Assert(d.FillRect(0, 0, 3, 1, red), qt.Equals, ErrRectangle)
+
+	// Other formats have less colors.
+	d = NewDisplay(1, 1, pixel.Gray4)
+	d.FillScreen(red)
+	c.Assert(d.Image().RGBAAt(0, 0), qt.Equals, color.RGBA{0x44, 0x44, 0x44, 0xFF})
+}
+
+func TestDisplaySSD1306(t *testing.T) {
+	c := qt.New(t)
+	d := NewSSD1306Display(4, 16)
+	w, h := d.Size()
+	c.Assert([2]int16{w, h}, qt.Equals, [2]int16{4, 16})
+
+	// Dark colors are on too.
+	d.SetPixel(1, 9, color.RGBA{0, 0, 1, 0xFF})
+	d.SetPixel(2, 0, red)
+	d.SetPixel(2, 0, black)
+	c.Assert(d.Buffer(), qt.DeepEquals, []byte{0, 0, 0, 0, 0, 0x02, 0, 0})
+
+	// Nothing is shown until Display.
+	c.Assert(d.Image().RGBAAt(1, 9), qt.Equals, black)
+	c.Assert(d.Display(), qt.IsNil)
+	c.Assert(d.Displays, qt.Equals, 1)
+	c.Assert(d.Image().RGBAAt(1, 9), qt.Equals, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+}
+
+func TestDisplayScroll(t *testing.T) {
+	c := qt.New(t)
+	d := NewDisplay(1, 8, pixel.RGB565)
+	for y := int16(0); y < 8; y++ {
+		d.SetPixel(0, y, color.RGBA{uint8(y * 8), 0, 0, 0xFF})
+	}
+	d.SetScrollArea(2, 2)
+	d.SetScroll(3)
+	var rows []uint8
+	img := d.Image()
+	for y := 0; y < 8; y++ {
+		rows = append(rows, img.RGBAAt(0, y).R/8)
+	}
+	c.Assert(rows, qt.DeepEquals, []uint8{0, 1, 3, 4, 5, 2, 6, 7})
+}
+
+func TestDisplayPNG(t *testing.T) {
+	c := qt.New(t)
+	golden := filepath.Join(t.TempDir(), "screen.png")
+	d := NewDisplay(8, 4, pixel.RGB565)
+	d.FillScreen(red)
+
+	// The golden image is created.
+	var f failures
+	d.CheckPNG(&f, golden)
+	c.Assert(f, qt.DeepEquals, failures{"created golden image " + golden})
+	f = nil
+	d.CheckPNG(&f, golden)
+	c.Assert(f, qt.IsNil)
+
+	d.SetPixel(5, 2, black)
+	d.CheckPNG(&f, golden)
+	got := filepath.Join(filepath.Dir(golden), "screen.got.png")
+	c.Assert(f, qt.DeepEquals, failures{golden + ": 1 pixels differ, the first at (5,2); the screen is in " + got})
+	file, err := os.Open(got)
+	c.Assert(err, qt.IsNil)
+	defer file.Close()
+}
@@ -0,0 +1,115 @@
+package tester
+
+// DS18B20 function commands.
+const (
+	ds18b20Convert         = 0x44
+	ds18b20WriteScratchpad = 0x4e
+	ds18b20ReadScratchpad  = 0xbe
+	ds18b20CopyScratchpad  = 0x48
+	ds18b20Recall          = 0xb8
+	ds18b20ReadPower       = 0xb4
+)
+
+// DS18B20 is a simulated DS18B20 thermometer for a OneWireBus.
+type DS18B20 struct {
+	// Temperature is measured by the next conversion, in milli °C.
+	Temperature int32
+
+	// Parasite is set for a device without power supply, which only
+	// converts when the master applies a strong pull-up.
+	Parasite bool
+
+	rom        [8]byte
+	scratchpad [9]byte
+	eeprom     [3]byte // TH, TL and configuration
+	cmd        byte
+	n          int
+}
+
+// NewDS18B20 returns a thermometer with a 48-bit serial number, in its
+// power-up state.
+func NewDS18B20(serial uint64) *DS18B20 {
+	d := &DS18B20{
+		rom:        romID(0x28, serial),
+		scratchpad: [9]byte{0x50, 0x05, 0x4b, 0x46, 0x7f, 0xff, 0x0c, 0x10},
+		eeprom:     [3]byte{0x4b, 0x46, 0x7f},
+	}
+	d.scratchpad[8] = crc8(d.scratchpad[:8])
+	return d
+}
+
+// ROM implements OneWireDevice.
+func (d *DS18B20) ROM() [8]byte {
+	return d.rom
+}
+
+// Scratchpad returns the 9 bytes of the scratchpad.
+func (d *DS18B20) Scratchpad() [9]byte {
+	return d.scratchpad
+}
+
+// Reset implements OneWireDevice. A conversion of a parasite powered device
+// that was started without strong pull-up is lost.
+func (d *DS18B20) Reset() {
+	d.cmd = 0
+}
+
+// Write implements OneWireDevice.
+func (d *DS18B20) Write(b byte) {
+	if d.cmd == 0 {
+		d.cmd = b
+		d.n = 0
+		switch b {
+		case ds18b20Convert:
+			if !d.Parasite {
+				d.convert()
+			}
+		case ds18b20CopyScratchpad:
+			copy(d.eeprom[:], d.scratchpad[2:5])
+		case ds18b20Recall:
+			copy(d.scratchpad[2:5], d.eeprom[:])
+			d.scratchpad[8] = crc8(d.scratchpad[:8])
+		}
+		return
+	}
+	if d.cmd == ds18b20WriteScratchpad && d.n < 3 {
+		d.scratchpad[2+d.n] = b
+		d.n++
+		d.scratchpad[4] |= 0x1f
+		d.scratchpad[8] = crc8(d.scratchpad[:8])
+	}
+}
+
+// Read implements OneWireDevice.
+func (d *DS18B20) Read() byte {
+	switch d.cmd {
+	case ds18b20ReadScratchpad:
+		if d.n < len(d.scratchpad) {
+			d.n++
+			return d.scratchpad[d.n-1]
+		}
+	case ds18b20ReadPower:
+		if d.Parasite {
+			return 0
+		}
+	}
+	// Conversions are done immediately.
+	return 0xff
+}
+
+// StrongPullUp powers a conversion of a parasite powered device.
+func (d *DS18B20) StrongPullUp() {
+	if d.cmd == ds18b20Convert {
+		d.convert()
+	}
+}
+
+// convert stores the temperature with the configured resolution.
+func (d *DS18B20) convert() {
+	raw := d.Temperature * 16 / 1000
+	resolution := d.scratchpad[4] >> 5 & 3 // 0 for 9 bits to 3 for 12 bits
+	raw &^= 1<<(3-resolution) - 1
+	d.scratchpad[0] = byte(raw)
+	d.scratchpad[1] = byte(raw >> 8)
+	d.scratchpad[8] = crc8(d.scratchpad[:8])
+}
@@ -0,0 +1,95 @@
+package tester
+
+// DS2413 function commands.
+const (
+	ds2413AccessRead  = 0xf5
+	ds2413AccessWrite = 0x5a
+)
+
+// DS2413 is a simulated DS2413 dual channel addressable switch for a
+// OneWireBus.
+type DS2413 struct {
+	// Latches are the output latches of PIOA (bit 0) and PIOB (bit 1). A
+	// cleared bit turns the output transistor on, which pulls the pin low.
+	Latches uint8
+
+	// Inputs are the levels that the external circuit drives the pins to,
+	// with the output transistors off.
+	Inputs uint8
+
+	rom [8]byte
+	cmd byte
+	n   int
+	buf [2]byte
+	out []byte
+}
+
+// NewDS2413 returns a switch with a 48-bit serial number, with the outputs
+// off and the pins pulled high.
+func NewDS2413(serial uint64) *DS2413 {
+	return &DS2413{
+		Latches: 3,
+		Inputs:  3,
+		rom:     romID(0x3a, serial),
+	}
+}
+
+// ROM implements OneWireDevice.
+func (d *DS2413) ROM() [8]byte {
+	return d.rom
+}
+
+// Reset implements OneWireDevice.
+func (d *DS2413) Reset() {
+	d.cmd = 0
+	d.out = nil
+}
+
+// Write implements OneWireDevice. The data of a PIO access write is followed
+// by its inverse, and may be repeated.
+func (d *DS2413) Write(b byte) {
+	if d.cmd == 0 {
+		d.cmd = b
+		d.n = 0
+		return
+	}
+	if d.cmd != ds2413AccessWrite {
+		return
+	}
+	d.buf[d.n] = b
+	d.n++
+	if d.n < 2 {
+		return
+	}
+	d.n = 0
+	if d.buf[1] != ^d.buf[0] {
+		// The device stops responding until the next reset.
+		d.cmd = 0xff
+		return
+	}
+	d.Latches = d.buf[0] & 3
+	d.out = []byte{0xaa, d.status()}
+}
+
+// Read implements OneWireDevice.
+func (d *DS2413) Read() byte {
+	switch d.cmd {
+	case ds2413AccessRead:
+		return d.status()
+	case ds2413AccessWrite:
+		if len(d.out) > 0 {
+			b := d.out[0]
+			d.out = d.out[1:]
+			return b
+		}
+	}
+	return 0xff
+}
+
+// status returns the pin and latch states in the lower nibble, and their
+// inverse in the upper one.
+func (d *DS2413) status() byte {
+	pins := d.Latches & d.Inputs
+	s := pins&1 | d.Latches&1<<1 | pins&2<<1 | d.Latches&2<<2
+	return s | ^s<<4
+}
@@ -0,0 +1,150 @@
+package tester
+
+// DS2431 function commands.
+const (
+	ds2431WriteScratchpad = 0x0f
+	ds2431ReadScratchpad  = 0xaa
+	ds2431CopyScratchpad  = 0x55
+	ds2431ReadMemory      = 0xf0
+)
+
+// DS2431MemorySize is the size of the memory of a DS2431: 128 bytes of data
+// and 16 bytes of registers.
+const DS2431MemorySize = 0x90
+
+// DS2431 is a simulated DS2431 1024-bit EEPROM for a OneWireBus. Data is
+// written to an 8-byte scratchpad and then copied to a row of the memory.
+// Write protection of the memory pages isn't simulated.
+type DS2431 struct {
+	// Memory holds the data and the registers.
+	Memory [DS2431MemorySize]byte
+
+	rom        [8]byte
+	scratchpad [8]byte
+	ta         uint16 // target address
+	es         byte   // ending offset and status
+	cmd        byte
+	n          int
+	out        []byte // bytes that are read next
+	buf        [16]byte
+	copied     bool
+}
+
+// Status bits of the E/S register.
+const (
+	ds2431PF = 0x20 // partial flag
+	ds2431AA = 0x80 // authorization accepted
+)
+
+// NewDS2431 returns an EEPROM with a 48-bit serial number, with all bits of
+// the memory set.
+func NewDS2431(serial uint64) *DS2431 {
+	d := &DS2431{rom: romID(0x2d, serial)}
+	for i := range d.Memory {
+		d.Memory[i] = 0xff
+	}
+	return d
+}
+
+// ROM implements OneWireDevice.
+func (d *DS2431) ROM() [8]byte {
+	return d.rom
+}
+
+// SupportsOverdrive returns true, as the DS2431 supports overdrive speed.
+func (d *DS2431) SupportsOverdrive() bool {
+	return true
+}
+
+// Reset implements OneWireDevice.
+func (d *DS2431) Reset() {
+	d.cmd = 0
+	d.out = nil
+	d.copied = false
+}
+
+// Write implements OneWireDevice.
+func (d *DS2431) Write(b byte) {
+	if d.cmd == 0 {
+		d.cmd = b
+		d.n = 0
+		d.buf[0] = b
+		if b == ds2431ReadScratchpad {
+			d.out = d.readScratchpad()
+		}
+		return
+	}
+	d.n++
+	if d.n < len(d.buf) {
+		d.buf[d.n] = b
+	}
+	switch d.cmd {
+	case ds2431WriteScratchpad:
+		switch {
+		case d.n == 2:
+			d.ta = uint16(d.buf[1]) | uint16(d.buf[2])<<8
+			d.es = ds2431PF | byte(d.ta&7)
+		case d.n > 2:
+			offset := int(d.ta&7) + d.n - 3
+			if offset > 7 || d.ta >= DS2431MemorySize {
+				return
+			}
+			d.scratchpad[offset] = b
+			d.es = d.es&^7 | byte(offset)
+			if offset == 7 {
+				// Writing to the end of the row sends the CRC.
+				d.es &^= ds2431PF
+				d.out = d.crc(d.buf[:d.n+1])
+			}
+		}
+	case ds2431CopyScratchpad:
+		if d.n == 3 {
+			ta := uint16(d.buf[1]) | uint16(d.buf[2])<<8
+			if ta != d.ta || d.buf[3] != d.es || d.es&ds2431PF != 0 || d.es&7 != 7 {
+				return
+			}
+			row := d.ta &^ 7
+			copy(d.Memory[row:row+8], d.scratchpad[:])
+			d.es |= ds2431AA
+			d.copied = true
+		}
+	case ds2431ReadMemory:
+		if d.n == 2 {
+			ta := int(d.buf[1]) | int(d.buf[2])<<8
+			if ta < DS2431MemorySize {
+				d.out = d.Memory[ta:]
+			}
+		}
+	}
+}
+
+// Read implements OneWireDevice.
+func (d *DS2431) Read() byte {
+	if d.copied {
+		// Alternating bits signal that the copy is done.
+		return 0xaa
+	}
+	if len(d.out) == 0 {
+		return 0xff
+	}
+	b := d.out[0]
+	d.out = d.out[1:]
+	return b
+}
+
+// readScratchpad returns TA1, TA2, E/S, the data and the CRC.
+func (d *DS2431) readScratchpad() []byte {
+	start := int(d.ta & 7)
+	end := int(d.es & 7)
+	msg := []byte{ds2431ReadScratchpad, byte(d.ta), byte(d.ta >> 8), d.es}
+	if end >= start {
+		msg = append(msg, d.scratchpad[start:end+1]...)
+	}
+	return append(msg[1:], d.crc(msg)...)
+}
+
+// crc returns the inverted CRC16 of data, LSB first.
+func (d *DS2431) crc(data []byte) []byte {
+	crc := ^crc16(0, data)
+	return []byte{byte(crc), byte(crc >> 8)}
+}
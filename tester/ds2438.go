@@ -0,0 +1,124 @@
+package tester
+
+// DS2438 function commands.
+const (
+	ds2438ConvertT        = 0x44
+	ds2438ConvertV        = 0xb4
+	ds2438Recall          = 0xb8
+	ds2438ReadScratchpad  = 0xbe
+	ds2438WriteScratchpad = 0x4e
+	ds2438CopyScratchpad  = 0x48
+)
+
+// Bits of the configuration register of a DS2438.
+const (
+	ds2438ConfigIAD = 0x01
+	ds2438ConfigAD  = 0x08
+)
+
+// DS2438 is a simulated DS2438 smart battery monitor for a OneWireBus.
+type DS2438 struct {
+	// Temperature is measured by the next temperature conversion, in
+	// milli °C.
+	Temperature int32
+
+	// VDD and VAD are the voltages of the inputs of the A/D converter, in
+	// mV.
+	VDD int32
+	VAD int32
+
+	// Current is the value of the current register, which is updated
+	// continuously while the current A/D converter is enabled.
+	Current int16
+
+	// Pages holds the 8 pages of memory. The first bytes of page 0 are
+	// the configuration and the registers of the measurements.
+	Pages [8][8]byte
+
+	rom        [8]byte
+	scratchpad [8][8]byte
+	cmd        byte
+	page       byte
+	n          int
+}
+
+// NewDS2438 returns a battery monitor with a 48-bit serial number, with the
+// current A/D converter enabled and the VDD input selected.
+func NewDS2438(serial uint64) *DS2438 {
+	d := &DS2438{rom: romID(0x26, serial)}
+	d.Pages[0][0] = 0x0f
+	return d
+}
+
+// ROM implements OneWireDevice.
+func (d *DS2438) ROM() [8]byte {
+	return d.rom
+}
+
+// Reset implements OneWireDevice.
+func (d *DS2438) Reset() {
+	d.cmd = 0
+}
+
+// Write implements OneWireDevice.
+func (d *DS2438) Write(b byte) {
+	if d.cmd == 0 {
+		d.cmd = b
+		d.n = 0
+		switch b {
+		case ds2438ConvertT:
+			t := int16(d.Temperature*32/1000) << 3
+			d.Pages[0][1] = byte(t)
+			d.Pages[0][2] = byte(t >> 8)
+		case ds2438ConvertV:
+			v := d.VAD / 10
+			if d.Pages[0][0]&ds2438ConfigAD != 0 {
+				v = d.VDD / 10
+			}
+			d.Pages[0][3] = byte(v)
+			d.Pages[0][4] = byte(v>>8) & 3
+		}
+		return
+	}
+	if d.n == 0 {
+		d.n++
+		d.page = b & 7
+		switch d.cmd {
+		case ds2438Recall:
+			if d.page == 0 && d.Pages[0][0]&ds2438ConfigIAD != 0 {
+				d.Pages[0][5] = byte(d.Current)
+				d.Pages[0][6] = byte(d.Current >> 8)
+			}
+			d.scratchpad[d.page] = d.Pages[d.page]
+		case ds2438CopyScratchpad:
+			sp := &d.scratchpad[d.page]
+			if d.page == 0 {
+				// Only the configuration and threshold are
+				// writable.
+				d.Pages[0][0] = d.Pages[0][0]&0x70 | sp[0]&0x0f
+				d.Pages[0][7] = sp[7]
+			} else {
+				d.Pages[d.page] = *sp
+			}
+		}
+		return
+	}
+	if d.cmd == ds2438WriteScratchpad && d.n <= 8 {
+		d.scratchpad[d.page][d.n-1] = b
+		d.n++
+	}
+}
+
+// Read implements OneWireDevice.
+func (d *DS2438) Read() byte {
+	if d.cmd != ds2438ReadScratchpad || d.n == 0 || d.n > 9 {
+		// Conversions are done immediately.
+		return 0xff
+	}
+	sp := d.scratchpad[d.page][:]
+	d.n++
+	if d.n == 10 {
+		return crc8(sp)
+	}
+	return sp[d.n-2]
+}
@@ -0,0 +1,192 @@
+package tester
+
+import "errors"
+
+// DS2482 commands, registers and bits.
+const (
+	ds2482DeviceReset   = 0xf0
+	ds2482SetPointer    = 0xe1
+	ds2482WriteConfig   = 0xd2
+	ds2482ChannelSelect = 0xc3
+	ds2482WireReset     = 0xb4
+	ds2482SingleBit     = 0x87
+	ds2482WriteByte     = 0xa5
+	ds2482ReadByte      = 0x96
+	ds2482Triplet       = 0x78
+
+	ds2482RegStatus  = 0xf0
+	ds2482RegData    = 0xe1
+	ds2482RegChannel = 0xd2
+	ds2482RegConfig  = 0xc3
+
+	ds2482StatusPPD = 0x02
+	ds2482StatusLL  = 0x08
+	ds2482StatusRST = 0x10
+	ds2482StatusSBR = 0x20
+	ds2482StatusTSB = 0x40
+	ds2482StatusDIR = 0x80
+
+	ds2482ConfigSPU = 0x04
+	ds2482Config1WS = 0x08
+)
+
+var errDS2482Command = errors.New("ds2482: invalid command")
+
+// ds2482Channels are the codes to select the channels of a DS2482-800, and
+// ds2482ChannelsRead those that are read back from the channel register.
+var (
+	ds2482Channels     = [8]byte{0xf0, 0xe1, 0xd2, 0xc3, 0xb4, 0xa5, 0x96, 0x87}
+	ds2482ChannelsRead = [8]byte{0xb8, 0xb1, 0xaa, 0xa3, 0x9c, 0x95, 0x8e, 0x87}
+)
+
+// DS2482 is a simulated DS2482-100 or DS2482-800 I2C to 1-Wire bridge, with
+// a OneWireBus on each channel. It can be added to an I2CBus.
+type DS2482 struct {
+	// Commands counts the 1-Wire commands: resets, bits, bytes and
+	// triplets.
+	Commands int
+
+	addr    uint8
+	buses   []*OneWireBus
+	channel int
+	status  byte
+	config  byte
+	data    byte
+	pointer byte
+}
+
+// NewDS2482 returns a bridge at an I2C address. A DS2482-100 has one bus and
+// a DS2482-800 eight.
+func NewDS2482(addr uint8, buses ...*OneWireBus) *DS2482 {
+	return &DS2482{
+		addr:    addr,
+		buses:   buses,
+		status:  ds2482StatusRST | ds2482StatusLL,
+		pointer: ds2482RegStatus,
+	}
+}
+
+// Addr implements I2CDevice.
+func (d *DS2482) Addr() uint8 {
+	return d.addr
+}
+
+func (d *DS2482) readRegister(r uint8, buf []byte) error {
+	return errDS2482Command
+}
+
+func (d *DS2482) writeRegister(r uint8, buf []byte) error {
+	return errDS2482Command
+}
+
+// Tx executes a command and then reads the register of the read pointer.
+// The 1-Wire commands complete immediately.
+func (d *DS2482) Tx(w, r []byte) error {
+	if len(w) > 0 {
+		if err := d.command(w); err != nil {
+			return err
+		}
+	}
+	for i := range r {
+		r[i] = d.register()
+	}
+	return nil
+}
+
+func (d *DS2482) command(w []byte) error {
+	bus := d.buses[d.channel]
+	switch w[0] {
+	case ds2482DeviceReset, ds2482WireReset, ds2482ReadByte:
+	default:
+		if len(w) < 2 {
+			return errDS2482Command
+		}
+	}
+	d.pointer = ds2482RegStatus
+	switch w[0] {
+	case ds2482DeviceReset:
+		d.config = 0
+		d.channel = 0
+		d.status = ds2482StatusRST | ds2482StatusLL
+		for _, bus := range d.buses {
+			bus.SetOverdrive(false)
+		}
+		return nil
+	case ds2482SetPointer:
+		d.pointer = w[1]
+		return nil
+	case ds2482WriteConfig:
+		if w[1]>>4 != ^w[1]&0x0f {
+			return errDS2482Command
+		}
+		d.config = w[1] & 0x0f
+		d.status &^= ds2482StatusRST
+		d.pointer = ds2482RegConfig
+		bus.SetOverdrive(d.config&ds2482Config1WS != 0)
+		return nil
+	case ds2482ChannelSelect:
+		for i, code := range ds2482Channels[:len(d.buses)] {
+			if code == w[1] {
+				d.channel = i
+				d.buses[i].SetOverdrive(d.config&ds2482Config1WS != 0)
+				d.pointer = ds2482RegChannel
+				return nil
+			}
+		}
+		return errDS2482Command
+	}
+
+	d.status &^= ds2482StatusPPD | ds2482StatusSBR | ds2482StatusTSB | ds2482StatusDIR
+	switch w[0] {
+	case ds2482WireReset:
+		if presence, _ := bus.Reset(); presence {
+			d.status |= ds2482StatusPPD
+		}
+	case ds2482SingleBit:
+		bit := w[1] >> 7
+		if bit == 1 {
+			bit = bus.ReadBit()
+		} else {
+			bus.WriteBit(0)
+		}
+		d.status |= bit * ds2482StatusSBR
+	case ds2482WriteByte:
+		if d.config&ds2482ConfigSPU != 0 {
+			bus.WritePower(w[1])
+			d.config &^= ds2482ConfigSPU
+		} else {
+			bus.Write(w[1])
+		}
+	case ds2482ReadByte:
+		d.data = bus.Read()
+	case ds2482Triplet:
+		dir := w[1] >> 7
+		bit := bus.ReadBit()
+		cmp := bus.ReadBit()
+		if bit != cmp {
+			dir = bit
+		}
+		if bit == 1 && cmp == 1 {
+			dir = 1
+		} else {
+			bus.WriteBit(dir)
+		}
+		d.status |= bit*ds2482StatusSBR | cmp*ds2482StatusTSB | dir*ds2482StatusDIR
+	default:
+		return errDS2482Command
+	}
+	d.Commands++
+	return nil
+}
+
+func (d *DS2482) register() byte {
+	switch d.pointer {
+	case ds2482RegData:
+		return d.data
+	case ds2482RegChannel:
+		return ds2482ChannelsRead[d.channel]
+	case ds2482RegConfig:
+		return d.config
+	}
+	return d.status
+}
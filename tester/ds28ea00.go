@@ -0,0 +1,95 @@
+package tester
+
+// DS28EA00 chain command and control bytes.
+const (
+	ds28ea00Chain     = 0x99
+	ds28ea00ChainOff  = 0x3c
+	ds28ea00ChainOn   = 0x5a
+	ds28ea00ChainDone = 0x96
+)
+
+// Chain states of a DS28EA00.
+const (
+	chainOff = iota
+	chainOn
+	chainDone
+)
+
+// DS28EA00 is a simulated DS28EA00 thermometer for a OneWireBus. The
+// thermometer works as a DS18B20, and the devices can be connected in a
+// chain, from the PIOB output of a device to the PIOA input of the next, to
+// detect their physical sequence.
+type DS28EA00 struct {
+	DS18B20
+
+	prev     *DS28EA00
+	chain    int
+	chainCmd int // bytes of a chain command received
+	control  byte
+	ok       bool
+}
+
+// NewDS28EA00 returns a thermometer with a 48-bit serial number, whose
+// enable input is connected to the output of prev. The first device of a
+// chain has a nil prev.
+func NewDS28EA00(serial uint64, prev *DS28EA00) *DS28EA00 {
+	d := &DS28EA00{
+		DS18B20: *NewDS18B20(serial),
+		prev:    prev,
+	}
+	d.rom = romID(0x42, serial)
+	return d
+}
+
+// ConditionalReadROM returns whether the device answers a conditional read
+// ROM command: when the chain is on and the previous device is done.
+func (d *DS28EA00) ConditionalReadROM() bool {
+	return d.chain == chainOn && (d.prev == nil || d.prev.chain == chainDone)
+}
+
+// Reset implements OneWireDevice.
+func (d *DS28EA00) Reset() {
+	d.chainCmd = 0
+	d.DS18B20.Reset()
+}
+
+// Write implements OneWireDevice.
+func (d *DS28EA00) Write(b byte) {
+	switch {
+	case d.chainCmd == 0 && d.cmd == 0 && b == ds28ea00Chain:
+		d.chainCmd = 1
+	case d.chainCmd == 0:
+		d.DS18B20.Write(b)
+	case d.chainCmd == 1:
+		d.control = b
+		d.chainCmd++
+	case d.chainCmd == 2:
+		d.chainCmd++
+		d.ok = b == ^d.control
+		if !d.ok {
+			return
+		}
+		switch d.control {
+		case ds28ea00ChainOff:
+			d.chain = chainOff
+		case ds28ea00ChainOn:
+			d.chain = chainOn
+		case ds28ea00ChainDone:
+			d.chain = chainDone
+		default:
+			d.ok = false
+		}
+	}
+}
+
+// Read implements OneWireDevice. A valid chain command is confirmed with
+// 0xAA.
+func (d *DS28EA00) Read() byte {
+	switch {
+	case d.chainCmd == 0:
+		return d.DS18B20.Read()
+	case d.chainCmd == 3 && d.ok:
+		return 0xaa
+	}
+	return 0xff
+}
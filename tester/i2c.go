@@ -1,11 +1,23 @@
 package tester
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNACK is returned by an I2CBus with NACK set for an address without a
+// device.
+var ErrNACK = errors.New("tester: I2C address not acknowledged")
 
 // I2CBus implements the I2C interface in memory for testing.
 type I2CBus struct {
 	c       Failer
 	devices []I2CDevice
+
+	// If NACK is set, a transaction with an address that has no device
+	// returns ErrNACK, like a real bus does when it is scanned, instead of
+	// failing the test.
+	NACK bool
 }
 
 // NewI2CBus returns an I2CBus mock I2C instance that uses c to flag errors
@@ -48,16 +60,26 @@ func (bus *I2CBus) WriteRegister(addr uint8, r uint8, buf []byte) error {
 
 // Tx implements I2C.Tx.
 func (bus *I2CBus) Tx(addr uint16, w, r []byte) error {
+	if bus.NACK && bus.device(uint8(addr)) == nil {
+		return ErrNACK
+	}
 	return bus.FindDevice(uint8(addr)).Tx(w, r)
 }
 
 // FindDevice returns the device with the given address.
 func (bus *I2CBus) FindDevice(addr uint8) I2CDevice {
+	if dev := bus.device(addr); dev != nil {
+		return dev
+	}
+	bus.c.Fatalf("invalid device addr %#x passed to i2c bus", addr)
+	panic("unreachable")
+}
+
+func (bus *I2CBus) device(addr uint8) I2CDevice {
 	for _, dev := range bus.devices {
 		if dev.Addr() == addr {
 			return dev
 		}
 	}
-	bus.c.Fatalf("invalid device addr %#x passed to i2c bus", addr)
-	panic("unreachable")
+	return nil
 }
@@ -0,0 +1,535 @@
+package tester
+
+import "tinygo.org/x/drivers"
+
+// MCP2515 registers, bits and SPI instructions used by the emulator.
+const (
+	mcpCANSTAT  = 0x0e
+	mcpCANCTRL  = 0x0f
+	mcpTEC      = 0x1c
+	mcpREC      = 0x1d
+	mcpRXM0SIDH = 0x20
+	mcpCANINTE  = 0x2b
+	mcpCANINTF  = 0x2c
+	mcpEFLG     = 0x2d
+	mcpTXB0CTRL = 0x30
+	mcpRXB0CTRL = 0x60
+
+	mcpModeNormal     = 0x00
+	mcpModeLoopback   = 0x40
+	mcpModeListenOnly = 0x60
+	mcpModeConfig     = 0x80
+	mcpModeMask       = 0xe0
+	mcpABAT           = 0x10
+
+	mcpTXREQ = 0x08
+	mcpTXERR = 0x10
+	mcpABTF  = 0x40
+	mcpEXIDE = 0x08
+	mcpSRR   = 0x10
+	mcpRTR   = 0x40
+	mcpBUKT  = 0x04
+	mcpRXRTR = 0x08
+
+	mcpRX0IF = 0x01
+	mcpRX1IF = 0x02
+	mcpTX0IF = 0x04
+	mcpERRIF = 0x20
+
+	mcpEflgRx1ovr = 0x80
+	mcpEflgRx0ovr = 0x40
+	mcpEflgTxbo   = 0x20
+	mcpEflgTxep   = 0x10
+	mcpEflgRxep   = 0x08
+	mcpEflgTxwar  = 0x04
+	mcpEflgRxwar  = 0x02
+	mcpEflgEwarn  = 0x01
+
+	mcpInstrWrite      = 0x02
+	mcpInstrRead       = 0x03
+	mcpInstrBitMod     = 0x05
+	mcpInstrReadStatus = 0xa0
+	mcpInstrRxStatus   = 0xb0
+	mcpInstrReset      = 0xc0
+)
+
+// MCP2515 emulates the registers of an MCP2515 CAN controller as seen over
+// SPI. It implements drivers.SPI, and its Set method controls the chip select
+// line, so it can be used as both the bus and the chip select pin of the
+// mcp2515 driver.
+//
+// All SPI instructions are supported. Frames are transmitted as soon as they
+// are requested: in normal mode they are appended to Sent, and in loopback
+// mode they are received again. Frames from other nodes can be added with
+// Receive. Acceptance filters, masks and rollover are applied to received
+// frames like the real chip does.
+type MCP2515 struct {
+	// Sent holds the frames that were transmitted in normal mode.
+	Sent []drivers.CANFrame
+
+	// FailTx makes transmissions fail as if no other node acknowledged them.
+	// Every attempt raises the transmit error counter by 8, until the
+	// controller goes bus-off.
+	FailTx bool
+
+	regs     [128]byte
+	tec      int
+	rec      int
+	selected bool
+	cmd      byte
+	n        int // number of bytes received in the current instruction
+	addr     byte
+	mask     byte
+}
+
+// NewMCP2515 returns an emulated MCP2515 in its reset state.
+func NewMCP2515() *MCP2515 {
+	m := &MCP2515{}
+	m.reset()
+	return m
+}
+
+// Interrupt returns whether the INT pin is active, which is the case if an
+// enabled interrupt flag is set.
+func (m *MCP2515) Interrupt() bool {
+	return m.regs[mcpCANINTE]&m.regs[mcpCANINTF] != 0
+}
+
+// Register returns the value of a register.
+func (m *MCP2515) Register(addr byte) byte {
+	return m.regs[addr&0x7f]
+}
+
+// SetErrorCounters sets the transmit and receive error counters and updates
+// the error flags. A transmit error count above 255 puts the controller in
+// the bus-off state.
+func (m *MCP2515) SetErrorCounters(tec, rec int) {
+	m.tec = tec
+	m.rec = rec
+	m.updateErrors()
+}
+
+// Receive lets the controller receive a frame from another node on the bus.
+// It returns false if the frame was not stored in a receive buffer, because
+// the controller is not listening, no filter matched or the receive buffer
+// was full.
+func (m *MCP2515) Receive(f drivers.CANFrame) bool {
+	switch m.mode() {
+	case mcpModeNormal, mcpModeListenOnly:
+		return m.receive(f)
+	}
+	return false
+}
+
+// Set changes the chip select line. The controller is selected when it is low.
+func (m *MCP2515) Set(high bool) {
+	if high && m.selected {
+		m.finish()
+	}
+	m.selected = !high
+	m.n = 0
+}
+
+// High deselects the controller.
+func (m *MCP2515) High() {
+	m.Set(true)
+}
+
+// Low selects the controller.
+func (m *MCP2515) Low() {
+	m.Set(false)
+}
+
+// Tx implements drivers.SPI.
+func (m *MCP2515) Tx(w, r []byte) error {
+	n := len(w)
+	if len(r) > n {
+		n = len(r)
+	}
+	for i := 0; i < n; i++ {
+		var b byte
+		if i < len(w) {
+			b = w[i]
+		}
+		b = m.exchange(b)
+		if i < len(r) {
+			r[i] = b
+		}
+	}
+	return nil
+}
+
+// Transfer implements drivers.SPI.
+func (m *MCP2515) Transfer(b byte) (byte, error) {
+	return m.exchange(b), nil
+}
+
+func (m *MCP2515) exchange(b byte) byte {
+	if !m.selected {
+		return 0xff
+	}
+	n := m.n
+	m.n++
+	if n == 0 {
+		m.cmd = b
+		switch {
+		case b == mcpInstrReset:
+			m.reset()
+		case b&0xf8 == 0x40: // LOAD TX BUFFER
+			m.addr = mcpTXB0CTRL + 0x10*(b>>1&3) + 1
+			if b&1 != 0 {
+				m.addr += 5
+			}
+		case b&0xf8 == 0x80: // RTS
+			for i := byte(0); i < 3; i++ {
+				if b&(1<<i) != 0 {
+					m.regs[mcpTXB0CTRL+0x10*i] |= mcpTXREQ
+				}
+			}
+		case b&0xf8 == 0x90: // READ RX BUFFER
+			m.addr = mcpRXB0CTRL + 0x10*(b>>2&1) + 1
+			if b&2 != 0 {
+				m.addr += 5
+			}
+		}
+		return 0
+	}
+	switch {
+	case m.cmd == mcpInstrRead:
+		if n == 1 {
+			m.addr = b
+			return 0
+		}
+		v := m.regs[m.addr&0x7f]
+		m.addr++
+		return v
+	case m.cmd == mcpInstrWrite:
+		if n == 1 {
+			m.addr = b
+			return 0
+		}
+		m.write(m.addr, b)
+		m.addr++
+	case m.cmd == mcpInstrBitMod:
+		switch n {
+		case 1:
+			m.addr = b
+		case 2:
+			m.mask = b
+		case 3:
+			m.write(m.addr, m.regs[m.addr&0x7f]&^m.mask|b&m.mask)
+		}
+	case m.cmd&0xf8 == 0x40:
+		m.write(m.addr, b)
+		m.addr++
+	case m.cmd&0xf8 == 0x90:
+		v := m.regs[m.addr&0x7f]
+		m.addr++
+		return v
+	case m.cmd == mcpInstrReadStatus:
+		return m.status()
+	case m.cmd == mcpInstrRxStatus:
+		return m.rxStatus()
+	}
+	return 0
+}
+
+// finish completes an instruction when the chip select line goes high.
+func (m *MCP2515) finish() {
+	if m.n > 0 && m.cmd&0xf8 == 0x90 {
+		// Reading a receive buffer clears its interrupt flag.
+		m.regs[mcpCANINTF] &^= mcpRX0IF << (m.cmd >> 2 & 1)
+	}
+	m.transmit()
+}
+
+func (m *MCP2515) reset() {
+	m.regs = [128]byte{}
+	m.regs[mcpCANCTRL] = 0x87
+	m.regs[mcpCANSTAT] = mcpModeConfig
+	m.tec = 0
+	m.rec = 0
+}
+
+func (m *MCP2515) mode() byte {
+	return m.regs[mcpCANSTAT] & mcpModeMask
+}
+
+func (m *MCP2515) write(addr, v byte) {
+	addr &= 0x7f
+	switch {
+	case addr&0x0f == mcpCANSTAT:
+		// Read only.
+	case addr&0x0f == mcpCANCTRL:
+		m.regs[mcpCANCTRL] = v
+		m.regs[mcpCANSTAT] = m.regs[mcpCANSTAT]&^mcpModeMask | v&mcpModeMask
+		if v&mcpABAT != 0 {
+			for i := byte(0); i < 3; i++ {
+				ctrl := &m.regs[mcpTXB0CTRL+0x10*i]
+				if *ctrl&mcpTXREQ != 0 {
+					*ctrl = *ctrl&^mcpTXREQ | mcpABTF
+				}
+			}
+		}
+	case addr == mcpTEC, addr == mcpREC:
+		// Read only.
+	case addr == mcpEFLG:
+		// Only the overflow flags can be cleared.
+		m.regs[addr] &= v | 0x3f
+	case addr >= mcpRXM0SIDH && addr < mcpRXM0SIDH+8,
+		addr < 0x20 && addr&0x0f < 0x0c:
+		// Masks and filters can only be changed in configuration mode.
+		if m.mode() == mcpModeConfig {
+			m.regs[addr] = v
+		}
+	default:
+		m.regs[addr] = v
+	}
+}
+
+// status returns the result of the READ STATUS instruction.
+func (m *MCP2515) status() byte {
+	intf := m.regs[mcpCANINTF]
+	s := intf & (mcpRX0IF | mcpRX1IF)
+	for i := byte(0); i < 3; i++ {
+		if m.regs[mcpTXB0CTRL+0x10*i]&mcpTXREQ != 0 {
+			s |= 0x04 << (2 * i)
+		}
+		if intf&(mcpTX0IF<<i) != 0 {
+			s |= 0x08 << (2 * i)
+		}
+	}
+	return s
+}
+
+// rxStatus returns the result of the RX STATUS instruction.
+func (m *MCP2515) rxStatus() byte {
+	var s byte
+	s |= m.regs[mcpCANINTF] & (mcpRX0IF | mcpRX1IF) << 6
+	for i := byte(0); i < 2; i++ {
+		if m.regs[mcpCANINTF]&(mcpRX0IF<<i) != 0 {
+			buf := m.regs[mcpRXB0CTRL+0x10*i:]
+			if buf[2]&mcpEXIDE != 0 {
+				s |= 0x10
+			}
+			if buf[0]&mcpRXRTR != 0 {
+				s |= 0x08
+			}
+			s |= buf[0] & 0x07
+			break
+		}
+	}
+	return s
+}
+
+// transmit sends the frames in the transmit buffers that have a pending
+// request, from the highest to the lowest priority.
+func (m *MCP2515) transmit() {
+	for {
+		buf := -1
+		for i := 2; i >= 0; i-- {
+			ctrl := m.regs[mcpTXB0CTRL+0x10*i]
+			if ctrl&mcpTXREQ == 0 {
+				continue
+			}
+			if buf < 0 || ctrl&3 > m.regs[mcpTXB0CTRL+0x10*buf]&3 {
+				buf = i
+			}
+		}
+		if buf < 0 {
+			return
+		}
+		ctrl := &m.regs[mcpTXB0CTRL+0x10*buf]
+		f := decodeCANFrame(m.regs[mcpTXB0CTRL+0x10*buf+1:], false)
+		switch m.mode() {
+		case mcpModeNormal:
+			if m.regs[mcpEFLG]&mcpEflgTxbo != 0 {
+				return
+			}
+			if m.FailTx {
+				*ctrl |= mcpTXERR
+				m.tec += 8
+				m.updateErrors()
+				return
+			}
+			m.Sent = append(m.Sent, f)
+			if m.tec > 0 {
+				m.tec--
+				m.updateErrors()
+			}
+		case mcpModeLoopback:
+			m.receive(f)
+		default:
+			return
+		}
+		*ctrl &^= mcpTXREQ | mcpTXERR
+		m.regs[mcpCANINTF] |= mcpTX0IF << buf
+	}
+}
+
+// updateErrors updates the error flags from the error counters.
+func (m *MCP2515) updateErrors() {
+	flags := byte(0)
+	if m.tec >= 96 {
+		flags |= mcpEflgTxwar | mcpEflgEwarn
+	}
+	if m.rec >= 96 {
+		flags |= mcpEflgRxwar | mcpEflgEwarn
+	}
+	if m.tec >= 128 {
+		flags |= mcpEflgTxep
+	}
+	if m.rec >= 128 {
+		flags |= mcpEflgRxep
+	}
+	if m.tec > 255 {
+		flags |= mcpEflgTxbo
+	}
+	tec := m.tec
+	if tec > 255 {
+		tec = 255
+	}
+	m.regs[mcpTEC] = byte(tec)
+	m.regs[mcpREC] = byte(m.rec)
+	old := m.regs[mcpEFLG]
+	m.regs[mcpEFLG] = old&(mcpEflgRx0ovr|mcpEflgRx1ovr) | flags
+	if flags&^old != 0 {
+		m.regs[mcpCANINTF] |= mcpERRIF
+	}
+}
+
+// receive stores a frame in a receive buffer if it passes the filters.
+func (m *MCP2515) receive(f drivers.CANFrame) bool {
+	intf := &m.regs[mcpCANINTF]
+	if filter, ok := m.accept(0, f); ok {
+		if *intf&mcpRX0IF == 0 {
+			m.store(0, filter, f)
+			return true
+		}
+		if m.regs[mcpRXB0CTRL]&mcpBUKT == 0 || *intf&mcpRX1IF != 0 {
+			m.overflow(mcpEflgRx0ovr)
+			return false
+		}
+		// Roll over to receive buffer 1.
+		m.store(1, filter, f)
+		return true
+	}
+	if filter, ok := m.accept(1, f); ok {
+		if *intf&mcpRX1IF == 0 {
+			m.store(1, filter, f)
+			return true
+		}
+		m.overflow(mcpEflgRx1ovr)
+	}
+	return false
+}
+
+func (m *MCP2515) overflow(flag byte) {
+	m.regs[mcpEFLG] |= flag
+	m.regs[mcpCANINTF] |= mcpERRIF
+}
+
+// accept returns the filter of a receive buffer that accepts the frame.
+func (m *MCP2515) accept(buf int, f drivers.CANFrame) (byte, bool) {
+	switch m.regs[mcpRXB0CTRL+0x10*buf] & 0x60 {
+	case 0x60: // receive any frame
+		return 0, true
+	case 0x20: // standard frames only
+		if f.Extended {
+			return 0, false
+		}
+	case 0x40: // extended frames only
+		if !f.Extended {
+			return 0, false
+		}
+	}
+	mask := m.regs[mcpRXM0SIDH+4*buf:]
+	filters := []byte{0, 1}
+	if buf == 1 {
+		filters = []byte{2, 3, 4, 5}
+	}
+	for _, i := range filters {
+		addr := 4 * i
+		if i >= 3 {
+			addr += 4
+		}
+		filter := m.regs[addr:]
+		if filter[1]&mcpEXIDE != 0 != f.Extended {
+			continue
+		}
+		id := encodeCANID(f.ID, f.Extended)
+		bits := canRegisterID(mask)
+		if !f.Extended {
+			// Only the standard ID is compared.
+			bits &= 0x7ff << 18
+		}
+		if (canRegisterID(id[:])^canRegisterID(filter))&bits == 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (m *MCP2515) store(buf int, filter byte, f drivers.CANFrame) {
+	regs := m.regs[mcpRXB0CTRL+0x10*buf:]
+	id := encodeCANID(f.ID, f.Extended)
+	copy(regs[1:5], id[:])
+	regs[5] = f.DLC & 0x0f
+	if f.Remote {
+		if f.Extended {
+			regs[5] |= mcpRTR
+		} else {
+			regs[2] |= mcpSRR
+		}
+	}
+	copy(regs[6:14], f.Data[:])
+	// FILHIT is bit 0 of RXB0CTRL, next to BUKT, and bits 2-0 of RXB1CTRL.
+	filhit := byte(0x07)
+	if buf == 0 {
+		filhit = 0x01
+		filter &= 1
+	}
+	ctrl := regs[0]&^(mcpRXRTR|filhit) | filter
+	if f.Remote {
+		ctrl |= mcpRXRTR
+	}
+	regs[0] = ctrl
+	m.regs[mcpCANINTF] |= mcpRX0IF << buf
+}
+
+// encodeCANID returns the SIDH, SIDL, EID8 and EID0 registers for an ID.
+func encodeCANID(id uint32, extended bool) [4]byte {
+	if !extended {
+		return [4]byte{byte(id >> 3), byte(id << 5), 0, 0}
+	}
+	sid := id >> 18
+	return [4]byte{byte(sid >> 3), byte(sid<<5) | mcpEXIDE | byte(id>>16)&3, byte(id >> 8), byte(id)}
+}
+
+// canRegisterID returns the 29-bit ID in SIDH, SIDL, EID8 and EID0
+// registers, with the standard ID in the upper 11 bits.
+func canRegisterID(r []byte) uint32 {
+	sid := uint32(r[0])<<3 | uint32(r[1])>>5
+	return sid<<18 | uint32(r[1]&3)<<16 | uint32(r[2])<<8 | uint32(r[3])
+}
+
+// decodeCANFrame decodes a frame from the registers of a transmit or
+// receive buffer, starting at SIDH.
+func decodeCANFrame(r []byte, rx bool) drivers.CANFrame {
+	var f drivers.CANFrame
+	f.Extended = r[1]&mcpEXIDE != 0
+	if f.Extended {
+		f.ID = canRegisterID(r)
+	} else {
+		f.ID = uint32(r[0])<<3 | uint32(r[1])>>5
+	}
+	f.DLC = r[4] & 0x0f
+	f.Remote = r[4]&mcpRTR != 0
+	if rx && !f.Extended {
+		f.Remote = r[1]&mcpSRR != 0
+	}
+	if !f.Remote {
+		copy(f.Data[:], r[5:13])
+	}
+	return f
+}
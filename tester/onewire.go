@@ -0,0 +1,339 @@
+package tester
+
+// OneWireDevice is a device on a OneWireBus. The bus handles the ROM
+// commands, and passes the function commands and their data to the devices
+// that are selected.
+type OneWireDevice interface {
+	// ROM returns the 64-bit ROM ID: the family code, the serial number and
+	// the CRC.
+	ROM() [8]byte
+
+	// Reset is called on a reset pulse, which ends the current function
+	// command.
+	Reset()
+
+	// Write is called with each byte that the master writes after the
+	// device was selected.
+	Write(b byte)
+
+	// Read returns the next byte that the master reads from the device.
+	Read() byte
+}
+
+// powered is implemented by devices that need a strong pull-up after a
+// command, which is applied after the last Write.
+type powered interface {
+	StrongPullUp()
+}
+
+// overdriver is implemented by devices that support overdrive speed.
+type overdriver interface {
+	SupportsOverdrive() bool
+}
+
+// conditional is implemented by devices that may answer a conditional read
+// ROM command, such as the DS28EA00 that is next in a chain.
+type conditional interface {
+	ConditionalReadROM() bool
+}
+
+// States of a device on a OneWireBus.
+const (
+	wireIdle     = iota // waiting for a reset
+	wireROM             // receiving a ROM command
+	wireReadROM         // sending the ROM ID
+	wireMatchROM        // receiving a ROM ID
+	wireSearch          // taking part in a search
+	wireFunction        // selected
+)
+
+// ROM commands.
+const (
+	wireReadROMCmd        = 0x33
+	wireMatchROMCmd       = 0x55
+	wireSkipROMCmd        = 0xcc
+	wireSearchROMCmd      = 0xf0
+	wireOverdriveSkipCmd  = 0x3c
+	wireOverdriveMatchCmd = 0x69
+	wireCondReadROMCmd    = 0x0f
+)
+
+// OneWireBus is a simulated 1-Wire bus with devices, which implements
+// onewire.Master. A read slot returns the wired AND of the bits of all
+// devices that take part.
+type OneWireBus struct {
+	// Overdrive is set while the master uses overdrive speed.
+	Overdrive bool
+
+	// StrongPullUps counts the bytes that were written with a strong
+	// pull-up.
+	StrongPullUps int
+
+	devices []*wireDevice
+}
+
+// wireDevice is the state of a device on the bus.
+type wireDevice struct {
+	dev       OneWireDevice
+	rom       [8]byte
+	state     int
+	overdrive bool
+	odMatch   bool // in an overdrive match ROM command
+	pos       int  // bit of the ROM ID
+	phase     int  // of a search step: bit, complement, direction
+	wbuf      byte
+	wbits     int
+	rbuf      byte
+	rbits     int
+}
+
+// NewOneWireBus returns a bus with devices.
+func NewOneWireBus(devices ...OneWireDevice) *OneWireBus {
+	b := &OneWireBus{}
+	for _, dev := range devices {
+		b.Add(dev)
+	}
+	return b
+}
+
+// Add connects a device to the bus.
+func (b *OneWireBus) Add(dev OneWireDevice) {
+	b.devices = append(b.devices, &wireDevice{dev: dev, rom: dev.ROM()})
+}
+
+// Reset sends a reset pulse. An overdrive reset pulse is too short for
+// devices at standard speed, and a standard reset pulse switches all devices
+// back to standard speed.
+func (b *OneWireBus) Reset() (bool, error) {
+	presence := false
+	for _, d := range b.devices {
+		if b.Overdrive && !d.overdrive {
+			d.state = wireIdle
+			continue
+		}
+		d.overdrive = b.Overdrive
+		d.state = wireROM
+		d.odMatch = false
+		d.wbits = 0
+		d.rbits = 0
+		d.dev.Reset()
+		presence = true
+	}
+	return presence, nil
+}
+
+// WriteBit sends a write time slot.
+func (b *OneWireBus) WriteBit(bit uint8) {
+	for _, d := range b.devices {
+		if b.active(d) {
+			d.writeBit(bit & 1)
+		}
+	}
+}
+
+// ReadBit sends a read time slot.
+func (b *OneWireBus) ReadBit() uint8 {
+	bit := uint8(1)
+	for _, d := range b.devices {
+		if b.active(d) {
+			bit &= d.readBit()
+		}
+	}
+	return bit
+}
+
+// Write writes a byte, LSB first.
+func (b *OneWireBus) Write(data uint8) {
+	for i := 0; i < 8; i++ {
+		b.WriteBit(data >> i)
+	}
+}
+
+// Read reads a byte, LSB first.
+func (b *OneWireBus) Read() uint8 {
+	var data uint8
+	for i := 0; i < 8; i++ {
+		data |= b.ReadBit() << i
+	}
+	return data
+}
+
+// WritePower writes a byte, and powers the selected devices with a strong
+// pull-up.
+func (b *OneWireBus) WritePower(data uint8) {
+	b.Write(data)
+	b.StrongPullUps++
+	for _, d := range b.devices {
+		if p, ok := d.dev.(powered); ok && b.active(d) && d.state == wireFunction {
+			p.StrongPullUp()
+		}
+	}
+}
+
+// SetOverdrive switches the master between standard and overdrive speed.
+func (b *OneWireBus) SetOverdrive(overdrive bool) {
+	b.Overdrive = overdrive
+}
+
+// active returns whether a device takes part in the time slots.
+func (b *OneWireBus) active(d *wireDevice) bool {
+	return d.state != wireIdle && d.overdrive == b.Overdrive
+}
+
+func (d *wireDevice) romBit() uint8 {
+	return d.rom[d.pos/8] >> (d.pos % 8) & 1
+}
+
+func (d *wireDevice) writeBit(bit uint8) {
+	switch d.state {
+	case wireROM:
+		d.wbuf = d.wbuf>>1 | bit<<7
+		d.wbits++
+		if d.wbits == 8 {
+			d.wbits = 0
+			d.command(d.wbuf)
+		}
+	case wireMatchROM:
+		if bit != d.romBit() {
+			d.state = wireIdle
+			if d.odMatch {
+				d.overdrive = false
+			}
+			return
+		}
+		d.pos++
+		if d.pos == 64 {
+			d.state = wireFunction
+		}
+	case wireSearch:
+		if d.phase != 2 {
+			return
+		}
+		if bit != d.romBit() {
+			d.state = wireIdle
+			return
+		}
+		d.phase = 0
+		d.pos++
+		if d.pos == 64 {
+			d.state = wireFunction
+		}
+	case wireFunction:
+		d.rbits = 0
+		d.wbuf = d.wbuf>>1 | bit<<7
+		d.wbits++
+		if d.wbits == 8 {
+			d.wbits = 0
+			d.dev.Write(d.wbuf)
+		}
+	}
+}
+
+func (d *wireDevice) readBit() uint8 {
+	switch d.state {
+	case wireReadROM:
+		bit := d.romBit()
+		d.pos++
+		if d.pos == 64 {
+			d.state = wireFunction
+		}
+		return bit
+	case wireSearch:
+		switch d.phase {
+		case 0:
+			d.phase++
+			return d.romBit()
+		case 1:
+			d.phase++
+			return d.romBit() ^ 1
+		}
+	case wireFunction:
+		d.wbits = 0
+		if d.rbits == 0 {
+			d.rbuf = d.dev.Read()
+			d.rbits = 8
+		}
+		bit := d.rbuf & 1
+		d.rbuf >>= 1
+		d.rbits--
+		return bit
+	}
+	// A read slot is the same as writing a 1.
+	d.writeBit(1)
+	return 1
+}
+
+// command executes a ROM command.
+func (d *wireDevice) command(cmd byte) {
+	d.pos = 0
+	d.phase = 0
+	switch cmd {
+	case wireReadROMCmd:
+		d.state = wireReadROM
+	case wireMatchROMCmd:
+		d.state = wireMatchROM
+	case wireSkipROMCmd:
+		d.state = wireFunction
+	case wireSearchROMCmd:
+		d.state = wireSearch
+	case wireCondReadROMCmd:
+		d.state = wireIdle
+		if c, ok := d.dev.(conditional); ok && c.ConditionalReadROM() {
+			d.state = wireReadROM
+		}
+	case wireOverdriveSkipCmd, wireOverdriveMatchCmd:
+		if o, ok := d.dev.(overdriver); !ok || !o.SupportsOverdrive() {
+			d.state = wireIdle
+			return
+		}
+		d.overdrive = true
+		d.state = wireFunction
+		if cmd == wireOverdriveMatchCmd {
+			d.state = wireMatchROM
+			d.odMatch = true
+		}
+	default:
+		d.state = wireIdle
+	}
+}
+
+// crc8 is the Dallas/Maxim CRC of ROM IDs and scratchpads.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			mix := (crc ^ b) & 1
+			crc >>= 1
+			if mix != 0 {
+				crc ^= 0x8c
+			}
+			b >>= 1
+		}
+	}
+	return crc
+}
+
+// crc16 is the CRC of memory functions, which devices send inverted.
+func crc16(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// romID returns a ROM ID with a family code and a 48-bit serial number.
+func romID(family byte, serial uint64) [8]byte {
+	rom := [8]byte{family}
+	for i := 1; i < 7; i++ {
+		rom[i] = byte(serial >> (8 * (i - 1)))
+	}
+	rom[7] = crc8(rom[:7])
+	return rom
+}
@@ -0,0 +1,162 @@
+package tester
+
+import (
+	"time"
+
+	"tinygo.org/x/drivers"
+)
+
+// Pin is a GPIO pin in memory, which implements drivers.Pin and
+// drivers.PinConfigurer. It records the level changes of the line in Edges,
+// and plays input waveforms set with Play and PlayOnInput. It is also the
+// chip select or data/command line of the devices on an SPIBus.
+//
+// The line is driven by the pin while it is an output. Otherwise it has the
+// level of the waveform, or once the waveform has ended its last level. An
+// input without waveform reads high, as if the line had a pull-up resistor,
+// unless it is configured with a pull-down.
+//
+// The levels of a waveform last for some time, or for a number of calls to
+// Get. The latter is for drivers that measure pulses by counting loop
+// iterations, which would depend on the speed of the host.
+type Pin struct {
+	high  bool // level of the line
+	out   bool // output level, also while the pin is an input
+	mode  drivers.PinMode
+	set   func(high bool) // called when the level changes
+	wave  []Level
+	step  int       // current step of the waveform
+	since time.Time // start of the current step
+	reads int       // calls to Get during the current step
+	armed []Level   // waveform that starts when the pin becomes an input
+
+	// Edges are the level changes of the line, except those of the
+	// waveforms.
+	Edges []Edge
+}
+
+// Edge is a level change of a pin.
+type Edge struct {
+	Time time.Time
+	High bool
+}
+
+// Level is a step of a waveform: the level of a pin for some time, or if
+// Reads is not zero, for that number of calls to Get.
+type Level struct {
+	High     bool
+	Duration time.Duration
+	Reads    int
+}
+
+// Set sets the output level of the pin.
+func (p *Pin) Set(high bool) {
+	p.out = high
+	if p.mode == drivers.PinModeOutput {
+		p.drive(high)
+	}
+}
+
+// High sets the pin high.
+func (p *Pin) High() {
+	p.Set(true)
+}
+
+// Low sets the pin low.
+func (p *Pin) Low() {
+	p.Set(false)
+}
+
+// Get returns the level of the line.
+func (p *Pin) Get() bool {
+	if p.mode == drivers.PinModeOutput || p.wave == nil {
+		return p.high
+	}
+	return p.level(1)
+}
+
+// level returns the level of the waveform, after reads more calls to Get.
+func (p *Pin) level(reads int) bool {
+	now := time.Now()
+	p.reads += reads
+	for ; p.step < len(p.wave)-1; p.step++ {
+		l := p.wave[p.step]
+		if l.Reads > 0 {
+			if p.reads <= l.Reads {
+				break
+			}
+			p.reads -= l.Reads
+			p.since = now
+			continue
+		}
+		if now.Sub(p.since) < l.Duration {
+			break
+		}
+		p.since = p.since.Add(l.Duration)
+		p.reads = reads
+	}
+	return p.wave[p.step].High
+}
+
+// ConfigureMode implements drivers.PinConfigurer. An output drives the line
+// to the level last set, and an input releases it.
+func (p *Pin) ConfigureMode(mode drivers.PinMode) {
+	if p.mode != drivers.PinModeOutput && p.wave != nil {
+		p.high = p.level(0) // the level the waveform left
+	}
+	p.mode = mode
+	if mode == drivers.PinModeOutput {
+		p.drive(p.out)
+		return
+	}
+	if p.armed != nil {
+		p.Play(p.armed...)
+		p.armed = nil
+	}
+	if p.wave != nil {
+		p.high = p.level(0)
+	} else {
+		p.drive(mode != drivers.PinModeInputPulldown)
+	}
+}
+
+// Mode returns the direction of the pin. It is an output until it is
+// configured otherwise.
+func (p *Pin) Mode() drivers.PinMode {
+	return p.mode
+}
+
+// Play starts a waveform, that the pin reads while it is an input.
+func (p *Pin) Play(wave ...Level) {
+	p.wave = wave
+	p.step = 0
+	p.since = time.Now()
+	p.reads = 0
+}
+
+// PlayOnInput starts a waveform when the pin is next configured as an
+// input, such as the reply of a sensor to a start signal.
+func (p *Pin) PlayOnInput(wave ...Level) {
+	p.armed = wave
+}
+
+// Pulses returns the times between the recorded edges.
+func (p *Pin) Pulses() []time.Duration {
+	var d []time.Duration
+	for i := 1; i < len(p.Edges); i++ {
+		d = append(d, p.Edges[i].Time.Sub(p.Edges[i-1].Time))
+	}
+	return d
+}
+
+// drive sets the level of the line.
+func (p *Pin) drive(high bool) {
+	if high == p.high {
+		return
+	}
+	p.high = high
+	p.Edges = append(p.Edges, Edge{time.Now(), high})
+	if p.set != nil {
+		p.set(high)
+	}
+}
@@ -0,0 +1,81 @@
+package tester
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"tinygo.org/x/drivers"
+)
+
+func levels(edges []Edge) []bool {
+	var l []bool
+	for _, e := range edges {
+		l = append(l, e.High)
+	}
+	return l
+}
+
+func TestPinEdges(t *testing.T) {
+	c := qt.New(t)
+	var p Pin
+	c.Assert(p.Mode(), qt.Equals, drivers.PinModeOutput)
+	p.High()
+	p.High()
+	time.Sleep(time.Millisecond)
+	p.Low()
+	c.Assert(levels(p.Edges), qt.DeepEquals, []bool{true, false})
+	c.Assert(p.Pulses()[0] >= time.Millisecond, qt.IsTrue)
+
+	// Open drain: the line is released to high by the pull-up.
+	p.ConfigureMode(drivers.PinModeInput)
+	c.Assert(p.Get(), qt.IsTrue)
+	p.ConfigureMode(drivers.PinModeOutput)
+	c.Assert(p.Get(), qt.IsFalse)
+	p.ConfigureMode(drivers.PinModeInputPulldown)
+	c.Assert(p.Get(), qt.IsFalse)
+	c.Assert(levels(p.Edges), qt.DeepEquals, []bool{true, false, true, false})
+}
+
+func TestPinPlay(t *testing.T) {
+	c := qt.New(t)
+	var p Pin
+	p.ConfigureMode(drivers.PinModeInput)
+	p.Play(Level{High: false, Duration: 20 * time.Millisecond}, Level{High: true, Duration: 20 * time.Millisecond}, Level{High: false})
+	c.Assert(p.Get(), qt.IsFalse)
+	time.Sleep(30 * time.Millisecond)
+	c.Assert(p.Get(), qt.IsTrue)
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(p.Get(), qt.IsFalse)
+	// The waveform is not recorded, the level it left is.
+	c.Assert(levels(p.Edges), qt.DeepEquals, []bool{true})
+
+	// The reply to a start signal.
+	var q Pin
+	q.PlayOnInput(Level{High: true})
+	q.Low()
+	c.Assert(q.Get(), qt.IsFalse)
+	q.ConfigureMode(drivers.PinModeInputPulldown)
+	c.Assert(q.Get(), qt.IsTrue)
+}
+
+func TestPinPlayReads(t *testing.T) {
+	c := qt.New(t)
+	var p Pin
+	p.ConfigureMode(drivers.PinModeInput)
+	p.Play(Level{High: false, Reads: 2}, Level{High: true, Reads: 3}, Level{High: false, Duration: 20 * time.Millisecond}, Level{High: true})
+	var got []bool
+	for i := 0; i < 6; i++ {
+		got = append(got, p.Get())
+	}
+	c.Assert(got, qt.DeepEquals, []bool{false, false, true, true, true, false})
+	time.Sleep(30 * time.Millisecond)
+	c.Assert(p.Get(), qt.IsTrue)
+
+	// Configuring the pin doesn't count as a read.
+	p.PlayOnInput(Level{High: false, Reads: 1}, Level{High: true})
+	p.ConfigureMode(drivers.PinModeOutput)
+	p.ConfigureMode(drivers.PinModeInput)
+	c.Assert(p.Get(), qt.IsFalse)
+	c.Assert(p.Get(), qt.IsTrue)
+}
@@ -0,0 +1,497 @@
+package tester
+
+import "encoding/binary"
+
+// SDCardType is the kind of card emulated by SDCard.
+type SDCardType uint8
+
+const (
+	SDv1 SDCardType = iota // standard capacity SD card, version 1
+	SDv2                   // standard capacity SD card, version 2
+	SDHC                   // high capacity SD card (up to 32GB)
+	SDXC                   // extended capacity SD card (more than 32GB)
+	MMC                    // MultiMediaCard, sector addressed above 2GB
+)
+
+// SD card commands and tokens used by the emulator.
+const (
+	sdR1Idle          = 1 << 0
+	sdR1Illegal       = 1 << 2
+	sdR1CRCError      = 1 << 3
+	sdR1AddressError  = 1 << 5
+	sdR1ParamError    = 1 << 6
+	sdTokenStart      = 0xFE
+	sdTokenStartMulti = 0xFC
+	sdTokenStop       = 0xFD
+	sdErrorOutOfRange = 0x08
+	sdBlockSize       = 512
+	sdInitRetries     = 2 // ACMD41 or CMD1 calls before the card is ready
+	sdWriteSingle     = 1
+	sdWriteMulti      = 2
+)
+
+// SDCard emulates an SD card or MMC that is connected over SPI, as seen by
+// the SPI host. It implements drivers.SPI, and its Set method controls the
+// chip select line, so it can be passed to sdcard.New as both the bus and
+// the chip select pin.
+//
+// The emulator implements the commands needed to initialize the card, read
+// its registers, read and write single and multiple blocks, switch to high
+// speed mode and check CRCs. Memory is allocated as blocks are written, so
+// even a card of many gigabytes only uses as much memory as is written to it.
+type SDCard struct {
+	// CorruptReads flips a bit in every data block sent to the host, after
+	// its CRC was calculated.
+	CorruptReads bool
+
+	// Commands counts how often each command was received. ACMDs are counted
+	// together with the CMD of the same number.
+	Commands [64]int
+
+	// HighSpeed is set once the host switched the card to high speed mode.
+	HighSpeed bool
+
+	// CRC reports whether the host enabled CRC checking.
+	CRC bool
+
+	typ     SDCardType
+	sectors uint32
+	blocks  map[uint32]*[sdBlockSize]byte
+
+	selected  bool
+	idle      bool
+	appCmd    bool
+	initCount int
+	cmd       [6]byte
+	ncmd      int
+	out       []byte // bytes to send to the host
+	reading   bool   // sending blocks until CMD12
+	readBlock uint32
+	writing   int // sdWriteSingle or sdWriteMulti
+	rx        []byte
+	wrBlock   uint32
+}
+
+// NewSDCard returns an emulated card of the given type and size in bytes.
+// The size is rounded down to a supported capacity: a multiple of 512KiB for
+// high and extended capacity cards, and of 256KiB (512KiB above 1GiB) for
+// other cards. Standard capacity cards can be at most 2GiB.
+func NewSDCard(typ SDCardType, size int64) *SDCard {
+	unit := int64(512 << 10)
+	if (typ == SDv1 || typ == SDv2 || typ == MMC) && size <= 1<<30 {
+		unit = 256 << 10
+	}
+	return &SDCard{
+		typ:     typ,
+		sectors: uint32(size / unit * unit / sdBlockSize),
+		blocks:  make(map[uint32]*[sdBlockSize]byte),
+		idle:    true,
+	}
+}
+
+// Size returns the capacity of the card in bytes.
+func (c *SDCard) Size() int64 {
+	return int64(c.sectors) * sdBlockSize
+}
+
+// ReadBlock copies the contents of a block into buf.
+func (c *SDCard) ReadBlock(block uint32, buf []byte) {
+	if b := c.blocks[block]; b != nil {
+		copy(buf, b[:])
+	} else {
+		copy(buf, make([]byte, sdBlockSize))
+	}
+}
+
+// WriteBlock changes the contents of a block.
+func (c *SDCard) WriteBlock(block uint32, buf []byte) {
+	b := c.blocks[block]
+	if b == nil {
+		b = new([sdBlockSize]byte)
+		c.blocks[block] = b
+	}
+	copy(b[:], buf)
+}
+
+// Set sets the chip select line, which is active low.
+func (c *SDCard) Set(high bool) {
+	if high == !c.selected {
+		return
+	}
+	c.selected = !high
+	c.ncmd = 0
+	if high {
+		// Deselecting the card aborts a response that wasn't read, but not
+		// a multi-block transfer.
+		c.out = c.out[:0]
+	}
+}
+
+// High deselects the card.
+func (c *SDCard) High() {
+	c.Set(true)
+}
+
+// Low selects the card.
+func (c *SDCard) Low() {
+	c.Set(false)
+}
+
+// Tx implements drivers.SPI.
+func (c *SDCard) Tx(w, r []byte) error {
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	}
+	for i := 0; i < n; i++ {
+		var in byte
+		if w != nil {
+			in = w[i]
+		}
+		out := c.exchange(in)
+		if r != nil {
+			r[i] = out
+		}
+	}
+	return nil
+}
+
+// Transfer implements drivers.SPI.
+func (c *SDCard) Transfer(b byte) (byte, error) {
+	return c.exchange(b), nil
+}
+
+// exchange sends one byte to the card and returns the byte sent back.
+func (c *SDCard) exchange(in byte) byte {
+	if !c.selected {
+		return 0xFF
+	}
+	if len(c.out) == 0 && c.reading {
+		c.sendBlock(c.readBlock)
+		c.readBlock++
+	}
+	out := byte(0xFF)
+	if len(c.out) > 0 {
+		out = c.out[0]
+		c.out = c.out[1:]
+	}
+	c.receive(in)
+	return out
+}
+
+func (c *SDCard) receive(in byte) {
+	if c.writing != 0 {
+		c.receiveData(in)
+		return
+	}
+	if c.ncmd == 0 && in&0xC0 != 0x40 {
+		return
+	}
+	c.cmd[c.ncmd] = in
+	c.ncmd++
+	if c.ncmd == len(c.cmd) {
+		c.ncmd = 0
+		c.command()
+	}
+}
+
+// receiveData handles the bytes of a single or multi-block write.
+func (c *SDCard) receiveData(in byte) {
+	if c.rx == nil {
+		switch {
+		case in == sdTokenStart && c.writing == sdWriteSingle,
+			in == sdTokenStartMulti && c.writing == sdWriteMulti:
+			c.rx = make([]byte, 0, sdBlockSize+2)
+		case in == sdTokenStop && c.writing == sdWriteMulti:
+			// Skip a byte, then be busy for a while.
+			c.writing = 0
+			c.out = append(c.out[:0], 0xFF, 0x00, 0x00)
+		}
+		return
+	}
+	c.rx = append(c.rx, in)
+	if len(c.rx) < sdBlockSize+2 {
+		return
+	}
+	data, crc := c.rx[:sdBlockSize], binary.BigEndian.Uint16(c.rx[sdBlockSize:])
+	c.rx = nil
+	if c.writing == sdWriteSingle {
+		c.writing = 0
+	}
+	// After an error, the host still ends a multi-block write with a stop
+	// token.
+	switch {
+	case c.CRC && crc != sdCRC16(data):
+		c.out = append(c.out[:0], 0x0B)
+		return
+	case c.wrBlock >= c.sectors:
+		c.out = append(c.out[:0], 0x0D)
+		return
+	}
+	c.WriteBlock(c.wrBlock, data)
+	c.wrBlock++
+	// Data accepted, followed by a few busy bytes.
+	c.out = append(c.out[:0], 0x05, 0x00, 0x00, 0x00)
+}
+
+// command handles a command received from the host.
+func (c *SDCard) command() {
+	cmd := c.cmd[0] & 0x3F
+	arg := binary.BigEndian.Uint32(c.cmd[1:5])
+	c.Commands[cmd]++
+	app := c.appCmd
+	c.appCmd = false
+
+	if (c.CRC || cmd == 0 || cmd == 8) && c.cmd[5] != sdCRC7(c.cmd[:5])<<1|1 {
+		c.respond(sdR1CRCError)
+		return
+	}
+
+	switch {
+	case cmd == 0: // GO_IDLE_STATE
+		c.idle = true
+		c.reading = false
+		c.CRC = false
+		c.HighSpeed = false
+		c.initCount = 0
+		c.respond(0)
+	case cmd == 1 && c.typ == MMC: // SEND_OP_COND
+		c.initialize()
+		c.respond(0)
+	case cmd == 8 && c.typ == MMC && !c.idle: // SEND_EXT_CSD
+		var ext [sdBlockSize]byte
+		binary.LittleEndian.PutUint32(ext[212:], c.sectors)
+		c.respond(0)
+		c.sendData(ext[:])
+	case cmd == 8 && (c.typ == SDv1 || c.typ == MMC): // SEND_IF_COND
+		c.respond(sdR1Illegal)
+	case cmd == 8:
+		c.respond(0, 0x00, 0x00, byte(arg>>8)&0x0F, byte(arg))
+	case cmd == 55 && c.typ != MMC: // APP_CMD
+		c.appCmd = true
+		c.respond(0)
+	case cmd == 41 && app: // SD_SEND_OP_COND
+		c.initialize()
+		c.respond(0)
+	case cmd == 58: // READ_OCR
+		ocr := []byte{0x00, 0xFF, 0x80, 0x00}
+		if !c.idle {
+			ocr[0] |= 0x80
+			if c.blockAddressed() {
+				ocr[0] |= 0x40
+			}
+		}
+		c.respond(0, ocr...)
+	case cmd == 59: // CRC_ON_OFF
+		c.CRC = arg&1 != 0
+		c.respond(0)
+	case c.idle:
+		c.respond(sdR1Illegal)
+	case cmd == 6 && c.typ != SDv1 && c.typ != MMC: // SWITCH_FUNC
+		c.respond(0)
+		c.sendData(c.switchFunc(arg))
+	case cmd == 9: // SEND_CSD
+		c.respond(0)
+		c.sendData(c.csd())
+	case cmd == 10: // SEND_CID
+		c.respond(0)
+		c.sendData(c.cid())
+	case cmd == 12: // STOP_TRANSMISSION
+		// The host skips a stuff byte before reading the response.
+		c.reading = false
+		c.out = append(c.out[:0], 0xFF, 0xFF, 0x00)
+	case cmd == 13: // SEND_STATUS
+		c.respond(0, 0x00)
+	case cmd == 16: // SET_BLOCKLEN
+		if arg != sdBlockSize {
+			c.respond(sdR1ParamError)
+			return
+		}
+		c.respond(0)
+	case cmd == 17 || cmd == 18 || cmd == 24 || cmd == 25:
+		block, ok := c.block(arg)
+		if !ok {
+			c.respond(sdR1AddressError)
+			return
+		}
+		c.respond(0)
+		switch cmd {
+		case 17: // READ_SINGLE_BLOCK
+			c.sendBlock(block)
+		case 18: // READ_MULTIPLE_BLOCK
+			c.reading = true
+			c.readBlock = block
+		case 24: // WRITE_BLOCK
+			c.writing = sdWriteSingle
+			c.wrBlock = block
+		case 25: // WRITE_MULTIPLE_BLOCK
+			c.writing = sdWriteMulti
+			c.wrBlock = block
+		}
+	default:
+		c.respond(sdR1Illegal)
+	}
+}
+
+// initialize handles ACMD41 and CMD1, which have to be repeated until the
+// card leaves the idle state.
+func (c *SDCard) initialize() {
+	c.initCount++
+	if c.initCount >= sdInitRetries {
+		c.idle = false
+	}
+}
+
+func (c *SDCard) blockAddressed() bool {
+	return c.typ == SDHC || c.typ == SDXC || (c.typ == MMC && c.sectors > 4<<20)
+}
+
+// block returns the block addressed by a command argument.
+func (c *SDCard) block(arg uint32) (uint32, bool) {
+	if !c.blockAddressed() {
+		if arg%sdBlockSize != 0 {
+			return 0, false
+		}
+		arg /= sdBlockSize
+	}
+	return arg, arg < c.sectors
+}
+
+// respond queues an R1 response, followed by more response bytes. The card
+// waits one byte before responding.
+func (c *SDCard) respond(r1 byte, rest ...byte) {
+	if c.idle {
+		r1 |= sdR1Idle
+	}
+	c.out = append(c.out[:0], 0xFF, r1)
+	c.out = append(c.out, rest...)
+}
+
+// sendData queues a data block with its CRC.
+func (c *SDCard) sendData(data []byte) {
+	crc := sdCRC16(data)
+	c.out = append(c.out, 0xFF, 0xFF, sdTokenStart)
+	start := len(c.out)
+	c.out = append(c.out, data...)
+	c.out = append(c.out, byte(crc>>8), byte(crc))
+	if c.CorruptReads {
+		c.out[start] ^= 0x01
+	}
+}
+
+// sendBlock queues a memory block, or an error token if it is out of range.
+func (c *SDCard) sendBlock(block uint32) {
+	if block >= c.sectors {
+		c.reading = false
+		c.out = append(c.out, 0xFF, sdErrorOutOfRange)
+		return
+	}
+	var buf [sdBlockSize]byte
+	c.ReadBlock(block, buf[:])
+	c.sendData(buf[:])
+}
+
+// switchFunc returns the status of CMD6. Only function 1 of group 1 (high
+// speed) is supported.
+func (c *SDCard) switchFunc(arg uint32) []byte {
+	status := make([]byte, 64)
+	status[1] = 100 // maximum current in mA
+	status[12], status[13] = 0x80, 0x03
+	switch fn := arg & 0x0F; fn {
+	case 0, 1:
+		status[16] = byte(fn)
+		if arg&(1<<31) != 0 {
+			c.HighSpeed = fn == 1
+		}
+	case 0x0F:
+		if c.HighSpeed {
+			status[16] = 1
+		}
+	default:
+		status[16] = 0x0F
+	}
+	return status
+}
+
+// csd returns the CSD register.
+func (c *SDCard) csd() []byte {
+	csd := make([]byte, 16)
+	csd[1] = 0x0E // TAAC
+	csd[3] = 0x32 // TRAN_SPEED: 25MHz
+	switch c.typ {
+	case SDHC, SDXC:
+		csd[0] = 0x40 // version 2.0
+		csd[4], csd[5] = 0x5B, 0x59
+		cSize := c.sectors/1024 - 1
+		csd[7] = byte(cSize>>16) & 0x3F
+		csd[8] = byte(cSize >> 8)
+		csd[9] = byte(cSize)
+	default:
+		blLen := uint32(9)
+		if c.sectors > 2<<20 {
+			blLen = 10
+		}
+		csd[4], csd[5] = 0x5B, 0x50|byte(blLen)
+		switch c.typ {
+		case SDv1:
+			csd[4], csd[5] = 0x1F, 0x50|byte(blLen) // no CMD6
+		case MMC:
+			csd[0] = 0x90 // version 1.2, spec version 4
+			csd[4], csd[5] = 0x0F, 0x50|byte(blLen)
+		}
+		cSize := c.sectors*sdBlockSize>>(blLen+9) - 1
+		if c.blockAddressed() {
+			cSize = 0xFFF
+		}
+		const mult = 7 // C_SIZE_MULT: 512 blocks per C_SIZE unit
+		csd[6] = byte(cSize>>10) & 0x03
+		csd[7] = byte(cSize >> 2)
+		csd[8] = byte(cSize << 6)
+		csd[9] = mult >> 1
+		csd[10] = mult & 1 << 7
+	}
+	csd[10] |= 0x7F // ERASE_BLK_EN, SECTOR_SIZE
+	csd[11] = 0x80
+	csd[12] = 0x0A // R2W_FACTOR, WRITE_BL_LEN
+	csd[13] = 0x40
+	csd[15] = sdCRC7(csd[:15])<<1 | 1
+	return csd
+}
+
+// cid returns the CID register.
+func (c *SDCard) cid() []byte {
+	cid := []byte{0x03, 'T', 'G', 'E', 'M', 'U', 'L', '8', 0x10, 0x12, 0x34, 0x56, 0x78, 0x01, 0x5A, 0}
+	cid[15] = sdCRC7(cid[:15])<<1 | 1
+	return cid
+}
+
+// sdCRC7 returns the CRC7 of a command or register.
+func sdCRC7(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if (b^crc)&0x80 != 0 {
+				crc ^= 0x09
+			}
+			b <<= 1
+		}
+	}
+	return crc & 0x7F
+}
+
+// sdCRC16 returns the CRC16-CCITT of a data block.
+func sdCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			if (crc>>8^uint16(b))&0x80 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+			b <<= 1
+		}
+	}
+	return crc
+}
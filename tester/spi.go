@@ -0,0 +1,142 @@
+package tester
+
+import "tinygo.org/x/drivers"
+
+// SPIDevice is a device on an SPIBus, such as SPIDevice8, SPIScript, MCP2515
+// or SDCard. Set is called when its chip select line changes, which is
+// active low, and the bytes are exchanged with Tx or Transfer while it is
+// selected.
+type SPIDevice interface {
+	drivers.SPI
+	Set(high bool)
+}
+
+// SPITransaction is a sequence of bytes exchanged with a device while it was
+// selected and the data/command line didn't change.
+type SPITransaction struct {
+	// Device is the selected device, or nil if there was none.
+	Device SPIDevice
+	// Data is the level of the data/command line of display controllers,
+	// which is high for data and low for commands.
+	Data bool
+	// W are the bytes written by the controller, R the bytes read.
+	W, R []byte
+}
+
+// SPIBus implements the SPI interface in memory for testing. The bytes go to
+// the device whose chip select pin is low, and are recorded as transactions.
+type SPIBus struct {
+	c       Failer
+	devices []SPIDevice
+	pins    []*Pin
+	open    bool // whether the last transaction can be continued
+
+	// DC is the data/command pin of display controllers.
+	DC *Pin
+
+	// Transactions are the transfers since the bus was created or the
+	// last call of Reset.
+	Transactions []SPITransaction
+}
+
+// NewSPIBus returns an SPI bus without devices, which uses c to flag errors.
+func NewSPIBus(c Failer) *SPIBus {
+	bus := &SPIBus{c: c}
+	bus.DC = &Pin{set: bus.split}
+	return bus
+}
+
+// AddDevice adds a device to the bus and returns its chip select pin, which
+// is high. A test can select a device without chip select line by setting
+// its pin low.
+func (bus *SPIBus) AddDevice(d SPIDevice) *Pin {
+	cs := &Pin{high: true, out: true}
+	cs.set = func(high bool) {
+		bus.split(high)
+		d.Set(high)
+	}
+	bus.devices = append(bus.devices, d)
+	bus.pins = append(bus.pins, cs)
+	return cs
+}
+
+// Reset clears the recorded transactions.
+func (bus *SPIBus) Reset() {
+	bus.Transactions = nil
+	bus.open = false
+}
+
+// Written returns the bytes written in the recorded transactions with the
+// given level of the data/command line, such as the commands sent to a
+// display controller.
+func (bus *SPIBus) Written(data bool) []byte {
+	var w []byte
+	for _, t := range bus.Transactions {
+		if t.Data == data {
+			w = append(w, t.W...)
+		}
+	}
+	return w
+}
+
+// Tx implements drivers.SPI.
+func (bus *SPIBus) Tx(w, r []byte) error {
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	}
+	if r != nil && w != nil && len(r) != len(w) {
+		bus.c.Fatalf("SPI Tx with buffers of %d and %d bytes", len(w), len(r))
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		var out byte
+		if w != nil {
+			out = w[i]
+		}
+		in := bus.exchange(out)
+		if r != nil {
+			r[i] = in
+		}
+	}
+	return nil
+}
+
+// Transfer implements drivers.SPI.
+func (bus *SPIBus) Transfer(b byte) (byte, error) {
+	return bus.exchange(b), nil
+}
+
+// exchange sends a byte to the selected device and records it.
+func (bus *SPIBus) exchange(b byte) byte {
+	var dev SPIDevice
+	for i, cs := range bus.pins {
+		if cs.Get() {
+			continue
+		}
+		if dev != nil {
+			bus.c.Fatalf("SPI transfer with more than one device selected")
+			return 0xFF
+		}
+		dev = bus.devices[i]
+	}
+	in := byte(0xFF) // the pull-up of SDI
+	if dev != nil {
+		in, _ = dev.Transfer(b)
+	}
+
+	if !bus.open {
+		bus.Transactions = append(bus.Transactions, SPITransaction{Device: dev, Data: bus.DC.Get()})
+		bus.open = true
+	}
+	t := &bus.Transactions[len(bus.Transactions)-1]
+	t.W = append(t.W, b)
+	t.R = append(t.R, in)
+	return in
+}
+
+// split starts a new transaction at the next byte, after a chip select or
+// the data/command line changed.
+func (bus *SPIBus) split(bool) {
+	bus.open = false
+}
@@ -0,0 +1,141 @@
+package tester
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSPIBus(t *testing.T) {
+	c := qt.New(t)
+	bus := NewSPIBus(c)
+	dev1 := NewSPIDevice8(c)
+	dev2 := NewSPIDevice8(c)
+	dev2.WriteHigh = true
+	cs1 := bus.AddDevice(dev1)
+	cs2 := bus.AddDevice(dev2)
+	c.Assert(cs1.Get(), qt.IsTrue)
+
+	// Write two registers of the first device, and read them back.
+	cs1.Low()
+	c.Assert(bus.Tx([]byte{0x10, 0xAB, 0xCD}, nil), qt.IsNil)
+	cs1.High()
+	c.Assert(dev1.Registers[0x10:0x12], qt.DeepEquals, []byte{0xAB, 0xCD})
+	cs1.Low()
+	r := make([]byte, 3)
+	c.Assert(bus.Tx([]byte{0x90, 0, 0}, r), qt.IsNil)
+	cs1.High()
+	c.Assert(r, qt.DeepEquals, []byte{0, 0xAB, 0xCD})
+
+	// The second device sets bit 7 to write.
+	cs2.Low()
+	bus.Transfer(0x85)
+	bus.Transfer(0x42)
+	cs2.High()
+	c.Assert(dev2.Registers[5], qt.Equals, byte(0x42))
+	c.Assert(dev1.Registers[5], qt.Equals, byte(0))
+	cs2.Low()
+	bus.Transfer(0x05)
+	v, err := bus.Transfer(0)
+	cs2.High()
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.Equals, byte(0x42))
+
+	c.Assert(bus.Transactions, qt.HasLen, 4)
+	tr := bus.Transactions[1]
+	c.Assert(tr.Device, qt.Equals, SPIDevice(dev1))
+	c.Assert(tr.W, qt.DeepEquals, []byte{0x90, 0, 0})
+	c.Assert(tr.R, qt.DeepEquals, []byte{0, 0xAB, 0xCD})
+	c.Assert(bus.Transactions[3].Device, qt.Equals, SPIDevice(dev2))
+
+	// Without a selected device the bus reads 0xFF.
+	bus.Reset()
+	v, _ = bus.Transfer(0x12)
+	c.Assert(v, qt.Equals, byte(0xFF))
+	c.Assert(bus.Transactions, qt.DeepEquals, []SPITransaction{{W: []byte{0x12}, R: []byte{0xFF}}})
+}
+
+func TestSPIBusDataCommand(t *testing.T) {
+	c := qt.New(t)
+	bus := NewSPIBus(c)
+
+	// A display controller: a command followed by data, without chip select.
+	bus.DC.Low()
+	bus.Tx([]byte{0x2A}, nil)
+	bus.DC.High()
+	bus.Tx([]byte{0x00, 0x10}, nil)
+	bus.Tx([]byte{0x00, 0x20}, nil)
+	bus.DC.Low()
+	bus.Transfer(0x2C)
+	c.Assert(bus.Transactions, qt.DeepEquals, []SPITransaction{
+		{W: []byte{0x2A}, R: []byte{0xFF}},
+		{Data: true, W: []byte{0x00, 0x10, 0x00, 0x20}, R: []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+		{W: []byte{0x2C}, R: []byte{0xFF}},
+	})
+	c.Assert(bus.Written(false), qt.DeepEquals, []byte{0x2A, 0x2C})
+	c.Assert(bus.Written(true), qt.DeepEquals, []byte{0x00, 0x10, 0x00, 0x20})
+}
+
+func TestSPIBusContention(t *testing.T) {
+	c := qt.New(t)
+	var f failures
+	bus := NewSPIBus(&f)
+	bus.AddDevice(NewSPIDevice8(c)).Low()
+	bus.AddDevice(NewSPIDevice8(c)).Low()
+	bus.Transfer(0)
+	c.Assert(f, qt.DeepEquals, failures{"SPI transfer with more than one device selected"})
+}
+
+func TestSPIScript(t *testing.T) {
+	c := qt.New(t)
+	bus := NewSPIBus(c)
+	script := NewSPIScript(c)
+	cs := bus.AddDevice(script)
+	script.Expect([]byte{0x9F, 0, 0, 0}, []byte{0xFF, 0xEF, 0x40, 0x18})
+	script.Expect([]byte{0x06}, nil)
+
+	cs.Low()
+	r := make([]byte, 4)
+	bus.Tx([]byte{0x9F, 0, 0, 0}, r)
+	cs.High()
+	c.Assert(r, qt.DeepEquals, []byte{0xFF, 0xEF, 0x40, 0x18})
+	cs.Low()
+	bus.Transfer(0x06)
+	cs.High()
+	script.Done()
+
+	// Failures.
+	var f failures
+	script = NewSPIScript(&f)
+	cs = bus.AddDevice(script)
+	script.Expect([]byte{0x03, 0x00}, nil)
+	script.Expect([]byte{0x05}, nil)
+	cs.Low()
+	bus.Transfer(0x03)
+	cs.High()
+	cs.Low()
+	bus.Transfer(0x04)
+	cs.High()
+	bus.Transfer(0x05)
+	script.Done()
+	c.Assert(f, qt.DeepEquals, failures{
+		"SPI transaction 0: got 1 bytes, want 03 00",
+		"SPI transaction 1: byte 0 is 0x4, want 0x5",
+		"SPI transaction 1 of 2 didn't happen: 05",
+	})
+}
+
+func TestSPIBusMCP2515(t *testing.T) {
+	c := qt.New(t)
+	bus := NewSPIBus(c)
+	chip := NewMCP2515()
+	cs := bus.AddDevice(chip)
+
+	// Read the CANSTAT register, which is in configuration mode.
+	cs.Low()
+	r := make([]byte, 3)
+	bus.Tx([]byte{0x03, 0x0E, 0}, r)
+	cs.High()
+	c.Assert(r[2], qt.Equals, byte(0x80))
+	c.Assert(bus.Transactions[0].Device, qt.Equals, SPIDevice(chip))
+}
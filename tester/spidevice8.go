@@ -0,0 +1,74 @@
+package tester
+
+// SPIDevice8 is a mock SPI device with 8-bit registers, like most SPI
+// sensors. The first byte of a transaction is the register address in the
+// lower 7 bits, with bit 7 telling a read from a write, followed by the data
+// of consecutive registers.
+type SPIDevice8 struct {
+	c Failer
+	// Registers holds the device registers. It can be inspected
+	// or changed as desired for testing.
+	Registers [128]uint8
+	// WriteHigh is set for devices whose bit 7 of the address is set to
+	// write, such as the SX127x. Other devices set it to read, such as the
+	// BMI160.
+	WriteHigh bool
+
+	selected bool
+	n        int // bytes since the device was selected
+	reg      uint8
+	read     bool
+}
+
+// NewSPIDevice8 returns a new mock SPI device, whose bit 7 of the address is
+// set to read.
+func NewSPIDevice8(c Failer) *SPIDevice8 {
+	return &SPIDevice8{c: c}
+}
+
+// Set implements SPIDevice.
+func (d *SPIDevice8) Set(high bool) {
+	d.selected = !high
+	d.n = 0
+}
+
+// Tx implements drivers.SPI.
+func (d *SPIDevice8) Tx(w, r []byte) error {
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	}
+	for i := 0; i < n; i++ {
+		var b byte
+		if w != nil {
+			b = w[i]
+		}
+		v, _ := d.Transfer(b)
+		if r != nil {
+			r[i] = v
+		}
+	}
+	return nil
+}
+
+// Transfer implements drivers.SPI.
+func (d *SPIDevice8) Transfer(b byte) (byte, error) {
+	if !d.selected {
+		d.c.Fatalf("SPI transfer %#x while the device is not selected", b)
+		return 0xFF, nil
+	}
+	d.n++
+	if d.n == 1 {
+		d.reg = b & 0x7F
+		d.read = (b&0x80 != 0) != d.WriteHigh
+		return 0, nil
+	}
+	var v byte
+	if d.read {
+		v = d.Registers[d.reg]
+	} else {
+		d.Registers[d.reg] = b
+	}
+	d.reg = (d.reg + 1) & 0x7F
+	return v, nil
+}
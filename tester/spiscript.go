@@ -0,0 +1,93 @@
+package tester
+
+// SPIScript is a mock SPI device that expects a sequence of transactions, and
+// replies to each one with the given bytes. It fails the test at the first
+// byte that differs from the script.
+type SPIScript struct {
+	c        Failer
+	steps    []spiStep
+	step     int // current transaction
+	n        int // bytes of the current transaction
+	selected bool
+}
+
+type spiStep struct {
+	w, r []byte
+}
+
+// NewSPIScript returns a mock SPI device with an empty script.
+func NewSPIScript(c Failer) *SPIScript {
+	return &SPIScript{c: c}
+}
+
+// Expect adds a transaction to the script, in which the device receives the
+// bytes w and sends back the bytes r at the same time. If r is shorter than w
+// the device sends zeros after it.
+func (s *SPIScript) Expect(w, r []byte) {
+	s.steps = append(s.steps, spiStep{w, r})
+}
+
+// Done fails the test if some transactions of the script didn't happen.
+func (s *SPIScript) Done() {
+	if s.step < len(s.steps) {
+		s.c.Fatalf("SPI transaction %d of %d didn't happen: % x", s.step, len(s.steps), s.steps[s.step].w)
+	}
+}
+
+// Set implements SPIDevice. Deselecting the device ends a transaction.
+func (s *SPIScript) Set(high bool) {
+	if high && s.selected && s.n > 0 {
+		if w := s.steps[s.step].w; s.n != len(w) {
+			s.c.Fatalf("SPI transaction %d: got %d bytes, want % x", s.step, s.n, w)
+		}
+		s.step++
+		s.n = 0
+	}
+	s.selected = !high
+}
+
+// Tx implements drivers.SPI.
+func (s *SPIScript) Tx(w, r []byte) error {
+	n := len(w)
+	if w == nil {
+		n = len(r)
+	}
+	for i := 0; i < n; i++ {
+		var b byte
+		if w != nil {
+			b = w[i]
+		}
+		v, _ := s.Transfer(b)
+		if r != nil {
+			r[i] = v
+		}
+	}
+	return nil
+}
+
+// Transfer implements drivers.SPI.
+func (s *SPIScript) Transfer(b byte) (byte, error) {
+	if !s.selected {
+		s.c.Fatalf("SPI transfer %#x while the device is not selected", b)
+		return 0xFF, nil
+	}
+	if s.step >= len(s.steps) {
+		s.c.Fatalf("SPI transfer %#x after the end of the script", b)
+		return 0xFF, nil
+	}
+	st := s.steps[s.step]
+	if s.n >= len(st.w) {
+		s.c.Fatalf("SPI transaction %d: more than % x", s.step, st.w)
+		return 0xFF, nil
+	}
+	if b != st.w[s.n] {
+		s.c.Fatalf("SPI transaction %d: byte %d is %#x, want %#x", s.step, s.n, b, st.w[s.n])
+		return 0xFF, nil
+	}
+	var v byte
+	if s.n < len(st.r) {
+		v = st.r[s.n]
+	}
+	s.n++
+	return v, nil
+}
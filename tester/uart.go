@@ -0,0 +1,121 @@
+package tester
+
+import (
+	"strings"
+	"time"
+)
+
+// UART is a mock UART, which implements drivers.UART. The driver reads the
+// bytes added with Inject, and the replies to the writes scripted with
+// Expect. The written bytes are recorded in Written.
+//
+// Bytes become readable, and are counted by Buffered, at the time they are
+// due, so drivers that poll the UART see a reply arrive after a delay.
+type UART struct {
+	c       Failer
+	rx      []byte
+	pending []uartReply
+	steps   []uartStep
+	step    int
+	partial []byte // bytes written of the current step
+
+	// Written are the bytes written by the driver.
+	Written []byte
+}
+
+type uartReply struct {
+	at   time.Time
+	data []byte
+}
+
+type uartStep struct {
+	w, reply string
+	delay    time.Duration
+}
+
+// NewUART returns a mock UART without data and script.
+func NewUART(c Failer) *UART {
+	return &UART{c: c}
+}
+
+// Inject adds bytes that the driver can read, such as a stream of NMEA
+// sentences.
+func (u *UART) Inject(data []byte) {
+	u.InjectAfter(data, 0)
+}
+
+// InjectAfter adds bytes that the driver can read after a delay, once the
+// bytes injected before them are readable.
+func (u *UART) InjectAfter(data []byte, delay time.Duration) {
+	at := time.Now().Add(delay)
+	if n := len(u.pending); n > 0 && u.pending[n-1].at.After(at) {
+		at = u.pending[n-1].at
+	}
+	u.pending = append(u.pending, uartReply{at, append([]byte(nil), data...)})
+}
+
+// Expect adds a step to the script: when the driver has written w, reply is
+// readable after the delay. The writes must follow the script, and bytes
+// written after its end fail the test. Without script, any write is allowed.
+func (u *UART) Expect(w, reply string, delay time.Duration) {
+	u.steps = append(u.steps, uartStep{w, reply, delay})
+}
+
+// Done fails the test if some writes of the script didn't happen.
+func (u *UART) Done() {
+	if u.step < len(u.steps) {
+		u.c.Fatalf("UART write %d of %d didn't happen: %q", u.step, len(u.steps), u.steps[u.step].w)
+	}
+}
+
+// Read implements io.Reader. It returns the readable bytes, without waiting
+// for more.
+func (u *UART) Read(p []byte) (int, error) {
+	u.receive()
+	n := copy(p, u.rx)
+	u.rx = u.rx[n:]
+	return n, nil
+}
+
+// Buffered implements drivers.UART. It returns the number of readable bytes.
+func (u *UART) Buffered() int {
+	u.receive()
+	return len(u.rx)
+}
+
+// Write implements io.Writer.
+func (u *UART) Write(p []byte) (int, error) {
+	u.Written = append(u.Written, p...)
+	if len(u.steps) == 0 {
+		return len(p), nil
+	}
+	for _, b := range p {
+		if u.step >= len(u.steps) {
+			u.c.Fatalf("UART write %q after the end of the script", p)
+			return len(p), nil
+		}
+		st := u.steps[u.step]
+		u.partial = append(u.partial, b)
+		if !strings.HasPrefix(st.w, string(u.partial)) {
+			u.c.Fatalf("UART write %d is %q, want %q", u.step, u.partial, st.w)
+			return len(p), nil
+		}
+		if len(u.partial) == len(st.w) {
+			u.InjectAfter([]byte(st.reply), st.delay)
+			u.partial = u.partial[:0]
+			u.step++
+		}
+	}
+	return len(p), nil
+}
+
+// receive moves the bytes that are due to the receive buffer.
+func (u *UART) receive() {
+	now := time.Now()
+	n := 0
+	for n < len(u.pending) && !u.pending[n].at.After(now) {
+		u.rx = append(u.rx, u.pending[n].data...)
+		n++
+	}
+	u.pending = u.pending[n:]
+}
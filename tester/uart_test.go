@@ -0,0 +1,67 @@
+package tester
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestUARTInject(t *testing.T) {
+	c := qt.New(t)
+	u := NewUART(c)
+	c.Assert(u.Buffered(), qt.Equals, 0)
+
+	u.Inject([]byte("$GPGLL"))
+	u.InjectAfter([]byte(",4916.45,N"), 30*time.Millisecond)
+	u.Inject([]byte("*31\r\n")) // after the delayed bytes
+	c.Assert(u.Buffered(), qt.Equals, 6)
+	buf := make([]byte, 4)
+	n, err := u.Read(buf)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(buf[:n]), qt.Equals, "$GPG")
+	c.Assert(u.Buffered(), qt.Equals, 2)
+
+	time.Sleep(40 * time.Millisecond)
+	buf = make([]byte, 64)
+	n, _ = u.Read(buf)
+	c.Assert(string(buf[:n]), qt.Equals, "LL,4916.45,N*31\r\n")
+	n, _ = u.Read(buf)
+	c.Assert(n, qt.Equals, 0)
+}
+
+func TestUARTScript(t *testing.T) {
+	c := qt.New(t)
+	u := NewUART(c)
+	u.Expect("AT\r\n", "\r\nOK\r\n", 0)
+	u.Expect("AT+CWJAP=\"ssid\",\"pass\"\r\n", "WIFI CONNECTED\r\n\r\nOK\r\n", 30*time.Millisecond)
+
+	u.Write([]byte("AT\r\n"))
+	c.Assert(u.Buffered(), qt.Equals, 6)
+	// A command written in pieces.
+	u.Write([]byte("AT+CWJAP="))
+	u.Write([]byte("\"ssid\",\"pass\"\r\n"))
+	c.Assert(u.Buffered(), qt.Equals, 6)
+	time.Sleep(40 * time.Millisecond)
+	c.Assert(u.Buffered(), qt.Equals, 6+22)
+	u.Done()
+	c.Assert(string(u.Written), qt.Equals, "AT\r\nAT+CWJAP=\"ssid\",\"pass\"\r\n")
+
+	// Failures.
+	var f failures
+	u = NewUART(&f)
+	u.Expect("AT+RST\r\n", "OK\r\n", 0)
+	u.Expect("AT+GMR\r\n", "OK\r\n", 0)
+	u.Write([]byte("AT+RST\r\n"))
+	u.Write([]byte("AT+CWMODE=1\r\n"))
+	u.Done()
+	c.Assert(f, qt.DeepEquals, failures{
+		`UART write 1 is "AT+C", want "AT+GMR\r\n"`,
+		`UART write 1 of 2 didn't happen: "AT+GMR\r\n"`,
+	})
+	f = nil
+	u = NewUART(&f)
+	u.Expect("AT\r\n", "OK\r\n", 0)
+	u.Write([]byte("AT\r\nAT\r\n"))
+	c.Assert(f, qt.DeepEquals, failures{`UART write "AT\r\nAT\r\n" after the end of the script`})
+}
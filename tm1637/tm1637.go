@@ -4,19 +4,21 @@
 package tm1637
 
 import (
-	"machine"
 	"time"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 // Device wraps the pins of the TM1637.
 type Device struct {
-	clk        machine.Pin
-	dio        machine.Pin
+	clk        drivers.PinOutput
+	dio        drivers.PinOutput
 	brightness uint8
 }
 
 // New creates a new TM1637 device.
-func New(clk machine.Pin, dio machine.Pin, brightness uint8) Device {
+func New(clk drivers.PinOutput, dio drivers.PinOutput, brightness uint8) Device {
 	return Device{clk: clk, dio: dio, brightness: brightness}
 }
 
@@ -147,16 +149,16 @@ func delaytm() {
 	time.Sleep(time.Microsecond * time.Duration(TM1637_DELAY))
 }
 
-func pinMode(pin machine.Pin, mode bool) {
+func pinMode(pin drivers.PinOutput, mode bool) {
 	// TM1637 has internal pull-up resistors for both CLK and DIO pins.
 	// Set them to input mode will pull them high,
 	// and set them to output mode will pull them down
 	// (since we did so in the beginning.)
 	// The High()/Low() method don't work on some boards.
 	if mode {
-		pin.Configure(machine.PinConfig{Mode: machine.PinInput})
+		legacy.ConfigurePin(pin, drivers.PinModeInput)
 	} else {
-		pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		legacy.ConfigurePin(pin, drivers.PinModeOutput)
 	}
 }
 
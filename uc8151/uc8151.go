@@ -7,10 +7,11 @@ package uc8151 // import "tinygo.org/x/drivers/uc8151"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 type Config struct {
@@ -23,10 +24,10 @@ type Config struct {
 
 type Device struct {
 	bus          drivers.SPI
-	cs           machine.Pin
-	dc           machine.Pin
-	rst          machine.Pin
-	busy         machine.Pin
+	cs           drivers.PinOutput
+	dc           drivers.PinOutput
+	rst          drivers.PinOutput
+	busy         drivers.PinInput
 	width        int16
 	height       int16
 	buffer       []uint8
@@ -34,17 +35,18 @@ type Device struct {
 	rotation     Rotation
 	speed        Speed
 	blocking     bool
+	dirty        dirty.Rect // bytes and rows changed since the last Display
 }
 
 type Rotation uint8
 type Speed uint8
 
 // New returns a new epd2in13x driver. Pass in a fully configured SPI bus.
-func New(bus drivers.SPI, csPin, dcPin, rstPin, busyPin machine.Pin) Device {
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	rstPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	busyPin.Configure(machine.PinConfig{Mode: machine.PinInput})
+func New(bus drivers.SPI, csPin, dcPin, rstPin drivers.PinOutput, busyPin drivers.PinInput) Device {
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(rstPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(busyPin, drivers.PinModeInput)
 	return Device{
 		bus:  bus,
 		cs:   csPin,
@@ -74,6 +76,7 @@ func (d *Device) Configure(cfg Config) {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.buffer[i] = 0xFF
 	}
+	d.dirty.All(d.width/8, d.height)
 
 	d.Reset()
 
@@ -168,15 +171,32 @@ func (d *Device) SetPixel(x int16, y int16, c color.RGBA) {
 		return
 	}
 	byteIndex := x/8 + y*(d.width/8)
+	b := d.buffer[byteIndex]
 	if c.R == 0 && c.G == 0 && c.B == 0 { // TRANSPARENT / WHITE
-		d.buffer[byteIndex] &^= 0x80 >> uint8(x%8)
+		b &^= 0x80 >> uint8(x%8)
 	} else { // WHITE / EMPTY
-		d.buffer[byteIndex] |= 0x80 >> uint8(x%8)
+		b |= 0x80 >> uint8(x%8)
+	}
+	if b != d.buffer[byteIndex] {
+		d.buffer[byteIndex] = b
+		d.dirty.Add(x/8, y)
 	}
 }
 
-// Display sends the buffer to the screen.
+// Display sends the buffer to the screen. If only a part of the buffer changed
+// since the last call, only that area is sent and refreshed, as DisplayRect
+// does.
 func (d *Device) Display() error {
+	if d.dirty.Empty() {
+		return nil
+	}
+	if !d.dirty.IsAll(d.width/8, d.height) {
+		err := d.displayRect(d.dirty.X0*8, d.dirty.Y0, (d.dirty.X1+1)*8, d.dirty.Y1+1)
+		d.dirty.Reset()
+		return err
+	}
+	d.dirty.Reset()
+
 	if d.blocking {
 		d.WaitUntilIdle()
 	}
@@ -200,10 +220,6 @@ func (d *Device) Display() error {
 // The rectangle points need to be a multiple of 8 in the screen.
 // They might not work as expected if the screen is rotated.
 func (d *Device) DisplayRect(x int16, y int16, width int16, height int16) error {
-	if d.blocking {
-		d.WaitUntilIdle()
-	}
-
 	x, y = d.xy(x, y)
 	if x < 0 || y < 0 || x >= d.width || y >= d.height || width < 0 || height < 0 {
 		return errors.New("wrong rectangle")
@@ -228,24 +244,31 @@ func (d *Device) DisplayRect(x int16, y int16, width int16, height int16) error
 	if height > d.height {
 		height = d.height
 	}
+	return d.displayRect(x, y, width, height)
+}
+
+// displayRect sends the area of the buffer from x0, y0 to x1, y1 (exclusive)
+// in the display coordinates to the screen. x0 and x1 are multiples of 8.
+func (d *Device) displayRect(x0, y0, x1, y1 int16) error {
+	if d.blocking {
+		d.WaitUntilIdle()
+	}
 
 	d.SendCommand(PON)
 	d.SendCommand(PTIN)
 	d.SendCommand(PTL)
 
-	d.SendData(uint8(x))
-	d.SendData(uint8(x+width-1) | 0x07)
-	d.SendData(uint8(y >> 8))
-	d.SendData(uint8(y))
-	d.SendData(uint8((y + height - 1) >> 8))
-	d.SendData(uint8(y + height - 1))
+	d.SendData(uint8(x0))
+	d.SendData(uint8(x1-1) | 0x07)
+	d.SendData(uint8(y0 >> 8))
+	d.SendData(uint8(y0))
+	d.SendData(uint8((y1 - 1) >> 8))
+	d.SendData(uint8(y1 - 1))
 	d.SendData(0x01)
 
 	d.SendCommand(DTM2)
-	x = x / 8
-	width = width / 8
-	for ; y < height; y++ {
-		for i := x; i < width; i++ {
+	for y := y0; y < y1; y++ {
+		for i := x0 / 8; i < x1/8; i++ {
 			d.SendData(d.buffer[i+y*(d.width/8)])
 		}
 	}
@@ -283,6 +306,7 @@ func (d *Device) ClearBuffer() {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.buffer[i] = 0x00
 	}
+	d.dirty.All(d.width/8, d.height)
 }
 
 // Size returns the current size of the display.
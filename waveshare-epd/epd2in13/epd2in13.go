@@ -6,10 +6,11 @@ package epd2in13 // import "tinygo.org/x/drivers/waveshare-epd/epd2in13"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 type Config struct {
@@ -21,16 +22,18 @@ type Config struct {
 
 type Device struct {
 	bus          drivers.SPI
-	cs           machine.Pin
-	dc           machine.Pin
-	rst          machine.Pin
-	busy         machine.Pin
+	cs           drivers.PinOutput
+	dc           drivers.PinOutput
+	rst          drivers.PinOutput
+	busy         drivers.PinInput
 	logicalWidth int16
 	width        int16
 	height       int16
 	buffer       []uint8
 	bufferLength uint32
 	rotation     Rotation
+	dirty        dirty.Rect // bytes and rows changed since the last Display
+	previous     dirty.Rect // area sent by the last Display
 }
 
 type Rotation uint8
@@ -52,11 +55,11 @@ var lutPartialUpdate = [30]uint8{
 }
 
 // New returns a new epd2in13x driver. Pass in a fully configured SPI bus.
-func New(bus drivers.SPI, csPin, dcPin, rstPin, busyPin machine.Pin) Device {
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	rstPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	busyPin.Configure(machine.PinConfig{Mode: machine.PinInput})
+func New(bus drivers.SPI, csPin, dcPin, rstPin drivers.PinOutput, busyPin drivers.PinInput) Device {
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(rstPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(busyPin, drivers.PinModeInput)
 	return Device{
 		bus:  bus,
 		cs:   csPin,
@@ -89,6 +92,7 @@ func (d *Device) Configure(cfg Config) {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.buffer[i] = 0xFF
 	}
+	d.dirty.All(d.logicalWidth/8, d.height)
 
 	d.cs.Low()
 	d.dc.Low()
@@ -176,20 +180,37 @@ func (d *Device) SetPixel(x int16, y int16, c color.RGBA) {
 		return
 	}
 	byteIndex := (x + y*d.logicalWidth) / 8
+	b := d.buffer[byteIndex]
 	if c.R == 0 && c.G == 0 && c.B == 0 { // TRANSPARENT / WHITE
-		d.buffer[byteIndex] |= 0x80 >> uint8(x%8)
+		b |= 0x80 >> uint8(x%8)
 	} else { // WHITE / EMPTY
-		d.buffer[byteIndex] &^= 0x80 >> uint8(x%8)
+		b &^= 0x80 >> uint8(x%8)
+	}
+	if b != d.buffer[byteIndex] {
+		d.buffer[byteIndex] = b
+		d.dirty.Add(x/8, y)
 	}
 }
 
-// Display sends the buffer to the screen.
+// Display sends the part of the buffer that changed since the last call to
+// the screen, and updates it.
 func (d *Device) Display() error {
-	d.setMemoryArea(0, 0, d.logicalWidth-1, d.height-1)
-	for j := int16(0); j < d.height; j++ {
-		d.setMemoryPointer(0, j)
+	if d.dirty.Empty() {
+		return nil
+	}
+	// The controller switches between two RAM banks at each update, so the
+	// other bank also lacks the area sent by the previous update.
+	area := d.dirty
+	area.Union(d.previous)
+	d.previous = d.dirty
+	d.dirty.Reset()
+
+	x0, x1 := area.X0*8, area.X1*8+7
+	d.setMemoryArea(x0, area.Y0, x1, area.Y1)
+	for j := area.Y0; j <= area.Y1; j++ {
+		d.setMemoryPointer(x0, j)
 		d.SendCommand(WRITE_RAM)
-		for i := int16(0); i < d.logicalWidth/8; i++ {
+		for i := area.X0; i <= area.X1; i++ {
 			d.SendData(d.buffer[i+j*(d.logicalWidth/8)])
 		}
 	}
@@ -239,6 +260,8 @@ func (d *Device) DisplayRect(x int16, y int16, width int16, height int16) error
 			d.SendData(d.buffer[i+y*d.logicalWidth/8])
 		}
 	}
+	// The RAM banks no longer match the last Display
+	d.dirty.All(d.logicalWidth/8, d.height)
 
 	d.SendCommand(DISPLAY_UPDATE_CONTROL_2)
 	d.SendData(0xC4)
@@ -255,6 +278,7 @@ func (d *Device) ClearDisplay() {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.SendData(0xFF)
 	}
+	d.dirty.All(d.logicalWidth/8, d.height)
 	d.Display()
 }
 
@@ -297,6 +321,7 @@ func (d *Device) ClearBuffer() {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.buffer[i] = 0xFF
 	}
+	d.dirty.All(d.logicalWidth/8, d.height)
 }
 
 // Size returns the current size of the display.
@@ -6,10 +6,11 @@ package epd2in13x // import "tinygo.org/x/drivers/waveshare-epd/epd2in13x"
 import (
 	"errors"
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 type Config struct {
@@ -20,24 +21,25 @@ type Config struct {
 
 type Device struct {
 	bus          drivers.SPI
-	cs           machine.Pin
-	dc           machine.Pin
-	rst          machine.Pin
-	busy         machine.Pin
+	cs           drivers.PinOutput
+	dc           drivers.PinOutput
+	rst          drivers.PinOutput
+	busy         drivers.PinInput
 	width        int16
 	height       int16
 	buffer       [][]uint8
 	bufferLength uint32
+	dirty        dirty.Rect // bytes and rows changed since the last Display
 }
 
 type Color uint8
 
 // New returns a new epd2in13x driver. Pass in a fully configured SPI bus.
-func New(bus drivers.SPI, csPin, dcPin, rstPin, busyPin machine.Pin) Device {
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	rstPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	busyPin.Configure(machine.PinConfig{Mode: machine.PinInput})
+func New(bus drivers.SPI, csPin, dcPin, rstPin drivers.PinOutput, busyPin drivers.PinInput) Device {
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(rstPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(busyPin, drivers.PinModeInput)
 	return Device{
 		bus:  bus,
 		cs:   csPin,
@@ -74,6 +76,7 @@ func (d *Device) Configure(cfg Config) {
 			d.buffer[i][j] = 0xFF
 		}
 	}
+	d.dirty.All(d.width/8, d.height)
 
 	d.cs.Low()
 	d.dc.Low()
@@ -159,56 +162,87 @@ func (d *Device) SetEPDPixel(x int16, y int16, c Color) {
 		return
 	}
 	byteIndex := (x + y*d.width) / 8
+	black, colored := d.buffer[BLACK-1][byteIndex], d.buffer[COLORED-1][byteIndex]
 	if c == WHITE {
-		d.buffer[BLACK-1][byteIndex] |= 0x80 >> uint8(x%8)
-		d.buffer[COLORED-1][byteIndex] |= 0x80 >> uint8(x%8)
+		black |= 0x80 >> uint8(x%8)
+		colored |= 0x80 >> uint8(x%8)
 	} else if c == COLORED {
-		d.buffer[BLACK-1][byteIndex] |= 0x80 >> uint8(x%8)
-		d.buffer[COLORED-1][byteIndex] &^= 0x80 >> uint8(x%8)
+		black |= 0x80 >> uint8(x%8)
+		colored &^= 0x80 >> uint8(x%8)
 	} else { // BLACK
-		d.buffer[COLORED-1][byteIndex] |= 0x80 >> uint8(x%8)
-		d.buffer[BLACK-1][byteIndex] &^= 0x80 >> uint8(x%8)
+		colored |= 0x80 >> uint8(x%8)
+		black &^= 0x80 >> uint8(x%8)
+	}
+	if black != d.buffer[BLACK-1][byteIndex] || colored != d.buffer[COLORED-1][byteIndex] {
+		d.buffer[BLACK-1][byteIndex] = black
+		d.buffer[COLORED-1][byteIndex] = colored
+		d.dirty.Add(x/8, y)
 	}
 }
 
-// Display sends the buffer (if any) to the screen.
+// Display sends the part of the buffer that changed since the last call to
+// the screen, and refreshes it.
 func (d *Device) Display() error {
+	if d.dirty.Empty() {
+		return nil
+	}
+	area := d.dirty
+	d.dirty.Reset()
+	full := area.IsAll(d.width/8, d.height)
+
+	if !full {
+		d.setPartialWindow(area.X0*8, area.Y0, (area.X1-area.X0+1)*8, area.Y1-area.Y0+1)
+	}
 	d.SendCommand(DATA_START_TRANSMISSION_1) // black
 	time.Sleep(2 * time.Millisecond)
-	for i := uint32(0); i < d.bufferLength; i++ {
-		d.SendData(d.buffer[BLACK-1][i])
-	}
+	d.sendArea(d.buffer[BLACK-1], area)
 	time.Sleep(2 * time.Millisecond)
 	d.SendCommand(DATA_START_TRANSMISSION_2) // red
 	time.Sleep(2 * time.Millisecond)
-	for i := uint32(0); i < d.bufferLength; i++ {
-		d.SendData(d.buffer[COLORED-1][i])
-	}
+	d.sendArea(d.buffer[COLORED-1], area)
 	time.Sleep(2 * time.Millisecond)
+	if !full {
+		d.SendCommand(PARTIAL_OUT)
+	}
 	d.SendCommand(DISPLAY_REFRESH)
 	return nil
 }
 
-// SetDisplayRect sends a rectangle of data at specific coordinates to the device SRAM directly
-func (d *Device) SetDisplayRect(buffer [][]uint8, x int16, y int16, w int16, h int16) error {
-	if w%8 != 0 {
-		return errors.New("rectangle width needs to be a multiple of 8")
-	}
-	for i := range buffer {
-		if int16(len(buffer[i])) < (w/8)*h {
-			return errors.New("buffer has the wrong size")
+// sendArea sends the bytes of a color buffer in an area, row by row.
+func (d *Device) sendArea(buffer []uint8, area dirty.Rect) {
+	for j := area.Y0; j <= area.Y1; j++ {
+		for i := area.X0; i <= area.X1; i++ {
+			d.SendData(buffer[i+j*(d.width/8)])
 		}
 	}
+}
+
+// setPartialWindow restricts the data transmissions to a rectangle of the
+// device SRAM. x and w need to be multiples of 8.
+func (d *Device) setPartialWindow(x int16, y int16, w int16, h int16) {
 	d.SendCommand(PARTIAL_IN)
 	d.SendCommand(PARTIAL_WINDOW)
 	d.SendData(uint8(x) & 0xF8)
 	d.SendData(((uint8(x) & 0xF8) + uint8(w) - 1) | 0x07)
-	d.SendData(uint8(y) >> 8)
+	d.SendData(uint8(y >> 8))
 	d.SendData(uint8(y) & 0xFF)
-	d.SendData(uint8(y+h-1) >> 8)
+	d.SendData(uint8((y + h - 1) >> 8))
 	d.SendData(uint8(y+h-1) & 0xFF)
 	d.SendData(0x01)
 	time.Sleep(2 * time.Millisecond)
+}
+
+// SetDisplayRect sends a rectangle of data at specific coordinates to the device SRAM directly
+func (d *Device) SetDisplayRect(buffer [][]uint8, x int16, y int16, w int16, h int16) error {
+	if w%8 != 0 {
+		return errors.New("rectangle width needs to be a multiple of 8")
+	}
+	for i := range buffer {
+		if int16(len(buffer[i])) < (w/8)*h {
+			return errors.New("buffer has the wrong size")
+		}
+	}
+	d.setPartialWindow(x, y, w, h)
 	d.SendCommand(DATA_START_TRANSMISSION_1)
 	for i := int16(0); i < (w/8)*h; i++ {
 		d.SendData(buffer[BLACK-1][i])
@@ -222,6 +256,8 @@ func (d *Device) SetDisplayRect(buffer [][]uint8, x int16, y int16, w int16, h i
 		time.Sleep(2 * time.Millisecond)
 	}
 	d.SendCommand(PARTIAL_OUT)
+	// The SRAM no longer matches the buffer
+	d.dirty.All(d.width/8, d.height)
 	return nil
 }
 
@@ -236,16 +272,7 @@ func (d *Device) SetDisplayRectColor(buffer []uint8, x int16, y int16, w int16,
 	if c == WHITE {
 		return errors.New("wrong color")
 	}
-	d.SendCommand(PARTIAL_IN)
-	d.SendCommand(PARTIAL_WINDOW)
-	d.SendData(uint8(x) & 0xF8)
-	d.SendData(((uint8(x) & 0xF8) + uint8(w) - 1) | 0x07)
-	d.SendData(uint8(y) >> 8)
-	d.SendData(uint8(y) & 0xFF)
-	d.SendData(uint8(y+h-1) >> 8)
-	d.SendData(uint8(y+h-1) & 0xFF)
-	d.SendData(0x01)
-	time.Sleep(2 * time.Millisecond)
+	d.setPartialWindow(x, y, w, h)
 	if c == COLORED {
 		d.SendCommand(DATA_START_TRANSMISSION_2)
 	} else {
@@ -256,6 +283,8 @@ func (d *Device) SetDisplayRectColor(buffer []uint8, x int16, y int16, w int16,
 	}
 	time.Sleep(2 * time.Millisecond)
 	d.SendCommand(PARTIAL_OUT)
+	// The SRAM no longer matches the buffer
+	d.dirty.All(d.width/8, d.height)
 	return nil
 }
 
@@ -273,6 +302,7 @@ func (d *Device) ClearDisplay() {
 		d.SendData(0xFF)
 	}
 	time.Sleep(2 * time.Millisecond)
+	d.dirty.All(d.width/8, d.height)
 }
 
 // WaitUntilIdle waits until the display is ready
@@ -294,6 +324,7 @@ func (d *Device) ClearBuffer() {
 			d.buffer[i][j] = 0xFF
 		}
 	}
+	d.dirty.All(d.width/8, d.height)
 }
 
 // Size returns the current size of the display.
@@ -10,10 +10,11 @@ package epd4in2
 
 import (
 	"image/color"
-	"machine"
 	"time"
 
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/internal/dirty"
+	"tinygo.org/x/drivers/internal/legacy"
 )
 
 type Config struct {
@@ -25,26 +26,27 @@ type Config struct {
 
 type Device struct {
 	bus          drivers.SPI
-	cs           machine.Pin
-	dc           machine.Pin
-	rst          machine.Pin
-	busy         machine.Pin
+	cs           drivers.PinOutput
+	dc           drivers.PinOutput
+	rst          drivers.PinOutput
+	busy         drivers.PinInput
 	logicalWidth int16
 	width        int16
 	height       int16
 	buffer       []uint8
 	bufferLength uint32
 	rotation     Rotation
+	dirty        dirty.Rect // bytes and rows changed since the last Display
 }
 
 type Rotation uint8
 
 // New returns a new epd4in2 driver. Pass in a fully configured SPI bus.
-func New(bus drivers.SPI, csPin, dcPin, rstPin, busyPin machine.Pin) Device {
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	rstPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	busyPin.Configure(machine.PinConfig{Mode: machine.PinInput})
+func New(bus drivers.SPI, csPin, dcPin, rstPin drivers.PinOutput, busyPin drivers.PinInput) Device {
+	legacy.ConfigurePin(csPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(dcPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(rstPin, drivers.PinModeOutput)
+	legacy.ConfigurePin(busyPin, drivers.PinModeInput)
 	return Device{
 		bus:  bus,
 		cs:   csPin,
@@ -77,6 +79,7 @@ func (d *Device) Configure(cfg Config) {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.buffer[i] = 0xFF
 	}
+	d.dirty.All(d.logicalWidth/8, d.height)
 
 	d.cs.Low()
 	d.dc.Low()
@@ -239,15 +242,28 @@ func (d *Device) SetPixel(x int16, y int16, c color.RGBA) {
 		return
 	}
 	byteIndex := (uint32(x) + uint32(y)*uint32(d.logicalWidth)) / 8
+	b := d.buffer[byteIndex]
 	if c.R == 0 && c.G == 0 && c.B == 0 { // TRANSPARENT / WHITE
-		d.buffer[byteIndex] |= 0x80 >> uint8(x%8)
+		b |= 0x80 >> uint8(x%8)
 	} else { // WHITE / EMPTY
-		d.buffer[byteIndex] &^= 0x80 >> uint8(x%8)
+		b &^= 0x80 >> uint8(x%8)
+	}
+	if b != d.buffer[byteIndex] {
+		d.buffer[byteIndex] = b
+		d.dirty.Add(x/8, y)
 	}
 }
 
-// Display sends the buffer to the screen.
+// Display sends the part of the buffer that changed since the last call to
+// the screen, and refreshes it.
 func (d *Device) Display() error {
+	if d.dirty.Empty() {
+		return nil
+	}
+	area := d.dirty
+	d.dirty.Reset()
+	full := area.IsAll(d.logicalWidth/8, d.height)
+
 	d.SendCommand(RESOLUTION_SETTING)
 	d.SendData(uint8(d.height >> 8))
 	d.SendData(uint8(d.logicalWidth & 0xff))
@@ -260,17 +276,26 @@ func (d *Device) Display() error {
 	d.SendCommand(VCOM_AND_DATA_INTERVAL_SETTING)
 	d.SendCommand(0x97) //VBDF 17|D7 VBDW 97  VBDB 57  VBDF F7  VBDW 77  VBDB 37  VBDR B7
 
+	if !full {
+		d.setPartialWindow(area.X0*8, area.Y0, area.X1*8+7, area.Y1)
+	}
 	d.SendCommand(DATA_START_TRANSMISSION_1)
-	var i int16
-	for i = 0; i < d.logicalWidth/8*d.height; i++ {
-		d.SendData(0xFF) // bit set: white, bit reset: black
+	for j := area.Y0; j <= area.Y1; j++ {
+		for i := area.X0; i <= area.X1; i++ {
+			d.SendData(0xFF) // bit set: white, bit reset: black
+		}
 	}
 	time.Sleep(2 * time.Millisecond)
 	d.SendCommand(DATA_START_TRANSMISSION_2)
-	for i = 0; i < d.logicalWidth/8*d.height; i++ {
-		d.SendData(d.buffer[i])
+	for j := area.Y0; j <= area.Y1; j++ {
+		for i := area.X0; i <= area.X1; i++ {
+			d.SendData(d.buffer[int32(i)+int32(j)*int32(d.logicalWidth/8)])
+		}
 	}
 	time.Sleep(2 * time.Millisecond)
+	if !full {
+		d.SendCommand(PARTIAL_OUT)
+	}
 
 	d.SetLUT()
 
@@ -307,6 +332,24 @@ func (d *Device) ClearDisplay() {
 	d.SendCommand(DISPLAY_REFRESH)
 	time.Sleep(100 * time.Millisecond)
 	d.WaitUntilIdle()
+	d.dirty.All(d.logicalWidth/8, d.height)
+}
+
+// setPartialWindow restricts the data transmissions to an area of the display
+// RAM, from x0, y0 to x1, y1 (inclusive). The bits of x below 8 are ignored.
+func (d *Device) setPartialWindow(x0, y0, x1, y1 int16) {
+	d.SendCommand(PARTIAL_IN)
+	d.SendCommand(PARTIAL_WINDOW)
+	d.SendData(uint8(x0 >> 8))
+	d.SendData(uint8(x0) & 0xF8)
+	d.SendData(uint8(x1 >> 8))
+	d.SendData(uint8(x1) | 0x07)
+	d.SendData(uint8(y0 >> 8))
+	d.SendData(uint8(y0))
+	d.SendData(uint8(y1 >> 8))
+	d.SendData(uint8(y1))
+	d.SendData(0x01) // gates scan both inside and outside of the window
+	time.Sleep(2 * time.Millisecond)
 }
 
 // WaitUntilIdle waits until the display is ready
@@ -326,6 +369,7 @@ func (d *Device) ClearBuffer() {
 	for i := uint32(0); i < d.bufferLength; i++ {
 		d.buffer[i] = 0xFF
 	}
+	d.dirty.All(d.logicalWidth/8, d.height)
 }
 
 // Size returns the current size of the display.